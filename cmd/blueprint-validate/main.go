@@ -0,0 +1,186 @@
+// Standalone executable that validates a blueprint file for an image type
+// without building it. All the problems found are reported at once and the
+// exit code is non-zero if there are any.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distroregistry"
+)
+
+// Exit codes
+const (
+	exitValid   = 0
+	exitInvalid = 1
+	exitUsage   = 2
+)
+
+// customTOMLKeys returns the TOML keys of the fields of the struct type t
+// that are decoded by their own UnmarshalTOML method. The TOML decoder cannot
+// tell which keys below them are used, so they are never reported as unknown.
+func customTOMLKeys(t reflect.Type, prefix string) []string {
+	unmarshaler := reflect.TypeOf((*toml.Unmarshaler)(nil)).Elem()
+
+	var keys []string
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer || ft.Kind() == reflect.Slice || ft.Kind() == reflect.Map {
+			ft = ft.Elem()
+		}
+		if reflect.PtrTo(ft).Implements(unmarshaler) {
+			keys = append(keys, key)
+		} else if ft.Kind() == reflect.Struct {
+			keys = append(keys, customTOMLKeys(ft, key+".")...)
+		}
+	}
+	return keys
+}
+
+// unknownTOMLKeys returns the keys of the TOML blueprint that do not match a
+// blueprint field.
+func unknownTOMLKeys(md toml.MetaData) []string {
+	custom := customTOMLKeys(reflect.TypeOf(blueprint.Blueprint{}), "")
+
+	var unknown []string
+	for _, key := range md.Undecoded() {
+		name := key.String()
+		known := false
+		for _, prefix := range custom {
+			if strings.HasPrefix(name, prefix+".") {
+				known = true
+				break
+			}
+		}
+		if !known {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// loadBlueprint reads a blueprint in the TOML format if the file has a .toml
+// extension and in the JSON format otherwise. Fields that are not part of the
+// blueprint format are errors, as they are most likely typos.
+func loadBlueprint(path string) (*blueprint.Blueprint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var bp blueprint.Blueprint
+	if filepath.Ext(path) == ".toml" {
+		md, err := toml.Decode(string(data), &bp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse TOML blueprint: %w", err)
+		}
+		if unknown := unknownTOMLKeys(md); len(unknown) > 0 {
+			return nil, fmt.Errorf("unknown blueprint fields: %s", strings.Join(unknown, ", "))
+		}
+		return &bp, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&bp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON blueprint: %w", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("extra data found after the JSON blueprint")
+	}
+	return &bp, nil
+}
+
+// getImageType resolves the image type to validate the blueprint for. The
+// distro of the blueprint is used if none is given.
+func getImageType(distroName, archName, imgTypeName string, bp *blueprint.Blueprint) (distro.ImageType, error) {
+	if distroName == "" {
+		distroName = bp.Distro
+	}
+	if distroName == "" {
+		return nil, fmt.Errorf("no distribution specified on the command line or in the blueprint")
+	}
+
+	d := distroregistry.NewDefault().GetDistro(distroName)
+	if d == nil {
+		return nil, fmt.Errorf("invalid or unsupported distribution: %q", distroName)
+	}
+	arch, err := d.GetArch(archName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arch name %q for distro %q: %s", archName, distroName, err.Error())
+	}
+	imgType, err := arch.GetImageType(imgTypeName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image type %q for distro %q and arch %q: %s", imgTypeName, distroName, archName, err.Error())
+	}
+	return imgType, nil
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("blueprint-validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: blueprint-validate [flags] <blueprint.json|blueprint.toml>\n")
+		flags.PrintDefaults()
+	}
+
+	var distroName, archName, imgTypeName string
+	flags.StringVar(&distroName, "distro", "", "distribution (defaults to the distro of the blueprint)")
+	flags.StringVar(&archName, "arch", "x86_64", "architecture")
+	flags.StringVar(&imgTypeName, "image", "", "image type name (required)")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 || imgTypeName == "" {
+		flags.Usage()
+		return exitUsage
+	}
+	path := flags.Arg(0)
+
+	bp, err := loadBlueprint(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", path, err)
+		return exitInvalid
+	}
+
+	imgType, err := getImageType(distroName, archName, imgTypeName, bp)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	errs := imgType.Validate(bp, distro.ImageOptions{})
+	for _, err := range errs {
+		fmt.Fprintf(stderr, "%s: %s\n", path, err)
+	}
+	if len(errs) > 0 {
+		return exitInvalid
+	}
+
+	fmt.Fprintf(stdout, "%s: valid for %s/%s/%s\n", path, imgType.Arch().Distro().Name(), imgType.Arch().Name(), imgType.Name())
+	return exitValid
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeBlueprint writes the blueprint to a file with the given name in a
+// temporary directory and returns its path.
+func writeBlueprint(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func runValidate(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestValidBlueprints(t *testing.T) {
+	jsonPath := writeBlueprint(t, "bp.json", `{
+  "name": "valid",
+  "distro": "fedora-39",
+  "packages": [{"name": "tmux", "version": "3.3a"}],
+  "customizations": {"hostname": "homelab", "sysctl": {"vm.swappiness": "10"}}
+}`)
+	code, stdout, stderr := runValidate("-image", "qcow2", jsonPath)
+	assert.Equal(t, exitValid, code)
+	assert.Equal(t, jsonPath+": valid for fedora-39/x86_64/qcow2\n", stdout)
+	assert.Empty(t, stderr)
+
+	tomlPath := writeBlueprint(t, "bp.toml", `
+name = "valid"
+
+[[packages]]
+name = "tmux"
+
+[customizations]
+hostname = "homelab"
+`)
+	code, stdout, stderr = runValidate("-distro", "rhel-94", "-arch", "aarch64", "-image", "qcow2", tomlPath)
+	assert.Equal(t, exitValid, code)
+	assert.Equal(t, tomlPath+": valid for rhel-94/aarch64/qcow2\n", stdout)
+	assert.Empty(t, stderr)
+}
+
+func TestInvalidBlueprint(t *testing.T) {
+	path := writeBlueprint(t, "bp.toml", `
+name = "invalid"
+
+[[packages]]
+name = "bash"
+version = ">= 5.2"
+
+[[customizations.filesystem]]
+mountpoint = "/etc"
+size = 1073741824
+
+[customizations.sysctl]
+"vm.swappiness" = ""
+`)
+	code, stdout, stderr := runValidate("-distro", "fedora-39", "-image", "qcow2", path)
+	assert.Equal(t, exitInvalid, code)
+	assert.Empty(t, stdout)
+	assert.Equal(t, path+`: package "bash" has an invalid version ">= 5.2": must be [epoch:]version[-release]
+`+path+`: The following custom mountpoints are not supported ["/etc"]
+`+path+`: sysctl key "vm.swappiness" has an empty value
+`, stderr)
+}
+
+func TestUnparsableBlueprints(t *testing.T) {
+	path := writeBlueprint(t, "bp.json", `{"name": "typo", "pakages": []}`)
+	code, _, stderr := runValidate("-distro", "fedora-39", "-image", "qcow2", path)
+	assert.Equal(t, exitInvalid, code)
+	assert.Equal(t, path+": failed to parse JSON blueprint: json: unknown field \"pakages\"\n", stderr)
+
+	path = writeBlueprint(t, "bp.toml", "name = \"typo\"\n[customizations]\nhostnme = \"homelab\"\n")
+	code, _, stderr = runValidate("-distro", "fedora-39", "-image", "qcow2", path)
+	assert.Equal(t, exitInvalid, code)
+	assert.Equal(t, path+": unknown blueprint fields: customizations.hostnme\n", stderr)
+
+	path = writeBlueprint(t, "bp.toml", "name = \n")
+	code, _, stderr = runValidate("-distro", "fedora-39", "-image", "qcow2", path)
+	assert.Equal(t, exitInvalid, code)
+	assert.Contains(t, stderr, path+": failed to parse TOML blueprint: ")
+}
+
+func TestUsageErrors(t *testing.T) {
+	path := writeBlueprint(t, "bp.json", `{"name": "no-distro"}`)
+
+	code, _, stderr := runValidate(path)
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "Usage: blueprint-validate")
+
+	code, _, stderr = runValidate("-image", "qcow2", path)
+	assert.Equal(t, exitUsage, code)
+	assert.Equal(t, "no distribution specified on the command line or in the blueprint\n", stderr)
+
+	code, _, stderr = runValidate("-distro", "fedora-1", "-image", "qcow2", path)
+	assert.Equal(t, exitUsage, code)
+	assert.Equal(t, "invalid or unsupported distribution: \"fedora-1\"\n", stderr)
+
+	code, _, stderr = runValidate("-distro", "fedora-39", "-image", "floppy", path)
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, `invalid image type "floppy" for distro "fedora-39" and arch "x86_64"`)
+}
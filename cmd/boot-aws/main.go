@@ -9,14 +9,18 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
 	"github.com/osbuild/images/internal/cloud/awscloud"
+	"github.com/osbuild/images/internal/cloud/bootprovider"
 )
 
 // exitCheck can be deferred from the top of command functions to exit with an
@@ -45,13 +49,41 @@ ssh_authorized_keys:
 	return userData, nil
 }
 
-// resources created or allocated for an instance that can be cleaned up when
-// tearing down.
-type resources struct {
-	AMI           *string `json:"ami,omitempty"`
-	Snapshot      *string `json:"snapshot,omitempty"`
-	SecurityGroup *string `json:"security-group,omitempty"`
-	InstanceID    *string `json:"instance,omitempty"`
+// defaultUsername returns the conventional name of the cloud user of the
+// images of the given distro, e.g. "fedora", "rhel-9" or "centos-9".
+func defaultUsername(distroName string) (string, error) {
+	family, version, _ := strings.Cut(distroName, "-")
+	switch family {
+	case "fedora":
+		return "fedora", nil
+	case "rhel", "centos":
+		if strings.HasPrefix(version, "7") {
+			return "cloud-user", nil
+		}
+		return "ec2-user", nil
+	}
+	return "", fmt.Errorf("no default username for distro %q, use --username", distroName)
+}
+
+// getUsername returns the --username if set and the default username of the
+// --distro otherwise.
+func getUsername(flags *pflag.FlagSet) (string, error) {
+	username, err := flags.GetString("username")
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		return username, nil
+	}
+
+	distroName, err := flags.GetString("distro")
+	if err != nil {
+		return "", err
+	}
+	if distroName == "" {
+		return "", fmt.Errorf("either --username or --distro must be specified")
+	}
+	return defaultUsername(distroName)
 }
 
 func run(c string, args ...string) ([]byte, []byte, error) {
@@ -76,21 +108,14 @@ func run(c string, args ...string) ([]byte, []byte, error) {
 	return stdout, stderr, err
 }
 
-func getInstanceType(arch string) (string, error) {
-	switch arch {
-	case "x86_64":
-		return "t3.small", nil
-	case "aarch64":
-		return "t4g.medium", nil
-	default:
-		return "", fmt.Errorf("getInstanceType(): unknown architecture %q", arch)
-	}
-}
+// runner executes commands on the local machine. It is replaced in tests to
+// avoid calling out to ssh.
+var runner = run
 
 func sshRun(ip, user, key, hostsfile string, command ...string) error {
 	sshargs := []string{"-i", key, "-o", fmt.Sprintf("UserKnownHostsFile=%s", hostsfile), "-l", user, ip}
 	sshargs = append(sshargs, command...)
-	_, _, err := run("ssh", sshargs...)
+	_, _, err := runner("ssh", sshargs...)
 	if err != nil {
 		return err
 	}
@@ -98,7 +123,15 @@ func sshRun(ip, user, key, hostsfile string, command ...string) error {
 }
 
 func scpFile(ip, user, key, hostsfile, source, dest string) error {
-	_, _, err := run("scp", "-i", key, "-o", fmt.Sprintf("UserKnownHostsFile=%s", hostsfile), "--", source, fmt.Sprintf("%s@%s:%s", user, ip, dest))
+	_, _, err := runner("scp", "-i", key, "-o", fmt.Sprintf("UserKnownHostsFile=%s", hostsfile), "--", source, fmt.Sprintf("%s@%s:%s", user, ip, dest))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func scpFileFromRemote(ip, user, key, hostsfile, source, dest string) error {
+	_, _, err := runner("scp", "-i", key, "-o", fmt.Sprintf("UserKnownHostsFile=%s", hostsfile), "--", fmt.Sprintf("%s@%s:%s", user, ip, source), dest)
 	if err != nil {
 		return err
 	}
@@ -110,7 +143,7 @@ func keyscan(ip, filepath string) error {
 	maxTries := 30 // wait for at least 5 mins
 	var keyscanErr error
 	for try := 0; try < maxTries; try++ {
-		keys, _, keyscanErr = run("ssh-keyscan", ip)
+		keys, _, keyscanErr = runner("ssh-keyscan", ip)
 		if keyscanErr == nil {
 			break
 		}
@@ -133,114 +166,271 @@ func keyscan(ip, filepath string) error {
 	return nil
 }
 
-func newClientFromArgs(flags *pflag.FlagSet) (*awscloud.AWS, error) {
+// credentialsFromArgs returns the static credentials of the
+// --access-key-id, --secret-access-key and --session-token flags if they are
+// set and nil, for the credentials of the --profile or the default credential
+// chain of the AWS SDK, otherwise.
+func credentialsFromArgs(flags *pflag.FlagSet) (*credentials.Credentials, error) {
+	keyID, err := flags.GetString("access-key-id")
+	if err != nil {
+		return nil, err
+	}
+	secretKey, err := flags.GetString("secret-access-key")
+	if err != nil {
+		return nil, err
+	}
+	sessionToken, err := flags.GetString("session-token")
+	if err != nil {
+		return nil, err
+	}
+	profile, err := flags.GetString("profile")
+	if err != nil {
+		return nil, err
+	}
+
+	if keyID != "" || secretKey != "" {
+		if keyID == "" || secretKey == "" {
+			return nil, fmt.Errorf("--access-key-id and --secret-access-key must be specified together")
+		}
+		if profile != "" {
+			return nil, fmt.Errorf("--profile cannot be combined with --access-key-id and --secret-access-key")
+		}
+		return credentials.NewStaticCredentials(keyID, secretKey, sessionToken), nil
+	}
+	if sessionToken != "" {
+		return nil, fmt.Errorf("--session-token requires --access-key-id and --secret-access-key")
+	}
+	return nil, nil
+}
+
+// sessionFromArgs creates the AWS session of the flags. The shared config
+// and credentials files are read like by the AWS CLI, so that the --profile
+// can also be an SSO, role_arn or credential_process profile of
+// ~/.aws/config, and the default credential chain (environment, shared files
+// and instance profile) is used without the static credentials or a profile.
+func sessionFromArgs(flags *pflag.FlagSet) (*session.Session, error) {
 	region, err := flags.GetString("region")
 	if err != nil {
 		return nil, err
 	}
-	keyID, err := flags.GetString("access-key-id")
+	creds, err := credentialsFromArgs(flags)
 	if err != nil {
 		return nil, err
 	}
-	secretKey, err := flags.GetString("secret-access-key")
+	profile, err := flags.GetString("profile")
 	if err != nil {
 		return nil, err
 	}
-	sessionToken, err := flags.GetString("session-token")
+	endpointURL, err := flags.GetString("endpoint-url")
 	if err != nil {
 		return nil, err
 	}
 
-	return awscloud.New(region, keyID, secretKey, sessionToken)
+	options := session.Options{
+		Config: aws.Config{
+			Credentials: creds,
+			Region:      aws.String(region),
+		},
+		Profile:           profile,
+		SharedConfigState: session.SharedConfigEnable,
+	}
+	if endpointURL != "" {
+		options.Config.Endpoint = aws.String(endpointURL)
+		options.Config.S3ForcePathStyle = aws.Bool(true)
+	}
+	return session.NewSessionWithOptions(options)
 }
 
-func doSetup(a *awscloud.AWS, filename string, flags *pflag.FlagSet, res *resources) error {
-	username, err := flags.GetString("username")
+func newClientFromArgs(flags *pflag.FlagSet) (*awscloud.AWS, error) {
+	sess, err := sessionFromArgs(flags)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	sshPubKey, err := flags.GetString("ssh-pubkey")
+	return awscloud.NewFromSession(sess), nil
+}
+
+// parseTags parses a list of key=value pairs into a map of tags.
+func parseTags(pairs []string) (map[string]string, error) {
+	tags := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid tag %q: must be in the form key=value", pair)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// newProviderFromArgs returns the provider that boots the image, configured
+// from the command line flags.
+func newProviderFromArgs(flags *pflag.FlagSet) (bootprovider.Provider, error) {
+	a, options, err := awsOptionsFromArgs(flags)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if options.Arch == "" {
+		return nil, fmt.Errorf("--arch is required")
+	}
+	return bootprovider.NewAWS(a, options), nil
+}
 
-	userData, err := createUserData(username, sshPubKey)
+// awsOptionsFromArgs returns the AWS client and the options of the AWS
+// provider, configured from the command line flags.
+func awsOptionsFromArgs(flags *pflag.FlagSet) (*awscloud.AWS, bootprovider.AWSOptions, error) {
+	a, err := newClientFromArgs(flags)
 	if err != nil {
-		return fmt.Errorf("createUserData(): %s", err.Error())
+		return nil, bootprovider.AWSOptions{}, err
 	}
 
 	bucketName, err := flags.GetString("bucket")
 	if err != nil {
-		return err
+		return nil, bootprovider.AWSOptions{}, err
 	}
 	keyName, err := flags.GetString("s3-key")
 	if err != nil {
-		return err
+		return nil, bootprovider.AWSOptions{}, err
 	}
-
-	uploadOutput, err := a.Upload(filename, bucketName, keyName)
+	createBucket, err := flags.GetBool("create-bucket")
 	if err != nil {
-		return fmt.Errorf("Upload() failed: %s", err.Error())
+		return nil, bootprovider.AWSOptions{}, err
 	}
 
-	fmt.Printf("file uploaded to %s\n", aws.StringValue(&uploadOutput.Location))
-
 	var bootModePtr *string
 	if bootMode, err := flags.GetString("boot-mode"); bootMode != "" {
 		bootModePtr = &bootMode
 	} else if err != nil {
-		return err
+		return nil, bootprovider.AWSOptions{}, err
 	}
 
 	imageName, err := flags.GetString("ami-name")
 	if err != nil {
-		return err
+		return nil, bootprovider.AWSOptions{}, err
 	}
 
 	arch, err := flags.GetString("arch")
 	if err != nil {
-		return err
+		return nil, bootprovider.AWSOptions{}, err
 	}
 
-	ami, snapshot, err := a.Register(imageName, bucketName, keyName, nil, arch, bootModePtr)
+	imageAvailableTimeout, err := flags.GetDuration("ami-available-timeout")
 	if err != nil {
-		return fmt.Errorf("Register(): %s", err.Error())
+		return nil, bootprovider.AWSOptions{}, err
 	}
 
-	res.AMI = ami
-	res.Snapshot = snapshot
+	tagPairs, err := flags.GetStringArray("tags")
+	if err != nil {
+		return nil, bootprovider.AWSOptions{}, err
+	}
+	tags, err := parseTags(tagPairs)
+	if err != nil {
+		return nil, bootprovider.AWSOptions{}, err
+	}
 
-	fmt.Printf("AMI registered: %s\n", aws.StringValue(ami))
+	var spotOptions *awscloud.SpotOptions
+	if spot, err := flags.GetBool("spot"); spot {
+		maxPrice, err := flags.GetString("spot-max-price")
+		if err != nil {
+			return nil, bootprovider.AWSOptions{}, err
+		}
+		fallback, err := flags.GetBool("spot-fallback")
+		if err != nil {
+			return nil, bootprovider.AWSOptions{}, err
+		}
+		spotOptions = &awscloud.SpotOptions{
+			MaxPrice:         maxPrice,
+			FallbackOnDemand: fallback,
+		}
+	} else if err != nil {
+		return nil, bootprovider.AWSOptions{}, err
+	}
 
-	securityGroupName := fmt.Sprintf("image-boot-tests-%s", uuid.New().String())
-	securityGroup, err := a.CreateSecurityGroupEC2(securityGroupName, "image-tests-security-group")
+	subnetID, err := flags.GetString("subnet-id")
+	if err != nil {
+		return nil, bootprovider.AWSOptions{}, err
+	}
+	vpcID, err := flags.GetString("vpc-id")
 	if err != nil {
-		return fmt.Errorf("CreateSecurityGroup(): %s", err.Error())
+		return nil, bootprovider.AWSOptions{}, err
 	}
 
-	res.SecurityGroup = securityGroup.GroupId
+	sshCIDR, err := flags.GetString("ssh-cidr")
+	if err != nil {
+		return nil, bootprovider.AWSOptions{}, err
+	}
 
-	_, err = a.AuthorizeSecurityGroupIngressEC2(securityGroup.GroupId, "0.0.0.0/0", 22, 22, "tcp")
+	var rootVolume *awscloud.RootVolumeOptions
+	rootVolumeSize, err := flags.GetInt64("root-volume-size")
+	if err != nil {
+		return nil, bootprovider.AWSOptions{}, err
+	}
+	rootVolumeType, err := flags.GetString("root-volume-type")
 	if err != nil {
-		return fmt.Errorf("AuthorizeSecurityGroupIngressEC2(): %s", err.Error())
+		return nil, bootprovider.AWSOptions{}, err
+	}
+	if rootVolumeSize < 0 {
+		return nil, bootprovider.AWSOptions{}, fmt.Errorf("invalid --root-volume-size %d: must not be negative", rootVolumeSize)
 	}
+	if rootVolumeSize != 0 || rootVolumeType != "" {
+		rootVolume = &awscloud.RootVolumeOptions{
+			Size: rootVolumeSize,
+			Type: rootVolumeType,
+		}
+	}
+
+	return a, bootprovider.AWSOptions{
+		Bucket:    bucketName,
+		Key:       keyName,
+		ImageName: imageName,
+		Arch:      arch,
+		BootMode:  bootModePtr,
+		Tags:      tags,
+		Spot:      spotOptions,
+
+		RootVolume:            rootVolume,
+		SubnetID:              subnetID,
+		VPCID:                 vpcID,
+		SSHCIDR:               sshCIDR,
+		CreateBucket:          createBucket,
+		ImageAvailableTimeout: imageAvailableTimeout,
+	}, nil
+}
 
-	instance, err := getInstanceType(arch)
+func doSetup(p bootprovider.Provider, filename string, flags *pflag.FlagSet, res *bootprovider.Resources) error {
+	username, err := getUsername(flags)
 	if err != nil {
 		return err
 	}
-	runResult, err := a.RunInstanceEC2(ami, securityGroup.GroupId, userData, instance)
+	sshPubKey, err := flags.GetString("ssh-pubkey")
 	if err != nil {
-		return fmt.Errorf("RunInstanceEC2(): %s", err.Error())
+		return err
 	}
-	instanceID := runResult.Instances[0].InstanceId
-	res.InstanceID = instanceID
 
-	ip, err := a.GetInstanceAddress(instanceID)
+	userData, err := createUserData(username, sshPubKey)
 	if err != nil {
-		return fmt.Errorf("GetInstanceAddress(): %s", err.Error())
+		return fmt.Errorf("createUserData(): %s", err.Error())
+	}
+
+	res.Provider = p.Name()
+	res.RunID = uuid.New().String()
+
+	if err := p.Upload(filename, res); err != nil {
+		return err
 	}
-	fmt.Printf("Instance %s is running and has IP address %s\n", *instanceID, ip)
+
+	if err := p.Register(res); err != nil {
+		return err
+	}
+
+	if err := p.Boot(userData, res); err != nil {
+		return err
+	}
+
+	ip, err := p.Address(res)
+	if err != nil {
+		return fmt.Errorf("Address(): %s", err.Error())
+	}
+	fmt.Printf("Instance is running and has IP address %s\n", ip)
 	return nil
 }
 
@@ -251,7 +441,7 @@ func setup(cmd *cobra.Command, args []string) {
 	filename := args[0]
 	flags := cmd.Flags()
 
-	a, err := newClientFromArgs(flags)
+	p, err := newProviderFromArgs(flags)
 	if err != nil {
 		fnerr = err
 		return
@@ -263,63 +453,52 @@ func setup(cmd *cobra.Command, args []string) {
 		fnerr = err
 		return
 	}
-	res := &resources{}
+	res := &bootprovider.Resources{}
 
-	fnerr = doSetup(a, filename, flags, res)
+	fnerr = doSetup(p, filename, flags, res)
 	if fnerr != nil {
 		fmt.Fprintf(os.Stderr, "setup() failed: %s\n", fnerr.Error())
 		fmt.Fprint(os.Stderr, "tearing down resources\n")
-		tderr := doTeardown(a, res)
+		tderr := doTeardown(p, res)
 		if tderr != nil {
 			fmt.Fprintf(os.Stderr, "teardown(): %s\n", tderr.Error())
 		}
 	}
 
+	if err := writeResources(res, resourcesFile); err != nil {
+		fnerr = err
+		return
+	}
+}
+
+// writeResources stores the IDs of the resources in the resources file, for a
+// later teardown.
+func writeResources(res *bootprovider.Resources, resourcesFile string) error {
 	resdata, err := json.MarshalIndent(res, "", "  ")
 	if err != nil {
-		fnerr = fmt.Errorf("failed to marshal resources data: %s", err.Error())
-		return
+		return fmt.Errorf("failed to marshal resources data: %s", err.Error())
 	}
 	resfile, err := os.Create(resourcesFile)
 	if err != nil {
-		fnerr = fmt.Errorf("failed to create resources file: %s", err.Error())
-		return
+		return fmt.Errorf("failed to create resources file: %s", err.Error())
 	}
 	_, err = resfile.Write(resdata)
 	if err != nil {
-		fnerr = fmt.Errorf("failed to write resources file: %s", err.Error())
-		return
+		resfile.Close()
+		return fmt.Errorf("failed to write resources file: %s", err.Error())
 	}
 	fmt.Printf("IDs for any newly created resources are stored in %s. Use the teardown command to clean them up.\n", resourcesFile)
 	if err = resfile.Close(); err != nil {
-		fmt.Fprintf(os.Stderr, "error closing resources file: %s\n", err.Error())
-		fnerr = err
-		return
+		return fmt.Errorf("error closing resources file: %s", err.Error())
 	}
+	return nil
 }
 
-func doTeardown(aws *awscloud.AWS, res *resources) error {
-	if res.InstanceID != nil {
-		fmt.Printf("terminating instance %s\n", *res.InstanceID)
-		if _, err := aws.TerminateInstanceEC2(res.InstanceID); err != nil {
-			return fmt.Errorf("failed to terminate instance: %v", err)
-		}
+func doTeardown(p bootprovider.Provider, res *bootprovider.Resources) error {
+	if res.Provider != "" && res.Provider != p.Name() {
+		return fmt.Errorf("resources were created by provider %q, cannot tear them down with %q", res.Provider, p.Name())
 	}
-
-	if res.SecurityGroup != nil {
-		fmt.Printf("deleting security group %s\n", *res.SecurityGroup)
-		if _, err := aws.DeleteSecurityGroupEC2(res.SecurityGroup); err != nil {
-			return fmt.Errorf("cannot delete the security group: %v", err)
-		}
-	}
-
-	if res.AMI != nil {
-		fmt.Printf("deleting EC2 image %s and snapshot %s\n", *res.AMI, *res.Snapshot)
-		if err := aws.DeleteEC2Image(res.AMI, res.Snapshot); err != nil {
-			return fmt.Errorf("failed to deregister image: %v", err)
-		}
-	}
-	return nil
+	return p.Teardown(res)
 }
 
 func teardown(cmd *cobra.Command, args []string) {
@@ -328,7 +507,7 @@ func teardown(cmd *cobra.Command, args []string) {
 
 	flags := cmd.Flags()
 
-	a, err := newClientFromArgs(flags)
+	p, err := newProviderFromArgs(flags)
 	if err != nil {
 		fnerr = err
 		return
@@ -339,7 +518,7 @@ func teardown(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	res := &resources{}
+	res := &bootprovider.Resources{}
 	resfile, err := os.Open(resourcesFile)
 	if err != nil {
 		fnerr = fmt.Errorf("failed to open resources file: %s", err.Error())
@@ -355,31 +534,205 @@ func teardown(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	fnerr = doTeardown(a, res)
+	fnerr = doTeardown(p, res)
+}
+
+func doReap(a *awscloud.AWS, olderThan time.Duration, dryRun bool) error {
+	tagged, err := a.DescribeResourcesByTagKey(bootprovider.RunIDTagKey)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	stale := tagged.OlderThan(bootprovider.RunIDTagKey, cutoff)
+	if stale.Empty() {
+		fmt.Printf("no resources tagged with %s older than %s\n", bootprovider.RunIDTagKey, olderThan)
+		return nil
+	}
+
+	action := "deleting"
+	if dryRun {
+		action = "would delete"
+	}
+
+	// keep going when a deletion fails so that one stuck resource doesn't
+	// keep all the others around, but report the failure at the end
+	var failed int
+	reapErr := func(format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+		failed++
+	}
+
+	for _, instance := range stale.Instances {
+		fmt.Printf("%s instance %s (launched %s)\n", action, aws.StringValue(instance.InstanceId), aws.TimeValue(instance.LaunchTime))
+		if dryRun {
+			continue
+		}
+		if _, err := a.TerminateInstanceEC2(instance.InstanceId); err != nil {
+			reapErr("failed to terminate instance %s: %v", aws.StringValue(instance.InstanceId), err)
+		}
+	}
+
+	// security groups can only be deleted after the instances using them are
+	// terminated
+	for _, group := range stale.SecurityGroups {
+		fmt.Printf("%s security group %s\n", action, aws.StringValue(group.GroupId))
+		if dryRun {
+			continue
+		}
+		if _, err := a.DeleteSecurityGroupEC2(group.GroupId); err != nil {
+			reapErr("failed to delete security group %s: %v", aws.StringValue(group.GroupId), err)
+		}
+	}
+
+	// snapshots can only be deleted after the images using them are
+	// deregistered
+	for _, image := range stale.Images {
+		fmt.Printf("%s image %s (created %s)\n", action, aws.StringValue(image.ImageId), aws.StringValue(image.CreationDate))
+		if dryRun {
+			continue
+		}
+		if _, err := a.DeregisterImageEC2(image.ImageId); err != nil {
+			reapErr("failed to deregister image %s: %v", aws.StringValue(image.ImageId), err)
+		}
+	}
+
+	for _, snapshot := range stale.Snapshots {
+		fmt.Printf("%s snapshot %s (created %s)\n", action, aws.StringValue(snapshot.SnapshotId), aws.TimeValue(snapshot.StartTime))
+		if dryRun {
+			continue
+		}
+		if _, err := a.DeleteSnapshotEC2(snapshot.SnapshotId); err != nil {
+			reapErr("failed to delete snapshot %s: %v", aws.StringValue(snapshot.SnapshotId), err)
+		}
+	}
+
+	for _, bucket := range stale.Buckets {
+		fmt.Printf("%s S3 bucket %s (created %s)\n", action, aws.StringValue(bucket.Name), aws.TimeValue(bucket.CreationDate))
+		if dryRun {
+			continue
+		}
+		if err := a.DeleteBucket(aws.StringValue(bucket.Name)); err != nil {
+			reapErr("failed to delete S3 bucket %s: %v", aws.StringValue(bucket.Name), err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d resources", failed)
+	}
+	return nil
 }
 
-func doRunExec(a *awscloud.AWS, filename string, flags *pflag.FlagSet, res *resources) error {
+func reap(cmd *cobra.Command, args []string) {
+	var fnerr error
+	defer func() { exitCheck(fnerr) }()
+
+	flags := cmd.Flags()
+
+	a, err := newClientFromArgs(flags)
+	if err != nil {
+		fnerr = err
+		return
+	}
+
+	olderThan, err := flags.GetDuration("older-than")
+	if err != nil {
+		fnerr = err
+		return
+	}
+	if olderThan <= 0 {
+		fnerr = fmt.Errorf("--older-than must be a positive duration")
+		return
+	}
+
+	dryRun, err := flags.GetBool("dry-run")
+	if err != nil {
+		fnerr = err
+		return
+	}
+
+	fnerr = doReap(a, olderThan, dryRun)
+}
+
+// waitForCloudInit blocks until cloud-init has finished on the remote host
+// or the timeout expires. Users and their keys are created by cloud-init, so
+// logging in before it's done can fail.
+func waitForCloudInit(ip, user, key, hostsfile string, timeout time.Duration) error {
+	// use the remote timeout command so that a hung cloud-init can't block
+	// the ssh session forever
+	seconds := fmt.Sprintf("%d", int(timeout.Seconds()))
+	if err := sshRun(ip, user, key, hostsfile, "timeout", seconds, "cloud-init", "status", "--wait"); err != nil {
+		return fmt.Errorf("waiting for cloud-init to finish failed: %w", err)
+	}
+	return nil
+}
+
+func doRunExec(p bootprovider.Provider, filename string, flags *pflag.FlagSet, res *bootprovider.Resources) error {
+	ip, err := p.Address(res)
+	if err != nil {
+		return err
+	}
+	return runOnHost(ip, filename, flags)
+}
+
+// runOnHost copies the executable to the host at the given address and runs
+// it.
+func runOnHost(ip, filename string, flags *pflag.FlagSet) error {
 	privKey, err := flags.GetString("ssh-privkey")
 	if err != nil {
 		return err
 	}
 
-	username, err := flags.GetString("username")
+	username, err := getUsername(flags)
 	if err != nil {
 		return err
 	}
 
-	tmpdir, err := os.MkdirTemp("", "boot-test-*")
+	skipCloudInitWait, err := flags.GetBool("skip-cloud-init-wait")
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(tmpdir)
 
-	hostsfile := filepath.Join(tmpdir, "known_hosts")
-	ip, err := a.GetInstanceAddress(res.InstanceID)
+	cloudInitTimeout, err := flags.GetDuration("cloud-init-timeout")
+	if err != nil {
+		return err
+	}
+
+	interpreter, err := flags.GetString("interpreter")
 	if err != nil {
 		return err
 	}
+
+	fileInfo, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	entrypoint, err := flags.GetString("entrypoint")
+	if err != nil {
+		return err
+	}
+	if fileInfo.IsDir() && entrypoint == "" {
+		return fmt.Errorf("%s is a directory: --entrypoint is required to run a bundle", filename)
+	}
+
+	logDir, err := flags.GetString("log-dir")
+	if err != nil {
+		return err
+	}
+
+	logPaths, err := flags.GetStringSlice("log-paths")
+	if err != nil {
+		return err
+	}
+
+	tmpdir, err := os.MkdirTemp("", "boot-test-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	hostsfile := filepath.Join(tmpdir, "known_hosts")
 	if err := keyscan(ip, hostsfile); err != nil {
 		return err
 	}
@@ -389,16 +742,165 @@ func doRunExec(a *awscloud.AWS, filename string, flags *pflag.FlagSet, res *reso
 		return err
 	}
 
+	// from here on the host is reachable, so logs can be collected if
+	// anything fails
+	err = func() error {
+		if !skipCloudInitWait {
+			if err := waitForCloudInit(ip, username, privKey, hostsfile, cloudInitTimeout); err != nil {
+				return err
+			}
+		}
+		if fileInfo.IsDir() {
+			return runBundle(ip, username, privKey, hostsfile, tmpdir, filename, interpreter, entrypoint)
+		}
+		return runExecutable(ip, username, privKey, hostsfile, filename, interpreter)
+	}()
+
+	if err != nil && logDir != "" {
+		// report log collection errors but return the error that caused
+		// the collection
+		if logErr := collectLogs(ip, username, privKey, hostsfile, logDir, logPaths); logErr != nil {
+			fmt.Fprintf(os.Stderr, "failed to collect logs from remote host: %s\n", logErr.Error())
+		}
+	}
+	return err
+}
+
+// runExecutable copies the executable to the remote host and runs it.
+func runExecutable(ip, user, key, hostsfile, filename, interpreter string) error {
 	// copy the executable without its path to the remote host
 	destination := filepath.Base(filename)
 
 	// copy the executable
-	if err := scpFile(ip, username, privKey, hostsfile, filename, destination); err != nil {
+	if err := scpFile(ip, user, key, hostsfile, filename, destination); err != nil {
 		return err
 	}
 
 	// run the executable
-	return sshRun(ip, username, privKey, hostsfile, fmt.Sprintf("./%s", destination))
+	return sshRun(ip, user, key, hostsfile, remoteCommand(interpreter, destination))
+}
+
+// runBundle copies the directory to the remote host as a tarball, extracts
+// it, and runs the entrypoint inside it. The tarball is created in tmpdir.
+func runBundle(ip, user, key, hostsfile, tmpdir, dirname, interpreter, entrypoint string) error {
+	// bundle the directory into a tarball, copy it to the remote host, and
+	// extract it there
+	bundleDir := filepath.Base(filepath.Clean(dirname))
+	tarball := bundleDir + ".tar"
+	tarballPath := filepath.Join(tmpdir, tarball)
+	if _, _, err := runner("tar", "-C", filepath.Dir(filepath.Clean(dirname)), "-cf", tarballPath, bundleDir); err != nil {
+		return fmt.Errorf("failed to create bundle from %s: %w", dirname, err)
+	}
+	if err := scpFile(ip, user, key, hostsfile, tarballPath, tarball); err != nil {
+		return err
+	}
+	if err := sshRun(ip, user, key, hostsfile, "tar", "-xf", tarball); err != nil {
+		return fmt.Errorf("failed to extract bundle on remote host: %w", err)
+	}
+
+	// run the entrypoint from inside the bundle so that it can find the
+	// other files with relative paths
+	return sshRun(ip, user, key, hostsfile, "cd", bundleDir, "&&", remoteCommand(interpreter, entrypoint))
+}
+
+// remoteLogDir is the name of the directory on the remote host where logs are
+// gathered before archiving them.
+const remoteLogDir = "boot-aws-logs"
+
+// collectLogs saves the journal of the current boot and archives it together
+// with the given paths on the remote host, then copies the archive into
+// logDir.
+func collectLogs(ip, user, key, hostsfile, logDir string, paths []string) error {
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return err
+	}
+
+	if err := sshRun(ip, user, key, hostsfile, "mkdir", "-p", remoteLogDir, "&&", "sudo", "journalctl", "-b", "--no-pager", ">", remoteLogDir+"/journal.log"); err != nil {
+		return fmt.Errorf("failed to save the journal: %w", err)
+	}
+
+	archive := remoteLogDir + ".tar.gz"
+	tarCmd := append([]string{"sudo", "tar", "--ignore-failed-read", "-czf", archive, remoteLogDir}, paths...)
+	if err := sshRun(ip, user, key, hostsfile, tarCmd...); err != nil {
+		return fmt.Errorf("failed to archive logs: %w", err)
+	}
+
+	dest := filepath.Join(logDir, fmt.Sprintf("logs-%s.tar.gz", ip))
+	if err := scpFileFromRemote(ip, user, key, hostsfile, archive, dest); err != nil {
+		return fmt.Errorf("failed to copy logs: %w", err)
+	}
+	fmt.Printf("Logs from the remote host were saved to %s\n", dest)
+	return nil
+}
+
+// remoteCommand returns the command that runs the executable at the given
+// path, relative to the working directory, with the interpreter if one is
+// specified.
+func remoteCommand(interpreter, path string) string {
+	command := fmt.Sprintf("./%s", path)
+	if interpreter != "" {
+		command = fmt.Sprintf("%s %s", interpreter, command)
+	}
+	return command
+}
+
+// doRun sets up the image, runs the executable on the instance and tears it
+// down. With --keep-running, the resources are written to the resources file
+// instead of being torn down, even if setting up or running failed, and the
+// command to connect to the instance is printed to stdout.
+func doRun(p bootprovider.Provider, image, executable string, flags *pflag.FlagSet, res *bootprovider.Resources, stdout io.Writer) (fnerr error) {
+	keepRunning, err := flags.GetBool("keep-running")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if !keepRunning {
+			if tderr := doTeardown(p, res); tderr != nil {
+				// report it but return the error of the run
+				fmt.Fprintf(os.Stderr, "teardown(): %s\n", tderr.Error())
+			}
+			return
+		}
+
+		if err := keepResources(p, flags, res, stdout); err != nil && fnerr == nil {
+			fnerr = err
+		}
+	}()
+
+	if err := doSetup(p, image, flags, res); err != nil {
+		return err
+	}
+
+	return doRunExec(p, executable, flags, res)
+}
+
+// keepResources writes the resources to the resources file and prints the
+// command to connect to the instance, if it is running.
+func keepResources(p bootprovider.Provider, flags *pflag.FlagSet, res *bootprovider.Resources, stdout io.Writer) error {
+	resourcesFile, err := flags.GetString("resourcefile")
+	if err != nil {
+		return err
+	}
+	if err := writeResources(res, resourcesFile); err != nil {
+		return err
+	}
+
+	ip, err := p.Address(res)
+	if err != nil {
+		fmt.Fprintf(stdout, "The instance is not running: %s\n", err.Error())
+		return nil
+	}
+	privKey, err := flags.GetString("ssh-privkey")
+	if err != nil {
+		return err
+	}
+	username, err := getUsername(flags)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "The instance is kept running, connect to it with:\n  ssh -i %s %s@%s\n", privKey, username, ip)
+	return nil
 }
 
 func runExec(cmd *cobra.Command, args []string) {
@@ -409,26 +911,133 @@ func runExec(cmd *cobra.Command, args []string) {
 	executable := args[1]
 	flags := cmd.Flags()
 
-	a, fnerr := newClientFromArgs(flags)
+	p, fnerr := newProviderFromArgs(flags)
 	if fnerr != nil {
 		return
 	}
 
-	res := &resources{}
-	defer func() {
-		tderr := doTeardown(a, res)
-		if tderr != nil {
-			// report it but let the exitCheck() handle fnerr
-			fmt.Fprintf(os.Stderr, "teardown(): %s\n", tderr.Error())
+	fnerr = doRun(p, image, executable, flags, &bootprovider.Resources{}, os.Stdout)
+}
+
+// archImage is an image to boot and the architecture it is built for.
+type archImage struct {
+	arch  string
+	image string
+}
+
+// parseArchImages parses a list of arch=image pairs. Each architecture can
+// only be given once.
+func parseArchImages(pairs []string) ([]archImage, error) {
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("at least one --image arch=image pair is required")
+	}
+	images := make([]archImage, 0, len(pairs))
+	seen := make(map[string]bool, len(pairs))
+	for _, pair := range pairs {
+		arch, image, found := strings.Cut(pair, "=")
+		if !found || arch == "" || image == "" {
+			return nil, fmt.Errorf("invalid image %q: must be in the form arch=image", pair)
 		}
-	}()
+		if seen[arch] {
+			return nil, fmt.Errorf("duplicate image for arch %s", arch)
+		}
+		seen[arch] = true
+		images = append(images, archImage{arch: arch, image: image})
+	}
+	return images, nil
+}
+
+// doRunMultiArch boots the images of all the architectures concurrently and
+// runs the executable on each of them. Every architecture has its own
+// provider and resources, which are torn down independently of the other
+// architectures, even if they fail. The returned error lists the failures of
+// all the architectures.
+func doRunMultiArch(newProvider func(arch string) (bootprovider.Provider, error), images []archImage, executable string, flags *pflag.FlagSet) (map[string]*bootprovider.Resources, error) {
+	resources := make(map[string]*bootprovider.Resources, len(images))
+	errs := make([]error, len(images))
+	for _, img := range images {
+		resources[img.arch] = &bootprovider.Resources{}
+	}
+
+	var wg sync.WaitGroup
+	for idx, img := range images {
+		wg.Add(1)
+		go func(idx int, img archImage) {
+			defer wg.Done()
+			res := resources[img.arch]
+
+			p, err := newProvider(img.arch)
+			if err != nil {
+				errs[idx] = err
+				return
+			}
+			defer func() {
+				if tderr := doTeardown(p, res); tderr != nil {
+					fmt.Fprintf(os.Stderr, "%s: teardown(): %s\n", img.arch, tderr.Error())
+					if errs[idx] == nil {
+						errs[idx] = tderr
+					}
+				}
+			}()
+
+			if err := doSetup(p, img.image, flags, res); err != nil {
+				errs[idx] = err
+				return
+			}
+			errs[idx] = doRunExec(p, executable, flags, res)
+		}(idx, img)
+	}
+	wg.Wait()
+
+	var failures []string
+	for idx, err := range errs {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", images[idx].arch, err.Error()))
+		} else {
+			fmt.Printf("%s: passed\n", images[idx].arch)
+		}
+	}
+	if len(failures) > 0 {
+		return resources, fmt.Errorf("%d of %d architectures failed:\n%s", len(failures), len(images), strings.Join(failures, "\n"))
+	}
+	return resources, nil
+}
+
+func runMultiArch(cmd *cobra.Command, args []string) {
+	var fnerr error
+	defer func() { exitCheck(fnerr) }()
+
+	executable := args[0]
+	flags := cmd.Flags()
 
-	fnerr = doSetup(a, image, flags, res)
+	pairs, fnerr := flags.GetStringArray("image")
+	if fnerr != nil {
+		return
+	}
+	images, fnerr := parseArchImages(pairs)
 	if fnerr != nil {
 		return
 	}
 
-	fnerr = doRunExec(a, executable, flags, res)
+	a, options, fnerr := awsOptionsFromArgs(flags)
+	if fnerr != nil {
+		return
+	}
+	if options.CreateBucket && len(images) > 1 {
+		fnerr = fmt.Errorf("--create-bucket cannot be used with more than one architecture, the bucket is shared")
+		return
+	}
+
+	// every architecture uploads and registers its own image
+	newProvider := func(arch string) (bootprovider.Provider, error) {
+		archOptions := options
+		archOptions.Arch = arch
+		archOptions.Key = fmt.Sprintf("%s-%s", options.Key, arch)
+		archOptions.ImageName = fmt.Sprintf("%s-%s", options.ImageName, arch)
+		return bootprovider.NewAWS(a, archOptions), nil
+	}
+
+	_, fnerr = doRunMultiArch(newProvider, images, executable, flags)
 }
 
 func setupCLI() *cobra.Command {
@@ -439,21 +1048,33 @@ func setupCLI() *cobra.Command {
 	}
 
 	rootFlags := rootCmd.PersistentFlags()
-	rootFlags.String("access-key-id", "", "access key ID")
+	rootFlags.String("access-key-id", "", "access key ID (default: the credentials of the --profile or the default AWS credential chain)")
 	rootFlags.String("secret-access-key", "", "secret access key")
 	rootFlags.String("session-token", "", "session token")
+	rootFlags.String("profile", "", "named profile of the shared config or credentials file to use instead of the access key")
 	rootFlags.String("region", "", "target region")
+	rootFlags.String("endpoint-url", "", "URL of the AWS API endpoint to use instead of the AWS one, with path-style S3 addressing (e.g. LocalStack or MinIO)")
 	rootFlags.String("bucket", "", "target S3 bucket name")
 	rootFlags.String("s3-key", "", "target S3 key name")
+	rootFlags.Bool("create-bucket", false, "create the S3 bucket before uploading and delete it on teardown; it is tagged with the run ID so that reap deletes it too")
 	rootFlags.String("ami-name", "", "AMI name")
-	rootFlags.String("arch", "", "arch (x86_64 or aarch64)")
+	rootFlags.String("arch", "", "arch (x86_64 or aarch64), required by all subcommands but run-multiarch")
 	rootFlags.String("boot-mode", "", "boot mode (legacy-bios, uefi, uefi-preferred)")
-	rootFlags.String("username", "", "name of the user to create on the system")
+	rootFlags.Duration("ami-available-timeout", bootprovider.DefaultImageAvailableTimeout, "maximum time to wait for the registered AMI to become available")
+	rootFlags.String("username", "", "name of the user to create on the system (default: the cloud user of the --distro)")
+	rootFlags.String("distro", "", "distro of the image (e.g. fedora-39, rhel-9), used to choose the default --username")
 	rootFlags.String("ssh-pubkey", "", "path to user's public ssh key")
 	rootFlags.String("ssh-privkey", "", "path to user's private ssh key")
+	rootFlags.Bool("spot", false, "request a spot instance instead of an on-demand instance")
+	rootFlags.String("spot-max-price", "", "maximum hourly price in USD for the spot instance (default: the on-demand price)")
+	rootFlags.Bool("spot-fallback", false, "launch an on-demand instance if the spot request can't be fulfilled")
+	rootFlags.Int64("root-volume-size", 0, "size of the root volume of the instance in GiB (default: the size of the image)")
+	rootFlags.String("root-volume-type", "", "EBS volume type of the root volume of the instance, e.g. gp3 (default: the type of the image)")
+	rootFlags.String("subnet-id", "", "subnet to launch the instance into, which must assign public IP addresses (default: the default subnet of the default VPC)")
+	rootFlags.String("vpc-id", "", "VPC of the --subnet-id, checked against the VPC the subnet belongs to")
+	rootFlags.String("ssh-cidr", "", "IPv4 CIDR allowed to connect to the instance with ssh, e.g. 0.0.0.0/0 for everyone (default: the public IP address of the caller)")
+	rootFlags.StringArray("tags", nil, "tag to apply to all created resources in the form key=value (can be specified multiple times)")
 
-	exitCheck(rootCmd.MarkPersistentFlagRequired("access-key-id"))
-	exitCheck(rootCmd.MarkPersistentFlagRequired("secret-access-key"))
 	exitCheck(rootCmd.MarkPersistentFlagRequired("region"))
 	exitCheck(rootCmd.MarkPersistentFlagRequired("bucket"))
 
@@ -463,11 +1084,6 @@ func setupCLI() *cobra.Command {
 	// TODO: make it optional and use UUID if not specified
 	exitCheck(rootCmd.MarkPersistentFlagRequired("ami-name"))
 
-	exitCheck(rootCmd.MarkPersistentFlagRequired("arch"))
-
-	// TODO: make it optional and use a default
-	exitCheck(rootCmd.MarkPersistentFlagRequired("username"))
-
 	// TODO: make ssh key pair optional for 'run' and if not specified generate
 	// a temporary key pair
 	exitCheck(rootCmd.MarkPersistentFlagRequired("ssh-privkey"))
@@ -492,14 +1108,48 @@ func setupCLI() *cobra.Command {
 	teardownCmd.Flags().StringP("resourcefile", "r", "resources.json", "path to store the resource IDs")
 	rootCmd.AddCommand(teardownCmd)
 
+	reapCmd := &cobra.Command{
+		Use:   "reap --older-than <duration> [--dry-run=false]",
+		Short: fmt.Sprintf("delete resources tagged with %s that are older than the given duration, e.g. when setup crashed before writing the resources file", bootprovider.RunIDTagKey),
+		Long:  fmt.Sprintf("Delete the instances, security groups, images, snapshots and S3 buckets (created with --create-bucket) of the region that are tagged with %s and older than the given duration, e.g. when setup crashed before writing the resources file.", bootprovider.RunIDTagKey),
+		Args:  cobra.NoArgs,
+		Run:   reap,
+	}
+	reapCmd.Flags().Duration("older-than", 0, "only delete resources created more than this long ago (e.g. 6h)")
+	reapCmd.Flags().Bool("dry-run", true, "only list the resources that would be deleted")
+	exitCheck(reapCmd.MarkFlagRequired("older-than"))
+	rootCmd.AddCommand(reapCmd)
+
 	runCmd := &cobra.Command{
 		Use:   "run <image> <executable>",
-		Short: "upload and boot an image, then upload the specified executable and run it on the remote host",
+		Short: "upload and boot an image, then upload the specified executable (or directory bundle) and run it on the remote host",
 		Args:  cobra.ExactArgs(2),
 		Run:   runExec,
 	}
+	runCmd.Flags().Bool("skip-cloud-init-wait", false, "don't wait for cloud-init to finish before copying the executable (for images without cloud-init)")
+	runCmd.Flags().Duration("cloud-init-timeout", 10*time.Minute, "maximum time to wait for cloud-init to finish")
+	runCmd.Flags().String("interpreter", "", "interpreter to run the executable with on the remote host (e.g. bash)")
+	runCmd.Flags().String("log-dir", "", "collect logs from the remote host into this directory if running the executable fails (disabled if empty)")
+	runCmd.Flags().StringSlice("log-paths", []string{"/var/log"}, "paths on the remote host to collect alongside the journal")
+	runCmd.Flags().String("entrypoint", "", "path of the file to run, relative to the bundle, when the executable is a directory")
 	rootCmd.AddCommand(runCmd)
 
+	runMultiArchCmd := &cobra.Command{
+		Use:   "run-multiarch --image <arch>=<image> [--image <arch>=<image>...] <executable>",
+		Short: "run the 'run' subcommand for the images of several architectures concurrently, with the arch appended to the --s3-key and --ami-name of each",
+		Args:  cobra.ExactArgs(1),
+		Run:   runMultiArch,
+	}
+	runMultiArchCmd.Flags().StringArray("image", nil, "image to boot in the form arch=image (can be specified multiple times)")
+	runMultiArchCmd.Flags().AddFlagSet(runCmd.Flags())
+	exitCheck(runMultiArchCmd.MarkFlagRequired("image"))
+	rootCmd.AddCommand(runMultiArchCmd)
+
+	// not shared with run-multiarch, which always tears down all the
+	// architectures
+	runCmd.Flags().Bool("keep-running", false, "don't tear down the instance after running the executable, print the ssh command to connect to it and store the resource IDs for a later teardown instead")
+	runCmd.Flags().StringP("resourcefile", "r", "resources.json", "path to store the resource IDs with --keep-running")
+
 	return rootCmd
 }
 
@@ -0,0 +1,599 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/cloud/bootprovider"
+)
+
+func TestParseTags(t *testing.T) {
+	tags, err := parseTags([]string{"owner=image-builder", "empty=", "expr=a=b"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"owner": "image-builder",
+		"empty": "",
+		"expr":  "a=b",
+	}, tags)
+
+	_, err = parseTags([]string{"novalue"})
+	assert.Error(t, err)
+
+	_, err = parseTags([]string{"=value"})
+	assert.Error(t, err)
+}
+
+func TestDefaultUsername(t *testing.T) {
+	for distroName, expected := range map[string]string{
+		"fedora":    "fedora",
+		"fedora-39": "fedora",
+		"rhel-7":    "cloud-user",
+		"rhel-89":   "ec2-user",
+		"rhel-9":    "ec2-user",
+		"centos-8":  "ec2-user",
+		"centos-9":  "ec2-user",
+	} {
+		username, err := defaultUsername(distroName)
+		require.NoError(t, err, distroName)
+		assert.Equal(t, expected, username, distroName)
+	}
+
+	_, err := defaultUsername("toucan-os")
+	assert.EqualError(t, err, `no default username for distro "toucan-os", use --username`)
+}
+
+func TestGetUsername(t *testing.T) {
+	username, err := getUsername(newRunFlags(t, "--distro", "fedora-39"))
+	require.NoError(t, err)
+	assert.Equal(t, "user", username, "--username overrides the distro default")
+
+	username, err = getUsername(newRunFlags(t, "--username", "", "--distro", "fedora-39"))
+	require.NoError(t, err)
+	assert.Equal(t, "fedora", username)
+
+	_, err = getUsername(newRunFlags(t, "--username", ""))
+	assert.EqualError(t, err, "either --username or --distro must be specified")
+}
+
+func TestCredentialsFromArgs(t *testing.T) {
+	credentialsFile := filepath.Join(t.TempDir(), "credentials")
+	require.NoError(t, os.WriteFile(credentialsFile, []byte("[default]\naws_access_key_id = default-id\naws_secret_access_key = default-secret\n\n[test]\naws_access_key_id = test-id\naws_secret_access_key = test-secret\n"), 0600))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+
+	parse := func(args ...string) *pflag.FlagSet {
+		flags := setupCLI().PersistentFlags()
+		require.NoError(t, flags.Parse(args))
+		return flags
+	}
+
+	t.Run("default chain", func(t *testing.T) {
+		creds, err := credentialsFromArgs(parse())
+		require.NoError(t, err)
+		assert.Nil(t, creds)
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		creds, err := credentialsFromArgs(parse("--profile", "test"))
+		require.NoError(t, err)
+		assert.Nil(t, creds, "the profile is read by the session")
+	})
+
+	t.Run("static", func(t *testing.T) {
+		creds, err := credentialsFromArgs(parse("--access-key-id", "id", "--secret-access-key", "secret", "--session-token", "token"))
+		require.NoError(t, err)
+		value, err := creds.Get()
+		require.NoError(t, err)
+		assert.Equal(t, credentials.StaticProviderName, value.ProviderName)
+		assert.Equal(t, "token", value.SessionToken)
+	})
+
+	for _, args := range [][]string{
+		{"--access-key-id", "id"},
+		{"--secret-access-key", "secret"},
+		{"--session-token", "token"},
+		{"--access-key-id", "id", "--secret-access-key", "secret", "--profile", "test"},
+	} {
+		_, err := credentialsFromArgs(parse(args...))
+		assert.Error(t, err, args)
+	}
+}
+
+func TestSessionFromArgsProfile(t *testing.T) {
+	dir := t.TempDir()
+	credentialsFile := filepath.Join(dir, "credentials")
+	require.NoError(t, os.WriteFile(credentialsFile, []byte("[test]\naws_access_key_id = test-id\naws_secret_access_key = test-secret\n"), 0600))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	// profiles of the config file, e.g. with SSO or role_arn, are found too
+	configFile := filepath.Join(dir, "config")
+	require.NoError(t, os.WriteFile(configFile, []byte("[profile config]\naws_access_key_id = config-id\naws_secret_access_key = config-secret\nregion = eu-west-1\n"), 0600))
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+
+	parse := func(args ...string) *pflag.FlagSet {
+		flags := setupCLI().PersistentFlags()
+		require.NoError(t, flags.Parse(append([]string{"--region", "us-east-1"}, args...)))
+		return flags
+	}
+
+	for profile, keyID := range map[string]string{"": "env-id", "test": "test-id", "config": "config-id"} {
+		sess, err := sessionFromArgs(parse("--profile", profile))
+		require.NoError(t, err, profile)
+		value, err := sess.Config.Credentials.Get()
+		require.NoError(t, err, profile)
+		assert.Equal(t, keyID, value.AccessKeyID, profile)
+		assert.Equal(t, "us-east-1", *sess.Config.Region, "--region overrides the region of the profile")
+	}
+
+	// the SDK only fails to find the profile when the credentials are used
+	sess, err := sessionFromArgs(parse("--profile", "missing"))
+	require.NoError(t, err)
+	_, err = sess.Config.Credentials.Get()
+	assert.Error(t, err)
+}
+
+func TestNewClientFromArgsEndpointURL(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_, err := w.Write([]byte(`<DescribeRegionsResponse><regionInfo><item><regionName>us-east-1</regionName></item></regionInfo></DescribeRegionsResponse>`))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	flags := setupCLI().PersistentFlags()
+	require.NoError(t, flags.Parse([]string{"--access-key-id", "test", "--secret-access-key", "test", "--region", "us-east-1", "--endpoint-url", server.URL}))
+
+	a, err := newClientFromArgs(flags)
+	require.NoError(t, err)
+	regions, err := a.Regions()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"us-east-1"}, regions)
+	assert.Equal(t, []string{"/"}, paths)
+}
+
+// fakeRunner records all commands instead of running them. Commands for
+// which fail returns true return an error.
+type fakeRunner struct {
+	mu       sync.Mutex
+	commands []string
+	fail     func(command string) bool
+}
+
+func (r *fakeRunner) run(c string, args ...string) ([]byte, []byte, error) {
+	command := strings.Join(append([]string{c}, args...), " ")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, command)
+	if r.fail != nil && r.fail(command) {
+		return nil, nil, fmt.Errorf("%s failed", c)
+	}
+	return nil, nil, nil
+}
+
+// useFakeRunner replaces the runner for the duration of the test.
+func useFakeRunner(t *testing.T, r *fakeRunner) {
+	orig := runner
+	runner = r.run
+	t.Cleanup(func() { runner = orig })
+}
+
+// hasCommand returns the index of the first recorded command containing all
+// the given substrings or -1 if there is none.
+func (r *fakeRunner) hasCommand(substrings ...string) int {
+	for idx, command := range r.commands {
+		found := true
+		for _, s := range substrings {
+			if !strings.Contains(command, s) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return idx
+		}
+	}
+	return -1
+}
+
+func newRunFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	pubkey := filepath.Join(t.TempDir(), "key.pub")
+	require.NoError(t, os.WriteFile(pubkey, []byte("ssh-ed25519 AAAA user@host"), 0600))
+
+	flags := pflag.NewFlagSet("run", pflag.ContinueOnError)
+	flags.String("ssh-pubkey", pubkey, "")
+	flags.String("ssh-privkey", "key", "")
+	flags.String("username", "user", "")
+	flags.String("distro", "", "")
+	flags.Bool("skip-cloud-init-wait", false, "")
+	flags.Duration("cloud-init-timeout", 5*time.Minute, "")
+	flags.String("interpreter", "", "")
+	flags.String("entrypoint", "", "")
+	flags.String("log-dir", "", "")
+	flags.StringSlice("log-paths", []string{"/var/log"}, "")
+	flags.Bool("keep-running", false, "")
+	flags.String("resourcefile", filepath.Join(t.TempDir(), "resources.json"), "")
+	require.NoError(t, flags.Parse(args))
+	return flags
+}
+
+// newTestExecutable creates an empty file named test-exe in a temporary
+// directory and returns its path.
+func newTestExecutable(t *testing.T) string {
+	exe := filepath.Join(t.TempDir(), "test-exe")
+	require.NoError(t, os.WriteFile(exe, nil, 0755))
+	return exe
+}
+
+func TestRunOnHostWaitsForCloudInit(t *testing.T) {
+	r := &fakeRunner{}
+	useFakeRunner(t, r)
+
+	exe := newTestExecutable(t)
+	require.NoError(t, runOnHost("192.0.2.1", exe, newRunFlags(t)))
+
+	wait := r.hasCommand("ssh", "timeout 300 cloud-init status --wait")
+	scp := r.hasCommand("scp", exe)
+	exec := r.hasCommand("ssh", "./test-exe")
+	require.NotEqual(t, -1, wait)
+	require.NotEqual(t, -1, scp)
+	require.NotEqual(t, -1, exec)
+	assert.Less(t, wait, scp)
+	assert.Less(t, scp, exec)
+}
+
+func TestRunOnHostCloudInitFailure(t *testing.T) {
+	r := &fakeRunner{
+		fail: func(command string) bool { return strings.Contains(command, "cloud-init") },
+	}
+	useFakeRunner(t, r)
+
+	err := runOnHost("192.0.2.1", newTestExecutable(t), newRunFlags(t))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cloud-init")
+	assert.Equal(t, -1, r.hasCommand("scp"))
+}
+
+func TestRunOnHostSkipCloudInitWait(t *testing.T) {
+	r := &fakeRunner{}
+	useFakeRunner(t, r)
+
+	require.NoError(t, runOnHost("192.0.2.1", newTestExecutable(t), newRunFlags(t, "--skip-cloud-init-wait")))
+	assert.Equal(t, -1, r.hasCommand("cloud-init"))
+	assert.NotEqual(t, -1, r.hasCommand("scp"))
+}
+
+func TestRunOnHostInterpreter(t *testing.T) {
+	r := &fakeRunner{}
+	useFakeRunner(t, r)
+
+	require.NoError(t, runOnHost("192.0.2.1", newTestExecutable(t), newRunFlags(t, "--interpreter", "bash")))
+	assert.NotEqual(t, -1, r.hasCommand("ssh", "bash ./test-exe"))
+}
+
+func TestRunOnHostBundle(t *testing.T) {
+	r := &fakeRunner{}
+	useFakeRunner(t, r)
+
+	parent := t.TempDir()
+	bundle := filepath.Join(parent, "tests")
+	require.NoError(t, os.Mkdir(bundle, 0755))
+
+	require.NoError(t, runOnHost("192.0.2.1", bundle, newRunFlags(t, "--interpreter", "bash", "--entrypoint", "run.sh")))
+
+	create := r.hasCommand("tar", "-C "+parent, "-cf", "tests.tar tests")
+	copy := r.hasCommand("scp", "tests.tar", "user@192.0.2.1:tests.tar")
+	extract := r.hasCommand("ssh", "tar -xf tests.tar")
+	exec := r.hasCommand("ssh", "cd tests && bash ./run.sh")
+	require.NotEqual(t, -1, create)
+	require.NotEqual(t, -1, copy)
+	require.NotEqual(t, -1, extract)
+	require.NotEqual(t, -1, exec)
+	assert.Less(t, create, copy)
+	assert.Less(t, copy, extract)
+	assert.Less(t, extract, exec)
+}
+
+func TestRunOnHostBundleRequiresEntrypoint(t *testing.T) {
+	r := &fakeRunner{}
+	useFakeRunner(t, r)
+
+	err := runOnHost("192.0.2.1", t.TempDir(), newRunFlags(t))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--entrypoint")
+	assert.Equal(t, -1, r.hasCommand("scp"))
+}
+
+func TestRunOnHostCollectsLogsOnFailure(t *testing.T) {
+	r := &fakeRunner{
+		fail: func(command string) bool { return strings.HasSuffix(command, "./test-exe") },
+	}
+	useFakeRunner(t, r)
+
+	logDir := filepath.Join(t.TempDir(), "logs")
+	err := runOnHost("192.0.2.1", newTestExecutable(t), newRunFlags(t, "--log-dir", logDir, "--log-paths", "/var/log/cloud-init.log"))
+	require.EqualError(t, err, "ssh failed")
+
+	journal := r.hasCommand("ssh", "sudo journalctl -b")
+	archive := r.hasCommand("ssh", "tar", "/var/log/cloud-init.log")
+	copy := r.hasCommand("scp", "user@192.0.2.1:boot-aws-logs.tar.gz", logDir)
+	require.NotEqual(t, -1, journal)
+	require.NotEqual(t, -1, archive)
+	require.NotEqual(t, -1, copy)
+	assert.Less(t, journal, archive)
+	assert.Less(t, archive, copy)
+}
+
+func TestRunOnHostNoLogsOnSuccess(t *testing.T) {
+	r := &fakeRunner{}
+	useFakeRunner(t, r)
+
+	require.NoError(t, runOnHost("192.0.2.1", newTestExecutable(t), newRunFlags(t, "--log-dir", t.TempDir())))
+	assert.Equal(t, -1, r.hasCommand("journalctl"))
+}
+
+func TestRunOnHostLogCollectionFailurePreservesError(t *testing.T) {
+	r := &fakeRunner{
+		fail: func(command string) bool {
+			return strings.Contains(command, "cloud-init") || strings.Contains(command, "journalctl")
+		},
+	}
+	useFakeRunner(t, r)
+
+	err := runOnHost("192.0.2.1", newTestExecutable(t), newRunFlags(t, "--log-dir", t.TempDir()))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "waiting for cloud-init to finish failed")
+	assert.NotEqual(t, -1, r.hasCommand("journalctl"))
+}
+
+func TestRunOnHostLogCollectionDisabled(t *testing.T) {
+	r := &fakeRunner{
+		fail: func(command string) bool { return strings.HasSuffix(command, "./test-exe") },
+	}
+	useFakeRunner(t, r)
+
+	require.Error(t, runOnHost("192.0.2.1", newTestExecutable(t), newRunFlags(t)))
+	assert.Equal(t, -1, r.hasCommand("journalctl"))
+}
+
+// fakeProvider records the lifecycle calls made to it and creates a fake
+// resource for each step in the resources.
+type fakeProvider struct {
+	calls []string
+
+	// failAt makes the call with the given name fail
+	failAt string
+
+	// torndown holds the resources that were passed to Teardown
+	torndown []string
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) step(name string, res *bootprovider.Resources) error {
+	p.calls = append(p.calls, name)
+	if p.failAt == name {
+		return fmt.Errorf("%s failed", name)
+	}
+	if res.AWS == nil {
+		res.AWS = &bootprovider.AWSResources{}
+	}
+	return nil
+}
+
+func (p *fakeProvider) Upload(filename string, res *bootprovider.Resources) error {
+	return p.step("upload", res)
+}
+
+func (p *fakeProvider) Register(res *bootprovider.Resources) error {
+	if err := p.step("register", res); err != nil {
+		return err
+	}
+	res.AWS.AMI = stringPtr("image-" + res.RunID)
+	return nil
+}
+
+func (p *fakeProvider) Boot(userData string, res *bootprovider.Resources) error {
+	if !strings.Contains(userData, "user: user") {
+		return fmt.Errorf("unexpected user data: %s", userData)
+	}
+	if err := p.step("boot", res); err != nil {
+		return err
+	}
+	res.AWS.InstanceID = stringPtr("instance-" + res.RunID)
+	return nil
+}
+
+func (p *fakeProvider) Address(res *bootprovider.Resources) (string, error) {
+	if err := p.step("address", res); err != nil {
+		return "", err
+	}
+	if res.AWS.InstanceID == nil {
+		return "", fmt.Errorf("no instance")
+	}
+	return "192.0.2.1", nil
+}
+
+func (p *fakeProvider) Teardown(res *bootprovider.Resources) error {
+	p.calls = append(p.calls, "teardown")
+	if res.AWS != nil {
+		if res.AWS.InstanceID != nil {
+			p.torndown = append(p.torndown, *res.AWS.InstanceID)
+		}
+		if res.AWS.AMI != nil {
+			p.torndown = append(p.torndown, *res.AWS.AMI)
+		}
+	}
+	return nil
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestLifecycleWithFakeProvider(t *testing.T) {
+	r := &fakeRunner{}
+	useFakeRunner(t, r)
+
+	p := &fakeProvider{}
+	flags := newRunFlags(t)
+	res := &bootprovider.Resources{}
+
+	require.NoError(t, doSetup(p, "disk.raw", flags, res))
+	assert.Equal(t, "fake", res.Provider)
+	assert.NotEmpty(t, res.RunID)
+
+	require.NoError(t, doRunExec(p, newTestExecutable(t), flags, res))
+	assert.NotEqual(t, -1, r.hasCommand("ssh", "user", "192.0.2.1", "./test-exe"))
+
+	require.NoError(t, doTeardown(p, res))
+	assert.Equal(t, []string{"upload", "register", "boot", "address", "address", "teardown"}, p.calls)
+	assert.Equal(t, []string{"instance-" + res.RunID, "image-" + res.RunID}, p.torndown)
+}
+
+func TestRunTearsDown(t *testing.T) {
+	useFakeRunner(t, &fakeRunner{})
+
+	p := &fakeProvider{}
+	flags := newRunFlags(t)
+	var stdout bytes.Buffer
+
+	require.NoError(t, doRun(p, "disk.raw", newTestExecutable(t), flags, &bootprovider.Resources{}, &stdout))
+	assert.Equal(t, []string{"upload", "register", "boot", "address", "address", "teardown"}, p.calls)
+	assert.Empty(t, stdout.String())
+
+	resourcesFile, err := flags.GetString("resourcefile")
+	require.NoError(t, err)
+	assert.NoFileExists(t, resourcesFile)
+}
+
+func TestRunKeepRunning(t *testing.T) {
+	useFakeRunner(t, &fakeRunner{})
+
+	p := &fakeProvider{}
+	flags := newRunFlags(t, "--keep-running")
+	res := &bootprovider.Resources{}
+	var stdout bytes.Buffer
+
+	require.NoError(t, doRun(p, "disk.raw", newTestExecutable(t), flags, res, &stdout))
+	assert.Equal(t, []string{"upload", "register", "boot", "address", "address", "address"}, p.calls)
+	assert.Empty(t, p.torndown)
+	assert.Contains(t, stdout.String(), "ssh -i key user@192.0.2.1\n")
+
+	resourcesFile, err := flags.GetString("resourcefile")
+	require.NoError(t, err)
+	data, err := os.ReadFile(resourcesFile)
+	require.NoError(t, err)
+	var saved bootprovider.Resources
+	require.NoError(t, json.Unmarshal(data, &saved))
+	assert.Equal(t, res, &saved)
+
+	require.NoError(t, doTeardown(p, &saved))
+	assert.Equal(t, []string{"instance-" + res.RunID, "image-" + res.RunID}, p.torndown)
+}
+
+func TestRunKeepRunningSetupFailure(t *testing.T) {
+	p := &fakeProvider{failAt: "boot"}
+	var stdout bytes.Buffer
+
+	require.EqualError(t, doRun(p, "disk.raw", newTestExecutable(t), newRunFlags(t, "--keep-running"), &bootprovider.Resources{}, &stdout), "boot failed")
+	assert.Equal(t, []string{"upload", "register", "boot", "address"}, p.calls)
+	assert.Empty(t, p.torndown)
+	assert.Contains(t, stdout.String(), "The instance is not running")
+}
+
+func TestSetupFailureKeepsResourcesForTeardown(t *testing.T) {
+	p := &fakeProvider{failAt: "boot"}
+	res := &bootprovider.Resources{}
+
+	require.EqualError(t, doSetup(p, "disk.raw", newRunFlags(t), res), "boot failed")
+	assert.Equal(t, []string{"upload", "register", "boot"}, p.calls)
+
+	require.NoError(t, doTeardown(p, res))
+	assert.Equal(t, []string{"image-" + res.RunID}, p.torndown)
+}
+
+func TestTeardownProviderMismatch(t *testing.T) {
+	p := &fakeProvider{}
+	res := &bootprovider.Resources{Provider: "aws"}
+
+	require.Error(t, doTeardown(p, res))
+	assert.Empty(t, p.calls)
+}
+
+func TestParseArchImages(t *testing.T) {
+	images, err := parseArchImages([]string{"x86_64=disk.raw", "aarch64=/tmp/disk=1.raw"})
+	require.NoError(t, err)
+	assert.Equal(t, []archImage{{"x86_64", "disk.raw"}, {"aarch64", "/tmp/disk=1.raw"}}, images)
+
+	for _, pairs := range [][]string{nil, {"disk.raw"}, {"=disk.raw"}, {"x86_64="}, {"x86_64=a", "x86_64=b"}} {
+		_, err := parseArchImages(pairs)
+		assert.Error(t, err, pairs)
+	}
+}
+
+// barrierProvider is a fakeProvider whose uploads only finish after the
+// uploads of all the providers sharing the barrier have started.
+type barrierProvider struct {
+	*fakeProvider
+	barrier *sync.WaitGroup
+}
+
+func (p *barrierProvider) Upload(filename string, res *bootprovider.Resources) error {
+	p.barrier.Done()
+	done := make(chan struct{})
+	go func() {
+		p.barrier.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		return fmt.Errorf("upload of %s did not run concurrently", filename)
+	}
+	return p.fakeProvider.Upload(filename, res)
+}
+
+func TestRunMultiArch(t *testing.T) {
+	useFakeRunner(t, &fakeRunner{})
+
+	barrier := &sync.WaitGroup{}
+	barrier.Add(2)
+	providers := map[string]*fakeProvider{
+		"x86_64":  {},
+		"aarch64": {failAt: "boot"},
+	}
+	newProvider := func(arch string) (bootprovider.Provider, error) {
+		return &barrierProvider{fakeProvider: providers[arch], barrier: barrier}, nil
+	}
+
+	images := []archImage{{"x86_64", "disk-x86_64.raw"}, {"aarch64", "disk-aarch64.raw"}}
+	resources, err := doRunMultiArch(newProvider, images, newTestExecutable(t), newRunFlags(t))
+	require.EqualError(t, err, "1 of 2 architectures failed:\naarch64: boot failed")
+
+	x86 := providers["x86_64"]
+	assert.Equal(t, []string{"upload", "register", "boot", "address", "address", "teardown"}, x86.calls)
+	assert.Equal(t, []string{"instance-" + resources["x86_64"].RunID, "image-" + resources["x86_64"].RunID}, x86.torndown)
+
+	aarch64 := providers["aarch64"]
+	assert.Equal(t, []string{"upload", "register", "boot", "teardown"}, aarch64.calls)
+	assert.Equal(t, []string{"image-" + resources["aarch64"].RunID}, aarch64.torndown)
+
+	assert.NotEqual(t, resources["x86_64"].RunID, resources["aarch64"].RunID)
+}
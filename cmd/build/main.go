@@ -58,16 +58,18 @@ type ostreeOptions struct {
 }
 
 type crBlueprint struct {
-	Name           string                    `json:"name,omitempty"`
-	Description    string                    `json:"description,omitempty"`
-	Version        string                    `json:"version,omitempty"`
-	Packages       []blueprint.Package       `json:"packages,omitempty"`
-	Modules        []blueprint.Package       `json:"modules,omitempty"`
-	Groups         []blueprint.Group         `json:"groups,omitempty"`
-	Containers     []blueprint.Container     `json:"containers,omitempty"`
-	Customizations *blueprint.Customizations `json:"customizations,omitempty"`
-	Distro         string                    `json:"distro,omitempty"`
-	Minimal        bool                      `json:"minimal,omitempty"`
+	Name            string                    `json:"name,omitempty"`
+	Description     string                    `json:"description,omitempty"`
+	Version         string                    `json:"version,omitempty"`
+	Packages        []blueprint.Package       `json:"packages,omitempty"`
+	Modules         []blueprint.Package       `json:"modules,omitempty"`
+	Groups          []blueprint.Group         `json:"groups,omitempty"`
+	Containers      []blueprint.Container     `json:"containers,omitempty"`
+	Customizations  *blueprint.Customizations `json:"customizations,omitempty"`
+	Distro          string                    `json:"distro,omitempty"`
+	ExcludePackages []string                  `json:"exclude_packages,omitempty"`
+	InstallWeakDeps *bool                     `json:"install_weak_deps,omitempty"`
+	Minimal         bool                      `json:"minimal,omitempty"`
 }
 
 type BuildConfig struct {
@@ -93,10 +95,16 @@ func loadConfig(path string) BuildConfig {
 	return conf
 }
 
-func makeManifest(imgType distro.ImageType, config BuildConfig, distribution distro.Distro, repos []rpmmd.RepoConfig, archName string, seedArg int64, cacheRoot string) (manifest.OSBuildManifest, error) {
+func makeManifest(imgType distro.ImageType, config BuildConfig, distribution distro.Distro, repos []rpmmd.RepoConfig, archName string, seedArg int64, cacheRoot string) (manifest.OSBuildManifest, distro.ComposeMetadata, error) {
 	cacheDir := filepath.Join(cacheRoot, archName+distribution.Name())
 
 	options := distro.ImageOptions{Size: 0}
+	sourceDateEpoch, err := distro.SourceDateEpochFromEnv()
+	if err != nil {
+		return nil, distro.ComposeMetadata{}, err
+	}
+	options.SourceDateEpoch = sourceDateEpoch
+	options.Proxy = distro.ProxyOptionsFromEnv()
 	if config.OSTree != nil {
 		options.OSTree = &ostree.ImageOptions{
 			URL:       config.OSTree.URL,
@@ -118,7 +126,7 @@ func makeManifest(imgType distro.ImageType, config BuildConfig, distribution dis
 
 	manifest, warnings, err := imgType.Manifest(&bp, options, repos, seedArg)
 	if err != nil {
-		return nil, fmt.Errorf("[ERROR] manifest generation failed: %s", err.Error())
+		return nil, distro.ComposeMetadata{}, fmt.Errorf("[ERROR] manifest generation failed: %s", err.Error())
 	}
 	if len(warnings) > 0 {
 		fmt.Fprintf(os.Stderr, "[WARNING]\n%s", strings.Join(warnings, "\n"))
@@ -126,10 +134,10 @@ func makeManifest(imgType distro.ImageType, config BuildConfig, distribution dis
 
 	packageSpecs, err := depsolve(cacheDir, manifest.GetPackageSetChains(), distribution, archName)
 	if err != nil {
-		return nil, fmt.Errorf("[ERROR] depsolve failed: %s", err.Error())
+		return nil, distro.ComposeMetadata{}, fmt.Errorf("[ERROR] depsolve failed: %s", err.Error())
 	}
 	if packageSpecs == nil {
-		return nil, fmt.Errorf("[ERROR] depsolve did not return any packages")
+		return nil, distro.ComposeMetadata{}, fmt.Errorf("[ERROR] depsolve did not return any packages")
 	}
 
 	if config.Blueprint != nil {
@@ -138,20 +146,20 @@ func makeManifest(imgType distro.ImageType, config BuildConfig, distribution dis
 
 	containerSpecs, err := resolvePipelineContainers(manifest.GetContainerSourceSpecs(), archName)
 	if err != nil {
-		return nil, fmt.Errorf("[ERROR] container resolution failed: %s", err.Error())
+		return nil, distro.ComposeMetadata{}, fmt.Errorf("[ERROR] container resolution failed: %s", err.Error())
 	}
 
 	commitSpecs, err := resolvePipelineCommits(manifest.GetOSTreeSourceSpecs())
 	if err != nil {
-		return nil, fmt.Errorf("[ERROR] ostree commit resolution failed: %s\n", err.Error())
+		return nil, distro.ComposeMetadata{}, fmt.Errorf("[ERROR] ostree commit resolution failed: %s\n", err.Error())
 	}
 
 	mf, err := manifest.Serialize(packageSpecs, containerSpecs, commitSpecs)
 	if err != nil {
-		return nil, fmt.Errorf("[ERROR] manifest serialization failed: %s", err.Error())
+		return nil, distro.ComposeMetadata{}, fmt.Errorf("[ERROR] manifest serialization failed: %s", err.Error())
 	}
 
-	return mf, nil
+	return mf, distro.NewComposeMetadata(imgType, manifest, packageSpecs, mf), nil
 }
 
 type DistroArchRepoMap map[string]map[string][]repository
@@ -366,7 +374,7 @@ func main() {
 	}
 
 	fmt.Printf("Generating manifest for %s: ", config.Name)
-	mf, err := makeManifest(imgType, config, distribution, rpmmdRepos, archName, seedArg, rpmCacheRoot)
+	mf, metadata, err := makeManifest(imgType, config, distribution, rpmmdRepos, archName, seedArg, rpmCacheRoot)
 	if err != nil {
 		check(err)
 	}
@@ -376,6 +384,9 @@ func main() {
 	if err := save(mf, manifestPath); err != nil {
 		check(err)
 	}
+	if err := distro.WriteComposeMetadata(buildDir, metadata); err != nil {
+		check(err)
+	}
 
 	fmt.Printf("Building manifest: %s\n", manifestPath)
 
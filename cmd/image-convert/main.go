@@ -0,0 +1,126 @@
+// Standalone executable that converts a raw disk image to another format
+// locally, with the same qemu-img options as the org.osbuild.qemu stage of
+// the images that are built, so that the results are the same.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// Exit codes
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// formats maps the names of the target formats to the qemu formats. vhd is
+// the vpc format of qemu.
+var formats = map[string]osbuild.QEMUFormat{
+	"qcow2": osbuild.QEMUFormatQCOW2,
+	"vdi":   osbuild.QEMUFormatVDI,
+	"vhd":   osbuild.QEMUFormatVPC,
+	"vhdx":  osbuild.QEMUFormatVHDX,
+	"vmdk":  osbuild.QEMUFormatVMDK,
+}
+
+type convertOptions struct {
+	format        string
+	qcow2Compat   string
+	vmdkSubformat string
+	vhdSubformat  string
+	vhdForceSize  bool
+}
+
+// formatOptions returns the options of the qemu stage for the target format.
+func formatOptions(options convertOptions) (osbuild.QEMUFormatOptions, error) {
+	format, ok := formats[options.format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %q (supported: qcow2, vdi, vhd, vhdx, vmdk)", options.format)
+	}
+
+	switch format {
+	case osbuild.QEMUFormatQCOW2:
+		return osbuild.QCOW2Options{Type: format, Compat: options.qcow2Compat}, nil
+	case osbuild.QEMUFormatVMDK:
+		return osbuild.VMDKOptions{Type: format, Subformat: osbuild.VMDKSubformat(options.vmdkSubformat)}, nil
+	case osbuild.QEMUFormatVPC:
+		return osbuild.VPCOptions{Type: format, Subformat: osbuild.VPCSubformat(options.vhdSubformat), ForceSize: &options.vhdForceSize}, nil
+	case osbuild.QEMUFormatVDI:
+		return osbuild.VDIOptions{Type: format}, nil
+	default:
+		return osbuild.VHDXOptions{Type: format}, nil
+	}
+}
+
+// convertArgs returns the qemu-img command that converts the source image.
+func convertArgs(options convertOptions, source, target string) ([]string, error) {
+	formatOpts, err := formatOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	stageOptions := &osbuild.QEMUStageOptions{
+		Filename: target,
+		Format:   formatOpts,
+	}
+	return osbuild.QEMUImgConvertArgs(stageOptions, source)
+}
+
+func runCommand(stdout, stderr io.Writer, argv ...string) error {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// runner runs the conversion. It is replaced in tests to avoid calling out to
+// qemu-img.
+var runner = runCommand
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("image-convert", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: image-convert [flags] <source.raw> <target>\n")
+		flags.PrintDefaults()
+	}
+
+	var options convertOptions
+	flags.StringVar(&options.format, "format", "", "target format: qcow2, vdi, vhd, vhdx or vmdk (required)")
+	flags.StringVar(&options.qcow2Compat, "qcow2-compat", "", "qcow2 compatibility version, e.g. 0.10 or 1.1 (default: the one of qemu-img)")
+	flags.StringVar(&options.vmdkSubformat, "vmdk-subformat", string(osbuild.VMDKSubformatStreamOptimized), "vmdk subformat")
+	flags.StringVar(&options.vhdSubformat, "vhd-subformat", string(osbuild.VPCSubformatFixed), "vhd subformat: fixed or dynamic")
+	flags.BoolVar(&options.vhdForceSize, "vhd-force-size", true, "keep the exact virtual size of vhd images, as Azure requires")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 2 || options.format == "" {
+		flags.Usage()
+		return exitUsage
+	}
+
+	argv, err := convertArgs(options, flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	fmt.Fprintf(stdout, "> %s\n", strings.Join(argv, " "))
+	if err := runner(stdout, stderr, argv...); err != nil {
+		fmt.Fprintf(stderr, "conversion failed: %s\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useFakeRunner records the commands instead of running them for the
+// duration of the test. The commands fail with err.
+func useFakeRunner(t *testing.T, err error) *[][]string {
+	var commands [][]string
+	orig := runner
+	runner = func(stdout, stderr io.Writer, argv ...string) error {
+		commands = append(commands, argv)
+		return err
+	}
+	t.Cleanup(func() { runner = orig })
+	return &commands
+}
+
+func runConvert(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestConvertArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected []string
+	}{
+		{
+			args:     []string{"-format", "qcow2"},
+			expected: []string{"qemu-img", "convert", "-O", "qcow2", "-c", "disk.raw", "disk.out"},
+		},
+		{
+			args:     []string{"-format", "qcow2", "-qcow2-compat", "0.10"},
+			expected: []string{"qemu-img", "convert", "-O", "qcow2", "-c", "-o", "compat=0.10", "disk.raw", "disk.out"},
+		},
+		{
+			args:     []string{"-format", "vmdk"},
+			expected: []string{"qemu-img", "convert", "-O", "vmdk", "-c", "-o", "subformat=streamOptimized", "disk.raw", "disk.out"},
+		},
+		{
+			args:     []string{"-format", "vmdk", "-vmdk-subformat", "monolithicSparse"},
+			expected: []string{"qemu-img", "convert", "-O", "vmdk", "-c", "-o", "subformat=monolithicSparse", "disk.raw", "disk.out"},
+		},
+		{
+			args:     []string{"-format", "vhd"},
+			expected: []string{"qemu-img", "convert", "-O", "vpc", "-o", "subformat=fixed", "-o", "force_size", "disk.raw", "disk.out"},
+		},
+		{
+			args:     []string{"-format", "vhd", "-vhd-subformat", "dynamic", "-vhd-force-size=false"},
+			expected: []string{"qemu-img", "convert", "-O", "vpc", "-o", "subformat=dynamic", "disk.raw", "disk.out"},
+		},
+		{
+			args:     []string{"-format", "vhdx"},
+			expected: []string{"qemu-img", "convert", "-O", "vhdx", "disk.raw", "disk.out"},
+		},
+		{
+			args:     []string{"-format", "vdi"},
+			expected: []string{"qemu-img", "convert", "-O", "vdi", "disk.raw", "disk.out"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.args), func(t *testing.T) {
+			commands := useFakeRunner(t, nil)
+			code, stdout, stderr := runConvert(append(test.args, "disk.raw", "disk.out")...)
+			assert.Equal(t, exitOK, code)
+			assert.Empty(t, stderr)
+			require.Len(t, *commands, 1)
+			assert.Equal(t, test.expected, (*commands)[0])
+			assert.Contains(t, stdout, "> qemu-img convert")
+		})
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	commands := useFakeRunner(t, nil)
+
+	code, _, stderr := runConvert("-format", "iso", "disk.raw", "disk.iso")
+	assert.Equal(t, exitUsage, code)
+	assert.Equal(t, "unsupported format \"iso\" (supported: qcow2, vdi, vhd, vhdx, vmdk)\n", stderr)
+
+	code, _, stderr = runConvert("-format", "vmdk", "-vmdk-subformat", "sparse", "disk.raw", "disk.vmdk")
+	assert.Equal(t, exitUsage, code)
+	assert.Equal(t, "'subformat' option does not allow \"sparse\" as a value\n", stderr)
+
+	code, _, stderr = runConvert("-format", "qcow2", "disk.raw")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "Usage: image-convert")
+
+	assert.Empty(t, *commands)
+}
+
+func TestConvertFailure(t *testing.T) {
+	useFakeRunner(t, fmt.Errorf("exit status 1"))
+	code, _, stderr := runConvert("-format", "qcow2", "disk.raw", "disk.qcow2")
+	assert.Equal(t, exitFailure, code)
+	assert.Equal(t, "conversion failed: exit status 1\n", stderr)
+}
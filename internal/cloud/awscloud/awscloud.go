@@ -3,16 +3,21 @@ package awscloud
 import (
 	"crypto/tls"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/sirupsen/logrus"
@@ -21,8 +26,29 @@ import (
 
 type AWS struct {
 	uploader *s3manager.Uploader
-	ec2      *ec2.EC2
+	ec2      ec2iface.EC2API
 	s3       *s3.S3
+
+	// logger of the API calls, see SetLogger
+	logger logrus.FieldLogger
+
+	// retries of the API calls, see SetRetryOptions
+	retryOptions RetryOptions
+}
+
+// Returns an *AWS object with the clients of the session. The API calls of
+// the clients are logged to the logger of the object and retried with its
+// retry options.
+func newAwsFromSession(sess *session.Session) *AWS {
+	a := &AWS{retryOptions: DefaultRetryOptions}
+	// the clients copy the configuration and the handlers of the session when
+	// they are created
+	request.WithRetryer(sess.Config, retryer{aws: a})
+	sess.Handlers.Complete.PushBackNamed(request.NamedHandler{Name: "awscloud.logRequest", Fn: a.logRequest})
+	a.uploader = s3manager.NewUploader(sess)
+	a.ec2 = ec2.New(sess)
+	a.s3 = s3.New(sess)
+	return a
 }
 
 // Create a new session from the credentials and the region and returns an *AWS object initialized with it.
@@ -36,11 +62,7 @@ func newAwsFromCreds(creds *credentials.Credentials, region string) (*AWS, error
 		return nil, err
 	}
 
-	return &AWS{
-		uploader: s3manager.NewUploader(sess),
-		ec2:      ec2.New(sess),
-		s3:       s3.New(sess),
-	}, nil
+	return newAwsFromSession(sess), nil
 }
 
 // Initialize a new AWS object from individual bits. SessionToken is optional
@@ -61,6 +83,12 @@ func NewFromFile(filename string, region string) (*AWS, error) {
 	return newAwsFromCreds(credentials.NewSharedCredentials(filename, "default"), region)
 }
 
+// NewFromSession initializes a new AWS object with the clients of the given
+// session, e.g. one that reads a named profile of the shared config file.
+func NewFromSession(sess *session.Session) *AWS {
+	return newAwsFromSession(sess)
+}
+
 // Initialize a new AWS object from defaults.
 // Looks for env variables, shared credential file, and EC2 Instance Roles.
 func NewDefault(region string) (*AWS, error) {
@@ -102,11 +130,7 @@ func newAwsFromCredsWithEndpoint(creds *credentials.Credentials, region, endpoin
 		return nil, err
 	}
 
-	return &AWS{
-		uploader: s3manager.NewUploader(sess),
-		ec2:      ec2.New(sess),
-		s3:       s3.New(sess),
-	}, nil
+	return newAwsFromSession(sess), nil
 }
 
 // Initialize a new AWS object targeting a specific endpoint from individual bits. SessionToken is optional
@@ -127,6 +151,69 @@ func NewForEndpointFromFile(filename, endpoint, region, caBundle string, skipSSL
 	return newAwsFromCredsWithEndpoint(credentials.NewSharedCredentials(filename, "default"), region, endpoint, caBundle, skipSSLVerification)
 }
 
+// CreateBucket creates the S3 bucket in the region of the session and waits
+// until it exists. The bucket is tagged with the given tags, if any, so that
+// it is found by DescribeResourcesByTagKey like the EC2 resources.
+func (a *AWS) CreateBucket(bucket string, tags map[string]string) error {
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(bucket),
+	}
+	// us-east-1 is the default location, which S3 rejects as an explicit
+	// location constraint
+	if region := aws.StringValue(a.s3.Config.Region); region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+			LocationConstraint: aws.String(region),
+		}
+	}
+
+	logrus.Infof("[AWS] 🪣 Creating S3 bucket: %s", bucket)
+	if _, err := a.s3.CreateBucket(input); err != nil {
+		return err
+	}
+	err := a.s3.WaitUntilBucketExists(&s3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil || len(tags) == 0 {
+		return err
+	}
+
+	var tagSet []*s3.Tag
+	for _, tag := range ec2Tags(tags) {
+		tagSet = append(tagSet, &s3.Tag{Key: tag.Key, Value: tag.Value})
+	}
+	_, err = a.s3.PutBucketTagging(&s3.PutBucketTaggingInput{
+		Bucket:  aws.String(bucket),
+		Tagging: &s3.Tagging{TagSet: tagSet},
+	})
+	return err
+}
+
+// DeleteBucket deletes the objects in the S3 bucket and then the bucket
+// itself.
+func (a *AWS) DeleteBucket(bucket string) error {
+	var keys []*string
+	err := a.s3.ListObjectsV2Pages(&s3.ListObjectsV2Input{Bucket: aws.String(bucket)}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, object.Key)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		logrus.Infof("[AWS] 🧹 Deleting object from S3: %s/%s", bucket, aws.StringValue(key))
+		if _, err := a.s3.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: key}); err != nil {
+			return err
+		}
+	}
+
+	logrus.Infof("[AWS] 🧹 Deleting S3 bucket: %s", bucket)
+	_, err = a.s3.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(bucket)})
+	return err
+}
+
 func (a *AWS) Upload(filename, bucket, key string) (*s3manager.UploadOutput, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -150,11 +237,15 @@ func (a *AWS) Upload(filename, bucket, key string) (*s3manager.UploadOutput, err
 	)
 }
 
+// importSnapshotPollInterval is the delay between two checks of the state of
+// an import task in WaitUntilImportSnapshotTaskCompleted.
+var importSnapshotPollInterval = 15 * time.Second
+
 // WaitUntilImportSnapshotCompleted uses the Amazon EC2 API operation
 // DescribeImportSnapshots to wait for a condition to be met before returning.
 // If the condition is not met within the max attempt window, an error will
 // be returned.
-func WaitUntilImportSnapshotTaskCompleted(c *ec2.EC2, input *ec2.DescribeImportSnapshotTasksInput) error {
+func WaitUntilImportSnapshotTaskCompleted(c ec2iface.EC2API, input *ec2.DescribeImportSnapshotTasksInput) error {
 	return WaitUntilImportSnapshotTaskCompletedWithContext(c, aws.BackgroundContext(), input)
 }
 
@@ -172,11 +263,11 @@ func WaitUntilImportSnapshotTaskCompleted(c *ec2.EC2, input *ec2.DescribeImportS
 // checking the status of the image import until it succeeds or fails. This
 // process can take anywhere from 5 to 60+ minutes depending on how quickly
 // AWS can import the snapshot.
-func WaitUntilImportSnapshotTaskCompletedWithContext(c *ec2.EC2, ctx aws.Context, input *ec2.DescribeImportSnapshotTasksInput, opts ...request.WaiterOption) error {
+func WaitUntilImportSnapshotTaskCompletedWithContext(c ec2iface.EC2API, ctx aws.Context, input *ec2.DescribeImportSnapshotTasksInput, opts ...request.WaiterOption) error {
 	w := request.Waiter{
 		Name:        "WaitUntilImportSnapshotTaskCompleted",
 		MaxAttempts: 0,
-		Delay:       request.ConstantWaiterDelay(15 * time.Second),
+		Delay:       request.ConstantWaiterDelay(importSnapshotPollInterval),
 		Acceptors: []request.WaiterAcceptor{
 			{
 				State:   request.SuccessWaiterState,
@@ -189,7 +280,6 @@ func WaitUntilImportSnapshotTaskCompletedWithContext(c *ec2.EC2, ctx aws.Context
 				Expected: "deleted",
 			},
 		},
-		Logger: c.Config.Logger,
 		NewRequest: func(opts []request.Option) (*request.Request, error) {
 			var inCpy *ec2.DescribeImportSnapshotTasksInput
 			if input != nil {
@@ -207,46 +297,41 @@ func WaitUntilImportSnapshotTaskCompletedWithContext(c *ec2.EC2, ctx aws.Context
 	return w.WaitWithContext(ctx)
 }
 
-// Register is a function that imports a snapshot, waits for the snapshot to
-// fully import, tags the snapshot, cleans up the image in S3, and registers
-// an AMI in AWS.
-// The caller can optionally specify the boot mode of the AMI. If the boot
-// mode is not specified, then the instances launched from this AMI use the
-// default boot mode value of the instance type.
-// Returns the image ID and the snapshot ID.
-func (a *AWS) Register(name, bucket, key string, shareWith []string, rpmArch string, bootMode *string) (*string, *string, error) {
-	rpmArchToEC2Arch := map[string]string{
-		"x86_64":  "x86_64",
-		"aarch64": "arm64",
-	}
+// ImportSnapshot imports the disk image in S3 as an EBS snapshot and waits
+// for the import to complete. The format is the format of the disk image,
+// VMDK, VHD or RAW (case insensitive). AWS detects it if it is empty.
+// Returns the snapshot ID, which can be registered as an AMI with
+// RegisterSnapshot. The object in S3 is kept.
+func (a *AWS) ImportSnapshot(bucket, key, format string) (*string, error) {
+	return a.importSnapshot(bucket, key, format, fmt.Sprintf("Image Builder AWS Import of %s/%s", bucket, key), nil)
+}
 
-	ec2Arch, validArch := rpmArchToEC2Arch[rpmArch]
-	if !validArch {
-		return nil, nil, fmt.Errorf("ec2 doesn't support the following arch: %s", rpmArch)
+func (a *AWS) importSnapshot(bucket, key, format, description string, tags map[string]string) (*string, error) {
+	diskContainer := &ec2.SnapshotDiskContainer{
+		UserBucket: &ec2.UserBucket{
+			S3Bucket: aws.String(bucket),
+			S3Key:    aws.String(key),
+		},
 	}
-
-	if bootMode != nil {
-		if !slices.Contains(ec2.BootModeValues_Values(), *bootMode) {
-			return nil, nil, fmt.Errorf("ec2 doesn't support the following boot mode: %s", *bootMode)
+	if format != "" {
+		format = strings.ToUpper(format)
+		if !slices.Contains(ec2.DiskImageFormat_Values(), format) {
+			return nil, fmt.Errorf("ec2 doesn't support importing snapshots from the following format: %s", format)
 		}
+		diskContainer.Format = aws.String(format)
 	}
 
 	logrus.Infof("[AWS] 📥 Importing snapshot from image: %s/%s", bucket, key)
-	snapshotDescription := fmt.Sprintf("Image Builder AWS Import of %s", name)
 	importTaskOutput, err := a.ec2.ImportSnapshot(
 		&ec2.ImportSnapshotInput{
-			Description: aws.String(snapshotDescription),
-			DiskContainer: &ec2.SnapshotDiskContainer{
-				UserBucket: &ec2.UserBucket{
-					S3Bucket: aws.String(bucket),
-					S3Key:    aws.String(key),
-				},
-			},
+			Description:       aws.String(description),
+			DiskContainer:     diskContainer,
+			TagSpecifications: tagSpecifications(tags, ec2.ResourceTypeImportSnapshotTask),
 		},
 	)
 	if err != nil {
 		logrus.Warnf("[AWS] error importing snapshot: %s", err)
-		return nil, nil, err
+		return nil, err
 	}
 
 	logrus.Infof("[AWS] 🚚 Waiting for snapshot to finish importing: %s", *importTaskOutput.ImportTaskId)
@@ -258,6 +343,61 @@ func (a *AWS) Register(name, bucket, key string, shareWith []string, rpmArch str
 			},
 		},
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	importOutput, err := a.ec2.DescribeImportSnapshotTasks(
+		&ec2.DescribeImportSnapshotTasksInput{
+			ImportTaskIds: []*string{
+				importTaskOutput.ImportTaskId,
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return importOutput.ImportSnapshotTasks[0].SnapshotTaskDetail.SnapshotId, nil
+}
+
+// ec2Arch returns the EC2 architecture of the rpm architecture after checking
+// that EC2 supports it and the boot mode, if any.
+func ec2Arch(rpmArch string, bootMode *string) (string, error) {
+	rpmArchToEC2Arch := map[string]string{
+		"x86_64":  "x86_64",
+		"aarch64": "arm64",
+	}
+
+	arch, validArch := rpmArchToEC2Arch[rpmArch]
+	if !validArch {
+		return "", fmt.Errorf("ec2 doesn't support the following arch: %s", rpmArch)
+	}
+
+	if bootMode != nil {
+		if !slices.Contains(ec2.BootModeValues_Values(), *bootMode) {
+			return "", fmt.Errorf("ec2 doesn't support the following boot mode: %s", *bootMode)
+		}
+	}
+	return arch, nil
+}
+
+// Register is a function that imports a snapshot, waits for the snapshot to
+// fully import, tags the snapshot, cleans up the image in S3, and registers
+// an AMI in AWS.
+// The caller can optionally specify the boot mode of the AMI. If the boot
+// mode is not specified, then the instances launched from this AMI use the
+// default boot mode value of the instance type.
+// Any additional tags are applied to the import task, the snapshot, and the
+// image alongside the Name tag.
+// Returns the image ID and the snapshot ID.
+func (a *AWS) Register(name, bucket, key string, shareWith []string, rpmArch string, bootMode *string, tags map[string]string) (*string, *string, error) {
+	if _, err := ec2Arch(rpmArch, bootMode); err != nil {
+		return nil, nil, err
+	}
+
+	snapshotDescription := fmt.Sprintf("Image Builder AWS Import of %s", name)
+	snapshotID, err := a.importSnapshot(bucket, key, "", snapshotDescription, tags)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -272,40 +412,41 @@ func (a *AWS) Register(name, bucket, key string, shareWith []string, rpmArch str
 		return nil, nil, err
 	}
 
-	importOutput, err := a.ec2.DescribeImportSnapshotTasks(
-		&ec2.DescribeImportSnapshotTasksInput{
-			ImportTaskIds: []*string{
-				importTaskOutput.ImportTaskId,
-			},
-		},
-	)
+	imageID, err := a.RegisterSnapshot(name, snapshotID, shareWith, rpmArch, bootMode, tags)
 	if err != nil {
 		return nil, nil, err
 	}
+	return imageID, snapshotID, nil
+}
 
-	snapshotID := importOutput.ImportSnapshotTasks[0].SnapshotTaskDetail.SnapshotId
+// RegisterSnapshot tags an existing EBS snapshot, e.g. one imported with
+// ImportSnapshot, and registers an AMI with it as the root device. The boot
+// mode, the sharing and the tags are the same as for Register.
+// Returns the image ID.
+func (a *AWS) RegisterSnapshot(name string, snapshotID *string, shareWith []string, rpmArch string, bootMode *string, tags map[string]string) (*string, error) {
+	arch, err := ec2Arch(rpmArch, bootMode)
+	if err != nil {
+		return nil, err
+	}
 
-	// Tag the snapshot with the image name.
+	// Tag the snapshot with the image name and any additional tags.
 	req, _ := a.ec2.CreateTagsRequest(
 		&ec2.CreateTagsInput{
 			Resources: []*string{snapshotID},
-			Tags: []*ec2.Tag{
-				{
-					Key:   aws.String("Name"),
-					Value: aws.String(name),
-				},
-			},
+			Tags:      ec2Tags(withNameTag(name, tags)),
 		},
 	)
+	req.ApplyOptions(retryEventualConsistency)
 	err = req.Send()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	logrus.Infof("[AWS] 📋 Registering AMI from imported snapshot: %s", *snapshotID)
-	registerOutput, err := a.ec2.RegisterImage(
+	registerOutput, err := a.ec2.RegisterImageWithContext(
+		aws.BackgroundContext(),
 		&ec2.RegisterImageInput{
-			Architecture:       aws.String(ec2Arch),
+			Architecture:       aws.String(arch),
 			BootMode:           bootMode,
 			VirtualizationType: aws.String("hvm"),
 			Name:               aws.String(name),
@@ -320,42 +461,83 @@ func (a *AWS) Register(name, bucket, key string, shareWith []string, rpmArch str
 				},
 			},
 		},
+		retryEventualConsistency,
 	)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	logrus.Infof("[AWS] 🎉 AMI registered: %s", *registerOutput.ImageId)
 
-	// Tag the image with the image name.
+	// Tag the image with the image name and any additional tags.
 	req, _ = a.ec2.CreateTagsRequest(
 		&ec2.CreateTagsInput{
 			Resources: []*string{registerOutput.ImageId},
-			Tags: []*ec2.Tag{
-				{
-					Key:   aws.String("Name"),
-					Value: aws.String(name),
-				},
-			},
+			Tags:      ec2Tags(withNameTag(name, tags)),
 		},
 	)
+	req.ApplyOptions(retryEventualConsistency)
 	err = req.Send()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	if len(shareWith) > 0 {
 		err = a.shareSnapshot(snapshotID, shareWith)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 		err = a.shareImage(registerOutput.ImageId, shareWith)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 	}
 
-	return registerOutput.ImageId, snapshotID, nil
+	return registerOutput.ImageId, nil
+}
+
+// imageAvailablePollInterval is the delay between two checks of the state of
+// an image in WaitForImageAvailable.
+var imageAvailablePollInterval = 15 * time.Second
+
+// WaitForImageAvailable blocks until the image is available, so that
+// instances can be launched from it. Registered images are pending for a
+// while before they become available. It fails if the image ends up in
+// another state or is still not available after the timeout.
+func (a *AWS) WaitForImageAvailable(imageID *string, timeout time.Duration) error {
+	logrus.Infof("[AWS] ⏳ Waiting for AMI to become available: %s", aws.StringValue(imageID))
+	deadline := time.Now().Add(timeout)
+	for {
+		out, err := a.ec2.DescribeImagesWithContext(aws.BackgroundContext(), &ec2.DescribeImagesInput{
+			ImageIds: []*string{imageID},
+		}, retryEventualConsistency)
+		if err != nil {
+			return err
+		}
+
+		// a newly registered image may not be listed yet
+		state := ec2.ImageStatePending
+		if len(out.Images) > 0 {
+			image := out.Images[0]
+			state = aws.StringValue(image.State)
+			if state != ec2.ImageStatePending && state != ec2.ImageStateAvailable {
+				reason := ""
+				if image.StateReason != nil {
+					reason = ": " + aws.StringValue(image.StateReason.Message)
+				}
+				return fmt.Errorf("AMI %s is %s instead of available%s", aws.StringValue(imageID), state, reason)
+			}
+		}
+		if state == ec2.ImageStateAvailable {
+			logrus.Infof("[AWS] 🎉 AMI is available: %s", aws.StringValue(imageID))
+			return nil
+		}
+
+		if time.Now().Add(imageAvailablePollInterval).After(deadline) {
+			return fmt.Errorf("AMI %s is still pending after %s", aws.StringValue(imageID), timeout)
+		}
+		time.Sleep(imageAvailablePollInterval)
+	}
 }
 
 // target region is determined by the region configured in the aws session
@@ -392,7 +574,7 @@ func (a *AWS) CopyImage(name, ami, sourceRegion string) (string, error) {
 				Expected: "failed",
 			},
 		},
-		Logger: a.ec2.Config.Logger,
+		RequestOptions: []request.Option{retryEventualConsistency},
 		NewRequest: func(opts []request.Option) (*request.Request, error) {
 			var inCpy *ec2.DescribeImagesInput
 			if dIInput != nil {
@@ -411,7 +593,7 @@ func (a *AWS) CopyImage(name, ami, sourceRegion string) (string, error) {
 	}
 
 	// Tag image with name
-	_, err = a.ec2.CreateTags(&ec2.CreateTagsInput{
+	_, err = a.ec2.CreateTagsWithContext(aws.BackgroundContext(), &ec2.CreateTagsInput{
 		Resources: []*string{result.ImageId},
 		Tags: []*ec2.Tag{
 			{
@@ -419,13 +601,13 @@ func (a *AWS) CopyImage(name, ami, sourceRegion string) (string, error) {
 				Value: aws.String(name),
 			},
 		},
-	})
+	}, retryEventualConsistency)
 
 	if err != nil {
 		return *result.ImageId, err
 	}
 
-	imgs, err := a.ec2.DescribeImages(dIInput)
+	imgs, err := a.ec2.DescribeImagesWithContext(aws.BackgroundContext(), dIInput, retryEventualConsistency)
 	if err != nil {
 		return *result.ImageId, err
 	}
@@ -435,7 +617,7 @@ func (a *AWS) CopyImage(name, ami, sourceRegion string) (string, error) {
 
 	// Tag snapshot with name
 	for _, bdm := range imgs.Images[0].BlockDeviceMappings {
-		_, err = a.ec2.CreateTags(&ec2.CreateTagsInput{
+		_, err = a.ec2.CreateTagsWithContext(aws.BackgroundContext(), &ec2.CreateTagsInput{
 			Resources: []*string{bdm.Ebs.SnapshotId},
 			Tags: []*ec2.Tag{
 				{
@@ -443,7 +625,7 @@ func (a *AWS) CopyImage(name, ami, sourceRegion string) (string, error) {
 					Value: aws.String(name),
 				},
 			},
-		})
+		}, retryEventualConsistency)
 		if err != nil {
 			return *result.ImageId, err
 		}
@@ -493,13 +675,15 @@ func (a *AWS) shareImage(ami *string, userIds []string) error {
 			UserId: id,
 		})
 	}
-	_, err := a.ec2.ModifyImageAttribute(
+	_, err := a.ec2.ModifyImageAttributeWithContext(
+		aws.BackgroundContext(),
 		&ec2.ModifyImageAttributeInput{
 			ImageId: ami,
 			LaunchPermission: &ec2.LaunchPermissionModifications{
 				Add: launchPerms,
 			},
 		},
+		retryEventualConsistency,
 	)
 	if err != nil {
 		logrus.Warnf("[AWS] 📨 Error sharing AMI: %v", err)
@@ -515,13 +699,15 @@ func (a *AWS) shareSnapshot(snapshotId *string, userIds []string) error {
 	for i := range userIds {
 		uIds = append(uIds, &userIds[i])
 	}
-	_, err := a.ec2.ModifySnapshotAttribute(
+	_, err := a.ec2.ModifySnapshotAttributeWithContext(
+		aws.BackgroundContext(),
 		&ec2.ModifySnapshotAttributeInput{
 			Attribute:     aws.String(ec2.SnapshotAttributeNameCreateVolumePermission),
 			OperationType: aws.String("add"),
 			SnapshotId:    snapshotId,
 			UserIds:       uIds,
 		},
+		retryEventualConsistency,
 	)
 	if err != nil {
 		logrus.Warnf("[AWS] 📨 Error sharing ec2 snapshot: %v", err)
@@ -623,13 +809,31 @@ func (a *AWS) Regions() ([]string, error) {
 	return result, nil
 }
 
-func (a *AWS) CreateSecurityGroupEC2(name, description string) (*ec2.CreateSecurityGroupOutput, error) {
+// CreateSecurityGroupEC2 creates a security group in the given VPC, the
+// default VPC if vpcID is nil.
+func (a *AWS) CreateSecurityGroupEC2(name, description string, tags map[string]string, vpcID *string) (*ec2.CreateSecurityGroupOutput, error) {
 	return a.ec2.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
-		GroupName:   aws.String(name),
-		Description: aws.String(description),
+		GroupName:         aws.String(name),
+		Description:       aws.String(description),
+		TagSpecifications: tagSpecifications(tags, ec2.ResourceTypeSecurityGroup),
+		VpcId:             vpcID,
 	})
 }
 
+// SubnetVPC returns the ID of the VPC the subnet belongs to.
+func (a *AWS) SubnetVPC(subnetID *string) (*string, error) {
+	out, err := a.ec2.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		SubnetIds: []*string{subnetID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Subnets) != 1 {
+		return nil, fmt.Errorf("subnet %s not found", aws.StringValue(subnetID))
+	}
+	return out.Subnets[0].VpcId, nil
+}
+
 func (a *AWS) DeleteSecurityGroupEC2(groupID *string) (*ec2.DeleteSecurityGroupOutput, error) {
 	return a.ec2.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
 		GroupId: groupID,
@@ -637,29 +841,160 @@ func (a *AWS) DeleteSecurityGroupEC2(groupID *string) (*ec2.DeleteSecurityGroupO
 }
 
 func (a *AWS) AuthorizeSecurityGroupIngressEC2(groupID *string, address string, from, to int64, proto string) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
-	return a.ec2.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+	return a.ec2.AuthorizeSecurityGroupIngressWithContext(aws.BackgroundContext(), &ec2.AuthorizeSecurityGroupIngressInput{
 		CidrIp:     aws.String(address),
 		GroupId:    groupID,
 		FromPort:   aws.Int64(from),
 		ToPort:     aws.Int64(to),
 		IpProtocol: aws.String(proto),
-	})
+	}, retryEventualConsistency)
+}
+
+// SpotOptions configures RunInstanceEC2 to request a one-time spot instance
+// instead of an on-demand instance.
+type SpotOptions struct {
+	// MaxPrice is the maximum hourly price in USD. If empty, the on-demand
+	// price is used as the maximum.
+	MaxPrice string
+
+	// FallbackOnDemand launches an on-demand instance when the spot request
+	// can't be fulfilled because of a lack of capacity or a too low price.
+	FallbackOnDemand bool
 }
 
-func (a *AWS) RunInstanceEC2(imageID, secGroupID *string, userData, instanceType string) (*ec2.Reservation, error) {
-	reservation, err := a.ec2.RunInstances(&ec2.RunInstancesInput{
-		MaxCount:         aws.Int64(1),
-		MinCount:         aws.Int64(1),
-		ImageId:          imageID,
-		InstanceType:     aws.String(instanceType),
-		SecurityGroupIds: []*string{secGroupID},
-		UserData:         aws.String(encodeBase64(userData)),
+// spotCapacityErrorCodes are the error codes returned by RunInstances when a
+// spot request can't be fulfilled right now.
+var spotCapacityErrorCodes = []string{
+	"InsufficientInstanceCapacity",
+	"MaxSpotInstanceCountExceeded",
+	"SpotMaxPriceTooLow",
+}
+
+func isSpotCapacityError(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && slices.Contains(spotCapacityErrorCodes, awsErr.Code())
+}
+
+// RootVolumeOptions configures the root volume of the instances launched by
+// RunInstanceEC2 instead of the one of the block device mapping of the image.
+type RootVolumeOptions struct {
+	// Size is the size of the volume in GiB. It must be at least the size of
+	// the snapshot of the root device of the image. If 0, the size of the
+	// snapshot is used.
+	Size int64
+
+	// Type is the EBS volume type, e.g. gp3. If empty, the type of the image
+	// is used.
+	Type string
+}
+
+// rootBlockDeviceMapping returns the block device mapping that replaces the
+// root volume of the image with one with the given options.
+func (a *AWS) rootBlockDeviceMapping(imageID *string, rootVolume *RootVolumeOptions) (*ec2.BlockDeviceMapping, error) {
+	if rootVolume.Type != "" && !slices.Contains(ec2.VolumeType_Values(), rootVolume.Type) {
+		return nil, fmt.Errorf("ec2 doesn't support the following volume type: %s", rootVolume.Type)
+	}
+
+	out, err := a.ec2.DescribeImages(&ec2.DescribeImagesInput{
+		ImageIds: []*string{imageID},
 	})
 	if err != nil {
 		return nil, err
 	}
+	if len(out.Images) != 1 {
+		return nil, fmt.Errorf("image %s not found", aws.StringValue(imageID))
+	}
+	image := out.Images[0]
+
+	for _, mapping := range image.BlockDeviceMappings {
+		if aws.StringValue(mapping.DeviceName) != aws.StringValue(image.RootDeviceName) || mapping.Ebs == nil {
+			continue
+		}
+		snapshotSize := aws.Int64Value(mapping.Ebs.VolumeSize)
+		if rootVolume.Size != 0 && rootVolume.Size < snapshotSize {
+			return nil, fmt.Errorf("root volume size of %d GiB is smaller than the %d GiB snapshot of image %s", rootVolume.Size, snapshotSize, aws.StringValue(imageID))
+		}
+
+		ebs := &ec2.EbsBlockDevice{
+			DeleteOnTermination: aws.Bool(true),
+		}
+		if rootVolume.Size != 0 {
+			ebs.VolumeSize = aws.Int64(rootVolume.Size)
+		}
+		if rootVolume.Type != "" {
+			ebs.VolumeType = aws.String(rootVolume.Type)
+		}
+		return &ec2.BlockDeviceMapping{
+			DeviceName: image.RootDeviceName,
+			Ebs:        ebs,
+		}, nil
+	}
+	return nil, fmt.Errorf("image %s has no EBS root device", aws.StringValue(imageID))
+}
+
+// RunInstanceEC2 launches a single instance from the given image and waits
+// until it is running. The tags are applied to both the instance and its
+// volumes. If spot is not nil, a spot instance is requested instead of an
+// on-demand one. If rootVolume is not nil, it configures the root volume of
+// the instance. The instance is launched into the subnet if subnetID is not
+// nil, which must belong to the VPC of the security group.
+func (a *AWS) RunInstanceEC2(imageID, secGroupID *string, userData, instanceType string, tags map[string]string, spot *SpotOptions, rootVolume *RootVolumeOptions, subnetID *string) (*ec2.Reservation, error) {
+	input := &ec2.RunInstancesInput{
+		MaxCount:          aws.Int64(1),
+		MinCount:          aws.Int64(1),
+		ImageId:           imageID,
+		InstanceType:      aws.String(instanceType),
+		SecurityGroupIds:  []*string{secGroupID},
+		SubnetId:          subnetID,
+		UserData:          aws.String(encodeBase64(userData)),
+		TagSpecifications: tagSpecifications(tags, ec2.ResourceTypeInstance, ec2.ResourceTypeVolume),
+	}
+	if rootVolume != nil {
+		mapping, err := a.rootBlockDeviceMapping(imageID, rootVolume)
+		if err != nil {
+			return nil, err
+		}
+		input.BlockDeviceMappings = []*ec2.BlockDeviceMapping{mapping}
+	}
+	if spot != nil {
+		spotOptions := &ec2.SpotMarketOptions{
+			SpotInstanceType:             aws.String(ec2.SpotInstanceTypeOneTime),
+			InstanceInterruptionBehavior: aws.String(ec2.InstanceInterruptionBehaviorTerminate),
+		}
+		if spot.MaxPrice != "" {
+			spotOptions.MaxPrice = aws.String(spot.MaxPrice)
+		}
+		input.InstanceMarketOptions = &ec2.InstanceMarketOptionsRequest{
+			MarketType:  aws.String(ec2.MarketTypeSpot),
+			SpotOptions: spotOptions,
+		}
+	}
+
+	reservation, err := a.ec2.RunInstancesWithContext(aws.BackgroundContext(), input, retryEventualConsistency)
+	if err != nil && spot != nil && isSpotCapacityError(err) {
+		if !spot.FallbackOnDemand {
+			return nil, fmt.Errorf("spot instance request could not be fulfilled: %w", err)
+		}
+		logrus.Warnf("[AWS] spot instance request could not be fulfilled, falling back to on-demand: %v", err)
+		input.InstanceMarketOptions = nil
+		reservation, err = a.ec2.RunInstancesWithContext(aws.BackgroundContext(), input, retryEventualConsistency)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	if err := a.ec2.WaitUntilInstanceRunning(describeInstanceInput(reservation.Instances[0].InstanceId)); err != nil {
+	instance := reservation.Instances[0]
+	if instance.SpotInstanceRequestId != nil {
+		logrus.Infof("[AWS] ⏳ Waiting for spot instance request to be fulfilled: %s", *instance.SpotInstanceRequestId)
+		err := a.ec2.WaitUntilSpotInstanceRequestFulfilled(&ec2.DescribeSpotInstanceRequestsInput{
+			SpotInstanceRequestIds: []*string{instance.SpotInstanceRequestId},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("spot instance request %s was not fulfilled: %w", *instance.SpotInstanceRequestId, err)
+		}
+	}
+
+	if err := a.ec2.WaitUntilInstanceRunningWithContext(aws.BackgroundContext(), describeInstanceInput(instance.InstanceId), request.WithWaiterRequestOptions(retryEventualConsistency)); err != nil {
 		return nil, err
 	}
 	return reservation, nil
@@ -717,6 +1052,51 @@ func (a *AWS) DeleteEC2Image(imageID, snapshotID *string) error {
 	return retErr
 }
 
+// withNameTag returns a copy of tags with the Name tag set to name.
+func withNameTag(name string, tags map[string]string) map[string]string {
+	named := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		named[k] = v
+	}
+	named["Name"] = name
+	return named
+}
+
+// ec2Tags converts a map of tags to a list of EC2 tags sorted by key.
+func ec2Tags(tags map[string]string) []*ec2.Tag {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ec2tags := make([]*ec2.Tag, 0, len(keys))
+	for _, k := range keys {
+		ec2tags = append(ec2tags, &ec2.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(tags[k]),
+		})
+	}
+	return ec2tags
+}
+
+// tagSpecifications returns a tag specification with the given tags for each
+// resource type. EC2 rejects empty tag specifications, so nil is returned when
+// there are no tags.
+func tagSpecifications(tags map[string]string, resourceTypes ...string) []*ec2.TagSpecification {
+	if len(tags) == 0 {
+		return nil
+	}
+	var specs []*ec2.TagSpecification
+	for _, rt := range resourceTypes {
+		specs = append(specs, &ec2.TagSpecification{
+			ResourceType: aws.String(rt),
+			Tags:         ec2Tags(tags),
+		})
+	}
+	return specs
+}
+
 // encodeBase64 encodes string to base64-encoded string
 func encodeBase64(input string) string {
 	return base64.StdEncoding.EncodeToString([]byte(input))
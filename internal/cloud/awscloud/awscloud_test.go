@@ -0,0 +1,589 @@
+package awscloud
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slices"
+)
+
+// fakeEC2 records the inputs of the calls made to it. Calls to methods that
+// are not overridden panic through the nil embedded interface.
+type fakeEC2 struct {
+	ec2iface.EC2API
+
+	// noSpotCapacity makes all spot instance requests fail
+	noSpotCapacity bool
+
+	// images are returned by DescribeImages
+	images []*ec2.Image
+	// subnets are returned by DescribeSubnets
+	subnets []*ec2.Subnet
+
+	runInstancesInputs       []*ec2.RunInstancesInput
+	spotRequestsWaited       []string
+	createSecurityGroupInput *ec2.CreateSecurityGroupInput
+}
+
+func (f *fakeEC2) RunInstancesWithContext(_ aws.Context, input *ec2.RunInstancesInput, _ ...request.Option) (*ec2.Reservation, error) {
+	// copy the input since the caller may modify it for retries
+	inCpy := *input
+	f.runInstancesInputs = append(f.runInstancesInputs, &inCpy)
+
+	instance := &ec2.Instance{InstanceId: aws.String("i-0123456789")}
+	if input.InstanceMarketOptions != nil {
+		if f.noSpotCapacity {
+			return nil, awserr.New("InsufficientInstanceCapacity", "no capacity", nil)
+		}
+		instance.SpotInstanceRequestId = aws.String("sir-0123456789")
+	}
+	return &ec2.Reservation{
+		Instances: []*ec2.Instance{instance},
+	}, nil
+}
+
+func (f *fakeEC2) DescribeImages(input *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	out := &ec2.DescribeImagesOutput{}
+	for _, image := range f.images {
+		if slices.Contains(aws.StringValueSlice(input.ImageIds), aws.StringValue(image.ImageId)) {
+			out.Images = append(out.Images, image)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEC2) DescribeSubnets(input *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	out := &ec2.DescribeSubnetsOutput{}
+	for _, subnet := range f.subnets {
+		if slices.Contains(aws.StringValueSlice(input.SubnetIds), aws.StringValue(subnet.SubnetId)) {
+			out.Subnets = append(out.Subnets, subnet)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEC2) WaitUntilSpotInstanceRequestFulfilled(input *ec2.DescribeSpotInstanceRequestsInput) error {
+	f.spotRequestsWaited = append(f.spotRequestsWaited, aws.StringValueSlice(input.SpotInstanceRequestIds)...)
+	return nil
+}
+
+func (f *fakeEC2) WaitUntilInstanceRunningWithContext(aws.Context, *ec2.DescribeInstancesInput, ...request.WaiterOption) error {
+	return nil
+}
+
+func (f *fakeEC2) CreateSecurityGroup(input *ec2.CreateSecurityGroupInput) (*ec2.CreateSecurityGroupOutput, error) {
+	f.createSecurityGroupInput = input
+	return &ec2.CreateSecurityGroupOutput{GroupId: aws.String("sg-0123456789")}, nil
+}
+
+// newAWSForServer returns an *AWS object whose API calls are handled by a
+// test server with the given handler.
+func newAWSForServer(t *testing.T, handler http.HandlerFunc) *AWS {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	a, err := newAwsFromCredsWithEndpoint(credentials.NewStaticCredentials("key-id", "secret-key", ""), "us-east-1", server.URL, "", false)
+	require.NoError(t, err)
+	return a
+}
+
+// testRequestID is the AWS request ID of the errors of writeEC2Error
+const testRequestID = "3f1c2a5e-9d7b-4e61-8a0f-2b6c4d8e1a97"
+
+// writeEC2Error writes an EC2 error response with the given code.
+func writeEC2Error(t *testing.T, w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	_, err := fmt.Fprintf(w, `<Response><Errors><Error><Code>%s</Code><Message>%s error</Message></Error></Errors><RequestID>%s</RequestID></Response>`, code, code, testRequestID)
+	require.NoError(t, err)
+}
+
+func tagsToMap(tags []*ec2.Tag) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		m[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return m
+}
+
+func TestRunInstanceEC2Tags(t *testing.T) {
+	fake := &fakeEC2{}
+	a := &AWS{ec2: fake}
+
+	tags := map[string]string{
+		"boot-aws-run-id": "1234",
+		"owner":           "image-builder",
+	}
+	_, err := a.RunInstanceEC2(aws.String("ami-0123456789"), aws.String("sg-0123456789"), "", "t3.small", tags, nil, nil, nil)
+	require.NoError(t, err)
+
+	require.Len(t, fake.runInstancesInputs, 1)
+	specs := fake.runInstancesInputs[0].TagSpecifications
+	require.Len(t, specs, 2)
+	assert.Equal(t, ec2.ResourceTypeInstance, aws.StringValue(specs[0].ResourceType))
+	assert.Equal(t, ec2.ResourceTypeVolume, aws.StringValue(specs[1].ResourceType))
+	for _, spec := range specs {
+		assert.Equal(t, tags, tagsToMap(spec.Tags))
+	}
+}
+
+func TestRunInstanceEC2NoTags(t *testing.T) {
+	fake := &fakeEC2{}
+	a := &AWS{ec2: fake}
+
+	_, err := a.RunInstanceEC2(aws.String("ami-0123456789"), aws.String("sg-0123456789"), "", "t3.small", nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, fake.runInstancesInputs, 1)
+	assert.Nil(t, fake.runInstancesInputs[0].TagSpecifications)
+	assert.Nil(t, fake.runInstancesInputs[0].InstanceMarketOptions)
+	assert.Empty(t, fake.spotRequestsWaited)
+}
+
+func TestRunInstanceEC2Spot(t *testing.T) {
+	fake := &fakeEC2{}
+	a := &AWS{ec2: fake}
+
+	_, err := a.RunInstanceEC2(aws.String("ami-0123456789"), aws.String("sg-0123456789"), "", "t3.small", nil, &SpotOptions{MaxPrice: "0.05"}, nil, nil)
+	require.NoError(t, err)
+
+	require.Len(t, fake.runInstancesInputs, 1)
+	marketOptions := fake.runInstancesInputs[0].InstanceMarketOptions
+	require.NotNil(t, marketOptions)
+	assert.Equal(t, ec2.MarketTypeSpot, aws.StringValue(marketOptions.MarketType))
+	require.NotNil(t, marketOptions.SpotOptions)
+	assert.Equal(t, "0.05", aws.StringValue(marketOptions.SpotOptions.MaxPrice))
+	assert.Equal(t, ec2.SpotInstanceTypeOneTime, aws.StringValue(marketOptions.SpotOptions.SpotInstanceType))
+	assert.Equal(t, []string{"sir-0123456789"}, fake.spotRequestsWaited)
+}
+
+func TestRunInstanceEC2SpotNoCapacity(t *testing.T) {
+	fake := &fakeEC2{noSpotCapacity: true}
+	a := &AWS{ec2: fake}
+
+	_, err := a.RunInstanceEC2(aws.String("ami-0123456789"), aws.String("sg-0123456789"), "", "t3.small", nil, &SpotOptions{}, nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "InsufficientInstanceCapacity")
+	assert.Len(t, fake.runInstancesInputs, 1)
+}
+
+func TestRunInstanceEC2SpotFallback(t *testing.T) {
+	fake := &fakeEC2{noSpotCapacity: true}
+	a := &AWS{ec2: fake}
+
+	_, err := a.RunInstanceEC2(aws.String("ami-0123456789"), aws.String("sg-0123456789"), "", "t3.small", nil, &SpotOptions{FallbackOnDemand: true}, nil, nil)
+	require.NoError(t, err)
+
+	require.Len(t, fake.runInstancesInputs, 2)
+	assert.NotNil(t, fake.runInstancesInputs[0].InstanceMarketOptions)
+	assert.Nil(t, fake.runInstancesInputs[1].InstanceMarketOptions)
+	assert.Empty(t, fake.spotRequestsWaited)
+}
+
+func TestRunInstanceEC2RootVolume(t *testing.T) {
+	fake := &fakeEC2{
+		images: []*ec2.Image{
+			{
+				ImageId:        aws.String("ami-0123456789"),
+				RootDeviceName: aws.String("/dev/sda1"),
+				BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+					{
+						DeviceName: aws.String("/dev/sda1"),
+						Ebs: &ec2.EbsBlockDevice{
+							SnapshotId: aws.String("snap-0123456789"),
+							VolumeSize: aws.Int64(10),
+							VolumeType: aws.String(ec2.VolumeTypeGp2),
+						},
+					},
+				},
+			},
+		},
+	}
+	a := &AWS{ec2: fake}
+
+	_, err := a.RunInstanceEC2(aws.String("ami-0123456789"), aws.String("sg-0123456789"), "", "t3.small", nil, nil, &RootVolumeOptions{Size: 20, Type: ec2.VolumeTypeGp3}, nil)
+	require.NoError(t, err)
+	require.Len(t, fake.runInstancesInputs, 1)
+	assert.Equal(t, []*ec2.BlockDeviceMapping{
+		{
+			DeviceName: aws.String("/dev/sda1"),
+			Ebs: &ec2.EbsBlockDevice{
+				DeleteOnTermination: aws.Bool(true),
+				VolumeSize:          aws.Int64(20),
+				VolumeType:          aws.String(ec2.VolumeTypeGp3),
+			},
+		},
+	}, fake.runInstancesInputs[0].BlockDeviceMappings)
+
+	_, err = a.RunInstanceEC2(aws.String("ami-0123456789"), aws.String("sg-0123456789"), "", "t3.small", nil, nil, &RootVolumeOptions{Size: 5}, nil)
+	assert.EqualError(t, err, "root volume size of 5 GiB is smaller than the 10 GiB snapshot of image ami-0123456789")
+
+	_, err = a.RunInstanceEC2(aws.String("ami-0123456789"), aws.String("sg-0123456789"), "", "t3.small", nil, nil, &RootVolumeOptions{Type: "floppy"}, nil)
+	assert.EqualError(t, err, "ec2 doesn't support the following volume type: floppy")
+
+	_, err = a.RunInstanceEC2(aws.String("ami-9876543210"), aws.String("sg-0123456789"), "", "t3.small", nil, nil, &RootVolumeOptions{Size: 20}, nil)
+	assert.EqualError(t, err, "image ami-9876543210 not found")
+	assert.Len(t, fake.runInstancesInputs, 1)
+}
+
+func TestRunInstanceEC2Subnet(t *testing.T) {
+	fake := &fakeEC2{}
+	a := &AWS{ec2: fake}
+
+	_, err := a.RunInstanceEC2(aws.String("ami-0123456789"), aws.String("sg-0123456789"), "", "t3.small", nil, nil, nil, aws.String("subnet-0123456789"))
+	require.NoError(t, err)
+	require.Len(t, fake.runInstancesInputs, 1)
+	assert.Equal(t, "subnet-0123456789", aws.StringValue(fake.runInstancesInputs[0].SubnetId))
+	assert.Equal(t, []string{"sg-0123456789"}, aws.StringValueSlice(fake.runInstancesInputs[0].SecurityGroupIds))
+}
+
+func TestCreateSecurityGroupEC2VPC(t *testing.T) {
+	fake := &fakeEC2{}
+	a := &AWS{ec2: fake}
+
+	_, err := a.CreateSecurityGroupEC2("group", "description", nil, aws.String("vpc-0123456789"))
+	require.NoError(t, err)
+	assert.Equal(t, "vpc-0123456789", aws.StringValue(fake.createSecurityGroupInput.VpcId))
+
+	_, err = a.CreateSecurityGroupEC2("group", "description", nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, fake.createSecurityGroupInput.VpcId)
+}
+
+func TestSubnetVPC(t *testing.T) {
+	fake := &fakeEC2{
+		subnets: []*ec2.Subnet{
+			{SubnetId: aws.String("subnet-0123456789"), VpcId: aws.String("vpc-0123456789")},
+		},
+	}
+	a := &AWS{ec2: fake}
+
+	vpcID, err := a.SubnetVPC(aws.String("subnet-0123456789"))
+	require.NoError(t, err)
+	assert.Equal(t, "vpc-0123456789", aws.StringValue(vpcID))
+
+	_, err = a.SubnetVPC(aws.String("subnet-9876543210"))
+	assert.EqualError(t, err, "subnet subnet-9876543210 not found")
+}
+
+func TestCreateSecurityGroupEC2Tags(t *testing.T) {
+	fake := &fakeEC2{}
+	a := &AWS{ec2: fake}
+
+	tags := map[string]string{
+		"boot-aws-run-id": "1234",
+		"owner":           "image-builder",
+	}
+	_, err := a.CreateSecurityGroupEC2("group", "description", tags, nil)
+	require.NoError(t, err)
+
+	specs := fake.createSecurityGroupInput.TagSpecifications
+	require.Len(t, specs, 1)
+	assert.Equal(t, ec2.ResourceTypeSecurityGroup, aws.StringValue(specs[0].ResourceType))
+	assert.Equal(t, tags, tagsToMap(specs[0].Tags))
+}
+
+func TestEC2TagsSorted(t *testing.T) {
+	tags := ec2Tags(withNameTag("image", map[string]string{"b": "2", "a": "1"}))
+	require.Len(t, tags, 3)
+	assert.Equal(t, "Name", aws.StringValue(tags[0].Key))
+	assert.Equal(t, "a", aws.StringValue(tags[1].Key))
+	assert.Equal(t, "b", aws.StringValue(tags[2].Key))
+	assert.Equal(t, "image", aws.StringValue(tags[0].Value))
+}
+
+func TestNewForEndpoint(t *testing.T) {
+	a, err := NewForEndpoint("http://localhost:4566", "us-east-1", "test", "test", "", "", false)
+	require.NoError(t, err)
+
+	for _, client := range []*aws.Config{&a.ec2.(*ec2.EC2).Config, &a.s3.Config, &a.uploader.S3.(*s3.S3).Config} {
+		assert.Equal(t, "http://localhost:4566", aws.StringValue(client.Endpoint))
+		assert.Equal(t, "us-east-1", aws.StringValue(client.Region))
+	}
+	assert.True(t, aws.BoolValue(a.s3.Config.S3ForcePathStyle))
+
+	a, err = New("us-east-1", "test", "test", "")
+	require.NoError(t, err)
+	assert.Empty(t, aws.StringValue(a.s3.Config.Endpoint))
+	assert.False(t, aws.BoolValue(a.s3.Config.S3ForcePathStyle))
+}
+
+// fakeImageStates returns the given image states, one per call, and then
+// the last one.
+type fakeImageStates struct {
+	ec2iface.EC2API
+
+	states []string
+	calls  int
+}
+
+func (f *fakeImageStates) DescribeImagesWithContext(_ aws.Context, input *ec2.DescribeImagesInput, _ ...request.Option) (*ec2.DescribeImagesOutput, error) {
+	state := f.states[len(f.states)-1]
+	if f.calls < len(f.states) {
+		state = f.states[f.calls]
+	}
+	f.calls++
+	if state == "" {
+		return &ec2.DescribeImagesOutput{}, nil
+	}
+	return &ec2.DescribeImagesOutput{
+		Images: []*ec2.Image{
+			{
+				ImageId:     input.ImageIds[0],
+				State:       aws.String(state),
+				StateReason: &ec2.StateReason{Message: aws.String("Snapshot is corrupted")},
+			},
+		},
+	}, nil
+}
+
+func fastImagePolling(t *testing.T) {
+	orig := imageAvailablePollInterval
+	imageAvailablePollInterval = time.Millisecond
+	t.Cleanup(func() { imageAvailablePollInterval = orig })
+}
+
+func TestWaitForImageAvailable(t *testing.T) {
+	fastImagePolling(t)
+	fake := &fakeImageStates{states: []string{"", ec2.ImageStatePending, ec2.ImageStatePending, ec2.ImageStateAvailable}}
+	a := &AWS{ec2: fake}
+
+	require.NoError(t, a.WaitForImageAvailable(aws.String("ami-0123456789"), time.Minute))
+	assert.Equal(t, 4, fake.calls)
+}
+
+func TestWaitForImageAvailableFailed(t *testing.T) {
+	fastImagePolling(t)
+	fake := &fakeImageStates{states: []string{ec2.ImageStatePending, ec2.ImageStateFailed}}
+	a := &AWS{ec2: fake}
+
+	err := a.WaitForImageAvailable(aws.String("ami-0123456789"), time.Minute)
+	assert.EqualError(t, err, "AMI ami-0123456789 is failed instead of available: Snapshot is corrupted")
+	assert.Equal(t, 2, fake.calls)
+}
+
+func TestWaitForImageAvailableTimeout(t *testing.T) {
+	fastImagePolling(t)
+	fake := &fakeImageStates{states: []string{ec2.ImageStatePending}}
+	a := &AWS{ec2: fake}
+
+	err := a.WaitForImageAvailable(aws.String("ami-0123456789"), 20*time.Millisecond)
+	assert.EqualError(t, err, "AMI ami-0123456789 is still pending after 20ms")
+	assert.Greater(t, fake.calls, 1)
+}
+
+// fakeImportServer handles the EC2 calls of importing a snapshot and
+// registering it. The import task is active for the given number of checks
+// before it completes.
+type fakeImportServer struct {
+	t            *testing.T
+	activeChecks int
+
+	importForm   url.Values
+	checks       int
+	registerForm url.Values
+}
+
+func (f *fakeImportServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	require.NoError(f.t, r.ParseForm())
+	var response string
+	switch action := r.PostForm.Get("Action"); action {
+	case "ImportSnapshot":
+		f.importForm = r.PostForm
+		response = `<ImportSnapshotResponse><importTaskId>import-snap-0123456789</importTaskId></ImportSnapshotResponse>`
+	case "DescribeImportSnapshotTasks":
+		f.checks++
+		status := "completed"
+		if f.checks <= f.activeChecks {
+			status = "active"
+		}
+		response = `<DescribeImportSnapshotTasksResponse><importSnapshotTaskSet><item><importTaskId>import-snap-0123456789</importTaskId><snapshotTaskDetail><status>` + status + `</status><snapshotId>snap-0123456789</snapshotId></snapshotTaskDetail></item></importSnapshotTaskSet></DescribeImportSnapshotTasksResponse>`
+	case "CreateTags":
+		response = `<CreateTagsResponse><return>true</return></CreateTagsResponse>`
+	case "RegisterImage":
+		f.registerForm = r.PostForm
+		response = `<RegisterImageResponse><imageId>ami-0123456789</imageId></RegisterImageResponse>`
+	default:
+		f.t.Errorf("unexpected action %q", action)
+	}
+	_, err := w.Write([]byte(response))
+	require.NoError(f.t, err)
+}
+
+func TestImportSnapshotAndRegister(t *testing.T) {
+	orig := importSnapshotPollInterval
+	importSnapshotPollInterval = time.Millisecond
+	t.Cleanup(func() { importSnapshotPollInterval = orig })
+
+	server := &fakeImportServer{t: t, activeChecks: 2}
+	a := newAWSForServer(t, server.ServeHTTP)
+
+	snapshotID, err := a.ImportSnapshot("bucket", "disk.vmdk", "vmdk")
+	require.NoError(t, err)
+	assert.Equal(t, "snap-0123456789", aws.StringValue(snapshotID))
+	assert.Equal(t, "VMDK", server.importForm.Get("DiskContainer.Format"))
+	assert.Equal(t, "bucket", server.importForm.Get("DiskContainer.UserBucket.S3Bucket"))
+	assert.Equal(t, "disk.vmdk", server.importForm.Get("DiskContainer.UserBucket.S3Key"))
+	// two active checks, the completed one and the one to get the snapshot
+	assert.Equal(t, 4, server.checks)
+
+	imageID, err := a.RegisterSnapshot("image", snapshotID, nil, "aarch64", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ami-0123456789", aws.StringValue(imageID))
+	assert.Equal(t, "snap-0123456789", server.registerForm.Get("BlockDeviceMapping.1.Ebs.SnapshotId"))
+	assert.Equal(t, "arm64", server.registerForm.Get("Architecture"))
+}
+
+func TestImportSnapshotInvalidFormat(t *testing.T) {
+	a := &AWS{ec2: &fakeEC2{}}
+	_, err := a.ImportSnapshot("bucket", "disk.qcow2", "qcow2")
+	assert.EqualError(t, err, "ec2 doesn't support importing snapshots from the following format: QCOW2")
+}
+
+// fakeS3Server is a minimal S3 endpoint with path-style addressing that
+// records the calls made to it.
+type fakeS3Server struct {
+	t *testing.T
+
+	// keys of the objects listed in all buckets
+	objects []string
+	// buckets are listed by ListBuckets
+	buckets []fakeBucket
+
+	calls              []string
+	createBucketConfig string
+	bucketTagging      string
+}
+
+// fakeBucket is a bucket of the fakeS3Server, in eu-central-1 if the region
+// is empty.
+type fakeBucket struct {
+	name    string
+	region  string
+	created time.Time
+	tags    map[string]string
+}
+
+func (f *fakeS3Server) bucket(name string) *fakeBucket {
+	for i := range f.buckets {
+		if f.buckets[i].name == name {
+			return &f.buckets[i]
+		}
+	}
+	return nil
+}
+
+func (f *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	require.NoError(f.t, err)
+	f.calls = append(f.calls, r.Method+" "+r.URL.Path)
+
+	query := r.URL.Query()
+	if r.Method == http.MethodPut && strings.Count(r.URL.Path, "/") == 1 {
+		if query.Has("tagging") {
+			f.bucketTagging = string(body)
+		} else {
+			f.createBucketConfig = string(body)
+		}
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/":
+		var buckets strings.Builder
+		for _, bucket := range f.buckets {
+			fmt.Fprintf(&buckets, "<Bucket><Name>%s</Name><CreationDate>%s</CreationDate></Bucket>", bucket.name, bucket.created.Format(time.RFC3339))
+		}
+		_, err := fmt.Fprintf(w, `<ListAllMyBucketsResult><Buckets>%s</Buckets></ListAllMyBucketsResult>`, buckets.String())
+		require.NoError(f.t, err)
+	case r.Method == http.MethodGet && query.Has("location"):
+		bucket := f.bucket(strings.TrimPrefix(r.URL.Path, "/"))
+		require.NotNil(f.t, bucket)
+		region := bucket.region
+		if region == "" {
+			region = "eu-central-1"
+		} else if region == "us-east-1" {
+			region = ""
+		}
+		_, err := fmt.Fprintf(w, `<LocationConstraint>%s</LocationConstraint>`, region)
+		require.NoError(f.t, err)
+	case r.Method == http.MethodGet && query.Has("tagging"):
+		bucket := f.bucket(strings.TrimPrefix(r.URL.Path, "/"))
+		require.NotNil(f.t, bucket)
+		if len(bucket.tags) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			_, err := w.Write([]byte(`<Error><Code>NoSuchTagSet</Code><Message>The TagSet does not exist</Message></Error>`))
+			require.NoError(f.t, err)
+			return
+		}
+		var tags strings.Builder
+		for k, v := range bucket.tags {
+			fmt.Fprintf(&tags, "<Tag><Key>%s</Key><Value>%s</Value></Tag>", k, v)
+		}
+		_, err := fmt.Fprintf(w, `<Tagging><TagSet>%s</TagSet></Tagging>`, tags.String())
+		require.NoError(f.t, err)
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		var contents strings.Builder
+		for _, key := range f.objects {
+			fmt.Fprintf(&contents, "<Contents><Key>%s</Key></Contents>", key)
+		}
+		_, err := fmt.Fprintf(w, `<ListBucketResult><IsTruncated>false</IsTruncated>%s</ListBucketResult>`, contents.String())
+		require.NoError(f.t, err)
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// newAWSForS3Server returns an *AWS object for the region whose API calls
+// are handled by the fake S3 endpoint.
+func newAWSForS3Server(t *testing.T, region string, server *fakeS3Server) *AWS {
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	a, err := NewForEndpoint(httpServer.URL, region, "key-id", "secret-key", "", "", false)
+	require.NoError(t, err)
+	return a
+}
+
+func TestCreateBucket(t *testing.T) {
+	server := &fakeS3Server{t: t}
+	a := newAWSForS3Server(t, "eu-central-1", server)
+	require.NoError(t, a.CreateBucket("bucket", nil))
+	assert.Equal(t, []string{"PUT /bucket", "HEAD /bucket"}, server.calls)
+	assert.Contains(t, server.createBucketConfig, "<LocationConstraint>eu-central-1</LocationConstraint>")
+	assert.Empty(t, server.bucketTagging)
+
+	// us-east-1 must not be set as the location constraint
+	server = &fakeS3Server{t: t}
+	a = newAWSForS3Server(t, "us-east-1", server)
+	require.NoError(t, a.CreateBucket("bucket", nil))
+	assert.Equal(t, []string{"PUT /bucket", "HEAD /bucket"}, server.calls)
+	assert.Empty(t, server.createBucketConfig)
+
+	// the tags are set after creating the bucket
+	server = &fakeS3Server{t: t}
+	a = newAWSForS3Server(t, "eu-central-1", server)
+	require.NoError(t, a.CreateBucket("bucket", map[string]string{"run-id": "run", "owner": "ci"}))
+	assert.Equal(t, []string{"PUT /bucket", "HEAD /bucket", "PUT /bucket"}, server.calls)
+	assert.Contains(t, server.bucketTagging, "<Key>run-id</Key>")
+	assert.Contains(t, server.bucketTagging, "<Value>run</Value>")
+	assert.Contains(t, server.bucketTagging, "<Key>owner</Key>")
+}
+
+func TestDeleteBucket(t *testing.T) {
+	server := &fakeS3Server{t: t, objects: []string{"disk.raw", "logs.tar"}}
+	a := newAWSForS3Server(t, "eu-central-1", server)
+	require.NoError(t, a.DeleteBucket("bucket"))
+	assert.Equal(t, []string{"GET /bucket", "DELETE /bucket/disk.raw", "DELETE /bucket/logs.tar", "DELETE /bucket"}, server.calls)
+}
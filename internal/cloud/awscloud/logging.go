@@ -0,0 +1,45 @@
+package awscloud
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/sirupsen/logrus"
+)
+
+// SetLogger sets the logger of the API calls made by the object. Each call is
+// logged with its operation and parameters once it completes: successful
+// calls at the debug level and failed calls as warnings with the AWS request
+// ID, which AWS support needs to look into a failure. The parameters that the
+// SDK marks as sensitive, such as the user data of instances, are redacted.
+// No calls are logged if the logger is nil, which is the default.
+func (a *AWS) SetLogger(logger logrus.FieldLogger) {
+	a.logger = logger
+}
+
+// logRequest is the handler that logs the API calls when they complete.
+func (a *AWS) logRequest(r *request.Request) {
+	if a.logger == nil {
+		return
+	}
+
+	logger := a.logger.WithFields(logrus.Fields{
+		"service":   r.ClientInfo.ServiceName,
+		"operation": r.Operation.Name,
+		// Prettify replaces the fields with a sensitive tag with <sensitive>
+		"params": awsutil.Prettify(r.Params),
+	})
+	if r.Error != nil {
+		// EC2 returns the request ID of failed calls in the error response
+		requestID := r.RequestID
+		var failure awserr.RequestFailure
+		if errors.As(r.Error, &failure) && failure.RequestID() != "" {
+			requestID = failure.RequestID()
+		}
+		logger.WithField("request_id", requestID).WithError(r.Error).Warn("[AWS] API call failed")
+		return
+	}
+	logger.Debug("[AWS] API call")
+}
@@ -0,0 +1,63 @@
+package awscloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newLoggedAWS returns an *AWS object for an EC2 endpoint that fails all the
+// calls and the buffer of its JSON logs.
+func newLoggedAWS(t *testing.T) (*AWS, *bytes.Buffer) {
+	a := newAWSForServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeEC2Error(t, w, http.StatusForbidden, "UnauthorizedOperation")
+	})
+
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetLevel(logrus.DebugLevel)
+	a.SetLogger(logger)
+	return a, &logs
+}
+
+func TestLogFailedCall(t *testing.T) {
+	a, logs := newLoggedAWS(t)
+
+	_, err := a.RunInstanceEC2(aws.String("ami-0123456789"), aws.String("sg-0123456789"), "#cloud-config\npassword: hunter2\n", "t3.small", nil, nil, nil, nil)
+	require.Error(t, err)
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.Len(t, lines, 1)
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "warning", entry["level"])
+	assert.Equal(t, "[AWS] API call failed", entry["msg"])
+	assert.Equal(t, "ec2", entry["service"])
+	assert.Equal(t, "RunInstances", entry["operation"])
+	assert.Equal(t, testRequestID, entry["request_id"])
+	assert.Contains(t, entry["error"], "UnauthorizedOperation")
+	assert.Contains(t, entry["params"], `ImageId: "ami-0123456789"`)
+	assert.Contains(t, entry["params"], "UserData: <sensitive>")
+
+	// neither the user data nor the credentials are logged
+	assert.NotContains(t, logs.String(), encodeBase64("#cloud-config\npassword: hunter2\n"))
+	assert.NotContains(t, logs.String(), "secret-key")
+}
+
+func TestNoLogger(t *testing.T) {
+	a, logs := newLoggedAWS(t)
+	a.SetLogger(nil)
+
+	_, err := a.Regions()
+	require.Error(t, err)
+	assert.Empty(t, logs.String())
+}
@@ -0,0 +1,106 @@
+package awscloud
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"golang.org/x/exp/slices"
+)
+
+// RetryOptions configures the retries of the API calls that fail because of
+// throttling (e.g. RequestLimitExceeded), transient server errors or the
+// eventual consistency of EC2.
+type RetryOptions struct {
+	// MaxAttempts is the maximum number of attempts of a call, including the
+	// first one. Calls are not retried if it is 1 or less.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. It doubles with every
+	// retry, with some jitter, up to maxRetryDelay.
+	BaseDelay time.Duration
+}
+
+// DefaultRetryOptions are the retry options of new *AWS objects.
+var DefaultRetryOptions = RetryOptions{
+	MaxAttempts: 8,
+	BaseDelay:   500 * time.Millisecond,
+}
+
+// maxRetryDelay is the maximum delay between two attempts of a call.
+const maxRetryDelay = 30 * time.Second
+
+// eventualConsistencyErrorCodes are the error codes returned by EC2 for
+// resources that were just created and are not visible to all the API calls
+// yet. They are only retried for the calls made with the
+// retryEventualConsistency option.
+var eventualConsistencyErrorCodes = []string{
+	"InvalidAMIID.NotFound",
+	"InvalidGroup.NotFound",
+	"InvalidInstanceID.NotFound",
+	"InvalidSnapshot.NotFound",
+}
+
+func isEventualConsistencyError(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && slices.Contains(eventualConsistencyErrorCodes, awsErr.Code())
+}
+
+// SetRetryOptions sets the retry options of the API calls made by the object.
+func (a *AWS) SetRetryOptions(options RetryOptions) {
+	a.retryOptions = options
+}
+
+// retryer retries the API calls of an *AWS object with its current retry
+// options.
+type retryer struct {
+	aws *AWS
+	// eventualConsistency also retries the NotFound errors of
+	// eventualConsistencyErrorCodes
+	eventualConsistency bool
+}
+
+// retryEventualConsistency is the request option of the API calls that are
+// made right after creating the resources they use, e.g. tagging a
+// registered image or launching an instance in a new security group. Only
+// these calls retry the NotFound errors of resources that are not visible
+// yet. For the other calls, e.g. the deletion of resources, the error means
+// that the resource is really gone and is returned right away.
+func retryEventualConsistency(r *request.Request) {
+	if rt, ok := r.Retryer.(retryer); ok {
+		rt.eventualConsistency = true
+		r.Retryer = rt
+	}
+}
+
+func (r retryer) defaultRetryer() client.DefaultRetryer {
+	options := r.aws.retryOptions
+	retries := 0
+	if options.MaxAttempts > 1 {
+		retries = options.MaxAttempts - 1
+	}
+	return client.DefaultRetryer{
+		NumMaxRetries:    retries,
+		MinRetryDelay:    options.BaseDelay,
+		MinThrottleDelay: options.BaseDelay,
+		MaxRetryDelay:    maxRetryDelay,
+		MaxThrottleDelay: maxRetryDelay,
+	}
+}
+
+func (r retryer) MaxRetries() int {
+	return r.defaultRetryer().MaxRetries()
+}
+
+func (r retryer) RetryRules(req *request.Request) time.Duration {
+	return r.defaultRetryer().RetryRules(req)
+}
+
+func (r retryer) ShouldRetry(req *request.Request) bool {
+	if r.eventualConsistency && r.MaxRetries() > 0 && isEventualConsistencyError(req.Error) {
+		return true
+	}
+	return r.defaultRetryer().ShouldRetry(req)
+}
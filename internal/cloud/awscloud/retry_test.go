@@ -0,0 +1,80 @@
+package awscloud
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFlakyAWS returns an *AWS object for an EC2 endpoint that fails the
+// given number of calls with the error code before succeeding, and the
+// number of calls made.
+func newFlakyAWS(t *testing.T, failures int, status int, code string) (*AWS, *int) {
+	calls := 0
+	a := newAWSForServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= failures {
+			writeEC2Error(t, w, status, code)
+			return
+		}
+		_, err := w.Write([]byte(`<DescribeRegionsResponse><regionInfo><item><regionName>us-east-1</regionName></item></regionInfo></DescribeRegionsResponse>`))
+		require.NoError(t, err)
+	})
+	a.SetRetryOptions(RetryOptions{MaxAttempts: 4, BaseDelay: time.Millisecond})
+	return a, &calls
+}
+
+func TestRetryThrottling(t *testing.T) {
+	a, calls := newFlakyAWS(t, 3, http.StatusServiceUnavailable, "RequestLimitExceeded")
+	regions, err := a.Regions()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"us-east-1"}, regions)
+	assert.Equal(t, 4, *calls)
+}
+
+func TestRetryThrottlingMaxAttempts(t *testing.T) {
+	a, calls := newFlakyAWS(t, 4, http.StatusServiceUnavailable, "RequestLimitExceeded")
+	_, err := a.Regions()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "RequestLimitExceeded")
+	assert.Equal(t, 4, *calls)
+}
+
+func TestRetryEventualConsistency(t *testing.T) {
+	a, calls := newFlakyAWS(t, 2, http.StatusBadRequest, "InvalidGroup.NotFound")
+	_, err := a.AuthorizeSecurityGroupIngressEC2(aws.String("sg-0123456789"), "0.0.0.0/0", 22, 22, "tcp")
+	require.NoError(t, err)
+	assert.Equal(t, 3, *calls)
+}
+
+func TestNoRetryNotFoundOnDelete(t *testing.T) {
+	a, calls := newFlakyAWS(t, 2, http.StatusBadRequest, "InvalidGroup.NotFound")
+	_, err := a.DeleteSecurityGroupEC2(aws.String("sg-0123456789"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "InvalidGroup.NotFound")
+	assert.Equal(t, 1, *calls)
+
+	a, calls = newFlakyAWS(t, 2, http.StatusBadRequest, "InvalidAMIID.NotFound")
+	err = a.DeleteEC2Image(aws.String("ami-0123456789"), aws.String("snap-0123456789"))
+	require.Error(t, err)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestNoRetryClientErrors(t *testing.T) {
+	a, calls := newFlakyAWS(t, 1, http.StatusForbidden, "UnauthorizedOperation")
+	_, err := a.Regions()
+	require.Error(t, err)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestNoRetries(t *testing.T) {
+	a, calls := newFlakyAWS(t, 1, http.StatusServiceUnavailable, "RequestLimitExceeded")
+	a.SetRetryOptions(RetryOptions{MaxAttempts: 1})
+	_, err := a.Regions()
+	require.Error(t, err)
+	assert.Equal(t, 1, *calls)
+}
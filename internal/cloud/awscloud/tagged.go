@@ -0,0 +1,250 @@
+package awscloud
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// TaggedResources holds the EC2 resources and the S3 buckets that carry a
+// specific tag key.
+type TaggedResources struct {
+	Instances      []*ec2.Instance
+	SecurityGroups []*ec2.SecurityGroup
+	Images         []*ec2.Image
+	Snapshots      []*ec2.Snapshot
+	Buckets        []*TaggedBucket
+}
+
+// TaggedBucket is an S3 bucket with its tags, which are not listed with the
+// bucket. The tags are converted to EC2 tags, like those of the other
+// resources.
+type TaggedBucket struct {
+	Name         *string
+	CreationDate *time.Time
+	Tags         []*ec2.Tag
+}
+
+// Empty returns true if there are no resources.
+func (r *TaggedResources) Empty() bool {
+	return len(r.Instances) == 0 && len(r.SecurityGroups) == 0 && len(r.Images) == 0 && len(r.Snapshots) == 0 && len(r.Buckets) == 0
+}
+
+// DescribeResourcesByTagKey returns the instances, security groups, images,
+// snapshots and S3 buckets owned by the account that carry a tag with the
+// given key, regardless of the tag's value. Instances that are already
+// terminated or shutting down and buckets in other regions are ignored.
+func (a *AWS) DescribeResourcesByTagKey(tagKey string) (*TaggedResources, error) {
+	filter := &ec2.Filter{
+		Name:   aws.String("tag-key"),
+		Values: []*string{aws.String(tagKey)},
+	}
+
+	res := &TaggedResources{}
+
+	err := a.ec2.DescribeInstancesPages(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			filter,
+			{
+				Name: aws.String("instance-state-name"),
+				Values: aws.StringSlice([]string{
+					ec2.InstanceStateNamePending,
+					ec2.InstanceStateNameRunning,
+					ec2.InstanceStateNameStopping,
+					ec2.InstanceStateNameStopped,
+				}),
+			},
+		},
+	}, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range page.Reservations {
+			res.Instances = append(res.Instances, reservation.Instances...)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe instances: %w", err)
+	}
+
+	err = a.ec2.DescribeSecurityGroupsPages(&ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{filter},
+	}, func(page *ec2.DescribeSecurityGroupsOutput, lastPage bool) bool {
+		res.SecurityGroups = append(res.SecurityGroups, page.SecurityGroups...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe security groups: %w", err)
+	}
+
+	err = a.ec2.DescribeImagesPages(&ec2.DescribeImagesInput{
+		Owners:  []*string{aws.String("self")},
+		Filters: []*ec2.Filter{filter},
+	}, func(page *ec2.DescribeImagesOutput, lastPage bool) bool {
+		res.Images = append(res.Images, page.Images...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe images: %w", err)
+	}
+
+	err = a.ec2.DescribeSnapshotsPages(&ec2.DescribeSnapshotsInput{
+		OwnerIds: []*string{aws.String("self")},
+		Filters:  []*ec2.Filter{filter},
+	}, func(page *ec2.DescribeSnapshotsOutput, lastPage bool) bool {
+		res.Snapshots = append(res.Snapshots, page.Snapshots...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe snapshots: %w", err)
+	}
+
+	res.Buckets, err = a.describeBucketsByTagKey(tagKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe buckets: %w", err)
+	}
+
+	return res, nil
+}
+
+// describeBucketsByTagKey returns the S3 buckets in the region of the
+// session that carry a tag with the given key. S3 has no filter for tags, so
+// the tags of every bucket of the account in the region are read.
+func (a *AWS) describeBucketsByTagKey(tagKey string) ([]*TaggedBucket, error) {
+	buckets, err := a.s3.ListBuckets(&s3.ListBucketsInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	region := aws.StringValue(a.s3.Config.Region)
+	var tagged []*TaggedBucket
+	for _, bucket := range buckets.Buckets {
+		location, err := a.s3.GetBucketLocation(&s3.GetBucketLocationInput{Bucket: bucket.Name})
+		if err != nil {
+			return nil, err
+		}
+		// buckets in us-east-1 have no location constraint
+		bucketRegion := aws.StringValue(location.LocationConstraint)
+		if bucketRegion == "" {
+			bucketRegion = "us-east-1"
+		}
+		if bucketRegion != region {
+			continue
+		}
+
+		tagging, err := a.s3.GetBucketTagging(&s3.GetBucketTaggingInput{Bucket: bucket.Name})
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == "NoSuchTagSet" {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var tags []*ec2.Tag
+		for _, tag := range tagging.TagSet {
+			tags = append(tags, &ec2.Tag{Key: tag.Key, Value: tag.Value})
+		}
+		if _, ok := tagValue(tags, tagKey); ok {
+			tagged = append(tagged, &TaggedBucket{Name: bucket.Name, CreationDate: bucket.CreationDate, Tags: tags})
+		}
+	}
+	return tagged, nil
+}
+
+// OlderThan returns the subset of the resources that were created before the
+// cutoff time.
+//
+// Security groups have no creation time, so they are considered as old as the
+// oldest instance, image, snapshot or bucket that carries the same value for
+// tagKey.
+// A security group that shares its tag value with no other resource has
+// outlived the rest of its run and is always included.
+func (r *TaggedResources) OlderThan(tagKey string, cutoff time.Time) *TaggedResources {
+	old := &TaggedResources{}
+
+	// the earliest creation time seen for each tag value
+	created := make(map[string]time.Time)
+	seen := func(tags []*ec2.Tag, t time.Time) {
+		value, ok := tagValue(tags, tagKey)
+		if !ok {
+			return
+		}
+		if prev, ok := created[value]; !ok || t.Before(prev) {
+			created[value] = t
+		}
+	}
+
+	for _, instance := range r.Instances {
+		launched := aws.TimeValue(instance.LaunchTime)
+		seen(instance.Tags, launched)
+		if launched.Before(cutoff) {
+			old.Instances = append(old.Instances, instance)
+		}
+	}
+
+	for _, image := range r.Images {
+		creationDate, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate))
+		if err != nil {
+			// never select a resource with an unknown age
+			continue
+		}
+		seen(image.Tags, creationDate)
+		if creationDate.Before(cutoff) {
+			old.Images = append(old.Images, image)
+		}
+	}
+
+	for _, snapshot := range r.Snapshots {
+		started := aws.TimeValue(snapshot.StartTime)
+		seen(snapshot.Tags, started)
+		if started.Before(cutoff) {
+			old.Snapshots = append(old.Snapshots, snapshot)
+		}
+	}
+
+	for _, bucket := range r.Buckets {
+		creationDate := aws.TimeValue(bucket.CreationDate)
+		seen(bucket.Tags, creationDate)
+		if creationDate.Before(cutoff) {
+			old.Buckets = append(old.Buckets, bucket)
+		}
+	}
+
+	for _, group := range r.SecurityGroups {
+		value, ok := tagValue(group.Tags, tagKey)
+		if !ok {
+			continue
+		}
+		if t, ok := created[value]; !ok || t.Before(cutoff) {
+			old.SecurityGroups = append(old.SecurityGroups, group)
+		}
+	}
+
+	return old
+}
+
+// DeregisterImageEC2 deregisters the image without deleting its snapshots.
+func (a *AWS) DeregisterImageEC2(imageID *string) (*ec2.DeregisterImageOutput, error) {
+	return a.ec2.DeregisterImage(&ec2.DeregisterImageInput{
+		ImageId: imageID,
+	})
+}
+
+func (a *AWS) DeleteSnapshotEC2(snapshotID *string) (*ec2.DeleteSnapshotOutput, error) {
+	return a.ec2.DeleteSnapshot(&ec2.DeleteSnapshotInput{
+		SnapshotId: snapshotID,
+	})
+}
+
+// tagValue returns the value of the tag with the given key.
+func tagValue(tags []*ec2.Tag, key string) (string, bool) {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key {
+			return aws.StringValue(tag.Value), true
+		}
+	}
+	return "", false
+}
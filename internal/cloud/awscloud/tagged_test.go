@@ -0,0 +1,174 @@
+package awscloud
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTaggedEC2 serves a fixed set of resources and applies the tag-key
+// filters of the describe calls the same way EC2 does. Each matching resource
+// is served in its own page.
+type fakeTaggedEC2 struct {
+	ec2iface.EC2API
+
+	instances      []*ec2.Instance
+	securityGroups []*ec2.SecurityGroup
+	images         []*ec2.Image
+	snapshots      []*ec2.Snapshot
+}
+
+func matchesTagKeyFilter(filters []*ec2.Filter, tags []*ec2.Tag) bool {
+	for _, filter := range filters {
+		if aws.StringValue(filter.Name) != "tag-key" {
+			continue
+		}
+		for _, key := range filter.Values {
+			if _, ok := tagValue(tags, aws.StringValue(key)); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (f *fakeTaggedEC2) DescribeInstancesPages(input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	var pages []*ec2.DescribeInstancesOutput
+	for _, instance := range f.instances {
+		if matchesTagKeyFilter(input.Filters, instance.Tags) {
+			pages = append(pages, &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{instance}}}})
+		}
+	}
+	for i, page := range pages {
+		if !fn(page, i == len(pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeTaggedEC2) DescribeSecurityGroupsPages(input *ec2.DescribeSecurityGroupsInput, fn func(*ec2.DescribeSecurityGroupsOutput, bool) bool) error {
+	var pages []*ec2.DescribeSecurityGroupsOutput
+	for _, group := range f.securityGroups {
+		if matchesTagKeyFilter(input.Filters, group.Tags) {
+			pages = append(pages, &ec2.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2.SecurityGroup{group}})
+		}
+	}
+	for i, page := range pages {
+		if !fn(page, i == len(pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeTaggedEC2) DescribeImagesPages(input *ec2.DescribeImagesInput, fn func(*ec2.DescribeImagesOutput, bool) bool) error {
+	var pages []*ec2.DescribeImagesOutput
+	for _, image := range f.images {
+		if matchesTagKeyFilter(input.Filters, image.Tags) {
+			pages = append(pages, &ec2.DescribeImagesOutput{Images: []*ec2.Image{image}})
+		}
+	}
+	for i, page := range pages {
+		if !fn(page, i == len(pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeTaggedEC2) DescribeSnapshotsPages(input *ec2.DescribeSnapshotsInput, fn func(*ec2.DescribeSnapshotsOutput, bool) bool) error {
+	var pages []*ec2.DescribeSnapshotsOutput
+	for _, snapshot := range f.snapshots {
+		if matchesTagKeyFilter(input.Filters, snapshot.Tags) {
+			pages = append(pages, &ec2.DescribeSnapshotsOutput{Snapshots: []*ec2.Snapshot{snapshot}})
+		}
+	}
+	for i, page := range pages {
+		if !fn(page, i == len(pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func runTags(runID string) []*ec2.Tag {
+	return []*ec2.Tag{{Key: aws.String("run-id"), Value: aws.String(runID)}}
+}
+
+func TestDescribeResourcesByTagKeyOlderThan(t *testing.T) {
+	now := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-5 * time.Hour)
+	recent := now.Add(-10 * time.Minute)
+
+	fake := &fakeTaggedEC2{
+		instances: []*ec2.Instance{
+			{InstanceId: aws.String("i-old"), LaunchTime: aws.Time(old), Tags: runTags("old")},
+			{InstanceId: aws.String("i-recent"), LaunchTime: aws.Time(recent), Tags: runTags("recent")},
+			{InstanceId: aws.String("i-untagged"), LaunchTime: aws.Time(old)},
+		},
+		securityGroups: []*ec2.SecurityGroup{
+			{GroupId: aws.String("sg-old"), Tags: runTags("old")},
+			{GroupId: aws.String("sg-recent"), Tags: runTags("recent")},
+			{GroupId: aws.String("sg-lonely"), Tags: runTags("lonely")},
+			{GroupId: aws.String("sg-untagged")},
+		},
+		images: []*ec2.Image{
+			{ImageId: aws.String("ami-old"), CreationDate: aws.String(old.Format(time.RFC3339)), Tags: runTags("old")},
+			{ImageId: aws.String("ami-recent"), CreationDate: aws.String(recent.Format(time.RFC3339)), Tags: runTags("recent")},
+			{ImageId: aws.String("ami-baddate"), CreationDate: aws.String("yesterday"), Tags: runTags("old")},
+			{ImageId: aws.String("ami-untagged"), CreationDate: aws.String(old.Format(time.RFC3339))},
+		},
+		snapshots: []*ec2.Snapshot{
+			{SnapshotId: aws.String("snap-old"), StartTime: aws.Time(old), Tags: runTags("old")},
+			{SnapshotId: aws.String("snap-recent"), StartTime: aws.Time(recent), Tags: runTags("recent")},
+			{SnapshotId: aws.String("snap-untagged"), StartTime: aws.Time(old)},
+		},
+	}
+	a := newAWSForS3Server(t, "eu-central-1", &fakeS3Server{t: t, buckets: []fakeBucket{
+		{name: "bucket-old", created: old, tags: map[string]string{"run-id": "old"}},
+		{name: "bucket-recent", created: recent, tags: map[string]string{"run-id": "recent"}},
+		{name: "bucket-untagged", created: old},
+		{name: "bucket-other-tag", created: old, tags: map[string]string{"owner": "ci"}},
+		{name: "bucket-other-region", region: "us-east-1", created: old, tags: map[string]string{"run-id": "old"}},
+	}})
+	a.ec2 = fake
+
+	tagged, err := a.DescribeResourcesByTagKey("run-id")
+	require.NoError(t, err)
+	assert.Len(t, tagged.Instances, 2)
+	assert.Len(t, tagged.SecurityGroups, 3)
+	assert.Len(t, tagged.Images, 3)
+	assert.Len(t, tagged.Snapshots, 2)
+	assert.Len(t, tagged.Buckets, 2)
+
+	selected := tagged.OlderThan("run-id", now.Add(-time.Hour))
+
+	var ids []string
+	for _, instance := range selected.Instances {
+		ids = append(ids, aws.StringValue(instance.InstanceId))
+	}
+	for _, group := range selected.SecurityGroups {
+		ids = append(ids, aws.StringValue(group.GroupId))
+	}
+	for _, image := range selected.Images {
+		ids = append(ids, aws.StringValue(image.ImageId))
+	}
+	for _, snapshot := range selected.Snapshots {
+		ids = append(ids, aws.StringValue(snapshot.SnapshotId))
+	}
+	for _, bucket := range selected.Buckets {
+		ids = append(ids, aws.StringValue(bucket.Name))
+	}
+	assert.Equal(t, []string{"i-old", "sg-old", "sg-lonely", "ami-old", "snap-old", "bucket-old"}, ids)
+}
+
+func TestOlderThanEmpty(t *testing.T) {
+	tagged := &TaggedResources{}
+	assert.True(t, tagged.OlderThan("run-id", time.Now()).Empty())
+}
@@ -0,0 +1,311 @@
+package bootprovider
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/osbuild/images/internal/cloud/awscloud"
+)
+
+// AWSOptions configures how the AWS provider uploads, registers, and boots
+// images.
+type AWSOptions struct {
+	// Bucket and Key are the location in S3 where the image is uploaded.
+	Bucket string
+	Key    string
+
+	// CreateBucket creates the bucket before uploading the image. It is
+	// tagged like the other resources and deleted, with all its content, on
+	// teardown.
+	CreateBucket bool
+
+	// ImageName is the name of the registered AMI.
+	ImageName string
+
+	// Arch is the rpm architecture of the image (x86_64 or aarch64).
+	Arch string
+
+	// BootMode of the AMI. The default boot mode of the instance type is
+	// used if nil.
+	BootMode *string
+
+	// ImageAvailableTimeout is how long to wait for the registered AMI to
+	// become available before giving up. DefaultImageAvailableTimeout is used
+	// if zero.
+	ImageAvailableTimeout time.Duration
+
+	// Tags are applied to all created resources, together with the run ID.
+	Tags map[string]string
+
+	// Spot requests a spot instance instead of an on-demand one if not nil.
+	Spot *awscloud.SpotOptions
+
+	// RootVolume replaces the root volume of the image if not nil.
+	RootVolume *awscloud.RootVolumeOptions
+
+	// SubnetID is the subnet the instance is launched into, the default
+	// subnet of the default VPC if empty. The subnet must assign public IP
+	// addresses for the instance to be reachable. The security group is
+	// created in the VPC of the subnet.
+	SubnetID string
+
+	// VPCID is the VPC of SubnetID, which is required if it is set. It is
+	// checked against the VPC that the subnet belongs to.
+	VPCID string
+
+	// SSHCIDR is the range of IPv4 addresses that can connect to the instance
+	// with SSH, e.g. 0.0.0.0/0 for all of them. If empty, only the public IP
+	// address of the caller, as seen by publicIPURL, can connect.
+	SSHCIDR string
+}
+
+// publicIPURL is the URL of the service that returns the caller's public IP
+// address.
+var publicIPURL = "https://checkip.amazonaws.com"
+
+// callerCIDR returns the CIDR of the single public IPv4 address of the
+// caller.
+func callerCIDR() (string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(publicIPURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the public IP address: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to look up the public IP address: %s returned %s", publicIPURL, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the public IP address: %w", err)
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSpace(string(body)))
+	if err != nil || !addr.Is4() {
+		return "", fmt.Errorf("failed to look up the public IP address: %s returned %q instead of an IPv4 address", publicIPURL, strings.TrimSpace(string(body)))
+	}
+	return netip.PrefixFrom(addr, 32).String(), nil
+}
+
+// sshCIDR returns the validated SSHCIDR or the CIDR of the caller.
+func (p *awsProvider) sshCIDR() (string, error) {
+	if p.options.SSHCIDR == "" {
+		return callerCIDR()
+	}
+	prefix, err := netip.ParsePrefix(p.options.SSHCIDR)
+	if err != nil {
+		return "", fmt.Errorf("invalid SSH CIDR: %w", err)
+	}
+	if !prefix.Addr().Is4() {
+		return "", fmt.Errorf("invalid SSH CIDR %q: only IPv4 is supported", p.options.SSHCIDR)
+	}
+	return p.options.SSHCIDR, nil
+}
+
+// DefaultImageAvailableTimeout is the default of
+// AWSOptions.ImageAvailableTimeout.
+const DefaultImageAvailableTimeout = 10 * time.Minute
+
+type awsProvider struct {
+	client  *awscloud.AWS
+	options AWSOptions
+}
+
+// NewAWS returns a Provider that boots images on EC2 using the client.
+func NewAWS(client *awscloud.AWS, options AWSOptions) Provider {
+	return &awsProvider{
+		client:  client,
+		options: options,
+	}
+}
+
+func (p *awsProvider) Name() string {
+	return "aws"
+}
+
+// resources returns the AWS section of res, creating it if necessary.
+func (p *awsProvider) resources(res *Resources) *AWSResources {
+	res.Provider = p.Name()
+	if res.AWS == nil {
+		res.AWS = &AWSResources{}
+	}
+	return res.AWS
+}
+
+// tags returns the configured tags together with the run ID tag.
+func (p *awsProvider) tags(res *Resources) map[string]string {
+	tags := make(map[string]string, len(p.options.Tags)+1)
+	for k, v := range p.options.Tags {
+		tags[k] = v
+	}
+	if res.RunID != "" {
+		tags[RunIDTagKey] = res.RunID
+	}
+	return tags
+}
+
+func (p *awsProvider) Upload(filename string, res *Resources) error {
+	awsRes := p.resources(res)
+	if p.options.CreateBucket {
+		if err := p.client.CreateBucket(p.options.Bucket, p.tags(res)); err != nil {
+			return fmt.Errorf("CreateBucket(): %s", err.Error())
+		}
+		awsRes.Bucket = aws.String(p.options.Bucket)
+	}
+
+	uploadOutput, err := p.client.Upload(filename, p.options.Bucket, p.options.Key)
+	if err != nil {
+		return fmt.Errorf("Upload() failed: %s", err.Error())
+	}
+
+	fmt.Printf("file uploaded to %s\n", aws.StringValue(&uploadOutput.Location))
+	return nil
+}
+
+func (p *awsProvider) Register(res *Resources) error {
+	awsRes := p.resources(res)
+	ami, snapshot, err := p.client.Register(p.options.ImageName, p.options.Bucket, p.options.Key, nil, p.options.Arch, p.options.BootMode, p.tags(res))
+	if err != nil {
+		return fmt.Errorf("Register(): %s", err.Error())
+	}
+
+	awsRes.AMI = ami
+	awsRes.Snapshot = snapshot
+
+	fmt.Printf("AMI registered: %s\n", aws.StringValue(ami))
+
+	// instances can only be launched from the AMI once it's available
+	timeout := p.options.ImageAvailableTimeout
+	if timeout == 0 {
+		timeout = DefaultImageAvailableTimeout
+	}
+	if err := p.client.WaitForImageAvailable(ami, timeout); err != nil {
+		return fmt.Errorf("WaitForImageAvailable(): %s", err.Error())
+	}
+	return nil
+}
+
+func getInstanceType(arch string) (string, error) {
+	switch arch {
+	case "x86_64":
+		return "t3.small", nil
+	case "aarch64":
+		return "t4g.medium", nil
+	default:
+		return "", fmt.Errorf("getInstanceType(): unknown architecture %q", arch)
+	}
+}
+
+func (p *awsProvider) Boot(userData string, res *Resources) error {
+	awsRes := p.resources(res)
+	if awsRes.AMI == nil {
+		return fmt.Errorf("cannot boot instance: no AMI registered")
+	}
+
+	tags := p.tags(res)
+
+	subnetID, vpcID, err := p.network()
+	if err != nil {
+		return err
+	}
+
+	sshCIDR, err := p.sshCIDR()
+	if err != nil {
+		return err
+	}
+
+	securityGroupName := fmt.Sprintf("image-boot-tests-%s", res.RunID)
+	securityGroup, err := p.client.CreateSecurityGroupEC2(securityGroupName, "image-tests-security-group", tags, vpcID)
+	if err != nil {
+		return fmt.Errorf("CreateSecurityGroup(): %s", err.Error())
+	}
+
+	awsRes.SecurityGroup = securityGroup.GroupId
+
+	_, err = p.client.AuthorizeSecurityGroupIngressEC2(securityGroup.GroupId, sshCIDR, 22, 22, "tcp")
+	if err != nil {
+		return fmt.Errorf("AuthorizeSecurityGroupIngressEC2(): %s", err.Error())
+	}
+
+	instance, err := getInstanceType(p.options.Arch)
+	if err != nil {
+		return err
+	}
+
+	runResult, err := p.client.RunInstanceEC2(awsRes.AMI, securityGroup.GroupId, userData, instance, tags, p.options.Spot, p.options.RootVolume, subnetID)
+	if err != nil {
+		return fmt.Errorf("RunInstanceEC2(): %s", err.Error())
+	}
+	awsRes.InstanceID = runResult.Instances[0].InstanceId
+	return nil
+}
+
+// network returns the subnet and the VPC to launch the instance into, nil
+// for the default ones, after checking that the subnet belongs to the VPC.
+func (p *awsProvider) network() (*string, *string, error) {
+	if p.options.SubnetID == "" {
+		if p.options.VPCID != "" {
+			return nil, nil, fmt.Errorf("a subnet is required to launch the instance into VPC %s", p.options.VPCID)
+		}
+		return nil, nil, nil
+	}
+
+	subnetID := aws.String(p.options.SubnetID)
+	vpcID, err := p.client.SubnetVPC(subnetID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("SubnetVPC(): %s", err.Error())
+	}
+	if p.options.VPCID != "" && p.options.VPCID != aws.StringValue(vpcID) {
+		return nil, nil, fmt.Errorf("subnet %s belongs to VPC %s, not to VPC %s", p.options.SubnetID, aws.StringValue(vpcID), p.options.VPCID)
+	}
+	return subnetID, vpcID, nil
+}
+
+func (p *awsProvider) Address(res *Resources) (string, error) {
+	if res.AWS == nil || res.AWS.InstanceID == nil {
+		return "", fmt.Errorf("no instance to get the address of")
+	}
+	return p.client.GetInstanceAddress(res.AWS.InstanceID)
+}
+
+func (p *awsProvider) Teardown(res *Resources) error {
+	if res.AWS == nil {
+		return nil
+	}
+	awsRes := res.AWS
+
+	if awsRes.InstanceID != nil {
+		fmt.Printf("terminating instance %s\n", *awsRes.InstanceID)
+		if _, err := p.client.TerminateInstanceEC2(awsRes.InstanceID); err != nil {
+			return fmt.Errorf("failed to terminate instance: %v", err)
+		}
+	}
+
+	if awsRes.SecurityGroup != nil {
+		fmt.Printf("deleting security group %s\n", *awsRes.SecurityGroup)
+		if _, err := p.client.DeleteSecurityGroupEC2(awsRes.SecurityGroup); err != nil {
+			return fmt.Errorf("cannot delete the security group: %v", err)
+		}
+	}
+
+	if awsRes.AMI != nil {
+		fmt.Printf("deleting EC2 image %s and snapshot %s\n", *awsRes.AMI, *awsRes.Snapshot)
+		if err := p.client.DeleteEC2Image(awsRes.AMI, awsRes.Snapshot); err != nil {
+			return fmt.Errorf("failed to deregister image: %v", err)
+		}
+	}
+
+	if awsRes.Bucket != nil {
+		fmt.Printf("deleting S3 bucket %s\n", *awsRes.Bucket)
+		if err := p.client.DeleteBucket(*awsRes.Bucket); err != nil {
+			return fmt.Errorf("failed to delete the S3 bucket: %v", err)
+		}
+	}
+	return nil
+}
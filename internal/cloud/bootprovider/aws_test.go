@@ -0,0 +1,198 @@
+package bootprovider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/cloud/awscloud"
+)
+
+// newS3Provider returns an AWS provider for an S3 endpoint with empty
+// buckets that records the calls made to it.
+func newS3Provider(t *testing.T, options AWSOptions) (Provider, *[]string) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodGet:
+			_, err := w.Write([]byte(`<ListBucketResult><IsTruncated>false</IsTruncated></ListBucketResult>`))
+			require.NoError(t, err)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := awscloud.NewForEndpoint(server.URL, "eu-central-1", "key-id", "secret-key", "", "", false)
+	require.NoError(t, err)
+	return NewAWS(client, options), &calls
+}
+
+// newEC2Provider returns an AWS provider for an EC2 endpoint with a single
+// subnet, subnet-0123456789 in vpc-0123456789, that records the parameters
+// of the calls made to it by action.
+func newEC2Provider(t *testing.T, options AWSOptions) (Provider, map[string]url.Values) {
+	calls := make(map[string]url.Values)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		action := r.Form.Get("Action")
+		calls[action] = r.Form
+
+		var response string
+		switch action {
+		case "DescribeSubnets":
+			response = `<subnetSet><item><subnetId>subnet-0123456789</subnetId><vpcId>vpc-0123456789</vpcId></item></subnetSet>`
+		case "CreateSecurityGroup":
+			response = `<groupId>sg-0123456789</groupId>`
+		case "AuthorizeSecurityGroupIngress":
+			response = `<return>true</return>`
+		case "RunInstances", "DescribeInstances":
+			instance := `<item><instanceId>i-0123456789</instanceId><instanceState><name>running</name></instanceState></item>`
+			if action == "RunInstances" {
+				response = `<instancesSet>` + instance + `</instancesSet>`
+			} else {
+				response = `<reservationSet><item><instancesSet>` + instance + `</instancesSet></item></reservationSet>`
+			}
+		default:
+			t.Errorf("unexpected action %s", action)
+		}
+		_, err := fmt.Fprintf(w, "<%sResponse>%s</%sResponse>", action, response, action)
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := awscloud.NewForEndpoint(server.URL, "eu-central-1", "key-id", "secret-key", "", "", false)
+	require.NoError(t, err)
+	return NewAWS(client, options), calls
+}
+
+// usePublicIP makes the public IP address lookup return the given response
+// for the duration of the test.
+func usePublicIP(t *testing.T, response string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(response))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	orig := publicIPURL
+	publicIPURL = server.URL
+	t.Cleanup(func() { publicIPURL = orig })
+}
+
+func newImageFile(t *testing.T) string {
+	filename := filepath.Join(t.TempDir(), "disk.raw")
+	require.NoError(t, os.WriteFile(filename, []byte("image"), 0600))
+	return filename
+}
+
+func TestAWSCreateBucket(t *testing.T) {
+	p, calls := newS3Provider(t, AWSOptions{Bucket: "bucket", Key: "disk.raw", CreateBucket: true})
+
+	res := &Resources{}
+	require.NoError(t, p.Upload(newImageFile(t), res))
+	assert.Equal(t, []string{"PUT /bucket", "HEAD /bucket", "PUT /bucket/disk.raw"}, *calls)
+	require.NotNil(t, res.AWS)
+	assert.Equal(t, "bucket", aws.StringValue(res.AWS.Bucket))
+
+	*calls = nil
+	require.NoError(t, p.Teardown(res))
+	assert.Equal(t, []string{"GET /bucket", "DELETE /bucket"}, *calls)
+}
+
+func TestAWSCreateBucketRunID(t *testing.T) {
+	p, calls := newS3Provider(t, AWSOptions{Bucket: "bucket", Key: "disk.raw", CreateBucket: true})
+
+	// the bucket is tagged with the run ID, so that reap finds it
+	res := &Resources{RunID: "run"}
+	require.NoError(t, p.Upload(newImageFile(t), res))
+	assert.Equal(t, []string{"PUT /bucket", "HEAD /bucket", "PUT /bucket", "PUT /bucket/disk.raw"}, *calls)
+}
+
+func TestAWSExistingBucket(t *testing.T) {
+	p, calls := newS3Provider(t, AWSOptions{Bucket: "bucket", Key: "disk.raw"})
+
+	res := &Resources{}
+	require.NoError(t, p.Upload(newImageFile(t), res))
+	assert.Equal(t, []string{"PUT /bucket/disk.raw"}, *calls)
+	assert.Nil(t, res.AWS.Bucket)
+
+	*calls = nil
+	require.NoError(t, p.Teardown(res))
+	assert.Empty(t, *calls)
+}
+
+func TestAWSBootSubnet(t *testing.T) {
+	usePublicIP(t, "198.51.100.7\n")
+	for _, vpcID := range []string{"", "vpc-0123456789"} {
+		p, calls := newEC2Provider(t, AWSOptions{Arch: "x86_64", SubnetID: "subnet-0123456789", VPCID: vpcID})
+
+		res := &Resources{RunID: "1234", AWS: &AWSResources{AMI: aws.String("ami-0123456789")}}
+		require.NoError(t, p.Boot("", res))
+		assert.Equal(t, "sg-0123456789", aws.StringValue(res.AWS.SecurityGroup))
+		assert.Equal(t, "i-0123456789", aws.StringValue(res.AWS.InstanceID))
+
+		assert.Equal(t, "subnet-0123456789", calls["DescribeSubnets"].Get("SubnetId.1"))
+		assert.Equal(t, "vpc-0123456789", calls["CreateSecurityGroup"].Get("VpcId"))
+		assert.Equal(t, "subnet-0123456789", calls["RunInstances"].Get("SubnetId"))
+		assert.Equal(t, "sg-0123456789", calls["RunInstances"].Get("SecurityGroupId.1"))
+	}
+}
+
+func TestAWSBootDefaultNetwork(t *testing.T) {
+	usePublicIP(t, "198.51.100.7\n")
+	p, calls := newEC2Provider(t, AWSOptions{Arch: "x86_64"})
+
+	res := &Resources{RunID: "1234", AWS: &AWSResources{AMI: aws.String("ami-0123456789")}}
+	require.NoError(t, p.Boot("", res))
+	assert.NotContains(t, calls, "DescribeSubnets")
+	assert.NotContains(t, calls["CreateSecurityGroup"], "VpcId")
+	assert.NotContains(t, calls["RunInstances"], "SubnetId")
+}
+
+func TestAWSBootNetworkErrors(t *testing.T) {
+	usePublicIP(t, "198.51.100.7\n")
+	p, calls := newEC2Provider(t, AWSOptions{Arch: "x86_64", SubnetID: "subnet-0123456789", VPCID: "vpc-9876543210"})
+	res := &Resources{RunID: "1234", AWS: &AWSResources{AMI: aws.String("ami-0123456789")}}
+	assert.EqualError(t, p.Boot("", res), "subnet subnet-0123456789 belongs to VPC vpc-0123456789, not to VPC vpc-9876543210")
+	assert.NotContains(t, calls, "CreateSecurityGroup")
+
+	p, calls = newEC2Provider(t, AWSOptions{Arch: "x86_64", VPCID: "vpc-0123456789"})
+	assert.EqualError(t, p.Boot("", res), "a subnet is required to launch the instance into VPC vpc-0123456789")
+	assert.Empty(t, calls)
+	assert.Nil(t, res.AWS.SecurityGroup)
+}
+
+func TestAWSBootSSHCIDR(t *testing.T) {
+	usePublicIP(t, "198.51.100.7\n")
+	res := &Resources{RunID: "1234", AWS: &AWSResources{AMI: aws.String("ami-0123456789")}}
+
+	p, calls := newEC2Provider(t, AWSOptions{Arch: "x86_64"})
+	require.NoError(t, p.Boot("", res))
+	assert.Equal(t, "198.51.100.7/32", calls["AuthorizeSecurityGroupIngress"].Get("CidrIp"))
+
+	p, calls = newEC2Provider(t, AWSOptions{Arch: "x86_64", SSHCIDR: "0.0.0.0/0"})
+	require.NoError(t, p.Boot("", res))
+	assert.Equal(t, "0.0.0.0/0", calls["AuthorizeSecurityGroupIngress"].Get("CidrIp"))
+
+	p, _ = newEC2Provider(t, AWSOptions{Arch: "x86_64", SSHCIDR: "0.0.0.0"})
+	assert.ErrorContains(t, p.Boot("", res), "invalid SSH CIDR")
+
+	p, _ = newEC2Provider(t, AWSOptions{Arch: "x86_64", SSHCIDR: "2001:db8::/32"})
+	assert.EqualError(t, p.Boot("", res), `invalid SSH CIDR "2001:db8::/32": only IPv4 is supported`)
+}
+
+func TestCallerCIDRInvalidResponse(t *testing.T) {
+	usePublicIP(t, "<html>rate limited</html>")
+	_, err := callerCIDR()
+	assert.ErrorContains(t, err, `returned "<html>rate limited</html>" instead of an IPv4 address`)
+}
@@ -0,0 +1,57 @@
+// Package bootprovider defines the interface used by the boot tooling to
+// upload, register, and boot images on a cloud provider and to clean up the
+// resources it creates along the way.
+package bootprovider
+
+// RunIDTagKey is the key of the tag (or label) that providers add to every
+// resource created during a single run so that resources from the same run
+// can be correlated and found again if they leak.
+const RunIDTagKey = "boot-aws-run-id"
+
+// Provider is a cloud provider that can boot images.
+//
+// The lifecycle of an image is Upload, Register, Boot, and finally Teardown.
+// Each step records the IDs of the resources it creates in the Resources
+// passed to it, so that Teardown can clean up everything created by the
+// previous steps, even when one of them failed.
+type Provider interface {
+	// Name returns the name of the provider, which is recorded in the
+	// Resources.
+	Name() string
+
+	// Upload uploads the image file to the provider's storage.
+	Upload(filename string, res *Resources) error
+
+	// Register creates a bootable image from the uploaded file.
+	Register(res *Resources) error
+
+	// Boot starts an instance from the registered image with the given
+	// cloud-init user data. It returns when the instance is running.
+	Boot(userData string, res *Resources) error
+
+	// Address returns the public address of the running instance.
+	Address(res *Resources) (string, error)
+
+	// Teardown deletes all the resources recorded in res.
+	Teardown(res *Resources) error
+}
+
+// Resources created or allocated for an instance that can be cleaned up when
+// tearing down. Only the section matching the Provider is set.
+type Resources struct {
+	Provider string `json:"provider"`
+	RunID    string `json:"run-id,omitempty"`
+
+	AWS *AWSResources `json:"aws,omitempty"`
+}
+
+// AWSResources are the resources created by the AWS provider.
+type AWSResources struct {
+	AMI           *string `json:"ami,omitempty"`
+	Snapshot      *string `json:"snapshot,omitempty"`
+	SecurityGroup *string `json:"security-group,omitempty"`
+	InstanceID    *string `json:"instance,omitempty"`
+
+	// Bucket is only set if the bucket was created by the provider.
+	Bucket *string `json:"bucket,omitempty"`
+}
@@ -266,18 +266,13 @@ func (s *Solver) reposFromRPMMD(rpmRepos []rpmmd.RepoConfig) ([]repoConfig, erro
 			Metalink:       rr.Metalink,
 			MirrorList:     rr.MirrorList,
 			GPGKeys:        rr.GPGKeys,
+			CheckGPG:       rr.GPGCheckEnabled(),
+			CheckRepoGPG:   rr.RepoGPGCheckEnabled(),
 			MetadataExpire: rr.MetadataExpire,
+			Proxy:          rr.Proxy,
 			repoHash:       rr.Hash(),
 		}
 
-		if rr.CheckGPG != nil {
-			dr.CheckGPG = *rr.CheckGPG
-		}
-
-		if rr.CheckRepoGPG != nil {
-			dr.CheckRepoGPG = *rr.CheckRepoGPG
-		}
-
 		if rr.IgnoreSSL != nil {
 			dr.IgnoreSSL = *rr.IgnoreSSL
 		}
@@ -315,6 +310,7 @@ type repoConfig struct {
 	SSLClientKey   string   `json:"sslclientkey,omitempty"`
 	SSLClientCert  string   `json:"sslclientcert,omitempty"`
 	MetadataExpire string   `json:"metadata_expire,omitempty"`
+	Proxy          string   `json:"proxy,omitempty"`
 	// set the repo hass from `rpmmd.RepoConfig.Hash()` function
 	// rather than re-calculating it
 	repoHash string
@@ -358,9 +354,10 @@ func (s *Solver) makeDepsolveRequest(pkgSets []rpmmd.PackageSet) (*Request, map[
 	transactions := make([]transactionArgs, len(pkgSets))
 	for dsIdx, pkgSet := range pkgSets {
 		transactions[dsIdx] = transactionArgs{
-			PackageSpecs:    pkgSet.Include,
-			ExcludeSpecs:    pkgSet.Exclude,
-			InstallWeakDeps: pkgSet.InstallWeakDeps,
+			PackageSpecs:      pkgSet.Include,
+			ExcludeSpecs:      pkgSet.Exclude,
+			InstallWeakDeps:   pkgSet.InstallWeakDeps,
+			ModuleEnableSpecs: pkgSet.EnabledModules,
 		}
 
 		for _, jobRepo := range pkgSet.Repositories {
@@ -459,9 +456,7 @@ func (pkgs packageSpecs) toRPMMD(repos map[string]rpmmd.RepoConfig) []rpmmd.Pack
 		rpmDependencies[i].Arch = dep.Arch
 		rpmDependencies[i].RemoteLocation = dep.RemoteLocation
 		rpmDependencies[i].Checksum = dep.Checksum
-		if repo.CheckGPG != nil {
-			rpmDependencies[i].CheckGPG = *repo.CheckGPG
-		}
+		rpmDependencies[i].CheckGPG = repo.GPGCheckEnabled()
 		if repo.IgnoreSSL != nil {
 			rpmDependencies[i].IgnoreSSL = *repo.IgnoreSSL
 		}
@@ -542,6 +537,9 @@ type transactionArgs struct {
 
 	// If we want weak deps for this depsolve
 	InstallWeakDeps bool `json:"install_weak_deps"`
+
+	// Module streams to enable for this depsolve
+	ModuleEnableSpecs []string `json:"module-enable-specs,omitempty"`
 }
 
 type packageSpecs []PackageSpec
@@ -1,6 +1,7 @@
 package dnfjson
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"github.com/osbuild/images/internal/mocks/rpmrepo"
 	"github.com/osbuild/images/pkg/rpmmd"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var forceDNF = flag.Bool("force-dnf", false, "force dnf testing, making them fail instead of skip if dnf isn't installed")
@@ -66,6 +68,35 @@ func TestDepsolver(t *testing.T) {
 		exp := expectedResult(s.RepoConfig)
 		assert.Equal(deps, exp)
 	}
+
+	{ // without weak dependencies, the recommended packages are left out
+		pkgsets := []rpmmd.PackageSet{{Include: []string{"kernel", "vim-minimal", "tmux", "zsh"}, Repositories: []rpmmd.RepoConfig{s.RepoConfig}}}
+		deps, err := solver.Depsolve(pkgsets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := expectedResult(s.RepoConfig)
+		assert.Less(len(deps), len(exp))
+		assert.Subset(exp, deps)
+	}
+
+	{ // a pinned version that is not available fails instead of installing another version
+		pkgsets := []rpmmd.PackageSet{{Include: []string{"kernel", "zsh-0.0.1-1"}, Repositories: []rpmmd.RepoConfig{s.RepoConfig}}}
+		_, err := solver.Depsolve(pkgsets)
+		var dnfErr Error
+		require.ErrorAs(t, err, &dnfErr)
+		assert.Equal("MarkingErrors", dnfErr.Kind)
+		assert.Contains(dnfErr.Reason, "zsh-0.0.1-1")
+	}
+
+	{ // groups missing from the comps data of the repositories fail
+		pkgsets := []rpmmd.PackageSet{{Include: []string{"kernel", "@no-such-group"}, Repositories: []rpmmd.RepoConfig{s.RepoConfig}}}
+		_, err := solver.Depsolve(pkgsets)
+		var dnfErr Error
+		require.ErrorAs(t, err, &dnfErr)
+		assert.Equal("MarkingErrors", dnfErr.Kind)
+		assert.Contains(dnfErr.Reason, "no-such-group")
+	}
 }
 
 func TestMakeDepsolveRequest(t *testing.T) {
@@ -128,6 +159,60 @@ func TestMakeDepsolveRequest(t *testing.T) {
 				},
 			},
 		},
+		// single transaction with module streams
+		{
+			packageSets: []rpmmd.PackageSet{
+				{
+					Include:        []string{"pkg1"},
+					Repositories:   []rpmmd.RepoConfig{baseOS, appstream},
+					EnabledModules: []string{"nodejs:18", "postgresql:15/server"},
+				},
+			},
+			args: []transactionArgs{
+				{
+					PackageSpecs:      []string{"pkg1"},
+					RepoIDs:           []string{baseOS.Hash(), appstream.Hash()},
+					ModuleEnableSpecs: []string{"nodejs:18", "postgresql:15/server"},
+				},
+			},
+			wantRepos: []repoConfig{
+				{
+					ID:       baseOS.Hash(),
+					Name:     "baseos",
+					BaseURLs: []string{"https://example.org/baseos"},
+					repoHash: "fdc2e5bb6cda8e113308df9396a005b81a55ec00ec29aa0a447952ad4248d803",
+				},
+				{
+					ID:       appstream.Hash(),
+					Name:     "appstream",
+					BaseURLs: []string{"https://example.org/appstream"},
+					repoHash: "71c280f63a779a8bf53961ec2f15d51d052021de024a4e06ae499b8029701808",
+				},
+			},
+		},
+		// pinned package versions
+		{
+			packageSets: []rpmmd.PackageSet{
+				{
+					Include:      []string{"pkg1-1.2.3", "pkg2-2:1.0-3.el9"},
+					Repositories: []rpmmd.RepoConfig{baseOS},
+				},
+			},
+			args: []transactionArgs{
+				{
+					PackageSpecs: []string{"pkg1-1.2.3", "pkg2-2:1.0-3.el9"},
+					RepoIDs:      []string{baseOS.Hash()},
+				},
+			},
+			wantRepos: []repoConfig{
+				{
+					ID:       baseOS.Hash(),
+					Name:     "baseos",
+					BaseURLs: []string{"https://example.org/baseos"},
+					repoHash: "fdc2e5bb6cda8e113308df9396a005b81a55ec00ec29aa0a447952ad4248d803",
+				},
+			},
+		},
 		// 2 transactions + package set specific repo
 		{
 			packageSets: []rpmmd.PackageSet{
@@ -622,3 +707,96 @@ func TestRequestHash(t *testing.T) {
 	assert.Equal(t, 64, len(req.Hash()))
 	assert.NotEqual(t, hash, req.Hash())
 }
+
+func TestReposFromRPMMDGPGCheck(t *testing.T) {
+	key := "-----BEGIN PGP PUBLIC KEY BLOCK-----\n\nmQINBGN9300BEAC1FLODu0cL6saMMHa7yJY1JZUc+jQUI/HdECQrrsTaPXlcc7nM\n-----END PGP PUBLIC KEY BLOCK-----\n"
+	repos := []rpmmd.RepoConfig{
+		{
+			Name:     "default",
+			BaseURLs: []string{"https://example.org/default"},
+			GPGKeys:  []string{key},
+		},
+		{
+			Name:         "disabled",
+			BaseURLs:     []string{"https://example.org/disabled"},
+			GPGKeys:      []string{key},
+			CheckGPG:     common.ToPtr(false),
+			CheckRepoGPG: common.ToPtr(false),
+		},
+		{
+			Name:         "repo-gpg",
+			BaseURLs:     []string{"https://example.org/repo-gpg"},
+			GPGKeys:      []string{"https://example.org/key.asc"},
+			CheckRepoGPG: common.ToPtr(true),
+		},
+		{
+			Name:     "no-keys",
+			BaseURLs: []string{"https://example.org/no-keys"},
+		},
+	}
+
+	solver := NewSolver("f38", "38", "x86_64", "fedora-38", "/tmp/cache")
+	rcs, err := solver.reposFromRPMMD(repos)
+	assert.NoError(t, err)
+	assert.Len(t, rcs, 4)
+
+	assert.True(t, rcs[0].CheckGPG)
+	assert.False(t, rcs[0].CheckRepoGPG)
+	assert.Equal(t, []string{key}, rcs[0].GPGKeys)
+
+	assert.False(t, rcs[1].CheckGPG)
+	assert.False(t, rcs[1].CheckRepoGPG)
+	assert.Equal(t, []string{key}, rcs[1].GPGKeys)
+
+	assert.True(t, rcs[2].CheckGPG)
+	assert.True(t, rcs[2].CheckRepoGPG)
+	assert.Equal(t, []string{"https://example.org/key.asc"}, rcs[2].GPGKeys)
+
+	assert.False(t, rcs[3].CheckGPG)
+	assert.False(t, rcs[3].CheckRepoGPG)
+	assert.Empty(t, rcs[3].GPGKeys)
+
+	// the toggles are part of the hash of the repository
+	assert.NotEqual(t, rcs[0].ID, rcs[1].ID)
+
+	// the packages inherit the verification setting of their repository
+	pkgs := packageSpecs{
+		{Name: "pkg1", RepoID: rcs[0].ID},
+		{Name: "pkg2", RepoID: rcs[1].ID},
+	}
+	specs := pkgs.toRPMMD(map[string]rpmmd.RepoConfig{
+		rcs[0].ID: repos[0],
+		rcs[1].ID: repos[1],
+	})
+	assert.True(t, specs[0].CheckGPG)
+	assert.False(t, specs[1].CheckGPG)
+}
+
+func TestReposFromRPMMDProxy(t *testing.T) {
+	repos := []rpmmd.RepoConfig{
+		{
+			Name:     "proxied",
+			BaseURLs: []string{"https://example.org/proxied"},
+			Proxy:    "http://proxy.example.com:3128",
+		},
+		{
+			Name:     "direct",
+			BaseURLs: []string{"https://example.org/direct"},
+		},
+	}
+
+	solver := NewSolver("f38", "38", "x86_64", "fedora-38", "/tmp/cache")
+	req, _, err := solver.makeDepsolveRequest([]rpmmd.PackageSet{{Include: []string{"bash"}, Repositories: repos}})
+	require.NoError(t, err)
+	require.Len(t, req.Arguments.Repos, 2)
+	assert.Equal(t, "http://proxy.example.com:3128", req.Arguments.Repos[0].Proxy)
+	assert.Empty(t, req.Arguments.Repos[1].Proxy)
+
+	data, err := json.Marshal(req.Arguments.Repos)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"proxy":"http://proxy.example.com:3128"`)
+	assert.Equal(t, 1, strings.Count(string(data), `"proxy"`))
+
+	// the proxy does not change the content of the repository
+	assert.Equal(t, repos[0].Hash(), (&rpmmd.RepoConfig{BaseURLs: repos[0].BaseURLs}).Hash())
+}
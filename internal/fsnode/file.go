@@ -1,12 +1,18 @@
 package fsnode
 
 import (
+	"fmt"
 	"os"
 )
 
 type File struct {
 	baseFsNode
 	data []byte
+
+	// Location and checksum of the content of a remote file, which is
+	// fetched at build time instead of being inlined
+	url      string
+	checksum string
 }
 
 func (f *File) IsDir() bool {
@@ -20,6 +26,24 @@ func (f *File) Data() []byte {
 	return f.data
 }
 
+// URL returns the location of the content of a remote file or an empty
+// string if the content is inlined.
+func (f *File) URL() string {
+	if f == nil {
+		return ""
+	}
+	return f.url
+}
+
+// Checksum returns the checksum of the content of a remote file in the
+// "algorithm:digest" format.
+func (f *File) Checksum() string {
+	if f == nil {
+		return ""
+	}
+	return f.checksum
+}
+
 // NewFile creates a new file with the given path, data, mode, user and group.
 // user and group can be either a string (user name/group name), an int64 (UID/GID) or nil.
 func NewFile(path string, mode *os.FileMode, user interface{}, group interface{}, data []byte) (*File, error) {
@@ -34,3 +58,29 @@ func NewFile(path string, mode *os.FileMode, user interface{}, group interface{}
 		data:       data,
 	}, nil
 }
+
+// NewRemoteFile creates a new file with the given path, mode, user and group,
+// whose content is fetched from the given URL at build time. The checksum of
+// the content is required to verify it and must be in the "algorithm:digest"
+// format.
+func NewRemoteFile(path string, mode *os.FileMode, user interface{}, group interface{}, url string, checksum string) (*File, error) {
+	baseNode, err := newBaseFsNode(path, mode, user, group)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if url == "" {
+		return nil, fmt.Errorf("url of remote file %q must not be empty", path)
+	}
+
+	if checksum == "" {
+		return nil, fmt.Errorf("checksum of remote file %q must not be empty", path)
+	}
+
+	return &File{
+		baseFsNode: *baseNode,
+		url:        url,
+		checksum:   checksum,
+	}, nil
+}
@@ -79,3 +79,19 @@ func TestNewFile(t *testing.T) {
 		})
 	}
 }
+
+func TestNewRemoteFile(t *testing.T) {
+	file, err := NewRemoteFile("/etc/file", nil, "root", nil, "https://example.com/file", "sha256:0123")
+	assert.NoError(t, err)
+	assert.Equal(t, &File{baseFsNode: baseFsNode{path: "/etc/file", user: "root"}, url: "https://example.com/file", checksum: "sha256:0123"}, file)
+	assert.Nil(t, file.Data())
+
+	_, err = NewRemoteFile("/etc/file", nil, nil, nil, "", "sha256:0123")
+	assert.EqualError(t, err, `url of remote file "/etc/file" must not be empty`)
+
+	_, err = NewRemoteFile("/etc/file", nil, nil, nil, "https://example.com/file", "")
+	assert.EqualError(t, err, `checksum of remote file "/etc/file" must not be empty`)
+
+	_, err = NewRemoteFile("etc/file", nil, nil, nil, "https://example.com/file", "sha256:0123")
+	assert.Error(t, err)
+}
@@ -1,6 +1,11 @@
 package users
 
-import "github.com/osbuild/images/pkg/blueprint"
+import (
+	"fmt"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/crypt"
+)
 
 type User struct {
 	Name        string
@@ -38,3 +43,17 @@ func GroupsFromBP(groupCustomizations []blueprint.GroupCustomization) []Group {
 	}
 	return groups
 }
+
+// HashPassword returns the SHA-512 crypt hash of the plaintext password, as
+// stored in /etc/shadow, so that plaintext passwords are never written to the
+// image. Passwords that are already hashed are returned as they are. Empty
+// passwords cannot be hashed, they lock the account instead.
+func HashPassword(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", fmt.Errorf("cannot hash an empty password")
+	}
+	if crypt.PasswordIsCrypted(plaintext) {
+		return plaintext, nil
+	}
+	return crypt.CryptSHA512(plaintext)
+}
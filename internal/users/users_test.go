@@ -0,0 +1,36 @@
+//go:build !darwin
+
+package users
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashPassword(t *testing.T) {
+	hashed, err := HashPassword("password")
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^\$6\$[./0-9A-Za-z]{16}\$[./0-9A-Za-z]{86}$`), hashed)
+
+	// the salt is random
+	again, err := HashPassword("password")
+	require.NoError(t, err)
+	assert.NotEqual(t, hashed, again)
+
+	// already hashed passwords are passed through
+	for _, crypted := range []string{
+		hashed,
+		"$5$1234567890123456$v.2bOKKLlpmUSKn0rxJmgnh.e3wOKivAVNZmNrOsoA3",
+		"$2b$04$123465789012345678901uac5A8egfBuZVHMrDZsQzR96IqNBivCy",
+	} {
+		passedThrough, err := HashPassword(crypted)
+		require.NoError(t, err)
+		assert.Equal(t, crypted, passedThrough)
+	}
+
+	_, err = HashPassword("")
+	assert.EqualError(t, err, "cannot hash an empty password")
+}
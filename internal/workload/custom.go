@@ -5,6 +5,13 @@ type Custom struct {
 	Packages         []string
 	Services         []string
 	DisabledServices []string
+	MaskedServices   []string
+	// ExcludePackages are excluded from all the package sets of the OS,
+	// including the packages of the image type
+	ExcludePackages []string
+	// InstallWeakDeps overrides whether the weak dependencies of the packages
+	// of the OS are installed, if set
+	InstallWeakDeps *bool
 }
 
 func (p *Custom) GetPackages() []string {
@@ -20,3 +27,15 @@ func (p *Custom) GetServices() []string {
 func (p *Custom) GetDisabledServices() []string {
 	return p.DisabledServices
 }
+
+func (p *Custom) GetMaskedServices() []string {
+	return p.MaskedServices
+}
+
+func (p *Custom) GetExcludePackages() []string {
+	return p.ExcludePackages
+}
+
+func (p *Custom) GetInstallWeakDeps() *bool {
+	return p.InstallWeakDeps
+}
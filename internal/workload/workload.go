@@ -7,6 +7,9 @@ type Workload interface {
 	GetRepos() []rpmmd.RepoConfig
 	GetServices() []string
 	GetDisabledServices() []string
+	GetMaskedServices() []string
+	GetExcludePackages() []string
+	GetInstallWeakDeps() *bool
 }
 
 type BaseWorkload struct {
@@ -28,3 +31,15 @@ func (p BaseWorkload) GetServices() []string {
 func (p BaseWorkload) GetDisabledServices() []string {
 	return []string{}
 }
+
+func (p BaseWorkload) GetMaskedServices() []string {
+	return []string{}
+}
+
+func (p BaseWorkload) GetExcludePackages() []string {
+	return []string{}
+}
+
+func (p BaseWorkload) GetInstallWeakDeps() *bool {
+	return nil
+}
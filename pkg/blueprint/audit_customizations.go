@@ -0,0 +1,104 @@
+package blueprint
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+const (
+	// AuditService is the audit daemon, which loads the rules of
+	// /etc/audit/rules.d with augenrules when it starts.
+	AuditService = "auditd.service"
+	// AuditPackage provides the AuditService and augenrules.
+	AuditPackage = "audit"
+	// AuditRulesPath is the file of the rules of the Audit customization. It
+	// is named so that it comes after the rules of the packages.
+	AuditRulesPath = "/etc/audit/rules.d/90-blueprint.rules"
+)
+
+// The options an audit rule can start with, see auditctl(8). Rules are
+// added with -a or -A, file watches with -w, and the others configure the
+// audit system, e.g. -e 2 makes the configuration immutable.
+var auditRuleOptions = map[string]bool{
+	"-a":                   true,
+	"-A":                   true,
+	"-w":                   true,
+	"-W":                   true,
+	"-b":                   true,
+	"-D":                   true,
+	"-e":                   true,
+	"-f":                   true,
+	"-r":                   true,
+	"--backlog_wait_time":  true,
+	"--loginuid-immutable": true,
+}
+
+// AuditCustomization is a list of audit rules, as required by the CIS and
+// STIG profiles, that auditd loads on boot.
+type AuditCustomization struct {
+	// The rules in the auditctl(8) format, one per entry, e.g.
+	// "-w /etc/sudoers -p wa -k scope"
+	Rules []string `json:"rules" toml:"rules"`
+}
+
+// ValidateAuditCustomization validates the given Audit customization against
+// the files of the blueprint. If the customization is invalid, an error is
+// returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - There is at least one rule
+// - Each rule is a single line that starts with an auditctl option, e.g. -a or
+// -w, or is a comment
+// - The rules file is not also defined in the Files customization
+func ValidateAuditCustomization(audit *AuditCustomization, files []FileCustomization) error {
+	if audit == nil {
+		return nil
+	}
+
+	if len(audit.Rules) == 0 {
+		return fmt.Errorf("audit customization has no rules")
+	}
+	for _, rule := range audit.Rules {
+		if strings.ContainsAny(rule, "\r\n") {
+			return fmt.Errorf("audit rule %q must be a single line", rule)
+		}
+		fields := strings.Fields(rule)
+		if len(fields) == 0 {
+			return fmt.Errorf("audit rule is empty")
+		}
+		if strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if !auditRuleOptions[fields[0]] {
+			return fmt.Errorf("audit rule %q is invalid: must start with an auditctl option like -a or -w", rule)
+		}
+	}
+
+	for _, file := range files {
+		if path.Clean(file.Path) == AuditRulesPath {
+			return fmt.Errorf("audit customizations cannot be combined with a custom %s file", AuditRulesPath)
+		}
+	}
+	return nil
+}
+
+// AuditCustomizationToFsNodeFile converts the Audit customization to the
+// rules file in /etc/audit/rules.d. It returns nil if there are no rules.
+func AuditCustomizationToFsNodeFile(audit *AuditCustomization) (*fsnode.File, error) {
+	if audit == nil {
+		return nil, nil
+	}
+
+	if err := ValidateAuditCustomization(audit, nil); err != nil {
+		return nil, err
+	}
+
+	// like the rules of the audit package, only root can read them
+	data := strings.Join(audit.Rules, "\n") + "\n"
+	return fsnode.NewFile(AuditRulesPath, common.ToPtr(os.FileMode(0600)), "root", "root", []byte(data))
+}
@@ -0,0 +1,94 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAuditCustomization(t *testing.T) {
+	testCases := []struct {
+		name    string
+		audit   *AuditCustomization
+		files   []FileCustomization
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "valid",
+			audit: &AuditCustomization{Rules: []string{
+				"# record changes to the sudoers",
+				"-w /etc/sudoers -p wa -k scope",
+				"-a always,exit -F arch=b64 -S adjtimex,settimeofday -k time-change",
+				"--loginuid-immutable",
+				"-e 2",
+			}},
+			files: []FileCustomization{{Path: "/etc/audit/auditd.conf"}},
+		},
+		{
+			name:    "no rules",
+			audit:   &AuditCustomization{},
+			wantErr: "audit customization has no rules",
+		},
+		{
+			name:    "empty rule",
+			audit:   &AuditCustomization{Rules: []string{" "}},
+			wantErr: "audit rule is empty",
+		},
+		{
+			name:    "multiple lines",
+			audit:   &AuditCustomization{Rules: []string{"-w /etc/passwd -p wa\n-w /etc/group -p wa"}},
+			wantErr: `audit rule "-w /etc/passwd -p wa\n-w /etc/group -p wa" must be a single line`,
+		},
+		{
+			name:    "not an option",
+			audit:   &AuditCustomization{Rules: []string{"always,exit -S mount"}},
+			wantErr: `audit rule "always,exit -S mount" is invalid: must start with an auditctl option like -a or -w`,
+		},
+		{
+			name:    "unknown option",
+			audit:   &AuditCustomization{Rules: []string{"-l"}},
+			wantErr: `audit rule "-l" is invalid: must start with an auditctl option like -a or -w`,
+		},
+		{
+			name:    "custom file",
+			audit:   &AuditCustomization{Rules: []string{"-w /etc/sudoers -p wa"}},
+			files:   []FileCustomization{{Path: "/etc/audit/rules.d/90-blueprint.rules"}},
+			wantErr: "audit customizations cannot be combined with a custom /etc/audit/rules.d/90-blueprint.rules file",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateAuditCustomization(tc.audit, tc.files)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuditCustomizationToFsNodeFile(t *testing.T) {
+	file, err := AuditCustomizationToFsNodeFile(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, file)
+
+	file, err = AuditCustomizationToFsNodeFile(&AuditCustomization{Rules: []string{
+		"-w /etc/sudoers -p wa -k scope",
+		"-a always,exit -F arch=b64 -S mount -k mounts",
+	}})
+	require.NoError(t, err)
+	require.NotNil(t, file)
+	assert.Equal(t, "/etc/audit/rules.d/90-blueprint.rules", file.Path())
+	assert.Equal(t, "-w /etc/sudoers -p wa -k scope\n-a always,exit -F arch=b64 -S mount -k mounts\n", string(file.Data()))
+	assert.Equal(t, os.FileMode(0600), *file.Mode())
+
+	_, err = AuditCustomizationToFsNodeFile(&AuditCustomization{Rules: []string{"mount"}})
+	assert.Error(t, err)
+}
@@ -1,6 +1,27 @@
 // Package blueprint contains primitives for representing weldr blueprints
 package blueprint
 
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+)
+
+// A package version in the [epoch:]version[-release] form accepted by dnf,
+// optionally with * and ? wildcards
+var packageVersionRegex = regexp.MustCompile(`^([0-9]+:)?[a-zA-Z0-9._+~^*?]+(-[a-zA-Z0-9._+~^*?]+)?$`)
+
+// A comps group or environment ID, e.g. development-tools or
+// ^server-product-environment
+var groupNameRegex = regexp.MustCompile(`^\^?[a-zA-Z0-9_.:+-]+$`)
+
+// A package name, optionally with * and ? wildcards, as accepted by the
+// excludes of dnf
+var packageNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.+*?-]+$`)
+
 // A Blueprint is a high-level description of an image.
 type Blueprint struct {
 	Name           string          `json:"name" toml:"name"`
@@ -13,6 +34,13 @@ type Blueprint struct {
 	Customizations *Customizations `json:"customizations,omitempty" toml:"customizations"`
 	Distro         string          `json:"distro" toml:"distro"`
 
+	// Packages that are never installed, e.g. the weak dependencies of the
+	// packages of the image type. Names may contain * and ? wildcards.
+	ExcludePackages []string `json:"exclude_packages,omitempty" toml:"exclude_packages,omitempty"`
+	// Install the weak dependencies (Recommends) of the packages. The default
+	// of the image type is used if unset.
+	InstallWeakDeps *bool `json:"install_weak_deps,omitempty" toml:"install_weak_deps,omitempty"`
+
 	// EXPERIMENTAL
 	Minimal bool `json:"minimal" toml:"minimal"`
 }
@@ -23,14 +51,22 @@ type Package struct {
 	Version string `json:"version,omitempty" toml:"version,omitempty"`
 }
 
-// A group specifies an package group.
+// A group specifies an package group. The name is the ID of the group in the
+// comps data of the repositories, optionally prefixed with @ like in kickstart
+// files.
 type Group struct {
 	Name string `json:"name" toml:"name"`
 }
 
+// A Container is a container image to embed in the container storage of the
+// image, so that it doesn't need to be pulled on first boot.
 type Container struct {
+	// Reference of the image to embed, e.g. quay.io/fedora/fedora:39. A
+	// reference with a digest, e.g. quay.io/fedora/fedora@sha256:..., pins the
+	// embedded image.
 	Source string `json:"source" toml:"source"`
-	Name   string `json:"name,omitempty" toml:"name,omitempty"`
+	// Name of the image in the container storage, defaults to the source
+	Name string `json:"name,omitempty" toml:"name,omitempty"`
 
 	TLSVerify *bool `json:"tls-verify,omitempty" toml:"tls-verify,omitempty"`
 }
@@ -50,7 +86,7 @@ func (b *Blueprint) GetPackagesEx(bootable bool) []string {
 		packages = append(packages, pkg.ToNameVersion())
 	}
 	for _, group := range b.Groups {
-		packages = append(packages, "@"+group.Name)
+		packages = append(packages, group.ToSpec())
 	}
 
 	if bootable {
@@ -62,6 +98,108 @@ func (b *Blueprint) GetPackagesEx(bootable bool) []string {
 	return packages
 }
 
+// ValidatePackageVersions returns an error if the version of a package or
+// module is not in the [epoch:]version[-release] form. The version is passed
+// to the depsolver as part of the package name, so an invalid one could match
+// unrelated packages.
+func (b *Blueprint) ValidatePackageVersions() error {
+	for _, packages := range [][]Package{b.Packages, b.Modules} {
+		for _, pkg := range packages {
+			if pkg.Version != "" && !packageVersionRegex.MatchString(pkg.Version) {
+				return fmt.Errorf("package %q has an invalid version %q: must be [epoch:]version[-release]", pkg.Name, pkg.Version)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateGroups returns an error if a group name is not a valid comps ID or is
+// listed more than once. Whether the group exists is only known to the
+// depsolver, which fails for groups missing from the comps data.
+func (b *Blueprint) ValidateGroups() error {
+	seen := make(map[string]bool, len(b.Groups))
+	for _, group := range b.Groups {
+		name := strings.TrimPrefix(group.Name, "@")
+		if !groupNameRegex.MatchString(name) {
+			return fmt.Errorf("group name %q is invalid", group.Name)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate group %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// ValidateContainers returns an error if the source or the name of a container
+// is not a valid image reference or if two containers are stored with the same
+// name. Whether the image exists is only known when resolving it.
+func (b *Blueprint) ValidateContainers() error {
+	seen := make(map[string]bool, len(b.Containers))
+	for _, c := range b.Containers {
+		source, err := reference.ParseNormalizedNamed(c.Source)
+		if err != nil {
+			return fmt.Errorf("container source %q is invalid: %w", c.Source, err)
+		}
+		name := source.String()
+		if c.Name != "" {
+			localName, err := reference.ParseNormalizedNamed(c.Name)
+			if err != nil {
+				return fmt.Errorf("container name %q is invalid: %w", c.Name, err)
+			}
+			name = localName.String()
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate container name %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// ValidateExcludePackages returns an error if an excluded package is not a
+// valid package name, is listed more than once or excludes a package or module
+// of the blueprint.
+func (b *Blueprint) ValidateExcludePackages() error {
+	seen := make(map[string]bool, len(b.ExcludePackages))
+	for _, name := range b.ExcludePackages {
+		if !packageNameRegex.MatchString(name) {
+			return fmt.Errorf("excluded package name %q is invalid", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate excluded package %q", name)
+		}
+		seen[name] = true
+	}
+
+	required := make([]string, 0, len(b.Packages)+len(b.Modules))
+	for _, packages := range [][]Package{b.Packages, b.Modules} {
+		for _, pkg := range packages {
+			required = append(required, pkg.Name)
+		}
+	}
+	return b.CheckExcludedPackages(required)
+}
+
+// CheckExcludedPackages returns an error if an excluded package matches one of
+// the given package names that the image requires, e.g. the packages of the
+// image type. Excluding them would make the depsolver fail.
+func (b *Blueprint) CheckExcludedPackages(required []string) error {
+	for _, exclude := range b.ExcludePackages {
+		for _, name := range required {
+			if matched, _ := path.Match(exclude, name); matched {
+				return fmt.Errorf("excluded package %q conflicts with the required package %q", exclude, name)
+			}
+		}
+	}
+	return nil
+}
+
+// ToSpec returns the @-prefixed group spec passed to the depsolver.
+func (g Group) ToSpec() string {
+	return "@" + strings.TrimPrefix(g.Name, "@")
+}
+
 func (p Package) ToNameVersion() string {
 	// Omit version to prevent all packages with prefix of name to be installed
 	if p.Version == "*" || p.Version == "" {
@@ -1,10 +1,14 @@
 package blueprint
 
 import (
+	"bytes"
 	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/BurntSushi/toml"
+	"github.com/osbuild/images/internal/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -143,3 +147,301 @@ func TestKernelNameCustomization(t *testing.T) {
 		}
 	}
 }
+
+func TestValidatePackageVersions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		version string
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "any", version: "*"},
+		{name: "version", version: "2.4.57"},
+		{name: "version glob", version: "2.4.*"},
+		{name: "version-release", version: "5.2.15-3.fc38"},
+		{name: "epoch:version-release", version: "2:9.0.1677-1.fc38"},
+		{name: "tilde and caret", version: "1.0~rc1^20230101git1234"},
+		{name: "whitespace", version: "2.4 57", wantErr: `package "pkg" has an invalid version "2.4 57": must be [epoch:]version[-release]`},
+		{name: "too many dashes", version: "1-2-3", wantErr: `package "pkg" has an invalid version "1-2-3": must be [epoch:]version[-release]`},
+		{name: "empty epoch", version: ":1.0", wantErr: `package "pkg" has an invalid version ":1.0": must be [epoch:]version[-release]`},
+		{name: "comparison", version: ">=1.0", wantErr: `package "pkg" has an invalid version ">=1.0": must be [epoch:]version[-release]`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, bp := range []Blueprint{
+				{Packages: []Package{{Name: "pkg", Version: tc.version}}},
+				{Modules: []Package{{Name: "pkg", Version: tc.version}}},
+			} {
+				err := bp.ValidatePackageVersions()
+				if tc.wantErr == "" {
+					assert.NoError(t, err)
+				} else {
+					assert.EqualError(t, err, tc.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateGroups(t *testing.T) {
+	testCases := []struct {
+		name    string
+		groups  []Group
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "valid", groups: []Group{{Name: "core"}, {Name: "development-tools"}, {Name: "@c-development"}, {Name: "^server-product-environment"}}},
+		{name: "empty", groups: []Group{{Name: ""}}, wantErr: `group name "" is invalid`},
+		{name: "only prefix", groups: []Group{{Name: "@"}}, wantErr: `group name "@" is invalid`},
+		{name: "display name", groups: []Group{{Name: "Development Tools"}}, wantErr: `group name "Development Tools" is invalid`},
+		{name: "duplicate", groups: []Group{{Name: "core"}, {Name: "@core"}}, wantErr: `duplicate group "core"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bp := Blueprint{Groups: tc.groups}
+			err := bp.ValidateGroups()
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateExcludePackages(t *testing.T) {
+	testCases := []struct {
+		name     string
+		excludes []string
+		wantErr  string
+	}{
+		{name: "none"},
+		{name: "valid", excludes: []string{"abrt", "PackageKit*", "iwl?00-firmware", "libstdc++-devel"}},
+		{name: "empty", excludes: []string{""}, wantErr: `excluded package name "" is invalid`},
+		{name: "version", excludes: []string{"tmux >= 3"}, wantErr: `excluded package name "tmux >= 3" is invalid`},
+		{name: "duplicate", excludes: []string{"abrt", "abrt"}, wantErr: `duplicate excluded package "abrt"`},
+		{name: "package", excludes: []string{"tmux"}, wantErr: `excluded package "tmux" conflicts with the required package "tmux"`},
+		{name: "module glob", excludes: []string{"node*"}, wantErr: `excluded package "node*" conflicts with the required package "nodejs"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bp := Blueprint{
+				Packages:        []Package{{Name: "tmux", Version: "3.3a"}},
+				Modules:         []Package{{Name: "nodejs"}},
+				ExcludePackages: tc.excludes,
+			}
+			err := bp.ValidateExcludePackages()
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckExcludedPackages(t *testing.T) {
+	bp := Blueprint{ExcludePackages: []string{"sssd-*"}}
+	assert.NoError(t, bp.CheckExcludedPackages([]string{"sssd", "@core"}))
+	assert.EqualError(t, bp.CheckExcludedPackages([]string{"sssd", "sssd-client"}), `excluded package "sssd-*" conflicts with the required package "sssd-client"`)
+}
+
+func TestValidateContainers(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	testCases := []struct {
+		name       string
+		containers []Container
+		wantErr    string
+	}{
+		{name: "none"},
+		{
+			name: "valid",
+			containers: []Container{
+				{Source: "quay.io/fedora/fedora:39"},
+				{Source: "registry.example.com:5000/app@" + digest, Name: "localhost/app:stable"},
+				{Source: "busybox"},
+			},
+		},
+		{
+			name:       "invalid source",
+			containers: []Container{{Source: "quay.io/Fedora/fedora"}},
+			wantErr:    `container source "quay.io/Fedora/fedora" is invalid: invalid reference format: repository name must be lowercase`,
+		},
+		{
+			name:       "invalid digest",
+			containers: []Container{{Source: "quay.io/fedora/fedora@sha256:abc"}},
+			wantErr:    `container source "quay.io/fedora/fedora@sha256:abc" is invalid: invalid reference format`,
+		},
+		{
+			name:       "invalid name",
+			containers: []Container{{Source: "quay.io/fedora/fedora", Name: "my app"}},
+			wantErr:    `container name "my app" is invalid: invalid reference format`,
+		},
+		{
+			name:       "duplicate name",
+			containers: []Container{{Source: "quay.io/fedora/fedora:39", Name: "fedora"}, {Source: "docker.io/library/fedora"}},
+			wantErr:    `duplicate container name "docker.io/library/fedora"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bp := Blueprint{Containers: tc.containers}
+			err := bp.ValidateContainers()
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestGroupToSpec(t *testing.T) {
+	assert.Equal(t, "@core", Group{Name: "core"}.ToSpec())
+	assert.Equal(t, "@core", Group{Name: "@core"}.ToSpec())
+	assert.Equal(t, "@^workstation-product-environment", Group{Name: "^workstation-product-environment"}.ToSpec())
+}
+
+// representativeBlueprint returns a blueprint that sets all the customizations
+// and both set and unset optional fields
+func representativeBlueprint() Blueprint {
+	return Blueprint{
+		Name:        "roundtrip",
+		Description: "TOML round trip",
+		Version:     "1.2.3",
+		Packages:    []Package{{Name: "tmux", Version: "3.3a"}, {Name: "zsh"}},
+		Modules:     []Package{{Name: "nodejs", Version: "*"}},
+		Groups:      []Group{{Name: "core"}},
+		Containers:  []Container{{Source: "quay.io/fedora/fedora:39", Name: "fedora", TLSVerify: common.ToPtr(false)}},
+		Distro:      "fedora-39",
+		Minimal:     true,
+		Customizations: &Customizations{
+			Hostname: common.ToPtr("homelab"),
+			Kernel:   &KernelCustomization{Name: "kernel-debug", Append: "nosmt=force", Remove: []string{"rhgb", "quiet"}},
+			SSHKey:   []SSHKeyCustomization{{User: "root", Key: "ssh-ed25519 AAAA"}},
+			User: []UserCustomization{
+				{Name: "admin", Password: common.ToPtr("$6$hash"), Groups: []string{"wheel"}, UID: common.ToPtr(1000), GID: common.ToPtr(1000)},
+				{Name: "service", Shell: common.ToPtr("/sbin/nologin"), Home: common.ToPtr("/srv")},
+			},
+			Group:    []GroupCustomization{{Name: "admin", GID: common.ToPtr(1000)}, {Name: "service"}},
+			Timezone: &TimezoneCustomization{Timezone: common.ToPtr("Europe/Berlin")},
+			Locale:   &LocaleCustomization{Languages: []string{"en_US.UTF-8"}, Keyboard: common.ToPtr("de")},
+			Firewall: &FirewallCustomization{
+				Ports:    []string{"22:tcp"},
+				Services: &FirewallServicesCustomization{Enabled: []string{"ssh"}, Disabled: []string{"telnet"}},
+				Zones:    []FirewallZoneCustomization{{Name: common.ToPtr("trusted"), Sources: []string{"10.0.0.0/8"}}},
+			},
+			Services:           &ServicesCustomization{Enabled: []string{"sshd"}, Disabled: []string{"cups"}, Masked: []string{"debug-shell.service"}},
+			Filesystem:         []FilesystemCustomization{{Mountpoint: "/var", MinSize: 2147483648}},
+			InstallationDevice: "/dev/vda",
+			FDO:                &FDOCustomization{ManufacturingServerURL: "http://fdo.example.com", DiunPubKeyHash: "sha256:abc"},
+			OpenSCAP: &OpenSCAPCustomization{
+				ProfileID: "xccdf_org.ssgproject.content_profile_cis",
+				Tailoring: &OpenSCAPTailoringCustomizations{Selected: []string{"rule_a"}},
+			},
+			Ignition: &IgnitionCustomization{FirstBoot: &FirstBootIgnitionCustomization{ProvisioningURL: "http://ignition.example.com"}},
+			Directories: []DirectoryCustomization{
+				{Path: "/etc/app", User: "admin", Group: int64(1000), Mode: "0750", EnsureParents: true},
+				{Path: "/srv/data"},
+			},
+			Files: []FileCustomization{
+				{Path: "/etc/app/config", User: int64(0), Group: "root", Mode: "0640", Data: "key = value\n"},
+				{Path: "/etc/app/ca.pem", Source: "https://example.com/ca.pem", Checksum: "sha256:" + strings.Repeat("0", 64)},
+			},
+			Repositories: []RepositoryCustomization{
+				{Id: "extras", BaseURLs: []string{"https://example.com/extras"}, Priority: common.ToPtr(10), Enabled: common.ToPtr(true), GPGCheck: common.ToPtr(false)},
+			},
+			Network: &NetworkCustomization{Connections: []NetworkConnectionCustomization{
+				{Name: "eth0", Addresses: []string{"192.168.0.2/24"}, Gateway: "192.168.0.1", DNS: []string{"192.168.0.1"}},
+			}},
+			Timesync: &TimesyncCustomization{Servers: []TimesyncServerCustomization{
+				{Hostname: "ntp.example.com", Iburst: common.ToPtr(true), Minpoll: common.ToPtr(-2)},
+			}},
+			Sysctl:           map[string]string{"vm.swappiness": "10"},
+			HostsEntries:     map[string][]string{"192.168.0.10": {"nas", "nas.lan"}},
+			Cron:             []CronJobCustomization{{Name: "backup", Schedule: "0 3 * * *", User: "root", Command: "/usr/local/bin/backup"}},
+			Installer:        &InstallerCustomization{TargetDisk: "sda", Reboot: true, Kickstart: &KickstartCustomization{Contents: "%post\necho done\n%end\n"}},
+			SELinux:          &SELinuxCustomization{Booleans: map[string]bool{"httpd_can_network_connect": true, "ftpd_full_access": false}},
+			EnabledModules:   []string{"postgresql:15/server"},
+			FIPS:             common.ToPtr(false),
+			CloudInit:        &CloudInitCustomization{UserData: "#cloud-config\nhostname: homelab\n"},
+			Bootloader:       BootloaderSystemdBoot,
+			Swap:             &SwapCustomization{Size: 2 * common.GibiByte, Type: SwapTypeFile},
+			UnallocatedSpace: common.ToPtr(uint64(common.GibiByte)),
+			Registries: []RegistryCustomization{
+				{Location: "registry.example.com", Insecure: true, Mirrors: []RegistryMirrorCustomization{{Location: "mirror.example.com:5000/team", Insecure: true}}},
+			},
+			ContainersPolicy: &ContainersPolicyCustomization{
+				Policy:              `{"default": [{"type": "reject"}]}`,
+				SigstoreAttachments: []string{"registry.example.com"},
+			},
+			DefaultTarget: "multi-user.target",
+			ReadOnlyRoot:  common.ToPtr(true),
+			DNFAutomatic:  &DNFAutomaticCustomization{Mode: DNFAutomaticModeDownload, UpgradeType: "security", Schedule: "Sun 03:00"},
+			Environment:   []string{"HTTP_PROXY=http://proxy.example.com:3128"},
+			CACerts:       &CACustomization{PEMCerts: []string{"-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"}},
+			RPMOSTree:     &RPMOSTreeCustomization{Install: []string{"kmod-nvidia"}, OverrideRemove: []string{"criu"}},
+			MachineID:     &MachineIDCustomization{Mode: MachineIDModeFixed, ID: strings.Repeat("0f", 16)},
+			ExtraMounts: []MountCustomization{
+				{Mountpoint: "/mnt/data", Source: "nas.example.com:/export/data", Type: "nfs", Options: "ro,_netdev"},
+				{Mountpoint: "/scratch", Source: "tmpfs", Type: "tmpfs"},
+			},
+			UdevRules:     []UdevRulesCustomization{{Filename: "70-persistent-net.rules", Rules: `SUBSYSTEM=="net", NAME="lan0"`}},
+			NetworkNaming: &NetworkNamingCustomization{Scheme: NetworkNamingSchemePredictable, NamePolicy: []string{"path", "mac"}},
+			FirstBoot:     &FirstBootCustomization{Script: "#!/bin/bash\necho first boot\n"},
+			Minimize:      &MinimizeCustomization{NoDocs: true, Locales: []string{"en", "de_DE"}},
+			Audit:         &AuditCustomization{Rules: []string{"-w /etc/sudoers -p wa -k scope"}},
+		},
+	}
+}
+
+// Ensure that the representative blueprint doesn't miss the customizations
+// that are added later
+func TestRepresentativeBlueprintSetsAllCustomizations(t *testing.T) {
+	customizations := reflect.ValueOf(*representativeBlueprint().Customizations)
+	for i := 0; i < customizations.NumField(); i++ {
+		assert.False(t, customizations.Field(i).IsZero(), "customization %s is not set", customizations.Type().Field(i).Name)
+	}
+}
+
+func TestBlueprintTOMLRoundTrip(t *testing.T) {
+	testCases := map[string]Blueprint{
+		"representative": representativeBlueprint(),
+		"empty":          {},
+		"empty customizations": {
+			Name:           "empty",
+			Customizations: &Customizations{},
+		},
+		"zero values": {
+			Customizations: &Customizations{
+				Kernel:     &KernelCustomization{},
+				Filesystem: []FilesystemCustomization{{Mountpoint: "/var"}},
+				User:       []UserCustomization{{Name: "root", UID: common.ToPtr(0)}},
+				FIPS:       common.ToPtr(true),
+			},
+		},
+	}
+
+	for name, expected := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// JSON -> struct
+			data, err := json.Marshal(expected)
+			require.NoError(t, err)
+			var fromJSON Blueprint
+			require.NoError(t, json.Unmarshal(data, &fromJSON), string(data))
+			require.Equal(t, expected, fromJSON)
+
+			// struct -> TOML -> struct
+			var buf bytes.Buffer
+			require.NoError(t, toml.NewEncoder(&buf).Encode(fromJSON))
+			var fromTOML Blueprint
+			_, err = toml.Decode(buf.String(), &fromTOML)
+			require.NoError(t, err, buf.String())
+			assert.Equal(t, expected, fromTOML, buf.String())
+		})
+	}
+}
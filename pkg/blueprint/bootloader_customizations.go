@@ -0,0 +1,21 @@
+package blueprint
+
+import "fmt"
+
+// Bootloaders that can be selected with the Bootloader customization
+const (
+	BootloaderGrub2       = "grub2"
+	BootloaderSystemdBoot = "systemd-boot"
+)
+
+// ValidateBootloaderCustomization validates the given Bootloader customization.
+// If the bootloader is unknown, an error is returned. Otherwise, nil is returned.
+// Whether the bootloader can be used with an image type depends on its boot
+// mode and is checked by the distro.
+func ValidateBootloaderCustomization(bootloader string) error {
+	switch bootloader {
+	case "", BootloaderGrub2, BootloaderSystemdBoot:
+		return nil
+	}
+	return fmt.Errorf("unsupported bootloader %q (supported: %s, %s)", bootloader, BootloaderGrub2, BootloaderSystemdBoot)
+}
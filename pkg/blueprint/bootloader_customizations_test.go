@@ -0,0 +1,15 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBootloaderCustomization(t *testing.T) {
+	for _, bootloader := range []string{"", "grub2", "systemd-boot"} {
+		assert.NoError(t, ValidateBootloaderCustomization(bootloader))
+	}
+	assert.EqualError(t, ValidateBootloaderCustomization("lilo"), `unsupported bootloader "lilo" (supported: grub2, systemd-boot)`)
+	assert.EqualError(t, ValidateBootloaderCustomization("systemd"), `unsupported bootloader "systemd" (supported: grub2, systemd-boot)`)
+}
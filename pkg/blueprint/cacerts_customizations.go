@@ -0,0 +1,136 @@
+package blueprint
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+// CACertsAnchorsDir is the directory of the CA certificates that the
+// system-wide trust store of the image trusts, see update-ca-trust(8).
+const CACertsAnchorsDir = "/etc/pki/ca-trust/source/anchors"
+
+// CACertsPackage provides the system-wide trust store and update-ca-trust.
+const CACertsPackage = "ca-certificates"
+
+// CACustomization adds certificate authorities to the trust store of the
+// image, e.g. the internal CAs of an organization.
+type CACustomization struct {
+	// PEM encoded CA certificates. Each entry may contain several
+	// certificates.
+	PEMCerts []string `json:"pem_certs,omitempty" toml:"pem_certs,omitempty"`
+}
+
+// parsePEMCerts returns the DER encoded certificates of a PEM blob.
+func parsePEMCerts(blob string) ([][]byte, error) {
+	var certs [][]byte
+	rest := []byte(blob)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("contains a %q block instead of a certificate", block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, fmt.Errorf("contains an invalid certificate: %w", err)
+		}
+		certs = append(certs, block.Bytes)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("contains no PEM encoded certificate")
+	}
+	if strings.TrimSpace(string(rest)) != "" {
+		return nil, fmt.Errorf("has extra data after the certificates")
+	}
+	return certs, nil
+}
+
+// caCertFingerprint returns the hex encoded SHA-256 fingerprint of the DER
+// encoded certificate.
+func caCertFingerprint(der []byte) string {
+	digest := sha256.Sum256(der)
+	return hex.EncodeToString(digest[:])
+}
+
+// ValidateCACustomization validates the given CACerts customization against
+// the files of the blueprint. If the customization is invalid, an error is
+// returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - Every entry is made of one or more PEM encoded X.509 certificates and
+// nothing else
+// - No certificate is listed twice
+// - No file of the Files customization is in the anchors directory
+func ValidateCACustomization(ca *CACustomization, files []FileCustomization) error {
+	if ca == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for idx, blob := range ca.PEMCerts {
+		certs, err := parsePEMCerts(blob)
+		if err != nil {
+			return fmt.Errorf("CA certificate %d %w", idx+1, err)
+		}
+		for _, der := range certs {
+			fingerprint := caCertFingerprint(der)
+			if seen[fingerprint] {
+				return fmt.Errorf("duplicate CA certificate with SHA-256 fingerprint %s", fingerprint)
+			}
+			seen[fingerprint] = true
+		}
+	}
+
+	if len(ca.PEMCerts) > 0 {
+		for _, file := range files {
+			if path.Dir(path.Clean(file.Path)) == CACertsAnchorsDir {
+				return fmt.Errorf("CA certificates cannot be combined with the custom file %s in %s", file.Path, CACertsAnchorsDir)
+			}
+		}
+	}
+
+	return nil
+}
+
+// CACustomizationToFsNodeFiles converts the CACerts customization to one
+// anchor file per certificate, named after the SHA-256 fingerprint of the
+// certificate. The trust store must be updated with update-ca-trust
+// afterwards.
+func CACustomizationToFsNodeFiles(ca *CACustomization) ([]*fsnode.File, error) {
+	if ca == nil || len(ca.PEMCerts) == 0 {
+		return nil, nil
+	}
+
+	if err := ValidateCACustomization(ca, nil); err != nil {
+		return nil, err
+	}
+
+	var files []*fsnode.File
+	for _, blob := range ca.PEMCerts {
+		certs, err := parsePEMCerts(blob)
+		if err != nil {
+			return nil, err
+		}
+		for _, der := range certs {
+			data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+			filePath := path.Join(CACertsAnchorsDir, caCertFingerprint(der)+".pem")
+			file, err := fsnode.NewFile(filePath, common.ToPtr(os.FileMode(0644)), "root", "root", data)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,174 @@
+package blueprint
+
+import (
+	"path"
+	"reflect"
+	"sort"
+)
+
+// Canonicalize returns a copy of the blueprint in a canonical form, so that
+// blueprints that describe the same image are equal and serialize the same,
+// e.g. to compute a content hash. The blueprint itself is not modified.
+//
+// In the canonical form:
+// - Lists whose order does not matter (packages, modules, groups, containers,
+// excluded packages, the groups of a user, SSH keys, services and the firewall
+// configuration) are sorted and duplicate packages, groups, SSH keys and
+// strings are removed.
+// Lists whose order matters, such as the filesystems, files, directories,
+// languages or NTP servers, are kept as they are. So are the users and the
+// user groups, unless they all have an explicit UID, respectively GID: they
+// are created in order, which determines the IDs they are assigned
+// otherwise.
+// - Empty lists and maps are nil, and so are the customizations if empty.
+// - Mountpoints are cleaned, e.g. "/var//log/" becomes "/var/log". Note that
+// the distros reject blueprints with such mountpoints, so the canonical form
+// of an invalid blueprint can be valid.
+func (b *Blueprint) Canonicalize() Blueprint {
+	c := deepCopy(reflect.ValueOf(*b)).Interface().(Blueprint)
+
+	c.Packages = canonicalPackages(c.Packages)
+	c.Modules = canonicalPackages(c.Modules)
+	sort.Slice(c.Groups, func(i, j int) bool { return c.Groups[i].Name < c.Groups[j].Name })
+	c.Groups = compact(c.Groups)
+	sort.SliceStable(c.Containers, func(i, j int) bool {
+		if c.Containers[i].Source != c.Containers[j].Source {
+			return c.Containers[i].Source < c.Containers[j].Source
+		}
+		return c.Containers[i].Name < c.Containers[j].Name
+	})
+	c.ExcludePackages = canonicalStrings(c.ExcludePackages)
+
+	if c.Customizations != nil {
+		c.Customizations.canonicalize()
+	}
+
+	trimEmpty(reflect.ValueOf(&c).Elem())
+	if c.Customizations != nil && reflect.ValueOf(*c.Customizations).IsZero() {
+		c.Customizations = nil
+	}
+	return c
+}
+
+func (c *Customizations) canonicalize() {
+	sort.SliceStable(c.SSHKey, func(i, j int) bool {
+		if c.SSHKey[i].User != c.SSHKey[j].User {
+			return c.SSHKey[i].User < c.SSHKey[j].User
+		}
+		return c.SSHKey[i].Key < c.SSHKey[j].Key
+	})
+	c.SSHKey = compact(c.SSHKey)
+
+	for idx := range c.User {
+		c.User[idx].Groups = canonicalStrings(c.User[idx].Groups)
+	}
+	if allUsersHaveUID(c.User) {
+		sort.SliceStable(c.User, func(i, j int) bool { return c.User[i].Name < c.User[j].Name })
+	}
+	if allGroupsHaveGID(c.Group) {
+		sort.SliceStable(c.Group, func(i, j int) bool { return c.Group[i].Name < c.Group[j].Name })
+	}
+
+	if c.Firewall != nil {
+		c.Firewall.Ports = canonicalStrings(c.Firewall.Ports)
+		if c.Firewall.Services != nil {
+			c.Firewall.Services.Enabled = canonicalStrings(c.Firewall.Services.Enabled)
+			c.Firewall.Services.Disabled = canonicalStrings(c.Firewall.Services.Disabled)
+		}
+		for idx := range c.Firewall.Zones {
+			c.Firewall.Zones[idx].Sources = canonicalStrings(c.Firewall.Zones[idx].Sources)
+		}
+		sort.SliceStable(c.Firewall.Zones, func(i, j int) bool {
+			return zoneName(c.Firewall.Zones[i]) < zoneName(c.Firewall.Zones[j])
+		})
+	}
+
+	if c.Services != nil {
+		c.Services.Enabled = canonicalStrings(c.Services.Enabled)
+		c.Services.Disabled = canonicalStrings(c.Services.Disabled)
+	}
+
+	for idx := range c.Filesystem {
+		c.Filesystem[idx].Mountpoint = path.Clean(c.Filesystem[idx].Mountpoint)
+	}
+}
+
+func allUsersHaveUID(users []UserCustomization) bool {
+	for _, user := range users {
+		if user.UID == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func allGroupsHaveGID(groups []GroupCustomization) bool {
+	for _, group := range groups {
+		if group.GID == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func zoneName(zone FirewallZoneCustomization) string {
+	if zone.Name == nil {
+		return ""
+	}
+	return *zone.Name
+}
+
+func canonicalPackages(packages []Package) []Package {
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Name != packages[j].Name {
+			return packages[i].Name < packages[j].Name
+		}
+		return packages[i].Version < packages[j].Version
+	})
+	return compact(packages)
+}
+
+func canonicalStrings(list []string) []string {
+	sort.Strings(list)
+	return compact(list)
+}
+
+// compact removes consecutive duplicates from a sorted list.
+func compact[T any](list []T) []T {
+	if len(list) == 0 {
+		return list
+	}
+	compacted := list[:1]
+	for _, entry := range list[1:] {
+		if !reflect.DeepEqual(entry, compacted[len(compacted)-1]) {
+			compacted = append(compacted, entry)
+		}
+	}
+	return compacted
+}
+
+// trimEmpty replaces the empty lists and maps below the value with nil.
+func trimEmpty(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for idx := 0; idx < v.NumField(); idx++ {
+			trimEmpty(v.Field(idx))
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			trimEmpty(v.Elem())
+		}
+	case reflect.Slice:
+		if v.Len() == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		for idx := 0; idx < v.Len(); idx++ {
+			trimEmpty(v.Index(idx))
+		}
+	case reflect.Map:
+		if v.Len() == 0 {
+			v.Set(reflect.Zero(v.Type()))
+		}
+	}
+}
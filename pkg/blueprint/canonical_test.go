@@ -0,0 +1,119 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/internal/common"
+)
+
+func TestCanonicalizeEquivalentBlueprints(t *testing.T) {
+	bp1 := Blueprint{
+		Name:     "canonical",
+		Packages: []Package{{Name: "vim"}, {Name: "tmux", Version: "3.3a"}, {Name: "vim"}},
+		Groups:   []Group{{Name: "core"}, {Name: "base"}},
+		Customizations: &Customizations{
+			User: []UserCustomization{
+				{Name: "zoe", UID: common.ToPtr(1001), Groups: []string{"wheel", "adm"}},
+				{Name: "alice", UID: common.ToPtr(1000)},
+			},
+			Firewall: &FirewallCustomization{
+				Ports:    []string{"443:tcp", "22:tcp"},
+				Services: &FirewallServicesCustomization{Enabled: []string{"ssh", "https", "ssh"}},
+			},
+			Services: &ServicesCustomization{Enabled: []string{"sshd", "chronyd"}},
+			Locale:   &LocaleCustomization{Languages: []string{"en_US.UTF-8", "de_DE.UTF-8"}},
+			Sysctl:   map[string]string{},
+		},
+	}
+	bp2 := Blueprint{
+		Name:     "canonical",
+		Packages: []Package{{Name: "tmux", Version: "3.3a"}, {Name: "vim"}},
+		Modules:  []Package{},
+		Groups:   []Group{{Name: "base"}, {Name: "core"}},
+		Customizations: &Customizations{
+			User: []UserCustomization{
+				{Name: "alice", UID: common.ToPtr(1000)},
+				{Name: "zoe", UID: common.ToPtr(1001), Groups: []string{"adm", "wheel"}},
+			},
+			Firewall: &FirewallCustomization{
+				Ports:    []string{"22:tcp", "443:tcp"},
+				Services: &FirewallServicesCustomization{Enabled: []string{"https", "ssh"}},
+			},
+			Services: &ServicesCustomization{Enabled: []string{"chronyd", "sshd"}},
+			Locale:   &LocaleCustomization{Languages: []string{"en_US.UTF-8", "de_DE.UTF-8"}},
+		},
+	}
+	assert.NotEqual(t, bp1, bp2)
+
+	canonical := bp1.Canonicalize()
+	assert.Equal(t, canonical, bp2.Canonicalize())
+	assert.Equal(t, []Package{{Name: "tmux", Version: "3.3a"}, {Name: "vim"}}, canonical.Packages)
+	assert.Nil(t, canonical.Modules)
+	assert.Nil(t, canonical.Customizations.Sysctl)
+	// the order of the languages matters, the first one is the default
+	assert.Equal(t, []string{"en_US.UTF-8", "de_DE.UTF-8"}, canonical.Customizations.Locale.Languages)
+
+	// the canonical form is stable
+	assert.Equal(t, canonical, canonical.Canonicalize())
+
+	// the blueprint itself is not modified
+	assert.Equal(t, "vim", bp1.Packages[0].Name)
+	assert.Equal(t, []string{"443:tcp", "22:tcp"}, bp1.Customizations.Firewall.Ports)
+}
+
+func TestCanonicalizeUsersOrder(t *testing.T) {
+	// without explicit IDs, the users and groups get their IDs in the order
+	// they are created, so that order is kept
+	bp := Blueprint{
+		Customizations: &Customizations{
+			User:  []UserCustomization{{Name: "zoe"}, {Name: "alice", UID: common.ToPtr(1000)}},
+			Group: []GroupCustomization{{Name: "web"}, {Name: "db"}},
+		},
+	}
+	canonical := bp.Canonicalize()
+	assert.Equal(t, bp.Customizations.User, canonical.Customizations.User)
+	assert.Equal(t, bp.Customizations.Group, canonical.Customizations.Group)
+
+	bp.Customizations.Group = []GroupCustomization{{Name: "web", GID: common.ToPtr(2001)}, {Name: "db", GID: common.ToPtr(2000)}}
+	assert.Equal(t, []GroupCustomization{{Name: "db", GID: common.ToPtr(2000)}, {Name: "web", GID: common.ToPtr(2001)}}, bp.Canonicalize().Customizations.Group)
+}
+
+func TestCanonicalizeEmptyCustomizations(t *testing.T) {
+	bp := Blueprint{
+		Name: "empty",
+		Customizations: &Customizations{
+			SSHKey: []SSHKeyCustomization{},
+			Sysctl: map[string]string{},
+		},
+	}
+	assert.Equal(t, Blueprint{Name: "empty"}, bp.Canonicalize())
+
+	bp = Blueprint{Customizations: &Customizations{Hostname: common.ToPtr("")}}
+	assert.NotNil(t, bp.Canonicalize().Customizations)
+}
+
+func TestCanonicalizeMountpoints(t *testing.T) {
+	dirty := Blueprint{
+		Customizations: &Customizations{
+			Filesystem: []FilesystemCustomization{
+				{Mountpoint: "//", MinSize: 1024},
+				{Mountpoint: "/var//", MinSize: 1024},
+				{Mountpoint: "/var//log/audit/", MinSize: 1024},
+			},
+		},
+	}
+	clean := Blueprint{
+		Customizations: &Customizations{
+			Filesystem: []FilesystemCustomization{
+				{Mountpoint: "/", MinSize: 1024},
+				{Mountpoint: "/var", MinSize: 1024},
+				{Mountpoint: "/var/log/audit", MinSize: 1024},
+			},
+		},
+	}
+	assert.Equal(t, clean, dirty.Canonicalize())
+	assert.Equal(t, clean.Canonicalize(), dirty.Canonicalize())
+	assert.Equal(t, "/var//", dirty.Customizations.Filesystem[1].Mountpoint)
+}
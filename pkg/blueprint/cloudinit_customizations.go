@@ -0,0 +1,205 @@
+package blueprint
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+// CloudInitSeedDir is the seed directory of the cloud-init NoCloud datasource
+const CloudInitSeedDir = "/var/lib/cloud/seed/nocloud"
+
+// CloudInitDatasourcesConfigPath is the cloud.cfg.d drop-in that restricts
+// the datasources of the CloudInit customization.
+const CloudInitDatasourcesConfigPath = "/etc/cloud/cloud.cfg.d/90-blueprint-datasources.cfg"
+
+// The datasources of cloud-init, mapped to whether they are looked up over
+// the network, e.g. from the metadata service of a cloud, instead of from a
+// local device
+var cloudInitDatasources = map[string]bool{
+	"Akamai":       true,
+	"AliYun":       true,
+	"AltCloud":     false,
+	"Azure":        true,
+	"Bigstep":      true,
+	"CloudSigma":   false,
+	"CloudStack":   true,
+	"ConfigDrive":  false,
+	"DigitalOcean": true,
+	"E24Cloud":     true,
+	"Ec2":          true,
+	"Exoscale":     true,
+	"GCE":          true,
+	"Hetzner":      true,
+	"IBMCloud":     false,
+	"LXD":          false,
+	"MAAS":         true,
+	"NWCS":         true,
+	"NoCloud":      false,
+	"None":         false,
+	"OVF":          false,
+	"OpenNebula":   false,
+	"OpenStack":    true,
+	"Oracle":       true,
+	"RbxCloud":     false,
+	"Scaleway":     true,
+	"SmartOS":      false,
+	"UpCloud":      true,
+	"VMware":       false,
+	"Vultr":        true,
+	"WSL":          false,
+}
+
+// CloudInitCustomization defines a cloud-init NoCloud seed that is embedded
+// in the image, so that it is configured on boot without an external
+// datasource, and the datasources that cloud-init looks for.
+type CloudInitCustomization struct {
+	// Contents of the user-data file, a cloud-config YAML document
+	UserData string `json:"user_data,omitempty" toml:"user_data,omitempty"`
+	// Contents of the meta-data file, a YAML document, e.g. with the
+	// instance-id and local-hostname keys
+	MetaData string `json:"meta_data,omitempty" toml:"meta_data,omitempty"`
+	// Datasources that cloud-init tries, in order, instead of all the
+	// datasources it supports, e.g. ["NoCloud", "None"]
+	DatasourceList []string `json:"datasource_list,omitempty" toml:"datasource_list,omitempty"`
+	// Don't try the datasources that are looked up over the network. Without
+	// a datasource list, cloud-init is restricted to all the local ones.
+	DisableNetworkDatasources bool `json:"disable_network_datasources,omitempty" toml:"disable_network_datasources,omitempty"`
+}
+
+// HasSeed returns true if the customization defines a NoCloud seed.
+func (ci *CloudInitCustomization) HasSeed() bool {
+	return ci != nil && (ci.UserData != "" || ci.MetaData != "")
+}
+
+// datasources returns the datasource list of the customization, nil if
+// cloud-init is not restricted.
+func (ci *CloudInitCustomization) datasources() []string {
+	if len(ci.DatasourceList) > 0 || !ci.DisableNetworkDatasources {
+		return ci.DatasourceList
+	}
+	var local []string
+	for name, network := range cloudInitDatasources {
+		if !network && name != "None" {
+			local = append(local, name)
+		}
+	}
+	sort.Strings(local)
+	// None is the fallback when no other datasource is found
+	return append(local, "None")
+}
+
+// ValidateCloudInitCustomization validates the given cloud-init
+// customization. If the customization is invalid, an error is returned.
+// Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - The user-data is set, unless only the datasources are configured, and is
+// a valid YAML mapping
+// - The meta-data, if set, is a valid YAML mapping
+// - The datasources are known to cloud-init, listed once and not looked up
+// over the network if the network datasources are disabled
+func ValidateCloudInitCustomization(ci *CloudInitCustomization) error {
+	if ci == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(ci.DatasourceList))
+	for _, name := range ci.DatasourceList {
+		network, known := cloudInitDatasources[name]
+		if !known {
+			return fmt.Errorf("unknown cloud-init datasource %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate cloud-init datasource %q", name)
+		}
+		seen[name] = true
+		if network && ci.DisableNetworkDatasources {
+			return fmt.Errorf("cloud-init datasource %q is looked up over the network, but the network datasources are disabled", name)
+		}
+	}
+
+	if !ci.HasSeed() && (len(ci.DatasourceList) > 0 || ci.DisableNetworkDatasources) {
+		return nil
+	}
+	if ci.UserData == "" {
+		return fmt.Errorf("cloud-init user-data is required")
+	}
+	var userData map[string]interface{}
+	if err := yaml.Unmarshal([]byte(ci.UserData), &userData); err != nil {
+		return fmt.Errorf("cloud-init user-data is not a valid YAML mapping: %w", err)
+	}
+
+	if ci.MetaData != "" {
+		var metaData map[string]interface{}
+		if err := yaml.Unmarshal([]byte(ci.MetaData), &metaData); err != nil {
+			return fmt.Errorf("cloud-init meta-data is not a valid YAML mapping: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CloudInitCustomizationToFsNodes converts the cloud-init customization to
+// the NoCloud seed directory and its user-data and meta-data files, if it
+// has a seed. The customization must have been validated.
+func CloudInitCustomizationToFsNodes(ci *CloudInitCustomization) (*fsnode.Directory, []*fsnode.File, error) {
+	if !ci.HasSeed() {
+		return nil, nil, nil
+	}
+
+	dir, err := fsnode.NewDirectory(CloudInitSeedDir, common.ToPtr(os.FileMode(0700)), "root", "root", true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seed := []struct {
+		name string
+		data string
+	}{
+		{"meta-data", ci.MetaData},
+		{"user-data", ci.UserData},
+	}
+	files := make([]*fsnode.File, 0, len(seed))
+	for _, f := range seed {
+		file, err := fsnode.NewFile(path.Join(CloudInitSeedDir, f.name), common.ToPtr(os.FileMode(0600)), "root", "root", []byte(f.data))
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, file)
+	}
+	return dir, files, nil
+}
+
+// CloudInitDatasourcesToFsNodes converts the datasources of the cloud-init
+// customization to a cloud.cfg.d drop-in with the datasource_list, if they
+// are restricted, and its directory, in case cloud-init is not part of the
+// image. The customization must have been validated.
+func CloudInitDatasourcesToFsNodes(ci *CloudInitCustomization) (*fsnode.Directory, *fsnode.File, error) {
+	if ci == nil {
+		return nil, nil, nil
+	}
+	datasources := ci.datasources()
+	if len(datasources) == 0 {
+		return nil, nil, nil
+	}
+
+	// no mode, so that the directory of the cloud-init package is kept as is
+	dir, err := fsnode.NewDirectory(path.Dir(CloudInitDatasourcesConfigPath), nil, nil, nil, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := fmt.Sprintf("datasource_list: [%s]\n", strings.Join(datasources, ", "))
+	file, err := fsnode.NewFile(CloudInitDatasourcesConfigPath, common.ToPtr(os.FileMode(0644)), "root", "root", []byte(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	return dir, file, nil
+}
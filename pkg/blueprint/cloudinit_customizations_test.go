@@ -0,0 +1,131 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCloudInitCustomization(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cloudInit *CloudInitCustomization
+		wantErr   string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "valid",
+			cloudInit: &CloudInitCustomization{
+				UserData: "#cloud-config\nusers:\n  - name: admin\n    ssh_authorized_keys:\n      - ssh-ed25519 AAAA\n",
+				MetaData: "instance-id: homelab-01\nlocal-hostname: homelab\n",
+			},
+		},
+		{
+			name:      "no user-data",
+			cloudInit: &CloudInitCustomization{MetaData: "instance-id: homelab-01\n"},
+			wantErr:   "cloud-init user-data is required",
+		},
+		{
+			name:      "invalid user-data",
+			cloudInit: &CloudInitCustomization{UserData: "#cloud-config\nusers: [admin\n"},
+			wantErr:   "cloud-init user-data is not a valid YAML mapping: yaml: line 1: did not find expected ',' or ']'",
+		},
+		{
+			name:      "user-data not a mapping",
+			cloudInit: &CloudInitCustomization{UserData: "- admin\n"},
+			wantErr:   "cloud-init user-data is not a valid YAML mapping: yaml: unmarshal errors:\n  line 1: cannot unmarshal !!seq into map[string]interface {}",
+		},
+		{
+			name:      "datasources only",
+			cloudInit: &CloudInitCustomization{DatasourceList: []string{"NoCloud", "ConfigDrive", "None"}},
+		},
+		{
+			name:      "network datasources disabled",
+			cloudInit: &CloudInitCustomization{DisableNetworkDatasources: true},
+		},
+		{
+			name:      "unknown datasource",
+			cloudInit: &CloudInitCustomization{DatasourceList: []string{"EC2"}},
+			wantErr:   `unknown cloud-init datasource "EC2"`,
+		},
+		{
+			name:      "duplicate datasource",
+			cloudInit: &CloudInitCustomization{DatasourceList: []string{"Ec2", "Ec2"}},
+			wantErr:   `duplicate cloud-init datasource "Ec2"`,
+		},
+		{
+			name:      "disabled network datasource",
+			cloudInit: &CloudInitCustomization{DatasourceList: []string{"NoCloud", "Ec2"}, DisableNetworkDatasources: true},
+			wantErr:   `cloud-init datasource "Ec2" is looked up over the network, but the network datasources are disabled`,
+		},
+		{
+			name:      "datasources with meta-data only",
+			cloudInit: &CloudInitCustomization{MetaData: "instance-id: homelab-01\n", DatasourceList: []string{"NoCloud"}},
+			wantErr:   "cloud-init user-data is required",
+		},
+		{
+			name:      "invalid meta-data",
+			cloudInit: &CloudInitCustomization{UserData: "#cloud-config\n", MetaData: "instance-id: [\n"},
+			wantErr:   "cloud-init meta-data is not a valid YAML mapping: yaml: line 1: did not find expected node content",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateCloudInitCustomization(tc.cloudInit)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCloudInitCustomizationToFsNodes(t *testing.T) {
+	dir, files, err := CloudInitCustomizationToFsNodes(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, dir)
+	assert.Nil(t, files)
+
+	dir, files, err = CloudInitCustomizationToFsNodes(&CloudInitCustomization{DatasourceList: []string{"NoCloud"}})
+	assert.NoError(t, err)
+	assert.Nil(t, dir)
+	assert.Nil(t, files)
+
+	dir, files, err = CloudInitCustomizationToFsNodes(&CloudInitCustomization{
+		UserData: "#cloud-config\nhostname: homelab\n",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/lib/cloud/seed/nocloud", dir.Path())
+	assert.Equal(t, os.FileMode(0700), *dir.Mode())
+	assert.Len(t, files, 2)
+	assert.Equal(t, "/var/lib/cloud/seed/nocloud/meta-data", files[0].Path())
+	assert.Empty(t, files[0].Data())
+	assert.Equal(t, "/var/lib/cloud/seed/nocloud/user-data", files[1].Path())
+	assert.Equal(t, "#cloud-config\nhostname: homelab\n", string(files[1].Data()))
+	for _, file := range files {
+		assert.Equal(t, os.FileMode(0600), *file.Mode())
+	}
+}
+
+func TestCloudInitDatasourcesToFsNodes(t *testing.T) {
+	dir, file, err := CloudInitDatasourcesToFsNodes(&CloudInitCustomization{UserData: "#cloud-config\n"})
+	assert.NoError(t, err)
+	assert.Nil(t, dir)
+	assert.Nil(t, file)
+
+	dir, file, err = CloudInitDatasourcesToFsNodes(&CloudInitCustomization{DatasourceList: []string{"ConfigDrive", "NoCloud"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/cloud/cloud.cfg.d", dir.Path())
+	assert.Nil(t, dir.Mode())
+	assert.Equal(t, "/etc/cloud/cloud.cfg.d/90-blueprint-datasources.cfg", file.Path())
+	assert.Equal(t, "datasource_list: [ConfigDrive, NoCloud]\n", string(file.Data()))
+
+	_, file, err = CloudInitDatasourcesToFsNodes(&CloudInitCustomization{DisableNetworkDatasources: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "datasource_list: [AltCloud, CloudSigma, ConfigDrive, IBMCloud, LXD, NoCloud, OVF, OpenNebula, RbxCloud, SmartOS, VMware, WSL, None]\n", string(file.Data()))
+}
@@ -0,0 +1,195 @@
+package blueprint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+// ContainersPolicyPath is the signature verification policy of the container
+// tools, see containers-policy.json(5).
+const ContainersPolicyPath = "/etc/containers/policy.json"
+
+// ContainersSigstoreConfigPath is the containers-registries.d drop-in that
+// enables the sigstore attachments of the ContainersPolicy customization.
+const ContainersSigstoreConfigPath = "/etc/containers/registries.d/90-blueprint.yaml"
+
+// ContainersPolicyCustomization configures the verification of the
+// signatures of the container images pulled on the image.
+type ContainersPolicyCustomization struct {
+	// Content of /etc/containers/policy.json in the containers-policy.json(5)
+	// format. The public keys it refers to by path must be part of the image,
+	// e.g. added with the Files customization.
+	Policy string `json:"policy" toml:"policy"`
+	// Registries or namespaces, as host[:port][/namespace], that store the
+	// sigstore signatures of their images as attachments
+	SigstoreAttachments []string `json:"sigstore_attachments,omitempty" toml:"sigstore_attachments,omitempty"`
+}
+
+// A policy requirement, only with the fields that are validated
+type containersPolicyRequirement struct {
+	Type     string          `json:"type"`
+	KeyType  string          `json:"keyType"`
+	KeyPath  string          `json:"keyPath"`
+	KeyPaths []string        `json:"keyPaths"`
+	KeyData  string          `json:"keyData"`
+	KeyDatas []string        `json:"keyDatas"`
+	Fulcio   json.RawMessage `json:"fulcio"`
+}
+
+type containersPolicy struct {
+	Default    []containersPolicyRequirement                       `json:"default"`
+	Transports map[string]map[string][]containersPolicyRequirement `json:"transports"`
+}
+
+func validateContainersPolicyRequirements(scope string, requirements []containersPolicyRequirement) error {
+	if len(requirements) == 0 {
+		return fmt.Errorf("containers policy %s has no requirements", scope)
+	}
+	for _, req := range requirements {
+		keys := 0
+		for _, set := range []bool{req.KeyPath != "", len(req.KeyPaths) > 0, req.KeyData != "", len(req.KeyDatas) > 0} {
+			if set {
+				keys++
+			}
+		}
+		for _, keyPath := range append([]string{req.KeyPath}, req.KeyPaths...) {
+			if keyPath != "" && !path.IsAbs(keyPath) {
+				return fmt.Errorf("containers policy %s has a relative key path %q", scope, keyPath)
+			}
+		}
+
+		switch req.Type {
+		case "insecureAcceptAnything", "reject":
+		case "signedBy":
+			if req.KeyType != "GPGKeys" {
+				return fmt.Errorf("containers policy %s has a signedBy requirement with unsupported key type %q", scope, req.KeyType)
+			}
+			if keys != 1 {
+				return fmt.Errorf("containers policy %s has a signedBy requirement without exactly one of keyPath, keyPaths, keyData and keyDatas", scope)
+			}
+		case "sigstoreSigned":
+			if len(req.Fulcio) > 0 {
+				keys++
+			}
+			if keys != 1 {
+				return fmt.Errorf("containers policy %s has a sigstoreSigned requirement without exactly one of keyPath, keyPaths, keyData, keyDatas and fulcio", scope)
+			}
+		default:
+			return fmt.Errorf("containers policy %s has a requirement with unsupported type %q", scope, req.Type)
+		}
+	}
+	return nil
+}
+
+// ValidateContainersPolicyCustomization validates the given ContainersPolicy
+// customization against the files of the blueprint. If the customization is
+// invalid, an error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - The policy is a JSON object with a non-empty default requirement list and
+// non-empty requirement lists for every scope of the transports
+// - Every requirement has a known type and the keys that the type requires
+// - Key paths are absolute
+// - The policy file is not also defined in the Files customization
+// - Sigstore attachment locations are host[:port][/namespace] and unique
+func ValidateContainersPolicyCustomization(cp *ContainersPolicyCustomization, files []FileCustomization) error {
+	if cp == nil {
+		return nil
+	}
+
+	var policy containersPolicy
+	dec := json.NewDecoder(strings.NewReader(cp.Policy))
+	if err := dec.Decode(&policy); err != nil {
+		return fmt.Errorf("containers policy is not a valid policy.json: %w", err)
+	}
+	if dec.More() {
+		return fmt.Errorf("containers policy has extra data after the JSON object")
+	}
+	if err := validateContainersPolicyRequirements("default", policy.Default); err != nil {
+		return err
+	}
+	transports := make([]string, 0, len(policy.Transports))
+	for transport := range policy.Transports {
+		transports = append(transports, transport)
+	}
+	sort.Strings(transports)
+	for _, transport := range transports {
+		scopes := make([]string, 0, len(policy.Transports[transport]))
+		for scope := range policy.Transports[transport] {
+			scopes = append(scopes, scope)
+		}
+		sort.Strings(scopes)
+		for _, scope := range scopes {
+			name := fmt.Sprintf("transport %q scope %q", transport, scope)
+			if err := validateContainersPolicyRequirements(name, policy.Transports[transport][scope]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, file := range files {
+		if path.Clean(file.Path) == ContainersPolicyPath {
+			return fmt.Errorf("containers policy cannot be combined with a custom %s file", ContainersPolicyPath)
+		}
+	}
+
+	seen := make(map[string]bool, len(cp.SigstoreAttachments))
+	for _, location := range cp.SigstoreAttachments {
+		if err := validateRegistryLocation(location); err != nil {
+			return fmt.Errorf("sigstore attachments: %w", err)
+		}
+		if seen[location] {
+			return fmt.Errorf("duplicate sigstore attachments location %q", location)
+		}
+		seen[location] = true
+	}
+
+	return nil
+}
+
+// ContainersPolicyCustomizationToFsNodeFiles converts the ContainersPolicy
+// customization to the policy.json file and, if any registry uses sigstore
+// attachments, a containers-registries.d configuration. The policy is
+// written as is.
+func ContainersPolicyCustomizationToFsNodeFiles(cp *ContainersPolicyCustomization) ([]*fsnode.File, error) {
+	if cp == nil {
+		return nil, nil
+	}
+
+	if err := ValidateContainersPolicyCustomization(cp, nil); err != nil {
+		return nil, err
+	}
+
+	policy := cp.Policy
+	if !strings.HasSuffix(policy, "\n") {
+		policy += "\n"
+	}
+	policyFile, err := fsnode.NewFile(ContainersPolicyPath, common.ToPtr(os.FileMode(0644)), "root", "root", []byte(policy))
+	if err != nil {
+		return nil, err
+	}
+	files := []*fsnode.File{policyFile}
+
+	if len(cp.SigstoreAttachments) > 0 {
+		var b bytes.Buffer
+		b.WriteString("docker:\n")
+		for _, location := range cp.SigstoreAttachments {
+			fmt.Fprintf(&b, "  %q:\n    use-sigstore-attachments: true\n", location)
+		}
+		sigstoreFile, err := fsnode.NewFile(ContainersSigstoreConfigPath, common.ToPtr(os.FileMode(0644)), "root", "root", b.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, sigstoreFile)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,150 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testContainersPolicy = `{
+  "default": [{"type": "reject"}],
+  "transports": {
+    "docker": {
+      "registry.example.com": [{"type": "sigstoreSigned", "keyPath": "/etc/pki/containers/example.pub", "signedIdentity": {"type": "matchRepository"}}],
+      "quay.io/fedora": [{"type": "signedBy", "keyType": "GPGKeys", "keyPaths": ["/etc/pki/rpm-gpg/RPM-GPG-KEY-fedora"]}]
+    },
+    "docker-daemon": {"": [{"type": "insecureAcceptAnything"}]}
+  }
+}`
+
+func TestContainersPolicyCustomizationToFsNodeFiles(t *testing.T) {
+	files, err := ContainersPolicyCustomizationToFsNodeFiles(&ContainersPolicyCustomization{
+		Policy:              testContainersPolicy,
+		SigstoreAttachments: []string{"registry.example.com", "[fd00::1]:5000/team"},
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	assert.Equal(t, "/etc/containers/policy.json", files[0].Path())
+	assert.Equal(t, testContainersPolicy+"\n", string(files[0].Data()))
+	assert.Equal(t, "/etc/containers/registries.d/90-blueprint.yaml", files[1].Path())
+	assert.Equal(t, `docker:
+  "registry.example.com":
+    use-sigstore-attachments: true
+  "[fd00::1]:5000/team":
+    use-sigstore-attachments: true
+`, string(files[1].Data()))
+	for _, file := range files {
+		require.NotNil(t, file.Mode())
+		assert.Equal(t, os.FileMode(0644), *file.Mode())
+		assert.Equal(t, "root", file.User())
+		assert.Equal(t, "root", file.Group())
+	}
+
+	files, err = ContainersPolicyCustomizationToFsNodeFiles(&ContainersPolicyCustomization{Policy: `{"default": [{"type": "insecureAcceptAnything"}]}` + "\n"})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, `{"default": [{"type": "insecureAcceptAnything"}]}`+"\n", string(files[0].Data()))
+}
+
+func TestContainersPolicyCustomizationToFsNodeFilesEmpty(t *testing.T) {
+	files, err := ContainersPolicyCustomizationToFsNodeFiles(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, files)
+}
+
+func TestValidateContainersPolicyCustomization(t *testing.T) {
+	testCases := []struct {
+		name    string
+		policy  *ContainersPolicyCustomization
+		files   []FileCustomization
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:   "valid",
+			policy: &ContainersPolicyCustomization{Policy: testContainersPolicy, SigstoreAttachments: []string{"registry.example.com"}},
+		},
+		{
+			name:    "not JSON",
+			policy:  &ContainersPolicyCustomization{Policy: `default = "reject"`},
+			wantErr: `containers policy is not a valid policy.json: invalid character 'd' looking for beginning of value`,
+		},
+		{
+			name:    "not an object",
+			policy:  &ContainersPolicyCustomization{Policy: `[{"type": "reject"}]`},
+			wantErr: `containers policy is not a valid policy.json: json: cannot unmarshal array into Go value of type blueprint.containersPolicy`,
+		},
+		{
+			name:    "extra data",
+			policy:  &ContainersPolicyCustomization{Policy: `{"default": [{"type": "reject"}]} {}`},
+			wantErr: `containers policy has extra data after the JSON object`,
+		},
+		{
+			name:    "no default",
+			policy:  &ContainersPolicyCustomization{Policy: `{"transports": {}}`},
+			wantErr: `containers policy default has no requirements`,
+		},
+		{
+			name:    "empty scope",
+			policy:  &ContainersPolicyCustomization{Policy: `{"default": [{"type": "reject"}], "transports": {"docker": {"quay.io": []}}}`},
+			wantErr: `containers policy transport "docker" scope "quay.io" has no requirements`,
+		},
+		{
+			name:    "unknown type",
+			policy:  &ContainersPolicyCustomization{Policy: `{"default": [{"type": "acceptAnything"}]}`},
+			wantErr: `containers policy default has a requirement with unsupported type "acceptAnything"`,
+		},
+		{
+			name:    "signedBy without key type",
+			policy:  &ContainersPolicyCustomization{Policy: `{"default": [{"type": "signedBy", "keyPath": "/etc/key.gpg"}]}`},
+			wantErr: `containers policy default has a signedBy requirement with unsupported key type ""`,
+		},
+		{
+			name:    "signedBy without key",
+			policy:  &ContainersPolicyCustomization{Policy: `{"default": [{"type": "signedBy", "keyType": "GPGKeys"}]}`},
+			wantErr: `containers policy default has a signedBy requirement without exactly one of keyPath, keyPaths, keyData and keyDatas`,
+		},
+		{
+			name:    "sigstoreSigned with two keys",
+			policy:  &ContainersPolicyCustomization{Policy: `{"default": [{"type": "sigstoreSigned", "keyPath": "/etc/key.pub", "fulcio": {}}]}`},
+			wantErr: `containers policy default has a sigstoreSigned requirement without exactly one of keyPath, keyPaths, keyData, keyDatas and fulcio`,
+		},
+		{
+			name:    "relative key path",
+			policy:  &ContainersPolicyCustomization{Policy: `{"default": [{"type": "sigstoreSigned", "keyPath": "key.pub"}]}`},
+			wantErr: `containers policy default has a relative key path "key.pub"`,
+		},
+		{
+			name:    "custom policy file",
+			policy:  &ContainersPolicyCustomization{Policy: `{"default": [{"type": "reject"}]}`},
+			files:   []FileCustomization{{Path: "/etc/containers/policy.json"}},
+			wantErr: `containers policy cannot be combined with a custom /etc/containers/policy.json file`,
+		},
+		{
+			name:    "invalid sigstore attachments location",
+			policy:  &ContainersPolicyCustomization{Policy: `{"default": [{"type": "reject"}]}`, SigstoreAttachments: []string{"https://quay.io"}},
+			wantErr: `sigstore attachments: registry location "https://quay.io" must not include a URL scheme`,
+		},
+		{
+			name:    "duplicate sigstore attachments location",
+			policy:  &ContainersPolicyCustomization{Policy: `{"default": [{"type": "reject"}]}`, SigstoreAttachments: []string{"quay.io", "quay.io"}},
+			wantErr: `duplicate sigstore attachments location "quay.io"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateContainersPolicyCustomization(tc.policy, tc.files)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package blueprint
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+const CronDir = "/etc/cron.d"
+
+// cron ignores files in /etc/cron.d with dots in their names on some
+// distributions, so only allow a conservative set of characters
+var cronJobNameRegex = regexp.MustCompile(`^[\w-]{1,200}$`)
+
+// CronJobCustomization represents an entry in /etc/cron.d.
+type CronJobCustomization struct {
+	// Name of the file in /etc/cron.d
+	Name string `json:"name" toml:"name"`
+	// Schedule in the five-field cron format: minute, hour, day of month, month, day of week
+	Schedule string `json:"schedule" toml:"schedule"`
+	// User that runs the command, either root or a user from the User customizations
+	User string `json:"user" toml:"user"`
+	// Command to run
+	Command string `json:"command" toml:"command"`
+}
+
+// cronField describes the values allowed in one field of a cron schedule.
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names []string // names of the values starting at min, if any
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	// both 0 and 7 are Sunday
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+func (f cronField) parseValue(value string) (int, error) {
+	for idx, name := range f.names {
+		if strings.EqualFold(value, name) {
+			return f.min + idx, nil
+		}
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid %s %q: must be between %d and %d", f.name, value, f.min, f.max)
+	}
+	return n, nil
+}
+
+// validate checks a single field of a schedule: a comma separated list of
+// values, ranges, or '*', each optionally followed by a '/step'.
+func (f cronField) validate(field string) error {
+	for _, item := range strings.Split(field, ",") {
+		rng, step, hasStep := strings.Cut(item, "/")
+		if hasStep {
+			if n, err := strconv.Atoi(step); err != nil || n < 1 {
+				return fmt.Errorf("invalid %s step %q", f.name, step)
+			}
+		}
+		if rng == "*" {
+			continue
+		}
+		first, last, isRange := strings.Cut(rng, "-")
+		start, err := f.parseValue(first)
+		if err != nil {
+			return err
+		}
+		if !isRange {
+			if hasStep {
+				return fmt.Errorf("invalid %s %q: a step requires a range or '*'", f.name, item)
+			}
+			continue
+		}
+		end, err := f.parseValue(last)
+		if err != nil {
+			return err
+		}
+		if start > end {
+			return fmt.Errorf("invalid %s range %q", f.name, rng)
+		}
+	}
+	return nil
+}
+
+func validateCronSchedule(schedule string) error {
+	fields := strings.Fields(schedule)
+	if len(fields) != len(cronFields) {
+		return fmt.Errorf("schedule %q must have %d fields", schedule, len(cronFields))
+	}
+	for idx, field := range fields {
+		if err := cronFields[idx].validate(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateCronCustomization validates the given Cron customization against
+// the users of the blueprint. If the customization is invalid, an error is
+// returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - Job names are valid file names and unique
+// - Schedules are valid five-field cron schedules
+// - Jobs run as root or as a user defined in the blueprint
+// - Commands are non-empty and fit on a single line
+func ValidateCronCustomization(jobs []CronJobCustomization, users []UserCustomization) error {
+	knownUsers := map[string]bool{"root": true}
+	for _, user := range users {
+		knownUsers[user.Name] = true
+	}
+
+	names := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		if !cronJobNameRegex.MatchString(job.Name) {
+			return fmt.Errorf("cron job name %q is invalid", job.Name)
+		}
+		if names[job.Name] {
+			return fmt.Errorf("duplicate cron job name %q", job.Name)
+		}
+		names[job.Name] = true
+
+		if err := validateCronSchedule(job.Schedule); err != nil {
+			return fmt.Errorf("cron job %q: %v", job.Name, err)
+		}
+		if !knownUsers[job.User] {
+			return fmt.Errorf("cron job %q: user %q must be root or defined in the blueprint", job.Name, job.User)
+		}
+		if strings.TrimSpace(job.Command) == "" {
+			return fmt.Errorf("cron job %q: command is empty", job.Name)
+		}
+		if strings.ContainsAny(job.Command, "\r\n") {
+			return fmt.Errorf("cron job %q: command spans multiple lines", job.Name)
+		}
+	}
+	return nil
+}
+
+// CronCustomizationToFsNodeFiles converts the Cron customization to files in
+// /etc/cron.d, one for each job. The jobs must have been validated.
+func CronCustomizationToFsNodeFiles(jobs []CronJobCustomization) ([]*fsnode.File, error) {
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	files := make([]*fsnode.File, 0, len(jobs))
+	for _, job := range jobs {
+		data := fmt.Sprintf("%s %s %s\n", strings.Join(strings.Fields(job.Schedule), " "), job.User, strings.TrimSpace(job.Command))
+		file, err := fsnode.NewFile(path.Join(CronDir, job.Name), common.ToPtr(os.FileMode(0644)), "root", "root", []byte(data))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
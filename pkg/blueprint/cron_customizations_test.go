@@ -0,0 +1,157 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronCustomizationToFsNodeFiles(t *testing.T) {
+	jobs := []CronJobCustomization{
+		{
+			Name:     "backup",
+			Schedule: "30 2 * * 1-5",
+			User:     "root",
+			Command:  "/usr/local/bin/backup --full",
+		},
+		{
+			Name:     "report",
+			Schedule: " */15  8-18 1,15 jan-jun   mon ",
+			User:     "reporter",
+			Command:  " /usr/bin/report ",
+		},
+	}
+
+	files, err := CronCustomizationToFsNodeFiles(jobs)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	assert.Equal(t, "/etc/cron.d/backup", files[0].Path())
+	assert.Equal(t, "30 2 * * 1-5 root /usr/local/bin/backup --full\n", string(files[0].Data()))
+	assert.Equal(t, "/etc/cron.d/report", files[1].Path())
+	assert.Equal(t, "*/15 8-18 1,15 jan-jun mon reporter /usr/bin/report\n", string(files[1].Data()))
+
+	for _, file := range files {
+		require.NotNil(t, file.Mode())
+		assert.Equal(t, os.FileMode(0644), *file.Mode())
+		assert.Equal(t, "root", file.User())
+		assert.Equal(t, "root", file.Group())
+	}
+}
+
+func TestCronCustomizationToFsNodeFilesEmpty(t *testing.T) {
+	files, err := CronCustomizationToFsNodeFiles(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, files)
+}
+
+func TestValidateCronSchedule(t *testing.T) {
+	valid := []string{
+		"* * * * *",
+		"0 0 1 1 0",
+		"59 23 31 12 7",
+		"*/5 * * * *",
+		"0-30/10 9-17 * * mon-fri",
+		"0 12 1,15 JAN,Jul sun",
+		"5,10,15-20 * * * *",
+	}
+	for _, schedule := range valid {
+		assert.NoError(t, validateCronSchedule(schedule), schedule)
+	}
+
+	invalid := map[string]string{
+		"":              `schedule "" must have 5 fields`,
+		"* * * *":       `schedule "* * * *" must have 5 fields`,
+		"* * * * * *":   `schedule "* * * * * *" must have 5 fields`,
+		"@daily":        `schedule "@daily" must have 5 fields`,
+		"60 * * * *":    `invalid minute "60": must be between 0 and 59`,
+		"* 24 * * *":    `invalid hour "24": must be between 0 and 23`,
+		"* * 0 * *":     `invalid day of month "0": must be between 1 and 31`,
+		"* * * 13 *":    `invalid month "13": must be between 1 and 12`,
+		"* * * foo *":   `invalid month "foo": must be between 1 and 12`,
+		"* * * * 8":     `invalid day of week "8": must be between 0 and 7`,
+		"*/0 * * * *":   `invalid minute step "0"`,
+		"*/x * * * *":   `invalid minute step "x"`,
+		"5/10 * * * *":  `invalid minute "5/10": a step requires a range or '*'`,
+		"30-10 * * * *": `invalid minute range "30-10"`,
+		"1,,2 * * * *":  `invalid minute "": must be between 0 and 59`,
+		"-5 * * * *":    `invalid minute "": must be between 0 and 59`,
+	}
+	for schedule, wantErr := range invalid {
+		assert.EqualError(t, validateCronSchedule(schedule), wantErr, schedule)
+	}
+}
+
+func TestValidateCronCustomization(t *testing.T) {
+	users := []UserCustomization{{Name: "reporter"}}
+
+	testCases := []struct {
+		name    string
+		jobs    []CronJobCustomization
+		wantErr string
+	}{
+		{
+			name: "valid",
+			jobs: []CronJobCustomization{
+				{Name: "backup", Schedule: "0 1 * * *", User: "root", Command: "/usr/bin/backup"},
+				{Name: "report_daily", Schedule: "0 8 * * *", User: "reporter", Command: "/usr/bin/report"},
+			},
+		},
+		{
+			name:    "invalid name",
+			jobs:    []CronJobCustomization{{Name: "backup.cron", Schedule: "0 1 * * *", User: "root", Command: "true"}},
+			wantErr: `cron job name "backup.cron" is invalid`,
+		},
+		{
+			name:    "path as name",
+			jobs:    []CronJobCustomization{{Name: "../backup", Schedule: "0 1 * * *", User: "root", Command: "true"}},
+			wantErr: `cron job name "../backup" is invalid`,
+		},
+		{
+			name: "duplicate name",
+			jobs: []CronJobCustomization{
+				{Name: "backup", Schedule: "0 1 * * *", User: "root", Command: "true"},
+				{Name: "backup", Schedule: "0 2 * * *", User: "root", Command: "true"},
+			},
+			wantErr: `duplicate cron job name "backup"`,
+		},
+		{
+			name:    "malformed schedule",
+			jobs:    []CronJobCustomization{{Name: "backup", Schedule: "0 1 * *", User: "root", Command: "true"}},
+			wantErr: `cron job "backup": schedule "0 1 * *" must have 5 fields`,
+		},
+		{
+			name:    "unknown user",
+			jobs:    []CronJobCustomization{{Name: "backup", Schedule: "0 1 * * *", User: "nobody", Command: "true"}},
+			wantErr: `cron job "backup": user "nobody" must be root or defined in the blueprint`,
+		},
+		{
+			name:    "empty user",
+			jobs:    []CronJobCustomization{{Name: "backup", Schedule: "0 1 * * *", Command: "true"}},
+			wantErr: `cron job "backup": user "" must be root or defined in the blueprint`,
+		},
+		{
+			name:    "empty command",
+			jobs:    []CronJobCustomization{{Name: "backup", Schedule: "0 1 * * *", User: "root", Command: " "}},
+			wantErr: `cron job "backup": command is empty`,
+		},
+		{
+			name:    "multiline command",
+			jobs:    []CronJobCustomization{{Name: "backup", Schedule: "0 1 * * *", User: "root", Command: "true\n* * * * * root false"}},
+			wantErr: `cron job "backup": command spans multiple lines`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateCronCustomization(tc.jobs, users)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
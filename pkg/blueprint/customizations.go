@@ -7,23 +7,51 @@ import (
 )
 
 type Customizations struct {
-	Hostname           *string                   `json:"hostname,omitempty" toml:"hostname,omitempty"`
-	Kernel             *KernelCustomization      `json:"kernel,omitempty" toml:"kernel,omitempty"`
-	SSHKey             []SSHKeyCustomization     `json:"sshkey,omitempty" toml:"sshkey,omitempty"`
-	User               []UserCustomization       `json:"user,omitempty" toml:"user,omitempty"`
-	Group              []GroupCustomization      `json:"group,omitempty" toml:"group,omitempty"`
-	Timezone           *TimezoneCustomization    `json:"timezone,omitempty" toml:"timezone,omitempty"`
-	Locale             *LocaleCustomization      `json:"locale,omitempty" toml:"locale,omitempty"`
-	Firewall           *FirewallCustomization    `json:"firewall,omitempty" toml:"firewall,omitempty"`
-	Services           *ServicesCustomization    `json:"services,omitempty" toml:"services,omitempty"`
-	Filesystem         []FilesystemCustomization `json:"filesystem,omitempty" toml:"filesystem,omitempty"`
-	InstallationDevice string                    `json:"installation_device,omitempty" toml:"installation_device,omitempty"`
-	FDO                *FDOCustomization         `json:"fdo,omitempty" toml:"fdo,omitempty"`
-	OpenSCAP           *OpenSCAPCustomization    `json:"openscap,omitempty" toml:"openscap,omitempty"`
-	Ignition           *IgnitionCustomization    `json:"ignition,omitempty" toml:"ignition,omitempty"`
-	Directories        []DirectoryCustomization  `json:"directories,omitempty" toml:"directories,omitempty"`
-	Files              []FileCustomization       `json:"files,omitempty" toml:"files,omitempty"`
-	Repositories       []RepositoryCustomization `json:"repositories,omitempty" toml:"repositories,omitempty"`
+	Hostname           *string                        `json:"hostname,omitempty" toml:"hostname,omitempty"`
+	Kernel             *KernelCustomization           `json:"kernel,omitempty" toml:"kernel,omitempty"`
+	SSHKey             []SSHKeyCustomization          `json:"sshkey,omitempty" toml:"sshkey,omitempty"`
+	User               []UserCustomization            `json:"user,omitempty" toml:"user,omitempty"`
+	Group              []GroupCustomization           `json:"group,omitempty" toml:"group,omitempty"`
+	Timezone           *TimezoneCustomization         `json:"timezone,omitempty" toml:"timezone,omitempty"`
+	Locale             *LocaleCustomization           `json:"locale,omitempty" toml:"locale,omitempty"`
+	Firewall           *FirewallCustomization         `json:"firewall,omitempty" toml:"firewall,omitempty"`
+	Services           *ServicesCustomization         `json:"services,omitempty" toml:"services,omitempty"`
+	Filesystem         []FilesystemCustomization      `json:"filesystem,omitempty" toml:"filesystem,omitempty"`
+	InstallationDevice string                         `json:"installation_device,omitempty" toml:"installation_device,omitempty"`
+	FDO                *FDOCustomization              `json:"fdo,omitempty" toml:"fdo,omitempty"`
+	OpenSCAP           *OpenSCAPCustomization         `json:"openscap,omitempty" toml:"openscap,omitempty"`
+	Ignition           *IgnitionCustomization         `json:"ignition,omitempty" toml:"ignition,omitempty"`
+	Directories        []DirectoryCustomization       `json:"directories,omitempty" toml:"directories,omitempty"`
+	Files              []FileCustomization            `json:"files,omitempty" toml:"files,omitempty"`
+	Repositories       []RepositoryCustomization      `json:"repositories,omitempty" toml:"repositories,omitempty"`
+	Network            *NetworkCustomization          `json:"network,omitempty" toml:"network,omitempty"`
+	Timesync           *TimesyncCustomization         `json:"timesync,omitempty" toml:"timesync,omitempty"`
+	Sysctl             map[string]string              `json:"sysctl,omitempty" toml:"sysctl,omitempty"`
+	HostsEntries       map[string][]string            `json:"hosts_entries,omitempty" toml:"hosts_entries,omitempty"`
+	Cron               []CronJobCustomization         `json:"cron,omitempty" toml:"cron,omitempty"`
+	Installer          *InstallerCustomization        `json:"installer,omitempty" toml:"installer,omitempty"`
+	SELinux            *SELinuxCustomization          `json:"selinux,omitempty" toml:"selinux,omitempty"`
+	EnabledModules     []string                       `json:"enabled_modules,omitempty" toml:"enabled_modules,omitempty"`
+	FIPS               *bool                          `json:"fips,omitempty" toml:"fips,omitempty"`
+	CloudInit          *CloudInitCustomization        `json:"cloud_init,omitempty" toml:"cloud_init,omitempty"`
+	Bootloader         string                         `json:"bootloader,omitempty" toml:"bootloader,omitempty"`
+	Swap               *SwapCustomization             `json:"swap,omitempty" toml:"swap,omitempty"`
+	UnallocatedSpace   *uint64                        `json:"unallocated_space,omitempty" toml:"unallocated_space,omitempty"`
+	Registries         []RegistryCustomization        `json:"registries,omitempty" toml:"registries,omitempty"`
+	ContainersPolicy   *ContainersPolicyCustomization `json:"containers_policy,omitempty" toml:"containers_policy,omitempty"`
+	DefaultTarget      string                         `json:"default_target,omitempty" toml:"default_target,omitempty"`
+	ReadOnlyRoot       *bool                          `json:"readonly_root,omitempty" toml:"readonly_root,omitempty"`
+	DNFAutomatic       *DNFAutomaticCustomization     `json:"dnf_automatic,omitempty" toml:"dnf_automatic,omitempty"`
+	Environment        []string                       `json:"environment,omitempty" toml:"environment,omitempty"`
+	CACerts            *CACustomization               `json:"cacerts,omitempty" toml:"cacerts,omitempty"`
+	RPMOSTree          *RPMOSTreeCustomization        `json:"rpm_ostree,omitempty" toml:"rpm_ostree,omitempty"`
+	MachineID          *MachineIDCustomization        `json:"machine_id,omitempty" toml:"machine_id,omitempty"`
+	ExtraMounts        []MountCustomization           `json:"extra_mounts,omitempty" toml:"extra_mounts,omitempty"`
+	UdevRules          []UdevRulesCustomization       `json:"udev_rules,omitempty" toml:"udev_rules,omitempty"`
+	NetworkNaming      *NetworkNamingCustomization    `json:"network_naming,omitempty" toml:"network_naming,omitempty"`
+	FirstBoot          *FirstBootCustomization        `json:"first_boot,omitempty" toml:"first_boot,omitempty"`
+	Minimize           *MinimizeCustomization         `json:"minimize,omitempty" toml:"minimize,omitempty"`
+	Audit              *AuditCustomization            `json:"audit,omitempty" toml:"audit,omitempty"`
 }
 
 type IgnitionCustomization struct {
@@ -51,6 +79,9 @@ type FDOCustomization struct {
 type KernelCustomization struct {
 	Name   string `json:"name,omitempty" toml:"name,omitempty"`
 	Append string `json:"append" toml:"append"`
+	// Arguments removed from the default kernel command line of the image
+	// type, e.g. ["rhgb", "quiet"], see RemoveKernelOptions
+	Remove []string `json:"remove,omitempty" toml:"remove,omitempty"`
 }
 
 type SSHKeyCustomization struct {
@@ -104,6 +135,9 @@ type FirewallServicesCustomization struct {
 type ServicesCustomization struct {
 	Enabled  []string `json:"enabled,omitempty" toml:"enabled,omitempty"`
 	Disabled []string `json:"disabled,omitempty" toml:"disabled,omitempty"`
+	// Masked units are linked to /dev/null, so that they cannot be started at
+	// all, not even as a dependency of another unit like disabled ones
+	Masked []string `json:"masked,omitempty" toml:"masked,omitempty"`
 }
 
 type OpenSCAPCustomization struct {
@@ -151,7 +185,7 @@ func (c *Customizations) CheckAllowed(allowed ...string) error {
 			if field.String() == "" {
 				empty = true
 			}
-		case reflect.Array, reflect.Slice:
+		case reflect.Array, reflect.Slice, reflect.Map:
 			if field.Len() == 0 {
 				empty = true
 			}
@@ -246,9 +280,11 @@ func (c *Customizations) GetGroups() []GroupCustomization {
 func (c *Customizations) GetKernel() *KernelCustomization {
 	var name string
 	var append string
+	var remove []string
 	if c != nil && c.Kernel != nil {
 		name = c.Kernel.Name
 		append = c.Kernel.Append
+		remove = c.Kernel.Remove
 	}
 
 	if name == "" {
@@ -258,6 +294,7 @@ func (c *Customizations) GetKernel() *KernelCustomization {
 	return &KernelCustomization{
 		Name:   name,
 		Append: append,
+		Remove: remove,
 	}
 }
 
@@ -342,6 +379,215 @@ func (c *Customizations) GetFiles() []FileCustomization {
 	return c.Files
 }
 
+func (c *Customizations) GetNetwork() *NetworkCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.Network
+}
+
+func (c *Customizations) GetTimesync() *TimesyncCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.Timesync
+}
+
+func (c *Customizations) GetSysctl() map[string]string {
+	if c == nil {
+		return nil
+	}
+	return c.Sysctl
+}
+
+func (c *Customizations) GetHostsEntries() map[string][]string {
+	if c == nil {
+		return nil
+	}
+	return c.HostsEntries
+}
+
+func (c *Customizations) GetCron() []CronJobCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.Cron
+}
+
+func (c *Customizations) GetInstaller() *InstallerCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.Installer
+}
+
+func (c *Customizations) GetSELinux() *SELinuxCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.SELinux
+}
+
+// GetEnabledModules returns the module streams to enable, as name:stream or
+// name:stream/profile. Unlike the modules of the blueprint, which are
+// installed as packages, these select the modular content used to depsolve.
+func (c *Customizations) GetEnabledModules() []string {
+	if c == nil {
+		return nil
+	}
+	return c.EnabledModules
+}
+
+func (c *Customizations) GetFIPS() bool {
+	if c == nil || c.FIPS == nil {
+		return false
+	}
+	return *c.FIPS
+}
+
+func (c *Customizations) GetCloudInit() *CloudInitCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.CloudInit
+}
+
+// GetBootloader returns the selected bootloader, or an empty string if the
+// image type default should be used.
+func (c *Customizations) GetBootloader() string {
+	if c == nil {
+		return ""
+	}
+	return c.Bootloader
+}
+
+func (c *Customizations) GetSwap() *SwapCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.Swap
+}
+
+// GetUnallocatedSpace returns the space in bytes to leave unallocated at the
+// end of the disk, e.g. to grow the partitions later.
+func (c *Customizations) GetUnallocatedSpace() uint64 {
+	if c == nil || c.UnallocatedSpace == nil {
+		return 0
+	}
+	return *c.UnallocatedSpace
+}
+
+func (c *Customizations) GetRegistries() []RegistryCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.Registries
+}
+
+func (c *Customizations) GetContainersPolicy() *ContainersPolicyCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.ContainersPolicy
+}
+
+// GetDefaultTarget returns the systemd target that the image boots into by
+// default, or an empty string to keep the default of the image type.
+func (c *Customizations) GetDefaultTarget() string {
+	if c == nil {
+		return ""
+	}
+	return c.DefaultTarget
+}
+
+// GetReadOnlyRoot returns true if the root filesystem of the image is mounted
+// read-only, with overlays that keep /etc, /home and /root writable.
+func (c *Customizations) GetReadOnlyRoot() bool {
+	if c == nil || c.ReadOnlyRoot == nil {
+		return false
+	}
+	return *c.ReadOnlyRoot
+}
+
+func (c *Customizations) GetDNFAutomatic() *DNFAutomaticCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.DNFAutomatic
+}
+
+// GetEnvironment returns the system-wide environment variables, as KEY=VALUE
+// entries.
+func (c *Customizations) GetEnvironment() []string {
+	if c == nil {
+		return nil
+	}
+	return c.Environment
+}
+
+func (c *Customizations) GetCACerts() *CACustomization {
+	if c == nil {
+		return nil
+	}
+	return c.CACerts
+}
+
+func (c *Customizations) GetRPMOSTree() *RPMOSTreeCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.RPMOSTree
+}
+
+func (c *Customizations) GetMachineID() *MachineIDCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.MachineID
+}
+
+func (c *Customizations) GetExtraMounts() []MountCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.ExtraMounts
+}
+
+func (c *Customizations) GetUdevRules() []UdevRulesCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.UdevRules
+}
+
+func (c *Customizations) GetNetworkNaming() *NetworkNamingCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.NetworkNaming
+}
+
+func (c *Customizations) GetFirstBoot() *FirstBootCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.FirstBoot
+}
+
+func (c *Customizations) GetMinimize() *MinimizeCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.Minimize
+}
+
+func (c *Customizations) GetAudit() *AuditCustomization {
+	if c == nil {
+		return nil
+	}
+	return c.Audit
+}
+
 func (c *Customizations) GetRepositories() ([]RepositoryCustomization, error) {
 	if c == nil {
 		return nil, nil
@@ -46,6 +46,15 @@ func TestCheckAllowed(t *testing.T) {
 	// "Hostname" not allowed anymore
 	err = x.CheckAllowed("User")
 	assert.Error(t, err)
+
+	// map customizations are only checked when non-empty
+	x = Customizations{Hostname: &expectedHostname, Sysctl: map[string]string{}}
+	err = x.CheckAllowed("Hostname")
+	assert.NoError(t, err)
+
+	x.Sysctl["net.ipv4.ip_forward"] = "1"
+	err = x.CheckAllowed("Hostname")
+	assert.Error(t, err)
 }
 
 func TestGetHostname(t *testing.T) {
@@ -0,0 +1,30 @@
+package blueprint
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Systemd targets that can be the default target of the image with the
+// DefaultTarget customization
+var defaultTargets = []string{
+	"emergency.target",
+	"graphical.target",
+	"multi-user.target",
+	"rescue.target",
+}
+
+// ValidateDefaultTargetCustomization validates the given DefaultTarget
+// customization. If the target is not a systemd target that an image can boot
+// into, an error is returned. Otherwise, nil is returned.
+func ValidateDefaultTargetCustomization(target string) error {
+	if target == "" {
+		return nil
+	}
+	for _, known := range defaultTargets {
+		if target == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported default target %q (supported: %s)", target, strings.Join(defaultTargets, ", "))
+}
@@ -0,0 +1,15 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDefaultTargetCustomization(t *testing.T) {
+	for _, target := range []string{"", "graphical.target", "multi-user.target", "rescue.target", "emergency.target"} {
+		assert.NoError(t, ValidateDefaultTargetCustomization(target))
+	}
+	assert.EqualError(t, ValidateDefaultTargetCustomization("multi-user"), `unsupported default target "multi-user" (supported: emergency.target, graphical.target, multi-user.target, rescue.target)`)
+	assert.EqualError(t, ValidateDefaultTargetCustomization("sshd.service"), `unsupported default target "sshd.service" (supported: emergency.target, graphical.target, multi-user.target, rescue.target)`)
+}
@@ -0,0 +1,133 @@
+package blueprint
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+// Modes of the DNFAutomatic customization
+const (
+	DNFAutomaticModeApply    = "apply"
+	DNFAutomaticModeDownload = "download"
+)
+
+// Types of the updates that dnf-automatic installs
+const (
+	DNFAutomaticUpgradeTypeDefault  = "default"
+	DNFAutomaticUpgradeTypeSecurity = "security"
+)
+
+const (
+	// DNFAutomaticPackage is the package of dnf-automatic.
+	DNFAutomaticPackage = "dnf-automatic"
+	// DNFAutomaticTimer is the systemd timer that runs dnf-automatic.
+	DNFAutomaticTimer = "dnf-automatic.timer"
+	// DNFAutomaticConfigPath is the configuration of dnf-automatic, see
+	// dnf-automatic(8).
+	DNFAutomaticConfigPath = "/etc/dnf/automatic.conf"
+	// DNFAutomaticTimerDropInPath is the drop-in of the timer that sets the
+	// schedule of the DNFAutomatic customization.
+	DNFAutomaticTimerDropInPath = "/etc/systemd/system/dnf-automatic.timer.d/90-blueprint.conf"
+)
+
+// The characters of systemd calendar event expressions, see
+// systemd.time(7), e.g. "Mon..Fri *-*-* 04:00:00 UTC"
+var dnfAutomaticScheduleRegex = regexp.MustCompile(`^[a-zA-Z0-9*:.,/~+ _-]+$`)
+
+// DNFAutomaticCustomization configures unattended updates with dnf-automatic.
+type DNFAutomaticCustomization struct {
+	// Either "apply" (the default) to install the updates or "download" to
+	// only download them
+	Mode string `json:"mode,omitempty" toml:"mode,omitempty"`
+	// Either "default" (the default) for all the updates or "security" for
+	// the security updates only
+	UpgradeType string `json:"upgrade_type,omitempty" toml:"upgrade_type,omitempty"`
+	// When to look for updates as a systemd calendar event, e.g. "daily" or
+	// "Sun 03:00". The schedule of the dnf-automatic timer is kept if empty.
+	Schedule string `json:"schedule,omitempty" toml:"schedule,omitempty"`
+}
+
+// ValidateDNFAutomaticCustomization validates the given DNFAutomatic
+// customization. If the customization is invalid, an error is returned.
+// Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - The mode and the upgrade type are known
+// - The schedule is a single line of the characters of systemd calendar
+// events
+func ValidateDNFAutomaticCustomization(da *DNFAutomaticCustomization) error {
+	if da == nil {
+		return nil
+	}
+
+	switch da.Mode {
+	case "", DNFAutomaticModeApply, DNFAutomaticModeDownload:
+	default:
+		return fmt.Errorf("unsupported dnf-automatic mode %q (supported: %s, %s)", da.Mode, DNFAutomaticModeApply, DNFAutomaticModeDownload)
+	}
+
+	switch da.UpgradeType {
+	case "", DNFAutomaticUpgradeTypeDefault, DNFAutomaticUpgradeTypeSecurity:
+	default:
+		return fmt.Errorf("unsupported dnf-automatic upgrade type %q (supported: %s, %s)", da.UpgradeType, DNFAutomaticUpgradeTypeDefault, DNFAutomaticUpgradeTypeSecurity)
+	}
+
+	if da.Schedule != "" && (strings.TrimSpace(da.Schedule) == "" || !dnfAutomaticScheduleRegex.MatchString(da.Schedule)) {
+		return fmt.Errorf("dnf-automatic schedule %q is not a valid systemd calendar event", da.Schedule)
+	}
+
+	return nil
+}
+
+// DNFAutomaticCustomizationToFsNodes converts the DNFAutomatic customization
+// to the configuration of dnf-automatic and, if the schedule is set, a drop-in
+// of the timer that replaces its schedule, with the directory of the drop-in.
+func DNFAutomaticCustomizationToFsNodes(da *DNFAutomaticCustomization) ([]*fsnode.Directory, []*fsnode.File, error) {
+	if da == nil {
+		return nil, nil, nil
+	}
+
+	if err := ValidateDNFAutomaticCustomization(da); err != nil {
+		return nil, nil, err
+	}
+
+	applyUpdates := "yes"
+	if da.Mode == DNFAutomaticModeDownload {
+		applyUpdates = "no"
+	}
+	upgradeType := DNFAutomaticUpgradeTypeDefault
+	if da.UpgradeType != "" {
+		upgradeType = da.UpgradeType
+	}
+	config := fmt.Sprintf("[commands]\nupgrade_type = %s\ndownload_updates = yes\napply_updates = %s\n\n[emitters]\nemit_via = stdio\n", upgradeType, applyUpdates)
+	configFile, err := fsnode.NewFile(DNFAutomaticConfigPath, common.ToPtr(os.FileMode(0644)), "root", "root", []byte(config))
+	if err != nil {
+		return nil, nil, err
+	}
+	files := []*fsnode.File{configFile}
+
+	var dirs []*fsnode.Directory
+	if da.Schedule != "" {
+		dropInDir, err := fsnode.NewDirectory(path.Dir(DNFAutomaticTimerDropInPath), common.ToPtr(os.FileMode(0755)), "root", "root", true)
+		if err != nil {
+			return nil, nil, err
+		}
+		dirs = append(dirs, dropInDir)
+
+		// the empty OnCalendar= resets the schedule of the timer unit
+		dropIn := fmt.Sprintf("[Timer]\nOnCalendar=\nOnCalendar=%s\n", strings.TrimSpace(da.Schedule))
+		dropInFile, err := fsnode.NewFile(DNFAutomaticTimerDropInPath, common.ToPtr(os.FileMode(0644)), "root", "root", []byte(dropIn))
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, dropInFile)
+	}
+
+	return dirs, files, nil
+}
@@ -0,0 +1,55 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNFAutomaticCustomizationToFsNodes(t *testing.T) {
+	dirs, files, err := DNFAutomaticCustomizationToFsNodes(&DNFAutomaticCustomization{})
+	require.NoError(t, err)
+	assert.Empty(t, dirs)
+	require.Len(t, files, 1)
+	assert.Equal(t, DNFAutomaticConfigPath, files[0].Path())
+	assert.Equal(t, "[commands]\nupgrade_type = default\ndownload_updates = yes\napply_updates = yes\n\n[emitters]\nemit_via = stdio\n", string(files[0].Data()))
+
+	dirs, files, err = DNFAutomaticCustomizationToFsNodes(&DNFAutomaticCustomization{
+		Mode:        DNFAutomaticModeDownload,
+		UpgradeType: DNFAutomaticUpgradeTypeSecurity,
+		Schedule:    "Sun 03:00",
+	})
+	require.NoError(t, err)
+	require.Len(t, dirs, 1)
+	assert.Equal(t, "/etc/systemd/system/dnf-automatic.timer.d", dirs[0].Path())
+	assert.True(t, dirs[0].EnsureParentDirs())
+	require.Len(t, files, 2)
+	assert.Equal(t, "[commands]\nupgrade_type = security\ndownload_updates = yes\napply_updates = no\n\n[emitters]\nemit_via = stdio\n", string(files[0].Data()))
+	assert.Equal(t, DNFAutomaticTimerDropInPath, files[1].Path())
+	assert.Equal(t, "[Timer]\nOnCalendar=\nOnCalendar=Sun 03:00\n", string(files[1].Data()))
+
+	for _, file := range files {
+		require.NotNil(t, file.Mode())
+		assert.Equal(t, os.FileMode(0644), *file.Mode())
+		assert.Equal(t, "root", file.User())
+		assert.Equal(t, "root", file.Group())
+	}
+
+	dirs, files, err = DNFAutomaticCustomizationToFsNodes(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, dirs)
+	assert.Nil(t, files)
+}
+
+func TestValidateDNFAutomaticCustomization(t *testing.T) {
+	assert.NoError(t, ValidateDNFAutomaticCustomization(nil))
+	assert.NoError(t, ValidateDNFAutomaticCustomization(&DNFAutomaticCustomization{Mode: "apply", Schedule: "Mon..Fri *-*-* 04:00:00 UTC"}))
+	assert.NoError(t, ValidateDNFAutomaticCustomization(&DNFAutomaticCustomization{Mode: "download", UpgradeType: "default", Schedule: "daily"}))
+
+	assert.EqualError(t, ValidateDNFAutomaticCustomization(&DNFAutomaticCustomization{Mode: "notify"}), `unsupported dnf-automatic mode "notify" (supported: apply, download)`)
+	assert.EqualError(t, ValidateDNFAutomaticCustomization(&DNFAutomaticCustomization{UpgradeType: "bugfix"}), `unsupported dnf-automatic upgrade type "bugfix" (supported: default, security)`)
+	assert.EqualError(t, ValidateDNFAutomaticCustomization(&DNFAutomaticCustomization{Schedule: "daily\nOnBootSec=0"}), `dnf-automatic schedule "daily\nOnBootSec=0" is not a valid systemd calendar event`)
+	assert.EqualError(t, ValidateDNFAutomaticCustomization(&DNFAutomaticCustomization{Schedule: " "}), `dnf-automatic schedule " " is not a valid systemd calendar event`)
+}
@@ -0,0 +1,85 @@
+package blueprint
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+const (
+	// EnvironmentFilePath holds the environment of the login sessions, read
+	// by pam_env(8).
+	EnvironmentFilePath = "/etc/environment"
+	// EnvironmentDropInPath is the environment.d(5) drop-in with the
+	// environment of the services of the systemd user manager.
+	EnvironmentDropInPath = "/etc/environment.d/90-blueprint.conf"
+)
+
+// The name of an environment variable, as accepted by the shell
+var environmentKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateEnvironmentCustomization validates the given Environment
+// customization, a list of KEY=VALUE entries. If the customization is
+// invalid, an error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - Every entry is KEY=VALUE, with a key that is a valid shell identifier
+// - No key is set twice
+// - No value contains a newline or another control character
+func ValidateEnvironmentCustomization(entries []string) error {
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		key, value, found := strings.Cut(entry, "=")
+		if !found {
+			return fmt.Errorf("environment entry %q must be KEY=VALUE", entry)
+		}
+		if !environmentKeyRegex.MatchString(key) {
+			return fmt.Errorf("environment entry %q: %q is not a valid variable name", entry, key)
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate environment variable %q", key)
+		}
+		seen[key] = true
+		if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			return fmt.Errorf("environment variable %q has a value with control characters", key)
+		}
+	}
+	return nil
+}
+
+// EnvironmentCustomizationToFsNodes converts the Environment customization to
+// /etc/environment, for login sessions, and an environment.d drop-in, for user
+// services, with the same entries in the order of the blueprint. The
+// directory of the drop-in is returned too, it is not part of every image.
+func EnvironmentCustomizationToFsNodes(entries []string) ([]*fsnode.Directory, []*fsnode.File, error) {
+	if len(entries) == 0 {
+		return nil, nil, nil
+	}
+
+	if err := ValidateEnvironmentCustomization(entries); err != nil {
+		return nil, nil, err
+	}
+
+	// no mode, so that the directory is kept as is if a package provides it
+	dropInDir, err := fsnode.NewDirectory(path.Dir(EnvironmentDropInPath), nil, nil, nil, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data := []byte(strings.Join(entries, "\n") + "\n")
+	files := make([]*fsnode.File, 0, 2)
+	for _, filePath := range []string{EnvironmentFilePath, EnvironmentDropInPath} {
+		file, err := fsnode.NewFile(filePath, common.ToPtr(os.FileMode(0644)), "root", "root", data)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, file)
+	}
+	return []*fsnode.Directory{dropInDir}, files, nil
+}
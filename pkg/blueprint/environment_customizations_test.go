@@ -0,0 +1,49 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironmentCustomizationToFsNodes(t *testing.T) {
+	dirs, files, err := EnvironmentCustomizationToFsNodes([]string{
+		"HTTP_PROXY=http://proxy.example.com:3128",
+		"NO_PROXY=localhost,127.0.0.1,.example.com",
+		"EMPTY=",
+	})
+	require.NoError(t, err)
+	require.Len(t, dirs, 1)
+	assert.Equal(t, "/etc/environment.d", dirs[0].Path())
+	assert.Nil(t, dirs[0].Mode())
+	require.Len(t, files, 2)
+
+	assert.Equal(t, "/etc/environment", files[0].Path())
+	assert.Equal(t, "/etc/environment.d/90-blueprint.conf", files[1].Path())
+	for _, file := range files {
+		assert.Equal(t, "HTTP_PROXY=http://proxy.example.com:3128\nNO_PROXY=localhost,127.0.0.1,.example.com\nEMPTY=\n", string(file.Data()))
+		require.NotNil(t, file.Mode())
+		assert.Equal(t, os.FileMode(0644), *file.Mode())
+		assert.Equal(t, "root", file.User())
+		assert.Equal(t, "root", file.Group())
+	}
+
+	dirs, files, err = EnvironmentCustomizationToFsNodes(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, dirs)
+	assert.Nil(t, files)
+}
+
+func TestValidateEnvironmentCustomization(t *testing.T) {
+	assert.NoError(t, ValidateEnvironmentCustomization(nil))
+	assert.NoError(t, ValidateEnvironmentCustomization([]string{"_A=1", "lower_case=a=b", "SPACES=a b"}))
+
+	assert.EqualError(t, ValidateEnvironmentCustomization([]string{"HTTP_PROXY"}), `environment entry "HTTP_PROXY" must be KEY=VALUE`)
+	assert.EqualError(t, ValidateEnvironmentCustomization([]string{"=value"}), `environment entry "=value": "" is not a valid variable name`)
+	assert.EqualError(t, ValidateEnvironmentCustomization([]string{"1PROXY=value"}), `environment entry "1PROXY=value": "1PROXY" is not a valid variable name`)
+	assert.EqualError(t, ValidateEnvironmentCustomization([]string{"MY-PROXY=value"}), `environment entry "MY-PROXY=value": "MY-PROXY" is not a valid variable name`)
+	assert.EqualError(t, ValidateEnvironmentCustomization([]string{"A=1", "A=2"}), `duplicate environment variable "A"`)
+	assert.EqualError(t, ValidateEnvironmentCustomization([]string{"A=1\nB=2"}), `environment variable "A" has a value with control characters`)
+}
@@ -0,0 +1,143 @@
+package blueprint
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Filesystem types of the ExtraMounts customization and the packages that
+// the images need to mount them
+var extraMountTypes = map[string]string{
+	"nfs":      "nfs-utils",
+	"nfs4":     "nfs-utils",
+	"cifs":     "cifs-utils",
+	"tmpfs":    "",
+	"virtiofs": "",
+	"9p":       "",
+	"none":     "",
+}
+
+// An option of a mount, e.g. "ro", "size=512M" or "credentials=/etc/creds"
+var mountOptionRegex = regexp.MustCompile(`^[^\s,#"'\\]+$`)
+
+// MountCustomization is an entry of /etc/fstab that is added after the mounts
+// of the partition table, e.g. for network shares, tmpfs or bind mounts.
+type MountCustomization struct {
+	// Directory to mount the filesystem on
+	Mountpoint string `json:"mountpoint" toml:"mountpoint"`
+	// What to mount: host:/path for nfs, //host/share for cifs, the
+	// directory for bind mounts and any name, e.g. tmpfs, for the others
+	Source string `json:"source" toml:"source"`
+	// Filesystem type: nfs, nfs4, cifs, tmpfs, virtiofs, 9p or none for bind
+	// mounts
+	Type string `json:"type" toml:"type"`
+	// Comma separated mount options, "defaults" if empty. Bind mounts need
+	// the bind or rbind option.
+	Options string `json:"options,omitempty" toml:"options,omitempty"`
+}
+
+// GetOptions returns the mount options of the entry.
+func (m MountCustomization) GetOptions() string {
+	if m.Options == "" {
+		return "defaults"
+	}
+	return m.Options
+}
+
+func validateMountSource(m MountCustomization) error {
+	if m.Source == "" || strings.ContainsAny(m.Source, " \t\n#") {
+		return fmt.Errorf("extra mount %q has an invalid source %q: must be non-empty without whitespace", m.Mountpoint, m.Source)
+	}
+	switch m.Type {
+	case "nfs", "nfs4":
+		// IPv6 addresses of hosts contain colons, but not followed by a /
+		host, _, found := strings.Cut(m.Source, ":/")
+		if !found || host == "" {
+			return fmt.Errorf("extra mount %q has an invalid %s source %q: must be host:/path", m.Mountpoint, m.Type, m.Source)
+		}
+	case "cifs":
+		host, share, found := strings.Cut(strings.TrimPrefix(m.Source, "//"), "/")
+		if !strings.HasPrefix(m.Source, "//") || !found || host == "" || share == "" {
+			return fmt.Errorf("extra mount %q has an invalid cifs source %q: must be //host/share", m.Mountpoint, m.Source)
+		}
+	case "none":
+		if !path.IsAbs(m.Source) {
+			return fmt.Errorf("extra mount %q has an invalid bind mount source %q: must be an absolute path", m.Mountpoint, m.Source)
+		}
+	}
+	return nil
+}
+
+// ValidateExtraMountsCustomization validates the given ExtraMounts
+// customization against the Filesystem customizations of the blueprint. If
+// the customization is invalid, an error is returned. Otherwise, nil is
+// returned.
+//
+// It currently ensures that:
+// - Mountpoints are absolute, canonical and not /
+// - No mountpoint is listed twice or is a mountpoint of the Filesystem
+// customizations
+// - The filesystem type is supported and the source has the format of the type
+// - Options are comma separated without whitespace or empty options
+// - Mounts of type none are bind mounts
+func ValidateExtraMountsCustomization(mounts []MountCustomization, filesystems []FilesystemCustomization) error {
+	mountpoints := make(map[string]bool, len(mounts)+len(filesystems))
+	for _, fs := range filesystems {
+		mountpoints[fs.Mountpoint] = true
+	}
+
+	for _, m := range mounts {
+		if !path.IsAbs(m.Mountpoint) || path.Clean(m.Mountpoint) != m.Mountpoint || m.Mountpoint == "/" {
+			return fmt.Errorf("extra mount mountpoint %q must be an absolute, canonical path other than /", m.Mountpoint)
+		}
+		if mountpoints[m.Mountpoint] {
+			return fmt.Errorf("extra mount mountpoint %q is already mounted", m.Mountpoint)
+		}
+		mountpoints[m.Mountpoint] = true
+
+		if _, ok := extraMountTypes[m.Type]; !ok {
+			types := make([]string, 0, len(extraMountTypes))
+			for t := range extraMountTypes {
+				types = append(types, t)
+			}
+			sort.Strings(types)
+			return fmt.Errorf("extra mount %q has an unsupported filesystem type %q (supported: %s)", m.Mountpoint, m.Type, strings.Join(types, ", "))
+		}
+		if err := validateMountSource(m); err != nil {
+			return err
+		}
+
+		isBind := false
+		if m.Options != "" {
+			for _, option := range strings.Split(m.Options, ",") {
+				if !mountOptionRegex.MatchString(option) {
+					return fmt.Errorf("extra mount %q has invalid options %q: must be comma separated options without whitespace", m.Mountpoint, m.Options)
+				}
+				if option == "bind" || option == "rbind" {
+					isBind = true
+				}
+			}
+		}
+		if m.Type == "none" && !isBind {
+			return fmt.Errorf("extra mount %q of type none must have the bind or rbind option", m.Mountpoint)
+		}
+	}
+	return nil
+}
+
+// ExtraMountsPackages returns the packages that are needed to mount the
+// filesystems of the ExtraMounts customization.
+func ExtraMountsPackages(mounts []MountCustomization) []string {
+	seen := make(map[string]bool)
+	var packages []string
+	for _, m := range mounts {
+		if pkg := extraMountTypes[m.Type]; pkg != "" && !seen[pkg] {
+			seen[pkg] = true
+			packages = append(packages, pkg)
+		}
+	}
+	return packages
+}
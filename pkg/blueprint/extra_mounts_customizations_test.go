@@ -0,0 +1,129 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateExtraMountsCustomization(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mounts      []MountCustomization
+		filesystems []FilesystemCustomization
+		wantErr     string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "valid",
+			mounts: []MountCustomization{
+				{Mountpoint: "/mnt/nfs", Source: "nas.example.com:/export/data", Type: "nfs", Options: "ro,soft,_netdev"},
+				{Mountpoint: "/mnt/nfs4", Source: "[2001:db8::1]:/data", Type: "nfs4"},
+				{Mountpoint: "/mnt/share", Source: "//fileserver/share", Type: "cifs", Options: "credentials=/etc/cifs-creds,vers=3.0"},
+				{Mountpoint: "/scratch", Source: "tmpfs", Type: "tmpfs", Options: "size=512M,mode=1777"},
+				{Mountpoint: "/srv/www", Source: "/var/www", Type: "none", Options: "bind"},
+				{Mountpoint: "/mnt/host", Source: "hostshare", Type: "virtiofs"},
+			},
+			filesystems: []FilesystemCustomization{{Mountpoint: "/var"}},
+		},
+		{
+			name:    "relative mountpoint",
+			mounts:  []MountCustomization{{Mountpoint: "mnt", Source: "tmpfs", Type: "tmpfs"}},
+			wantErr: `extra mount mountpoint "mnt" must be an absolute, canonical path other than /`,
+		},
+		{
+			name:    "unclean mountpoint",
+			mounts:  []MountCustomization{{Mountpoint: "/mnt/", Source: "tmpfs", Type: "tmpfs"}},
+			wantErr: `extra mount mountpoint "/mnt/" must be an absolute, canonical path other than /`,
+		},
+		{
+			name:    "root mountpoint",
+			mounts:  []MountCustomization{{Mountpoint: "/", Source: "tmpfs", Type: "tmpfs"}},
+			wantErr: `extra mount mountpoint "/" must be an absolute, canonical path other than /`,
+		},
+		{
+			name: "duplicate mountpoint",
+			mounts: []MountCustomization{
+				{Mountpoint: "/mnt", Source: "tmpfs", Type: "tmpfs"},
+				{Mountpoint: "/mnt", Source: "nas:/data", Type: "nfs"},
+			},
+			wantErr: `extra mount mountpoint "/mnt" is already mounted`,
+		},
+		{
+			name:        "filesystem mountpoint",
+			mounts:      []MountCustomization{{Mountpoint: "/var", Source: "tmpfs", Type: "tmpfs"}},
+			filesystems: []FilesystemCustomization{{Mountpoint: "/var"}},
+			wantErr:     `extra mount mountpoint "/var" is already mounted`,
+		},
+		{
+			name:    "unknown type",
+			mounts:  []MountCustomization{{Mountpoint: "/mnt", Source: "/dev/sdb1", Type: "ext4"}},
+			wantErr: `extra mount "/mnt" has an unsupported filesystem type "ext4" (supported: 9p, cifs, nfs, nfs4, none, tmpfs, virtiofs)`,
+		},
+		{
+			name:    "empty source",
+			mounts:  []MountCustomization{{Mountpoint: "/mnt", Type: "tmpfs"}},
+			wantErr: `extra mount "/mnt" has an invalid source "": must be non-empty without whitespace`,
+		},
+		{
+			name:    "source with whitespace",
+			mounts:  []MountCustomization{{Mountpoint: "/mnt", Source: "my tmpfs", Type: "tmpfs"}},
+			wantErr: `extra mount "/mnt" has an invalid source "my tmpfs": must be non-empty without whitespace`,
+		},
+		{
+			name:    "nfs without export",
+			mounts:  []MountCustomization{{Mountpoint: "/mnt", Source: "nas.example.com", Type: "nfs"}},
+			wantErr: `extra mount "/mnt" has an invalid nfs source "nas.example.com": must be host:/path`,
+		},
+		{
+			name:    "cifs without share",
+			mounts:  []MountCustomization{{Mountpoint: "/mnt", Source: "//fileserver", Type: "cifs"}},
+			wantErr: `extra mount "/mnt" has an invalid cifs source "//fileserver": must be //host/share`,
+		},
+		{
+			name:    "relative bind source",
+			mounts:  []MountCustomization{{Mountpoint: "/mnt", Source: "var/www", Type: "none", Options: "bind"}},
+			wantErr: `extra mount "/mnt" has an invalid bind mount source "var/www": must be an absolute path`,
+		},
+		{
+			name:    "none without bind",
+			mounts:  []MountCustomization{{Mountpoint: "/mnt", Source: "/var/www", Type: "none", Options: "ro"}},
+			wantErr: `extra mount "/mnt" of type none must have the bind or rbind option`,
+		},
+		{
+			name:    "empty option",
+			mounts:  []MountCustomization{{Mountpoint: "/mnt", Source: "tmpfs", Type: "tmpfs", Options: "rw,,size=1G"}},
+			wantErr: `extra mount "/mnt" has invalid options "rw,,size=1G": must be comma separated options without whitespace`,
+		},
+		{
+			name:    "option with whitespace",
+			mounts:  []MountCustomization{{Mountpoint: "/mnt", Source: "tmpfs", Type: "tmpfs", Options: "rw, size=1G"}},
+			wantErr: `extra mount "/mnt" has invalid options "rw, size=1G": must be comma separated options without whitespace`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateExtraMountsCustomization(tc.mounts, tc.filesystems)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtraMountsPackages(t *testing.T) {
+	assert.Nil(t, ExtraMountsPackages(nil))
+	mounts := []MountCustomization{
+		{Mountpoint: "/scratch", Source: "tmpfs", Type: "tmpfs"},
+		{Mountpoint: "/mnt/a", Source: "nas:/a", Type: "nfs"},
+		{Mountpoint: "/mnt/b", Source: "nas:/b", Type: "nfs4"},
+		{Mountpoint: "/mnt/c", Source: "//server/c", Type: "cifs"},
+	}
+	assert.Equal(t, []string{"nfs-utils", "cifs-utils"}, ExtraMountsPackages(mounts))
+	assert.Equal(t, "defaults", mounts[0].GetOptions())
+}
@@ -3,6 +3,7 @@ package blueprint
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/internal/pathpolicy"
@@ -10,7 +11,8 @@ import (
 
 type FilesystemCustomization struct {
 	Mountpoint string `json:"mountpoint,omitempty" toml:"mountpoint,omitempty"`
-	MinSize    uint64 `json:"minsize,omitempty" toml:"size,omitempty"`
+	// The size is always written since it is required when unmarshalling
+	MinSize uint64 `json:"minsize" toml:"size"`
 }
 
 func (fsc *FilesystemCustomization) UnmarshalTOML(data interface{}) error {
@@ -87,3 +89,35 @@ func CheckMountpointsPolicy(mountpoints []FilesystemCustomization, mountpointAll
 
 	return nil
 }
+
+// CheckMountpointsConflicts checks that the mountpoints do not conflict with
+// each other, i.e. that every mountpoint is defined only once and is not larger
+// than the closest parent mountpoint with a size, e.g. /var/log must not be
+// larger than /var. The root mountpoint is not taken into account as a parent
+// since it always gets the space of the image that is not used by the others.
+func CheckMountpointsConflicts(mountpoints []FilesystemCustomization) error {
+	sizes := make(map[string]uint64, len(mountpoints))
+	for _, m := range mountpoints {
+		if _, exists := sizes[m.Mountpoint]; exists {
+			return fmt.Errorf("duplicate mountpoint %q", m.Mountpoint)
+		}
+		sizes[m.Mountpoint] = m.MinSize
+	}
+
+	for _, m := range mountpoints {
+		parent := ""
+		for candidate, size := range sizes {
+			if candidate == "/" || size == 0 || !strings.HasPrefix(m.Mountpoint, candidate+"/") {
+				continue
+			}
+			if len(candidate) > len(parent) {
+				parent = candidate
+			}
+		}
+		if parent != "" && m.MinSize > sizes[parent] {
+			return fmt.Errorf("mountpoint %q (%d bytes) is larger than its parent mountpoint %q (%d bytes)", m.Mountpoint, m.MinSize, parent, sizes[parent])
+		}
+	}
+
+	return nil
+}
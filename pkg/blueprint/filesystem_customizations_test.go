@@ -0,0 +1,79 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMountpointsConflicts(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mountpoints []FilesystemCustomization
+		wantErr     string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "arbitrary depth",
+			mountpoints: []FilesystemCustomization{
+				{Mountpoint: "/", MinSize: 1024},
+				{Mountpoint: "/var", MinSize: 4096},
+				{Mountpoint: "/var/log", MinSize: 2048},
+				{Mountpoint: "/var/log/audit", MinSize: 2048},
+			},
+		},
+		{
+			name: "larger than root",
+			mountpoints: []FilesystemCustomization{
+				{Mountpoint: "/", MinSize: 1024},
+				{Mountpoint: "/home", MinSize: 4096},
+			},
+		},
+		{
+			name: "sibling with a common prefix",
+			mountpoints: []FilesystemCustomization{
+				{Mountpoint: "/var", MinSize: 1024},
+				{Mountpoint: "/variable", MinSize: 4096},
+			},
+		},
+		{
+			name: "duplicate",
+			mountpoints: []FilesystemCustomization{
+				{Mountpoint: "/var", MinSize: 1024},
+				{Mountpoint: "/home", MinSize: 1024},
+				{Mountpoint: "/var", MinSize: 2048},
+			},
+			wantErr: `duplicate mountpoint "/var"`,
+		},
+		{
+			name: "child larger than parent",
+			mountpoints: []FilesystemCustomization{
+				{Mountpoint: "/var/lib/containers", MinSize: 8192},
+				{Mountpoint: "/var", MinSize: 4096},
+			},
+			wantErr: `mountpoint "/var/lib/containers" (8192 bytes) is larger than its parent mountpoint "/var" (4096 bytes)`,
+		},
+		{
+			name: "child larger than closest parent",
+			mountpoints: []FilesystemCustomization{
+				{Mountpoint: "/var", MinSize: 8192},
+				{Mountpoint: "/var/lib", MinSize: 2048},
+				{Mountpoint: "/var/lib/containers", MinSize: 4096},
+			},
+			wantErr: `mountpoint "/var/lib/containers" (4096 bytes) is larger than its parent mountpoint "/var/lib" (2048 bytes)`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckMountpointsConflicts(tc.mountpoints)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
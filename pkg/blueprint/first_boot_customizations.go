@@ -0,0 +1,102 @@
+package blueprint
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+const (
+	// FirstBootService is the oneshot systemd service that runs the script
+	// of the FirstBoot customization.
+	FirstBootService = "blueprint-firstboot.service"
+	// FirstBootServicePath is the unit file of the FirstBootService.
+	FirstBootServicePath = "/etc/systemd/system/" + FirstBootService
+	// FirstBootScriptPath is the script of the FirstBoot customization.
+	FirstBootScriptPath = "/usr/libexec/blueprint-firstboot"
+)
+
+// FirstBootCustomization is a script that runs once, on the first boot of the
+// image, independently of cloud-init and ignition.
+type FirstBootCustomization struct {
+	// The script, which starts with an interpreter line, e.g. #!/bin/bash
+	Script string `json:"script" toml:"script"`
+}
+
+// ValidateFirstBootCustomization validates the given FirstBoot customization
+// against the files of the blueprint. If the customization is invalid, an
+// error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - The script is non-empty and starts with an interpreter line
+// - The script and the service are not also defined in the Files
+// customization
+func ValidateFirstBootCustomization(fb *FirstBootCustomization, files []FileCustomization) error {
+	if fb == nil {
+		return nil
+	}
+
+	if strings.TrimSpace(fb.Script) == "" {
+		return fmt.Errorf("first boot script is empty")
+	}
+	if !strings.HasPrefix(fb.Script, "#!") {
+		return fmt.Errorf("first boot script must start with an interpreter line, e.g. #!/bin/bash")
+	}
+
+	for _, file := range files {
+		if p := path.Clean(file.Path); p == FirstBootScriptPath || p == FirstBootServicePath {
+			return fmt.Errorf("first boot script cannot be combined with a custom %s file", p)
+		}
+	}
+
+	return nil
+}
+
+// FirstBootCustomizationToFsNodeFiles converts the FirstBoot customization to
+// the executable script and the FirstBootService that runs it. The service
+// disables itself after the script ran, even if the script failed, so that it
+// only runs on the first boot. It has to be enabled to run.
+func FirstBootCustomizationToFsNodeFiles(fb *FirstBootCustomization) ([]*fsnode.File, error) {
+	if fb == nil {
+		return nil, nil
+	}
+
+	if err := ValidateFirstBootCustomization(fb, nil); err != nil {
+		return nil, err
+	}
+
+	script := fb.Script
+	if !strings.HasSuffix(script, "\n") {
+		script += "\n"
+	}
+	scriptFile, err := fsnode.NewFile(FirstBootScriptPath, common.ToPtr(os.FileMode(0755)), "root", "root", []byte(script))
+	if err != nil {
+		return nil, err
+	}
+
+	// the "-" prefix ignores the exit code of the script, which is logged,
+	// so that the service is disabled in any case
+	unit := fmt.Sprintf(`[Unit]
+Description=Run the first boot script of the blueprint
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=oneshot
+ExecStart=-%s
+ExecStartPost=/usr/bin/systemctl disable %s
+
+[Install]
+WantedBy=multi-user.target
+`, FirstBootScriptPath, FirstBootService)
+	unitFile, err := fsnode.NewFile(FirstBootServicePath, common.ToPtr(os.FileMode(0644)), "root", "root", []byte(unit))
+	if err != nil {
+		return nil, err
+	}
+
+	return []*fsnode.File{scriptFile, unitFile}, nil
+}
@@ -0,0 +1,86 @@
+package blueprint
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFirstBootCustomization(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fb      *FirstBootCustomization
+		files   []FileCustomization
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "valid",
+			fb:    &FirstBootCustomization{Script: "#!/bin/bash\necho hello > /var/log/firstboot.log\n"},
+			files: []FileCustomization{{Path: "/etc/systemd/system/other.service"}},
+		},
+		{
+			name:    "empty script",
+			fb:      &FirstBootCustomization{Script: " \n"},
+			wantErr: "first boot script is empty",
+		},
+		{
+			name:    "no interpreter",
+			fb:      &FirstBootCustomization{Script: "echo hello\n"},
+			wantErr: "first boot script must start with an interpreter line, e.g. #!/bin/bash",
+		},
+		{
+			name:    "custom script file",
+			fb:      &FirstBootCustomization{Script: "#!/bin/sh\ntrue\n"},
+			files:   []FileCustomization{{Path: "/usr/libexec/blueprint-firstboot"}},
+			wantErr: "first boot script cannot be combined with a custom /usr/libexec/blueprint-firstboot file",
+		},
+		{
+			name:    "custom service file",
+			fb:      &FirstBootCustomization{Script: "#!/bin/sh\ntrue\n"},
+			files:   []FileCustomization{{Path: "/etc/systemd/system//blueprint-firstboot.service"}},
+			wantErr: "first boot script cannot be combined with a custom /etc/systemd/system/blueprint-firstboot.service file",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateFirstBootCustomization(tc.fb, tc.files)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestFirstBootCustomizationToFsNodeFiles(t *testing.T) {
+	files, err := FirstBootCustomizationToFsNodeFiles(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, files)
+
+	files, err = FirstBootCustomizationToFsNodeFiles(&FirstBootCustomization{Script: "#!/bin/bash\ntouch /root/booted"})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	assert.Equal(t, "/usr/libexec/blueprint-firstboot", files[0].Path())
+	assert.Equal(t, "#!/bin/bash\ntouch /root/booted\n", string(files[0].Data()))
+	assert.Equal(t, os.FileMode(0755), *files[0].Mode())
+
+	assert.Equal(t, "/etc/systemd/system/blueprint-firstboot.service", files[1].Path())
+	assert.Equal(t, os.FileMode(0644), *files[1].Mode())
+	unit := string(files[1].Data())
+	assert.Contains(t, unit, "Type=oneshot\n")
+	assert.Contains(t, unit, "ExecStart=-/usr/libexec/blueprint-firstboot\n")
+	assert.Contains(t, unit, "ExecStartPost=/usr/bin/systemctl disable blueprint-firstboot.service\n")
+	assert.True(t, strings.HasSuffix(unit, "[Install]\nWantedBy=multi-user.target\n"))
+
+	_, err = FirstBootCustomizationToFsNodeFiles(&FirstBootCustomization{})
+	assert.EqualError(t, err, "first boot script is empty")
+}
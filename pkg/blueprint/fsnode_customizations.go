@@ -3,6 +3,7 @@ package blueprint
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path"
 	"regexp"
@@ -25,6 +26,41 @@ func validateModeString(mode string) error {
 	return fmt.Errorf("invalid mode %s: must be an octal number", mode)
 }
 
+// Length of the hex digest of the supported checksum algorithms
+var checksumDigestLengths = map[string]int{
+	"sha256": 64,
+	"sha384": 96,
+	"sha512": 128,
+}
+
+// validateFileSource checks that the given URL can be fetched at build time
+// and that the checksum is a valid digest for its algorithm
+func validateFileSource(source, checksum string) error {
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return fmt.Errorf("invalid source %q: %v", source, err)
+	}
+	if sourceURL.Scheme != "http" && sourceURL.Scheme != "https" {
+		return fmt.Errorf("invalid source %q: scheme must be http or https", source)
+	}
+	if sourceURL.Host == "" {
+		return fmt.Errorf("invalid source %q: host must not be empty", source)
+	}
+
+	if checksum == "" {
+		return fmt.Errorf("source %q requires a checksum", source)
+	}
+	algorithm, digest, _ := strings.Cut(checksum, ":")
+	length, ok := checksumDigestLengths[algorithm]
+	if !ok {
+		return fmt.Errorf("invalid checksum %q: algorithm must be one of sha256, sha384 or sha512", checksum)
+	}
+	if !regexp.MustCompile(fmt.Sprintf(`^[0-9a-f]{%d}$`, length)).MatchString(digest) {
+		return fmt.Errorf("invalid checksum %q: digest must be %d lowercase hex characters", checksum, length)
+	}
+	return nil
+}
+
 // DirectoryCustomization represents a directory to be created in the image
 type DirectoryCustomization struct {
 	// Absolute path to the directory
@@ -190,6 +226,12 @@ type FileCustomization struct {
 	Mode string `json:"mode,omitempty" toml:"mode,omitempty"`
 	// Data is the file content in plain text
 	Data string `json:"data,omitempty" toml:"data,omitempty"`
+	// Source is the http(s) URL the file content is fetched from at build
+	// time, as an alternative to Data
+	Source string `json:"source,omitempty" toml:"source,omitempty"`
+	// Checksum of the content fetched from Source as "algorithm:digest".
+	// Required with Source, since the content is verified before it is used.
+	Checksum string `json:"checksum,omitempty" toml:"checksum,omitempty"`
 }
 
 // Custom TOML unmarshalling for FileCustomization with validation
@@ -245,6 +287,24 @@ func (f *FileCustomization) UnmarshalTOML(data interface{}) error {
 		return fmt.Errorf("UnmarshalTOML: data must be a string")
 	}
 
+	switch source := dataMap["source"].(type) {
+	case string:
+		file.Source = source
+	case nil:
+		break
+	default:
+		return fmt.Errorf("UnmarshalTOML: source must be a string")
+	}
+
+	switch checksum := dataMap["checksum"].(type) {
+	case string:
+		file.Checksum = checksum
+	case nil:
+		break
+	default:
+		return fmt.Errorf("UnmarshalTOML: checksum must be a string")
+	}
+
 	// try converting to fsnode.File to validate all values
 	_, err := file.ToFsNodeFile()
 	if err != nil {
@@ -309,6 +369,19 @@ func (f FileCustomization) ToFsNodeFile() (*fsnode.File, error) {
 		mode = common.ToPtr(os.FileMode(modeNum))
 	}
 
+	if f.Source != "" {
+		if f.Data != "" {
+			return nil, fmt.Errorf("file %q: data and source are mutually exclusive", f.Path)
+		}
+		if err := validateFileSource(f.Source, f.Checksum); err != nil {
+			return nil, fmt.Errorf("file %q: %v", f.Path, err)
+		}
+		return fsnode.NewRemoteFile(f.Path, mode, f.User, f.Group, f.Source, f.Checksum)
+	}
+	if f.Checksum != "" {
+		return nil, fmt.Errorf("file %q: checksum requires a source", f.Path)
+	}
+
 	return fsnode.NewFile(f.Path, mode, f.User, f.Group, data)
 }
 
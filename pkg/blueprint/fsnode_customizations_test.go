@@ -616,6 +616,69 @@ func TestFileCustomizationToFsNodeFile(t *testing.T) {
 			},
 			Want: ensureFileCreation(fsnode.NewFile("/etc/file", nil, nil, nil, []byte("hello world"))),
 		},
+		{
+			Name: "path-and-source",
+			File: FileCustomization{
+				Path:     "/etc/file",
+				Mode:     "0644",
+				Source:   "https://example.com/file",
+				Checksum: "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+			},
+			Want: ensureFileCreation(fsnode.NewRemoteFile("/etc/file", common.ToPtr(os.FileMode(0644)), nil, nil, "https://example.com/file", "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9")),
+		},
+		{
+			Name: "source-and-data",
+			File: FileCustomization{
+				Path:     "/etc/file",
+				Data:     "hello world",
+				Source:   "https://example.com/file",
+				Checksum: "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+			},
+			Error: true,
+		},
+		{
+			Name: "source-invalid-scheme",
+			File: FileCustomization{
+				Path:     "/etc/file",
+				Source:   "file:///etc/file",
+				Checksum: "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+			},
+			Error: true,
+		},
+		{
+			Name: "source-without-checksum",
+			File: FileCustomization{
+				Path:   "/etc/file",
+				Source: "https://example.com/file",
+			},
+			Error: true,
+		},
+		{
+			Name: "source-checksum-length-mismatch",
+			File: FileCustomization{
+				Path:     "/etc/file",
+				Source:   "https://example.com/file",
+				Checksum: "sha512:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+			},
+			Error: true,
+		},
+		{
+			Name: "source-checksum-unsupported-algorithm",
+			File: FileCustomization{
+				Path:     "/etc/file",
+				Source:   "https://example.com/file",
+				Checksum: "md5:5eb63bbbe01eeed093cb22bb8f5acdc3",
+			},
+			Error: true,
+		},
+		{
+			Name: "checksum-without-source",
+			File: FileCustomization{
+				Path:     "/etc/file",
+				Checksum: "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+			},
+			Error: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -825,6 +888,26 @@ group = -1
 `,
 			Error: true,
 		},
+		{
+			Name: "remote-file",
+			TOML: `
+name = "test"
+description = "Test"
+version = "0.0.0"
+
+[[customizations.files]]
+path = "/opt/asset.bin"
+source = "https://example.com/asset.bin"
+checksum = "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+`,
+			Want: []FileCustomization{
+				{
+					Path:     "/opt/asset.bin",
+					Source:   "https://example.com/asset.bin",
+					Checksum: "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
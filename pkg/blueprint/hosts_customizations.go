@@ -0,0 +1,93 @@
+package blueprint
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+const HostsFilePath = "/etc/hosts"
+
+// The content of /etc/hosts as shipped by the setup package, which the hosts
+// entries are appended to
+const defaultHostsFileContent = `127.0.0.1   localhost localhost.localdomain localhost4 localhost4.localdomain4
+::1         localhost localhost.localdomain localhost6 localhost6.localdomain6
+`
+
+type hostsEntry struct {
+	addr  netip.Addr
+	names []string
+}
+
+// parseHostsEntries validates the entries and returns them sorted by address.
+func parseHostsEntries(entries map[string][]string) ([]hostsEntry, error) {
+	parsed := make([]hostsEntry, 0, len(entries))
+	seen := make(map[netip.Addr]string, len(entries))
+	for ip, names := range entries {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return nil, fmt.Errorf("hosts entry %q is not a valid IP address", ip)
+		}
+		if other, exists := seen[addr]; exists {
+			// sort the two spellings so the error does not depend on the map iteration order
+			dups := []string{ip, other}
+			sort.Strings(dups)
+			return nil, fmt.Errorf("hosts entries %q and %q are the same IP address", dups[0], dups[1])
+		}
+		seen[addr] = ip
+
+		if len(names) == 0 {
+			return nil, fmt.Errorf("hosts entry %q has no names", ip)
+		}
+		for _, name := range names {
+			if !isValidHostname(name) {
+				return nil, fmt.Errorf("hosts entry %q: %q is not a valid hostname", ip, name)
+			}
+		}
+		parsed = append(parsed, hostsEntry{addr: addr, names: names})
+	}
+
+	sort.Slice(parsed, func(i, j int) bool {
+		return parsed[i].addr.Less(parsed[j].addr)
+	})
+	return parsed, nil
+}
+
+// ValidateHostsEntriesCustomization validates the given HostsEntries customization.
+// If the customization is invalid, an error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - Every key is a valid IP address and no address appears twice
+// - Every address has at least one name and all names are valid hostnames
+func ValidateHostsEntriesCustomization(entries map[string][]string) error {
+	_, err := parseHostsEntries(entries)
+	return err
+}
+
+// HostsEntriesCustomizationToFsNodeFile converts the HostsEntries
+// customization to an /etc/hosts file with the entries appended to the
+// default content. The entries are sorted by address, IPv4 before IPv6, so
+// that the file content does not depend on the map iteration order.
+func HostsEntriesCustomizationToFsNodeFile(entries map[string][]string) (*fsnode.File, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	parsed, err := parseHostsEntries(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString(defaultHostsFileContent)
+	for _, entry := range parsed {
+		fmt.Fprintf(&b, "%-11s %s\n", entry.addr.String(), strings.Join(entry.names, " "))
+	}
+
+	return fsnode.NewFile(HostsFilePath, common.ToPtr(os.FileMode(0644)), "root", "root", []byte(b.String()))
+}
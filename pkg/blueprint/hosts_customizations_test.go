@@ -0,0 +1,98 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostsEntriesCustomizationToFsNodeFile(t *testing.T) {
+	entries := map[string][]string{
+		"2001:db8::10":  {"ipv6.example.com"},
+		"192.168.0.10":  {"server.example.com", "server"},
+		"10.0.0.1":      {"gateway.example.com"},
+		"192.168.0.2":   {"registry.example.com"},
+		"2001:db8::0:1": {"router6.example.com"},
+	}
+
+	expected := `127.0.0.1   localhost localhost.localdomain localhost4 localhost4.localdomain4
+::1         localhost localhost.localdomain localhost6 localhost6.localdomain6
+10.0.0.1    gateway.example.com
+192.168.0.2 registry.example.com
+192.168.0.10 server.example.com server
+2001:db8::1 router6.example.com
+2001:db8::10 ipv6.example.com
+`
+
+	// the content must not depend on the map iteration order
+	for i := 0; i < 10; i++ {
+		file, err := HostsEntriesCustomizationToFsNodeFile(entries)
+		require.NoError(t, err)
+		assert.Equal(t, "/etc/hosts", file.Path())
+		assert.Equal(t, expected, string(file.Data()))
+		require.NotNil(t, file.Mode())
+		assert.Equal(t, os.FileMode(0644), *file.Mode())
+	}
+}
+
+func TestHostsEntriesCustomizationToFsNodeFileEmpty(t *testing.T) {
+	file, err := HostsEntriesCustomizationToFsNodeFile(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, file)
+}
+
+func TestValidateHostsEntriesCustomization(t *testing.T) {
+	testCases := []struct {
+		name    string
+		entries map[string][]string
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			entries: map[string][]string{"192.168.0.1": {"host.example.com", "host"}, "fe80::1": {"link-local"}},
+		},
+		{
+			name:    "invalid ip",
+			entries: map[string][]string{"192.168.0.256": {"host"}},
+			wantErr: `hosts entry "192.168.0.256" is not a valid IP address`,
+		},
+		{
+			name:    "cidr",
+			entries: map[string][]string{"192.168.0.0/24": {"host"}},
+			wantErr: `hosts entry "192.168.0.0/24" is not a valid IP address`,
+		},
+		{
+			name:    "same address",
+			entries: map[string][]string{"2001:db8::1": {"a"}, "2001:db8:0::1": {"b"}},
+			wantErr: `hosts entries "2001:db8:0::1" and "2001:db8::1" are the same IP address`,
+		},
+		{
+			name:    "no names",
+			entries: map[string][]string{"192.168.0.1": {}},
+			wantErr: `hosts entry "192.168.0.1" has no names`,
+		},
+		{
+			name:    "invalid name",
+			entries: map[string][]string{"192.168.0.1": {"host", "bad_name"}},
+			wantErr: `hosts entry "192.168.0.1": "bad_name" is not a valid hostname`,
+		},
+		{
+			name:    "name with whitespace",
+			entries: map[string][]string{"192.168.0.1": {"host other"}},
+			wantErr: `hosts entry "192.168.0.1": "host other" is not a valid hostname`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateHostsEntriesCustomization(tc.entries)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package blueprint
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// The URL schemes that ignition fetches remote configs with
+var ignitionURLSchemes = map[string]bool{
+	"http":  true,
+	"https": true,
+	"tftp":  true,
+	"s3":    true,
+	"gs":    true,
+	"data":  true,
+}
+
+// The version of the config specification, e.g. 3.4.0 or 3.5.0-experimental
+var ignitionVersionRegex = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+(-experimental)?$`)
+
+// ValidateIgnitionCustomization validates the given Ignition customization.
+// If the customization is invalid, an error is returned. Otherwise, nil is
+// returned.
+//
+// It currently ensures that:
+// - Only one of the embedded and the firstboot configurations is set
+// - The embedded config is a base64 encoded JSON object with an
+// ignition.version
+// - The firstboot provisioning URL is set and uses a scheme that ignition
+// can fetch configs with
+func ValidateIgnitionCustomization(ign *IgnitionCustomization) error {
+	if ign == nil {
+		return nil
+	}
+
+	if ign.Embedded != nil && ign.FirstBoot != nil {
+		return fmt.Errorf("both ignition embedded and firstboot configurations found")
+	}
+
+	if ign.Embedded != nil {
+		decoded, err := base64.StdEncoding.DecodeString(ign.Embedded.Config)
+		if err != nil {
+			return fmt.Errorf("ignition embedded config is not base64 encoded: %w", err)
+		}
+		var config struct {
+			Ignition struct {
+				Version string `json:"version"`
+			} `json:"ignition"`
+		}
+		if err := json.Unmarshal(decoded, &config); err != nil {
+			return fmt.Errorf("ignition embedded config is not a valid JSON object: %w", err)
+		}
+		if !ignitionVersionRegex.MatchString(config.Ignition.Version) {
+			return fmt.Errorf("ignition embedded config has an invalid ignition.version %q", config.Ignition.Version)
+		}
+	}
+
+	if ign.FirstBoot != nil {
+		if ign.FirstBoot.ProvisioningURL == "" {
+			return fmt.Errorf("ignition.firstboot requires a provisioning url")
+		}
+		u, err := url.Parse(ign.FirstBoot.ProvisioningURL)
+		if err != nil || !ignitionURLSchemes[u.Scheme] {
+			return fmt.Errorf("ignition.firstboot provisioning url %q is not a http, https, tftp, s3, gs or data URL", ign.FirstBoot.ProvisioningURL)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package blueprint
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateIgnitionCustomization(t *testing.T) {
+	encode := func(config string) string {
+		return base64.StdEncoding.EncodeToString([]byte(config))
+	}
+
+	testCases := []struct {
+		name    string
+		ign     *IgnitionCustomization
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "embedded",
+			ign:  &IgnitionCustomization{Embedded: &EmbeddedIgnitionCustomization{Config: encode(`{"ignition":{"version":"3.4.0"},"passwd":{"users":[{"name":"core"}]}}`)}},
+		},
+		{
+			name: "embedded experimental",
+			ign:  &IgnitionCustomization{Embedded: &EmbeddedIgnitionCustomization{Config: encode(`{"ignition":{"version":"3.5.0-experimental"}}`)}},
+		},
+		{
+			name: "firstboot",
+			ign:  &IgnitionCustomization{FirstBoot: &FirstBootIgnitionCustomization{ProvisioningURL: "https://example.com/config.ign"}},
+		},
+		{
+			name: "both",
+			ign: &IgnitionCustomization{
+				Embedded:  &EmbeddedIgnitionCustomization{Config: encode(`{"ignition":{"version":"3.4.0"}}`)},
+				FirstBoot: &FirstBootIgnitionCustomization{ProvisioningURL: "https://example.com/config.ign"},
+			},
+			wantErr: "both ignition embedded and firstboot configurations found",
+		},
+		{
+			name:    "not base64",
+			ign:     &IgnitionCustomization{Embedded: &EmbeddedIgnitionCustomization{Config: `{"ignition":{"version":"3.4.0"}}`}},
+			wantErr: "ignition embedded config is not base64 encoded: illegal base64 data at input byte 0",
+		},
+		{
+			name:    "not json",
+			ign:     &IgnitionCustomization{Embedded: &EmbeddedIgnitionCustomization{Config: encode(`variant: fcos`)}},
+			wantErr: "ignition embedded config is not a valid JSON object: invalid character 'v' looking for beginning of value",
+		},
+		{
+			name:    "no version",
+			ign:     &IgnitionCustomization{Embedded: &EmbeddedIgnitionCustomization{Config: encode(`{"passwd":{}}`)}},
+			wantErr: `ignition embedded config has an invalid ignition.version ""`,
+		},
+		{
+			name:    "no url",
+			ign:     &IgnitionCustomization{FirstBoot: &FirstBootIgnitionCustomization{}},
+			wantErr: "ignition.firstboot requires a provisioning url",
+		},
+		{
+			name:    "bad url scheme",
+			ign:     &IgnitionCustomization{FirstBoot: &FirstBootIgnitionCustomization{ProvisioningURL: "ftp://example.com/config.ign"}},
+			wantErr: `ignition.firstboot provisioning url "ftp://example.com/config.ign" is not a http, https, tftp, s3, gs or data URL`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateIgnitionCustomization(tc.ign)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// InstallerCustomization configures the kickstart file of installer image
+// types.
+type InstallerCustomization struct {
+	// Disk to wipe and install the system to, e.g. sda or /dev/disk/by-id/...
+	TargetDisk string `json:"target_disk,omitempty" toml:"target_disk,omitempty"`
+	// Reboot and eject the installation media when the installation finishes
+	Reboot bool `json:"reboot,omitempty" toml:"reboot,omitempty"`
+	// Install without prompting. The installer runs in command line mode and
+	// fails instead of asking for the settings that the kickstart misses.
+	Unattended bool `json:"unattended,omitempty" toml:"unattended,omitempty"`
+	// Kickstart content to add to the generated kickstart file
+	Kickstart *KickstartCustomization `json:"kickstart,omitempty" toml:"kickstart,omitempty"`
+	// Additional dracut modules and kernel drivers of the initrd of the
+	// installer, e.g. for the network or storage adapters of the target
+	// machines that the default initrd does not support
+	InitrdModules []string `json:"initrd_modules,omitempty" toml:"initrd_modules,omitempty"`
+	InitrdDrivers []string `json:"initrd_drivers,omitempty" toml:"initrd_drivers,omitempty"`
+}
+
+type KickstartCustomization struct {
+	Contents string `json:"contents" toml:"contents"`
+}
+
+// The name of a dracut module or of a kernel driver, e.g. "fcoe-uefi" or
+// "ipmi_devintf"
+var initrdModuleNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{0,63}$`)
+
+// Kickstart commands that are always part of the generated kickstart file
+var generatedKickstartCommands = []string{"ostreesetup", "liveimg"}
+
+// Kickstart commands generated when the target disk is set
+var targetDiskKickstartCommands = []string{"zerombr", "clearpart", "ignoredisk", "autopart", "part", "partition", "reqpart"}
+
+// Kickstart commands generated when reboot is set
+var rebootKickstartCommands = []string{"reboot", "poweroff", "halt", "shutdown"}
+
+// Kickstart commands generated when unattended is set
+var unattendedKickstartCommands = []string{"cmdline", "text", "graphical"}
+
+// Kickstart sections, ended by %end
+var kickstartSections = []string{"%pre", "%pre-install", "%post", "%packages", "%addon", "%onerror", "%traceback", "%anaconda"}
+
+// ValidateInstallerCustomization validates the given Installer customization.
+// If the customization is invalid, an error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - The target disk is a single device name or path
+// - An unattended installation has a target disk or a kickstart to partition
+// the disk with
+// - The initrd modules and drivers are valid dracut module and kernel driver
+// names
+// - The kickstart content does not contain commands that are generated from
+// the payload or the other installer settings
+// - All the kickstart sections are closed with %end
+func ValidateInstallerCustomization(ic *InstallerCustomization) error {
+	if ic == nil {
+		return nil
+	}
+
+	if ic.TargetDisk != "" && strings.ContainsAny(ic.TargetDisk, ", \t\n") {
+		return fmt.Errorf("installer target disk %q must be a single device name or path", ic.TargetDisk)
+	}
+
+	if ic.Unattended && ic.TargetDisk == "" && ic.Kickstart == nil {
+		return fmt.Errorf("installer unattended installation requires a target disk or a kickstart")
+	}
+
+	for _, module := range ic.InitrdModules {
+		if !initrdModuleNameRegex.MatchString(module) {
+			return fmt.Errorf("installer initrd module %q is not a valid dracut module name", module)
+		}
+	}
+	for _, driver := range ic.InitrdDrivers {
+		if !initrdModuleNameRegex.MatchString(driver) {
+			return fmt.Errorf("installer initrd driver %q is not a valid kernel driver name", driver)
+		}
+	}
+
+	if ic.Kickstart == nil {
+		return nil
+	}
+
+	conflicting := append([]string{}, generatedKickstartCommands...)
+	if ic.TargetDisk != "" {
+		conflicting = append(conflicting, targetDiskKickstartCommands...)
+	}
+	if ic.Reboot {
+		conflicting = append(conflicting, rebootKickstartCommands...)
+	}
+	if ic.Unattended {
+		conflicting = append(conflicting, unattendedKickstartCommands...)
+	}
+
+	section := ""
+	for _, line := range strings.Split(ic.Kickstart.Contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		command := fields[0]
+
+		if section != "" {
+			if command == "%end" {
+				section = ""
+			}
+			continue
+		}
+
+		for _, s := range kickstartSections {
+			if command == s {
+				section = s
+			}
+		}
+		if command == "%end" {
+			return fmt.Errorf("installer kickstart contains %%end outside of a section")
+		}
+		for _, c := range conflicting {
+			if command == c {
+				return fmt.Errorf("installer kickstart command %q conflicts with the generated kickstart", command)
+			}
+		}
+	}
+	if section != "" {
+		return fmt.Errorf("installer kickstart section %s is not closed with %%end", section)
+	}
+
+	return nil
+}
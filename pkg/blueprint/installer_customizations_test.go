@@ -0,0 +1,102 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateInstallerCustomization(t *testing.T) {
+	testCases := []struct {
+		name      string
+		installer *InstallerCustomization
+		wantErr   string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "valid",
+			installer: &InstallerCustomization{
+				TargetDisk: "/dev/disk/by-id/nvme-disk0",
+				Reboot:     true,
+				Kickstart: &KickstartCustomization{
+					Contents: "# comment\nrootpw --lock\n%post --log=/root/ks-post.log\nreboot\n%end\n",
+				},
+			},
+		},
+		{
+			name:      "partitioning without target disk",
+			installer: &InstallerCustomization{Kickstart: &KickstartCustomization{Contents: "zerombr\nclearpart --all\nautopart\npoweroff\n"}},
+		},
+		{
+			name:      "multiple target disks",
+			installer: &InstallerCustomization{TargetDisk: "sda,sdb"},
+			wantErr:   `installer target disk "sda,sdb" must be a single device name or path`,
+		},
+		{
+			name:      "payload command",
+			installer: &InstallerCustomization{Kickstart: &KickstartCustomization{Contents: "liveimg --url=http://example.com/image.tar\n"}},
+			wantErr:   `installer kickstart command "liveimg" conflicts with the generated kickstart`,
+		},
+		{
+			name:      "partitioning with target disk",
+			installer: &InstallerCustomization{TargetDisk: "sda", Kickstart: &KickstartCustomization{Contents: "  autopart --type=lvm\n"}},
+			wantErr:   `installer kickstart command "autopart" conflicts with the generated kickstart`,
+		},
+		{
+			name:      "reboot",
+			installer: &InstallerCustomization{Reboot: true, Kickstart: &KickstartCustomization{Contents: "poweroff\n"}},
+			wantErr:   `installer kickstart command "poweroff" conflicts with the generated kickstart`,
+		},
+		{
+			name:      "unattended",
+			installer: &InstallerCustomization{TargetDisk: "sda", Unattended: true},
+		},
+		{
+			name:      "unattended without target disk",
+			installer: &InstallerCustomization{Unattended: true},
+			wantErr:   `installer unattended installation requires a target disk or a kickstart`,
+		},
+		{
+			name:      "unattended display mode",
+			installer: &InstallerCustomization{TargetDisk: "sda", Unattended: true, Kickstart: &KickstartCustomization{Contents: "text\n"}},
+			wantErr:   `installer kickstart command "text" conflicts with the generated kickstart`,
+		},
+		{
+			name:      "unclosed section",
+			installer: &InstallerCustomization{Kickstart: &KickstartCustomization{Contents: "%post\necho done\n"}},
+			wantErr:   `installer kickstart section %post is not closed with %end`,
+		},
+		{
+			name:      "initrd modules and drivers",
+			installer: &InstallerCustomization{InitrdModules: []string{"fcoe-uefi", "nvdimm"}, InitrdDrivers: []string{"ipmi_devintf", "bnx2x"}},
+		},
+		{
+			name:      "invalid initrd module",
+			installer: &InstallerCustomization{InitrdModules: []string{"../nfs"}},
+			wantErr:   `installer initrd module "../nfs" is not a valid dracut module name`,
+		},
+		{
+			name:      "invalid initrd driver",
+			installer: &InstallerCustomization{InitrdDrivers: []string{"bnx2x.ko"}},
+			wantErr:   `installer initrd driver "bnx2x.ko" is not a valid kernel driver name`,
+		},
+		{
+			name:      "end outside of section",
+			installer: &InstallerCustomization{Kickstart: &KickstartCustomization{Contents: "%end\n"}},
+			wantErr:   `installer kickstart contains %end outside of a section`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateInstallerCustomization(tc.installer)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
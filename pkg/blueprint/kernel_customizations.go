@@ -0,0 +1,77 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// KernelVariants are the known variants of the default kernel package of the
+// distros, e.g. the real-time kernel. Which of them are available depends on
+// the distro and the architecture. Other kernel packages, e.g. of third-party
+// repositories, are not checked.
+var KernelVariants = []string{
+	"kernel-64k",
+	"kernel-64k-debug",
+	"kernel-debug",
+	"kernel-rt",
+	"kernel-rt-debug",
+	"kernel-zfcpdump",
+}
+
+// The name of a kernel package, without a version
+var kernelNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.+-]+$`)
+
+// ValidateKernelCustomization validates the name of the given kernel
+// customization against the kernel variants that are available for the
+// distro and the architecture of the image. If the customization is invalid,
+// an error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - The name is a package name, without a version
+// - The name is not a kernel variant that is unavailable
+// - The removed arguments are single, non-empty arguments
+func ValidateKernelCustomization(kernel *KernelCustomization, available []string) error {
+	if kernel == nil {
+		return nil
+	}
+
+	if kernel.Name != "" {
+		if !kernelNameRegex.MatchString(kernel.Name) {
+			return fmt.Errorf("kernel name %q is invalid", kernel.Name)
+		}
+		if slices.Contains(KernelVariants, kernel.Name) && !slices.Contains(available, kernel.Name) {
+			return fmt.Errorf("kernel variant %q is not available", kernel.Name)
+		}
+	}
+
+	for _, arg := range kernel.Remove {
+		if fields := strings.Fields(arg); len(fields) != 1 || fields[0] != arg {
+			return fmt.Errorf("removed kernel argument %q must be a single argument", arg)
+		}
+	}
+
+	return nil
+}
+
+// RemoveKernelOptions removes the arguments of the Remove list of the kernel
+// customization from the given kernel command line, e.g. the defaults of an
+// image type. An argument without a value, e.g. console, removes the
+// argument with any value, e.g. console=tty0 and console=ttyS0, while one
+// with a value only removes the same argument. Arguments that are not in the
+// command line are ignored.
+func (k *KernelCustomization) RemoveKernelOptions(options string) string {
+	if k == nil || len(k.Remove) == 0 {
+		return options
+	}
+	var kept []string
+	for _, arg := range strings.Fields(options) {
+		key, _, _ := strings.Cut(arg, "=")
+		if !slices.Contains(k.Remove, arg) && !slices.Contains(k.Remove, key) {
+			kept = append(kept, arg)
+		}
+	}
+	return strings.Join(kept, " ")
+}
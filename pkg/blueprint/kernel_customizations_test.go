@@ -0,0 +1,94 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateKernelCustomization(t *testing.T) {
+	available := []string{"kernel-debug", "kernel-rt"}
+
+	testCases := []struct {
+		name    string
+		kernel  *KernelCustomization
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:   "append only",
+			kernel: &KernelCustomization{Append: "nosmt"},
+		},
+		{
+			name:   "default",
+			kernel: &KernelCustomization{Name: "kernel"},
+		},
+		{
+			name:   "available variant",
+			kernel: &KernelCustomization{Name: "kernel-rt"},
+		},
+		{
+			name:   "third-party kernel",
+			kernel: &KernelCustomization{Name: "kernel-ml"},
+		},
+		{
+			name:    "unavailable variant",
+			kernel:  &KernelCustomization{Name: "kernel-64k"},
+			wantErr: `kernel variant "kernel-64k" is not available`,
+		},
+		{
+			name:   "remove",
+			kernel: &KernelCustomization{Remove: []string{"rhgb", "quiet", "console=tty0"}},
+		},
+		{
+			name:    "remove several in one",
+			kernel:  &KernelCustomization{Remove: []string{"rhgb quiet"}},
+			wantErr: `removed kernel argument "rhgb quiet" must be a single argument`,
+		},
+		{
+			name:    "remove empty",
+			kernel:  &KernelCustomization{Remove: []string{""}},
+			wantErr: `removed kernel argument "" must be a single argument`,
+		},
+		{
+			name:    "version",
+			kernel:  &KernelCustomization{Name: "kernel-rt 5.14.0"},
+			wantErr: `kernel name "kernel-rt 5.14.0" is invalid`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateKernelCustomization(tc.kernel, available)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemoveKernelOptions(t *testing.T) {
+	defaults := "ro rhgb quiet console=tty0 console=ttyS0,115200n8 crashkernel=auto"
+
+	var nilKernel *KernelCustomization
+	assert.Equal(t, defaults, nilKernel.RemoveKernelOptions(defaults))
+	assert.Equal(t, defaults, (&KernelCustomization{Append: "debug"}).RemoveKernelOptions(defaults))
+
+	kernel := &KernelCustomization{Remove: []string{"rhgb", "quiet"}}
+	assert.Equal(t, "ro console=tty0 console=ttyS0,115200n8 crashkernel=auto", kernel.RemoveKernelOptions(defaults))
+
+	// without a value, all the values of the argument are removed
+	kernel = &KernelCustomization{Remove: []string{"console"}}
+	assert.Equal(t, "ro rhgb quiet crashkernel=auto", kernel.RemoveKernelOptions(defaults))
+	kernel = &KernelCustomization{Remove: []string{"console=tty0"}}
+	assert.Equal(t, "ro rhgb quiet console=ttyS0,115200n8 crashkernel=auto", kernel.RemoveKernelOptions(defaults))
+
+	// removing arguments that are not there is a no-op
+	kernel = &KernelCustomization{Remove: []string{"splash", "crashkernel=1G"}}
+	assert.Equal(t, defaults, kernel.RemoveKernelOptions(defaults))
+	assert.Equal(t, "", kernel.RemoveKernelOptions(""))
+}
@@ -0,0 +1,98 @@
+package blueprint
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+// MachineIDPath holds the machine ID of the system, see machine-id(5).
+const MachineIDPath = "/etc/machine-id"
+
+const (
+	// MachineIDModeRegenerate empties the machine ID of the image, so that
+	// every system generates its own when it boots
+	MachineIDModeRegenerate = "regenerate"
+	// MachineIDModeFixed sets the machine ID of the image, so that all the
+	// systems have the same one
+	MachineIDModeFixed = "fixed"
+)
+
+// A machine ID: 32 lowercase hexadecimal characters
+var machineIDRegex = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// MachineIDCustomization defines how the machine ID is set on the systems
+// built from the image.
+type MachineIDCustomization struct {
+	// MachineIDModeRegenerate (default) or MachineIDModeFixed
+	Mode string `json:"mode,omitempty" toml:"mode,omitempty"`
+	// Machine ID of the fixed mode
+	ID string `json:"id,omitempty" toml:"id,omitempty"`
+}
+
+// GetMode returns the mode of the customization, MachineIDModeRegenerate
+// unless it is set.
+func (mid *MachineIDCustomization) GetMode() string {
+	if mid == nil || mid.Mode == "" {
+		return MachineIDModeRegenerate
+	}
+	return mid.Mode
+}
+
+// ValidateMachineIDCustomization validates the given MachineID customization
+// against the files of the blueprint. If the customization is invalid, an
+// error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - The mode is known
+// - The fixed mode has a machine ID of 32 lowercase hexadecimal characters,
+// which is not all zeros, and the regenerate mode has none
+// - The machine ID file is not also defined in the Files customization
+func ValidateMachineIDCustomization(mid *MachineIDCustomization, files []FileCustomization) error {
+	if mid == nil {
+		return nil
+	}
+
+	switch mid.GetMode() {
+	case MachineIDModeRegenerate:
+		if mid.ID != "" {
+			return fmt.Errorf("machine ID %q requires the %q mode", mid.ID, MachineIDModeFixed)
+		}
+	case MachineIDModeFixed:
+		if !machineIDRegex.MatchString(mid.ID) {
+			return fmt.Errorf("machine ID %q must be 32 lowercase hexadecimal characters", mid.ID)
+		}
+		if mid.ID == "00000000000000000000000000000000" {
+			return fmt.Errorf("machine ID must not be all zeros")
+		}
+	default:
+		return fmt.Errorf("unknown machine ID mode %q (valid modes: %s, %s)", mid.Mode, MachineIDModeRegenerate, MachineIDModeFixed)
+	}
+
+	for _, file := range files {
+		if path.Clean(file.Path) == MachineIDPath {
+			return fmt.Errorf("machine ID customizations cannot be combined with a custom %s file", MachineIDPath)
+		}
+	}
+
+	return nil
+}
+
+// MachineIDCustomizationToFsNodeFile converts the fixed mode of the MachineID
+// customization to the machine-id file. There is no file for the regenerate
+// mode.
+func MachineIDCustomizationToFsNodeFile(mid *MachineIDCustomization) (*fsnode.File, error) {
+	if mid.GetMode() != MachineIDModeFixed {
+		return nil, nil
+	}
+
+	if err := ValidateMachineIDCustomization(mid, nil); err != nil {
+		return nil, err
+	}
+
+	return fsnode.NewFile(MachineIDPath, common.ToPtr(os.FileMode(0444)), "root", "root", []byte(mid.ID+"\n"))
+}
@@ -0,0 +1,93 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMachineIDCustomization(t *testing.T) {
+	testCases := []struct {
+		name      string
+		machineID *MachineIDCustomization
+		files     []FileCustomization
+		wantErr   string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:      "default",
+			machineID: &MachineIDCustomization{},
+		},
+		{
+			name:      "regenerate",
+			machineID: &MachineIDCustomization{Mode: MachineIDModeRegenerate},
+		},
+		{
+			name:      "fixed",
+			machineID: &MachineIDCustomization{Mode: MachineIDModeFixed, ID: "4f3b5c1e2a9d4c7b8e6f1a2b3c4d5e6f"},
+		},
+		{
+			name:      "regenerate with id",
+			machineID: &MachineIDCustomization{ID: "4f3b5c1e2a9d4c7b8e6f1a2b3c4d5e6f"},
+			wantErr:   `machine ID "4f3b5c1e2a9d4c7b8e6f1a2b3c4d5e6f" requires the "fixed" mode`,
+		},
+		{
+			name:      "fixed without id",
+			machineID: &MachineIDCustomization{Mode: MachineIDModeFixed},
+			wantErr:   `machine ID "" must be 32 lowercase hexadecimal characters`,
+		},
+		{
+			name:      "uppercase id",
+			machineID: &MachineIDCustomization{Mode: MachineIDModeFixed, ID: "4F3B5C1E2A9D4C7B8E6F1A2B3C4D5E6F"},
+			wantErr:   `machine ID "4F3B5C1E2A9D4C7B8E6F1A2B3C4D5E6F" must be 32 lowercase hexadecimal characters`,
+		},
+		{
+			name:      "uuid id",
+			machineID: &MachineIDCustomization{Mode: MachineIDModeFixed, ID: "4f3b5c1e-2a9d-4c7b-8e6f-1a2b3c4d5e6f"},
+			wantErr:   `machine ID "4f3b5c1e-2a9d-4c7b-8e6f-1a2b3c4d5e6f" must be 32 lowercase hexadecimal characters`,
+		},
+		{
+			name:      "zero id",
+			machineID: &MachineIDCustomization{Mode: MachineIDModeFixed, ID: "00000000000000000000000000000000"},
+			wantErr:   "machine ID must not be all zeros",
+		},
+		{
+			name:      "unknown mode",
+			machineID: &MachineIDCustomization{Mode: "firstboot"},
+			wantErr:   `unknown machine ID mode "firstboot" (valid modes: regenerate, fixed)`,
+		},
+		{
+			name:      "custom file",
+			machineID: &MachineIDCustomization{},
+			files:     []FileCustomization{{Path: "/etc/machine-id"}},
+			wantErr:   "machine ID customizations cannot be combined with a custom /etc/machine-id file",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateMachineIDCustomization(tc.machineID, tc.files)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestMachineIDCustomizationToFsNodeFile(t *testing.T) {
+	file, err := MachineIDCustomizationToFsNodeFile(&MachineIDCustomization{})
+	assert.NoError(t, err)
+	assert.Nil(t, file)
+
+	file, err = MachineIDCustomizationToFsNodeFile(&MachineIDCustomization{Mode: MachineIDModeFixed, ID: "4f3b5c1e2a9d4c7b8e6f1a2b3c4d5e6f"})
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/machine-id", file.Path())
+	assert.Equal(t, os.FileMode(0444), *file.Mode())
+	assert.Equal(t, "4f3b5c1e2a9d4c7b8e6f1a2b3c4d5e6f\n", string(file.Data()))
+}
@@ -0,0 +1,167 @@
+package blueprint
+
+import (
+	"reflect"
+)
+
+// Fields that identify an entry of a list, in order of precedence. Entries of
+// an overlay list replace the entries of the base list with the same identity.
+var mergeIdentityFields = []string{"Name", "Path", "Mountpoint", "Id", "Hostname", "Source", "Location"}
+
+// Merge returns a new blueprint with the overlay applied on top of the base,
+// e.g. a per-environment blueprint on top of a common one. The result does not
+// share any memory with the inputs, which are not modified.
+//
+// The rules are:
+// - Scalars set in the overlay replace the ones of the base. Zero values
+// (empty strings, false, 0 and nil pointers) are unset and never replace a
+// value of the base.
+// - Structs, such as the customizations, are merged field by field.
+// - Lists are appended to the list of the base. An overlay entry with the
+// same Name, Path, Mountpoint, Id, Hostname, Source or Location as a base
+// entry (the first of these fields that the entry has) replaces the base
+// entry instead, and strings that are already in the base list are not added
+// again.
+// - Maps are merged key by key, the values of the overlay replacing the ones
+// of the base.
+func Merge(base, overlay Blueprint) Blueprint {
+	return mergeValues(reflect.ValueOf(base), reflect.ValueOf(overlay)).Interface().(Blueprint)
+}
+
+func mergeValues(base, overlay reflect.Value) reflect.Value {
+	switch base.Kind() {
+	case reflect.Struct:
+		merged := reflect.New(base.Type()).Elem()
+		for idx := 0; idx < base.NumField(); idx++ {
+			merged.Field(idx).Set(mergeValues(base.Field(idx), overlay.Field(idx)))
+		}
+		return merged
+	case reflect.Pointer:
+		if overlay.IsNil() {
+			return deepCopy(base)
+		}
+		if base.IsNil() || base.Elem().Kind() != reflect.Struct {
+			return deepCopy(overlay)
+		}
+		merged := reflect.New(base.Type().Elem())
+		merged.Elem().Set(mergeValues(base.Elem(), overlay.Elem()))
+		return merged
+	case reflect.Slice:
+		return mergeSlices(base, overlay)
+	case reflect.Map:
+		if base.IsNil() && overlay.IsNil() {
+			return reflect.Zero(base.Type())
+		}
+		merged := reflect.MakeMap(base.Type())
+		for _, src := range []reflect.Value{base, overlay} {
+			iter := src.MapRange()
+			for iter.Next() {
+				merged.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+			}
+		}
+		return merged
+	default:
+		if overlay.IsZero() {
+			return deepCopy(base)
+		}
+		return deepCopy(overlay)
+	}
+}
+
+func mergeSlices(base, overlay reflect.Value) reflect.Value {
+	if overlay.Len() == 0 {
+		return deepCopy(base)
+	}
+
+	merged := deepCopy(base)
+	if merged.IsNil() {
+		merged = reflect.MakeSlice(base.Type(), 0, overlay.Len())
+	}
+	for idx := 0; idx < overlay.Len(); idx++ {
+		entry := overlay.Index(idx)
+		if pos := findEntry(merged, entry); pos >= 0 {
+			merged.Index(pos).Set(deepCopy(entry))
+		} else {
+			merged = reflect.Append(merged, deepCopy(entry))
+		}
+	}
+	return merged
+}
+
+// findEntry returns the index of the entry of the list with the same identity
+// as the given entry or -1 if there is none. Entries without an identity field
+// are only found if they are equal.
+func findEntry(list, entry reflect.Value) int {
+	var identityField string
+	if entry.Kind() == reflect.Struct {
+		for _, name := range mergeIdentityFields {
+			if _, ok := entry.Type().FieldByName(name); ok {
+				identityField = name
+				break
+			}
+		}
+	}
+	identity := func(v reflect.Value) interface{} {
+		if identityField == "" {
+			return v.Interface()
+		}
+		return v.FieldByName(identityField).Interface()
+	}
+
+	key := identity(entry)
+	for idx := 0; idx < list.Len(); idx++ {
+		if reflect.DeepEqual(identity(list.Index(idx)), key) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// deepCopy returns a copy of the value that does not share any memory with it.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		for idx := 0; idx < v.NumField(); idx++ {
+			c.Field(idx).Set(deepCopy(v.Field(idx)))
+		}
+		return c
+	case reflect.Pointer:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for idx := 0; idx < v.Len(); idx++ {
+			c.Index(idx).Set(deepCopy(v.Index(idx)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	default:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		return c
+	}
+}
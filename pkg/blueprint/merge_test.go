@@ -0,0 +1,124 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/internal/common"
+)
+
+func TestMergeScalars(t *testing.T) {
+	base := Blueprint{
+		Name:        "base",
+		Description: "common blueprint",
+		Version:     "1.0.0",
+		Customizations: &Customizations{
+			Hostname: common.ToPtr("base-host"),
+			Timezone: &TimezoneCustomization{Timezone: common.ToPtr("UTC")},
+		},
+	}
+	overlay := Blueprint{
+		Name:    "prod",
+		Version: "1.1.0",
+		Customizations: &Customizations{
+			Hostname: common.ToPtr("prod-host"),
+		},
+	}
+
+	merged := Merge(base, overlay)
+	assert.Equal(t, "prod", merged.Name)
+	assert.Equal(t, "common blueprint", merged.Description)
+	assert.Equal(t, "1.1.0", merged.Version)
+	assert.Equal(t, "prod-host", *merged.Customizations.Hostname)
+	assert.Equal(t, "UTC", *merged.Customizations.Timezone.Timezone)
+}
+
+func TestMergeLists(t *testing.T) {
+	base := Blueprint{
+		Packages: []Package{{Name: "tmux", Version: "3.2"}, {Name: "vim"}},
+		Customizations: &Customizations{
+			Services: &ServicesCustomization{Enabled: []string{"sshd", "chronyd"}},
+		},
+	}
+	overlay := Blueprint{
+		Packages: []Package{{Name: "tmux", Version: "3.3a"}, {Name: "htop"}},
+		Customizations: &Customizations{
+			Services: &ServicesCustomization{Enabled: []string{"chronyd", "podman"}},
+		},
+	}
+
+	merged := Merge(base, overlay)
+	assert.Equal(t, []Package{{Name: "tmux", Version: "3.3a"}, {Name: "vim"}, {Name: "htop"}}, merged.Packages)
+	assert.Equal(t, []string{"sshd", "chronyd", "podman"}, merged.Customizations.Services.Enabled)
+
+	// an empty overlay list keeps the base list
+	merged = Merge(base, Blueprint{})
+	assert.Equal(t, base.Packages, merged.Packages)
+}
+
+func TestMergeNestedCustomizations(t *testing.T) {
+	base := Blueprint{
+		Customizations: &Customizations{
+			Kernel: &KernelCustomization{Name: "kernel-rt", Append: "nosmt"},
+			Firewall: &FirewallCustomization{
+				Ports:    []string{"22:tcp"},
+				Services: &FirewallServicesCustomization{Enabled: []string{"ssh"}},
+			},
+			Sysctl: map[string]string{"vm.swappiness": "10", "net.ipv4.ip_forward": "0"},
+		},
+	}
+	overlay := Blueprint{
+		Customizations: &Customizations{
+			Kernel: &KernelCustomization{Append: "console=ttyS0"},
+			Firewall: &FirewallCustomization{
+				Services: &FirewallServicesCustomization{Enabled: []string{"https"}},
+			},
+			Sysctl: map[string]string{"net.ipv4.ip_forward": "1"},
+		},
+	}
+
+	merged := Merge(base, overlay)
+	assert.Equal(t, &KernelCustomization{Name: "kernel-rt", Append: "console=ttyS0"}, merged.Customizations.Kernel)
+	assert.Equal(t, []string{"22:tcp"}, merged.Customizations.Firewall.Ports)
+	assert.Equal(t, []string{"ssh", "https"}, merged.Customizations.Firewall.Services.Enabled)
+	assert.Equal(t, map[string]string{"vm.swappiness": "10", "net.ipv4.ip_forward": "1"}, merged.Customizations.Sysctl)
+
+	// customizations only set on one side are taken as they are
+	assert.Equal(t, base.Customizations, Merge(base, Blueprint{}).Customizations)
+	assert.Equal(t, overlay.Customizations, Merge(Blueprint{}, overlay).Customizations)
+}
+
+func TestMergeDoesNotModifyInputs(t *testing.T) {
+	newBase := func() Blueprint {
+		return Blueprint{
+			Packages: []Package{{Name: "tmux"}},
+			Customizations: &Customizations{
+				Hostname: common.ToPtr("base-host"),
+				Kernel:   &KernelCustomization{Append: "nosmt"},
+				Sysctl:   map[string]string{"vm.swappiness": "10"},
+			},
+		}
+	}
+	newOverlay := func() Blueprint {
+		return Blueprint{
+			Packages: []Package{{Name: "htop"}},
+			Customizations: &Customizations{
+				Services: &ServicesCustomization{Enabled: []string{"sshd"}},
+			},
+		}
+	}
+	base := newBase()
+	overlay := newOverlay()
+
+	merged := Merge(base, overlay)
+	merged.Packages[0].Name = "screen"
+	merged.Packages[1].Name = "btop"
+	*merged.Customizations.Hostname = "changed"
+	merged.Customizations.Kernel.Append = "changed"
+	merged.Customizations.Sysctl["vm.swappiness"] = "60"
+	merged.Customizations.Services.Enabled[0] = "changed"
+
+	assert.Equal(t, newBase(), base)
+	assert.Equal(t, newOverlay(), overlay)
+}
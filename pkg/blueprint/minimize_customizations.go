@@ -0,0 +1,84 @@
+package blueprint
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+// RPMLanguagesMacroPath is the rpm macro file that limits the translations
+// of the packages installed on the system, the same as the one of anaconda.
+const RPMLanguagesMacroPath = "/etc/rpm/macros.image-language-conf"
+
+// A language of the _install_langs rpm macro, e.g. de or pt_BR
+var minimizeLocaleRegex = regexp.MustCompile(`^[a-z]{2,3}(_[A-Z]{2})?$`)
+
+// MinimizeCustomization leaves out the documentation and the translations
+// that most systems do not need, to make the image smaller. The packages
+// installed on the system later are trimmed the same way.
+type MinimizeCustomization struct {
+	// Do not install the documentation of the packages
+	NoDocs bool `json:"nodocs,omitempty" toml:"nodocs,omitempty"`
+	// Languages, as ll or ll_CC, whose translations are installed, e.g.
+	// ["en", "de_DE"]. All translations are installed if empty.
+	Locales []string `json:"locales,omitempty" toml:"locales,omitempty"`
+}
+
+// ValidateMinimizeCustomization validates the given Minimize customization
+// against the locale of the image, e.g. en_US.UTF-8. If the customization is
+// invalid, an error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - Locales are unique ll or ll_CC language codes
+// - The language of the locale of the image is kept
+func ValidateMinimizeCustomization(m *MinimizeCustomization, locale string) error {
+	if m == nil || len(m.Locales) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(m.Locales))
+	for _, l := range m.Locales {
+		if !minimizeLocaleRegex.MatchString(l) {
+			return fmt.Errorf("minimize locale %q is invalid: must be a language code like en or en_US", l)
+		}
+		if seen[l] {
+			return fmt.Errorf("duplicate minimize locale %q", l)
+		}
+		seen[l] = true
+	}
+
+	// the C and POSIX locales are part of glibc and always kept
+	language, _, _ := strings.Cut(locale, ".")
+	language, _, _ = strings.Cut(language, "@")
+	switch language {
+	case "", "C", "POSIX":
+		return nil
+	}
+	base, _, _ := strings.Cut(language, "_")
+	if !seen[language] && !seen[base] {
+		return fmt.Errorf("minimize locales %s do not keep the locale %q of the image", strings.Join(m.Locales, ", "), locale)
+	}
+
+	return nil
+}
+
+// MinimizeCustomizationToFsNodeFile converts the locales of the Minimize
+// customization to an rpm macro file, so that the packages installed on the
+// system later only get the same translations. It returns nil if all the
+// translations are kept.
+func MinimizeCustomizationToFsNodeFile(m *MinimizeCustomization) (*fsnode.File, error) {
+	if m == nil || len(m.Locales) == 0 {
+		return nil, nil
+	}
+
+	if err := ValidateMinimizeCustomization(m, ""); err != nil {
+		return nil, err
+	}
+
+	macro := fmt.Sprintf("%%_install_langs %s\n", strings.Join(m.Locales, ":"))
+	return fsnode.NewFile(RPMLanguagesMacroPath, common.ToPtr(os.FileMode(0644)), "root", "root", []byte(macro))
+}
@@ -0,0 +1,96 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMinimizeCustomization(t *testing.T) {
+	testCases := []struct {
+		name    string
+		m       *MinimizeCustomization
+		locale  string
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:   "only docs",
+			m:      &MinimizeCustomization{NoDocs: true},
+			locale: "de_DE.UTF-8",
+		},
+		{
+			name:   "language",
+			m:      &MinimizeCustomization{Locales: []string{"en", "de"}},
+			locale: "de_DE.UTF-8",
+		},
+		{
+			name:   "language and country",
+			m:      &MinimizeCustomization{Locales: []string{"pt_BR"}},
+			locale: "pt_BR.UTF-8",
+		},
+		{
+			name:   "modifier",
+			m:      &MinimizeCustomization{Locales: []string{"sr"}},
+			locale: "sr_RS@latin",
+		},
+		{
+			name:   "C locale",
+			m:      &MinimizeCustomization{Locales: []string{"fr"}},
+			locale: "C.UTF-8",
+		},
+		{
+			name:    "invalid",
+			m:       &MinimizeCustomization{Locales: []string{"en_US.UTF-8"}},
+			wantErr: `minimize locale "en_US.UTF-8" is invalid: must be a language code like en or en_US`,
+		},
+		{
+			name:    "invalid case",
+			m:       &MinimizeCustomization{Locales: []string{"EN"}},
+			wantErr: `minimize locale "EN" is invalid: must be a language code like en or en_US`,
+		},
+		{
+			name:    "duplicate",
+			m:       &MinimizeCustomization{Locales: []string{"en", "en"}},
+			wantErr: `duplicate minimize locale "en"`,
+		},
+		{
+			name:    "locale of the image not kept",
+			m:       &MinimizeCustomization{Locales: []string{"de", "en_GB"}},
+			locale:  "en_US.UTF-8",
+			wantErr: `minimize locales de, en_GB do not keep the locale "en_US.UTF-8" of the image`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateMinimizeCustomization(tc.m, tc.locale)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestMinimizeCustomizationToFsNodeFile(t *testing.T) {
+	file, err := MinimizeCustomizationToFsNodeFile(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, file)
+
+	file, err = MinimizeCustomizationToFsNodeFile(&MinimizeCustomization{NoDocs: true})
+	assert.NoError(t, err)
+	assert.Nil(t, file)
+
+	file, err = MinimizeCustomizationToFsNodeFile(&MinimizeCustomization{Locales: []string{"en", "de_DE"}})
+	require.NoError(t, err)
+	require.NotNil(t, file)
+	assert.Equal(t, "/etc/rpm/macros.image-language-conf", file.Path())
+	assert.Equal(t, "%_install_langs en:de_DE\n", string(file.Data()))
+	assert.Equal(t, os.FileMode(0644), *file.Mode())
+}
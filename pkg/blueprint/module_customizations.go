@@ -0,0 +1,33 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// A module stream specification: name:stream with an optional /profile
+var moduleSpecRegex = regexp.MustCompile(`^([a-zA-Z0-9_.+-]+):([a-zA-Z0-9_.+-]+)(/[a-zA-Z0-9_.+-]+)?$`)
+
+// ValidateModuleCustomization validates the given module stream
+// specifications. If any of them is invalid, an error is returned. Otherwise,
+// nil is returned.
+//
+// It currently ensures that:
+// - Every module is specified as name:stream or name:stream/profile
+// - Only one stream of each module is enabled
+func ValidateModuleCustomization(modules []string) error {
+	seen := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		match := moduleSpecRegex.FindStringSubmatch(module)
+		if match == nil {
+			return fmt.Errorf("module %q must be specified as name:stream or name:stream/profile", module)
+		}
+		name := match[1]
+		if seen[name] {
+			return fmt.Errorf("module %q is enabled more than once", name)
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
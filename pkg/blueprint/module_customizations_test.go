@@ -0,0 +1,59 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateModuleCustomization(t *testing.T) {
+	testCases := []struct {
+		name    string
+		modules []string
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "valid",
+			modules: []string{"nodejs:18", "postgresql:15/server", "perl-DBI:1.641"},
+		},
+		{
+			name:    "no stream",
+			modules: []string{"nodejs"},
+			wantErr: `module "nodejs" must be specified as name:stream or name:stream/profile`,
+		},
+		{
+			name:    "empty profile",
+			modules: []string{"nodejs:18/"},
+			wantErr: `module "nodejs:18/" must be specified as name:stream or name:stream/profile`,
+		},
+		{
+			name:    "multiple profiles",
+			modules: []string{"nodejs:18/common/development"},
+			wantErr: `module "nodejs:18/common/development" must be specified as name:stream or name:stream/profile`,
+		},
+		{
+			name:    "whitespace",
+			modules: []string{"nodejs: 18"},
+			wantErr: `module "nodejs: 18" must be specified as name:stream or name:stream/profile`,
+		},
+		{
+			name:    "multiple streams",
+			modules: []string{"nodejs:18", "nodejs:20/common"},
+			wantErr: `module "nodejs" is enabled more than once`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateModuleCustomization(tc.modules)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
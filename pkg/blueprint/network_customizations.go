@@ -0,0 +1,292 @@
+package blueprint
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+// NetworkManagerConnectionsDir is the directory where NetworkManager looks for
+// keyfile connection profiles.
+const NetworkManagerConnectionsDir = "/etc/NetworkManager/system-connections"
+
+const (
+	NetworkConnectionTypeEthernet = "ethernet"
+	NetworkConnectionTypeBond     = "bond"
+	NetworkConnectionTypeBridge   = "bridge"
+)
+
+// The connection name is used both as the profile ID and as the base name of the keyfile.
+const networkConnectionNameRegex = `^[\w.-]{1,200}$`
+
+// Maximum length of a Linux interface name (IFNAMSIZ - 1)
+const maxInterfaceNameLen = 15
+
+// NetworkCustomization holds the NetworkManager connection profiles to be
+// created in the image.
+type NetworkCustomization struct {
+	Connections []NetworkConnectionCustomization `json:"connections,omitempty" toml:"connections,omitempty"`
+}
+
+// NetworkConnectionCustomization represents a single NetworkManager
+// connection profile.
+type NetworkConnectionCustomization struct {
+	// Name of the connection profile, also used for the keyfile name
+	Name string `json:"name" toml:"name"`
+	// Type of the connection: ethernet (default), bond or bridge
+	Type string `json:"type,omitempty" toml:"type,omitempty"`
+	// Name of the interface the connection is bound to. Defaults to Name.
+	Interface string `json:"interface,omitempty" toml:"interface,omitempty"`
+	// Static IPv4 and IPv6 addresses in CIDR notation. The address families
+	// without a static address are configured automatically.
+	Addresses []string `json:"addresses,omitempty" toml:"addresses,omitempty"`
+	// Default gateway, must match the family of one of the static addresses
+	Gateway string `json:"gateway,omitempty" toml:"gateway,omitempty"`
+	// IP addresses of the DNS servers
+	DNS []string `json:"dns,omitempty" toml:"dns,omitempty"`
+	// Name of the bond or bridge connection this connection is a port of
+	Controller string `json:"controller,omitempty" toml:"controller,omitempty"`
+	// Bonding mode for bond connections (e.g. active-backup, 802.3ad)
+	BondMode string `json:"bond_mode,omitempty" toml:"bond_mode,omitempty"`
+}
+
+func (c *NetworkConnectionCustomization) getType() string {
+	if c.Type == "" {
+		return NetworkConnectionTypeEthernet
+	}
+	return c.Type
+}
+
+func (c *NetworkConnectionCustomization) getInterface() string {
+	if c.Interface == "" {
+		return c.Name
+	}
+	return c.Interface
+}
+
+func (c *NetworkConnectionCustomization) getFilename() string {
+	return path.Join(NetworkManagerConnectionsDir, c.Name+".nmconnection")
+}
+
+// validateInterfaceName checks the name against the rules the kernel applies
+// to network interface names.
+func validateInterfaceName(name string) error {
+	if name == "" || len(name) > maxInterfaceNameLen {
+		return fmt.Errorf("interface name %q must be between 1 and %d characters long", name, maxInterfaceNameLen)
+	}
+	if name == "." || name == ".." {
+		return fmt.Errorf("interface name %q is not allowed", name)
+	}
+	if strings.ContainsAny(name, "/: \t\n") {
+		return fmt.Errorf("interface name %q must not contain '/', ':' or whitespace", name)
+	}
+	return nil
+}
+
+// ValidateNetworkCustomization validates the given Network customization.
+// If the customization is invalid, an error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - Connection names are valid file names and unique
+// - Interface names are valid and unique
+// - Addresses are in CIDR notation and the gateway and DNS servers are IP addresses
+// - Ports reference an existing bond or bridge connection and have no IP configuration
+func ValidateNetworkCustomization(nc *NetworkCustomization) error {
+	if nc == nil {
+		return nil
+	}
+
+	nameRegex := regexp.MustCompile(networkConnectionNameRegex)
+	connections := make(map[string]NetworkConnectionCustomization, len(nc.Connections))
+	interfaces := make(map[string]bool, len(nc.Connections))
+	for _, conn := range nc.Connections {
+		if !nameRegex.MatchString(conn.Name) {
+			return fmt.Errorf("network connection name %q is invalid", conn.Name)
+		}
+		if _, exists := connections[conn.Name]; exists {
+			return fmt.Errorf("duplicate network connection name %q", conn.Name)
+		}
+		connections[conn.Name] = conn
+
+		iface := conn.getInterface()
+		if err := validateInterfaceName(iface); err != nil {
+			return fmt.Errorf("network connection %q: %v", conn.Name, err)
+		}
+		if interfaces[iface] {
+			return fmt.Errorf("network connection %q: interface %q is used by another connection", conn.Name, iface)
+		}
+		interfaces[iface] = true
+
+		switch conn.getType() {
+		case NetworkConnectionTypeEthernet, NetworkConnectionTypeBridge:
+			if conn.BondMode != "" {
+				return fmt.Errorf("network connection %q: bond mode is only supported for bond connections", conn.Name)
+			}
+		case NetworkConnectionTypeBond:
+		default:
+			return fmt.Errorf("network connection %q: unsupported type %q", conn.Name, conn.Type)
+		}
+
+		var families []bool
+		for _, addr := range conn.Addresses {
+			prefix, err := netip.ParsePrefix(addr)
+			if err != nil {
+				return fmt.Errorf("network connection %q: address %q is not in CIDR notation", conn.Name, addr)
+			}
+			families = append(families, prefix.Addr().Is4())
+		}
+		if conn.Gateway != "" {
+			gw, err := netip.ParseAddr(conn.Gateway)
+			if err != nil {
+				return fmt.Errorf("network connection %q: gateway %q is not an IP address", conn.Name, conn.Gateway)
+			}
+			matches := false
+			for _, is4 := range families {
+				if is4 == gw.Is4() {
+					matches = true
+				}
+			}
+			if !matches {
+				return fmt.Errorf("network connection %q: gateway %q requires a static address of the same family", conn.Name, conn.Gateway)
+			}
+		}
+		for _, dns := range conn.DNS {
+			if _, err := netip.ParseAddr(dns); err != nil {
+				return fmt.Errorf("network connection %q: DNS server %q is not an IP address", conn.Name, dns)
+			}
+		}
+
+		if conn.Controller != "" && (len(conn.Addresses) > 0 || conn.Gateway != "" || len(conn.DNS) > 0) {
+			return fmt.Errorf("network connection %q: ports of a controller cannot have an IP configuration", conn.Name)
+		}
+	}
+
+	// controllers can be defined after their ports, so check them once all
+	// the connections are known
+	for _, conn := range nc.Connections {
+		if conn.Controller == "" {
+			continue
+		}
+		controller, exists := connections[conn.Controller]
+		if !exists {
+			return fmt.Errorf("network connection %q: controller %q is not defined", conn.Name, conn.Controller)
+		}
+		if t := controller.getType(); t != NetworkConnectionTypeBond && t != NetworkConnectionTypeBridge {
+			return fmt.Errorf("network connection %q: controller %q must be a bond or a bridge", conn.Name, conn.Controller)
+		}
+		if controller.Controller != "" {
+			return fmt.Errorf("network connection %q: controller %q cannot be a port itself", conn.Name, conn.Controller)
+		}
+	}
+
+	return nil
+}
+
+// keyfileIPSection returns the ipv4 or ipv6 section of a keyfile with the
+// static configuration of the given family.
+func (c *NetworkConnectionCustomization) keyfileIPSection(ipv4 bool) string {
+	var addresses, dns []string
+	for _, addr := range c.Addresses {
+		if prefix := netip.MustParsePrefix(addr); prefix.Addr().Is4() == ipv4 {
+			addresses = append(addresses, prefix.String())
+		}
+	}
+	for _, server := range c.DNS {
+		if netip.MustParseAddr(server).Is4() == ipv4 {
+			dns = append(dns, server)
+		}
+	}
+
+	var b strings.Builder
+	if ipv4 {
+		b.WriteString("[ipv4]\n")
+	} else {
+		b.WriteString("[ipv6]\n")
+	}
+	for idx, addr := range addresses {
+		fmt.Fprintf(&b, "address%d=%s\n", idx+1, addr)
+	}
+	if len(dns) > 0 {
+		fmt.Fprintf(&b, "dns=%s;\n", strings.Join(dns, ";"))
+	}
+	if c.Gateway != "" && netip.MustParseAddr(c.Gateway).Is4() == ipv4 {
+		fmt.Fprintf(&b, "gateway=%s\n", c.Gateway)
+	}
+	if len(addresses) > 0 {
+		b.WriteString("method=manual\n")
+	} else {
+		b.WriteString("method=auto\n")
+	}
+	return b.String()
+}
+
+// keyfile renders the connection as a NetworkManager keyfile. The connection
+// must have been validated.
+func (c *NetworkConnectionCustomization) keyfile(controllerType string) string {
+	var sections []string
+
+	connection := fmt.Sprintf("[connection]\nid=%s\ntype=%s\ninterface-name=%s\nautoconnect=true\n", c.Name, c.getType(), c.getInterface())
+	if c.Controller != "" {
+		// master/slave-type are understood by all NetworkManager versions,
+		// unlike their newer controller/port-type aliases
+		connection += fmt.Sprintf("master=%s\nslave-type=%s\n", c.Controller, controllerType)
+	}
+	sections = append(sections, connection)
+
+	switch c.getType() {
+	case NetworkConnectionTypeEthernet:
+		sections = append(sections, "[ethernet]\n")
+	case NetworkConnectionTypeBond:
+		bond := "[bond]\n"
+		if c.BondMode != "" {
+			bond += fmt.Sprintf("mode=%s\n", c.BondMode)
+		}
+		sections = append(sections, bond)
+	case NetworkConnectionTypeBridge:
+		sections = append(sections, "[bridge]\n")
+	}
+
+	// ports are configured through their controller
+	if c.Controller == "" {
+		sections = append(sections, c.keyfileIPSection(true), c.keyfileIPSection(false))
+	}
+
+	return strings.Join(sections, "\n")
+}
+
+// NetworkCustomizationToFsNodeFiles converts the connections of the Network
+// customization to NetworkManager keyfiles. The files are in the order of the
+// connections and are readable by root only, since NetworkManager ignores
+// keyfiles that are accessible by other users.
+func NetworkCustomizationToFsNodeFiles(nc *NetworkCustomization) ([]*fsnode.File, error) {
+	if nc == nil || len(nc.Connections) == 0 {
+		return nil, nil
+	}
+
+	if err := ValidateNetworkCustomization(nc); err != nil {
+		return nil, err
+	}
+
+	types := make(map[string]string, len(nc.Connections))
+	for _, conn := range nc.Connections {
+		types[conn.Name] = conn.getType()
+	}
+
+	files := make([]*fsnode.File, 0, len(nc.Connections))
+	for idx := range nc.Connections {
+		conn := nc.Connections[idx]
+		file, err := fsnode.NewFile(conn.getFilename(), common.ToPtr(os.FileMode(0600)), "root", "root", []byte(conn.keyfile(types[conn.Controller])))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,298 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkCustomizationToFsNodeFiles(t *testing.T) {
+	nc := &NetworkCustomization{
+		Connections: []NetworkConnectionCustomization{
+			{
+				Name:      "static",
+				Interface: "eth0",
+				Addresses: []string{"192.168.122.10/24", "2001:db8::10/64"},
+				Gateway:   "192.168.122.1",
+				DNS:       []string{"192.168.122.1", "2001:db8::1", "1.1.1.1"},
+			},
+			{
+				Name: "eth1",
+			},
+			{
+				Name:       "bond0-port1",
+				Interface:  "eth2",
+				Controller: "bond0",
+			},
+			{
+				Name:      "bond0",
+				Type:      "bond",
+				BondMode:  "active-backup",
+				Addresses: []string{"10.0.0.2/8"},
+			},
+			{
+				Name:       "br0-port1",
+				Interface:  "eth3",
+				Controller: "br0",
+			},
+			{
+				Name: "br0",
+				Type: "bridge",
+			},
+		},
+	}
+
+	files, err := NetworkCustomizationToFsNodeFiles(nc)
+	require.NoError(t, err)
+	require.Len(t, files, 6)
+
+	for _, file := range files {
+		require.NotNil(t, file.Mode())
+		assert.Equal(t, os.FileMode(0600), *file.Mode())
+		assert.Equal(t, "root", file.User())
+		assert.Equal(t, "root", file.Group())
+	}
+
+	expected := []struct {
+		path string
+		data string
+	}{
+		{
+			path: "/etc/NetworkManager/system-connections/static.nmconnection",
+			data: `[connection]
+id=static
+type=ethernet
+interface-name=eth0
+autoconnect=true
+
+[ethernet]
+
+[ipv4]
+address1=192.168.122.10/24
+dns=192.168.122.1;1.1.1.1;
+gateway=192.168.122.1
+method=manual
+
+[ipv6]
+address1=2001:db8::10/64
+dns=2001:db8::1;
+method=manual
+`,
+		},
+		{
+			path: "/etc/NetworkManager/system-connections/eth1.nmconnection",
+			data: `[connection]
+id=eth1
+type=ethernet
+interface-name=eth1
+autoconnect=true
+
+[ethernet]
+
+[ipv4]
+method=auto
+
+[ipv6]
+method=auto
+`,
+		},
+		{
+			path: "/etc/NetworkManager/system-connections/bond0-port1.nmconnection",
+			data: `[connection]
+id=bond0-port1
+type=ethernet
+interface-name=eth2
+autoconnect=true
+master=bond0
+slave-type=bond
+
+[ethernet]
+`,
+		},
+		{
+			path: "/etc/NetworkManager/system-connections/bond0.nmconnection",
+			data: `[connection]
+id=bond0
+type=bond
+interface-name=bond0
+autoconnect=true
+
+[bond]
+mode=active-backup
+
+[ipv4]
+address1=10.0.0.2/8
+method=manual
+
+[ipv6]
+method=auto
+`,
+		},
+		{
+			path: "/etc/NetworkManager/system-connections/br0-port1.nmconnection",
+			data: `[connection]
+id=br0-port1
+type=ethernet
+interface-name=eth3
+autoconnect=true
+master=br0
+slave-type=bridge
+
+[ethernet]
+`,
+		},
+		{
+			path: "/etc/NetworkManager/system-connections/br0.nmconnection",
+			data: `[connection]
+id=br0
+type=bridge
+interface-name=br0
+autoconnect=true
+
+[bridge]
+
+[ipv4]
+method=auto
+
+[ipv6]
+method=auto
+`,
+		},
+	}
+	for idx, exp := range expected {
+		assert.Equal(t, exp.path, files[idx].Path())
+		assert.Equal(t, exp.data, string(files[idx].Data()))
+	}
+}
+
+func TestNetworkCustomizationToFsNodeFilesEmpty(t *testing.T) {
+	files, err := NetworkCustomizationToFsNodeFiles(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, files)
+
+	files, err = NetworkCustomizationToFsNodeFiles(&NetworkCustomization{})
+	assert.NoError(t, err)
+	assert.Nil(t, files)
+}
+
+func TestValidateNetworkCustomization(t *testing.T) {
+	testCases := []struct {
+		name        string
+		connections []NetworkConnectionCustomization
+		wantErr     string
+	}{
+		{
+			name: "valid",
+			connections: []NetworkConnectionCustomization{
+				{Name: "eth0", Addresses: []string{"192.168.0.2/24"}, Gateway: "192.168.0.1", DNS: []string{"192.168.0.1"}},
+				{Name: "port", Interface: "eth1", Controller: "br0"},
+				{Name: "br0", Type: "bridge"},
+			},
+		},
+		{
+			name:        "invalid connection name",
+			connections: []NetworkConnectionCustomization{{Name: "../eth0"}},
+			wantErr:     `network connection name "../eth0" is invalid`,
+		},
+		{
+			name:        "empty connection name",
+			connections: []NetworkConnectionCustomization{{Name: ""}},
+			wantErr:     `network connection name "" is invalid`,
+		},
+		{
+			name:        "duplicate connection name",
+			connections: []NetworkConnectionCustomization{{Name: "eth0"}, {Name: "eth0", Interface: "eth1"}},
+			wantErr:     `duplicate network connection name "eth0"`,
+		},
+		{
+			name:        "interface name too long",
+			connections: []NetworkConnectionCustomization{{Name: "conn", Interface: "averyverylongname"}},
+			wantErr:     `network connection "conn": interface name "averyverylongname" must be between 1 and 15 characters long`,
+		},
+		{
+			name:        "interface name with colon",
+			connections: []NetworkConnectionCustomization{{Name: "conn", Interface: "eth0:1"}},
+			wantErr:     `network connection "conn": interface name "eth0:1" must not contain '/', ':' or whitespace`,
+		},
+		{
+			name:        "interface name dot",
+			connections: []NetworkConnectionCustomization{{Name: "."}},
+			wantErr:     `network connection ".": interface name "." is not allowed`,
+		},
+		{
+			name:        "duplicate interface",
+			connections: []NetworkConnectionCustomization{{Name: "a", Interface: "eth0"}, {Name: "b", Interface: "eth0"}},
+			wantErr:     `network connection "b": interface "eth0" is used by another connection`,
+		},
+		{
+			name:        "unsupported type",
+			connections: []NetworkConnectionCustomization{{Name: "wlan0", Type: "wifi"}},
+			wantErr:     `network connection "wlan0": unsupported type "wifi"`,
+		},
+		{
+			name:        "bond mode on ethernet",
+			connections: []NetworkConnectionCustomization{{Name: "eth0", BondMode: "802.3ad"}},
+			wantErr:     `network connection "eth0": bond mode is only supported for bond connections`,
+		},
+		{
+			name:        "address without prefix",
+			connections: []NetworkConnectionCustomization{{Name: "eth0", Addresses: []string{"192.168.0.2"}}},
+			wantErr:     `network connection "eth0": address "192.168.0.2" is not in CIDR notation`,
+		},
+		{
+			name:        "invalid gateway",
+			connections: []NetworkConnectionCustomization{{Name: "eth0", Addresses: []string{"192.168.0.2/24"}, Gateway: "gateway"}},
+			wantErr:     `network connection "eth0": gateway "gateway" is not an IP address`,
+		},
+		{
+			name:        "gateway family mismatch",
+			connections: []NetworkConnectionCustomization{{Name: "eth0", Addresses: []string{"192.168.0.2/24"}, Gateway: "2001:db8::1"}},
+			wantErr:     `network connection "eth0": gateway "2001:db8::1" requires a static address of the same family`,
+		},
+		{
+			name:        "invalid dns",
+			connections: []NetworkConnectionCustomization{{Name: "eth0", DNS: []string{"dns.example.com"}}},
+			wantErr:     `network connection "eth0": DNS server "dns.example.com" is not an IP address`,
+		},
+		{
+			name:        "undefined controller",
+			connections: []NetworkConnectionCustomization{{Name: "eth0", Controller: "bond0"}},
+			wantErr:     `network connection "eth0": controller "bond0" is not defined`,
+		},
+		{
+			name:        "ethernet controller",
+			connections: []NetworkConnectionCustomization{{Name: "eth0", Controller: "eth1"}, {Name: "eth1"}},
+			wantErr:     `network connection "eth0": controller "eth1" must be a bond or a bridge`,
+		},
+		{
+			name: "nested controller",
+			connections: []NetworkConnectionCustomization{
+				{Name: "eth0", Controller: "bond0"},
+				{Name: "bond0", Type: "bond", Controller: "br0"},
+				{Name: "br0", Type: "bridge"},
+			},
+			wantErr: `network connection "eth0": controller "bond0" cannot be a port itself`,
+		},
+		{
+			name: "port with addresses",
+			connections: []NetworkConnectionCustomization{
+				{Name: "eth0", Controller: "bond0", Addresses: []string{"192.168.0.2/24"}},
+				{Name: "bond0", Type: "bond"},
+			},
+			wantErr: `network connection "eth0": ports of a controller cannot have an IP configuration`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateNetworkCustomization(&NetworkCustomization{Connections: tc.connections})
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package blueprint
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+// NetworkNamingLinkPath is the systemd link file of the NamePolicy of the
+// NetworkNaming customization. It replaces the default policy of
+// /usr/lib/systemd/network/99-default.link.
+const NetworkNamingLinkPath = "/etc/systemd/network/99-default.link"
+
+// BiosdevnamePackage is the package of the udev helper that names the
+// interfaces with the biosdevname scheme.
+const BiosdevnamePackage = "biosdevname"
+
+// Naming schemes of the network interfaces
+const (
+	// The systemd predictable names, e.g. enp1s0
+	NetworkNamingSchemePredictable = "predictable"
+	// The names of the kernel, e.g. eth0
+	NetworkNamingSchemeKernel = "kernel"
+	// The names of biosdevname, e.g. em1 and p1p1
+	NetworkNamingSchemeBiosdevname = "biosdevname"
+)
+
+// The kernel command line arguments of each naming scheme
+var networkNamingSchemeKernelOptions = map[string][]string{
+	NetworkNamingSchemePredictable: {"net.ifnames=1", "biosdevname=0"},
+	NetworkNamingSchemeKernel:      {"net.ifnames=0", "biosdevname=0"},
+	NetworkNamingSchemeBiosdevname: {"net.ifnames=0", "biosdevname=1"},
+}
+
+// The naming policies of systemd.link(5)
+var networkNamePolicies = []string{"kernel", "database", "onboard", "slot", "path", "mac", "keep"}
+
+func isNetworkNamePolicy(policy string) bool {
+	for _, p := range networkNamePolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// NetworkNamingCustomization selects how the network interfaces are named,
+// so that the names stay stable when the image moves between hardware.
+type NetworkNamingCustomization struct {
+	// Naming scheme, one of predictable, kernel or biosdevname, that is set
+	// on the kernel command line
+	Scheme string `json:"scheme,omitempty" toml:"scheme,omitempty"`
+	// Policies of systemd.link(5), in order of precedence, that the
+	// predictable names are chosen with, e.g. ["path", "mac"]. They imply
+	// the predictable scheme.
+	NamePolicy []string `json:"name_policy,omitempty" toml:"name_policy,omitempty"`
+}
+
+// GetScheme returns the naming scheme, which is the predictable scheme if
+// only a name policy is set, or an empty string.
+func (n *NetworkNamingCustomization) GetScheme() string {
+	if n == nil {
+		return ""
+	}
+	if n.Scheme == "" && len(n.NamePolicy) > 0 {
+		return NetworkNamingSchemePredictable
+	}
+	return n.Scheme
+}
+
+// KernelOptions returns the kernel command line arguments of the naming
+// scheme, if any.
+func (n *NetworkNamingCustomization) KernelOptions() []string {
+	return networkNamingSchemeKernelOptions[n.GetScheme()]
+}
+
+// FilterKernelOptions removes the net.ifnames and biosdevname arguments from
+// the given kernel command line, e.g. the defaults of an image type, if the
+// naming scheme replaces them.
+func (n *NetworkNamingCustomization) FilterKernelOptions(options string) string {
+	if n.GetScheme() == "" {
+		return options
+	}
+	var filtered []string
+	for _, arg := range strings.Fields(options) {
+		if !isNetworkNamingKernelOption(arg) {
+			filtered = append(filtered, arg)
+		}
+	}
+	return strings.Join(filtered, " ")
+}
+
+func isNetworkNamingKernelOption(arg string) bool {
+	key, _, _ := strings.Cut(arg, "=")
+	return key == "net.ifnames" || key == "biosdevname"
+}
+
+// ValidateNetworkNamingCustomization validates the given NetworkNaming
+// customization against the kernel and files customizations of the
+// blueprint. If the customization is invalid, an error is returned.
+// Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - The scheme, if any, is predictable, kernel or biosdevname
+// - The name policies are unique policies of systemd.link(5), which only
+// apply to the predictable names and imply them if no scheme is set
+// - The kernel Append customization does not also set net.ifnames or
+// biosdevname
+// - The link file is not also defined in the Files customization
+func ValidateNetworkNamingCustomization(naming *NetworkNamingCustomization, kernel *KernelCustomization, files []FileCustomization) error {
+	if naming == nil {
+		return nil
+	}
+
+	if naming.Scheme == "" && len(naming.NamePolicy) == 0 {
+		return fmt.Errorf("network naming requires a scheme or a name policy")
+	}
+	if naming.Scheme != "" && networkNamingSchemeKernelOptions[naming.Scheme] == nil {
+		return fmt.Errorf("unsupported network naming scheme %q (supported: %s, %s, %s)", naming.Scheme, NetworkNamingSchemePredictable, NetworkNamingSchemeKernel, NetworkNamingSchemeBiosdevname)
+	}
+
+	if len(naming.NamePolicy) > 0 {
+		if naming.Scheme != "" && naming.Scheme != NetworkNamingSchemePredictable {
+			return fmt.Errorf("network name policy requires the %s naming scheme, not %q", NetworkNamingSchemePredictable, naming.Scheme)
+		}
+		seen := make(map[string]bool, len(naming.NamePolicy))
+		for _, policy := range naming.NamePolicy {
+			if !isNetworkNamePolicy(policy) {
+				return fmt.Errorf("unsupported network name policy %q (supported: %s)", policy, strings.Join(networkNamePolicies, ", "))
+			}
+			if seen[policy] {
+				return fmt.Errorf("duplicate network name policy %q", policy)
+			}
+			seen[policy] = true
+		}
+		for _, file := range files {
+			if path.Clean(file.Path) == NetworkNamingLinkPath {
+				return fmt.Errorf("network name policy cannot be combined with a custom %s file", NetworkNamingLinkPath)
+			}
+		}
+	}
+
+	if kernel != nil {
+		for _, arg := range strings.Fields(kernel.Append) {
+			if isNetworkNamingKernelOption(arg) {
+				return fmt.Errorf("kernel append %q cannot be combined with the network naming scheme %q", arg, naming.GetScheme())
+			}
+		}
+	}
+
+	return nil
+}
+
+// NetworkNamingCustomizationToFsNodeFile converts the name policy of the
+// NetworkNaming customization to a systemd link file that matches every
+// interface. It returns nil if there is no name policy.
+func NetworkNamingCustomizationToFsNodeFile(naming *NetworkNamingCustomization) (*fsnode.File, error) {
+	if naming == nil || len(naming.NamePolicy) == 0 {
+		return nil, nil
+	}
+
+	if err := ValidateNetworkNamingCustomization(naming, nil, nil); err != nil {
+		return nil, err
+	}
+
+	// the file replaces the default link file, so it keeps its MAC address
+	// policy
+	content := fmt.Sprintf("[Match]\nOriginalName=*\n\n[Link]\nNamePolicy=%s\nMACAddressPolicy=persistent\n", strings.Join(naming.NamePolicy, " "))
+	return fsnode.NewFile(NetworkNamingLinkPath, common.ToPtr(os.FileMode(0644)), "root", "root", []byte(content))
+}
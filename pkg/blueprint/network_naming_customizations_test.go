@@ -0,0 +1,137 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateNetworkNamingCustomization(t *testing.T) {
+	testCases := []struct {
+		name    string
+		naming  *NetworkNamingCustomization
+		kernel  *KernelCustomization
+		files   []FileCustomization
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:   "kernel scheme",
+			naming: &NetworkNamingCustomization{Scheme: "kernel"},
+			kernel: &KernelCustomization{Append: "console=ttyS0 net.ifnames.prefix=net"},
+		},
+		{
+			name:   "predictable scheme with name policy",
+			naming: &NetworkNamingCustomization{Scheme: "predictable", NamePolicy: []string{"path", "mac"}},
+			files:  []FileCustomization{{Path: "/etc/systemd/network/10-lan.link"}},
+		},
+		{
+			name:   "only name policy",
+			naming: &NetworkNamingCustomization{NamePolicy: []string{"keep", "kernel", "onboard"}},
+			kernel: &KernelCustomization{Append: "net.ifnames.prefix=net"},
+		},
+		{
+			name:    "nothing set",
+			naming:  &NetworkNamingCustomization{},
+			wantErr: "network naming requires a scheme or a name policy",
+		},
+		{
+			name:    "unknown scheme",
+			naming:  &NetworkNamingCustomization{Scheme: "eth"},
+			wantErr: `unsupported network naming scheme "eth" (supported: predictable, kernel, biosdevname)`,
+		},
+		{
+			name:    "name policy with kernel scheme",
+			naming:  &NetworkNamingCustomization{Scheme: "kernel", NamePolicy: []string{"mac"}},
+			wantErr: `network name policy requires the predictable naming scheme, not "kernel"`,
+		},
+		{
+			name:    "unknown name policy",
+			naming:  &NetworkNamingCustomization{NamePolicy: []string{"path", "pci"}},
+			wantErr: `unsupported network name policy "pci" (supported: kernel, database, onboard, slot, path, mac, keep)`,
+		},
+		{
+			name:    "duplicate name policy",
+			naming:  &NetworkNamingCustomization{NamePolicy: []string{"path", "path"}},
+			wantErr: `duplicate network name policy "path"`,
+		},
+		{
+			name:    "custom link file",
+			naming:  &NetworkNamingCustomization{NamePolicy: []string{"mac"}},
+			files:   []FileCustomization{{Path: "/etc/systemd/network/99-default.link"}},
+			wantErr: "network name policy cannot be combined with a custom /etc/systemd/network/99-default.link file",
+		},
+		{
+			name:    "kernel append net.ifnames",
+			naming:  &NetworkNamingCustomization{Scheme: "predictable"},
+			kernel:  &KernelCustomization{Append: "quiet net.ifnames=0"},
+			wantErr: `kernel append "net.ifnames=0" cannot be combined with the network naming scheme "predictable"`,
+		},
+		{
+			name:    "kernel append with name policy",
+			naming:  &NetworkNamingCustomization{NamePolicy: []string{"mac"}},
+			kernel:  &KernelCustomization{Append: "net.ifnames=1"},
+			wantErr: `kernel append "net.ifnames=1" cannot be combined with the network naming scheme "predictable"`,
+		},
+		{
+			name:    "kernel append biosdevname",
+			naming:  &NetworkNamingCustomization{Scheme: "biosdevname"},
+			kernel:  &KernelCustomization{Append: "biosdevname=1"},
+			wantErr: `kernel append "biosdevname=1" cannot be combined with the network naming scheme "biosdevname"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateNetworkNamingCustomization(tc.naming, tc.kernel, tc.files)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNetworkNamingKernelOptions(t *testing.T) {
+	var naming *NetworkNamingCustomization
+	assert.Nil(t, naming.KernelOptions())
+	assert.Equal(t, []string{"net.ifnames=1", "biosdevname=0"}, (&NetworkNamingCustomization{NamePolicy: []string{"mac"}}).KernelOptions())
+	assert.Equal(t, []string{"net.ifnames=1", "biosdevname=0"}, (&NetworkNamingCustomization{Scheme: "predictable"}).KernelOptions())
+	assert.Equal(t, []string{"net.ifnames=0", "biosdevname=0"}, (&NetworkNamingCustomization{Scheme: "kernel"}).KernelOptions())
+	assert.Equal(t, []string{"net.ifnames=0", "biosdevname=1"}, (&NetworkNamingCustomization{Scheme: "biosdevname"}).KernelOptions())
+}
+
+func TestNetworkNamingFilterKernelOptions(t *testing.T) {
+	defaults := "console=tty0 net.ifnames=0 biosdevname=0 crashkernel=auto"
+
+	var naming *NetworkNamingCustomization
+	assert.Equal(t, defaults, naming.FilterKernelOptions(defaults))
+	assert.Equal(t, "console=tty0 crashkernel=auto", (&NetworkNamingCustomization{Scheme: "kernel"}).FilterKernelOptions(defaults))
+	assert.Equal(t, "console=tty0 crashkernel=auto", (&NetworkNamingCustomization{NamePolicy: []string{"mac"}}).FilterKernelOptions(defaults))
+	assert.Equal(t, "", (&NetworkNamingCustomization{Scheme: "predictable"}).FilterKernelOptions("net.ifnames=0"))
+}
+
+func TestNetworkNamingCustomizationToFsNodeFile(t *testing.T) {
+	file, err := NetworkNamingCustomizationToFsNodeFile(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, file)
+
+	file, err = NetworkNamingCustomizationToFsNodeFile(&NetworkNamingCustomization{Scheme: "kernel"})
+	assert.NoError(t, err)
+	assert.Nil(t, file)
+
+	file, err = NetworkNamingCustomizationToFsNodeFile(&NetworkNamingCustomization{NamePolicy: []string{"path", "mac"}})
+	require.NoError(t, err)
+	require.NotNil(t, file)
+	assert.Equal(t, "/etc/systemd/network/99-default.link", file.Path())
+	assert.Equal(t, "[Match]\nOriginalName=*\n\n[Link]\nNamePolicy=path mac\nMACAddressPolicy=persistent\n", string(file.Data()))
+	assert.Equal(t, os.FileMode(0644), *file.Mode())
+
+	_, err = NetworkNamingCustomizationToFsNodeFile(&NetworkNamingCustomization{NamePolicy: []string{"pci"}})
+	assert.Error(t, err)
+}
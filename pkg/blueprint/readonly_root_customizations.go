@@ -0,0 +1,50 @@
+package blueprint
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+)
+
+// The size of the /var filesystem that is added to images with a read-only
+// root if the blueprint does not define one. /var holds all the writable
+// state of the image, including the changes to /etc.
+const ReadOnlyRootVarMinSize = 2 * common.GibiByte
+
+// ValidateReadOnlyRootCustomization validates the filesystem and swap
+// customizations of an image with a read-only root. If they are incompatible
+// with it, an error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - No filesystem is mounted on or below /etc, which is an overlay on top of
+// the root filesystem
+// - No swap file is requested, because it would be created in the read-only
+// root on first boot
+func ValidateReadOnlyRootCustomization(mountpoints []FilesystemCustomization, swap *SwapCustomization) error {
+	for _, mp := range mountpoints {
+		mountpoint := filepath.Clean(mp.Mountpoint)
+		if mountpoint == "/etc" || strings.HasPrefix(mountpoint, "/etc/") {
+			return fmt.Errorf("read-only root cannot be combined with a custom %s filesystem, /etc is an overlay", mountpoint)
+		}
+	}
+	if swap.IsFile() {
+		return fmt.Errorf("read-only root cannot be combined with a swap file, use a swap partition instead")
+	}
+	return nil
+}
+
+// ReadOnlyRootFilesystems returns the filesystem customizations of an image
+// with a read-only root: the given ones and a writable /var filesystem of
+// ReadOnlyRootVarMinSize if they do not include one.
+func ReadOnlyRootFilesystems(mountpoints []FilesystemCustomization) []FilesystemCustomization {
+	for _, mp := range mountpoints {
+		if filepath.Clean(mp.Mountpoint) == "/var" {
+			return mountpoints
+		}
+	}
+	filesystems := make([]FilesystemCustomization, 0, len(mountpoints)+1)
+	filesystems = append(filesystems, mountpoints...)
+	return append(filesystems, FilesystemCustomization{Mountpoint: "/var", MinSize: ReadOnlyRootVarMinSize})
+}
@@ -0,0 +1,30 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReadOnlyRootCustomization(t *testing.T) {
+	assert.NoError(t, ValidateReadOnlyRootCustomization(nil, nil))
+	assert.NoError(t, ValidateReadOnlyRootCustomization(
+		[]FilesystemCustomization{{Mountpoint: "/var", MinSize: 1024}, {Mountpoint: "/etcetera", MinSize: 1024}},
+		&SwapCustomization{Size: 1024 * 1024},
+	))
+
+	assert.EqualError(t, ValidateReadOnlyRootCustomization([]FilesystemCustomization{{Mountpoint: "/etc/"}}, nil), "read-only root cannot be combined with a custom /etc filesystem, /etc is an overlay")
+	assert.EqualError(t, ValidateReadOnlyRootCustomization([]FilesystemCustomization{{Mountpoint: "/etc/pki"}}, nil), "read-only root cannot be combined with a custom /etc/pki filesystem, /etc is an overlay")
+	assert.EqualError(t, ValidateReadOnlyRootCustomization(nil, &SwapCustomization{Size: 1024 * 1024, Type: SwapTypeFile}), "read-only root cannot be combined with a swap file, use a swap partition instead")
+}
+
+func TestReadOnlyRootFilesystems(t *testing.T) {
+	assert.Equal(t, []FilesystemCustomization{{Mountpoint: "/var", MinSize: ReadOnlyRootVarMinSize}}, ReadOnlyRootFilesystems(nil))
+
+	home := []FilesystemCustomization{{Mountpoint: "/home", MinSize: 1024}}
+	assert.Equal(t, []FilesystemCustomization{{Mountpoint: "/home", MinSize: 1024}, {Mountpoint: "/var", MinSize: ReadOnlyRootVarMinSize}}, ReadOnlyRootFilesystems(home))
+	assert.Len(t, home, 1)
+
+	withVar := []FilesystemCustomization{{Mountpoint: "/var", MinSize: 1024}}
+	assert.Equal(t, withVar, ReadOnlyRootFilesystems(withVar))
+}
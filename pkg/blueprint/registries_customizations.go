@@ -0,0 +1,154 @@
+package blueprint
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+// RegistriesConfigPath is the containers-registries.conf drop-in that holds
+// the registries of the Registries customization.
+const RegistriesConfigPath = "/etc/containers/registries.conf.d/90-blueprint.conf"
+
+// A repository namespace component, e.g. the "library" of
+// docker.io/library
+var registryPathComponentRegex = regexp.MustCompile(`^[a-z0-9]+([._-][a-z0-9]+)*$`)
+
+// RegistryCustomization configures the access to a container registry or to
+// a namespace of it.
+type RegistryCustomization struct {
+	// Registry or namespace the configuration applies to, as
+	// host[:port][/namespace], e.g. registry.example.com/team
+	Location string `json:"location" toml:"location"`
+	// Allow unencrypted HTTP and unverified TLS connections
+	Insecure bool `json:"insecure,omitempty" toml:"insecure,omitempty"`
+	// Refuse to pull images from the registry
+	Blocked bool `json:"blocked,omitempty" toml:"blocked,omitempty"`
+	// Mirrors to try, in order, before the registry itself
+	Mirrors []RegistryMirrorCustomization `json:"mirrors,omitempty" toml:"mirrors,omitempty"`
+}
+
+// RegistryMirrorCustomization is a mirror of a container registry.
+type RegistryMirrorCustomization struct {
+	// Location of the mirror as host[:port][/namespace]
+	Location string `json:"location" toml:"location"`
+	// Allow unencrypted HTTP and unverified TLS connections to the mirror
+	Insecure bool `json:"insecure,omitempty" toml:"insecure,omitempty"`
+}
+
+// validateRegistryLocation checks that the location is a registry host, an
+// optional port and an optional namespace path.
+func validateRegistryLocation(location string) error {
+	if strings.Contains(location, "://") {
+		return fmt.Errorf("registry location %q must not include a URL scheme", location)
+	}
+
+	host, namespace, _ := strings.Cut(location, "/")
+	if namespace != "" {
+		for _, component := range strings.Split(namespace, "/") {
+			if !registryPathComponentRegex.MatchString(component) {
+				return fmt.Errorf("registry location %q has an invalid namespace: must be lowercase alphanumeric components separated by /", location)
+			}
+		}
+	} else if strings.HasSuffix(location, "/") {
+		return fmt.Errorf("registry location %q must not end with /", location)
+	}
+
+	if strings.HasPrefix(host, "[") {
+		if addr, err := netip.ParseAddr(strings.TrimSuffix(host[1:], "]")); err == nil && addr.Is6() && strings.HasSuffix(host, "]") {
+			return nil
+		}
+		if addrPort, err := netip.ParseAddrPort(host); err == nil && addrPort.Port() != 0 {
+			return nil
+		}
+		return fmt.Errorf("registry location %q has an invalid IPv6 address or port", location)
+	}
+	if name, port, hasPort := strings.Cut(host, ":"); hasPort {
+		if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+			return fmt.Errorf("registry location %q has an invalid port", location)
+		}
+		host = name
+	}
+	if !isValidHostname(host) {
+		return fmt.Errorf("registry location %q does not start with a valid hostname", location)
+	}
+	return nil
+}
+
+// ValidateRegistriesCustomization validates the given Registries
+// customization. If the customization is invalid, an error is returned.
+// Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - Registry and mirror locations are host[:port][/namespace], without a
+// URL scheme
+// - No registry location is listed twice
+// - No registry lists the same mirror twice or itself as a mirror
+func ValidateRegistriesCustomization(registries []RegistryCustomization) error {
+	seen := make(map[string]bool, len(registries))
+	for _, registry := range registries {
+		if err := validateRegistryLocation(registry.Location); err != nil {
+			return err
+		}
+		if seen[registry.Location] {
+			return fmt.Errorf("duplicate registry location %q", registry.Location)
+		}
+		seen[registry.Location] = true
+
+		mirrors := make(map[string]bool, len(registry.Mirrors))
+		for _, mirror := range registry.Mirrors {
+			if err := validateRegistryLocation(mirror.Location); err != nil {
+				return fmt.Errorf("mirror of registry %q: %w", registry.Location, err)
+			}
+			if mirror.Location == registry.Location {
+				return fmt.Errorf("registry %q is listed as its own mirror", registry.Location)
+			}
+			if mirrors[mirror.Location] {
+				return fmt.Errorf("registry %q has duplicate mirror %q", registry.Location, mirror.Location)
+			}
+			mirrors[mirror.Location] = true
+		}
+	}
+	return nil
+}
+
+// RegistriesCustomizationToFsNodeFile converts the Registries customization
+// to a containers-registries.conf drop-in in the version 2 format. The
+// registries keep the order of the blueprint.
+func RegistriesCustomizationToFsNodeFile(registries []RegistryCustomization) (*fsnode.File, error) {
+	if len(registries) == 0 {
+		return nil, nil
+	}
+
+	if err := ValidateRegistriesCustomization(registries); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	for idx, registry := range registries {
+		if idx > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[[registry]]\nlocation = %q\n", registry.Location)
+		if registry.Insecure {
+			b.WriteString("insecure = true\n")
+		}
+		if registry.Blocked {
+			b.WriteString("blocked = true\n")
+		}
+		for _, mirror := range registry.Mirrors {
+			fmt.Fprintf(&b, "\n[[registry.mirror]]\nlocation = %q\n", mirror.Location)
+			if mirror.Insecure {
+				b.WriteString("insecure = true\n")
+			}
+		}
+	}
+
+	return fsnode.NewFile(RegistriesConfigPath, common.ToPtr(os.FileMode(0644)), "root", "root", []byte(b.String()))
+}
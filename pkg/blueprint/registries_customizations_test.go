@@ -0,0 +1,141 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistriesCustomizationToFsNodeFile(t *testing.T) {
+	registries := []RegistryCustomization{
+		{
+			Location: "registry.example.com",
+			Mirrors: []RegistryMirrorCustomization{
+				{Location: "mirror.example.com:5000/cache", Insecure: true},
+				{Location: "192.168.0.2:5000"},
+			},
+		},
+		{Location: "registry.internal:8443/team", Insecure: true},
+		{Location: "docker.io", Blocked: true},
+	}
+
+	expected := `[[registry]]
+location = "registry.example.com"
+
+[[registry.mirror]]
+location = "mirror.example.com:5000/cache"
+insecure = true
+
+[[registry.mirror]]
+location = "192.168.0.2:5000"
+
+[[registry]]
+location = "registry.internal:8443/team"
+insecure = true
+
+[[registry]]
+location = "docker.io"
+blocked = true
+`
+
+	file, err := RegistriesCustomizationToFsNodeFile(registries)
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/containers/registries.conf.d/90-blueprint.conf", file.Path())
+	assert.Equal(t, expected, string(file.Data()))
+	require.NotNil(t, file.Mode())
+	assert.Equal(t, os.FileMode(0644), *file.Mode())
+	assert.Equal(t, "root", file.User())
+	assert.Equal(t, "root", file.Group())
+}
+
+func TestRegistriesCustomizationToFsNodeFileEmpty(t *testing.T) {
+	file, err := RegistriesCustomizationToFsNodeFile(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, file)
+}
+
+func TestValidateRegistriesCustomization(t *testing.T) {
+	testCases := []struct {
+		name       string
+		registries []RegistryCustomization
+		wantErr    string
+	}{
+		{
+			name: "valid",
+			registries: []RegistryCustomization{
+				{Location: "quay.io/my-org/images", Mirrors: []RegistryMirrorCustomization{{Location: "[fd00::2]:5000"}}},
+				{Location: "localhost:5000", Insecure: true},
+				{Location: "[fd00::1]"},
+			},
+		},
+		{
+			name:       "URL",
+			registries: []RegistryCustomization{{Location: "https://registry.example.com"}},
+			wantErr:    `registry location "https://registry.example.com" must not include a URL scheme`,
+		},
+		{
+			name:       "hostname with underscore",
+			registries: []RegistryCustomization{{Location: "my_registry"}},
+			wantErr:    `registry location "my_registry" does not start with a valid hostname`,
+		},
+		{
+			name:       "invalid port",
+			registries: []RegistryCustomization{{Location: "registry.example.com:99999"}},
+			wantErr:    `registry location "registry.example.com:99999" has an invalid port`,
+		},
+		{
+			name:       "invalid IPv6 address",
+			registries: []RegistryCustomization{{Location: "[192.168.0.1]:5000"}},
+			wantErr:    `registry location "[192.168.0.1]:5000" has an invalid IPv6 address or port`,
+		},
+		{
+			name:       "uppercase namespace",
+			registries: []RegistryCustomization{{Location: "quay.io/MyOrg"}},
+			wantErr:    `registry location "quay.io/MyOrg" has an invalid namespace: must be lowercase alphanumeric components separated by /`,
+		},
+		{
+			name:       "trailing slash",
+			registries: []RegistryCustomization{{Location: "quay.io/"}},
+			wantErr:    `registry location "quay.io/" must not end with /`,
+		},
+		{
+			name:       "duplicate location",
+			registries: []RegistryCustomization{{Location: "quay.io"}, {Location: "quay.io", Blocked: true}},
+			wantErr:    `duplicate registry location "quay.io"`,
+		},
+		{
+			name: "invalid mirror",
+			registries: []RegistryCustomization{
+				{Location: "quay.io", Mirrors: []RegistryMirrorCustomization{{Location: "mirror..example.com"}}},
+			},
+			wantErr: `mirror of registry "quay.io": registry location "mirror..example.com" does not start with a valid hostname`,
+		},
+		{
+			name: "own mirror",
+			registries: []RegistryCustomization{
+				{Location: "quay.io", Mirrors: []RegistryMirrorCustomization{{Location: "quay.io"}}},
+			},
+			wantErr: `registry "quay.io" is listed as its own mirror`,
+		},
+		{
+			name: "duplicate mirror",
+			registries: []RegistryCustomization{
+				{Location: "quay.io", Mirrors: []RegistryMirrorCustomization{{Location: "mirror.lan"}, {Location: "mirror.lan", Insecure: true}}},
+			},
+			wantErr: `registry "quay.io" has duplicate mirror "mirror.lan"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateRegistriesCustomization(tc.registries)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
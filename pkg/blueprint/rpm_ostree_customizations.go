@@ -0,0 +1,65 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// An rpm package name, without a version or wildcards
+var rpmPackageNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._+-]*$`)
+
+// RPMOSTreeCustomization changes the packages of an ostree commit, like
+// rpm-ostree install and rpm-ostree override remove do on a deployment, but
+// when the commit is composed. The packages are part of the commit instead of
+// being layered on every system.
+type RPMOSTreeCustomization struct {
+	// Packages to add to the base packages of the commit, e.g. drivers
+	Install []string `json:"install,omitempty" toml:"install,omitempty"`
+	// Packages of the base to remove from the commit
+	OverrideRemove []string `json:"override_remove,omitempty" toml:"override_remove,omitempty"`
+}
+
+// ValidateRPMOSTreeCustomization validates the given RPMOSTree customization
+// against the packages of the blueprint. If the customization is invalid, an
+// error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - All the packages are rpm package names, without versions or wildcards
+// - No package is listed twice
+// - No package is both installed and removed, by the customization or by the
+// packages of the blueprint
+func ValidateRPMOSTreeCustomization(ro *RPMOSTreeCustomization, packages []Package) error {
+	if ro == nil {
+		return nil
+	}
+
+	installed := make(map[string]bool, len(ro.Install)+len(packages))
+	for _, name := range ro.Install {
+		if !rpmPackageNameRegex.MatchString(name) {
+			return fmt.Errorf("rpm-ostree install package %q is not a valid package name", name)
+		}
+		if installed[name] {
+			return fmt.Errorf("rpm-ostree install package %q is listed more than once", name)
+		}
+		installed[name] = true
+	}
+	for _, pkg := range packages {
+		installed[pkg.Name] = true
+	}
+
+	removed := make(map[string]bool, len(ro.OverrideRemove))
+	for _, name := range ro.OverrideRemove {
+		if !rpmPackageNameRegex.MatchString(name) {
+			return fmt.Errorf("rpm-ostree override remove package %q is not a valid package name", name)
+		}
+		if removed[name] {
+			return fmt.Errorf("rpm-ostree override remove package %q is listed more than once", name)
+		}
+		if installed[name] {
+			return fmt.Errorf("rpm-ostree override remove package %q is also installed", name)
+		}
+		removed[name] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRPMOSTreeCustomization(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rpmOSTree *RPMOSTreeCustomization
+		packages  []Package
+		wantErr   string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "valid",
+			rpmOSTree: &RPMOSTreeCustomization{
+				Install:        []string{"kmod-nvidia", "libstdc++", "python3.11"},
+				OverrideRemove: []string{"firefox", "zram-generator-defaults"},
+			},
+			packages: []Package{{Name: "tmux"}},
+		},
+		{
+			name:      "install wildcard",
+			rpmOSTree: &RPMOSTreeCustomization{Install: []string{"kmod-*"}},
+			wantErr:   `rpm-ostree install package "kmod-*" is not a valid package name`,
+		},
+		{
+			name:      "install duplicate",
+			rpmOSTree: &RPMOSTreeCustomization{Install: []string{"tmux", "tmux"}},
+			wantErr:   `rpm-ostree install package "tmux" is listed more than once`,
+		},
+		{
+			name:      "remove invalid",
+			rpmOSTree: &RPMOSTreeCustomization{OverrideRemove: []string{"firefox >= 118"}},
+			wantErr:   `rpm-ostree override remove package "firefox >= 118" is not a valid package name`,
+		},
+		{
+			name:      "remove duplicate",
+			rpmOSTree: &RPMOSTreeCustomization{OverrideRemove: []string{"firefox", "firefox"}},
+			wantErr:   `rpm-ostree override remove package "firefox" is listed more than once`,
+		},
+		{
+			name:      "install and remove",
+			rpmOSTree: &RPMOSTreeCustomization{Install: []string{"firefox"}, OverrideRemove: []string{"firefox"}},
+			wantErr:   `rpm-ostree override remove package "firefox" is also installed`,
+		},
+		{
+			name:      "remove blueprint package",
+			rpmOSTree: &RPMOSTreeCustomization{OverrideRemove: []string{"tmux"}},
+			packages:  []Package{{Name: "tmux", Version: "3.3a"}},
+			wantErr:   `rpm-ostree override remove package "tmux" is also installed`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateRPMOSTreeCustomization(tc.rpmOSTree, tc.packages)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package blueprint
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// SELinux boolean names consist of lowercase letters, digits and underscores
+var selinuxBooleanNameRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// SELinuxCustomization configures the SELinux policy of the image.
+type SELinuxCustomization struct {
+	// Booleans to set persistently, e.g. httpd_can_network_connect = true
+	Booleans map[string]bool `json:"booleans,omitempty" toml:"booleans,omitempty"`
+	// Paths of compiled (.pp) policy modules to install. The modules must be
+	// added to the image with the Files customization.
+	Modules []string `json:"modules,omitempty" toml:"modules,omitempty"`
+}
+
+// ValidateSELinuxCustomization validates the given SELinux customization
+// against the files of the blueprint. If the customization is invalid, an
+// error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - Boolean names are valid
+// - Every module is a .pp file defined in the Files customization and is
+// listed only once
+func ValidateSELinuxCustomization(sc *SELinuxCustomization, files []FileCustomization) error {
+	if sc == nil {
+		return nil
+	}
+
+	for name := range sc.Booleans {
+		if !selinuxBooleanNameRegex.MatchString(name) {
+			return fmt.Errorf("SELinux boolean name %q is invalid", name)
+		}
+	}
+
+	filePaths := make(map[string]bool, len(files))
+	for _, file := range files {
+		filePaths[path.Clean(file.Path)] = true
+	}
+	seen := make(map[string]bool, len(sc.Modules))
+	for _, module := range sc.Modules {
+		if !path.IsAbs(module) || path.Clean(module) != module || !strings.HasSuffix(module, ".pp") {
+			return fmt.Errorf("SELinux module %q must be an absolute path to a .pp file", module)
+		}
+		if seen[module] {
+			return fmt.Errorf("duplicate SELinux module %q", module)
+		}
+		seen[module] = true
+		if !filePaths[module] {
+			return fmt.Errorf("SELinux module %q is not defined in the files customization", module)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSELinuxCustomization(t *testing.T) {
+	files := []FileCustomization{
+		{Path: "/etc/selinux/modules/myapp.pp"},
+		{Path: "/etc/myapp.conf"},
+	}
+
+	testCases := []struct {
+		name    string
+		selinux *SELinuxCustomization
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "valid",
+			selinux: &SELinuxCustomization{
+				Booleans: map[string]bool{"httpd_can_network_connect": true, "virt_use_nfs": false},
+				Modules:  []string{"/etc/selinux/modules/myapp.pp"},
+			},
+		},
+		{
+			name:    "invalid boolean name",
+			selinux: &SELinuxCustomization{Booleans: map[string]bool{"httpd can network": true}},
+			wantErr: `SELinux boolean name "httpd can network" is invalid`,
+		},
+		{
+			name:    "relative module path",
+			selinux: &SELinuxCustomization{Modules: []string{"myapp.pp"}},
+			wantErr: `SELinux module "myapp.pp" must be an absolute path to a .pp file`,
+		},
+		{
+			name:    "module without pp suffix",
+			selinux: &SELinuxCustomization{Modules: []string{"/etc/myapp.conf"}},
+			wantErr: `SELinux module "/etc/myapp.conf" must be an absolute path to a .pp file`,
+		},
+		{
+			name:    "duplicate module",
+			selinux: &SELinuxCustomization{Modules: []string{"/etc/selinux/modules/myapp.pp", "/etc/selinux/modules/myapp.pp"}},
+			wantErr: `duplicate SELinux module "/etc/selinux/modules/myapp.pp"`,
+		},
+		{
+			name:    "undefined module file",
+			selinux: &SELinuxCustomization{Modules: []string{"/etc/selinux/modules/other.pp"}},
+			wantErr: `SELinux module "/etc/selinux/modules/other.pp" is not defined in the files customization`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSELinuxCustomization(tc.selinux, files)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
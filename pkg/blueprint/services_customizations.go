@@ -0,0 +1,50 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// A systemd unit name, as accepted by systemctl: a name of ASCII letters,
+// digits and ":-_.\", with an optional instance after an "@", and an optional
+// unit type suffix, .service by default
+var serviceUnitNameRegex = regexp.MustCompile(`^[a-zA-Z0-9:_.\\-]+(@[a-zA-Z0-9:_.\\-]*)?(\.(service|socket|device|mount|automount|swap|target|path|timer|slice|scope))?$`)
+
+// ValidateServicesCustomization validates the given Services customization.
+// If the customization is invalid, an error is returned. Otherwise, nil is
+// returned.
+//
+// It currently ensures that:
+// - All the unit names are valid systemd unit names
+// - No unit is both masked and enabled, as a masked unit cannot be started
+func ValidateServicesCustomization(services *ServicesCustomization) error {
+	if services == nil {
+		return nil
+	}
+
+	for _, list := range []struct {
+		name  string
+		units []string
+	}{
+		{"enabled", services.Enabled},
+		{"disabled", services.Disabled},
+		{"masked", services.Masked},
+	} {
+		for _, unit := range list.units {
+			if len(unit) > 255 || !serviceUnitNameRegex.MatchString(unit) {
+				return fmt.Errorf("%s service %q is not a valid systemd unit name", list.name, unit)
+			}
+		}
+	}
+
+	enabled := make(map[string]bool, len(services.Enabled))
+	for _, unit := range services.Enabled {
+		enabled[unit] = true
+	}
+	for _, unit := range services.Masked {
+		if enabled[unit] {
+			return fmt.Errorf("service %q cannot be both enabled and masked", unit)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateServicesCustomization(t *testing.T) {
+	assert.NoError(t, ValidateServicesCustomization(nil))
+	assert.NoError(t, ValidateServicesCustomization(&ServicesCustomization{
+		Enabled:  []string{"sshd", "cockpit.socket", "getty@tty1.service"},
+		Disabled: []string{"kdump.service"},
+		Masked:   []string{"ctrl-alt-del.target", "debug-shell.service", "kdump.service"},
+	}))
+
+	assert.EqualError(t, ValidateServicesCustomization(&ServicesCustomization{Masked: []string{"debug shell.service"}}), `masked service "debug shell.service" is not a valid systemd unit name`)
+	assert.EqualError(t, ValidateServicesCustomization(&ServicesCustomization{Masked: []string{"/etc/systemd/system/debug-shell.service"}}), `masked service "/etc/systemd/system/debug-shell.service" is not a valid systemd unit name`)
+	assert.EqualError(t, ValidateServicesCustomization(&ServicesCustomization{Enabled: []string{""}}), `enabled service "" is not a valid systemd unit name`)
+	assert.EqualError(t, ValidateServicesCustomization(&ServicesCustomization{Disabled: []string{"kdump;reboot"}}), `disabled service "kdump;reboot" is not a valid systemd unit name`)
+	assert.EqualError(t, ValidateServicesCustomization(&ServicesCustomization{Enabled: []string{"sshd"}, Masked: []string{"sshd"}}), `service "sshd" cannot be both enabled and masked`)
+}
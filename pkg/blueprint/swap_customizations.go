@@ -0,0 +1,59 @@
+package blueprint
+
+import (
+	"fmt"
+
+	"github.com/osbuild/images/internal/common"
+)
+
+// Kinds of swap space that can be selected with the Swap customization
+const (
+	SwapTypePartition = "partition"
+	SwapTypeFile      = "file"
+)
+
+// The smallest swap space that is accepted, mkswap(8) requires at least ten
+// pages
+const minSwapSize = common.MebiByte
+
+// SwapCustomization adds swap space to the image.
+type SwapCustomization struct {
+	// Size of the swap space in bytes
+	Size uint64 `json:"size" toml:"size"`
+	// Either "partition" (the default) for a swap partition or "file" for a
+	// swap file at /swapfile, which is created on first boot
+	Type string `json:"type,omitempty" toml:"type,omitempty"`
+}
+
+// IsFile returns true if a swap file is requested instead of a partition.
+func (sc *SwapCustomization) IsFile() bool {
+	return sc != nil && sc.Type == SwapTypeFile
+}
+
+// ValidateSwapCustomization validates the given swap customization for an image
+// of the given size. If the customization is invalid, an error is returned.
+// Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - The type is known
+// - The size is at least 1 MiB and not more than half of the image size
+func ValidateSwapCustomization(sc *SwapCustomization, imageSize uint64) error {
+	if sc == nil {
+		return nil
+	}
+
+	switch sc.Type {
+	case "", SwapTypePartition, SwapTypeFile:
+	default:
+		return fmt.Errorf("unsupported swap type %q (supported: %s, %s)", sc.Type, SwapTypePartition, SwapTypeFile)
+	}
+
+	if sc.Size < minSwapSize {
+		return fmt.Errorf("swap size %d bytes is smaller than the minimum of %d bytes", sc.Size, uint64(minSwapSize))
+	}
+	if imageSize > 0 && sc.Size > imageSize/2 {
+		return fmt.Errorf("swap size %d bytes is larger than half of the image size (%d bytes)", sc.Size, imageSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/internal/common"
+)
+
+func TestValidateSwapCustomization(t *testing.T) {
+	imageSize := uint64(10 * common.GibiByte)
+	testCases := []struct {
+		name    string
+		swap    *SwapCustomization
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "partition",
+			swap: &SwapCustomization{Size: 2 * common.GibiByte},
+		},
+		{
+			name: "file",
+			swap: &SwapCustomization{Size: 2 * common.GibiByte, Type: SwapTypeFile},
+		},
+		{
+			name: "half of the image",
+			swap: &SwapCustomization{Size: 5 * common.GibiByte, Type: SwapTypePartition},
+		},
+		{
+			name:    "unknown type",
+			swap:    &SwapCustomization{Size: 2 * common.GibiByte, Type: "zram"},
+			wantErr: `unsupported swap type "zram" (supported: partition, file)`,
+		},
+		{
+			name:    "no size",
+			swap:    &SwapCustomization{},
+			wantErr: "swap size 0 bytes is smaller than the minimum of 1048576 bytes",
+		},
+		{
+			name:    "larger than half of the image",
+			swap:    &SwapCustomization{Size: 6 * common.GibiByte},
+			wantErr: "swap size 6442450944 bytes is larger than half of the image size (10737418240 bytes)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSwapCustomization(tc.swap, imageSize)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package blueprint
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+// SysctlConfigPath is the sysctl.d drop-in that holds the kernel parameters
+// of the Sysctl customization.
+const SysctlConfigPath = "/etc/sysctl.d/90-blueprint.conf"
+
+// A sysctl key in dotted notation, e.g. net.ipv4.ip_forward
+var sysctlKeyRegex = regexp.MustCompile(`^[\w-]+(\.[\w-]+)+$`)
+
+// ValidateSysctlCustomization validates the given Sysctl customization.
+// If the customization is invalid, an error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - Keys are sysctl names in dotted notation
+// - Values are non-empty and fit on a single line
+func ValidateSysctlCustomization(params map[string]string) error {
+	for _, key := range sortedSysctlKeys(params) {
+		if !sysctlKeyRegex.MatchString(key) {
+			return fmt.Errorf("sysctl key %q is invalid: must be a dotted name such as net.ipv4.ip_forward", key)
+		}
+		value := strings.TrimSpace(params[key])
+		if value == "" {
+			return fmt.Errorf("sysctl key %q has an empty value", key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("sysctl key %q has a value spanning multiple lines", key)
+		}
+	}
+	return nil
+}
+
+func sortedSysctlKeys(params map[string]string) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SysctlCustomizationToFsNodeFile converts the Sysctl customization to a
+// sysctl.d configuration file. The parameters are sorted by key so that the
+// file content does not depend on the map iteration order.
+func SysctlCustomizationToFsNodeFile(params map[string]string) (*fsnode.File, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+
+	if err := ValidateSysctlCustomization(params); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	for _, key := range sortedSysctlKeys(params) {
+		fmt.Fprintf(&b, "%s = %s\n", key, strings.TrimSpace(params[key]))
+	}
+
+	return fsnode.NewFile(SysctlConfigPath, common.ToPtr(os.FileMode(0644)), "root", "root", []byte(b.String()))
+}
@@ -0,0 +1,98 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSysctlCustomizationToFsNodeFile(t *testing.T) {
+	params := map[string]string{
+		"net.ipv4.ip_forward":         "1",
+		"kernel.kptr_restrict":        " 2 ",
+		"net.ipv4.conf.all.rp_filter": "1",
+		"vm.swappiness":               "10",
+		"net.ipv4.ping_group_range":   "0 2147483647",
+	}
+
+	expected := `kernel.kptr_restrict = 2
+net.ipv4.conf.all.rp_filter = 1
+net.ipv4.ip_forward = 1
+net.ipv4.ping_group_range = 0 2147483647
+vm.swappiness = 10
+`
+
+	// the content must not depend on the map iteration order
+	for i := 0; i < 10; i++ {
+		file, err := SysctlCustomizationToFsNodeFile(params)
+		require.NoError(t, err)
+		assert.Equal(t, "/etc/sysctl.d/90-blueprint.conf", file.Path())
+		assert.Equal(t, expected, string(file.Data()))
+		require.NotNil(t, file.Mode())
+		assert.Equal(t, os.FileMode(0644), *file.Mode())
+		assert.Equal(t, "root", file.User())
+		assert.Equal(t, "root", file.Group())
+	}
+}
+
+func TestSysctlCustomizationToFsNodeFileEmpty(t *testing.T) {
+	file, err := SysctlCustomizationToFsNodeFile(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, file)
+}
+
+func TestValidateSysctlCustomization(t *testing.T) {
+	testCases := []struct {
+		name    string
+		params  map[string]string
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			params: map[string]string{"net.ipv4.ip_forward": "1", "net.ipv6.conf.eth0-1.disable_ipv6": "1"},
+		},
+		{
+			name:    "not dotted",
+			params:  map[string]string{"swappiness": "10"},
+			wantErr: `sysctl key "swappiness" is invalid: must be a dotted name such as net.ipv4.ip_forward`,
+		},
+		{
+			name:    "slash separated",
+			params:  map[string]string{"net/ipv4/ip_forward": "1"},
+			wantErr: `sysctl key "net/ipv4/ip_forward" is invalid: must be a dotted name such as net.ipv4.ip_forward`,
+		},
+		{
+			name:    "empty segment",
+			params:  map[string]string{"net..ip_forward": "1"},
+			wantErr: `sysctl key "net..ip_forward" is invalid: must be a dotted name such as net.ipv4.ip_forward`,
+		},
+		{
+			name:    "key with spaces",
+			params:  map[string]string{"net.ipv4.ip_forward = 1\nvm": "1"},
+			wantErr: `sysctl key "net.ipv4.ip_forward = 1\nvm" is invalid: must be a dotted name such as net.ipv4.ip_forward`,
+		},
+		{
+			name:    "empty value",
+			params:  map[string]string{"net.ipv4.ip_forward": " "},
+			wantErr: `sysctl key "net.ipv4.ip_forward" has an empty value`,
+		},
+		{
+			name:    "multiline value",
+			params:  map[string]string{"net.ipv4.ip_forward": "1\nvm.swappiness = 10"},
+			wantErr: `sysctl key "net.ipv4.ip_forward" has a value spanning multiple lines`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSysctlCustomization(tc.params)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package blueprint
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// Valid range of the minpoll and maxpoll options of chrony (log2 seconds)
+const (
+	minChronyPoll = -6
+	maxChronyPoll = 24
+)
+
+// A DNS label as defined in RFC 1123
+var hostnameLabelRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// isValidHostname returns true if the given name is a valid DNS hostname.
+func isValidHostname(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if !hostnameLabelRegex.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// TimesyncCustomization configures the time sources of chrony. The servers
+// replace the ones configured by default in /etc/chrony.conf.
+type TimesyncCustomization struct {
+	Servers []TimesyncServerCustomization `json:"servers,omitempty" toml:"servers,omitempty"`
+}
+
+// TimesyncServerCustomization represents a single NTP server.
+type TimesyncServerCustomization struct {
+	// Hostname or IP address of the server
+	Hostname string `json:"hostname" toml:"hostname"`
+	// Send a burst of requests at startup to speed up the initial synchronization
+	Iburst *bool `json:"iburst,omitempty" toml:"iburst,omitempty"`
+	// Prefer this server over the others
+	Prefer *bool `json:"prefer,omitempty" toml:"prefer,omitempty"`
+	// Minimum and maximum polling interval as a power of 2 in seconds
+	Minpoll *int `json:"minpoll,omitempty" toml:"minpoll,omitempty"`
+	Maxpoll *int `json:"maxpoll,omitempty" toml:"maxpoll,omitempty"`
+}
+
+// ValidateTimesyncCustomization validates the given Timesync customization.
+// If the customization is invalid, an error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - Every server is a valid hostname or IP address and appears only once
+// - The polling intervals are within the range supported by chrony
+func ValidateTimesyncCustomization(tc *TimesyncCustomization) error {
+	if tc == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(tc.Servers))
+	for _, server := range tc.Servers {
+		if _, err := netip.ParseAddr(server.Hostname); err != nil && !isValidHostname(server.Hostname) {
+			return fmt.Errorf("timesync server %q is not a valid hostname or IP address", server.Hostname)
+		}
+		if seen[server.Hostname] {
+			return fmt.Errorf("duplicate timesync server %q", server.Hostname)
+		}
+		seen[server.Hostname] = true
+
+		for _, poll := range []*int{server.Minpoll, server.Maxpoll} {
+			if poll != nil && (*poll < minChronyPoll || *poll > maxChronyPoll) {
+				return fmt.Errorf("timesync server %q: polling interval %d must be between %d and %d", server.Hostname, *poll, minChronyPoll, maxChronyPoll)
+			}
+		}
+		if server.Minpoll != nil && server.Maxpoll != nil && *server.Minpoll > *server.Maxpoll {
+			return fmt.Errorf("timesync server %q: minpoll must not be greater than maxpoll", server.Hostname)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTimesyncCustomization(t *testing.T) {
+	testCases := []struct {
+		name    string
+		servers []TimesyncServerCustomization
+		wantErr string
+	}{
+		{
+			name: "valid",
+			servers: []TimesyncServerCustomization{
+				{Hostname: "ntp.example.com", Iburst: common.ToPtr(true)},
+				{Hostname: "ntp1.", Prefer: common.ToPtr(true)},
+				{Hostname: "192.168.0.1", Minpoll: common.ToPtr(-6), Maxpoll: common.ToPtr(24)},
+				{Hostname: "2001:db8::1"},
+			},
+		},
+		{
+			name:    "empty hostname",
+			servers: []TimesyncServerCustomization{{Hostname: ""}},
+			wantErr: `timesync server "" is not a valid hostname or IP address`,
+		},
+		{
+			name:    "invalid hostname",
+			servers: []TimesyncServerCustomization{{Hostname: "ntp_server.example.com"}},
+			wantErr: `timesync server "ntp_server.example.com" is not a valid hostname or IP address`,
+		},
+		{
+			name:    "hostname with leading dash",
+			servers: []TimesyncServerCustomization{{Hostname: "-ntp.example.com"}},
+			wantErr: `timesync server "-ntp.example.com" is not a valid hostname or IP address`,
+		},
+		{
+			name:    "hostname with spaces",
+			servers: []TimesyncServerCustomization{{Hostname: "ntp.example.com iburst"}},
+			wantErr: `timesync server "ntp.example.com iburst" is not a valid hostname or IP address`,
+		},
+		{
+			name:    "duplicate server",
+			servers: []TimesyncServerCustomization{{Hostname: "ntp.example.com"}, {Hostname: "ntp.example.com"}},
+			wantErr: `duplicate timesync server "ntp.example.com"`,
+		},
+		{
+			name:    "minpoll out of range",
+			servers: []TimesyncServerCustomization{{Hostname: "ntp.example.com", Minpoll: common.ToPtr(-7)}},
+			wantErr: `timesync server "ntp.example.com": polling interval -7 must be between -6 and 24`,
+		},
+		{
+			name:    "maxpoll out of range",
+			servers: []TimesyncServerCustomization{{Hostname: "ntp.example.com", Maxpoll: common.ToPtr(25)}},
+			wantErr: `timesync server "ntp.example.com": polling interval 25 must be between -6 and 24`,
+		},
+		{
+			name:    "minpoll greater than maxpoll",
+			servers: []TimesyncServerCustomization{{Hostname: "ntp.example.com", Minpoll: common.ToPtr(10), Maxpoll: common.ToPtr(4)}},
+			wantErr: `timesync server "ntp.example.com": minpoll must not be greater than maxpoll`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateTimesyncCustomization(&TimesyncCustomization{Servers: tc.servers})
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package blueprint
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+)
+
+// UdevRulesDir is the directory of the local udev rules, which override the
+// rules of the packages with the same name.
+const UdevRulesDir = "/etc/udev/rules.d"
+
+// udev only reads files with the .rules suffix, which are ordered by name,
+// e.g. 70-persistent-net.rules
+var udevRulesFilenameRegex = regexp.MustCompile(`^[\w.-]+\.rules$`)
+
+// UdevRulesCustomization is a file of udev rules in /etc/udev/rules.d, e.g.
+// for stable device names or permissions.
+type UdevRulesCustomization struct {
+	// Name of the file in /etc/udev/rules.d, which must end with .rules
+	Filename string `json:"filename" toml:"filename"`
+	// The rules in the udev(7) format
+	Rules string `json:"rules" toml:"rules"`
+}
+
+// ValidateUdevRulesCustomization validates the given UdevRules customization
+// against the files of the blueprint. If the customization is invalid, an
+// error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - Filenames are file names without a directory that end with .rules
+// - Filenames are unique and not also defined in the Files customization
+// - The rules are non-empty
+func ValidateUdevRulesCustomization(rules []UdevRulesCustomization, files []FileCustomization) error {
+	filePaths := make(map[string]bool, len(files))
+	for _, file := range files {
+		filePaths[path.Clean(file.Path)] = true
+	}
+
+	filenames := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if !udevRulesFilenameRegex.MatchString(rule.Filename) {
+			return fmt.Errorf("udev rules filename %q is invalid: must be a file name ending with .rules", rule.Filename)
+		}
+		if filenames[rule.Filename] {
+			return fmt.Errorf("duplicate udev rules filename %q", rule.Filename)
+		}
+		filenames[rule.Filename] = true
+
+		rulesPath := path.Join(UdevRulesDir, rule.Filename)
+		if filePaths[rulesPath] {
+			return fmt.Errorf("udev rules %q cannot be combined with a custom %s file", rule.Filename, rulesPath)
+		}
+		if strings.TrimSpace(rule.Rules) == "" {
+			return fmt.Errorf("udev rules %q are empty", rule.Filename)
+		}
+	}
+	return nil
+}
+
+// UdevRulesCustomizationToFsNodeFiles converts the UdevRules customization to
+// files in /etc/udev/rules.d. The rules must have been validated.
+func UdevRulesCustomizationToFsNodeFiles(rules []UdevRulesCustomization) ([]*fsnode.File, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	files := make([]*fsnode.File, 0, len(rules))
+	for _, rule := range rules {
+		data := rule.Rules
+		if !strings.HasSuffix(data, "\n") {
+			data += "\n"
+		}
+		file, err := fsnode.NewFile(path.Join(UdevRulesDir, rule.Filename), common.ToPtr(os.FileMode(0644)), "root", "root", []byte(data))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
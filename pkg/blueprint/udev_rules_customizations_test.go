@@ -0,0 +1,95 @@
+package blueprint
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateUdevRulesCustomization(t *testing.T) {
+	validRules := `SUBSYSTEM=="net", ACTION=="add", ATTR{address}=="52:54:00:12:34:56", NAME="lan0"`
+
+	testCases := []struct {
+		name    string
+		rules   []UdevRulesCustomization
+		files   []FileCustomization
+		wantErr string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "valid",
+			rules: []UdevRulesCustomization{
+				{Filename: "70-persistent-net.rules", Rules: validRules},
+				{Filename: "99-serial_ports.rules", Rules: `KERNEL=="ttyUSB[0-9]*", MODE="0660", GROUP="dialout"`},
+			},
+			files: []FileCustomization{{Path: "/etc/udev/udev.conf"}},
+		},
+		{
+			name:    "missing suffix",
+			rules:   []UdevRulesCustomization{{Filename: "70-persistent-net", Rules: validRules}},
+			wantErr: `udev rules filename "70-persistent-net" is invalid: must be a file name ending with .rules`,
+		},
+		{
+			name:    "only suffix",
+			rules:   []UdevRulesCustomization{{Filename: ".rules", Rules: validRules}},
+			wantErr: `udev rules filename ".rules" is invalid: must be a file name ending with .rules`,
+		},
+		{
+			name:    "directory",
+			rules:   []UdevRulesCustomization{{Filename: "../70-persistent-net.rules", Rules: validRules}},
+			wantErr: `udev rules filename "../70-persistent-net.rules" is invalid: must be a file name ending with .rules`,
+		},
+		{
+			name: "duplicate",
+			rules: []UdevRulesCustomization{
+				{Filename: "70-persistent-net.rules", Rules: validRules},
+				{Filename: "70-persistent-net.rules", Rules: validRules},
+			},
+			wantErr: `duplicate udev rules filename "70-persistent-net.rules"`,
+		},
+		{
+			name:    "custom file",
+			rules:   []UdevRulesCustomization{{Filename: "70-persistent-net.rules", Rules: validRules}},
+			files:   []FileCustomization{{Path: "/etc/udev/rules.d/70-persistent-net.rules"}},
+			wantErr: `udev rules "70-persistent-net.rules" cannot be combined with a custom /etc/udev/rules.d/70-persistent-net.rules file`,
+		},
+		{
+			name:    "empty rules",
+			rules:   []UdevRulesCustomization{{Filename: "70-persistent-net.rules", Rules: " \n"}},
+			wantErr: `udev rules "70-persistent-net.rules" are empty`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateUdevRulesCustomization(tc.rules, tc.files)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestUdevRulesCustomizationToFsNodeFiles(t *testing.T) {
+	files, err := UdevRulesCustomizationToFsNodeFiles(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, files)
+
+	files, err = UdevRulesCustomizationToFsNodeFiles([]UdevRulesCustomization{
+		{Filename: "70-persistent-net.rules", Rules: `SUBSYSTEM=="net", NAME="lan0"`},
+		{Filename: "99-usb.rules", Rules: "KERNEL==\"ttyUSB0\", MODE=\"0666\"\n"},
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "/etc/udev/rules.d/70-persistent-net.rules", files[0].Path())
+	assert.Equal(t, "SUBSYSTEM==\"net\", NAME=\"lan0\"\n", string(files[0].Data()))
+	assert.Equal(t, os.FileMode(0644), *files[0].Mode())
+	assert.Equal(t, "/etc/udev/rules.d/99-usb.rules", files[1].Path())
+	assert.Equal(t, "KERNEL==\"ttyUSB0\", MODE=\"0666\"\n", string(files[1].Data()))
+}
@@ -0,0 +1,89 @@
+package blueprint
+
+import (
+	"fmt"
+)
+
+// Groups that exist on every image, created by the setup and systemd
+// packages, and the docker group of the container engine packages, that
+// users can be added to without a Group customization
+var wellKnownGroups = map[string]bool{
+	"root":            true,
+	"bin":             true,
+	"daemon":          true,
+	"sys":             true,
+	"adm":             true,
+	"tty":             true,
+	"disk":            true,
+	"lp":              true,
+	"mem":             true,
+	"kmem":            true,
+	"wheel":           true,
+	"cdrom":           true,
+	"mail":            true,
+	"man":             true,
+	"dialout":         true,
+	"floppy":          true,
+	"games":           true,
+	"tape":            true,
+	"video":           true,
+	"ftp":             true,
+	"lock":            true,
+	"audio":           true,
+	"users":           true,
+	"nobody":          true,
+	"utmp":            true,
+	"input":           true,
+	"kvm":             true,
+	"render":          true,
+	"systemd-journal": true,
+	"docker":          true,
+}
+
+// ValidateUsersCustomization validates the given User customizations against
+// the Group customizations of the blueprint. If the customizations are
+// invalid, an error is returned. Otherwise, nil is returned.
+//
+// It currently ensures that:
+// - UIDs and GIDs are not negative
+// - No two users have the same name or UID
+// - The secondary groups of the users are either Group customizations or
+// well-known groups that exist on every image
+func ValidateUsersCustomization(users []UserCustomization, groups []GroupCustomization) error {
+	knownGroups := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		if group.GID != nil && *group.GID < 0 {
+			return fmt.Errorf("group %q has a negative GID %d", group.Name, *group.GID)
+		}
+		knownGroups[group.Name] = true
+	}
+
+	names := make(map[string]bool, len(users))
+	uids := make(map[int]string, len(users))
+	for _, user := range users {
+		if names[user.Name] {
+			return fmt.Errorf("duplicate user %q", user.Name)
+		}
+		names[user.Name] = true
+
+		if user.UID != nil {
+			if *user.UID < 0 {
+				return fmt.Errorf("user %q has a negative UID %d", user.Name, *user.UID)
+			}
+			if other, ok := uids[*user.UID]; ok {
+				return fmt.Errorf("users %q and %q have the same UID %d", other, user.Name, *user.UID)
+			}
+			uids[*user.UID] = user.Name
+		}
+		if user.GID != nil && *user.GID < 0 {
+			return fmt.Errorf("user %q has a negative GID %d", user.Name, *user.GID)
+		}
+
+		for _, group := range user.Groups {
+			if !knownGroups[group] && !wellKnownGroups[group] {
+				return fmt.Errorf("user %q is a member of group %q, which is neither a group customization nor a well-known group", user.Name, group)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUsersCustomization(t *testing.T) {
+	assert.NoError(t, ValidateUsersCustomization(nil, nil))
+	assert.NoError(t, ValidateUsersCustomization(
+		[]UserCustomization{
+			{Name: "admin", UID: common.ToPtr(1000), GID: common.ToPtr(1000), Groups: []string{"wheel", "docker", "operators"}},
+			{Name: "service", UID: common.ToPtr(1001)},
+		},
+		[]GroupCustomization{{Name: "operators", GID: common.ToPtr(2000)}},
+	))
+
+	tests := []struct {
+		users  []UserCustomization
+		groups []GroupCustomization
+		err    string
+	}{
+		{
+			users: []UserCustomization{{Name: "admin", Groups: []string{"operators"}}},
+			err:   `user "admin" is a member of group "operators", which is neither a group customization nor a well-known group`,
+		},
+		{
+			users: []UserCustomization{{Name: "admin"}, {Name: "admin"}},
+			err:   `duplicate user "admin"`,
+		},
+		{
+			users: []UserCustomization{{Name: "admin", UID: common.ToPtr(1000)}, {Name: "service", UID: common.ToPtr(1000)}},
+			err:   `users "admin" and "service" have the same UID 1000`,
+		},
+		{
+			users: []UserCustomization{{Name: "admin", UID: common.ToPtr(-1)}},
+			err:   `user "admin" has a negative UID -1`,
+		},
+		{
+			users: []UserCustomization{{Name: "admin", GID: common.ToPtr(-1)}},
+			err:   `user "admin" has a negative GID -1`,
+		},
+		{
+			groups: []GroupCustomization{{Name: "operators", GID: common.ToPtr(-1)}},
+			err:    `group "operators" has a negative GID -1`,
+		},
+	}
+	for _, tc := range tests {
+		assert.EqualError(t, ValidateUsersCustomization(tc.users, tc.groups), tc.err)
+	}
+}
@@ -15,7 +15,7 @@ func CryptSHA512(phrase string) (string, error) {
 	salt, err := genSalt(SHA512SaltLength)
 
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	hashSettings := "$6$" + salt
@@ -48,6 +48,13 @@ const (
 
 	// Extended Boot Loader Partition
 	XBootLDRPartitionGUID = "BC13C2FF-59E6-4262-A352-B275FD6F7172"
+
+	// Linux swap partition
+	SwapPartitionGUID = "0657FD6D-A4AB-43C4-84E5-0933C84B4F4F"
+
+	// cloud-init NoCloud seed partition
+	NoCloudSeedPartitionUUID  = "2F7E9C1A-5B3D-4C8E-9A61-0C1DA7A5EED1"
+	NoCloudSeedFilesystemUUID = "C1DA-7A00"
 )
 
 // Entity is the base interface for all disk-related entities.
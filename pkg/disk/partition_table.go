@@ -7,6 +7,7 @@ import (
 
 	"github.com/google/uuid"
 
+	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/blueprint"
 )
 
@@ -17,7 +18,7 @@ type PartitionTable struct {
 	Partitions []Partition
 
 	SectorSize   uint64 // Sector size in bytes
-	ExtraPadding uint64 // Extra space at the end of the partition table (sectors)
+	ExtraPadding uint64 // Extra space at the end of the partition table (bytes)
 	StartOffset  uint64 // Starting offset of the first partition in the table (Mb)
 }
 
@@ -383,6 +384,135 @@ func (pt *PartitionTable) applyCustomization(mountpoints []blueprint.FilesystemC
 	return newMountpoints, nil
 }
 
+const (
+	// NoCloudSeedLabel is the file system label the cloud-init NoCloud
+	// datasource looks for
+	NoCloudSeedLabel = "CIDATA"
+
+	noCloudSeedSize = 4 * common.MebiByte
+)
+
+// AddNoCloudSeedPartition adds a small vfat partition labelled CIDATA for a
+// cloud-init NoCloud seed. It is mounted at the given seed directory, so that
+// the files of the tree under that directory end up on the partition. The
+// partition is added after the existing ones, to keep their numbering, and
+// the root partition remains the last one on the disk so that it can still
+// be grown.
+func (pt *PartitionTable) AddNoCloudSeedPartition(mountpoint string) error {
+	if pt.ContainsMountpoint(mountpoint) {
+		return fmt.Errorf("partition table already contains %s", mountpoint)
+	}
+
+	partition := Partition{
+		Size: noCloudSeedSize,
+		Payload: &Filesystem{
+			Type:         "vfat",
+			UUID:         NoCloudSeedFilesystemUUID,
+			Label:        NoCloudSeedLabel,
+			Mountpoint:   mountpoint,
+			FSTabOptions: "ro,nofail",
+			FSTabFreq:    0,
+			FSTabPassNo:  0,
+		},
+	}
+
+	if pt.Type == "gpt" {
+		partition.Type = FilesystemDataGUID
+		partition.UUID = NoCloudSeedPartitionUUID
+	} else {
+		partition.Type = "0c"
+	}
+	return pt.appendPartition(partition)
+}
+
+// MoveBootToESP mounts the EFI system partition at /boot, for boot loaders
+// that only read the ESP, like systemd-boot, so that the kernels and the boot
+// loader entries of /boot end up on it. A separate /boot partition is removed
+// and its space is added to the ESP.
+func (pt *PartitionTable) MoveBootToESP() error {
+	espIdx := -1
+	for idx := range pt.Partitions {
+		if pt.Partitions[idx].Type == EFISystemPartitionGUID {
+			espIdx = idx
+		}
+	}
+	if espIdx < 0 {
+		return fmt.Errorf("partition table has no EFI system partition")
+	}
+	esp, ok := pt.Partitions[espIdx].Payload.(*Filesystem)
+	if !ok || esp.Type != "vfat" {
+		return fmt.Errorf("EFI system partition has no vfat filesystem")
+	}
+
+	if bootPath := entityPath(pt, "/boot"); bootPath != nil {
+		boot, ok := bootPath[1].(*Partition)
+		if !ok {
+			return fmt.Errorf("/boot must be a partition to be replaced by the EFI system partition")
+		}
+		for idx := range pt.Partitions {
+			if &pt.Partitions[idx] == boot {
+				pt.Partitions[espIdx].Size += boot.Size
+				pt.Partitions = append(pt.Partitions[:idx], pt.Partitions[idx+1:]...)
+				break
+			}
+		}
+	}
+
+	esp.Mountpoint = "/boot"
+	pt.relayout(pt.Size)
+	return nil
+}
+
+// SwapMountpoint is the mountpoint of swap filesystems, which are not mounted
+// but only listed in fstab(5).
+const SwapMountpoint = "none"
+
+// AddSwapPartition adds a swap partition of the given size. Like the NoCloud
+// seed partition, it is added after the existing ones and the root partition
+// remains the last one on the disk.
+func (pt *PartitionTable) AddSwapPartition(size uint64, rng *rand.Rand) error {
+	if pt.ContainsMountpoint(SwapMountpoint) {
+		return fmt.Errorf("partition table already contains a swap partition")
+	}
+
+	swap := &Filesystem{
+		Type:         "swap",
+		Mountpoint:   SwapMountpoint,
+		FSTabOptions: "defaults",
+		FSTabFreq:    0,
+		FSTabPassNo:  0,
+	}
+	swap.GenUUID(rng)
+	partition := Partition{
+		Size:    size,
+		Payload: swap,
+	}
+
+	if pt.Type == "gpt" {
+		partition.Type = SwapPartitionGUID
+		partition.UUID = uuid.Must(newRandomUUIDFromReader(rng)).String()
+	} else {
+		partition.Type = "82"
+	}
+	return pt.appendPartition(partition)
+}
+
+// appendPartition adds the partition after the existing ones and lays out the
+// partition table again, growing it if needed.
+func (pt *PartitionTable) appendPartition(partition Partition) error {
+	maxNo := 4
+	if pt.Type == "gpt" {
+		maxNo = 128
+	}
+	if len(pt.Partitions) == maxNo {
+		return fmt.Errorf("maximum number of partitions reached (%d)", maxNo)
+	}
+
+	pt.Partitions = append(pt.Partitions, partition)
+	pt.relayout(pt.Size)
+	return nil
+}
+
 // Dynamically calculate and update the start point for each of the existing
 // partitions. Adjusts the overall size of image to either the supplied
 // value in `size` or to the sum of all partitions if that is lager.
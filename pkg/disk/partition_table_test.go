@@ -1,9 +1,11 @@
 package disk
 
 import (
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPartitionTableFeatures(t *testing.T) {
@@ -24,3 +26,164 @@ func TestPartitionTableFeatures(t *testing.T) {
 
 	}
 }
+
+func TestAddNoCloudSeedPartition(t *testing.T) {
+	// math/rand is good enough in this case
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(13))
+	for _, mode := range []PartitioningMode{RawPartitioningMode, AutoLVMPartitioningMode} {
+		plain := testPartitionTables["plain"]
+		pt, err := NewPartitionTable(&plain, nil, uint64(10*GiB), mode, nil, rng)
+		require.NoError(t, err)
+		nPartitions := len(pt.Partitions)
+		size := pt.Size
+
+		require.NoError(t, pt.AddNoCloudSeedPartition("/var/lib/cloud/seed/nocloud"))
+		require.Len(t, pt.Partitions, nPartitions+1)
+
+		seed := pt.Partitions[nPartitions]
+		assert.Equal(t, FilesystemDataGUID, seed.Type)
+		assert.Equal(t, NoCloudSeedPartitionUUID, seed.UUID)
+		assert.Equal(t, uint64(4*MiB), seed.Size)
+		assert.Equal(t, &Filesystem{
+			Type:         "vfat",
+			UUID:         NoCloudSeedFilesystemUUID,
+			Label:        "CIDATA",
+			Mountpoint:   "/var/lib/cloud/seed/nocloud",
+			FSTabOptions: "ro,nofail",
+		}, seed.Payload)
+
+		// the root partition is still the last one on the disk and it
+		// keeps its size
+		var root *Partition
+		for idx := range pt.Partitions {
+			if part := &pt.Partitions[idx]; part != &pt.Partitions[nPartitions] && part.Start > seed.Start {
+				root = part
+			}
+		}
+		require.NotNil(t, root)
+		assert.NotEmpty(t, entityPath(root, "/"))
+		assert.Equal(t, seed.Start+seed.Size, root.Start)
+		assert.Equal(t, size+uint64(4*MiB), pt.Size)
+
+		assert.EqualError(t, pt.AddNoCloudSeedPartition("/var/lib/cloud/seed/nocloud"), "partition table already contains /var/lib/cloud/seed/nocloud")
+	}
+}
+
+func TestNewPartitionTableUnallocatedSpace(t *testing.T) {
+	// math/rand is good enough in this case
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(13))
+	for _, mode := range []PartitioningMode{RawPartitioningMode, LVMPartitioningMode} {
+		base := testPartitionTables["plain"]
+		base.ExtraPadding = uint64(2 * GiB)
+		pt, err := NewPartitionTable(&base, nil, uint64(10*GiB), mode, nil, rng)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(10*GiB), pt.Size)
+
+		// the last partition ends before the free space and the secondary
+		// GPT header
+		var end uint64
+		for _, part := range pt.Partitions {
+			if part.Start+part.Size > end {
+				end = part.Start + part.Size
+			}
+		}
+		assert.Equal(t, pt.Size-uint64(2*GiB)-pt.HeaderSize(), end)
+
+		// the space is kept when a partition is appended
+		require.NoError(t, pt.AddSwapPartition(uint64(1*GiB), rng))
+		assert.Equal(t, uint64(11*GiB), pt.Size)
+		root := entityPath(pt, "/")
+		require.NotNil(t, root)
+		var rootPart *Partition
+		for _, ent := range root {
+			if part, ok := ent.(*Partition); ok {
+				rootPart = part
+			}
+		}
+		assert.Equal(t, pt.Size-uint64(2*GiB)-pt.HeaderSize(), rootPart.Start+rootPart.Size)
+	}
+}
+
+func TestAddSwapPartition(t *testing.T) {
+	// math/rand is good enough in this case
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(13))
+	for ptName, ptType := range map[string]string{"plain": "gpt", "plain-noboot": "dos"} {
+		base := testPartitionTables[ptName]
+		base.Type = ptType
+		pt, err := NewPartitionTable(&base, nil, uint64(10*GiB), RawPartitioningMode, nil, rng)
+		require.NoError(t, err)
+		nPartitions := len(pt.Partitions)
+		size := pt.Size
+
+		require.NoError(t, pt.AddSwapPartition(uint64(2*GiB), rng))
+		require.Len(t, pt.Partitions, nPartitions+1)
+
+		swap := pt.Partitions[nPartitions]
+		if ptType == "gpt" {
+			assert.Equal(t, SwapPartitionGUID, swap.Type)
+			assert.NotEmpty(t, swap.UUID)
+		} else {
+			assert.Equal(t, "82", swap.Type)
+		}
+		assert.Equal(t, uint64(2*GiB), swap.Size)
+		fs := swap.Payload.(*Filesystem)
+		assert.Equal(t, "swap", fs.Type)
+		assert.Equal(t, SwapMountpoint, fs.Mountpoint)
+		assert.NotEmpty(t, fs.UUID)
+
+		// the root partition is still the last one on the disk and the
+		// image grows by the size of the swap partition
+		root := entityPath(pt, "/")
+		require.NotNil(t, root)
+		assert.Equal(t, swap.Start+swap.Size, root[1].(*Partition).Start)
+		assert.Equal(t, size+uint64(2*GiB), pt.Size)
+
+		assert.EqualError(t, pt.AddSwapPartition(uint64(2*GiB), rng), "partition table already contains a swap partition")
+	}
+}
+
+func TestMoveBootToESP(t *testing.T) {
+	// math/rand is good enough in this case
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(13))
+	for _, mode := range []PartitioningMode{RawPartitioningMode, LVMPartitioningMode} {
+		plain := testPartitionTables["plain"]
+		pt, err := NewPartitionTable(&plain, nil, uint64(10*GiB), mode, nil, rng)
+		require.NoError(t, err)
+		nPartitions := len(pt.Partitions)
+		size := pt.Size
+
+		require.NoError(t, pt.MoveBootToESP())
+		require.Len(t, pt.Partitions, nPartitions-1)
+		assert.Equal(t, size, pt.Size)
+
+		// the kernels and the entries of /boot are on the ESP, which
+		// is not in /boot anymore
+		assert.Nil(t, pt.FindMountable("/boot/efi"))
+		boot := entityPath(pt, "/boot")
+		require.NotNil(t, boot)
+		esp := boot[1].(*Partition)
+		assert.Equal(t, EFISystemPartitionGUID, esp.Type)
+		assert.Equal(t, "vfat", esp.Payload.(*Filesystem).Type)
+		assert.Equal(t, uint64(700*MiB), esp.Size)
+
+		// the partitions are laid out again
+		root := &pt.Partitions[len(pt.Partitions)-1]
+		assert.NotEmpty(t, entityPath(root, "/"))
+		assert.Equal(t, esp.Start+esp.Size, root.Start)
+	}
+
+	noESP := PartitionTable{
+		Type: "gpt",
+		Partitions: []Partition{
+			{
+				Type:    FilesystemDataGUID,
+				Payload: &Filesystem{Type: "xfs", Mountpoint: "/"},
+			},
+		},
+	}
+	assert.EqualError(t, noESP.MoveBootToESP(), "partition table has no EFI system partition")
+}
@@ -0,0 +1,92 @@
+package distro
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// ComposeMetadataFilename is the name of the file that WriteComposeMetadata
+// writes next to the artifacts of a build.
+const ComposeMetadataFilename = "compose-metadata.json"
+
+// ComposeMetadata is a machine-readable summary of a build of an image type,
+// e.g. for dashboards and provenance systems. All of it is derived from the
+// manifest of the build, see NewComposeMetadata.
+type ComposeMetadata struct {
+	ImageType string `json:"image_type"`
+	Distro    string `json:"distro"`
+	Arch      string `json:"arch"`
+	// Number of packages installed in the image. The packages of the build
+	// root are not counted.
+	PackageCount int `json:"package_count"`
+	// Size of the image in bytes, i.e. the virtual size of disk images, or 0
+	// if the image has no partition table
+	Size uint64 `json:"size"`
+	// Content hash of the serialized manifest, see
+	// manifest.OSBuildManifest.ContentHash
+	ContentHash string `json:"content_hash"`
+	// Repositories that the package sets were depsolved with
+	Repositories []ComposeMetadataRepo `json:"repositories"`
+}
+
+// ComposeMetadataRepo is a repository of the compose metadata. Only the
+// locations of the repository are included, not its credentials or keys.
+type ComposeMetadataRepo struct {
+	Name       string   `json:"name,omitempty"`
+	BaseURLs   []string `json:"baseurls,omitempty"`
+	Metalink   string   `json:"metalink,omitempty"`
+	MirrorList string   `json:"mirrorlist,omitempty"`
+}
+
+// NewComposeMetadata returns the compose metadata of the given manifest of
+// the image type, serialized to mf with the given depsolved package sets.
+func NewComposeMetadata(t ImageType, m *manifest.Manifest, packageSets map[string][]rpmmd.PackageSpec, mf manifest.OSBuildManifest) ComposeMetadata {
+	// a package can be in the package sets of several payload pipelines
+	packages := make(map[string]bool)
+	for _, name := range t.PayloadPipelines() {
+		for _, pkg := range packageSets[name] {
+			packages[pkg.GetNEVRA()] = true
+		}
+	}
+
+	repos := make([]ComposeMetadataRepo, 0)
+	for _, repo := range m.GetRepositories() {
+		repos = append(repos, ComposeMetadataRepo{
+			Name:       repo.Name,
+			BaseURLs:   repo.BaseURLs,
+			Metalink:   repo.Metalink,
+			MirrorList: repo.MirrorList,
+		})
+	}
+
+	return ComposeMetadata{
+		ImageType:    t.Name(),
+		Distro:       t.Arch().Distro().Name(),
+		Arch:         t.Arch().Name(),
+		PackageCount: len(packages),
+		Size:         m.GetDiskSize(),
+		ContentHash:  mf.ContentHash(),
+		Repositories: repos,
+	}
+}
+
+// WriteComposeMetadata writes the compose metadata as JSON to the
+// ComposeMetadataFilename file of the given directory, e.g. the output
+// directory of the build.
+func WriteComposeMetadata(dir string, metadata ComposeMetadata) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal the compose metadata: %w", err)
+	}
+	data = append(data, '\n')
+	path := filepath.Join(dir, ComposeMetadataFilename)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write the compose metadata: %w", err)
+	}
+	return nil
+}
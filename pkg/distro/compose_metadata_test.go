@@ -0,0 +1,72 @@
+package distro_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distroregistry"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComposeMetadata(t *testing.T) {
+	arch, err := distroregistry.NewDefault().GetDistro("fedora-39").GetArch("x86_64")
+	require.NoError(t, err)
+	imageType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	repos := []rpmmd.RepoConfig{
+		{
+			Name:     "fedora",
+			Metalink: "https://mirrors.fedoraproject.org/metalink?repo=fedora-39&arch=x86_64",
+			GPGKeys:  []string{"-----BEGIN PGP PUBLIC KEY BLOCK-----"},
+		},
+		{Name: "custom", BaseURLs: []string{"https://example.com/custom"}},
+	}
+	m, _, err := imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{Size: 5 * 1024 * 1024 * 1024}, repos, 0)
+	require.NoError(t, err)
+
+	packageSets := map[string][]rpmmd.PackageSpec{
+		"build": {
+			{Name: "rpm", Version: "4.19.0", Release: "1.fc39", Arch: "x86_64", Checksum: "sha256:ca2e7246ba1e9a0f72f6baa195f6b3e2ff4b6ca3cea9dd8c1c8a2e2f48ba6c7a"},
+		},
+		"os": {
+			{Name: "kernel", Version: "6.5.6", Release: "300.fc39", Arch: "x86_64", Checksum: "sha256:a0c936696eb7d5ee3192bf53b9d281cecbb40ca9db520de72cb95817ad92ac72"},
+			{Name: "filesystem", Version: "3.18", Release: "6.fc39", Arch: "x86_64", Checksum: "sha256:6b4bf18ba28ccbdd49f2716c9f33c9211155ff703fa6c195c78a07bd160da0eb"},
+			{Name: "tmux", Version: "3.3a", Release: "5.fc39", Arch: "x86_64", Checksum: "sha256:3a9b4ce7e3ac9e5e19a8a47b3c6c2ad1f8b9050fdc4fbd6f0e6b53e9adc0bb0c"},
+		},
+	}
+	mf, err := m.Serialize(packageSets, nil, nil)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, distro.WriteComposeMetadata(dir, distro.NewComposeMetadata(imageType, m, packageSets, mf)))
+	data, err := os.ReadFile(filepath.Join(dir, "compose-metadata.json"))
+	require.NoError(t, err)
+
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &metadata))
+	assert.Equal(t, map[string]interface{}{
+		"image_type":    "qcow2",
+		"distro":        "fedora-39",
+		"arch":          "x86_64",
+		"package_count": float64(3),
+		"size":          float64(5 * 1024 * 1024 * 1024),
+		"content_hash":  mf.ContentHash(),
+		"repositories": []interface{}{
+			map[string]interface{}{
+				"name":     "fedora",
+				"metalink": "https://mirrors.fedoraproject.org/metalink?repo=fedora-39&arch=x86_64",
+			},
+			map[string]interface{}{
+				"name":     "custom",
+				"baseurls": []interface{}{"https://example.com/custom"},
+			},
+		},
+	}, metadata)
+}
@@ -0,0 +1,65 @@
+package distro
+
+import (
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// DefaultSizes maps image type names to the image size, in bytes, to use
+// when no size is requested for an image of that type. It overrides the
+// defaults built into the distributions, for example to make all qcow2
+// images of a deployment bigger.
+type DefaultSizes map[string]uint64
+
+// Apply returns the image type with its default size replaced by the one
+// configured for its name. Image types without a configured size are
+// returned unchanged.
+func (ds DefaultSizes) Apply(imageType ImageType) ImageType {
+	size, ok := ds[imageType.Name()]
+	if !ok || size == 0 {
+		return imageType
+	}
+	return &defaultSizeImageType{ImageType: imageType, defaultSize: size}
+}
+
+// defaultSizeImageType wraps an ImageType and replaces its default size.
+// Explicitly requested sizes are passed through, and the partition table is
+// still grown to fit the filesystem customizations.
+type defaultSizeImageType struct {
+	ImageType
+	defaultSize uint64
+}
+
+func (t *defaultSizeImageType) Size(size uint64) uint64 {
+	if size == 0 {
+		size = t.defaultSize
+	}
+	// let the image type apply its own constraints, like rounding
+	return t.ImageType.Size(size)
+}
+
+// withDefaultSize returns the options with the default size set if no size
+// is requested. Like the built-in default sizes, the default size is grown to
+// fit the customizations instead of being rejected as too small.
+func (t *defaultSizeImageType) withDefaultSize(bp *blueprint.Blueprint, options ImageOptions) ImageOptions {
+	if options.Size == 0 {
+		options.Size = t.defaultSize
+		if minSize, err := t.ImageType.MinimumSize(bp); err == nil && minSize > options.Size {
+			options.Size = minSize
+		}
+	}
+	return options
+}
+
+func (t *defaultSizeImageType) Manifest(bp *blueprint.Blueprint, options ImageOptions, repos []rpmmd.RepoConfig, seed int64) (*manifest.Manifest, []string, error) {
+	return t.ImageType.Manifest(bp, t.withDefaultSize(bp, options), repos, seed)
+}
+
+func (t *defaultSizeImageType) Validate(bp *blueprint.Blueprint, options ImageOptions) []error {
+	return t.ImageType.Validate(bp, t.withDefaultSize(bp, options))
+}
+
+func (t *defaultSizeImageType) SizeEstimate(bp *blueprint.Blueprint, options ImageOptions) (uint64, uint64, error) {
+	return t.ImageType.SizeEstimate(bp, t.withDefaultSize(bp, options))
+}
@@ -0,0 +1,82 @@
+package distro_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distro/distro_test_common"
+	"github.com/osbuild/images/pkg/distroregistry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// imageSize returns the size of the disk image created in the image pipeline
+func imageSize(t *testing.T, pm *distro_test_common.SerializedManifest) uint64 {
+	truncate := pm.StageOptions("image", "org.osbuild.truncate")
+	require.Len(t, truncate, 1)
+	var options struct {
+		Size string `json:"size"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(truncate[0]), &options))
+	size, err := strconv.ParseUint(options.Size, 10, 64)
+	require.NoError(t, err)
+	return size
+}
+
+func TestDefaultSizes(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			qcow2, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			override := qcow2.Size(0) + 5*common.GibiByte
+			defaultSizes := distro.DefaultSizes{"qcow2": override}
+
+			imageType := defaultSizes.Apply(qcow2)
+			assert.Equal(t, qcow2.Name(), imageType.Name())
+			assert.Equal(t, qcow2.Filename(), imageType.Filename())
+
+			// the override replaces the default
+			assert.Equal(t, override, imageType.Size(0))
+			assert.Equal(t, override, imageSize(t, distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, distro.ImageOptions{})))
+
+			// the size estimate and the validation use the override too
+			virtual, _, err := imageType.SizeEstimate(&blueprint.Blueprint{}, distro.ImageOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, override, virtual)
+			assert.Empty(t, imageType.Validate(&blueprint.Blueprint{}, distro.ImageOptions{}))
+
+			// an explicit size wins over the override
+			explicit := override + 2*common.GibiByte
+			assert.Equal(t, explicit, imageType.Size(explicit))
+			assert.Equal(t, explicit, imageSize(t, distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, distro.ImageOptions{Size: explicit})))
+
+			// filesystem customizations that need more space win too
+			bp := &blueprint.Blueprint{
+				Customizations: &blueprint.Customizations{
+					Filesystem: []blueprint.FilesystemCustomization{
+						{Mountpoint: "/var", MinSize: override + common.GibiByte},
+					},
+				},
+			}
+			assert.Greater(t, imageSize(t, distro_test_common.SerializeManifest(t, imageType, bp, distro.ImageOptions{})), override+common.GibiByte)
+			virtual, _, err = imageType.SizeEstimate(bp, distro.ImageOptions{})
+			require.NoError(t, err)
+			assert.Greater(t, virtual, override+common.GibiByte)
+			assert.Empty(t, imageType.Validate(bp, distro.ImageOptions{}))
+
+			// image types without an override are not wrapped
+			ami, err := arch.GetImageType("ami")
+			if err == nil {
+				assert.Equal(t, ami, defaultSizes.Apply(ami))
+			}
+		})
+	}
+}
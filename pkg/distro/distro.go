@@ -1,9 +1,14 @@
 package distro
 
 import (
+	"fmt"
+	"os"
+	"strconv"
+
 	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/disk"
 	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/ostree"
 	"github.com/osbuild/images/pkg/rhsm/facts"
 	"github.com/osbuild/images/pkg/rpmmd"
@@ -34,6 +39,31 @@ func (m BootMode) String() string {
 	}
 }
 
+// The firmware boot modes of virtual machines, as named by the clouds, e.g.
+// the --boot-mode of boot-aws and the BootMode of EC2 images
+const (
+	FirmwareBootModeLegacyBIOS    = "legacy-bios"
+	FirmwareBootModeUEFI          = "uefi"
+	FirmwareBootModeUEFIPreferred = "uefi-preferred"
+)
+
+// SupportedBootModes returns the firmware boot modes that images with this
+// boot mode can be booted with, e.g. all of them for the hybrid images of
+// x86_64 but only UEFI for aarch64. Images that cannot be booted support
+// none.
+func (m BootMode) SupportedBootModes() []string {
+	switch m {
+	case BOOT_LEGACY:
+		return []string{FirmwareBootModeLegacyBIOS}
+	case BOOT_UEFI:
+		return []string{FirmwareBootModeUEFI}
+	case BOOT_HYBRID:
+		return []string{FirmwareBootModeLegacyBIOS, FirmwareBootModeUEFI, FirmwareBootModeUEFIPreferred}
+	default:
+		return nil
+	}
+}
+
 // A Distro represents composer's notion of what a given distribution is.
 type Distro interface {
 	// Returns the name of the distro.
@@ -120,6 +150,29 @@ type ImageType interface {
 	// Returns the names of the stages that will produce the build output.
 	Exports() []string
 
+	// Returns the files produced by a build with the given options. The
+	// first one is the image described by Exports(), Filename() and
+	// MIMEType().
+	Outputs(options ImageOptions) []Output
+
+	// Returns all the problems found in the given blueprint and options for
+	// the image type at once. Manifest runs the same checks and fails with the
+	// first of them.
+	Validate(bp *blueprint.Blueprint, options ImageOptions) []error
+
+	// Returns the size of the smallest image that fits the customizations of
+	// the given blueprint, or 0 if the size of the image type can't be set.
+	// Requesting a smaller size is an error, while the default size of the
+	// image type is grown to this size.
+	MinimumSize(bp *blueprint.Blueprint) (uint64, error)
+
+	// Returns the virtual size of the disk image for the given blueprint and
+	// options, and an estimate of the size that it actually allocates, which
+	// accounts for sparse allocation and compression (see
+	// EstimateActualSize). Both are 0 if the image type has no partition
+	// table.
+	SizeEstimate(bp *blueprint.Blueprint, options ImageOptions) (virtual, actual uint64, err error)
+
 	// Returns an osbuild manifest, containing the sources and pipeline necessary
 	// to build an image, given output format with all packages and customizations
 	// specified in the given blueprint; it also returns any warnings (e.g.
@@ -135,8 +188,95 @@ type ImageOptions struct {
 	Subscription     *subscription.ImageOptions
 	Facts            *facts.ImageOptions
 	PartitioningMode disk.PartitioningMode
+	// RawOutput also exports the raw disk image that the image is converted
+	// from, e.g. next to a qcow2 image, without building the tree twice. Only
+	// disk images that are not raw images already support it.
+	RawOutput bool
+	// VMDKSubformat of vmdk images, streamOptimized (the default) or
+	// monolithicSparse
+	VMDKSubformat osbuild.VMDKSubformat
+	// VHDSubformat of vhd images, fixed (the default) or dynamic. The vhd
+	// images for Azure must be fixed.
+	VHDSubformat osbuild.VPCSubformat
+	// QCOW2Compression of the clusters of qcow2 images, zlib (the default)
+	// or zstd, which is faster to decompress. zstd requires a qemu-img that
+	// supports it in the build root.
+	QCOW2Compression osbuild.QCOW2CompressionType
+	// QCOW2ClusterSize is the size of the clusters of qcow2 images in bytes,
+	// a power of two between 512 bytes and 2 MiB. qemu-img uses 64 KiB if
+	// it is unset.
+	QCOW2ClusterSize uint64
+	// QCOW2Preallocation of qcow2 images: off (the default), metadata,
+	// falloc or full. Preallocated images are not compressed.
+	QCOW2Preallocation osbuild.QCOW2Preallocation
+	// OVA configures the virtual machine described by the OVF descriptor of
+	// ova images
+	OVA *osbuild.OVFVirtualMachineOptions
+	// Proxmox also exports the configuration of a Proxmox VE virtual machine
+	// with the given settings for the disk image, which can then be imported
+	// with `qm importdisk`. Only uncompressed qcow2 and raw disk images
+	// support it.
+	Proxmox *manifest.ProxmoxVMOptions
+	// ContentAddressedFilenames appends the content hash of the manifest to
+	// the filenames of the outputs, e.g. disk-<hash>.qcow2, so that they can
+	// be stored by content. The filenames are then only known once the
+	// manifest is serialized, see manifest.ContentAddressedFilename.
+	ContentAddressedFilenames bool
+	// SourceDateEpoch is the Unix timestamp that the timestamps of the image
+	// are set to for reproducible builds, e.g. the SOURCE_DATE_EPOCH of the
+	// environment (see SourceDateEpochFromEnv). The current time is used if
+	// it is nil.
+	SourceDateEpoch *int64
+	// Proxy routes the depsolving of the package sets through an HTTP proxy,
+	// e.g. the one of the environment (see ProxyOptionsFromEnv). It is set on
+	// the repositories of the package sets.
+	Proxy *ProxyOptions
+	// NoWeakDeps doesn't install the weak dependencies (Recommends) of any of
+	// the packages of the image, which makes it a lot smaller. It overrides
+	// the install_weak_deps of the blueprint.
+	NoWeakDeps bool
+	// Progress is called with each phase of the generation and serialization
+	// of the manifest. It is optional and doesn't affect the manifest.
+	Progress manifest.ProgressFunc `json:"-"`
 }
 
+// SourceDateEpochFromEnv returns the SOURCE_DATE_EPOCH environment variable,
+// the Unix timestamp of reproducible builds, or nil if it is not set.
+func SourceDateEpochFromEnv() (*int64, error) {
+	value, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+	if !ok || value == "" {
+		return nil, nil
+	}
+	epoch, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || epoch < 0 {
+		return nil, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: must be a non-negative Unix timestamp", value)
+	}
+	return &epoch, nil
+}
+
+// An Output is a file produced by a build of an image type.
+type Output struct {
+	// Name of the pipeline that exports the file
+	Pipeline string
+	Filename string
+	MIMEType string
+}
+
+// The raw disk image exported with the RawOutput image option
+const (
+	RawOutputPipeline = "image"
+	RawOutputFilename = "disk.raw"
+	RawOutputMIMEType = "application/octet-stream"
+)
+
+// The Proxmox VE virtual machine configuration exported with the Proxmox
+// image option
+const (
+	ProxmoxOutputPipeline = "proxmox"
+	ProxmoxOutputFilename = "proxmox.conf"
+	ProxmoxOutputMIMEType = "text/plain"
+)
+
 type BasePartitionTableMap map[string]disk.PartitionTable
 
 // Fallbacks: When a new method is added to an interface to provide to provide
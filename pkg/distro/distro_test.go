@@ -1,6 +1,7 @@
 package distro_test
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
@@ -11,7 +12,10 @@ import (
 	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distro/distro_test_common"
 	"github.com/osbuild/images/pkg/distroregistry"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/ostree"
 	"github.com/osbuild/images/pkg/rpmmd"
 	"github.com/stretchr/testify/assert"
@@ -550,3 +554,2048 @@ func (s stringSet) Merge(other stringSet) stringSet {
 	}
 	return merged
 }
+
+// serializeCustomizationManifests serializes the qcow2 manifest of every
+// distro for the given blueprint.
+func serializeCustomizationManifests(t *testing.T, bp *blueprint.Blueprint) map[string]*distro_test_common.SerializedManifest {
+	manifests := make(map[string]*distro_test_common.SerializedManifest)
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+		imageType, err := arch.GetImageType("qcow2")
+		require.NoError(t, err)
+		manifests[distroName] = distro_test_common.SerializeManifest(t, imageType, bp, distro.ImageOptions{})
+	}
+	return manifests
+}
+
+// assertCustomizationError asserts that the qcow2 manifest of every distro
+// fails with the given error for the blueprint.
+func assertCustomizationError(t *testing.T, bp *blueprint.Blueprint, expected string) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+		imageType, err := arch.GetImageType("qcow2")
+		require.NoError(t, err)
+		_, _, err = imageType.Manifest(bp, distro.ImageOptions{}, nil, 0)
+		assert.EqualError(t, err, expected, distroName)
+	}
+}
+
+// Ensure that the NetworkManager keyfiles from the network customization end
+// up in the os tree with the right content and permissions
+func TestNetworkCustomizationKeyfiles(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Network: &blueprint.NetworkCustomization{
+				Connections: []blueprint.NetworkConnectionCustomization{
+					{
+						Name:      "eth0",
+						Addresses: []string{"192.168.122.10/24"},
+						Gateway:   "192.168.122.1",
+					},
+				},
+			},
+		},
+	}
+	keyfilePath := "/etc/NetworkManager/system-connections/eth0.nmconnection"
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.copy"), ""), `"to":"tree://`+keyfilePath+`"`)
+			assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.chmod"), ""), `"`+keyfilePath+`":{"mode":"0600"}`)
+			assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.chown"), ""), `"`+keyfilePath+`":{"user":"root","group":"root"}`)
+
+			var keyfile string
+			for _, data := range pm.InlineData(t) {
+				if strings.Contains(data, "[connection]") {
+					keyfile = data
+				}
+			}
+			assert.Contains(t, keyfile, "interface-name=eth0\n")
+			assert.Contains(t, keyfile, "[ipv4]\naddress1=192.168.122.10/24\ngateway=192.168.122.1\nmethod=manual\n")
+		})
+	}
+}
+
+// Ensure that the timesync servers replace the default chrony servers
+func TestTimesyncCustomizationChrony(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Timesync: &blueprint.TimesyncCustomization{
+				Servers: []blueprint.TimesyncServerCustomization{
+					{Hostname: "ntp1.example.com", Iburst: common.ToPtr(true)},
+					{Hostname: "10.0.0.1", Prefer: common.ToPtr(true), Minpoll: common.ToPtr(4), Maxpoll: common.ToPtr(8)},
+				},
+			},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			chrony := pm.OSStageOptions("org.osbuild.chrony")
+			require.Len(t, chrony, 1)
+			var options struct {
+				Servers []map[string]interface{} `json:"servers"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(chrony[0]), &options))
+			assert.Equal(t, []map[string]interface{}{
+				{"hostname": "ntp1.example.com", "iburst": true},
+				{"hostname": "10.0.0.1", "prefer": true, "minpoll": float64(4), "maxpoll": float64(8)},
+			}, options.Servers)
+		})
+	}
+}
+
+func TestTimesyncCustomizationErrors(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+		imageType, err := arch.GetImageType("qcow2")
+		require.NoError(t, err)
+
+		bp := blueprint.Blueprint{
+			Customizations: &blueprint.Customizations{
+				Timezone: &blueprint.TimezoneCustomization{NTPServers: []string{"ntp.example.com"}},
+				Timesync: &blueprint.TimesyncCustomization{
+					Servers: []blueprint.TimesyncServerCustomization{{Hostname: "ntp1.example.com"}},
+				},
+			},
+		}
+		_, _, err = imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+		assert.EqualError(t, err, "timesync servers cannot be combined with timezone ntpservers", distroName)
+
+		bp.Customizations.Timezone = nil
+		bp.Customizations.Timesync.Servers[0].Hostname = "not a hostname"
+		_, _, err = imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+		assert.EqualError(t, err, `timesync server "not a hostname" is not a valid hostname or IP address`, distroName)
+	}
+}
+
+// Ensure that the customizations that are written to a single configuration
+// file end up in the os tree with the right content and permissions
+func TestCustomizationFiles(t *testing.T) {
+	testCases := []struct {
+		name           string
+		customizations blueprint.Customizations
+		path           string
+		mode           string
+		content        string
+	}{
+		{
+			name: "sysctl",
+			customizations: blueprint.Customizations{
+				Sysctl: map[string]string{
+					"net.ipv4.ip_forward":  "1",
+					"kernel.kptr_restrict": "2",
+				},
+			},
+			path:    "/etc/sysctl.d/90-blueprint.conf",
+			mode:    "0644",
+			content: "kernel.kptr_restrict = 2\nnet.ipv4.ip_forward = 1\n",
+		},
+		{
+			name: "registries",
+			customizations: blueprint.Customizations{
+				Registries: []blueprint.RegistryCustomization{
+					{
+						Location: "registry.example.com",
+						Mirrors: []blueprint.RegistryMirrorCustomization{
+							{Location: "mirror.lan:5000", Insecure: true},
+						},
+					},
+					{Location: "registry.lan", Insecure: true},
+				},
+			},
+			path: "/etc/containers/registries.conf.d/90-blueprint.conf",
+			mode: "0644",
+			content: `[[registry]]
+location = "registry.example.com"
+
+[[registry.mirror]]
+location = "mirror.lan:5000"
+insecure = true
+
+[[registry]]
+location = "registry.lan"
+insecure = true
+`,
+		},
+		{
+			name: "cron",
+			customizations: blueprint.Customizations{
+				User: []blueprint.UserCustomization{{Name: "reporter"}},
+				Cron: []blueprint.CronJobCustomization{
+					{Name: "report", Schedule: "0 8 * * mon-fri", User: "reporter", Command: "/usr/bin/report"},
+				},
+			},
+			path:    "/etc/cron.d/report",
+			mode:    "0644",
+			content: "0 8 * * mon-fri reporter /usr/bin/report\n",
+		},
+		{
+			name: "udev-rules",
+			customizations: blueprint.Customizations{
+				UdevRules: []blueprint.UdevRulesCustomization{
+					{Filename: "70-persistent-net.rules", Rules: `SUBSYSTEM=="net", ACTION=="add", ATTR{address}=="52:54:00:12:34:56", NAME="lan0"`},
+				},
+			},
+			path:    "/etc/udev/rules.d/70-persistent-net.rules",
+			mode:    "0644",
+			content: `SUBSYSTEM=="net", ACTION=="add", ATTR{address}=="52:54:00:12:34:56", NAME="lan0"` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		customizations := tc.customizations
+		for distroName, pm := range serializeCustomizationManifests(t, &blueprint.Blueprint{Customizations: &customizations}) {
+			t.Run(tc.name+"/"+distroName, func(t *testing.T) {
+				assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.copy"), ""), `"to":"tree://`+tc.path+`"`)
+				assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.chmod"), ""), `"`+tc.path+`":{"mode":"`+tc.mode+`"}`)
+				assert.Contains(t, pm.InlineData(t), tc.content)
+			})
+		}
+	}
+
+	cron := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Cron: []blueprint.CronJobCustomization{
+				{Name: "report", Schedule: "0 8 * * mon-fri", User: "reporter", Command: "/usr/bin/report"},
+			},
+		},
+	}
+	assertCustomizationError(t, &cron, `cron job "report": user "reporter" must be root or defined in the blueprint`)
+
+	udev := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			UdevRules: []blueprint.UdevRulesCustomization{{Filename: "70-persistent-net.conf", Rules: "KERNEL==\"sda\""}},
+		},
+	}
+	assertCustomizationError(t, &udev, `udev rules filename "70-persistent-net.conf" is invalid: must be a file name ending with .rules`)
+}
+
+// Ensure that the hosts entries are appended to the default /etc/hosts
+func TestHostsEntriesCustomizationFile(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			HostsEntries: map[string][]string{
+				"192.168.0.2": {"registry.example.com", "registry"},
+			},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.copy"), ""), `"to":"tree:///etc/hosts"`)
+
+			var hosts string
+			for _, data := range pm.InlineData(t) {
+				if strings.HasPrefix(data, "127.0.0.1") {
+					hosts = data
+				}
+			}
+			lines := strings.Split(strings.TrimSpace(hosts), "\n")
+			require.Len(t, lines, 3)
+			assert.True(t, strings.HasPrefix(lines[0], "127.0.0.1 "))
+			assert.True(t, strings.HasPrefix(lines[1], "::1 "))
+			assert.Equal(t, "192.168.0.2 registry.example.com registry", lines[2])
+		})
+	}
+}
+
+// Ensure that the containers of the blueprint are copied to the container
+// storage of the image by a skopeo stage, which is in /usr/share for ostree
+// commits as /var is not part of them
+func TestContainersEmbedding(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Containers: []blueprint.Container{
+			{Source: "quay.io/fedora/fedora@sha256:" + strings.Repeat("1", 64), Name: "localhost/fedora"},
+		},
+	}
+	testCases := map[string]map[string]string{
+		"fedora-39": {"qcow2": "", "iot-commit": "/usr/share/containers/storage"},
+		"rhel-94":   {"qcow2": "", "edge-commit": "/usr/share/containers/storage"},
+		"rhel-89":   {"qcow2": "", "edge-commit": "/usr/share/containers/storage"},
+	}
+
+	distros := distroregistry.NewDefault()
+	for distroName, imageTypes := range testCases {
+		for imageTypeName, storagePath := range imageTypes {
+			t.Run(distroName+"/"+imageTypeName, func(t *testing.T) {
+				arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+				require.NoError(t, err)
+				imageType, err := arch.GetImageType(imageTypeName)
+				require.NoError(t, err)
+
+				m, _, err := imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+				require.NoError(t, err)
+
+				packageSets := make(map[string][]rpmmd.PackageSpec)
+				for _, plName := range append(imageType.BuildPipelines(), imageType.PayloadPipelines()...) {
+					packageSets[plName] = []rpmmd.PackageSpec{
+						{Name: "kernel", Checksum: "sha256:a0c936696eb7d5ee3192bf53b9d281cecbb40ca9db520de72cb95817ad92ac72"},
+						{Name: "filesystem", Checksum: "sha256:6b4bf18ba28ccbdd49f2716c9f33c9211155ff703fa6c195c78a07bd160da0eb"},
+					}
+				}
+				containerSpecs := make(map[string][]container.Spec)
+				for plName, sources := range m.GetContainerSourceSpecs() {
+					require.Equal(t, bp.Containers[0].Source, sources[0].Source)
+					containerSpecs[plName] = []container.Spec{{
+						Source:    "quay.io/fedora/fedora",
+						Digest:    "sha256:" + strings.Repeat("1", 64),
+						ImageID:   "sha256:" + strings.Repeat("2", 64),
+						LocalName: sources[0].Name,
+					}}
+				}
+				require.Len(t, containerSpecs, 1)
+				require.Contains(t, containerSpecs, "os")
+
+				mf, err := m.Serialize(packageSets, containerSpecs, nil)
+				require.NoError(t, err)
+				pm := new(distro_test_common.SerializedManifest)
+				require.NoError(t, json.Unmarshal(mf, pm))
+
+				destination := `{"type":"containers-storage"}`
+				if storagePath != "" {
+					destination = `{"type":"containers-storage","storage-path":"` + storagePath + `"}`
+				}
+				assert.Equal(t, []string{`{"destination":` + destination + `}`}, pm.OSStageOptions("org.osbuild.skopeo"))
+				assert.Contains(t, string(mf), `"sha256:`+strings.Repeat("2", 64)+`":{"image":{"name":"quay.io/fedora/fedora","digest":"sha256:`+strings.Repeat("1", 64)+`"}}`)
+
+				invalid := blueprint.Blueprint{Containers: []blueprint.Container{{Source: "quay.io/fedora/fedora@sha256:1234"}}}
+				_, _, err = imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+				assert.EqualError(t, err, `container source "quay.io/fedora/fedora@sha256:1234" is invalid: invalid reference format`)
+			})
+		}
+	}
+}
+
+// Ensure that the containers policy is written to /etc/containers/policy.json
+// and that an invalid policy is rejected
+func TestContainersPolicyCustomizationFiles(t *testing.T) {
+	policy := `{"default": [{"type": "sigstoreSigned", "keyPath": "/etc/pki/containers/example.pub"}]}`
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			ContainersPolicy: &blueprint.ContainersPolicyCustomization{
+				Policy:              policy,
+				SigstoreAttachments: []string{"registry.example.com"},
+			},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			copies := strings.Join(pm.OSStageOptions("org.osbuild.copy"), "")
+			assert.Contains(t, copies, `"to":"tree:///etc/containers/policy.json"`)
+			assert.Contains(t, copies, `"to":"tree:///etc/containers/registries.d/90-blueprint.yaml"`)
+			assert.Contains(t, pm.InlineData(t), policy+"\n")
+			assert.Contains(t, pm.InlineData(t), "docker:\n  \"registry.example.com\":\n    use-sigstore-attachments: true\n")
+		})
+	}
+
+	invalid := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			ContainersPolicy: &blueprint.ContainersPolicyCustomization{Policy: `{"default": []}`},
+		},
+	}
+	assertCustomizationError(t, &invalid, "containers policy default has no requirements")
+}
+
+// Ensure that the default target replaces the one of the image type in the
+// systemd stage, which links default.target to it, and that unknown targets
+// are rejected
+func TestDefaultTargetCustomization(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			DefaultTarget: "graphical.target",
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			systemd := pm.OSStageOptions("org.osbuild.systemd")
+			require.Len(t, systemd, 1)
+			assert.Contains(t, systemd[0], `"default_target":"graphical.target"`)
+			assert.NotContains(t, systemd[0], "multi-user.target")
+		})
+	}
+
+	invalid := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			DefaultTarget: "graphical",
+		},
+	}
+	assertCustomizationError(t, &invalid, `unsupported default target "graphical" (supported: emergency.target, graphical.target, multi-user.target, rescue.target)`)
+}
+
+// Ensure that masked services are masked, which links them to /dev/null, and
+// that disabled services are only disabled
+func TestMaskedServicesCustomization(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Services: &blueprint.ServicesCustomization{
+				Disabled: []string{"kdump.service"},
+				Masked:   []string{"debug-shell.service", "ctrl-alt-del.target"},
+			},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			systemd := pm.OSStageOptions("org.osbuild.systemd")
+			require.Len(t, systemd, 1)
+			var options struct {
+				DisabledServices []string `json:"disabled_services"`
+				MaskedServices   []string `json:"masked_services"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(systemd[0]), &options))
+			assert.Equal(t, []string{"debug-shell.service", "ctrl-alt-del.target"}, options.MaskedServices)
+			assert.Contains(t, options.DisabledServices, "kdump.service")
+			assert.NotContains(t, options.MaskedServices, "kdump.service")
+			for _, unit := range options.MaskedServices {
+				assert.NotContains(t, options.DisabledServices, unit)
+			}
+		})
+	}
+
+	invalid := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Services: &blueprint.ServicesCustomization{
+				Masked: []string{"debug shell"},
+			},
+		},
+	}
+	assertCustomizationError(t, &invalid, `masked service "debug shell" is not a valid systemd unit name`)
+}
+
+// Ensure that the users are created with the UIDs, GIDs and secondary groups
+// of the blueprint
+func TestUserGroupsCustomization(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Group: []blueprint.GroupCustomization{{Name: "operators", GID: common.ToPtr(2000)}},
+			User: []blueprint.UserCustomization{
+				{Name: "admin", UID: common.ToPtr(1500), GID: common.ToPtr(2000), Groups: []string{"wheel", "docker", "operators"}},
+			},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			users := pm.OSStageOptions("org.osbuild.users")
+			require.Len(t, users, 1)
+			var options osbuild.UsersStageOptions
+			require.NoError(t, json.Unmarshal([]byte(users[0]), &options))
+			admin, ok := options.Users["admin"]
+			require.True(t, ok)
+			assert.Equal(t, common.ToPtr(1500), admin.UID)
+			assert.Equal(t, common.ToPtr(2000), admin.GID)
+			assert.Equal(t, []string{"wheel", "docker", "operators"}, admin.Groups)
+
+			groups := pm.OSStageOptions("org.osbuild.groups")
+			require.Len(t, groups, 1)
+			assert.Contains(t, groups[0], `"operators":{"gid":2000}`)
+		})
+	}
+
+	invalid := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			User: []blueprint.UserCustomization{{Name: "admin", Groups: []string{"operators"}}},
+		},
+	}
+	assertCustomizationError(t, &invalid, `user "admin" is a member of group "operators", which is neither a group customization nor a well-known group`)
+}
+
+// Ensure that images with a read-only root mount it read-only, and /etc, /home
+// and /root as overlays on a writable /var filesystem, so that the users of the
+// blueprint can write to their homes
+func TestReadOnlyRootCustomization(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			ReadOnlyRoot: common.ToPtr(true),
+			User:         []blueprint.UserCustomization{{Name: "admin", Key: common.ToPtr("ssh-ed25519 AAAA admin@example.com")}},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			fstab := pm.OSStageOptions("org.osbuild.fstab")
+			require.Len(t, fstab, 1)
+			var options osbuild.FSTabStageOptions
+			require.NoError(t, json.Unmarshal([]byte(fstab[0]), &options))
+
+			entries := make(map[string]*osbuild.FSTabEntry)
+			for _, fs := range options.FileSystems {
+				entries[fs.Path] = fs
+			}
+			require.Contains(t, entries, "/")
+			assert.Contains(t, strings.Split(entries["/"].Options, ","), "ro")
+			require.Contains(t, entries, "/var")
+			assert.NotContains(t, strings.Split(entries["/var"].Options, ","), "ro")
+
+			// the initramfs mounts /var and the /etc overlay before
+			// systemd starts writing to /etc
+			assert.Contains(t, strings.Split(entries["/var"].Options, ","), "x-initrd.mount")
+			assert.Equal(t, &osbuild.FSTabEntry{
+				Device:  "overlay",
+				VFSType: "overlay",
+				Path:    "/etc",
+				Options: "lowerdir=/sysroot/etc,upperdir=/sysroot/var/lib/readonly-root/etc/upper,workdir=/sysroot/var/lib/readonly-root/etc/work,x-initrd.mount,x-systemd.requires-mounts-for=/sysroot/var/lib/readonly-root/etc",
+			}, entries["/etc"])
+			assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.dracut"), ""), `"add_drivers":["overlay"]`)
+
+			for _, path := range []string{"/home", "/root"} {
+				assert.Equal(t, &osbuild.FSTabEntry{
+					Device:  "overlay",
+					VFSType: "overlay",
+					Path:    path,
+					Options: fmt.Sprintf("lowerdir=%[1]s,upperdir=/var/lib/readonly-root%[1]s/upper,workdir=/var/lib/readonly-root%[1]s/work,x-systemd.requires-mounts-for=/var/lib/readonly-root%[1]s", path),
+				}, entries[path])
+			}
+			assert.Equal(t, &osbuild.FSTabEntry{Device: "tmpfs", VFSType: "tmpfs", Path: "/tmp", Options: "mode=1777,strictatime,nosuid,nodev"}, entries["/tmp"])
+
+			mkdir := strings.Join(pm.OSStageOptions("org.osbuild.mkdir"), "")
+			for _, dir := range []string{"etc", "home", "root"} {
+				assert.Contains(t, mkdir, fmt.Sprintf(`"path":"/var/lib/readonly-root/%s/upper"`, dir))
+				assert.Contains(t, mkdir, fmt.Sprintf(`"path":"/var/lib/readonly-root/%s/work"`, dir))
+			}
+		})
+	}
+
+	// a /home filesystem is writable and not an overlay
+	withHome := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			ReadOnlyRoot: common.ToPtr(true),
+			Filesystem:   []blueprint.FilesystemCustomization{{Mountpoint: "/home", MinSize: common.GibiByte}},
+		},
+	}
+	for distroName, pm := range serializeCustomizationManifests(t, &withHome) {
+		fstab := strings.Join(pm.OSStageOptions("org.osbuild.fstab"), "")
+		assert.NotContains(t, fstab, "lowerdir=/home", distroName)
+		assert.Contains(t, fstab, "lowerdir=/root", distroName)
+	}
+
+	invalid := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			ReadOnlyRoot: common.ToPtr(true),
+			Swap:         &blueprint.SwapCustomization{Size: common.GibiByte, Type: blueprint.SwapTypeFile},
+		},
+	}
+	assertCustomizationError(t, &invalid, "read-only root cannot be combined with a swap file, use a swap partition instead")
+}
+
+// Ensure that the environment customization writes the variables to
+// /etc/environment and to an environment.d drop-in
+func TestEnvironmentCustomization(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Environment: []string{"HTTP_PROXY=http://proxy.example.com:3128", "NO_PROXY=localhost,.example.com"},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			copies := strings.Join(pm.OSStageOptions("org.osbuild.copy"), "")
+			assert.Contains(t, copies, `"to":"tree:///etc/environment"`)
+			assert.Contains(t, copies, `"to":"tree:///etc/environment.d/90-blueprint.conf"`)
+			assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.mkdir"), ""), `"path":"/etc/environment.d"`)
+			assert.Contains(t, pm.InlineData(t), "HTTP_PROXY=http://proxy.example.com:3128\nNO_PROXY=localhost,.example.com\n")
+		})
+	}
+
+	invalid := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Environment: []string{"HTTP-PROXY=http://proxy.example.com:3128"},
+		},
+	}
+	assertCustomizationError(t, &invalid, `environment entry "HTTP-PROXY=http://proxy.example.com:3128": "HTTP-PROXY" is not a valid variable name`)
+}
+
+// Ensure that the CA certificates are written to the anchors of the trust
+// store and that it is updated after the files are created
+func TestCACertsCustomization(t *testing.T) {
+	caCert := `-----BEGIN CERTIFICATE-----
+MIIBkzCCATmgAwIBAgIUFcOOda/NSLYHId6/GlprMXD9gdowCgYIKoZIzj0EAwIw
+HjEcMBoGA1UEAwwTRXhhbXBsZSBJbnRlcm5hbCBDQTAgFw0yNjEwMTQwODE4MDNa
+GA8yMTI2MDkyMDA4MTgwM1owHjEcMBoGA1UEAwwTRXhhbXBsZSBJbnRlcm5hbCBD
+QTBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABDkmJlEYhgHQYFLgdxl9aogTM2Z/
+3oUKKR4VRWtGm3VxYOo9zyzUpj3HTrIXPKAMJ/cNAki79qfHPERLbsJxxQujUzBR
+MB0GA1UdDgQWBBQoKA6e+dJHrvlinMFDWbDT5Em/sjAfBgNVHSMEGDAWgBQoKA6e
++dJHrvlinMFDWbDT5Em/sjAPBgNVHRMBAf8EBTADAQH/MAoGCCqGSM49BAMCA0gA
+MEUCICcjo7tA7VWHF+Ph9WHytHWhTdWY9zUw/UMnFam1uSibAiEAyx4/nEzMfbQX
+0t6S/FpGsSi+PKz0wbUp9bD9YifFB8A=
+-----END CERTIFICATE-----
+`
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			CACerts: &blueprint.CACustomization{PEMCerts: []string{caCert}},
+		},
+	}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			m, _, err := imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			require.NoError(t, err)
+			var include []string
+			for _, ps := range m.GetPackageSetChains()["os"] {
+				include = append(include, ps.Include...)
+			}
+			assert.Contains(t, include, "ca-certificates")
+
+			pm := distro_test_common.SerializeManifest(t, imageType, &bp, distro.ImageOptions{})
+			copies := strings.Join(pm.OSStageOptions("org.osbuild.copy"), "")
+			assert.Contains(t, copies, `"to":"tree:///etc/pki/ca-trust/source/anchors/ad86c57ff6e66cf6a3d3e5b60873dc21595308c354d31b9fffb27c6fda58a074.pem"`)
+			assert.Contains(t, pm.InlineData(t), caCert)
+
+			// the trust store is updated after the last file is copied
+			lastCopy, updateCATrust := -1, -1
+			for _, pl := range pm.Pipelines {
+				if pl.Name != "os" {
+					continue
+				}
+				for idx, s := range pl.Stages {
+					switch s.Type {
+					case "org.osbuild.copy":
+						lastCopy = idx
+					case "org.osbuild.pki.update-ca-trust":
+						updateCATrust = idx
+					}
+				}
+			}
+			require.NotEqual(t, -1, updateCATrust)
+			assert.Less(t, lastCopy, updateCATrust)
+		})
+
+		invalid := blueprint.Blueprint{
+			Customizations: &blueprint.Customizations{
+				CACerts: &blueprint.CACustomization{PEMCerts: []string{"not a certificate"}},
+			},
+		}
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+		imageType, err := arch.GetImageType("qcow2")
+		require.NoError(t, err)
+		_, _, err = imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+		assert.EqualError(t, err, "CA certificate 1 contains no PEM encoded certificate", distroName)
+	}
+}
+
+// Ensure that the packages of the RPMOSTree customization are added to or
+// removed from the os package set of the ostree commits and that it is
+// rejected for the other image types
+func TestRPMOSTreeCustomization(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			RPMOSTree: &blueprint.RPMOSTreeCustomization{
+				Install:        []string{"kmod-nvidia", "nvidia-driver"},
+				OverrideRemove: []string{"criu"},
+			},
+		},
+	}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+
+			qcow2, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+			_, _, err = qcow2.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, `rpm-ostree customizations are not supported for image type "qcow2"`)
+
+			invalid := blueprint.Blueprint{
+				Packages: []blueprint.Package{{Name: "criu"}},
+				Customizations: &blueprint.Customizations{
+					RPMOSTree: &blueprint.RPMOSTreeCustomization{OverrideRemove: []string{"criu"}},
+				},
+			}
+			for _, imageType := range distro_test_common.OSTreeCommitImageTypes(t, arch) {
+				m, _, err := imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+				require.NoError(t, err)
+				var include, exclude []string
+				for _, ps := range m.GetPackageSetChains()["os"] {
+					include = append(include, ps.Include...)
+					exclude = append(exclude, ps.Exclude...)
+				}
+				assert.Contains(t, include, "kmod-nvidia")
+				assert.Contains(t, include, "nvidia-driver")
+				assert.NotContains(t, include, "criu")
+				assert.Contains(t, exclude, "criu")
+
+				_, _, err = imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+				assert.EqualError(t, err, `rpm-ostree override remove package "criu" is also installed`)
+			}
+		})
+	}
+}
+
+// Ensure that the extra mounts are added to fstab after the mounts of the
+// partition table, in the order of the blueprint, with the packages to mount
+// them
+func TestExtraMountsCustomization(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			ExtraMounts: []blueprint.MountCustomization{
+				{Mountpoint: "/mnt/data", Source: "nas.example.com:/export/data", Type: "nfs", Options: "ro,_netdev"},
+				{Mountpoint: "/scratch", Source: "tmpfs", Type: "tmpfs"},
+			},
+		},
+	}
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			pm := distro_test_common.SerializeManifest(t, imageType, &bp, distro.ImageOptions{})
+			fstab := pm.OSStageOptions("org.osbuild.fstab")
+			require.Len(t, fstab, 1)
+			var options osbuild.FSTabStageOptions
+			require.NoError(t, json.Unmarshal([]byte(fstab[0]), &options))
+			n := len(options.FileSystems)
+			require.Greater(t, n, 2)
+			for _, fs := range options.FileSystems[:n-2] {
+				assert.NotEmpty(t, fs.UUID)
+			}
+			assert.Equal(t, &osbuild.FSTabEntry{Device: "nas.example.com:/export/data", VFSType: "nfs", Path: "/mnt/data", Options: "ro,_netdev"}, options.FileSystems[n-2])
+			assert.Equal(t, &osbuild.FSTabEntry{Device: "tmpfs", VFSType: "tmpfs", Path: "/scratch", Options: "defaults"}, options.FileSystems[n-1])
+
+			m, _, err := imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			require.NoError(t, err)
+			assert.Contains(t, m.GetPackageSetChains()["os"][0].Include, "nfs-utils")
+
+			invalid := blueprint.Blueprint{
+				Customizations: &blueprint.Customizations{
+					ExtraMounts: []blueprint.MountCustomization{{Mountpoint: "/mnt", Source: "/dev/sdb1", Type: "ext4"}},
+				},
+			}
+			_, _, err = imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, `extra mount "/mnt" has an unsupported filesystem type "ext4" (supported: 9p, cifs, nfs, nfs4, none, tmpfs, virtiofs)`)
+		})
+	}
+}
+
+// Ensure that the machine ID is emptied with the machine-id stage by default
+// and written to /etc/machine-id in the fixed mode
+func TestMachineIDCustomization(t *testing.T) {
+	regenerate := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			MachineID: &blueprint.MachineIDCustomization{},
+		},
+	}
+	for distroName, pm := range serializeCustomizationManifests(t, &regenerate) {
+		t.Run(distroName, func(t *testing.T) {
+			assert.Equal(t, []string{`{"first-boot":"no"}`}, pm.OSStageOptions("org.osbuild.machine-id"))
+			assert.NotContains(t, strings.Join(pm.OSStageOptions("org.osbuild.copy"), ""), `"to":"tree:///etc/machine-id"`)
+		})
+	}
+
+	fixed := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			MachineID: &blueprint.MachineIDCustomization{Mode: blueprint.MachineIDModeFixed, ID: "4f3b5c1e2a9d4c7b8e6f1a2b3c4d5e6f"},
+		},
+	}
+	for distroName, pm := range serializeCustomizationManifests(t, &fixed) {
+		t.Run(distroName, func(t *testing.T) {
+			assert.Empty(t, pm.OSStageOptions("org.osbuild.machine-id"))
+			assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.copy"), ""), `"to":"tree:///etc/machine-id"`)
+			assert.Contains(t, pm.InlineData(t), "4f3b5c1e2a9d4c7b8e6f1a2b3c4d5e6f\n")
+		})
+	}
+
+	// the machine ID of the tree is kept without the customization
+	for distroName, pm := range serializeCustomizationManifests(t, &blueprint.Blueprint{}) {
+		assert.Empty(t, pm.OSStageOptions("org.osbuild.machine-id"), distroName)
+	}
+
+	invalid := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			MachineID: &blueprint.MachineIDCustomization{Mode: blueprint.MachineIDModeFixed, ID: "4f3b"},
+		},
+	}
+	assertCustomizationError(t, &invalid, `machine ID "4f3b" must be 32 lowercase hexadecimal characters`)
+}
+
+// Ensure that the network naming scheme ends up on the kernel command line
+// after the kernel append customization and that the name policy is written
+// to a systemd link file
+func TestNetworkNamingCustomization(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Kernel:        &blueprint.KernelCustomization{Append: "console=ttyS0"},
+			NetworkNaming: &blueprint.NetworkNamingCustomization{Scheme: "biosdevname"},
+		},
+	}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			m, _, err := imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			require.NoError(t, err)
+			var include []string
+			for _, ps := range m.GetPackageSetChains()["os"] {
+				include = append(include, ps.Include...)
+			}
+			assert.Contains(t, include, "biosdevname")
+
+			pm := distro_test_common.SerializeManifest(t, imageType, &bp, distro.ImageOptions{})
+			assert.Contains(t, pm.KernelCmdline(), "console=ttyS0 net.ifnames=0 biosdevname=1")
+			assert.NotContains(t, strings.Join(pm.OSStageOptions("org.osbuild.copy"), ""), "99-default.link")
+		})
+	}
+
+	policyBP := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			NetworkNaming: &blueprint.NetworkNamingCustomization{NamePolicy: []string{"path", "mac"}},
+		},
+	}
+	for distroName, pm := range serializeCustomizationManifests(t, &policyBP) {
+		t.Run(distroName, func(t *testing.T) {
+			assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.copy"), ""), `"to":"tree:///etc/systemd/network/99-default.link"`)
+			assert.Contains(t, pm.InlineData(t), "[Match]\nOriginalName=*\n\n[Link]\nNamePolicy=path mac\nMACAddressPolicy=persistent\n")
+			// the name policy implies the predictable names, which replace
+			// the defaults of the image type
+			cmdline := pm.KernelCmdline()
+			assert.Contains(t, cmdline, "net.ifnames=1 biosdevname=0")
+			assert.NotContains(t, cmdline, "net.ifnames=0")
+		})
+	}
+
+	conflictBP := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Kernel:        &blueprint.KernelCustomization{Append: "net.ifnames=0"},
+			NetworkNaming: &blueprint.NetworkNamingCustomization{Scheme: "predictable"},
+		},
+	}
+	for _, distroName := range distros.List() {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+		imageType, err := arch.GetImageType("qcow2")
+		require.NoError(t, err)
+		_, _, err = imageType.Manifest(&conflictBP, distro.ImageOptions{}, nil, 0)
+		assert.EqualError(t, err, `kernel append "net.ifnames=0" cannot be combined with the network naming scheme "predictable"`, distroName)
+	}
+
+	schemeBP := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			NetworkNaming: &blueprint.NetworkNamingCustomization{Scheme: "kernel"},
+		},
+	}
+	for distroName, imageTypeName := range map[string]string{
+		"fedora-39": "iot-commit",
+		"fedora-40": "iot-container",
+		"rhel-810":  "edge-commit",
+		"rhel-94":   "edge-container",
+	} {
+		d := distros.GetDistro(distroName)
+		require.NotNil(t, d, distroName)
+		arch, err := d.GetArch("x86_64")
+		require.NoError(t, err)
+		imageType, err := arch.GetImageType(imageTypeName)
+		require.NoError(t, err)
+		_, _, err = imageType.Manifest(&schemeBP, distro.ImageOptions{}, nil, 0)
+		assert.ErrorContains(t, err, fmt.Sprintf("network naming is not supported for image type %q", imageTypeName), distroName)
+	}
+}
+
+// Ensure that the first boot script is installed with an enabled oneshot
+// service that disables itself
+func TestFirstBootCustomization(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			FirstBoot: &blueprint.FirstBootCustomization{Script: "#!/bin/bash\nsubscription-manager register --auto-attach\n"},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			copies := strings.Join(pm.OSStageOptions("org.osbuild.copy"), "")
+			assert.Contains(t, copies, `"to":"tree:///usr/libexec/blueprint-firstboot"`)
+			assert.Contains(t, copies, `"to":"tree:///etc/systemd/system/blueprint-firstboot.service"`)
+			chmod := strings.Join(pm.OSStageOptions("org.osbuild.chmod"), "")
+			assert.Contains(t, chmod, `"/usr/libexec/blueprint-firstboot":{"mode":"0755"}`)
+			assert.Contains(t, chmod, `"/etc/systemd/system/blueprint-firstboot.service":{"mode":"0644"}`)
+			assert.Contains(t, pm.InlineData(t), "#!/bin/bash\nsubscription-manager register --auto-attach\n")
+
+			var unit string
+			for _, data := range pm.InlineData(t) {
+				if strings.Contains(data, "[Service]") {
+					unit = data
+				}
+			}
+			assert.Contains(t, unit, "Type=oneshot\nExecStart=-/usr/libexec/blueprint-firstboot\nExecStartPost=/usr/bin/systemctl disable blueprint-firstboot.service\n")
+
+			systemd := pm.OSStageOptions("org.osbuild.systemd")
+			require.Len(t, systemd, 1)
+			assert.Contains(t, systemd[0], `"blueprint-firstboot.service"`)
+		})
+	}
+
+	bp.Customizations.FirstBoot.Script = "\n"
+	assertCustomizationError(t, &bp, "first boot script is empty")
+}
+
+func TestKernelRemoveCustomization(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Kernel: &blueprint.KernelCustomization{
+				Append: "net.ifnames=1 debug",
+				Remove: []string{"net.ifnames", "quiet", "no-such-argument=1"},
+			},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			cmdline := pm.KernelCmdline()
+			assert.NotContains(t, cmdline, "net.ifnames=0")
+			// the appended arguments are not removed
+			assert.Contains(t, cmdline, "net.ifnames=1 debug")
+		})
+	}
+
+	ostreeBP := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Kernel: &blueprint.KernelCustomization{Remove: []string{"quiet"}},
+		},
+	}
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+		for _, imageType := range distro_test_common.OSTreeCommitImageTypes(t, arch) {
+			_, _, err = imageType.Manifest(&ostreeBP, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, "kernel boot parameter removals are not supported for ostree types", distroName)
+		}
+	}
+}
+
+// Ensure that the audit rules are written to /etc/audit/rules.d and that
+// auditd is enabled
+func TestAuditCustomization(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Audit: &blueprint.AuditCustomization{
+				Rules: []string{
+					"-w /etc/sudoers -p wa -k scope",
+					"-a always,exit -F arch=b64 -S adjtimex,settimeofday -k time-change",
+				},
+			},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.copy"), ""), `"to":"tree:///etc/audit/rules.d/90-blueprint.rules"`)
+			assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.chmod"), ""), `"/etc/audit/rules.d/90-blueprint.rules":{"mode":"0600"}`)
+			assert.Contains(t, pm.InlineData(t), "-w /etc/sudoers -p wa -k scope\n-a always,exit -F arch=b64 -S adjtimex,settimeofday -k time-change\n")
+
+			systemd := pm.OSStageOptions("org.osbuild.systemd")
+			require.Len(t, systemd, 1)
+			assert.Contains(t, systemd[0], `"auditd.service"`)
+		})
+	}
+
+	bp.Customizations.Audit.Rules = []string{"watch /etc/sudoers"}
+	assertCustomizationError(t, &bp, `audit rule "watch /etc/sudoers" is invalid: must start with an auditctl option like -a or -w`)
+}
+
+// Ensure that files with a source URL are fetched by the curl source and
+// copied to the os tree instead of being inlined
+func TestRemoteFileCustomization(t *testing.T) {
+	checksum := "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Files: []blueprint.FileCustomization{
+				{
+					Path:     "/etc/asset.bin",
+					Mode:     "0644",
+					Source:   "https://example.com/asset.bin",
+					Checksum: checksum,
+				},
+			},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			assert.Equal(t, `"https://example.com/asset.bin"`, string(pm.Sources.Curl.Items[checksum]))
+			copyOptions := strings.Join(pm.OSStageOptions("org.osbuild.copy"), "")
+			assert.Contains(t, copyOptions, `"from":"input://file-b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9/`+checksum+`","to":"tree:///etc/asset.bin"`)
+			assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.chmod"), ""), `"/etc/asset.bin":{"mode":"0644"}`)
+			assert.Empty(t, pm.InlineData(t))
+		})
+	}
+}
+
+// Ensure that the SELinux modules and booleans are applied on first boot and
+// that the tree is still labelled by the selinux stage
+func TestSELinuxCustomizationFirstBoot(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Files: []blueprint.FileCustomization{
+				{Path: "/etc/selinux/modules/myapp.pp", Data: "module"},
+			},
+			SELinux: &blueprint.SELinuxCustomization{
+				Booleans: map[string]bool{"httpd_can_network_connect": true},
+				Modules:  []string{"/etc/selinux/modules/myapp.pp"},
+			},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			firstBoot := pm.OSStageOptions("org.osbuild.first-boot")
+			require.Len(t, firstBoot, 1)
+			assert.Contains(t, firstBoot[0], `"/usr/sbin/semodule -i /etc/selinux/modules/myapp.pp","/usr/sbin/setsebool -P httpd_can_network_connect=on"`)
+			assert.Len(t, pm.OSStageOptions("org.osbuild.selinux"), 1)
+		})
+	}
+
+	bp.Customizations.Files = nil
+	assertCustomizationError(t, &bp, `SELinux module "/etc/selinux/modules/myapp.pp" is not defined in the files customization`)
+}
+
+func TestPackageVersionPins(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Packages: []blueprint.Package{
+			{Name: "tmux", Version: "3.3a"},
+			{Name: "bash", Version: "5.2.15-3.fc38"},
+			{Name: "vim-enhanced", Version: "2:9.0.1677-1.fc38"},
+			{Name: "zsh", Version: "*"},
+		},
+	}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			m, _, err := imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			require.NoError(t, err)
+			var include []string
+			for _, ps := range m.GetPackageSetChains()["os"] {
+				include = append(include, ps.Include...)
+			}
+			assert.Subset(t, include, []string{"tmux-3.3a", "bash-5.2.15-3.fc38", "vim-enhanced-2:9.0.1677-1.fc38", "zsh"})
+
+			invalid := blueprint.Blueprint{Packages: []blueprint.Package{{Name: "bash", Version: ">= 5.2"}}}
+			_, _, err = imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, `package "bash" has an invalid version ">= 5.2": must be [epoch:]version[-release]`)
+		})
+	}
+}
+
+func TestBlueprintGroups(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Groups: []blueprint.Group{{Name: "core"}, {Name: "@development-tools"}},
+	}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			m, _, err := imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			require.NoError(t, err)
+			var include []string
+			for _, ps := range m.GetPackageSetChains()["os"] {
+				include = append(include, ps.Include...)
+			}
+			assert.Subset(t, include, []string{"@core", "@development-tools"})
+			assert.NotContains(t, include, "@@development-tools")
+
+			invalid := blueprint.Blueprint{Groups: []blueprint.Group{{Name: "Development Tools"}}}
+			_, _, err = imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, `group name "Development Tools" is invalid`)
+		})
+	}
+}
+
+func TestBlueprintExcludePackages(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Packages:        []blueprint.Package{{Name: "tmux"}},
+		ExcludePackages: []string{"abrt*", "PackageKit"},
+		InstallWeakDeps: common.ToPtr(false),
+	}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			m, _, err := imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			require.NoError(t, err)
+			chain := m.GetPackageSetChains()["os"]
+			require.Len(t, chain, 2)
+			for _, ps := range chain {
+				assert.Subset(t, ps.Exclude, []string{"abrt*", "PackageKit"})
+				assert.False(t, ps.InstallWeakDeps)
+			}
+
+			conflict := blueprint.Blueprint{ExcludePackages: []string{"kernel"}}
+			_, _, err = imageType.Manifest(&conflict, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, `excluded package "kernel" conflicts with the required package "kernel"`)
+
+			conflict = blueprint.Blueprint{
+				Packages:        []blueprint.Package{{Name: "tmux"}},
+				ExcludePackages: []string{"tm?x"},
+			}
+			_, _, err = imageType.Manifest(&conflict, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, `excluded package "tm?x" conflicts with the required package "tmux"`)
+		})
+	}
+}
+
+func TestImageTypeValidate(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Filesystem: []blueprint.FilesystemCustomization{{Mountpoint: "/etc", MinSize: 1073741824}},
+			Timezone:   &blueprint.TimezoneCustomization{NTPServers: []string{"0.pool.ntp.org"}},
+			Timesync: &blueprint.TimesyncCustomization{
+				Servers: []blueprint.TimesyncServerCustomization{{Hostname: "1.pool.ntp.org"}},
+			},
+			Sysctl: map[string]string{"vm.swappiness": ""},
+		},
+	}
+	options := distro.ImageOptions{PartitioningMode: "bogus"}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			assert.Empty(t, imageType.Validate(&blueprint.Blueprint{}, distro.ImageOptions{}))
+
+			errs := imageType.Validate(&bp, options)
+			var messages []string
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+			assert.Equal(t, []string{
+				`The following custom mountpoints are not supported ["/etc"]`,
+				"timesync servers cannot be combined with timezone ntpservers",
+				`sysctl key "vm.swappiness" has an empty value`,
+				`unsupported partitioning mode "bogus"`,
+			}, messages)
+
+			// Manifest stops at the first problem
+			_, _, err = imageType.Manifest(&bp, options, nil, 0)
+			assert.EqualError(t, err, messages[0])
+		})
+	}
+}
+
+// Ensure that Validate finds a problem for every image type that Manifest
+// rejects, and only for those
+func TestImageTypeValidateAgreesWithManifest(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		d := distros.GetDistro(distroName)
+		for _, archName := range d.ListArches() {
+			arch, err := d.GetArch(archName)
+			require.NoError(t, err)
+			for _, imageTypeName := range arch.ListImageTypes() {
+				imageType, err := arch.GetImageType(imageTypeName)
+				require.NoError(t, err)
+				t.Run(distroName+"/"+archName+"/"+imageTypeName, func(t *testing.T) {
+					errs := imageType.Validate(&blueprint.Blueprint{}, distro.ImageOptions{})
+					_, _, err := imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{}, nil, 0)
+					if err != nil {
+						assert.NotEmpty(t, errs, "Manifest failed with %q", err)
+					} else {
+						assert.Empty(t, errs)
+					}
+				})
+			}
+		}
+	}
+}
+
+// Ensure that the cloud-init seed files end up on a vfat partition labelled
+// CIDATA in the qcow2 and openstack images
+func TestCloudInitCustomizationSeedPartition(t *testing.T) {
+	userData := "#cloud-config\nusers:\n  - name: admin\n"
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			CloudInit: &blueprint.CloudInitCustomization{
+				UserData: userData,
+				MetaData: "instance-id: homelab-01\n",
+			},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			copies := strings.Join(pm.OSStageOptions("org.osbuild.copy"), "")
+			assert.Contains(t, copies, `"to":"tree:///var/lib/cloud/seed/nocloud/user-data"`)
+			assert.Contains(t, copies, `"to":"tree:///var/lib/cloud/seed/nocloud/meta-data"`)
+			assert.Contains(t, pm.InlineData(t), userData)
+			assert.Contains(t, pm.InlineData(t), "instance-id: homelab-01\n")
+
+			assert.Contains(t, pm.StageOptions("image", "org.osbuild.mkfs.fat"), `{"volid":"C1DA7A00","label":"CIDATA"}`)
+			assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.fstab"), ""), `{"uuid":"C1DA-7A00","vfs_type":"vfat","path":"/var/lib/cloud/seed/nocloud","options":"ro,nofail"}`)
+		})
+	}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+
+		imageType, err := arch.GetImageType("qcow2")
+		require.NoError(t, err)
+		invalid := blueprint.Blueprint{Customizations: &blueprint.Customizations{
+			CloudInit: &blueprint.CloudInitCustomization{UserData: "users: [admin\n"},
+		}}
+		_, _, err = imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+		assert.ErrorContains(t, err, "cloud-init user-data is not a valid YAML mapping", distroName)
+
+		for _, imageTypeName := range arch.ListImageTypes() {
+			if imageTypeName != "ami" && imageTypeName != "vhd" {
+				continue
+			}
+			imageType, err := arch.GetImageType(imageTypeName)
+			require.NoError(t, err)
+			_, _, err = imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, fmt.Sprintf("cloud-init seed customization is not supported for image type %q", imageTypeName), distroName)
+		}
+	}
+}
+
+// Ensure that the datasources of cloud-init are restricted with a
+// cloud.cfg.d drop-in, without a seed partition
+func TestCloudInitCustomizationDatasources(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			CloudInit: &blueprint.CloudInitCustomization{DatasourceList: []string{"Ec2", "None"}},
+		},
+	}
+
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			copies := strings.Join(pm.OSStageOptions("org.osbuild.copy"), "")
+			assert.Contains(t, copies, `"to":"tree:///etc/cloud/cloud.cfg.d/90-blueprint-datasources.cfg"`)
+			assert.NotContains(t, copies, "/var/lib/cloud/seed/nocloud")
+			assert.Contains(t, pm.InlineData(t), "datasource_list: [Ec2, None]\n")
+			assert.NotContains(t, strings.Join(pm.StageOptions("image", "org.osbuild.mkfs.fat"), ""), "CIDATA")
+		})
+	}
+
+	local := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			CloudInit: &blueprint.CloudInitCustomization{DisableNetworkDatasources: true},
+		},
+	}
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+		imageType, err := arch.GetImageType("qcow2")
+		require.NoError(t, err)
+		pm := distro_test_common.SerializeManifest(t, imageType, &local, distro.ImageOptions{})
+		assert.Contains(t, pm.InlineData(t), "datasource_list: [AltCloud, CloudSigma, ConfigDrive, IBMCloud, LXD, NoCloud, OVF, OpenNebula, RbxCloud, SmartOS, VMware, WSL, None]\n", distroName)
+
+		invalid := blueprint.Blueprint{
+			Customizations: &blueprint.Customizations{
+				CloudInit: &blueprint.CloudInitCustomization{DatasourceList: []string{"Ec2"}, DisableNetworkDatasources: true},
+			},
+		}
+		_, _, err = imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+		assert.EqualError(t, err, `cloud-init datasource "Ec2" is looked up over the network, but the network datasources are disabled`, distroName)
+
+		// the seed is restricted to some image types, but not the datasources
+		for _, imageTypeName := range arch.ListImageTypes() {
+			if imageTypeName != "ami" && imageTypeName != "vhd" {
+				continue
+			}
+			imageType, err := arch.GetImageType(imageTypeName)
+			require.NoError(t, err)
+			_, _, err = imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			assert.NoError(t, err, distroName)
+		}
+	}
+}
+
+func TestSwapCustomization(t *testing.T) {
+	partition := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Swap: &blueprint.SwapCustomization{Size: 2 * common.GibiByte},
+		},
+	}
+	for distroName, pm := range serializeCustomizationManifests(t, &partition) {
+		t.Run(distroName+"/partition", func(t *testing.T) {
+			mkswap := pm.StageOptions("image", "org.osbuild.mkswap")
+			require.Len(t, mkswap, 1)
+			assert.Regexp(t, `^{"uuid":"[0-9a-f-]{36}"}$`, mkswap[0])
+			fstab := strings.Join(pm.OSStageOptions("org.osbuild.fstab"), "")
+			assert.Regexp(t, `{"uuid":"[0-9a-f-]{36}","vfs_type":"swap","path":"none","options":"defaults"}`, fstab)
+			assert.NotContains(t, strings.Join(pm.OSStageOptions("org.osbuild.first-boot"), ""), "/swapfile")
+		})
+	}
+
+	file := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Swap: &blueprint.SwapCustomization{Size: 2 * common.GibiByte, Type: blueprint.SwapTypeFile},
+		},
+	}
+	for distroName, pm := range serializeCustomizationManifests(t, &file) {
+		t.Run(distroName+"/file", func(t *testing.T) {
+			assert.Empty(t, pm.StageOptions("image", "org.osbuild.mkswap"))
+			assert.NotContains(t, strings.Join(pm.OSStageOptions("org.osbuild.fstab"), ""), `"vfs_type":"swap"`)
+			firstBoot := strings.Join(pm.OSStageOptions("org.osbuild.first-boot"), "")
+			assert.Contains(t, firstBoot, "/usr/bin/fallocate -l 2147483648 /swapfile")
+			assert.Contains(t, firstBoot, "/usr/sbin/mkswap /swapfile")
+		})
+	}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+
+		imageType, err := arch.GetImageType("qcow2")
+		require.NoError(t, err)
+		imageSize := imageType.Size(0)
+		tooLarge := blueprint.Blueprint{Customizations: &blueprint.Customizations{
+			Swap: &blueprint.SwapCustomization{Size: imageSize},
+		}}
+		_, _, err = imageType.Manifest(&tooLarge, distro.ImageOptions{}, nil, 0)
+		assert.EqualError(t, err, fmt.Sprintf("swap size %d bytes is larger than half of the image size (%d bytes)", imageSize, imageSize), distroName)
+
+		for _, imageTypeName := range arch.ListImageTypes() {
+			if imageTypeName != "tar" && imageTypeName != "container" {
+				continue
+			}
+			imageType, err := arch.GetImageType(imageTypeName)
+			require.NoError(t, err)
+			_, _, err = imageType.Manifest(&partition, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, fmt.Sprintf("swap is not supported for image type %q", imageTypeName), distroName)
+		}
+	}
+}
+
+func TestUnallocatedSpaceCustomization(t *testing.T) {
+	space := uint64(2 * common.GibiByte)
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			UnallocatedSpace: common.ToPtr(space),
+		},
+	}
+	for distroName, pm := range serializeCustomizationManifests(t, &bp) {
+		t.Run(distroName, func(t *testing.T) {
+			imageSize := imageSize(t, pm)
+
+			partitioning := append(pm.StageOptions("image", "org.osbuild.sgdisk"), pm.StageOptions("image", "org.osbuild.sfdisk")...)
+			require.Len(t, partitioning, 1)
+			var partitionTable struct {
+				Partitions []struct {
+					Start uint64 `json:"start"`
+					Size  uint64 `json:"size"`
+				} `json:"partitions"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(partitioning[0]), &partitionTable))
+			var end uint64
+			for _, part := range partitionTable.Partitions {
+				if part.Start+part.Size > end {
+					end = part.Start + part.Size
+				}
+			}
+			// the sizes of the partitions are in sectors
+			assert.LessOrEqual(t, end*512, imageSize-space)
+		})
+	}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+
+		imageType, err := arch.GetImageType("qcow2")
+		require.NoError(t, err)
+		imageSize := imageType.Size(0)
+		tooLarge := blueprint.Blueprint{Customizations: &blueprint.Customizations{
+			UnallocatedSpace: common.ToPtr(imageSize),
+		}}
+		_, _, err = imageType.Manifest(&tooLarge, distro.ImageOptions{}, nil, 0)
+		assert.EqualError(t, err, fmt.Sprintf("unallocated space of %d bytes does not fit in the image size (%d bytes)", imageSize, imageSize), distroName)
+
+		for _, imageTypeName := range arch.ListImageTypes() {
+			if imageTypeName != "tar" && imageTypeName != "container" {
+				continue
+			}
+			imageType, err := arch.GetImageType(imageTypeName)
+			require.NoError(t, err)
+			_, _, err = imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, fmt.Sprintf("unallocated space is not supported for image type %q", imageTypeName), distroName)
+		}
+	}
+}
+
+func TestImageTypeMinimumSize(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Filesystem: []blueprint.FilesystemCustomization{
+				{Mountpoint: "/var", MinSize: 6 * common.GibiByte},
+				{Mountpoint: "/home", MinSize: 4 * common.GibiByte},
+			},
+		},
+	}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			minSize, err := imageType.MinimumSize(&bp)
+			require.NoError(t, err)
+			assert.Greater(t, minSize, uint64(10*common.GibiByte))
+
+			// the minimum size and larger ones are accepted, smaller ones
+			// are rejected
+			for _, size := range []uint64{minSize, minSize + common.GibiByte} {
+				pm := distro_test_common.SerializeManifest(t, imageType, &bp, distro.ImageOptions{Size: size})
+				assert.Equal(t, size, imageSize(t, pm))
+			}
+			_, _, err = imageType.Manifest(&bp, distro.ImageOptions{Size: minSize - common.MebiByte}, nil, 0)
+			assert.EqualError(t, err, fmt.Sprintf("requested size %d is smaller than the minimum size %d for the blueprint", minSize-common.MebiByte, minSize))
+
+			// without a requested size, the default size is grown to fit
+			assert.Equal(t, minSize, imageSize(t, distro_test_common.SerializeManifest(t, imageType, &bp, distro.ImageOptions{})))
+
+			// mountpoints smaller than their required size are grown, like
+			// /usr which needs 2 GiB
+			small, err := imageType.MinimumSize(&blueprint.Blueprint{Customizations: &blueprint.Customizations{
+				Filesystem: []blueprint.FilesystemCustomization{{Mountpoint: "/usr", MinSize: 1024}},
+			}})
+			require.NoError(t, err)
+			required, err := imageType.MinimumSize(&blueprint.Blueprint{Customizations: &blueprint.Customizations{
+				Filesystem: []blueprint.FilesystemCustomization{{Mountpoint: "/usr", MinSize: 2 * common.GibiByte}},
+			}})
+			require.NoError(t, err)
+			assert.Equal(t, required, small)
+
+			for _, imageTypeName := range arch.ListImageTypes() {
+				if imageTypeName != "tar" && imageTypeName != "container" {
+					continue
+				}
+				imageType, err := arch.GetImageType(imageTypeName)
+				require.NoError(t, err)
+				size, err := imageType.MinimumSize(&bp)
+				assert.NoError(t, err)
+				assert.Zero(t, size)
+			}
+		})
+	}
+}
+
+// Ensure that the repositories of a manifest include both the distro
+// repositories and the custom payload repositories
+func TestManifestRepositories(t *testing.T) {
+	distroRepos, err := rpmmd.LoadRepositories([]string{"../../test/data"}, "fedora-38")
+	require.NoError(t, err)
+	custom := rpmmd.RepoConfig{
+		Name:        "custom",
+		BaseURLs:    []string{"https://example.org/custom"},
+		Priority:    common.ToPtr(10),
+		PackageSets: []string{"blueprint"},
+	}
+	repos := append(distroRepos["x86_64"], custom)
+
+	d := distroregistry.NewDefault().GetDistro("fedora-38")
+	require.NotNil(t, d)
+	arch, err := d.GetArch("x86_64")
+	require.NoError(t, err)
+	imageType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	bp := blueprint.Blueprint{Packages: []blueprint.Package{{Name: "tmux"}}}
+	m, _, err := imageType.Manifest(&bp, distro.ImageOptions{}, repos, 0)
+	require.NoError(t, err)
+
+	exported := make(map[string]rpmmd.RepoConfig)
+	for _, repo := range m.GetRepositories() {
+		exported[repo.Name] = repo
+	}
+	assert.Len(t, exported, len(repos))
+	for _, repo := range repos {
+		require.Contains(t, exported, repo.Name)
+		assert.Equal(t, repo.BaseURLs, exported[repo.Name].BaseURLs)
+		assert.Equal(t, repo.Priority, exported[repo.Name].Priority)
+	}
+}
+
+// Ensure that the progress callback is called for each phase of the
+// generation of the manifest in order and doesn't change the manifest
+func TestManifestProgress(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			var phases []manifest.Phase
+			options := distro.ImageOptions{
+				Progress: func(phase manifest.Phase) {
+					phases = append(phases, phase)
+				},
+			}
+			bp := &blueprint.Blueprint{}
+			withProgress := distro_test_common.SerializeManifest(t, imageType, bp, options)
+			assert.Equal(t, []manifest.Phase{
+				manifest.PhaseResolvingPackageSets,
+				manifest.PhaseBuildingPipelines,
+				manifest.PhaseSerializing,
+			}, phases)
+
+			assert.Equal(t, distro_test_common.SerializeManifest(t, imageType, bp, distro.ImageOptions{}), withProgress)
+		})
+	}
+}
+
+// Ensure that the raw output exports the raw disk image that the qcow2 image
+// is converted from, building the os tree only once
+func TestRawOutput(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range []string{"fedora-39", "rhel-7", "rhel-89", "rhel-94"} {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			options := distro.ImageOptions{RawOutput: true}
+			assert.Equal(t, []distro.Output{
+				{Pipeline: "qcow2", Filename: imageType.Filename(), MIMEType: "application/x-qemu-disk"},
+				{Pipeline: "image", Filename: "disk.raw", MIMEType: "application/octet-stream"},
+			}, imageType.Outputs(options))
+			assert.Len(t, imageType.Outputs(distro.ImageOptions{}), 1)
+
+			m, _, err := imageType.Manifest(&blueprint.Blueprint{}, options, nil, 0)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"image", "qcow2"}, m.GetExports())
+
+			pm := distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, options)
+			var pipelines []string
+			for _, pl := range pm.Pipelines {
+				pipelines = append(pipelines, pl.Name)
+			}
+			assert.Equal(t, []string{"build", "os", "image", "qcow2"}, pipelines)
+			assert.Contains(t, strings.Join(pm.StageOptions("image", "org.osbuild.truncate"), ""), `"filename":"disk.raw"`)
+			assert.Contains(t, strings.Join(pm.StageOptions("qcow2", "org.osbuild.qemu"), ""), `"filename":"`+imageType.Filename()+`"`)
+		})
+	}
+
+	for distroName, imageTypeName := range map[string]string{"fedora-39": "minimal-raw", "rhel-94": "tar", "rhel-89": "edge-commit"} {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+		imageType, err := arch.GetImageType(imageTypeName)
+		require.NoError(t, err)
+		_, _, err = imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{RawOutput: true}, nil, 0)
+		assert.EqualError(t, err, fmt.Sprintf("raw output is not supported for image type %q", imageTypeName))
+	}
+}
+
+// Ensure that the VMDK subformat option selects the subformat of the qemu-img
+// conversion of vmdk images, streamOptimized by default
+func TestVMDKSubformat(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range []string{"fedora-39", "rhel-89", "rhel-94"} {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("vmdk")
+			require.NoError(t, err)
+
+			for subformat, want := range map[osbuild.VMDKSubformat]string{
+				"":                                    "streamOptimized",
+				osbuild.VMDKSubformatStreamOptimized:  "streamOptimized",
+				osbuild.VMDKSubformatMonolithicSparse: "monolithicSparse",
+			} {
+				pm := distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, distro.ImageOptions{VMDKSubformat: subformat})
+				qemu := pm.StageOptions("vmdk", "org.osbuild.qemu")
+				require.Len(t, qemu, 1)
+				assert.Contains(t, qemu[0], `"format":{"type":"vmdk","subformat":"`+want+`"}`, subformat)
+			}
+
+			_, _, err = imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{VMDKSubformat: osbuild.VMDKSubformatMonolithicFlat}, nil, 0)
+			assert.EqualError(t, err, `unsupported VMDK subformat "monolithicFlat" (supported: streamOptimized, monolithicSparse)`)
+
+			qcow2, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+			_, _, err = qcow2.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{VMDKSubformat: osbuild.VMDKSubformatMonolithicSparse}, nil, 0)
+			assert.EqualError(t, err, `VMDK subformat is not supported for image type "qcow2"`)
+		})
+	}
+}
+
+// Ensure that the VHD subformat option selects the subformat of the qemu-img
+// conversion of vhd images and that the Azure images are always fixed
+func TestVHDSubformat(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range []string{"fedora-39", "rhel-89", "rhel-94"} {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("vhd")
+			require.NoError(t, err)
+
+			pm := distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, distro.ImageOptions{})
+			qemu := pm.StageOptions("vpc", "org.osbuild.qemu")
+			require.Len(t, qemu, 1)
+			assert.NotContains(t, qemu[0], `"subformat"`)
+
+			pm = distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, distro.ImageOptions{VHDSubformat: osbuild.VPCSubformatFixed})
+			qemu = pm.StageOptions("vpc", "org.osbuild.qemu")
+			require.Len(t, qemu, 1)
+			assert.Contains(t, qemu[0], `"subformat":"fixed"`)
+
+			_, _, err = imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{VHDSubformat: osbuild.VPCSubformatDynamic}, nil, 0)
+			assert.EqualError(t, err, `image type "vhd" is for Azure, which requires fixed VHDs`)
+
+			_, _, err = imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{VHDSubformat: "sparse"}, nil, 0)
+			assert.EqualError(t, err, `unsupported VHD subformat "sparse" (supported: fixed, dynamic)`)
+
+			qcow2, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+			_, _, err = qcow2.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{VHDSubformat: osbuild.VPCSubformatFixed}, nil, 0)
+			assert.EqualError(t, err, `VHD subformat is not supported for image type "qcow2"`)
+		})
+	}
+}
+
+// Ensure that the OVA options end up in the options of the ovf stage that
+// generates the OVF descriptor of ova images
+func TestOVAOptions(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range []string{"fedora-39", "rhel-89", "rhel-94"} {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("ova")
+			require.NoError(t, err)
+
+			pm := distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, distro.ImageOptions{})
+			assert.Equal(t, []string{`{"vmdk":"image.vmdk"}`}, pm.StageOptions("ovf", "org.osbuild.ovf"))
+
+			options := distro.ImageOptions{
+				OVA: &osbuild.OVFVirtualMachineOptions{HardwareVersion: 19, NetworkAdapter: "e1000", MemoryMiB: 4096, CPUs: 2},
+			}
+			pm = distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, options)
+			assert.Equal(t, []string{`{"vmdk":"image.vmdk","virtual_machine":{"hardware_version":19,"network_adapter":"e1000","memory_mib":4096,"cpus":2}}`}, pm.StageOptions("ovf", "org.osbuild.ovf"))
+
+			options.OVA.HardwareVersion = 30
+			_, _, err = imageType.Manifest(&blueprint.Blueprint{}, options, nil, 0)
+			assert.EqualError(t, err, "virtual hardware version 30 is outside of the supported range 4-21")
+
+			vmdk, err := arch.GetImageType("vmdk")
+			require.NoError(t, err)
+			_, _, err = vmdk.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{OVA: &osbuild.OVFVirtualMachineOptions{CPUs: 2}}, nil, 0)
+			assert.EqualError(t, err, `OVA options are not supported for image type "vmdk"`)
+		})
+	}
+}
+
+// Ensure that the qcow2 compression ends up in the options of the qemu stage
+// that converts the raw image, and that zstd is only allowed with a qemu-img
+// that supports it
+func TestQCOW2Compression(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range []string{"fedora-39", "rhel-94"} {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			pm := distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, distro.ImageOptions{})
+			assert.Equal(t, []string{`{"filename":"disk.qcow2","format":{"type":"qcow2","compat":"1.1"}}`}, pm.StageOptions("qcow2", "org.osbuild.qemu"))
+
+			pm = distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, distro.ImageOptions{QCOW2Compression: osbuild.QCOW2CompressionZstd})
+			assert.Equal(t, []string{`{"filename":"disk.qcow2","format":{"type":"qcow2","compat":"1.1","compression_type":"zstd"}}`}, pm.StageOptions("qcow2", "org.osbuild.qemu"))
+
+			_, _, err = imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{QCOW2Compression: "lz4"}, nil, 0)
+			assert.EqualError(t, err, `unsupported qcow2 compression "lz4" (supported: zlib, zstd)`)
+
+			vmdk, err := arch.GetImageType("vmdk")
+			require.NoError(t, err)
+			_, _, err = vmdk.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{QCOW2Compression: osbuild.QCOW2CompressionZstd}, nil, 0)
+			assert.EqualError(t, err, `qcow2 compression is not supported for image type "vmdk"`)
+		})
+	}
+}
+
+// Ensure that the qcow2 cluster size and preallocation end up in the options
+// of the qemu stage that converts the raw image
+func TestQCOW2ClusterSizePreallocation(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range []string{"fedora-39", "rhel-89", "rhel-94"} {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			pm := distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, distro.ImageOptions{})
+			require.Len(t, pm.StageOptions("qcow2", "org.osbuild.qemu"), 1)
+			assert.NotContains(t, pm.StageOptions("qcow2", "org.osbuild.qemu")[0], "cluster_size")
+			assert.NotContains(t, pm.StageOptions("qcow2", "org.osbuild.qemu")[0], "preallocation")
+
+			options := distro.ImageOptions{QCOW2ClusterSize: 2 * common.MebiByte, QCOW2Preallocation: osbuild.QCOW2PreallocationMetadata}
+			pm = distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, options)
+			require.Len(t, pm.StageOptions("qcow2", "org.osbuild.qemu"), 1)
+			assert.Contains(t, pm.StageOptions("qcow2", "org.osbuild.qemu")[0], `"cluster_size":2097152,"preallocation":"metadata"}`)
+
+			_, _, err = imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{QCOW2ClusterSize: 1000}, nil, 0)
+			assert.EqualError(t, err, "'cluster_size' option must be a power of two between 512 and 2097152, not 1000")
+
+			_, _, err = imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{QCOW2Preallocation: "sparse"}, nil, 0)
+			assert.EqualError(t, err, `'preallocation' option does not allow "sparse" as a value`)
+
+			_, _, err = imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{QCOW2Preallocation: osbuild.QCOW2PreallocationFull}, nil, 0)
+			assert.NoError(t, err)
+
+			vmdk, err := arch.GetImageType("vmdk")
+			require.NoError(t, err)
+			_, _, err = vmdk.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{QCOW2ClusterSize: 65536}, nil, 0)
+			assert.EqualError(t, err, `qcow2 cluster size and preallocation are not supported for image type "vmdk"`)
+		})
+	}
+}
+
+// Ensure that the Proxmox options export a virtual machine configuration
+// with the given settings next to the disk image
+func TestProxmoxOptions(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range []string{"fedora-39", "rhel-89", "rhel-94"} {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			pm := distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, distro.ImageOptions{})
+			assert.Empty(t, pm.StageOptions("proxmox", "org.osbuild.copy"))
+
+			options := distro.ImageOptions{
+				Proxmox: &manifest.ProxmoxVMOptions{Name: "homelab", Cores: 4, MemoryMiB: 8192},
+			}
+			assert.Equal(t, distro.Output{Pipeline: "proxmox", Filename: "proxmox.conf", MIMEType: "text/plain"}, imageType.Outputs(options)[1])
+
+			pm = distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, options)
+			assert.Contains(t, strings.Join(pm.StageOptions("proxmox", "org.osbuild.copy"), ""), `"to":"tree:///proxmox.conf"`)
+			var config string
+			for _, data := range pm.InlineData(t) {
+				if strings.Contains(data, "qm importdisk") {
+					config = data
+				}
+			}
+			assert.Contains(t, config, "qm importdisk <vmid> "+imageType.Filename()+" <storage>\n")
+			assert.Contains(t, config, "\ncores: 4\n")
+			assert.Contains(t, config, "\nmemory: 8192\n")
+			assert.Contains(t, config, "\nname: homelab\n")
+			assert.Contains(t, config, "\nnet0: virtio,bridge=vmbr0\n")
+			assert.Contains(t, config, "\nbios: seabios\n")
+
+			options.Proxmox.MemoryMiB = 8
+			_, _, err = imageType.Manifest(&blueprint.Blueprint{}, options, nil, 0)
+			assert.EqualError(t, err, "proxmox virtual machine memory 8 MiB is less than the minimum of 16 MiB")
+
+			vmdk, err := arch.GetImageType("vmdk")
+			require.NoError(t, err)
+			_, _, err = vmdk.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{Proxmox: &manifest.ProxmoxVMOptions{}}, nil, 0)
+			assert.EqualError(t, err, `Proxmox options are not supported for image type "vmdk"`)
+		})
+	}
+}
+
+// Ensure that the content addressed filenames of the outputs have the hash of
+// the manifest with the static filenames, which is the same for the same
+// inputs and differs when they change
+func TestContentAddressedFilenames(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range []string{"fedora-39", "rhel-7", "rhel-89", "rhel-94"} {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+			bp := &blueprint.Blueprint{Customizations: &blueprint.Customizations{Hostname: common.ToPtr("first")}}
+
+			options := distro.ImageOptions{}
+			hash := distro_test_common.SerializeOSBuildManifest(t, imageType, bp, options).ContentHash()
+			assert.Len(t, hash, 64)
+
+			options.ContentAddressedFilenames = true
+			mf := distro_test_common.SerializeOSBuildManifest(t, imageType, bp, options)
+			assert.Equal(t, mf, distro_test_common.SerializeOSBuildManifest(t, imageType, bp, options))
+
+			pm := new(distro_test_common.SerializedManifest)
+			require.NoError(t, json.Unmarshal(mf, pm))
+			qemu := pm.StageOptions("qcow2", "org.osbuild.qemu")
+			require.Len(t, qemu, 1)
+			filename := manifest.ContentAddressedFilename(imageType.Filename(), hash)
+			assert.Contains(t, qemu[0], `"filename":"`+filename+`"`)
+
+			bp.Customizations.Hostname = common.ToPtr("second")
+			pm = distro_test_common.SerializeManifest(t, imageType, bp, options)
+			qemu = pm.StageOptions("qcow2", "org.osbuild.qemu")
+			require.Len(t, qemu, 1)
+			assert.NotContains(t, qemu[0], filename)
+			assert.Contains(t, qemu[0], `"filename":"`+strings.TrimSuffix(imageType.Filename(), ".qcow2")+"-")
+		})
+	}
+}
+
+// Ensure that the source date epoch is set on all pipelines, so that builds
+// with the same epoch have the same content hash and builds with different
+// ones don't
+func TestSourceDateEpoch(t *testing.T) {
+	distros := distroregistry.NewDefault()
+	for _, distroName := range []string{"fedora-39", "rhel-7", "rhel-89", "rhel-94"} {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+			bp := &blueprint.Blueprint{}
+
+			options := distro.ImageOptions{SourceDateEpoch: common.ToPtr(int64(1696161600))}
+			mf := distro_test_common.SerializeOSBuildManifest(t, imageType, bp, options)
+			assert.Equal(t, mf.ContentHash(), distro_test_common.SerializeOSBuildManifest(t, imageType, bp, options).ContentHash())
+
+			var parsed struct {
+				Pipelines []struct {
+					Name        string `json:"name"`
+					SourceEpoch *int64 `json:"source-epoch"`
+				} `json:"pipelines"`
+			}
+			require.NoError(t, json.Unmarshal(mf, &parsed))
+			require.NotEmpty(t, parsed.Pipelines)
+			for _, pipeline := range parsed.Pipelines {
+				assert.Equal(t, common.ToPtr(int64(1696161600)), pipeline.SourceEpoch, pipeline.Name)
+			}
+
+			other := distro_test_common.SerializeOSBuildManifest(t, imageType, bp, distro.ImageOptions{SourceDateEpoch: common.ToPtr(int64(1696165200))})
+			assert.NotEqual(t, mf.ContentHash(), other.ContentHash())
+			unset := distro_test_common.SerializeOSBuildManifest(t, imageType, bp, distro.ImageOptions{})
+			assert.NotContains(t, string(unset), "source-epoch")
+
+			_, _, err = imageType.Manifest(bp, distro.ImageOptions{SourceDateEpoch: common.ToPtr(int64(-1))}, nil, 0)
+			assert.EqualError(t, err, "source date epoch -1 is before 1970")
+		})
+	}
+}
+
+func TestSourceDateEpochFromEnv(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	epoch, err := distro.SourceDateEpochFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, epoch)
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1696161600")
+	epoch, err = distro.SourceDateEpochFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, common.ToPtr(int64(1696161600)), epoch)
+
+	for _, value := range []string{"yesterday", "-1", "1696161600.5"} {
+		t.Setenv("SOURCE_DATE_EPOCH", value)
+		_, err = distro.SourceDateEpochFromEnv()
+		assert.Error(t, err, value)
+	}
+}
+
+// Ensure that a saved and loaded manifest is the one that was serialized,
+// with the same content hash, and has everything needed to build it
+func TestNoWeakDeps(t *testing.T) {
+	bp := &blueprint.Blueprint{
+		Packages:        []blueprint.Package{{Name: "tmux"}},
+		InstallWeakDeps: common.ToPtr(true),
+	}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			m, _, err := imageType.Manifest(bp, distro.ImageOptions{}, nil, 0)
+			require.NoError(t, err)
+			assert.True(t, m.GetPackageSetChains()["os"][0].InstallWeakDeps)
+
+			m, _, err = imageType.Manifest(bp, distro.ImageOptions{NoWeakDeps: true}, nil, 0)
+			require.NoError(t, err)
+			chains := m.GetPackageSetChains()
+			require.Contains(t, chains, "os")
+			for name, chain := range chains {
+				for _, ps := range chain {
+					// the build root is not part of the image
+					assert.Equal(t, name == "build", ps.InstallWeakDeps, name)
+				}
+			}
+		})
+	}
+}
+
+func TestManifestSaveLoad(t *testing.T) {
+	arch, err := distroregistry.NewDefault().GetDistro("fedora-39").GetArch("x86_64")
+	require.NoError(t, err)
+	imageType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	m, _, err := imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{}, nil, 0)
+	require.NoError(t, err)
+	packageSets := make(map[string][]rpmmd.PackageSpec)
+	for _, plName := range append(imageType.BuildPipelines(), imageType.PayloadPipelines()...) {
+		packageSets[plName] = []rpmmd.PackageSpec{
+			{Name: "kernel", Checksum: "sha256:a0c936696eb7d5ee3192bf53b9d281cecbb40ca9db520de72cb95817ad92ac72", RemoteLocation: "https://example.com/kernel.rpm"},
+		}
+	}
+	mf, err := m.Serialize(packageSets, nil, nil)
+	require.NoError(t, err)
+
+	var saved bytes.Buffer
+	require.NoError(t, m.Save(&saved, packageSets, nil, nil))
+	loaded, err := manifest.Load(&saved)
+	require.NoError(t, err)
+
+	assert.Equal(t, mf, loaded.Manifest)
+	assert.Equal(t, mf.ContentHash(), loaded.ContentHash())
+	assert.Equal(t, imageType.Exports(), loaded.Exports)
+	assert.Equal(t, m.GetCheckpoints(), loaded.Checkpoints)
+	assert.Equal(t, imageType.BuildPipelines(), loaded.BuildPipelines)
+	assert.Equal(t, packageSets, loaded.PackageSets)
+}
+
+// Ensure that the hybrid x86_64 images can be booted in all the firmware
+// modes and the aarch64 ones only with UEFI
+func TestSupportedBootModes(t *testing.T) {
+	assert.Empty(t, distro.BOOT_NONE.SupportedBootModes())
+	assert.Equal(t, []string{distro.FirmwareBootModeLegacyBIOS}, distro.BOOT_LEGACY.SupportedBootModes())
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		d := distros.GetDistro(distroName)
+		for archName, expected := range map[string][]string{
+			"x86_64":  {distro.FirmwareBootModeLegacyBIOS, distro.FirmwareBootModeUEFI, distro.FirmwareBootModeUEFIPreferred},
+			"aarch64": {distro.FirmwareBootModeUEFI},
+		} {
+			arch, err := d.GetArch(archName)
+			if err != nil {
+				continue
+			}
+			imageType, err := arch.GetImageType("ami")
+			if err != nil {
+				continue
+			}
+			assert.Equal(t, expected, imageType.BootMode().SupportedBootModes(), "%s/%s", distroName, archName)
+		}
+	}
+}
+
+// Ensure that the repositories of the package sets are depsolved through the
+// proxy, except for the ones excluded by NO_PROXY
+func TestProxyOptions(t *testing.T) {
+	proxy := &distro.ProxyOptions{
+		URL:     "http://proxy.example.com:3128",
+		NoProxy: []string{".internal.example.com", "10.0.0.0/8", "mirror.example.org:8080"},
+	}
+	repos := []rpmmd.RepoConfig{
+		{Name: "public", BaseURLs: []string{"https://cdn.example.com/os"}},
+		{Name: "internal", BaseURLs: []string{"https://repo.internal.example.com/os"}},
+		{Name: "domain", Metalink: "https://internal.example.com/metalink"},
+		{Name: "address", BaseURLs: []string{"http://10.1.2.3/os"}},
+		{Name: "port", MirrorList: "http://mirror.example.org:8080/mirrorlist"},
+		{Name: "suffix", BaseURLs: []string{"https://notinternal.example.com/os"}},
+	}
+
+	proxied := proxy.ApplyToRepos(repos)
+	require.Len(t, proxied, len(repos))
+	for idx, expected := range []string{"http://proxy.example.com:3128", "", "", "", "", "http://proxy.example.com:3128"} {
+		assert.Equal(t, expected, proxied[idx].Proxy, repos[idx].Name)
+		assert.Empty(t, repos[idx].Proxy, "the repositories are copied")
+	}
+	var none *distro.ProxyOptions
+	assert.Equal(t, repos, none.ApplyToRepos(repos))
+	assert.Empty(t, (&distro.ProxyOptions{URL: "http://proxy:3128", NoProxy: []string{"*"}}).ApplyToRepos(repos)[0].Proxy)
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range []string{"fedora-39", "rhel-7", "rhel-89", "rhel-94"} {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+		imageType, err := arch.GetImageType("qcow2")
+		require.NoError(t, err)
+
+		m, _, err := imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{Proxy: proxy}, repos[:2], 0)
+		require.NoError(t, err)
+		for name, chain := range m.GetPackageSetChains() {
+			for _, ps := range chain {
+				for _, repo := range ps.Repositories {
+					if repo.Name == "public" {
+						assert.Equal(t, proxy.URL, repo.Proxy, "%s/%s", distroName, name)
+					} else {
+						assert.Empty(t, repo.Proxy, "%s/%s", distroName, name)
+					}
+				}
+			}
+		}
+
+		_, _, err = imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{Proxy: &distro.ProxyOptions{URL: "ftp://proxy.example.com"}}, repos[:1], 0)
+		assert.EqualError(t, err, `proxy "ftp://proxy.example.com" has an unsupported scheme "ftp"`, distroName)
+		_, _, err = imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{Proxy: &distro.ProxyOptions{URL: "proxy.example.com:3128"}}, repos[:1], 0)
+		assert.Error(t, err, distroName)
+	}
+}
+
+func TestProxyOptionsFromEnv(t *testing.T) {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "NO_PROXY", "no_proxy"} {
+		t.Setenv(name, "")
+	}
+	assert.Nil(t, distro.ProxyOptionsFromEnv())
+
+	t.Setenv("http_proxy", "http://proxy.example.com:3128")
+	assert.Equal(t, &distro.ProxyOptions{URL: "http://proxy.example.com:3128"}, distro.ProxyOptionsFromEnv())
+
+	t.Setenv("HTTPS_PROXY", "http://secure-proxy.example.com:3128")
+	t.Setenv("NO_PROXY", "localhost, .example.com,,10.0.0.0/8")
+	assert.Equal(t, &distro.ProxyOptions{
+		URL:     "http://secure-proxy.example.com:3128",
+		NoProxy: []string{"localhost", ".example.com", "10.0.0.0/8"},
+	}, distro.ProxyOptionsFromEnv())
+}
@@ -0,0 +1,241 @@
+package distro_test_common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func qcow2ImageType(t *testing.T, d distro.Distro) distro.ImageType {
+	arch, err := d.GetArch("x86_64")
+	require.NoError(t, err)
+	imageType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+	return imageType
+}
+
+func osPackages(t *testing.T, imageType distro.ImageType, bp *blueprint.Blueprint) []string {
+	m, _, err := imageType.Manifest(bp, distro.ImageOptions{}, nil, 0)
+	require.NoError(t, err)
+	var include []string
+	for _, ps := range m.GetPackageSetChains()["os"] {
+		include = append(include, ps.Include...)
+	}
+	return include
+}
+
+// Ensure that the dnf-automatic customization installs dnf-automatic, writes
+// its configuration and the schedule of the timer and enables the timer
+func TestDistro_DNFAutomaticCustomization(t *testing.T, d distro.Distro) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			DNFAutomatic: &blueprint.DNFAutomaticCustomization{
+				Mode:     blueprint.DNFAutomaticModeDownload,
+				Schedule: "Sun 03:00",
+			},
+		},
+	}
+
+	imageType := qcow2ImageType(t, d)
+	assert.Contains(t, osPackages(t, imageType, &bp), "dnf-automatic")
+
+	pm := SerializeManifest(t, imageType, &bp, distro.ImageOptions{})
+	copies := strings.Join(pm.OSStageOptions("org.osbuild.copy"), "")
+	assert.Contains(t, copies, `"to":"tree:///etc/dnf/automatic.conf"`)
+	assert.Contains(t, copies, `"to":"tree:///etc/systemd/system/dnf-automatic.timer.d/90-blueprint.conf"`)
+	assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.mkdir"), ""), `"path":"/etc/systemd/system/dnf-automatic.timer.d"`)
+	assert.Contains(t, pm.InlineData(t), "[commands]\nupgrade_type = default\ndownload_updates = yes\napply_updates = no\n\n[emitters]\nemit_via = stdio\n")
+	assert.Contains(t, pm.InlineData(t), "[Timer]\nOnCalendar=\nOnCalendar=Sun 03:00\n")
+
+	systemd := pm.OSStageOptions("org.osbuild.systemd")
+	require.Len(t, systemd, 1)
+	assert.Contains(t, systemd[0], `"dnf-automatic.timer"`)
+
+	invalid := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			DNFAutomatic: &blueprint.DNFAutomaticCustomization{Mode: "notify"},
+		},
+	}
+	_, _, err := imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+	assert.EqualError(t, err, `unsupported dnf-automatic mode "notify" (supported: apply, download)`)
+}
+
+// Ensure that the ignition config is embedded in the initramfs of the disk
+// images and that ignition runs on the first boot
+func TestDistro_IgnitionCustomization(t *testing.T, d distro.Distro) {
+	config := `{"ignition":{"version":"3.4.0"},"passwd":{"users":[{"name":"core"}]}}`
+	embedded := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Ignition: &blueprint.IgnitionCustomization{
+				Embedded: &blueprint.EmbeddedIgnitionCustomization{Config: base64.StdEncoding.EncodeToString([]byte(config))},
+			},
+		},
+	}
+	firstBoot := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Ignition: &blueprint.IgnitionCustomization{
+				FirstBoot: &blueprint.FirstBootIgnitionCustomization{ProvisioningURL: "https://example.com/config.ign"},
+			},
+		},
+	}
+
+	imageType := qcow2ImageType(t, d)
+	assert.Contains(t, osPackages(t, imageType, &embedded), "ignition")
+
+	pm := SerializeManifest(t, imageType, &embedded, distro.ImageOptions{})
+	assert.Contains(t, pm.OSStageOptions("org.osbuild.dracut.conf"), `{"filename":"40-ignition.conf","config":{"add_dracutmodules":["ignition"]}}`)
+	dracut := strings.Join(pm.OSStageOptions("org.osbuild.dracut"), "")
+	assert.Contains(t, dracut, `"add_modules":["ignition"],"include":{"/usr/lib/ignition/user.ign":"/usr/lib/ignition/user.ign"}`)
+	assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.copy"), ""), `"to":"tree:///usr/lib/ignition/user.ign"`)
+	assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.chmod"), ""), `"/usr/lib/ignition/user.ign":{"mode":"0600"}`)
+	assert.Contains(t, pm.InlineData(t), config)
+	assert.Len(t, pm.OSStageOptions("org.osbuild.ignition"), 1)
+	assert.Contains(t, pm.KernelCmdline(), "ignition.platform.id=qemu $ignition_firstboot")
+	assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.grub2"), ""), `"ignition":true`)
+
+	pm = SerializeManifest(t, imageType, &firstBoot, distro.ImageOptions{})
+	assert.Contains(t, pm.KernelCmdline(), "ignition.config.url=https://example.com/config.ign")
+	assert.Contains(t, pm.KernelCmdline(), "ignition.platform.id=qemu $ignition_firstboot")
+	assert.NotContains(t, strings.Join(pm.OSStageOptions("org.osbuild.copy"), ""), "user.ign")
+
+	invalid := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Ignition: &blueprint.IgnitionCustomization{
+				Embedded: &blueprint.EmbeddedIgnitionCustomization{Config: base64.StdEncoding.EncodeToString([]byte(`{"passwd":{}}`))},
+			},
+		},
+	}
+	_, _, err := imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+	assert.EqualError(t, err, `ignition embedded config has an invalid ignition.version ""`)
+}
+
+// Ensure that the documentation is excluded by the rpm stage and the dnf
+// configuration and that only the translations of the locales are installed
+func TestDistro_MinimizeCustomization(t *testing.T, d distro.Distro) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Locale:   &blueprint.LocaleCustomization{Languages: []string{"de_DE.UTF-8"}},
+			Minimize: &blueprint.MinimizeCustomization{NoDocs: true, Locales: []string{"en", "de"}},
+		},
+	}
+
+	imageType := qcow2ImageType(t, d)
+	pm := SerializeManifest(t, imageType, &bp, distro.ImageOptions{})
+	rpm := strings.Join(pm.OSStageOptions("org.osbuild.rpm"), "")
+	assert.Contains(t, rpm, `"exclude":{"docs":true}`)
+	assert.Contains(t, rpm, `"install_langs":["en","de"]`)
+	assert.Contains(t, pm.OSStageOptions("org.osbuild.dnf.config"), `{"config":{"main":{"tsflags":["nodocs"]}}}`)
+	assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.copy"), ""), `"to":"tree:///etc/rpm/macros.image-language-conf"`)
+	assert.Contains(t, pm.InlineData(t), "%_install_langs en:de\n")
+
+	invalid := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Locale:   &blueprint.LocaleCustomization{Languages: []string{"en_US.UTF-8"}},
+			Minimize: &blueprint.MinimizeCustomization{Locales: []string{"de"}},
+		},
+	}
+	_, _, err := imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+	assert.EqualError(t, err, `minimize locales de do not keep the locale "en_US.UTF-8" of the image`)
+}
+
+// Ensure that the module streams are enabled in the os tree and in the
+// depsolve of the os package sets
+func TestDistro_ModuleCustomization(t *testing.T, d distro.Distro) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			EnabledModules: []string{"nodejs:18", "postgresql:15/server"},
+		},
+	}
+
+	imageType := qcow2ImageType(t, d)
+	pm := SerializeManifest(t, imageType, &bp, distro.ImageOptions{})
+	assert.Equal(t, []string{
+		`{"conf":{"name":"nodejs","stream":"18","state":"enabled","profiles":[]}}`,
+		`{"conf":{"name":"postgresql","stream":"15","state":"enabled","profiles":["server"]}}`,
+	}, pm.OSStageOptions("org.osbuild.dnf.module-config"))
+
+	m, _, err := imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+	require.NoError(t, err)
+	for _, ps := range m.GetPackageSetChains()["os"] {
+		assert.Equal(t, bp.Customizations.EnabledModules, ps.EnabledModules)
+	}
+
+	invalid := blueprint.Blueprint{Customizations: &blueprint.Customizations{EnabledModules: []string{"nodejs"}}}
+	_, _, err = imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+	assert.EqualError(t, err, `module "nodejs" must be specified as name:stream or name:stream/profile`)
+}
+
+// Ensure that FIPS mode sets the crypto policy and the kernel command line in
+// addition to the appended kernel options, and that it is rejected for ostree
+// commits
+func TestDistro_FIPSCustomization(t *testing.T, d distro.Distro) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			FIPS:   common.ToPtr(true),
+			Kernel: &blueprint.KernelCustomization{Append: "debug"},
+		},
+	}
+
+	imageType := qcow2ImageType(t, d)
+	assert.Contains(t, osPackages(t, imageType, &bp), "crypto-policies-scripts")
+
+	pm := SerializeManifest(t, imageType, &bp, distro.ImageOptions{})
+	assert.Equal(t, []string{`{"policy":"FIPS"}`}, pm.OSStageOptions("org.osbuild.update-crypto-policies"))
+	assert.Contains(t, pm.OSStageOptions("org.osbuild.dracut.conf"), `{"filename":"40-fips.conf","config":{"add_dracutmodules":["fips"]}}`)
+	assert.Contains(t, pm.KernelCmdline(), "debug fips=1")
+
+	arch, err := d.GetArch("x86_64")
+	require.NoError(t, err)
+	fips := blueprint.Blueprint{Customizations: &blueprint.Customizations{FIPS: common.ToPtr(true)}}
+	for _, imageType := range OSTreeCommitImageTypes(t, arch) {
+		_, _, err = imageType.Manifest(&fips, distro.ImageOptions{}, nil, 0)
+		assert.EqualError(t, err, "FIPS mode is not supported for ostree types", imageType.Name())
+	}
+}
+
+// Ensure that the kernel variant is installed and that the bootloader
+// defaults to its entry
+func TestDistro_KernelVariantCustomization(t *testing.T, d distro.Distro, variant string) {
+	bp := blueprint.Blueprint{Customizations: &blueprint.Customizations{Kernel: &blueprint.KernelCustomization{Name: variant}}}
+
+	imageType := qcow2ImageType(t, d)
+	m, _, err := imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+	require.NoError(t, err)
+	assert.Contains(t, m.GetPackageSetChains()["os"][0].Include, variant)
+
+	packages := []rpmmd.PackageSpec{
+		{Name: variant, Version: "5.14.0", Release: "362.rt14.el9", Arch: "x86_64", Checksum: "sha256:a0c936696eb7d5ee3192bf53b9d281cecbb40ca9db520de72cb95817ad92ac72"},
+		{Name: "filesystem", Checksum: "sha256:6b4bf18ba28ccbdd49f2716c9f33c9211155ff703fa6c195c78a07bd160da0eb"},
+	}
+	packageSets := make(map[string][]rpmmd.PackageSpec)
+	for _, plName := range append(imageType.BuildPipelines(), imageType.PayloadPipelines()...) {
+		packageSets[plName] = packages
+	}
+	mf, err := m.Serialize(packageSets, nil, nil)
+	require.NoError(t, err)
+	pm := new(SerializedManifest)
+	require.NoError(t, json.Unmarshal(mf, pm))
+
+	// the bootloader defaults to the entry of the selected kernel
+	if grub2 := pm.OSStageOptions("org.osbuild.grub2"); len(grub2) > 0 {
+		assert.Contains(t, grub2[0], `"saved_entry":"ffffffffffffffffffffffffffffffff-5.14.0-362.rt14.el9.x86_64"`)
+	} else {
+		legacy := pm.OSStageOptions("org.osbuild.grub2.legacy")
+		require.Len(t, legacy, 1)
+		assert.Contains(t, legacy[0], `"default":true`)
+		assert.Contains(t, legacy[0], `"kernel":"5.14.0-362.rt14.el9.x86_64"`)
+	}
+	for _, sysconfig := range pm.OSStageOptions("org.osbuild.sysconfig") {
+		assert.Contains(t, sysconfig, fmt.Sprintf(`"default_kernel":"%s`, variant))
+	}
+}
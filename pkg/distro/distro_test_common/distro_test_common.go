@@ -165,6 +165,7 @@ func TestDistro_OSTreeOptions(t *testing.T, d distro.Distro) {
 		"edge-container": true,
 		"iot-commit":     true,
 		"iot-container":  true,
+		"bootc":          true,
 	}
 
 	typesWithPayload := map[string]bool{
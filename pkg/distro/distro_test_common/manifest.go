@@ -0,0 +1,127 @@
+package distro_test_common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// SerializedManifest is the subset of a serialized manifest inspected by the
+// customization tests
+type SerializedManifest struct {
+	Pipelines []struct {
+		Name   string `json:"name"`
+		Stages []struct {
+			Type    string          `json:"type"`
+			Options json.RawMessage `json:"options"`
+		} `json:"stages"`
+	} `json:"pipelines"`
+	Sources struct {
+		Inline struct {
+			Items map[string]struct {
+				Encoding string `json:"encoding"`
+				Data     string `json:"data"`
+			} `json:"items"`
+		} `json:"org.osbuild.inline"`
+		Curl struct {
+			Items map[string]json.RawMessage `json:"items"`
+		} `json:"org.osbuild.curl"`
+	} `json:"sources"`
+}
+
+// StageOptions returns the options of all the stages of the given type in
+// the named pipeline.
+func (m *SerializedManifest) StageOptions(pipelineName, stageType string) []string {
+	var options []string
+	for _, pl := range m.Pipelines {
+		if pl.Name != pipelineName {
+			continue
+		}
+		for _, s := range pl.Stages {
+			if s.Type == stageType {
+				options = append(options, string(s.Options))
+			}
+		}
+	}
+	return options
+}
+
+// OSStageOptions returns the options of all the stages of the given type in
+// the os pipeline.
+func (m *SerializedManifest) OSStageOptions(stageType string) []string {
+	return m.StageOptions("os", stageType)
+}
+
+// InlineData returns the decoded inline sources of the manifest.
+func (m *SerializedManifest) InlineData(t *testing.T) []string {
+	var data []string
+	for _, item := range m.Sources.Inline.Items {
+		require.Equal(t, "base64", item.Encoding)
+		decoded, err := base64.StdEncoding.DecodeString(item.Data)
+		require.NoError(t, err)
+		data = append(data, string(decoded))
+	}
+	return data
+}
+
+// KernelCmdline returns the options of the stages of the os pipeline that set
+// the kernel command line, which depend on the distro.
+func (m *SerializedManifest) KernelCmdline() string {
+	var cmdline []string
+	for _, stageType := range []string{"org.osbuild.kernel-cmdline", "org.osbuild.grub2", "org.osbuild.grub2.legacy"} {
+		cmdline = append(cmdline, m.OSStageOptions(stageType)...)
+	}
+	return strings.Join(cmdline, "")
+}
+
+// SerializeManifest serializes the manifest of the image type for the given
+// blueprint and options.
+func SerializeManifest(t *testing.T, imageType distro.ImageType, bp *blueprint.Blueprint, options distro.ImageOptions) *SerializedManifest {
+	pm := new(SerializedManifest)
+	require.NoError(t, json.Unmarshal(SerializeOSBuildManifest(t, imageType, bp, options), pm))
+	return pm
+}
+
+// SerializeOSBuildManifest serializes the manifest of the image type with a
+// minimal package set for all the pipelines.
+func SerializeOSBuildManifest(t *testing.T, imageType distro.ImageType, bp *blueprint.Blueprint, options distro.ImageOptions) manifest.OSBuildManifest {
+	// Pipelines that require package sets will fail if none are defined. OS
+	// pipelines require a kernel.
+	minimalPackageSet := []rpmmd.PackageSpec{
+		{Name: "kernel", Checksum: "sha256:a0c936696eb7d5ee3192bf53b9d281cecbb40ca9db520de72cb95817ad92ac72"},
+		{Name: "filesystem", Checksum: "sha256:6b4bf18ba28ccbdd49f2716c9f33c9211155ff703fa6c195c78a07bd160da0eb"},
+	}
+
+	m, _, err := imageType.Manifest(bp, options, nil, 0)
+	require.NoError(t, err)
+
+	packageSets := make(map[string][]rpmmd.PackageSpec)
+	for _, plName := range append(imageType.BuildPipelines(), imageType.PayloadPipelines()...) {
+		packageSets[plName] = minimalPackageSet
+	}
+	mf, err := m.Serialize(packageSets, nil, nil)
+	require.NoError(t, err)
+	return mf
+}
+
+// OSTreeCommitImageTypes returns the image types of the architecture that
+// build an ostree commit.
+func OSTreeCommitImageTypes(t *testing.T, arch distro.Arch) []distro.ImageType {
+	var imageTypes []distro.ImageType
+	for _, name := range arch.ListImageTypes() {
+		imageType, err := arch.GetImageType(name)
+		require.NoError(t, err)
+		if isOSTree(imageType) && strings.HasSuffix(name, "-commit") {
+			imageTypes = append(imageTypes, imageType)
+		}
+	}
+	return imageTypes
+}
@@ -148,6 +148,20 @@ var (
 		exports:          []string{"container"},
 	}
 
+	bootcImgType = imageType{
+		name:     "bootc",
+		filename: "container.tar",
+		mimeType: "application/x-tar",
+		packageSets: map[string]packageSetFunc{
+			osPkgsKey: bootcPackageSet,
+		},
+		rpmOstree:        true,
+		image:            bootcImage,
+		buildPipelines:   []string{"build"},
+		payloadPipelines: []string{"os", "ostree-commit", "ostree-encapsulate"},
+		exports:          []string{"ostree-encapsulate"},
+	}
+
 	iotInstallerImgType = imageType{
 		name:        "iot-installer",
 		nameAliases: []string{"fedora-iot-installer"},
@@ -299,6 +313,46 @@ var (
 		basePartitionTables: defaultBasePartitionTables,
 	}
 
+	vagrantLibvirtImgType = imageType{
+		name:     "vagrant-libvirt",
+		filename: "vagrant-libvirt.box",
+		mimeType: "application/x-tar",
+		packageSets: map[string]packageSetFunc{
+			osPkgsKey: vagrantLibvirtPackageSet,
+		},
+		defaultImageConfig: &distro.ImageConfig{
+			DefaultTarget: common.ToPtr("multi-user.target"),
+		},
+		kernelOptions:       cloudKernelOptions,
+		bootable:            true,
+		defaultSize:         5 * common.GibiByte,
+		image:               diskImage,
+		buildPipelines:      []string{"build"},
+		payloadPipelines:    []string{"os", "image", "qcow2", "vagrant", "archive"},
+		exports:             []string{"archive"},
+		basePartitionTables: defaultBasePartitionTables,
+	}
+
+	vagrantVirtualBoxImgType = imageType{
+		name:     "vagrant-virtualbox",
+		filename: "vagrant-virtualbox.box",
+		mimeType: "application/x-tar",
+		packageSets: map[string]packageSetFunc{
+			osPkgsKey: vagrantVirtualBoxPackageSet,
+		},
+		defaultImageConfig: &distro.ImageConfig{
+			DefaultTarget: common.ToPtr("multi-user.target"),
+		},
+		kernelOptions:       cloudKernelOptions,
+		bootable:            true,
+		defaultSize:         5 * common.GibiByte,
+		image:               diskImage,
+		buildPipelines:      []string{"build"},
+		payloadPipelines:    []string{"os", "image", "vmdk", "vagrant", "archive"},
+		exports:             []string{"archive"},
+		basePartitionTables: defaultBasePartitionTables,
+	}
+
 	containerImgType = imageType{
 		name:     "container",
 		filename: "container.tar",
@@ -513,6 +567,12 @@ func (a *architecture) Distro() distro.Distro {
 	return a.distro
 }
 
+// kernelVariants returns the variants of the default kernel that the
+// repositories of the distro provide for the architecture.
+func (a *architecture) kernelVariants() []string {
+	return []string{"kernel-debug"}
+}
+
 // New creates a new distro object, defining the supported architectures and image types
 func NewF37() distro.Distro {
 	return newDistro(37)
@@ -636,6 +696,27 @@ func newDistro(version int) distro.Distro {
 		},
 		ovaImgType,
 	)
+	x86_64.addImageTypes(
+		&platform.X86{
+			BIOS:       true,
+			UEFIVendor: "fedora",
+			BasePlatform: platform.BasePlatform{
+				ImageFormat: platform.FORMAT_VAGRANT_LIBVIRT,
+				QCOW2Compat: "1.1",
+			},
+		},
+		vagrantLibvirtImgType,
+	)
+	x86_64.addImageTypes(
+		&platform.X86{
+			BIOS:       true,
+			UEFIVendor: "fedora",
+			BasePlatform: platform.BasePlatform{
+				ImageFormat: platform.FORMAT_VAGRANT_VIRTUALBOX,
+			},
+		},
+		vagrantVirtualBoxImgType,
+	)
 	x86_64.addImageTypes(
 		&platform.X86{
 			BIOS:       true,
@@ -667,6 +748,7 @@ func newDistro(version int) distro.Distro {
 		iotOCIImgType,
 		iotCommitImgType,
 		iotInstallerImgType,
+		bootcImgType,
 		imageInstallerImgType,
 		liveInstallerImgType,
 	)
@@ -742,6 +824,7 @@ func newDistro(version int) distro.Distro {
 		iotCommitImgType,
 		iotInstallerImgType,
 		iotOCIImgType,
+		bootcImgType,
 		liveInstallerImgType,
 	)
 	aarch64.addImageTypes(
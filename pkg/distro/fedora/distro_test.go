@@ -1,6 +1,7 @@
 package fedora_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
@@ -8,10 +9,13 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/distro_test_common"
 	"github.com/osbuild/images/pkg/distro/fedora"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
 )
 
 type fedoraFamilyDistro struct {
@@ -101,6 +105,22 @@ func TestFilenameFromType(t *testing.T) {
 				mimeType: "application/ovf",
 			},
 		},
+		{
+			name: "vagrant-libvirt",
+			args: args{"vagrant-libvirt"},
+			want: wantResult{
+				filename: "vagrant-libvirt.box",
+				mimeType: "application/x-tar",
+			},
+		},
+		{
+			name: "vagrant-virtualbox",
+			args: args{"vagrant-virtualbox"},
+			want: wantResult{
+				filename: "vagrant-virtualbox.box",
+				mimeType: "application/x-tar",
+			},
+		},
 		{
 			name: "container",
 			args: args{"container"},
@@ -149,6 +169,14 @@ func TestFilenameFromType(t *testing.T) {
 				mimeType: "application/x-tar",
 			},
 		},
+		{
+			name: "bootc",
+			args: args{"bootc"},
+			want: wantResult{
+				filename: "container.tar",
+				mimeType: "application/x-tar",
+			},
+		},
 		{
 			name: "iot-installer",
 			args: args{"iot-installer"},
@@ -326,6 +354,7 @@ func TestImageType_Name(t *testing.T) {
 			arch: "x86_64",
 			imgNames: []string{
 				"ami",
+				"bootc",
 				"image-installer",
 				"iot-commit",
 				"iot-container",
@@ -338,6 +367,8 @@ func TestImageType_Name(t *testing.T) {
 				"openstack",
 				"ova",
 				"qcow2",
+				"vagrant-libvirt",
+				"vagrant-virtualbox",
 				"vhd",
 				"vmdk",
 				"wsl",
@@ -352,6 +383,7 @@ func TestImageType_Name(t *testing.T) {
 			arch: "aarch64",
 			imgNames: []string{
 				"ami",
+				"bootc",
 				"image-installer",
 				"iot-commit",
 				"iot-container",
@@ -483,19 +515,16 @@ func TestDistro_ManifestError(t *testing.T) {
 		for _, imgTypeName := range arch.ListImageTypes() {
 			t.Run(fmt.Sprintf("%s/%s", archName, imgTypeName), func(t *testing.T) {
 				imgType, _ := arch.GetImageType(imgTypeName)
-				imgOpts := distro.ImageOptions{
-					Size: imgType.Size(0),
-				}
-				_, _, err := imgType.Manifest(&bp, imgOpts, nil, 0)
+				_, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
 				if imgTypeName == "iot-commit" || imgTypeName == "iot-container" {
 					assert.EqualError(t, err, "kernel boot parameter customizations are not supported for ostree types")
 				} else if imgTypeName == "iot-installer" || imgTypeName == "iot-simplified-installer" {
 					assert.EqualError(t, err, fmt.Sprintf("boot ISO image type \"%s\" requires specifying a URL from which to retrieve the OSTree commit", imgTypeName))
 				} else if imgTypeName == "image-installer" {
-					assert.EqualError(t, err, fmt.Sprintf("unsupported blueprint customizations found for boot ISO image type \"%s\": (allowed: User, Group)", imgTypeName))
+					assert.EqualError(t, err, fmt.Sprintf("unsupported blueprint customizations found for boot ISO image type \"%s\": (allowed: User, Group, Installer, Locale, Timezone)", imgTypeName))
 				} else if imgTypeName == "live-installer" {
 					assert.EqualError(t, err, fmt.Sprintf("unsupported blueprint customizations found for boot ISO image type \"%s\": (allowed: None)", imgTypeName))
-				} else if imgTypeName == "iot-raw-image" || imgTypeName == "iot-qcow2-image" {
+				} else if imgTypeName == "iot-raw-image" || imgTypeName == "iot-qcow2-image" || imgTypeName == "bootc" {
 					assert.EqualError(t, err, fmt.Sprintf("unsupported blueprint customizations found for image type %q: (allowed: User, Group, Directories, Files, Services)", imgTypeName))
 				} else {
 					assert.NoError(t, err)
@@ -515,6 +544,7 @@ func TestArchitecture_ListImageTypes(t *testing.T) {
 			arch: "x86_64",
 			imgNames: []string{
 				"ami",
+				"bootc",
 				"container",
 				"image-installer",
 				"iot-commit",
@@ -528,6 +558,8 @@ func TestArchitecture_ListImageTypes(t *testing.T) {
 				"openstack",
 				"ova",
 				"qcow2",
+				"vagrant-libvirt",
+				"vagrant-virtualbox",
 				"vhd",
 				"vmdk",
 				"wsl",
@@ -542,6 +574,7 @@ func TestArchitecture_ListImageTypes(t *testing.T) {
 			arch: "aarch64",
 			imgNames: []string{
 				"ami",
+				"bootc",
 				"container",
 				"image-installer",
 				"iot-commit",
@@ -671,7 +704,7 @@ func TestDistro_CustomFileSystemManifestError(t *testing.T) {
 			_, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
 			if imgTypeName == "iot-commit" || imgTypeName == "iot-container" {
 				assert.EqualError(t, err, "Custom mountpoints are not supported for ostree types")
-			} else if imgTypeName == "iot-raw-image" || imgTypeName == "iot-qcow2-image" {
+			} else if imgTypeName == "iot-raw-image" || imgTypeName == "iot-qcow2-image" || imgTypeName == "bootc" {
 				assert.EqualError(t, err, fmt.Sprintf("unsupported blueprint customizations found for image type %q: (allowed: User, Group, Directories, Files, Services)", imgTypeName))
 			} else if imgTypeName == "iot-installer" || imgTypeName == "iot-simplified-installer" || imgTypeName == "image-installer" {
 				continue
@@ -703,7 +736,7 @@ func TestDistro_TestRootMountPoint(t *testing.T) {
 			_, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
 			if imgTypeName == "iot-commit" || imgTypeName == "iot-container" {
 				assert.EqualError(t, err, "Custom mountpoints are not supported for ostree types")
-			} else if imgTypeName == "iot-raw-image" || imgTypeName == "iot-qcow2-image" {
+			} else if imgTypeName == "iot-raw-image" || imgTypeName == "iot-qcow2-image" || imgTypeName == "bootc" {
 				assert.EqualError(t, err, fmt.Sprintf("unsupported blueprint customizations found for image type %q: (allowed: User, Group, Directories, Files, Services)", imgTypeName))
 			} else if imgTypeName == "iot-installer" || imgTypeName == "iot-simplified-installer" || imgTypeName == "image-installer" {
 				continue
@@ -737,7 +770,7 @@ func TestDistro_CustomFileSystemSubDirectories(t *testing.T) {
 		for _, imgTypeName := range arch.ListImageTypes() {
 			imgType, _ := arch.GetImageType(imgTypeName)
 			_, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
-			if strings.HasPrefix(imgTypeName, "iot-") || strings.HasPrefix(imgTypeName, "image-") {
+			if strings.HasPrefix(imgTypeName, "iot-") || strings.HasPrefix(imgTypeName, "image-") || imgTypeName == "bootc" {
 				continue
 			} else if imgTypeName == "live-installer" {
 				assert.EqualError(t, err, fmt.Sprintf("unsupported blueprint customizations found for boot ISO image type \"%s\": (allowed: None)", imgTypeName))
@@ -777,7 +810,7 @@ func TestDistro_MountpointsWithArbitraryDepthAllowed(t *testing.T) {
 		for _, imgTypeName := range arch.ListImageTypes() {
 			imgType, _ := arch.GetImageType(imgTypeName)
 			_, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
-			if strings.HasPrefix(imgTypeName, "iot-") || strings.HasPrefix(imgTypeName, "image-") {
+			if strings.HasPrefix(imgTypeName, "iot-") || strings.HasPrefix(imgTypeName, "image-") || imgTypeName == "bootc" {
 				continue
 			} else if imgTypeName == "live-installer" {
 				assert.EqualError(t, err, fmt.Sprintf("unsupported blueprint customizations found for boot ISO image type \"%s\": (allowed: None)", imgTypeName))
@@ -813,7 +846,7 @@ func TestDistro_DirtyMountpointsNotAllowed(t *testing.T) {
 		for _, imgTypeName := range arch.ListImageTypes() {
 			imgType, _ := arch.GetImageType(imgTypeName)
 			_, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
-			if strings.HasPrefix(imgTypeName, "iot-") || strings.HasPrefix(imgTypeName, "image-") {
+			if strings.HasPrefix(imgTypeName, "iot-") || strings.HasPrefix(imgTypeName, "image-") || imgTypeName == "bootc" {
 				continue
 			} else if imgTypeName == "live-installer" {
 				assert.EqualError(t, err, fmt.Sprintf("unsupported blueprint customizations found for boot ISO image type \"%s\": (allowed: None)", imgTypeName))
@@ -824,6 +857,31 @@ func TestDistro_DirtyMountpointsNotAllowed(t *testing.T) {
 	}
 }
 
+func TestDistro_ConflictingMountpointsNotAllowed(t *testing.T) {
+	fedoraDistro := fedora.NewF37()
+	testCases := map[string][]blueprint.FilesystemCustomization{
+		`duplicate mountpoint "/var"`: {
+			{MinSize: 1024, Mountpoint: "/var"},
+			{MinSize: 2048, Mountpoint: "/var"},
+		},
+		`mountpoint "/var/log" (2048 bytes) is larger than its parent mountpoint "/var" (1024 bytes)`: {
+			{MinSize: 1024, Mountpoint: "/var"},
+			{MinSize: 2048, Mountpoint: "/var/log"},
+		},
+	}
+	for wantErr, mountpoints := range testCases {
+		bp := blueprint.Blueprint{
+			Customizations: &blueprint.Customizations{
+				Filesystem: mountpoints,
+			},
+		}
+		arch, _ := fedoraDistro.GetArch("x86_64")
+		imgType, _ := arch.GetImageType("qcow2")
+		_, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+		assert.EqualError(t, err, wantErr)
+	}
+}
+
 func TestDistro_CustomUsrPartitionNotLargeEnough(t *testing.T) {
 	fedoraDistro := fedora.NewF37()
 	bp := blueprint.Blueprint{
@@ -843,7 +901,7 @@ func TestDistro_CustomUsrPartitionNotLargeEnough(t *testing.T) {
 			_, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
 			if imgTypeName == "iot-commit" || imgTypeName == "iot-container" {
 				assert.EqualError(t, err, "Custom mountpoints are not supported for ostree types")
-			} else if imgTypeName == "iot-raw-image" || imgTypeName == "iot-qcow2-image" {
+			} else if imgTypeName == "iot-raw-image" || imgTypeName == "iot-qcow2-image" || imgTypeName == "bootc" {
 				assert.EqualError(t, err, fmt.Sprintf("unsupported blueprint customizations found for image type %q: (allowed: User, Group, Directories, Files, Services)", imgTypeName))
 			} else if imgTypeName == "iot-installer" || imgTypeName == "iot-simplified-installer" || imgTypeName == "image-installer" {
 				continue
@@ -855,3 +913,437 @@ func TestDistro_CustomUsrPartitionNotLargeEnough(t *testing.T) {
 		}
 	}
 }
+
+func TestDistro_ImageSizeSmallerThanMinimum(t *testing.T) {
+	fedoraDistro := fedora.NewF37()
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Filesystem: []blueprint.FilesystemCustomization{
+				{
+					MinSize:    5 * common.GibiByte,
+					Mountpoint: "/var",
+				},
+			},
+		},
+	}
+	for _, archName := range fedoraDistro.ListArches() {
+		arch, _ := fedoraDistro.GetArch(archName)
+		for _, imgTypeName := range arch.ListImageTypes() {
+			imgType, _ := arch.GetImageType(imgTypeName)
+			// ostree and bootc types don't support custom mountpoints
+			if strings.HasPrefix(imgTypeName, "iot-") || imgTypeName == "bootc" {
+				continue
+			}
+			minSize, err := imgType.MinimumSize(&bp)
+			require.NoError(t, err)
+			if minSize == 0 {
+				continue
+			}
+			t.Run(fmt.Sprintf("%s/%s", archName, imgTypeName), func(t *testing.T) {
+				assert.Greater(t, minSize, uint64(5*common.GibiByte))
+
+				_, _, err := imgType.Manifest(&bp, distro.ImageOptions{Size: minSize}, nil, 0)
+				assert.NoError(t, err)
+
+				undersized := minSize - common.MebiByte
+				wantErr := fmt.Sprintf("requested size %d is smaller than the minimum size %d for the blueprint", undersized, minSize)
+				_, _, err = imgType.Manifest(&bp, distro.ImageOptions{Size: undersized}, nil, 0)
+				assert.EqualError(t, err, wantErr)
+				errs := imgType.Validate(&bp, distro.ImageOptions{Size: undersized})
+				require.Len(t, errs, 1)
+				assert.EqualError(t, errs[0], wantErr)
+			})
+		}
+	}
+}
+
+func TestFedora_DNFAutomaticCustomization(t *testing.T) {
+	for _, dist := range fedoraFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_DNFAutomaticCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestFedora_IgnitionCustomization(t *testing.T) {
+	for _, dist := range fedoraFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_IgnitionCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestFedora_MinimizeCustomization(t *testing.T) {
+	for _, dist := range fedoraFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_MinimizeCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestFedora_ModuleCustomization(t *testing.T) {
+	for _, dist := range fedoraFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_ModuleCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestFedora_FIPSCustomization(t *testing.T) {
+	for _, dist := range fedoraFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_FIPSCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestFedora_KernelVariantCustomization(t *testing.T) {
+	for _, dist := range fedoraFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_KernelVariantCustomization(t, dist.distro, "kernel-debug")
+
+			// fedora has no real-time kernel
+			arch, err := dist.distro.GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+			rt := blueprint.Blueprint{Customizations: &blueprint.Customizations{Kernel: &blueprint.KernelCustomization{Name: "kernel-rt"}}}
+			_, _, err = imageType.Manifest(&rt, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, fmt.Sprintf(`kernel variant "kernel-rt" is not available for %s on x86_64`, dist.distro.Name()))
+		})
+	}
+}
+
+// Ensure that the installer settings end up in the kickstart file of the
+// fedora image installer and that the user kickstart includes it
+func TestInstallerCustomizationKickstart(t *testing.T) {
+	snippet := "%post\necho provisioned > /etc/provisioned\n%end\n"
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Installer: &blueprint.InstallerCustomization{
+				TargetDisk: "sda",
+				Reboot:     true,
+				Kickstart:  &blueprint.KickstartCustomization{Contents: snippet},
+			},
+		},
+	}
+
+	for _, dist := range fedoraFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			arch, err := dist.distro.GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("image-installer")
+			require.NoError(t, err)
+			pm := distro_test_common.SerializeManifest(t, imageType, &bp, distro.ImageOptions{})
+
+			kickstart := pm.StageOptions("bootiso-tree", "org.osbuild.kickstart")
+			require.Len(t, kickstart, 1)
+			assert.Contains(t, kickstart[0], `"path":"/osbuild-base.ks"`)
+			assert.Contains(t, kickstart[0], `"zerombr":true`)
+			assert.Contains(t, kickstart[0], `"clearpart":{"drives":["sda"],"initlabel":true}`)
+			assert.Contains(t, kickstart[0], `"ignoredisk":{"only-use":["sda"]}`)
+			assert.Contains(t, kickstart[0], `"reboot":{"eject":true}`)
+
+			assert.Contains(t, strings.Join(pm.StageOptions("bootiso-tree", "org.osbuild.copy"), ""), `"to":"tree:///osbuild.ks"`)
+			assert.Contains(t, pm.InlineData(t), "%include /run/install/repo/osbuild-base.ks\n"+snippet)
+		})
+	}
+}
+
+func TestInstallerCustomizationKickstartConflict(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Installer: &blueprint.InstallerCustomization{
+				TargetDisk: "sda",
+				Kickstart:  &blueprint.KickstartCustomization{Contents: "clearpart --all\n"},
+			},
+		},
+	}
+
+	for _, dist := range fedoraFamilyDistros {
+		arch, err := dist.distro.GetArch("x86_64")
+		require.NoError(t, err)
+		imageType, err := arch.GetImageType("image-installer")
+		require.NoError(t, err)
+		_, _, err = imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+		assert.EqualError(t, err, `installer kickstart command "clearpart" conflicts with the generated kickstart`, dist.distro.Name())
+	}
+}
+
+// Ensure that the language, keyboard and timezone of the installer and the
+// unattended mode end up in the kickstart file and that customizations that
+// the installers don't support are still rejected
+func TestInstallerCustomizationLocaleTimezone(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Locale: &blueprint.LocaleCustomization{
+				Languages: []string{"de_DE.UTF-8", "en_US.UTF-8"},
+				Keyboard:  common.ToPtr("de"),
+			},
+			Timezone: &blueprint.TimezoneCustomization{Timezone: common.ToPtr("Europe/Berlin")},
+		},
+	}
+	unattended := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Installer: &blueprint.InstallerCustomization{TargetDisk: "sda", Unattended: true},
+		},
+	}
+
+	for _, dist := range fedoraFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			arch, err := dist.distro.GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("image-installer")
+			require.NoError(t, err)
+
+			pm := distro_test_common.SerializeManifest(t, imageType, &bp, distro.ImageOptions{})
+			kickstart := pm.StageOptions("bootiso-tree", "org.osbuild.kickstart")
+			require.Len(t, kickstart, 1)
+			assert.Contains(t, kickstart[0], `"path":"/osbuild.ks"`)
+			assert.Contains(t, kickstart[0], `"lang":"de_DE.UTF-8"`)
+			assert.Contains(t, kickstart[0], `"keyboard":"de"`)
+			assert.Contains(t, kickstart[0], `"timezone":"Europe/Berlin"`)
+			assert.NotContains(t, kickstart[0], `"display_mode"`)
+
+			pm = distro_test_common.SerializeManifest(t, imageType, &unattended, distro.ImageOptions{})
+			kickstart = pm.StageOptions("bootiso-tree", "org.osbuild.kickstart")
+			require.Len(t, kickstart, 1)
+			assert.Contains(t, kickstart[0], `"display_mode":"cmdline"`)
+			assert.Contains(t, kickstart[0], `"lang":"en_US.UTF-8"`)
+			assert.Contains(t, kickstart[0], `"keyboard":"us"`)
+			assert.Contains(t, kickstart[0], `"timezone":"UTC"`)
+
+			disallowed := blueprint.Blueprint{
+				Customizations: &blueprint.Customizations{
+					Timezone: &blueprint.TimezoneCustomization{Timezone: common.ToPtr("UTC")},
+					Hostname: common.ToPtr("installer"),
+				},
+			}
+			_, _, err = imageType.Manifest(&disallowed, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, `unsupported blueprint customizations found for boot ISO image type "image-installer": (allowed: User, Group, Installer, Locale, Timezone)`)
+
+			ntp := blueprint.Blueprint{
+				Customizations: &blueprint.Customizations{
+					Timezone: &blueprint.TimezoneCustomization{NTPServers: []string{"ntp.example.com"}},
+				},
+			}
+			_, _, err = imageType.Manifest(&ntp, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, `timezone ntp servers are not supported for boot ISO image type "image-installer"`)
+		})
+	}
+}
+
+// Ensure that the extra initrd modules and drivers of the installer end up in
+// the dracut stage of the installer and are rejected for other image types
+func TestInstallerCustomizationInitrdModules(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Installer: &blueprint.InstallerCustomization{
+				InitrdModules: []string{"nvdimm"},
+				InitrdDrivers: []string{"bnx2x", "mpt3sas"},
+			},
+		},
+	}
+
+	for _, dist := range fedoraFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			arch, err := dist.distro.GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("image-installer")
+			require.NoError(t, err)
+			pm := distro_test_common.SerializeManifest(t, imageType, &bp, distro.ImageOptions{})
+
+			dracut := pm.StageOptions("anaconda-tree", "org.osbuild.dracut")
+			require.Len(t, dracut, 1)
+			var options osbuild.DracutStageOptions
+			require.NoError(t, json.Unmarshal([]byte(dracut[0]), &options))
+			assert.Contains(t, options.Modules, "nvdimm")
+			assert.Contains(t, options.Modules, "anaconda")
+			assert.Equal(t, []string{"bnx2x", "mpt3sas"}, options.AddDrivers)
+
+			qcow2, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+			_, _, err = qcow2.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, `installer initrd modules and drivers are not supported for image type "qcow2"`)
+
+			invalid := blueprint.Blueprint{
+				Customizations: &blueprint.Customizations{
+					Installer: &blueprint.InstallerCustomization{InitrdModules: []string{"nfs kernel"}},
+				},
+			}
+			_, _, err = imageType.Manifest(&invalid, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, `installer initrd module "nfs kernel" is not a valid dracut module name`)
+		})
+	}
+}
+
+func TestSystemdBootCustomization(t *testing.T) {
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Bootloader: blueprint.BootloaderSystemdBoot,
+		},
+	}
+
+	packages := func(m *manifest.Manifest, pipeline string) []string {
+		var include []string
+		for _, ps := range m.GetPackageSetChains()[pipeline] {
+			include = append(include, ps.Include...)
+		}
+		return include
+	}
+
+	for _, dist := range fedoraFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			d := dist.distro
+			arch, err := d.GetArch("aarch64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			grub2, _, err := imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{}, nil, 0)
+			require.NoError(t, err)
+			m, _, err := imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			require.NoError(t, err)
+
+			assert.Contains(t, packages(grub2, "os"), "grub2-efi-aa64")
+			assert.NotContains(t, packages(m, "os"), "grub2-efi-aa64")
+			assert.NotContains(t, packages(m, "os"), "shim-aa64")
+			assert.Contains(t, packages(m, "os"), "systemd-boot-unsigned")
+
+			pm := distro_test_common.SerializeManifest(t, imageType, &bp, distro.ImageOptions{})
+			assert.Empty(t, pm.OSStageOptions("org.osbuild.grub2"))
+
+			// kernel-install puts the kernels and their entries in /boot,
+			// which is the ESP: there is no separate /boot filesystem
+			assert.Contains(t, pm.InlineData(t), "layout=bls\nBOOT_ROOT=/boot\n")
+			assert.Equal(t, []string{`{"prefix":""}`}, pm.OSStageOptions("org.osbuild.fix-bls"))
+			fstab := strings.Join(pm.OSStageOptions("org.osbuild.fstab"), "")
+			assert.Contains(t, fstab, `"vfs_type":"vfat","path":"/boot",`)
+			assert.NotContains(t, fstab, `"path":"/boot/efi"`)
+			assert.Equal(t, 1, strings.Count(fstab, `"path":"/boot"`))
+
+			// the image pipeline copies the tree, /boot included, to the
+			// ESP and installs systemd-boot as its default boot loader
+			var image struct {
+				Pipelines []struct {
+					Name   string `json:"name"`
+					Stages []struct {
+						Type    string                   `json:"type"`
+						Options osbuild.CopyStageOptions `json:"options"`
+						Mounts  []osbuild.Mount          `json:"mounts"`
+					} `json:"stages"`
+				} `json:"pipelines"`
+			}
+			require.NoError(t, json.Unmarshal(distro_test_common.SerializeOSBuildManifest(t, imageType, &bp, distro.ImageOptions{}), &image))
+			var copyStages int
+			for _, pl := range image.Pipelines {
+				if pl.Name != "image" {
+					continue
+				}
+				for _, stage := range pl.Stages {
+					if stage.Type != "org.osbuild.copy" {
+						continue
+					}
+					copyStages++
+					assert.Contains(t, stage.Mounts, osbuild.Mount{Name: "boot", Type: "org.osbuild.fat", Source: "boot", Target: "/boot"})
+					for _, mount := range stage.Mounts {
+						assert.NotEqual(t, "/boot/efi", mount.Target)
+					}
+					assert.Equal(t, []osbuild.CopyStagePath{
+						{From: "input://root-tree/", To: "mount://-/"},
+						{From: "input://root-tree/usr/lib/systemd/boot/efi/systemd-bootaa64.efi", To: "mount://boot/EFI/BOOT/BOOTAA64.EFI"},
+					}, stage.Options.Paths)
+				}
+			}
+			assert.Equal(t, 1, copyStages)
+
+			// BIOS and hybrid boot require grub2
+			arch, err = d.GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err = arch.GetImageType("qcow2")
+			require.NoError(t, err)
+			_, _, err = imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, `systemd-boot requires UEFI boot, image type "qcow2" uses hybrid boot`)
+
+			// /boot is the ESP
+			arch, err = d.GetArch("aarch64")
+			require.NoError(t, err)
+			imageType, err = arch.GetImageType("qcow2")
+			require.NoError(t, err)
+			bp := blueprint.Blueprint{Customizations: &blueprint.Customizations{
+				Bootloader: blueprint.BootloaderSystemdBoot,
+				Filesystem: []blueprint.FilesystemCustomization{{Mountpoint: "/boot", MinSize: common.GibiByte}},
+			}}
+			_, _, err = imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, "systemd-boot installs /boot on the EFI system partition, it cannot be a custom filesystem")
+
+			bp = blueprint.Blueprint{Customizations: &blueprint.Customizations{Bootloader: blueprint.BootloaderGrub2}}
+			_, _, err = imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			assert.NoError(t, err)
+			bp.Customizations.Bootloader = "lilo"
+			_, _, err = imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, `unsupported bootloader "lilo" (supported: grub2, systemd-boot)`)
+		})
+	}
+}
+
+// Ensure that the vagrant boxes are tarballs with the disk image, the
+// metadata.json and the Vagrantfile of the provider at the top level
+func TestVagrantBoxes(t *testing.T) {
+	arch, err := fedora.NewF39().GetArch("x86_64")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		imageType   string
+		disk        string
+		diskFrom    string
+		metadata    string
+		vagrantfile string
+		ovf         []string
+	}{
+		{
+			imageType:   "vagrant-libvirt",
+			disk:        "box.img",
+			diskFrom:    "input://image-tree/image.qcow2",
+			metadata:    `{"provider":"libvirt","format":"qcow2","virtual_size":5}` + "\n",
+			vagrantfile: "libvirt.driver = \"kvm\"",
+		},
+		{
+			imageType:   "vagrant-virtualbox",
+			disk:        "box.vmdk",
+			diskFrom:    "input://image-tree/image.vmdk",
+			metadata:    `{"provider":"virtualbox"}` + "\n",
+			vagrantfile: "config.vm.provider :virtualbox",
+			ovf:         []string{`{"vmdk":"box.vmdk"}`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.imageType, func(t *testing.T) {
+			imageType, err := arch.GetImageType(tc.imageType)
+			require.NoError(t, err)
+			assert.Equal(t, tc.imageType+".box", imageType.Filename())
+			assert.Equal(t, "application/x-tar", imageType.MIMEType())
+			assert.Equal(t, []string{"archive"}, imageType.Exports())
+
+			pm := distro_test_common.SerializeManifest(t, imageType, &blueprint.Blueprint{}, distro.ImageOptions{})
+			copies := strings.Join(pm.StageOptions("vagrant", "org.osbuild.copy"), "")
+			assert.Contains(t, copies, fmt.Sprintf(`"from":"%s","to":"tree:///%s"`, tc.diskFrom, tc.disk))
+			assert.Contains(t, copies, `"to":"tree:///metadata.json"`)
+			assert.Contains(t, copies, `"to":"tree:///Vagrantfile"`)
+			assert.Equal(t, tc.ovf, pm.StageOptions("vagrant", "org.osbuild.ovf"))
+
+			inline := pm.InlineData(t)
+			assert.Contains(t, inline, tc.metadata)
+			var vagrantfile string
+			for _, data := range inline {
+				if strings.HasPrefix(data, "Vagrant.configure(\"2\")") {
+					vagrantfile = data
+				}
+			}
+			assert.Contains(t, vagrantfile, tc.vagrantfile)
+
+			assert.Equal(t, []string{fmt.Sprintf(`{"filename":"%s.box","root-node":"omit"}`, tc.imageType)}, pm.StageOptions("archive", "org.osbuild.tar"))
+		})
+	}
+}
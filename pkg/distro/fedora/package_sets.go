@@ -37,6 +37,19 @@ func qcow2CommonPackageSet(t *imageType) rpmmd.PackageSet {
 		})
 }
 
+func vagrantLibvirtPackageSet(t *imageType) rpmmd.PackageSet {
+	return qcow2CommonPackageSet(t)
+}
+
+func vagrantVirtualBoxPackageSet(t *imageType) rpmmd.PackageSet {
+	return cloudBaseSet(t).Append(
+		rpmmd.PackageSet{
+			Include: []string{
+				"virtualbox-guest-additions",
+			},
+		})
+}
+
 func vhdCommonPackageSet(t *imageType) rpmmd.PackageSet {
 	return cloudBaseSet(t).Append(
 		rpmmd.PackageSet{
@@ -220,6 +233,18 @@ func installerPackageSet(t *imageType) rpmmd.PackageSet {
 	}
 }
 
+// bootc images are ostree commits with the tools to install and update
+// systems from the container
+func bootcPackageSet(t *imageType) rpmmd.PackageSet {
+	return iotCommitPackageSet(t).Append(rpmmd.PackageSet{
+		Include: []string{
+			"bootc",
+			"rpm-ostree",
+			"skopeo",
+		},
+	})
+}
+
 func anacondaPackageSet(t *imageType) rpmmd.PackageSet {
 
 	// common installer packages
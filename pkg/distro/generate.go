@@ -0,0 +1,70 @@
+package distro
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// Spec holds the arguments of a single ImageType.Manifest() call.
+type Spec struct {
+	ImageType ImageType
+	Blueprint *blueprint.Blueprint
+	Options   ImageOptions
+	Repos     []rpmmd.RepoConfig
+	Seed      int64
+}
+
+// Result holds the return values of the ImageType.Manifest() call of the
+// Spec with the same index.
+type Result struct {
+	Manifest *manifest.Manifest
+	Warnings []string
+	Err      error
+}
+
+// GenerateManifests generates the manifests for all the specs concurrently,
+// running at most GOMAXPROCS generations at a time. Image types, and the
+// distributions they belong to, are only read while generating manifests, so
+// specs can share them.
+//
+// The results are in the order of the specs. The returned error is the error
+// of the first failed spec; the results of all the specs, including the
+// failed ones, are returned regardless.
+func GenerateManifests(specs []Spec) ([]Result, error) {
+	results := make([]Result, len(specs))
+
+	// buffered channel used as a semaphore to bound the number of workers
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for idx := range specs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			spec := specs[idx]
+			bp := spec.Blueprint
+			if bp == nil {
+				bp = &blueprint.Blueprint{}
+			}
+			m, warnings, err := spec.ImageType.Manifest(bp, spec.Options, spec.Repos, spec.Seed)
+			results[idx] = Result{Manifest: m, Warnings: warnings, Err: err}
+		}(idx)
+	}
+	wg.Wait()
+
+	for idx, res := range results {
+		if res.Err != nil {
+			it := specs[idx].ImageType
+			return results, fmt.Errorf("generating manifest %d (%s/%s/%s) failed: %w", idx, it.Arch().Distro().Name(), it.Arch().Name(), it.Name(), res.Err)
+		}
+	}
+	return results, nil
+}
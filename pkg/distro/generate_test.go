@@ -0,0 +1,119 @@
+package distro_test
+
+import (
+	"testing"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distroregistry"
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generateManifestSpecs returns a spec for every image type of every distro on
+// x86_64 that can be built from a blueprint without customizations. All the
+// specs share the same blueprint, like the specs of a build matrix would.
+func generateManifestSpecs(t testing.TB) []distro.Spec {
+	bp := &blueprint.Blueprint{
+		Packages: []blueprint.Package{{Name: "tmux"}},
+	}
+	repos := []rpmmd.RepoConfig{{Name: "base", BaseURLs: []string{"http://example.com/base"}}}
+
+	var specs []distro.Spec
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+		for _, imageTypeName := range arch.ListImageTypes() {
+			imageType, err := arch.GetImageType(imageTypeName)
+			require.NoError(t, err)
+			options := distro.ImageOptions{}
+			if imageType.OSTreeRef() != "" {
+				// some ostree image types require a URL
+				options.OSTree = &ostree.ImageOptions{URL: "https://example.com/ostree"}
+			}
+			// skip the image types that require customizations, like the
+			// installation device of the simplified installers
+			if _, _, err := imageType.Manifest(bp, options, repos, 0); err != nil {
+				continue
+			}
+			specs = append(specs, distro.Spec{
+				ImageType: imageType,
+				Blueprint: bp,
+				Options:   options,
+				Repos:     repos,
+				Seed:      42,
+			})
+		}
+	}
+	return specs
+}
+
+func TestGenerateManifestsMatchesSerial(t *testing.T) {
+	specs := generateManifestSpecs(t)
+
+	results, err := distro.GenerateManifests(specs)
+	require.NoError(t, err)
+	require.Len(t, results, len(specs))
+
+	for idx, spec := range specs {
+		m, warnings, err := spec.ImageType.Manifest(spec.Blueprint, spec.Options, spec.Repos, spec.Seed)
+		require.NoError(t, err)
+
+		name := spec.ImageType.Arch().Distro().Name() + "/" + spec.ImageType.Name()
+		require.NoError(t, results[idx].Err, name)
+		assert.Equal(t, warnings, results[idx].Warnings, name)
+		assert.Equal(t, m.GetPackageSetChains(), results[idx].Manifest.GetPackageSetChains(), name)
+		assert.Equal(t, m.GetContainerSourceSpecs(), results[idx].Manifest.GetContainerSourceSpecs(), name)
+		assert.Equal(t, m.GetOSTreeSourceSpecs(), results[idx].Manifest.GetOSTreeSourceSpecs(), name)
+		assert.Equal(t, m, results[idx].Manifest, name)
+	}
+}
+
+func TestGenerateManifestsError(t *testing.T) {
+	specs := generateManifestSpecs(t)[:3]
+	invalid := &blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Filesystem: []blueprint.FilesystemCustomization{{Mountpoint: "/etc", MinSize: 1024}},
+		},
+	}
+	specs[1].Blueprint = invalid
+	specs[2].Blueprint = invalid
+
+	results, err := distro.GenerateManifests(specs)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "generating manifest 1 ")
+	require.Len(t, results, 3)
+	assert.NoError(t, results[0].Err)
+	assert.NotNil(t, results[0].Manifest)
+	assert.Error(t, results[1].Err)
+	assert.Error(t, results[2].Err)
+}
+
+func TestGenerateManifestsEmpty(t *testing.T) {
+	results, err := distro.GenerateManifests(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func BenchmarkGenerateManifestsSerial(b *testing.B) {
+	specs := generateManifestSpecs(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, spec := range specs {
+			_, _, err := spec.ImageType.Manifest(spec.Blueprint, spec.Options, spec.Repos, spec.Seed)
+			require.NoError(b, err)
+		}
+	}
+}
+
+func BenchmarkGenerateManifestsConcurrent(b *testing.B) {
+	specs := generateManifestSpecs(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := distro.GenerateManifests(specs)
+		require.NoError(b, err)
+	}
+}
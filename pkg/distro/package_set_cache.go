@@ -0,0 +1,143 @@
+package distro
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// PackageSetChainCache memoizes the package set chains of image types. The
+// chains only depend on the image type and the arguments of the Manifest()
+// call, so repeatedly generating manifests for the same blueprint, for
+// example while a user is editing other parts of a request, can reuse them.
+//
+// A PackageSetChainCache is safe for concurrent use. The zero value is not
+// usable; create caches with NewPackageSetChainCache().
+type PackageSetChainCache struct {
+	mu     sync.Mutex
+	chains map[string]map[string][]rpmmd.PackageSet
+}
+
+func NewPackageSetChainCache() *PackageSetChainCache {
+	return &PackageSetChainCache{
+		chains: make(map[string]map[string][]rpmmd.PackageSet),
+	}
+}
+
+// packageSetChainCacheKey identifies the image type by its distro, arch, and
+// name, and all the other inputs of the package set chains by their hash, so
+// that any change to them results in a different key.
+func packageSetChainCacheKey(imageType ImageType, bp *blueprint.Blueprint, options ImageOptions, repos []rpmmd.RepoConfig) (string, error) {
+	inputs, err := json.Marshal(struct {
+		Blueprint *blueprint.Blueprint `json:"blueprint"`
+		Options   ImageOptions         `json:"options"`
+		Repos     []rpmmd.RepoConfig   `json:"repos"`
+	}{bp, options, repos})
+	if err != nil {
+		return "", err
+	}
+	arch := imageType.Arch()
+	return fmt.Sprintf("%s/%s/%s/%x", arch.Distro().Name(), arch.Name(), imageType.Name(), sha256.Sum256(inputs)), nil
+}
+
+// Get returns the package set chains of the manifest for the given image
+// type, blueprint, options, and repositories, computing them on a cache miss.
+// The returned chains, their package lists and their repositories are copies
+// that the caller may modify.
+func (c *PackageSetChainCache) Get(imageType ImageType, bp *blueprint.Blueprint, options ImageOptions, repos []rpmmd.RepoConfig) (map[string][]rpmmd.PackageSet, error) {
+	if bp == nil {
+		bp = &blueprint.Blueprint{}
+	}
+
+	key, err := packageSetChainCacheKey(imageType, bp, options, repos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute the package set chain cache key: %w", err)
+	}
+
+	c.mu.Lock()
+	chains, ok := c.chains[key]
+	c.mu.Unlock()
+	if ok {
+		return copyPackageSetChains(chains), nil
+	}
+
+	// generate the manifest without holding the lock; concurrent misses for
+	// the same key compute identical chains, so the last one stored wins
+	m, _, err := imageType.Manifest(bp, options, repos, 0)
+	if err != nil {
+		return nil, err
+	}
+	chains = m.GetPackageSetChains()
+
+	// the chains share the repositories with the caller, which may modify
+	// them later
+	c.mu.Lock()
+	c.chains[key] = copyPackageSetChains(chains)
+	c.mu.Unlock()
+
+	return copyPackageSetChains(chains), nil
+}
+
+// Len returns the number of cached entries.
+func (c *PackageSetChainCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.chains)
+}
+
+// copyPackageSetChains returns a deep copy of the chains so that callers
+// cannot modify the cached ones.
+func copyPackageSetChains(chains map[string][]rpmmd.PackageSet) map[string][]rpmmd.PackageSet {
+	chainsCopy := make(map[string][]rpmmd.PackageSet, len(chains))
+	for name, chain := range chains {
+		chainCopy := make([]rpmmd.PackageSet, len(chain))
+		for idx, ps := range chain {
+			chainCopy[idx] = rpmmd.PackageSet{
+				Include:         append([]string(nil), ps.Include...),
+				Exclude:         append([]string(nil), ps.Exclude...),
+				Repositories:    copyRepoConfigs(ps.Repositories),
+				InstallWeakDeps: ps.InstallWeakDeps,
+				EnabledModules:  append([]string(nil), ps.EnabledModules...),
+			}
+		}
+		chainsCopy[name] = chainCopy
+	}
+	return chainsCopy
+}
+
+// copyRepoConfigs returns a deep copy of the repositories, including their
+// lists and the values of their pointers.
+func copyRepoConfigs(repos []rpmmd.RepoConfig) []rpmmd.RepoConfig {
+	if repos == nil {
+		return nil
+	}
+	copyBool := func(b *bool) *bool {
+		if b == nil {
+			return nil
+		}
+		v := *b
+		return &v
+	}
+
+	reposCopy := make([]rpmmd.RepoConfig, len(repos))
+	for idx, repo := range repos {
+		repo.BaseURLs = append([]string(nil), repo.BaseURLs...)
+		repo.GPGKeys = append([]string(nil), repo.GPGKeys...)
+		repo.ImageTypeTags = append([]string(nil), repo.ImageTypeTags...)
+		repo.PackageSets = append([]string(nil), repo.PackageSets...)
+		repo.CheckGPG = copyBool(repo.CheckGPG)
+		repo.CheckRepoGPG = copyBool(repo.CheckRepoGPG)
+		repo.IgnoreSSL = copyBool(repo.IgnoreSSL)
+		repo.Enabled = copyBool(repo.Enabled)
+		if repo.Priority != nil {
+			priority := *repo.Priority
+			repo.Priority = &priority
+		}
+		reposCopy[idx] = repo
+	}
+	return reposCopy
+}
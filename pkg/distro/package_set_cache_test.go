@@ -0,0 +1,142 @@
+package distro_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distro/fedora"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingImageType counts the calls to Manifest()
+type countingImageType struct {
+	distro.ImageType
+	calls int32
+}
+
+func (t *countingImageType) Manifest(bp *blueprint.Blueprint, options distro.ImageOptions, repos []rpmmd.RepoConfig, seed int64) (*manifest.Manifest, []string, error) {
+	atomic.AddInt32(&t.calls, 1)
+	return t.ImageType.Manifest(bp, options, repos, seed)
+}
+
+func newCountingImageType(t *testing.T, name string) *countingImageType {
+	arch, err := fedora.NewF38().GetArch("x86_64")
+	require.NoError(t, err)
+	imageType, err := arch.GetImageType(name)
+	require.NoError(t, err)
+	return &countingImageType{ImageType: imageType}
+}
+
+func TestPackageSetChainCacheHit(t *testing.T) {
+	imageType := newCountingImageType(t, "qcow2")
+	cache := distro.NewPackageSetChainCache()
+	bp := &blueprint.Blueprint{Packages: []blueprint.Package{{Name: "tmux"}}}
+	repos := []rpmmd.RepoConfig{{Name: "base", BaseURLs: []string{"http://example.com/base"}, Enabled: common.ToPtr(true)}}
+
+	first, err := cache.Get(imageType, bp, distro.ImageOptions{}, repos)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), imageType.calls)
+
+	// an equal but distinct blueprint hits the cache
+	second, err := cache.Get(imageType, &blueprint.Blueprint{Packages: []blueprint.Package{{Name: "tmux"}}}, distro.ImageOptions{}, repos)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), imageType.calls)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, cache.Len())
+
+	// the cached chains are identical to the uncached ones
+	m, _, err := imageType.ImageType.Manifest(bp, distro.ImageOptions{}, repos, 0)
+	require.NoError(t, err)
+	assert.Equal(t, m.GetPackageSetChains(), second)
+
+	// modifying the returned chains, their repositories or the repositories
+	// of the call does not affect the cache
+	second["os"][0].Include[0] = "modified"
+	second["os"][0].Repositories[0].BaseURLs[0] = "http://example.com/modified"
+	*second["os"][0].Repositories[0].Enabled = false
+	repos[0].BaseURLs[0] = "http://example.com/modified"
+	*repos[0].Enabled = false
+	third, err := cache.Get(imageType, bp, distro.ImageOptions{}, []rpmmd.RepoConfig{{Name: "base", BaseURLs: []string{"http://example.com/base"}, Enabled: common.ToPtr(true)}})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), imageType.calls)
+	assert.Equal(t, first, third)
+	assert.Equal(t, "http://example.com/base", third["os"][0].Repositories[0].BaseURLs[0])
+	assert.True(t, *third["os"][0].Repositories[0].Enabled)
+}
+
+func TestPackageSetChainCacheMiss(t *testing.T) {
+	qcow2 := newCountingImageType(t, "qcow2")
+	ami := newCountingImageType(t, "ami")
+	cache := distro.NewPackageSetChainCache()
+	bp := &blueprint.Blueprint{Packages: []blueprint.Package{{Name: "tmux"}}}
+
+	base, err := cache.Get(qcow2, bp, distro.ImageOptions{}, nil)
+	require.NoError(t, err)
+
+	// changing the blueprint misses the cache
+	changed, err := cache.Get(qcow2, &blueprint.Blueprint{Packages: []blueprint.Package{{Name: "tmux"}, {Name: "vim-enhanced"}}}, distro.ImageOptions{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), qcow2.calls)
+	assert.NotEqual(t, base, changed)
+
+	// so does changing the repositories or the options
+	_, err = cache.Get(qcow2, bp, distro.ImageOptions{}, []rpmmd.RepoConfig{{Name: "extra", BaseURLs: []string{"http://example.com/extra"}}})
+	require.NoError(t, err)
+	_, err = cache.Get(qcow2, bp, distro.ImageOptions{Size: 20 * 1024 * 1024 * 1024}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), qcow2.calls)
+
+	// and using a different image type
+	_, err = cache.Get(ami, bp, distro.ImageOptions{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), ami.calls)
+	assert.Equal(t, 5, cache.Len())
+}
+
+func TestPackageSetChainCacheError(t *testing.T) {
+	imageType := newCountingImageType(t, "qcow2")
+	cache := distro.NewPackageSetChainCache()
+	bp := &blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Filesystem: []blueprint.FilesystemCustomization{{Mountpoint: "/etc", MinSize: 1024}},
+		},
+	}
+
+	_, err := cache.Get(imageType, bp, distro.ImageOptions{}, nil)
+	assert.Error(t, err)
+	// errors are not cached
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestPackageSetChainCacheConcurrent(t *testing.T) {
+	imageType := newCountingImageType(t, "qcow2")
+	cache := distro.NewPackageSetChainCache()
+	bps := []*blueprint.Blueprint{
+		{Packages: []blueprint.Package{{Name: "tmux"}}},
+		{Packages: []blueprint.Package{{Name: "vim-enhanced"}}},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(bp *blueprint.Blueprint) {
+			defer wg.Done()
+			chains, err := cache.Get(imageType, bp, distro.ImageOptions{}, nil)
+			assert.NoError(t, err)
+			var include []string
+			for _, ps := range chains["os"] {
+				include = append(include, ps.Include...)
+			}
+			assert.Contains(t, include, bp.Packages[0].Name)
+		}(bps[i%len(bps)])
+	}
+	wg.Wait()
+	assert.Equal(t, 2, cache.Len())
+}
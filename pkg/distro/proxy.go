@@ -0,0 +1,127 @@
+package distro
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// ProxyOptions route the depsolving of the package sets, i.e. the downloads
+// of the repository metadata by dnf, through an HTTP proxy, e.g. in
+// disconnected corporate networks. The packages are downloaded by osbuild,
+// which uses the proxy of its environment.
+type ProxyOptions struct {
+	// URL of the proxy, e.g. http://proxy.example.com:3128
+	URL string
+	// Hosts that are reached directly, in the format of the NO_PROXY
+	// environment variable: host names that also match their subdomains, with
+	// an optional leading dot, IP addresses, CIDR ranges or "*" for all hosts
+	NoProxy []string
+}
+
+// ProxyOptionsFromEnv returns the proxy of the environment, the first of the
+// HTTPS_PROXY and HTTP_PROXY environment variables (or their lower case
+// versions) that is set, with the exclusions of NO_PROXY, or nil if no proxy
+// is set.
+func ProxyOptionsFromEnv() *ProxyOptions {
+	getenv := func(name string) string {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		return os.Getenv(strings.ToLower(name))
+	}
+
+	proxyURL := getenv("HTTPS_PROXY")
+	if proxyURL == "" {
+		proxyURL = getenv("HTTP_PROXY")
+	}
+	if proxyURL == "" {
+		return nil
+	}
+
+	options := &ProxyOptions{URL: proxyURL}
+	for _, host := range strings.Split(getenv("NO_PROXY"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			options.NoProxy = append(options.NoProxy, host)
+		}
+	}
+	return options
+}
+
+// Validate returns an error if the proxy URL is not an absolute URL with a
+// scheme that dnf supports.
+func (p *ProxyOptions) Validate() error {
+	if p == nil {
+		return nil
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("proxy %q is not a valid URL", p.URL)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks4", "socks4a", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("proxy %q has an unsupported scheme %q", p.URL, u.Scheme)
+	}
+	return nil
+}
+
+// excludes returns true if the host is reached without the proxy.
+func (p *ProxyOptions) excludes(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	addr, addrErr := netip.ParseAddr(host)
+	for _, entry := range p.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "*" {
+			return true
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			if addrErr == nil && prefix.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		entry = strings.TrimSuffix(strings.TrimPrefix(entry, "."), ".")
+		if entry != "" && (host == entry || strings.HasSuffix(host, "."+entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyToRepos returns copies of the repositories that reach their URLs
+// through the proxy, except for the ones whose first URL, the first base URL,
+// the metalink or the mirror list, is on a host of NoProxy. The repositories
+// are returned as they are if the options are nil.
+func (p *ProxyOptions) ApplyToRepos(repos []rpmmd.RepoConfig) []rpmmd.RepoConfig {
+	if p == nil {
+		return repos
+	}
+
+	proxied := make([]rpmmd.RepoConfig, len(repos))
+	for idx, repo := range repos {
+		proxied[idx] = repo
+		var repoURL string
+		switch {
+		case len(repo.BaseURLs) > 0:
+			repoURL = repo.BaseURLs[0]
+		case repo.Metalink != "":
+			repoURL = repo.Metalink
+		default:
+			repoURL = repo.MirrorList
+		}
+		if u, err := url.Parse(repoURL); err == nil && p.excludes(u.Hostname()) {
+			continue
+		}
+		proxied[idx].Proxy = p.URL
+	}
+	return proxied
+}
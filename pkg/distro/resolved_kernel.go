@@ -0,0 +1,57 @@
+package distro
+
+import (
+	"fmt"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"golang.org/x/exp/slices"
+)
+
+// A Depsolver resolves a chain of package sets to the packages to install, e.g.
+// with dnfjson.Solver.Depsolve.
+type Depsolver func(chain []rpmmd.PackageSet) ([]rpmmd.PackageSpec, error)
+
+// ResolvedKernel returns the NEVRA of the kernel package that the image type
+// installs for the given blueprint, e.g. kernel-6.5.6-300.fc39.x86_64, so that
+// the kernel version is known before building. The kernel name of the
+// blueprint, if set, is respected.
+//
+// Only the package set chain of the payload pipeline that installs the kernel
+// is depsolved with the given repositories. An error is returned if the image
+// type has no kernel, e.g. for container images.
+func ResolvedKernel(t ImageType, bp *blueprint.Blueprint, options ImageOptions, repos []rpmmd.RepoConfig, depsolve Depsolver) (string, error) {
+	if bp == nil {
+		bp = &blueprint.Blueprint{}
+	}
+
+	m, _, err := t.Manifest(bp, options, repos, 0)
+	if err != nil {
+		return "", err
+	}
+	kernelName := m.GetKernelName()
+	if kernelName == "" {
+		return "", fmt.Errorf("image type %q has no kernel", t.Name())
+	}
+
+	chains := m.GetPackageSetChains()
+	for _, pipeline := range t.PayloadPipelines() {
+		chain := chains[pipeline]
+		if !slices.ContainsFunc(chain, func(ps rpmmd.PackageSet) bool { return slices.Contains(ps.Include, kernelName) }) {
+			continue
+		}
+
+		packages, err := depsolve(chain)
+		if err != nil {
+			return "", fmt.Errorf("depsolving the packages of pipeline %q failed: %w", pipeline, err)
+		}
+		for _, pkg := range packages {
+			if pkg.Name == kernelName {
+				return pkg.GetNEVRA(), nil
+			}
+		}
+		return "", fmt.Errorf("kernel package %q not found in the depsolved packages of pipeline %q", kernelName, pipeline)
+	}
+
+	return "", fmt.Errorf("no package set of image type %q includes the kernel package %q", t.Name(), kernelName)
+}
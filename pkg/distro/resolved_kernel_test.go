@@ -0,0 +1,69 @@
+package distro_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distroregistry"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDepsolve resolves every included package of the chain to a single
+// version of it, without dependencies.
+func fakeDepsolve(chain []rpmmd.PackageSet) ([]rpmmd.PackageSpec, error) {
+	var packages []rpmmd.PackageSpec
+	for _, ps := range chain {
+		for _, name := range ps.Include {
+			packages = append(packages, rpmmd.PackageSpec{Name: name, Version: "6.5.6", Release: "300.fc39", Arch: "x86_64"})
+		}
+	}
+	return packages, nil
+}
+
+func TestResolvedKernel(t *testing.T) {
+	nevraRegex := regexp.MustCompile(`^[a-zA-Z0-9_.+-]+-([0-9]+:)?[a-zA-Z0-9._+~^]+-[a-zA-Z0-9._+~^]+\.[a-z0-9_]+$`)
+	repos := []rpmmd.RepoConfig{{Name: "base", BaseURLs: []string{"http://example.com/base"}}}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range []string{"fedora-39", "rhel-7", "rhel-89", "rhel-94"} {
+		t.Run(distroName, func(t *testing.T) {
+			arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			kernel, err := distro.ResolvedKernel(imageType, nil, distro.ImageOptions{}, repos, fakeDepsolve)
+			require.NoError(t, err)
+			assert.Equal(t, "kernel-6.5.6-300.fc39.x86_64", kernel)
+			assert.Regexp(t, nevraRegex, kernel)
+
+			bp := &blueprint.Blueprint{
+				Customizations: &blueprint.Customizations{
+					Kernel: &blueprint.KernelCustomization{Name: "kernel-debug"},
+				},
+			}
+			debugKernel, err := distro.ResolvedKernel(imageType, bp, distro.ImageOptions{}, repos, fakeDepsolve)
+			require.NoError(t, err)
+			assert.Equal(t, "kernel-debug-6.5.6-300.fc39.x86_64", debugKernel)
+			assert.Regexp(t, nevraRegex, debugKernel)
+		})
+	}
+
+	arch, err := distros.GetDistro("fedora-39").GetArch("x86_64")
+	require.NoError(t, err)
+	imageType, err := arch.GetImageType("container")
+	require.NoError(t, err)
+	_, err = distro.ResolvedKernel(imageType, nil, distro.ImageOptions{}, repos, fakeDepsolve)
+	assert.EqualError(t, err, `image type "container" has no kernel`)
+
+	imageType, err = arch.GetImageType("qcow2")
+	require.NoError(t, err)
+	_, err = distro.ResolvedKernel(imageType, nil, distro.ImageOptions{}, repos, func([]rpmmd.PackageSet) ([]rpmmd.PackageSpec, error) {
+		return []rpmmd.PackageSpec{{Name: "filesystem", Version: "3.18", Release: "6.fc39", Arch: "x86_64"}}, nil
+	})
+	assert.EqualError(t, err, `kernel package "kernel" not found in the depsolved packages of pipeline "os"`)
+}
@@ -189,6 +189,17 @@ func (a *architecture) Distro() distro.Distro {
 	return a.distro
 }
 
+// kernelVariants returns the variants of the default kernel that the
+// repositories of the distro provide for the architecture. The real-time
+// kernel is in the RT repository, which has to be added to the repositories.
+func (a *architecture) kernelVariants() []string {
+	variants := []string{"kernel-debug"}
+	if a.name == platform.ARCH_X86_64.String() {
+		variants = append(variants, "kernel-rt", "kernel-rt-debug")
+	}
+	return variants
+}
+
 // New creates a new distro object, defining the supported architectures and image types
 func New() distro.Distro {
 	return newDistro("rhel-7")
@@ -1,15 +1,19 @@
 package rhel7_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/distro_test_common"
 	"github.com/osbuild/images/pkg/distro/rhel7"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
 )
 
 type rhelFamilyDistro struct {
@@ -424,3 +428,106 @@ func TestDistro_CustomUsrPartitionNotLargeEnough(t *testing.T) {
 		}
 	}
 }
+
+// Ensure that the customizations that need a newer distro are rejected
+func TestRhel7_UnsupportedCustomizations(t *testing.T) {
+	d := rhel7.New()
+	arch, err := d.GetArch("x86_64")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		imageType      string
+		customizations blueprint.Customizations
+		err            string
+	}{
+		{
+			imageType:      "qcow2",
+			customizations: blueprint.Customizations{DNFAutomatic: &blueprint.DNFAutomaticCustomization{Mode: blueprint.DNFAutomaticModeDownload}},
+			err:            "dnf-automatic is not supported for rhel-7",
+		},
+		{
+			imageType:      "azure-rhui",
+			customizations: blueprint.Customizations{Minimize: &blueprint.MinimizeCustomization{NoDocs: true}},
+			err:            "minimize nodocs is not supported for rhel-7",
+		},
+		{
+			imageType:      "qcow2",
+			customizations: blueprint.Customizations{EnabledModules: []string{"nodejs:18"}},
+			err:            "enabled modules are not supported for rhel-7",
+		},
+		{
+			imageType:      "qcow2",
+			customizations: blueprint.Customizations{FIPS: common.ToPtr(true)},
+			err:            "FIPS mode is not supported for rhel-7",
+		},
+		{
+			imageType:      "qcow2",
+			customizations: blueprint.Customizations{Ignition: &blueprint.IgnitionCustomization{FirstBoot: &blueprint.FirstBootIgnitionCustomization{ProvisioningURL: "https://example.com/config.ign"}}},
+			err:            "ignition is not supported for rhel-7",
+		},
+		{
+			imageType:      "qcow2",
+			customizations: blueprint.Customizations{Bootloader: blueprint.BootloaderSystemdBoot},
+			err:            "systemd-boot is not supported for rhel-7",
+		},
+	}
+	for _, tc := range testCases {
+		imageType, err := arch.GetImageType(tc.imageType)
+		require.NoError(t, err)
+		customizations := tc.customizations
+		_, _, err = imageType.Manifest(&blueprint.Blueprint{Customizations: &customizations}, distro.ImageOptions{}, nil, 0)
+		assert.EqualError(t, err, tc.err)
+	}
+}
+
+// Ensure that the locales are minimized without the documentation
+func TestRhel7_MinimizeLocales(t *testing.T) {
+	arch, err := rhel7.New().GetArch("x86_64")
+	require.NoError(t, err)
+	imageType, err := arch.GetImageType("azure-rhui")
+	require.NoError(t, err)
+
+	bp := blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Minimize: &blueprint.MinimizeCustomization{Locales: []string{"en"}},
+		},
+	}
+	pm := distro_test_common.SerializeManifest(t, imageType, &bp, distro.ImageOptions{})
+	assert.Contains(t, strings.Join(pm.OSStageOptions("org.osbuild.rpm"), ""), `"install_langs":["en"]`)
+}
+
+func TestRhel7_KernelVariantCustomization(t *testing.T) {
+	distro_test_common.TestDistro_KernelVariantCustomization(t, rhel7.New(), "kernel-rt")
+}
+
+// Ensure that the qcow2 options that the qemu-img of the build root doesn't
+// support are rejected and that the azure images don't take the qcow2 and
+// Proxmox options
+func TestRhel7_QCOW2Options(t *testing.T) {
+	arch, err := rhel7.New().GetArch("x86_64")
+	require.NoError(t, err)
+	qcow2, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	distro_test_common.SerializeManifest(t, qcow2, &blueprint.Blueprint{}, distro.ImageOptions{QCOW2Compression: osbuild.QCOW2CompressionZlib})
+	_, _, err = qcow2.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{QCOW2Compression: osbuild.QCOW2CompressionZstd}, nil, 0)
+	assert.EqualError(t, err, `qcow2 compression "zstd" is not supported by the qemu-img of the rhel-7 build root`)
+
+	options := distro.ImageOptions{QCOW2ClusterSize: 2 * common.MebiByte, QCOW2Preallocation: osbuild.QCOW2PreallocationMetadata}
+	pm := distro_test_common.SerializeManifest(t, qcow2, &blueprint.Blueprint{}, options)
+	require.Len(t, pm.StageOptions("qcow2", "org.osbuild.qemu"), 1)
+	assert.Contains(t, pm.StageOptions("qcow2", "org.osbuild.qemu")[0], `"cluster_size":2097152,"preallocation":"metadata"}`)
+	_, _, err = qcow2.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{QCOW2Preallocation: osbuild.QCOW2PreallocationFull}, nil, 0)
+	assert.EqualError(t, err, `qcow2 preallocation "full" is not supported by the qemu-img of the rhel-7 build root`)
+
+	proxmox := distro.ImageOptions{Proxmox: &manifest.ProxmoxVMOptions{Name: "homelab"}}
+	pm = distro_test_common.SerializeManifest(t, qcow2, &blueprint.Blueprint{}, proxmox)
+	assert.Contains(t, strings.Join(pm.StageOptions("proxmox", "org.osbuild.copy"), ""), `"to":"tree:///proxmox.conf"`)
+
+	azure, err := arch.GetImageType("azure-rhui")
+	require.NoError(t, err)
+	_, _, err = azure.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{QCOW2ClusterSize: 65536}, nil, 0)
+	assert.EqualError(t, err, `qcow2 cluster size and preallocation are not supported for image type "azure-rhui"`)
+	_, _, err = azure.Manifest(&blueprint.Blueprint{}, proxmox, nil, 0)
+	assert.EqualError(t, err, `Proxmox options are not supported for image type "azure-rhui"`)
+}
@@ -33,12 +33,16 @@ func osCustomizations(
 		osc.KernelName = c.GetKernel().Name
 
 		var kernelOptions []string
-		if t.kernelOptions != "" {
-			kernelOptions = append(kernelOptions, t.kernelOptions)
+		// the network naming scheme replaces the one of the image type and
+		// the removed arguments only apply to the defaults, not the appends
+		defaultOptions := c.GetNetworkNaming().FilterKernelOptions(t.kernelOptions)
+		if defaultOptions = c.GetKernel().RemoveKernelOptions(defaultOptions); defaultOptions != "" {
+			kernelOptions = append(kernelOptions, defaultOptions)
 		}
 		if bpKernel := c.GetKernel(); bpKernel.Append != "" {
 			kernelOptions = append(kernelOptions, bpKernel.Append)
 		}
+		kernelOptions = append(kernelOptions, c.GetNetworkNaming().KernelOptions()...)
 		osc.KernelOptionsAppend = kernelOptions
 		if t.platform.GetArch() != platform.ARCH_S390X {
 			osc.KernelOptionsBootloader = true
@@ -66,6 +70,9 @@ func osCustomizations(
 	if imageConfig.DefaultTarget != nil {
 		osc.DefaultTarget = *imageConfig.DefaultTarget
 	}
+	if target := c.GetDefaultTarget(); target != "" {
+		osc.DefaultTarget = target
+	}
 
 	osc.Firewall = imageConfig.Firewall
 	if fw := c.GetFirewall(); fw != nil {
@@ -114,7 +121,17 @@ func osCustomizations(
 		osc.Timezone = *imageConfig.Timezone
 	}
 
-	if len(ntpServers) > 0 {
+	if timesync := c.GetTimesync(); timesync != nil && len(timesync.Servers) > 0 {
+		for _, server := range timesync.Servers {
+			osc.NTPServers = append(osc.NTPServers, osbuild.ChronyConfigServer{
+				Hostname: server.Hostname,
+				Minpoll:  server.Minpoll,
+				Maxpoll:  server.Maxpoll,
+				Iburst:   server.Iburst,
+				Prefer:   server.Prefer,
+			})
+		}
+	} else if len(ntpServers) > 0 {
 		for _, server := range ntpServers {
 			osc.NTPServers = append(osc.NTPServers, osbuild.ChronyConfigServer{Hostname: server})
 		}
@@ -182,6 +199,197 @@ func osCustomizations(
 		osc.Files = append(osc.Files, gpgKeyFiles...)
 	}
 
+	networkFiles, err := blueprint.NetworkCustomizationToFsNodeFiles(c.GetNetwork())
+	if err != nil {
+		// The network customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert network customizations to fs node files: %v", err))
+	}
+	if len(networkFiles) > 0 {
+		osc.Files = append(osc.Files, networkFiles...)
+		osc.ExtraBasePackages = append(osc.ExtraBasePackages, "NetworkManager")
+	}
+
+	sysctlFile, err := blueprint.SysctlCustomizationToFsNodeFile(c.GetSysctl())
+	if err != nil {
+		// The sysctl customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert sysctl customizations to fs node file: %v", err))
+	}
+	if sysctlFile != nil {
+		osc.Files = append(osc.Files, sysctlFile)
+	}
+
+	registriesFile, err := blueprint.RegistriesCustomizationToFsNodeFile(c.GetRegistries())
+	if err != nil {
+		// The registries customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert registries customizations to fs node file: %v", err))
+	}
+	if registriesFile != nil {
+		osc.Files = append(osc.Files, registriesFile)
+		osc.ExtraBasePackages = append(osc.ExtraBasePackages, "containers-common")
+	}
+
+	containersPolicyFiles, err := blueprint.ContainersPolicyCustomizationToFsNodeFiles(c.GetContainersPolicy())
+	if err != nil {
+		// The containers policy customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert containers policy customizations to fs node files: %v", err))
+	}
+	if len(containersPolicyFiles) > 0 {
+		osc.Files = append(osc.Files, containersPolicyFiles...)
+		osc.ExtraBasePackages = append(osc.ExtraBasePackages, "containers-common")
+	}
+
+	hostsFile, err := blueprint.HostsEntriesCustomizationToFsNodeFile(c.GetHostsEntries())
+	if err != nil {
+		// The hosts entries customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert hosts entries customizations to fs node file: %v", err))
+	}
+	if hostsFile != nil {
+		osc.Files = append(osc.Files, hostsFile)
+	}
+
+	environmentDirs, environmentFiles, err := blueprint.EnvironmentCustomizationToFsNodes(c.GetEnvironment())
+	if err != nil {
+		// The environment customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert environment customizations to fs nodes: %v", err))
+	}
+	osc.Directories = append(osc.Directories, environmentDirs...)
+	osc.Files = append(osc.Files, environmentFiles...)
+
+	if machineID := c.GetMachineID(); machineID != nil {
+		machineIDFile, err := blueprint.MachineIDCustomizationToFsNodeFile(machineID)
+		if err != nil {
+			// The machine ID customizations should have been validated before this point.
+			panic(fmt.Sprintf("failed to convert machine ID customizations to fs node file: %v", err))
+		}
+		if machineIDFile != nil {
+			osc.Files = append(osc.Files, machineIDFile)
+		} else {
+			osc.MachineIDFirstBoot = osbuild.MachineIdFirstBootNo
+		}
+	}
+
+	caFiles, err := blueprint.CACustomizationToFsNodeFiles(c.GetCACerts())
+	if err != nil {
+		// The CA certificates should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert CA certificates to fs node files: %v", err))
+	}
+	if len(caFiles) > 0 {
+		osc.Files = append(osc.Files, caFiles...)
+		osc.ExtraBasePackages = append(osc.ExtraBasePackages, blueprint.CACertsPackage)
+		osc.UpdateCATrust = true
+	}
+
+	udevRulesFiles, err := blueprint.UdevRulesCustomizationToFsNodeFiles(c.GetUdevRules())
+	if err != nil {
+		// The udev rules customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert udev rules customizations to fs node files: %v", err))
+	}
+	osc.Files = append(osc.Files, udevRulesFiles...)
+
+	if naming := c.GetNetworkNaming(); naming != nil {
+		linkFile, err := blueprint.NetworkNamingCustomizationToFsNodeFile(naming)
+		if err != nil {
+			// The network naming customizations should have been validated before this point.
+			panic(fmt.Sprintf("failed to convert network naming customizations to fs node file: %v", err))
+		}
+		if linkFile != nil {
+			osc.Files = append(osc.Files, linkFile)
+		}
+		if naming.Scheme == blueprint.NetworkNamingSchemeBiosdevname {
+			osc.ExtraBasePackages = append(osc.ExtraBasePackages, blueprint.BiosdevnamePackage)
+		}
+	}
+
+	firstBootFiles, err := blueprint.FirstBootCustomizationToFsNodeFiles(c.GetFirstBoot())
+	if err != nil {
+		// The first boot customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert first boot customizations to fs node files: %v", err))
+	}
+	if len(firstBootFiles) > 0 {
+		osc.Files = append(osc.Files, firstBootFiles...)
+		// copy the services of the image config before adding the service
+		osc.EnabledServices = append(append([]string{}, osc.EnabledServices...), blueprint.FirstBootService)
+	}
+
+	auditFile, err := blueprint.AuditCustomizationToFsNodeFile(c.GetAudit())
+	if err != nil {
+		// The audit customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert audit customizations to fs node file: %v", err))
+	}
+	if auditFile != nil {
+		osc.Files = append(osc.Files, auditFile)
+		osc.ExtraBasePackages = append(osc.ExtraBasePackages, blueprint.AuditPackage)
+		// copy the services of the image config before adding the service
+		osc.EnabledServices = append(append([]string{}, osc.EnabledServices...), blueprint.AuditService)
+	}
+
+	cronFiles, err := blueprint.CronCustomizationToFsNodeFiles(c.GetCron())
+	if err != nil {
+		// The cron customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert cron customizations to fs node files: %v", err))
+	}
+	if len(cronFiles) > 0 {
+		osc.Files = append(osc.Files, cronFiles...)
+		osc.ExtraBasePackages = append(osc.ExtraBasePackages, "cronie")
+	}
+
+	dnfAutomaticDirs, dnfAutomaticFiles, err := blueprint.DNFAutomaticCustomizationToFsNodes(c.GetDNFAutomatic())
+	if err != nil {
+		// The dnf-automatic customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert dnf-automatic customizations to fs nodes: %v", err))
+	}
+	if len(dnfAutomaticFiles) > 0 {
+		osc.Directories = append(osc.Directories, dnfAutomaticDirs...)
+		osc.Files = append(osc.Files, dnfAutomaticFiles...)
+		osc.ExtraBasePackages = append(osc.ExtraBasePackages, blueprint.DNFAutomaticPackage)
+		// copy the services of the image config before adding the timer
+		osc.EnabledServices = append(append([]string{}, osc.EnabledServices...), blueprint.DNFAutomaticTimer)
+	}
+
+	seedDir, seedFiles, err := blueprint.CloudInitCustomizationToFsNodes(c.GetCloudInit())
+	if err != nil {
+		// The cloud-init customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert cloud-init customizations to fs nodes: %v", err))
+	}
+	if seedDir != nil {
+		osc.Directories = append(osc.Directories, seedDir)
+		osc.Files = append(osc.Files, seedFiles...)
+	}
+
+	datasourcesDir, datasourcesFile, err := blueprint.CloudInitDatasourcesToFsNodes(c.GetCloudInit())
+	if err != nil {
+		// The cloud-init customizations should have been validated before this point.
+		panic(fmt.Sprintf("failed to convert cloud-init datasources to fs nodes: %v", err))
+	}
+	if datasourcesFile != nil {
+		osc.Directories = append(osc.Directories, datasourcesDir)
+		osc.Files = append(osc.Files, datasourcesFile)
+	}
+
+	if selinux := c.GetSELinux(); selinux != nil {
+		osc.SELinuxBooleans = selinux.Booleans
+		osc.SELinuxModules = selinux.Modules
+	}
+
+	if mounts := c.GetExtraMounts(); len(mounts) > 0 {
+		for _, m := range mounts {
+			osc.ExtraMounts = append(osc.ExtraMounts, &osbuild.FSTabEntry{
+				Device:  m.Source,
+				VFSType: m.Type,
+				Path:    m.Mountpoint,
+				Options: m.GetOptions(),
+			})
+		}
+		osc.ExtraBasePackages = append(osc.ExtraBasePackages, blueprint.ExtraMountsPackages(mounts)...)
+	}
+
+	if swap := c.GetSwap(); swap.IsFile() {
+		osc.SwapFileSize = swap.Size
+	}
+
+	osc.FIPS = c.GetFIPS()
+	osc.ReadOnlyRoot = c.GetReadOnlyRoot()
+
 	for filename, repos := range yumRepos {
 		osc.YUMRepos = append(osc.YUMRepos, osbuild.NewYumReposStageOptions(filename, repos))
 	}
@@ -190,6 +398,13 @@ func osCustomizations(
 
 	osc.Grub2Config = imageConfig.Grub2Config
 	osc.Sysconfig = imageConfig.Sysconfig
+	// kernel updates keep the selected kernel variant as the default entry
+	if osc.KernelName != "" && osc.KernelName != "kernel" {
+		osc.Sysconfig = make([]*osbuild.SysconfigStageOptions, len(imageConfig.Sysconfig))
+		for idx, sysconfig := range imageConfig.Sysconfig {
+			osc.Sysconfig[idx] = sysconfig.WithDefaultKernel(osc.KernelName)
+		}
+	}
 	osc.SystemdLogind = imageConfig.SystemdLogind
 	osc.CloudInit = imageConfig.CloudInit
 	osc.Modprobe = imageConfig.Modprobe
@@ -202,6 +417,18 @@ func osCustomizations(
 	osc.PamLimitsConf = imageConfig.PamLimitsConf
 	osc.Sysctld = imageConfig.Sysctld
 	osc.DNFConfig = imageConfig.DNFConfig
+
+	if minimize := c.GetMinimize(); minimize != nil {
+		osc.InstallLangs = minimize.Locales
+		languagesFile, err := blueprint.MinimizeCustomizationToFsNodeFile(minimize)
+		if err != nil {
+			// The minimize customizations should have been validated before this point.
+			panic(fmt.Sprintf("failed to convert minimize customizations to fs node file: %v", err))
+		}
+		if languagesFile != nil {
+			osc.Files = append(osc.Files, languagesFile)
+		}
+	}
 	osc.DNFAutomaticConfig = imageConfig.DNFAutomaticConfig
 	osc.YUMConfig = imageConfig.YumConfig
 	osc.SshdConfig = imageConfig.SshdConfig
@@ -241,13 +468,23 @@ func diskImage(workload workload.Workload,
 	img.OSNick = t.arch.distro.nick
 
 	// TODO: move generation into LiveImage
-	pt, err := t.getPartitionTable(customizations.GetFilesystems(), options, rng)
+	pt, err := t.getPartitionTable(customizations, options, rng)
 	if err != nil {
 		return nil, err
 	}
 	img.PartitionTable = pt
 
 	img.Filename = t.Filename()
+	if options.RawOutput {
+		img.RawFilename = distro.RawOutputFilename
+	}
+	img.VMDKSubformat = options.VMDKSubformat
+	img.VPCSubformat = options.VHDSubformat
+	img.QCOW2Compression = options.QCOW2Compression
+	img.QCOW2ClusterSize = options.QCOW2ClusterSize
+	img.QCOW2Preallocation = options.QCOW2Preallocation
+	img.OVFVirtualMachine = options.OVA
+	img.Proxmox = options.Proxmox
 
 	return img, nil
 }
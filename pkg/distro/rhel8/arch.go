@@ -71,3 +71,17 @@ func (a *architecture) addImageTypes(platform platform.Platform, imageTypes ...i
 func (a *architecture) Distro() distro.Distro {
 	return a.distro
 }
+
+// kernelVariants returns the variants of the default kernel that the
+// repositories of the distro provide for the architecture. The real-time
+// kernel is in the RT repository, which has to be added to the repositories.
+func (a *architecture) kernelVariants() []string {
+	variants := []string{"kernel-debug"}
+	switch a.name {
+	case platform.ARCH_X86_64.String():
+		variants = append(variants, "kernel-rt", "kernel-rt-debug")
+	case platform.ARCH_S390X.String():
+		variants = append(variants, "kernel-zfcpdump")
+	}
+	return variants
+}
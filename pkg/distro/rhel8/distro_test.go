@@ -11,6 +11,7 @@ import (
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/distro_test_common"
 	"github.com/osbuild/images/pkg/distro/rhel8"
+	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/platform"
 )
 
@@ -482,10 +483,7 @@ func TestDistro_ManifestError(t *testing.T) {
 		arch, _ := r8distro.GetArch(archName)
 		for _, imgTypeName := range arch.ListImageTypes() {
 			imgType, _ := arch.GetImageType(imgTypeName)
-			imgOpts := distro.ImageOptions{
-				Size: imgType.Size(0),
-			}
-			_, _, err := imgType.Manifest(&bp, imgOpts, nil, 0)
+			_, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
 			if imgTypeName == "edge-commit" || imgTypeName == "edge-container" {
 				assert.EqualError(t, err, "kernel boot parameter customizations are not supported for ostree types")
 			} else if imgTypeName == "edge-raw-image" {
@@ -881,3 +879,68 @@ func TestDistro_CustomUsrPartitionNotLargeEnough(t *testing.T) {
 		}
 	}
 }
+
+func TestRHEL8_DNFAutomaticCustomization(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_DNFAutomaticCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestRHEL8_MinimizeCustomization(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_MinimizeCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestRHEL8_ModuleCustomization(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_ModuleCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestRHEL8_FIPSCustomization(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_FIPSCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestRHEL8_KernelVariantCustomization(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_KernelVariantCustomization(t, dist.distro, "kernel-rt")
+		})
+	}
+}
+
+// Ensure that the customizations that need a newer distro are rejected
+func TestRHEL8_UnsupportedCustomizations(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			arch, err := dist.distro.GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			ignition := blueprint.Blueprint{Customizations: &blueprint.Customizations{
+				Ignition: &blueprint.IgnitionCustomization{FirstBoot: &blueprint.FirstBootIgnitionCustomization{ProvisioningURL: "https://example.com/config.ign"}},
+			}}
+			_, _, err = imageType.Manifest(&ignition, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, fmt.Sprintf("ignition is not supported for %s", dist.distro.Name()))
+
+			systemdBoot := blueprint.Blueprint{Customizations: &blueprint.Customizations{Bootloader: blueprint.BootloaderSystemdBoot}}
+			_, _, err = imageType.Manifest(&systemdBoot, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, fmt.Sprintf("systemd-boot is not supported for %s", dist.distro.Name()))
+
+			_, _, err = imageType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{QCOW2Compression: osbuild.QCOW2CompressionZstd}, nil, 0)
+			assert.EqualError(t, err, fmt.Sprintf(`qcow2 compression "zstd" is not supported by the qemu-img of the %s build root`, dist.distro.Name()))
+		})
+	}
+}
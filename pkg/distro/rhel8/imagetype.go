@@ -19,6 +19,7 @@ import (
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/image"
 	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/platform"
 	"github.com/osbuild/images/pkg/rpmmd"
 )
@@ -130,6 +131,30 @@ func (t *imageType) Exports() []string {
 	return []string{"assembler"}
 }
 
+// supportsRawOutput returns true if the image type is a disk image that is
+// converted from a raw disk image.
+func (t *imageType) supportsRawOutput() bool {
+	return t.PartitionType() != "" && !t.rpmOstree && !t.bootISO && t.platform.GetImageFormat() != platform.FORMAT_RAW
+}
+
+// supportsProxmoxOutput returns true if the image type is an uncompressed
+// qcow2 or raw disk image, which Proxmox VE can import.
+func (t *imageType) supportsProxmoxOutput() bool {
+	format := t.platform.GetImageFormat()
+	return t.PartitionType() != "" && !t.rpmOstree && !t.bootISO && t.compression == "" && (format == platform.FORMAT_QCOW2 || format == platform.FORMAT_RAW)
+}
+
+func (t *imageType) Outputs(options distro.ImageOptions) []distro.Output {
+	outputs := []distro.Output{{Pipeline: t.Exports()[0], Filename: t.Filename(), MIMEType: t.MIMEType()}}
+	if options.RawOutput && t.supportsRawOutput() {
+		outputs = append(outputs, distro.Output{Pipeline: distro.RawOutputPipeline, Filename: distro.RawOutputFilename, MIMEType: distro.RawOutputMIMEType})
+	}
+	if options.Proxmox != nil && t.supportsProxmoxOutput() {
+		outputs = append(outputs, distro.Output{Pipeline: distro.ProxmoxOutputPipeline, Filename: distro.ProxmoxOutputFilename, MIMEType: distro.ProxmoxOutputMIMEType})
+	}
+	return outputs
+}
+
 func (t *imageType) BootMode() distro.BootMode {
 	if t.platform.GetUEFIVendor() != "" && t.platform.GetBIOSPlatform() != "" {
 		return distro.BOOT_HYBRID
@@ -142,7 +167,7 @@ func (t *imageType) BootMode() distro.BootMode {
 }
 
 func (t *imageType) getPartitionTable(
-	mountpoints []blueprint.FilesystemCustomization,
+	customizations *blueprint.Customizations,
 	options distro.ImageOptions,
 	rng *rand.Rand,
 ) (*disk.PartitionTable, error) {
@@ -167,7 +192,34 @@ func (t *imageType) getPartitionTable(
 		partitioningMode = disk.RawPartitioningMode
 	}
 
-	return disk.NewPartitionTable(&basePartitionTable, mountpoints, imageSize, partitioningMode, nil, rng)
+	if space := customizations.GetUnallocatedSpace(); space > 0 {
+		// leave the space unallocated at the end of the disk
+		basePartitionTable.ExtraPadding = space
+	}
+
+	mountpoints := customizations.GetFilesystems()
+	if customizations.GetReadOnlyRoot() {
+		// the writable state of the image, /etc included, lives in /var
+		mountpoints = blueprint.ReadOnlyRootFilesystems(mountpoints)
+	}
+
+	pt, err := disk.NewPartitionTable(&basePartitionTable, mountpoints, imageSize, partitioningMode, nil, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	if customizations.GetCloudInit().HasSeed() {
+		if err := pt.AddNoCloudSeedPartition(blueprint.CloudInitSeedDir); err != nil {
+			return nil, err
+		}
+	}
+	if swap := customizations.GetSwap(); swap != nil && !swap.IsFile() {
+		if err := pt.AddSwapPartition(swap.Size, rng); err != nil {
+			return nil, err
+		}
+	}
+
+	return pt, nil
 }
 
 func (t *imageType) getDefaultImageConfig() *distro.ImageConfig {
@@ -190,16 +242,106 @@ func (t *imageType) PartitionType() string {
 	return basePartitionTable.Type
 }
 
+// Validate returns all the problems found in the options and customizations,
+// including the ones that prevent creating the partition table, instead of
+// only the first one like Manifest.
+func (t *imageType) Validate(bp *blueprint.Blueprint, options distro.ImageOptions) []error {
+	_, errs := t.checkOptions(bp, options)
+
+	if t.PartitionType() != "" {
+		// the partition table is only checked, the random UUIDs don't matter
+		/* #nosec G404 */
+		rng := rand.New(rand.NewSource(0))
+		if _, err := t.getPartitionTable(bp.Customizations, options, rng); err != nil {
+			errs = append(errs, err)
+		} else if err := t.checkSize(bp, options); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// MinimumSize returns the size of the smallest image that fits the
+// customizations of the blueprint with the default partitioning mode, or 0 if
+// the image type has no partition table.
+func (t *imageType) MinimumSize(bp *blueprint.Blueprint) (uint64, error) {
+	return t.minimumSize(bp, disk.DefaultPartitioningMode)
+}
+
+func (t *imageType) minimumSize(bp *blueprint.Blueprint, mode disk.PartitioningMode) (uint64, error) {
+	if t.PartitionType() == "" {
+		return 0, nil
+	}
+
+	// The partition table grows to fit its contents when the image size is
+	// too small, 1 is the smallest size that is not replaced by the default
+	// size. The random UUIDs don't matter.
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(0))
+	pt, err := t.getPartitionTable(bp.Customizations, distro.ImageOptions{Size: 1, PartitioningMode: mode}, rng)
+	if err != nil {
+		return 0, err
+	}
+	return pt.Size, nil
+}
+
+// SizeEstimate returns the virtual size of the disk image, i.e. the size of
+// its partition table, and an estimate of the size that it allocates, based
+// on the minimum size of the partition table for the blueprint.
+func (t *imageType) SizeEstimate(bp *blueprint.Blueprint, options distro.ImageOptions) (uint64, uint64, error) {
+	if t.PartitionType() == "" {
+		return 0, 0, nil
+	}
+
+	m, _, err := t.Manifest(bp, options, nil, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	virtual := m.GetDiskSize()
+	content, err := t.minimumSize(bp, options.PartitioningMode)
+	if err != nil {
+		return 0, 0, err
+	}
+	return virtual, distro.EstimateActualSize(t.platform.GetImageFormat(), t.compression, virtual, content, options), nil
+}
+
+// checkSize returns an error if an image size is requested and it is smaller
+// than the minimum size for the blueprint and partitioning mode.
+func (t *imageType) checkSize(bp *blueprint.Blueprint, options distro.ImageOptions) error {
+	if options.Size == 0 {
+		return nil
+	}
+
+	minSize, err := t.minimumSize(bp, options.PartitioningMode)
+	if err != nil {
+		return err
+	}
+	if size := t.Size(options.Size); size < minSize {
+		return fmt.Errorf("requested size %d is smaller than the minimum size %d for the blueprint", size, minSize)
+	}
+	return nil
+}
+
 func (t *imageType) Manifest(bp *blueprint.Blueprint,
 	options distro.ImageOptions,
 	repos []rpmmd.RepoConfig,
 	seed int64) (*manifest.Manifest, []string, error) {
 
-	warnings, err := t.checkOptions(bp, options)
-	if err != nil {
+	warnings, errs := t.checkOptions(bp, options)
+	if len(errs) > 0 {
+		return nil, nil, errs[0]
+	}
+
+	if err := t.checkSize(bp, options); err != nil {
 		return nil, nil, err
 	}
 
+	options.Progress.Report(manifest.PhaseResolvingPackageSets)
+
+	// the package sets are depsolved with the repositories, through the proxy
+	repos = options.Proxy.ApplyToRepos(repos)
+
 	// merge package sets that appear in the image type with the package sets
 	// of the same name from the distro and arch
 	staticPackageSets := make(map[string]rpmmd.PackageSet)
@@ -224,17 +366,30 @@ func (t *imageType) Manifest(bp *blueprint.Blueprint,
 		}
 	}
 
+	// the packages of the image type cannot be excluded, the depsolver would
+	// fail to install them
+	required := append([]string{}, staticPackageSets[osPkgsKey].Include...)
+	if t.bootable || t.rpmOstree {
+		required = append(required, bp.Customizations.GetKernel().Name)
+	}
+	if err := bp.CheckExcludedPackages(required); err != nil {
+		return nil, nil, err
+	}
+
 	w := t.workload
 	if w == nil {
 		cw := &workload.Custom{
 			BaseWorkload: workload.BaseWorkload{
 				Repos: payloadRepos,
 			},
-			Packages: bp.GetPackagesEx(false),
+			Packages:        bp.GetPackagesEx(false),
+			ExcludePackages: bp.ExcludePackages,
+			InstallWeakDeps: bp.InstallWeakDeps,
 		}
 		if services := bp.Customizations.GetServices(); services != nil {
 			cw.Services = services.Enabled
 			cw.DisabledServices = services.Disabled
+			cw.MaskedServices = services.Masked
 		}
 		w = cw
 	}
@@ -252,12 +407,17 @@ func (t *imageType) Manifest(bp *blueprint.Blueprint,
 	if t.image == nil {
 		return nil, nil, nil
 	}
+	options.Progress.Report(manifest.PhaseBuildingPipelines)
 	img, err := t.image(w, t, bp.Customizations, options, staticPackageSets, containerSources, rng)
 	if err != nil {
 		return nil, nil, err
 	}
 	mf := manifest.New()
 	mf.Distro = manifest.DISTRO_EL8
+	mf.Progress = options.Progress
+	mf.ContentAddressedFilenames = options.ContentAddressedFilenames
+	mf.SourceDateEpoch = options.SourceDateEpoch
+	mf.NoWeakDeps = options.NoWeakDeps
 	_, err = img.InstantiateManifest(&mf, repos, t.arch.distro.runner, rng)
 	if err != nil {
 		return nil, nil, err
@@ -267,11 +427,13 @@ func (t *imageType) Manifest(bp *blueprint.Blueprint,
 }
 
 // checkOptions checks the validity and compatibility of options and customizations for the image type.
-// Returns ([]string, error) where []string, if non-nil, will hold any generated warnings (e.g. deprecation notices).
-func (t *imageType) checkOptions(bp *blueprint.Blueprint, options distro.ImageOptions) ([]string, error) {
+// Returns ([]string, []error) where []string, if non-nil, will hold any generated warnings (e.g. deprecation notices)
+// and []error holds every problem found, in the order the checks are run.
+func (t *imageType) checkOptions(bp *blueprint.Blueprint, options distro.ImageOptions) ([]string, []error) {
 	customizations := bp.Customizations
 	// holds warnings (e.g. deprecation notices)
 	var warnings []string
+	var errs []error
 	if t.workload != nil {
 		// For now, if an image type defines its own workload, don't allow any
 		// user customizations.
@@ -279,38 +441,68 @@ func (t *imageType) checkOptions(bp *blueprint.Blueprint, options distro.ImageOp
 		// set of customizations.  The current set of customizations defined in
 		// the blueprint spec corresponds to the Custom workflow.
 		if customizations != nil {
-			return warnings, fmt.Errorf("image type %q does not support customizations", t.name)
+			errs = append(errs, fmt.Errorf("image type %q does not support customizations", t.name))
 		}
 	}
 	// we do not support embedding containers on ostree-derived images, only on commits themselves
 	if len(bp.Containers) > 0 && t.rpmOstree && (t.name != "edge-commit" && t.name != "edge-container") {
-		return warnings, fmt.Errorf("embedding containers is not supported for %s on %s", t.name, t.arch.distro.name)
+		errs = append(errs, fmt.Errorf("embedding containers is not supported for %s on %s", t.name, t.arch.distro.name))
+	}
+
+	// the packages of an ostree commit can only be changed when it is composed
+	if rpmOSTree := customizations.GetRPMOSTree(); rpmOSTree != nil {
+		if t.name != "edge-commit" && t.name != "edge-container" {
+			errs = append(errs, fmt.Errorf("rpm-ostree customizations are not supported for image type %q", t.name))
+		}
+		if err := blueprint.ValidateRPMOSTreeCustomization(rpmOSTree, bp.Packages); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := bp.ValidatePackageVersions(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := bp.ValidateGroups(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := bp.ValidateExcludePackages(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := blueprint.ValidateKernelCustomization(customizations.GetKernel(), t.arch.kernelVariants()); err != nil {
+		errs = append(errs, fmt.Errorf("%w for %s on %s", err, t.arch.distro.name, t.arch.name))
+	}
+
+	if err := bp.ValidateContainers(); err != nil {
+		errs = append(errs, err)
 	}
 
 	if options.OSTree != nil {
 		if err := options.OSTree.Validate(); err != nil {
-			return nil, err
+			errs = append(errs, err)
 		}
 	}
 
 	if t.bootISO && t.rpmOstree {
 		// ostree-based ISOs require a URL from which to pull a payload commit
 		if options.OSTree == nil || options.OSTree.URL == "" {
-			return nil, fmt.Errorf("boot ISO image type %q requires specifying a URL from which to retrieve the OSTree commit", t.name)
+			errs = append(errs, fmt.Errorf("boot ISO image type %q requires specifying a URL from which to retrieve the OSTree commit", t.name))
 		}
 
 		if t.name == "edge-simplified-installer" {
 			allowed := []string{"InstallationDevice", "FDO", "User", "Group"}
 			if err := customizations.CheckAllowed(allowed...); err != nil {
-				return warnings, fmt.Errorf("unsupported blueprint customizations found for boot ISO image type %q: (allowed: %s)", t.name, strings.Join(allowed, ", "))
+				errs = append(errs, fmt.Errorf("unsupported blueprint customizations found for boot ISO image type %q: (allowed: %s)", t.name, strings.Join(allowed, ", ")))
 			}
 			if customizations.GetInstallationDevice() == "" {
-				return warnings, fmt.Errorf("boot ISO image type %q requires specifying an installation device to install to", t.name)
+				errs = append(errs, fmt.Errorf("boot ISO image type %q requires specifying an installation device to install to", t.name))
 			}
 			//making fdo optional so that simplified installer can be composed w/o the FDO section in the blueprint
 			if customizations.GetFDO() != nil {
 				if customizations.GetFDO().ManufacturingServerURL == "" {
-					return warnings, fmt.Errorf("boot ISO image type %q requires specifying FDO.ManufacturingServerURL configuration to install to", t.name)
+					errs = append(errs, fmt.Errorf("boot ISO image type %q requires specifying FDO.ManufacturingServerURL configuration to install to", t.name))
 				}
 				var diunSet int
 				if customizations.GetFDO().DiunPubKeyHash != "" {
@@ -323,13 +515,13 @@ func (t *imageType) checkOptions(bp *blueprint.Blueprint, options distro.ImageOp
 					diunSet++
 				}
 				if diunSet != 1 {
-					return warnings, fmt.Errorf("boot ISO image type %q requires specifying one of [FDO.DiunPubKeyHash,FDO.DiunPubKeyInsecure,FDO.DiunPubKeyRootCerts] configuration to install to", t.name)
+					errs = append(errs, fmt.Errorf("boot ISO image type %q requires specifying one of [FDO.DiunPubKeyHash,FDO.DiunPubKeyInsecure,FDO.DiunPubKeyRootCerts] configuration to install to", t.name))
 				}
 			}
 		} else if t.name == "edge-installer" {
 			allowed := []string{"User", "Group"}
 			if err := customizations.CheckAllowed(allowed...); err != nil {
-				return warnings, fmt.Errorf("unsupported blueprint customizations found for boot ISO image type %q: (allowed: %s)", t.name, strings.Join(allowed, ", "))
+				errs = append(errs, fmt.Errorf("unsupported blueprint customizations found for boot ISO image type %q: (allowed: %s)", t.name, strings.Join(allowed, ", ")))
 			}
 		}
 	}
@@ -337,12 +529,12 @@ func (t *imageType) checkOptions(bp *blueprint.Blueprint, options distro.ImageOp
 	if t.name == "edge-raw-image" {
 		// ostree-based bootable images require a URL from which to pull a payload commit
 		if options.OSTree == nil || options.OSTree.URL == "" {
-			return warnings, fmt.Errorf("%q images require specifying a URL from which to retrieve the OSTree commit", t.name)
+			errs = append(errs, fmt.Errorf("%q images require specifying a URL from which to retrieve the OSTree commit", t.name))
 		}
 
 		allowed := []string{"User", "Group"}
 		if err := customizations.CheckAllowed(allowed...); err != nil {
-			return warnings, fmt.Errorf("unsupported blueprint customizations found for image type %q: (allowed: %s)", t.name, strings.Join(allowed, ", "))
+			errs = append(errs, fmt.Errorf("unsupported blueprint customizations found for image type %q: (allowed: %s)", t.name, strings.Join(allowed, ", ")))
 		}
 		// TODO: consider additional checks, such as those in "edge-simplified-installer"
 	}
@@ -363,32 +555,54 @@ func (t *imageType) checkOptions(bp *blueprint.Blueprint, options distro.ImageOp
 	}
 
 	if kernelOpts := customizations.GetKernel(); kernelOpts.Append != "" && t.rpmOstree && t.name != "edge-raw-image" && t.name != "edge-simplified-installer" {
-		return warnings, fmt.Errorf("kernel boot parameter customizations are not supported for ostree types")
+		errs = append(errs, fmt.Errorf("kernel boot parameter customizations are not supported for ostree types"))
+	}
+
+	if len(customizations.GetKernel().Remove) > 0 && t.rpmOstree {
+		errs = append(errs, fmt.Errorf("kernel boot parameter removals are not supported for ostree types"))
+	}
+
+	if customizations.GetFIPS() && t.rpmOstree {
+		errs = append(errs, fmt.Errorf("FIPS mode is not supported for ostree types"))
+	}
+
+	if bootloader := customizations.GetBootloader(); bootloader != "" {
+		if err := blueprint.ValidateBootloaderCustomization(bootloader); err != nil {
+			errs = append(errs, err)
+		}
+		// systemd-boot is not shipped in the distro repositories
+		if bootloader == blueprint.BootloaderSystemdBoot {
+			errs = append(errs, fmt.Errorf("systemd-boot is not supported for %s", t.arch.distro.name))
+		}
 	}
 
 	mountpoints := customizations.GetFilesystems()
 
 	if mountpoints != nil && t.rpmOstree {
-		return warnings, fmt.Errorf("Custom mountpoints are not supported for ostree types")
+		errs = append(errs, fmt.Errorf("Custom mountpoints are not supported for ostree types"))
 	}
 
 	err := blueprint.CheckMountpointsPolicy(mountpoints, pathpolicy.MountpointPolicies)
 	if err != nil {
-		return warnings, err
+		errs = append(errs, err)
+	}
+
+	if err := blueprint.CheckMountpointsConflicts(mountpoints); err != nil {
+		errs = append(errs, err)
 	}
 
 	if osc := customizations.GetOpenSCAP(); osc != nil {
 		if t.arch.distro.osVersion == "9.0" {
-			return warnings, fmt.Errorf(fmt.Sprintf("OpenSCAP unsupported os version: %s", t.arch.distro.osVersion))
+			errs = append(errs, fmt.Errorf(fmt.Sprintf("OpenSCAP unsupported os version: %s", t.arch.distro.osVersion)))
 		}
 		if !oscap.IsProfileAllowed(osc.ProfileID, oscapProfileAllowList) {
-			return warnings, fmt.Errorf(fmt.Sprintf("OpenSCAP unsupported profile: %s", osc.ProfileID))
+			errs = append(errs, fmt.Errorf(fmt.Sprintf("OpenSCAP unsupported profile: %s", osc.ProfileID)))
 		}
 		if t.rpmOstree {
-			return warnings, fmt.Errorf("OpenSCAP customizations are not supported for ostree types")
+			errs = append(errs, fmt.Errorf("OpenSCAP customizations are not supported for ostree types"))
 		}
 		if osc.ProfileID == "" {
-			return warnings, fmt.Errorf("OpenSCAP profile cannot be empty")
+			errs = append(errs, fmt.Errorf("OpenSCAP profile cannot be empty"))
 		}
 	}
 
@@ -398,23 +612,294 @@ func (t *imageType) checkOptions(bp *blueprint.Blueprint, options distro.ImageOp
 
 	err = blueprint.ValidateDirFileCustomizations(dc, fc)
 	if err != nil {
-		return warnings, err
+		errs = append(errs, err)
 	}
 	err = blueprint.CheckDirectoryCustomizationsPolicy(dc, pathpolicy.CustomDirectoriesPolicies)
 	if err != nil {
-		return warnings, err
+		errs = append(errs, err)
 	}
 
 	err = blueprint.CheckFileCustomizationsPolicy(fc, pathpolicy.CustomFilesPolicies)
 	if err != nil {
-		return warnings, err
+		errs = append(errs, err)
 	}
 
 	// check if repository customizations are valid
 	_, err = customizations.GetRepositories()
 	if err != nil {
-		return warnings, err
+		errs = append(errs, err)
+	}
+
+	err = blueprint.ValidateNetworkCustomization(customizations.GetNetwork())
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if timesync := customizations.GetTimesync(); timesync != nil {
+		if _, ntpServers := customizations.GetTimezoneSettings(); len(ntpServers) > 0 {
+			errs = append(errs, fmt.Errorf("timesync servers cannot be combined with timezone ntpservers"))
+		}
+		err = blueprint.ValidateTimesyncCustomization(timesync)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	err = blueprint.ValidateSysctlCustomization(customizations.GetSysctl())
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = blueprint.ValidateRegistriesCustomization(customizations.GetRegistries())
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = blueprint.ValidateContainersPolicyCustomization(customizations.GetContainersPolicy(), fc)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = blueprint.ValidateDefaultTargetCustomization(customizations.GetDefaultTarget())
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = blueprint.ValidateServicesCustomization(customizations.GetServices())
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = blueprint.ValidateUsersCustomization(customizations.GetUsers(), customizations.GetGroups())
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if dnfAutomatic := customizations.GetDNFAutomatic(); dnfAutomatic != nil {
+		if t.rpmOstree {
+			errs = append(errs, fmt.Errorf("dnf-automatic is not supported for ostree types"))
+		}
+		err = blueprint.ValidateDNFAutomaticCustomization(dnfAutomatic)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if hosts := customizations.GetHostsEntries(); len(hosts) > 0 {
+		for _, file := range fc {
+			if file.Path == blueprint.HostsFilePath {
+				errs = append(errs, fmt.Errorf("hosts entries cannot be combined with a custom %s file", blueprint.HostsFilePath))
+			}
+		}
+		err = blueprint.ValidateHostsEntriesCustomization(hosts)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if environment := customizations.GetEnvironment(); len(environment) > 0 {
+		for _, file := range fc {
+			if file.Path == blueprint.EnvironmentFilePath || file.Path == blueprint.EnvironmentDropInPath {
+				errs = append(errs, fmt.Errorf("environment customizations cannot be combined with a custom %s file", file.Path))
+			}
+		}
+		err = blueprint.ValidateEnvironmentCustomization(environment)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if machineID := customizations.GetMachineID(); machineID != nil {
+		if t.rpmOstree {
+			errs = append(errs, fmt.Errorf("machine ID customizations are not supported for ostree types"))
+		}
+		err = blueprint.ValidateMachineIDCustomization(machineID, fc)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	err = blueprint.ValidateCACustomization(customizations.GetCACerts(), fc)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = blueprint.ValidateUdevRulesCustomization(customizations.GetUdevRules(), fc)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if naming := customizations.GetNetworkNaming(); naming != nil {
+		if naming.GetScheme() != "" && (t.rpmOstree || !t.bootable) {
+			errs = append(errs, fmt.Errorf("network naming is not supported for image type %q", t.name))
+		}
+		if naming.Scheme == blueprint.NetworkNamingSchemeBiosdevname && t.arch.Name() != platform.ARCH_X86_64.String() {
+			errs = append(errs, fmt.Errorf("network naming scheme %q is not supported on %s", naming.Scheme, t.arch.Name()))
+		}
+		if err := blueprint.ValidateNetworkNamingCustomization(naming, customizations.GetKernel(), fc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	err = blueprint.ValidateFirstBootCustomization(customizations.GetFirstBoot(), fc)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = blueprint.ValidateAuditCustomization(customizations.GetAudit(), fc)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if minimize := customizations.GetMinimize(); minimize != nil {
+		var locale string
+		if language, _ := customizations.GetPrimaryLocale(); language != nil {
+			locale = *language
+		} else if defaultLocale := t.getDefaultImageConfig().Locale; defaultLocale != nil {
+			locale = *defaultLocale
+		}
+		if err := blueprint.ValidateMinimizeCustomization(minimize, locale); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// the edge image types configure ignition themselves
+	if customizations.GetIgnition() != nil && !t.rpmOstree && !t.bootISO {
+		errs = append(errs, fmt.Errorf("ignition is not supported for %s", t.arch.distro.name))
+	}
+
+	err = blueprint.ValidateCronCustomization(customizations.GetCron(), customizations.GetUsers())
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = blueprint.ValidateSELinuxCustomization(customizations.GetSELinux(), fc)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = blueprint.ValidateModuleCustomization(customizations.GetEnabledModules())
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if cloudInit := customizations.GetCloudInit(); cloudInit != nil {
+		if cloudInit.HasSeed() && t.name != "qcow2" && t.name != "openstack" {
+			errs = append(errs, fmt.Errorf("cloud-init seed customization is not supported for image type %q", t.name))
+		}
+		err = blueprint.ValidateCloudInitCustomization(cloudInit)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if options.VMDKSubformat != "" {
+		if t.PartitionType() == "" || t.rpmOstree || t.bootISO || t.platform.GetImageFormat() != platform.FORMAT_VMDK {
+			errs = append(errs, fmt.Errorf("VMDK subformat is not supported for image type %q", t.name))
+		} else if options.VMDKSubformat != osbuild.VMDKSubformatStreamOptimized && options.VMDKSubformat != osbuild.VMDKSubformatMonolithicSparse {
+			errs = append(errs, fmt.Errorf("unsupported VMDK subformat %q (supported: %s, %s)", options.VMDKSubformat, osbuild.VMDKSubformatStreamOptimized, osbuild.VMDKSubformatMonolithicSparse))
+		}
+	}
+
+	if options.SourceDateEpoch != nil && *options.SourceDateEpoch < 0 {
+		errs = append(errs, fmt.Errorf("source date epoch %d is before 1970", *options.SourceDateEpoch))
+	}
+
+	if err := options.Proxy.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if options.VHDSubformat != "" {
+		if t.PartitionType() == "" || t.rpmOstree || t.bootISO || t.platform.GetImageFormat() != platform.FORMAT_VHD {
+			errs = append(errs, fmt.Errorf("VHD subformat is not supported for image type %q", t.name))
+		} else if options.VHDSubformat != osbuild.VPCSubformatFixed && options.VHDSubformat != osbuild.VPCSubformatDynamic {
+			errs = append(errs, fmt.Errorf("unsupported VHD subformat %q (supported: %s, %s)", options.VHDSubformat, osbuild.VPCSubformatFixed, osbuild.VPCSubformatDynamic))
+		} else if options.VHDSubformat != osbuild.VPCSubformatFixed {
+			// all the vhd image types are for Azure, which only accepts fixed VHDs
+			errs = append(errs, fmt.Errorf("image type %q is for Azure, which requires fixed VHDs", t.name))
+		}
+	}
+
+	if options.QCOW2Compression != "" {
+		if t.PartitionType() == "" || t.rpmOstree || t.bootISO || t.platform.GetImageFormat() != platform.FORMAT_QCOW2 {
+			errs = append(errs, fmt.Errorf("qcow2 compression is not supported for image type %q", t.name))
+		} else if options.QCOW2Compression == osbuild.QCOW2CompressionZstd {
+			// zstd needs qemu-img 5.1, which the build root doesn't have
+			errs = append(errs, fmt.Errorf("qcow2 compression %q is not supported by the qemu-img of the %s build root", options.QCOW2Compression, t.arch.distro.name))
+		} else if options.QCOW2Compression != osbuild.QCOW2CompressionZlib {
+			errs = append(errs, fmt.Errorf("unsupported qcow2 compression %q (supported: %s)", options.QCOW2Compression, osbuild.QCOW2CompressionZlib))
+		}
+	}
+
+	if options.QCOW2ClusterSize != 0 || options.QCOW2Preallocation != "" {
+		qcow2Options := osbuild.QCOW2Options{
+			Compat:          t.platform.GetQCOW2Compat(),
+			CompressionType: options.QCOW2Compression,
+			ClusterSize:     options.QCOW2ClusterSize,
+			Preallocation:   options.QCOW2Preallocation,
+		}
+		if t.PartitionType() == "" || t.rpmOstree || t.bootISO || t.platform.GetImageFormat() != platform.FORMAT_QCOW2 {
+			errs = append(errs, fmt.Errorf("qcow2 cluster size and preallocation are not supported for image type %q", t.name))
+		} else if err := qcow2Options.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if options.OVA != nil {
+		if t.PartitionType() == "" || t.rpmOstree || t.bootISO || t.platform.GetImageFormat() != platform.FORMAT_OVA {
+			errs = append(errs, fmt.Errorf("OVA options are not supported for image type %q", t.name))
+		} else if err := options.OVA.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if options.RawOutput && !t.supportsRawOutput() {
+		errs = append(errs, fmt.Errorf("raw output is not supported for image type %q", t.name))
+	}
+
+	if options.Proxmox != nil {
+		if !t.supportsProxmoxOutput() {
+			errs = append(errs, fmt.Errorf("Proxmox options are not supported for image type %q", t.name))
+		} else if err := options.Proxmox.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if mounts := customizations.GetExtraMounts(); len(mounts) > 0 {
+		if t.PartitionType() == "" || t.rpmOstree || t.bootISO {
+			errs = append(errs, fmt.Errorf("extra mounts are not supported for image type %q", t.name))
+		}
+		if err := blueprint.ValidateExtraMountsCustomization(mounts, customizations.GetFilesystems()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if swap := customizations.GetSwap(); swap != nil {
+		if t.PartitionType() == "" || t.rpmOstree || t.bootISO {
+			errs = append(errs, fmt.Errorf("swap is not supported for image type %q", t.name))
+		}
+		if err := blueprint.ValidateSwapCustomization(swap, t.Size(options.Size)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if customizations.GetReadOnlyRoot() {
+		if t.PartitionType() == "" || t.rpmOstree || t.bootISO {
+			errs = append(errs, fmt.Errorf("read-only root is not supported for image type %q", t.name))
+		}
+		if err := blueprint.ValidateReadOnlyRootCustomization(customizations.GetFilesystems(), customizations.GetSwap()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if space := customizations.GetUnallocatedSpace(); space > 0 {
+		imageSize := t.Size(options.Size)
+		if t.PartitionType() == "" {
+			errs = append(errs, fmt.Errorf("unallocated space is not supported for image type %q", t.name))
+		} else if space >= imageSize {
+			errs = append(errs, fmt.Errorf("unallocated space of %d bytes does not fit in the image size (%d bytes)", space, imageSize))
+		}
 	}
 
-	return warnings, nil
+	return warnings, errs
 }
@@ -481,10 +481,7 @@ func TestDistro_ManifestError(t *testing.T) {
 		arch, _ := r9distro.GetArch(archName)
 		for _, imgTypeName := range arch.ListImageTypes() {
 			imgType, _ := arch.GetImageType(imgTypeName)
-			imgOpts := distro.ImageOptions{
-				Size: imgType.Size(0),
-			}
-			_, _, err := imgType.Manifest(&bp, imgOpts, nil, 0)
+			_, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
 			if imgTypeName == "edge-commit" || imgTypeName == "edge-container" {
 				assert.EqualError(t, err, "kernel boot parameter customizations are not supported for ostree types")
 			} else if imgTypeName == "edge-raw-image" || imgTypeName == "edge-ami" || imgTypeName == "edge-vsphere" {
@@ -838,3 +835,66 @@ func TestDistro_CustomUsrPartitionNotLargeEnough(t *testing.T) {
 		}
 	}
 }
+
+func TestRhel9_DNFAutomaticCustomization(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_DNFAutomaticCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestRhel9_IgnitionCustomization(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_IgnitionCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestRhel9_MinimizeCustomization(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_MinimizeCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestRhel9_ModuleCustomization(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_ModuleCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestRhel9_FIPSCustomization(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_FIPSCustomization(t, dist.distro)
+		})
+	}
+}
+
+func TestRhel9_KernelVariantCustomization(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			distro_test_common.TestDistro_KernelVariantCustomization(t, dist.distro, "kernel-rt")
+		})
+	}
+}
+
+func TestRhel9_SystemdBootNotSupported(t *testing.T) {
+	for _, dist := range rhelFamilyDistros {
+		t.Run(dist.distro.Name(), func(t *testing.T) {
+			arch, err := dist.distro.GetArch("x86_64")
+			require.NoError(t, err)
+			imageType, err := arch.GetImageType("qcow2")
+			require.NoError(t, err)
+
+			bp := blueprint.Blueprint{Customizations: &blueprint.Customizations{Bootloader: blueprint.BootloaderSystemdBoot}}
+			_, _, err = imageType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			assert.EqualError(t, err, fmt.Sprintf("systemd-boot is not supported for %s", dist.distro.Name()))
+		})
+	}
+}
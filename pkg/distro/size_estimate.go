@@ -0,0 +1,58 @@
+package distro
+
+import (
+	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/platform"
+)
+
+// Rough ratios that the data of an OS image is compressed with
+const (
+	compressionRatioDeflate = 2 // gzip, zlib and zstd
+	compressionRatioXZ      = 3
+)
+
+// EstimateActualSize returns an estimate of the size in bytes that a disk
+// image of the given format and virtual size allocates, i.e. the size of the
+// file that is stored or uploaded. The data of the image is estimated with
+// the given content size, e.g. the minimum size of the partition table, which
+// is an upper bound of the data that the OS writes to the disk.
+//
+// Sparse images only allocate their data, preallocated and fixed images their
+// whole virtual size and compressed images, e.g. the qcow2 images or the
+// images with a compressed output, the compressed data. The estimate is of
+// the image itself, without the raw disk image exported with RawOutput.
+func EstimateActualSize(format platform.ImageFormat, compression string, virtual, content uint64, options ImageOptions) uint64 {
+	actual := content
+	if actual == 0 || actual > virtual {
+		actual = virtual
+	}
+
+	switch compression {
+	case "xz":
+		return actual / compressionRatioXZ
+	case "gzip", "zstd":
+		return actual / compressionRatioDeflate
+	}
+
+	switch format {
+	case platform.FORMAT_QCOW2, platform.FORMAT_VAGRANT_LIBVIRT:
+		switch options.QCOW2Preallocation {
+		case "", osbuild.QCOW2PreallocationOff:
+			return actual / compressionRatioDeflate
+		case osbuild.QCOW2PreallocationFalloc, osbuild.QCOW2PreallocationFull:
+			return virtual
+		}
+	case platform.FORMAT_VMDK:
+		if options.VMDKSubformat == "" || options.VMDKSubformat == osbuild.VMDKSubformatStreamOptimized {
+			return actual / compressionRatioDeflate
+		}
+	case platform.FORMAT_OVA, platform.FORMAT_VAGRANT_VIRTUALBOX, platform.FORMAT_GCE:
+		// streamOptimized vmdk and gzip compressed tarballs
+		return actual / compressionRatioDeflate
+	case platform.FORMAT_VHD:
+		if options.VHDSubformat == "" || options.VHDSubformat == osbuild.VPCSubformatFixed {
+			return virtual
+		}
+	}
+	return actual
+}
@@ -0,0 +1,100 @@
+package distro_test
+
+import (
+	"testing"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distroregistry"
+	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/platform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizeEstimate(t *testing.T) {
+	bp := &blueprint.Blueprint{}
+
+	distros := distroregistry.NewDefault()
+	for _, distroName := range distros.List() {
+		arch, err := distros.GetDistro(distroName).GetArch("x86_64")
+		require.NoError(t, err)
+		for _, imageTypeName := range arch.ListImageTypes() {
+			imageType, err := arch.GetImageType(imageTypeName)
+			require.NoError(t, err)
+			if imageType.PartitionType() == "" || imageType.OSTreeRef() != "" {
+				continue
+			}
+			t.Run(distroName+"/"+imageTypeName, func(t *testing.T) {
+				virtual, actual, err := imageType.SizeEstimate(bp, distro.ImageOptions{})
+				require.NoError(t, err)
+				assert.Positive(t, virtual)
+				assert.Positive(t, actual)
+				assert.GreaterOrEqual(t, virtual, actual)
+				assert.GreaterOrEqual(t, virtual, imageType.Size(0))
+			})
+		}
+	}
+
+	arch, err := distros.GetDistro("fedora-39").GetArch("x86_64")
+	require.NoError(t, err)
+	imageType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+	virtual, actual, err := imageType.SizeEstimate(bp, distro.ImageOptions{})
+	require.NoError(t, err)
+	// the partition table grows to fit the filesystems of the blueprint
+	large := &blueprint.Blueprint{
+		Customizations: &blueprint.Customizations{
+			Filesystem: []blueprint.FilesystemCustomization{{Mountpoint: "/var", MinSize: 20 * common.GibiByte}},
+		},
+	}
+	largeVirtual, largeActual, err := imageType.SizeEstimate(large, distro.ImageOptions{})
+	require.NoError(t, err)
+	assert.Greater(t, largeVirtual, virtual)
+	assert.Greater(t, largeActual, actual)
+	_, _, err = imageType.SizeEstimate(bp, distro.ImageOptions{Size: common.MebiByte})
+	assert.Error(t, err)
+
+	imageType, err = arch.GetImageType("container")
+	require.NoError(t, err)
+	virtual, actual, err = imageType.SizeEstimate(bp, distro.ImageOptions{})
+	require.NoError(t, err)
+	assert.Zero(t, virtual)
+	assert.Zero(t, actual)
+}
+
+func TestEstimateActualSize(t *testing.T) {
+	virtual := uint64(10 * common.GibiByte)
+	content := uint64(3 * common.GibiByte)
+
+	testCases := []struct {
+		name        string
+		format      platform.ImageFormat
+		compression string
+		options     distro.ImageOptions
+		want        uint64
+	}{
+		{name: "sparse raw", format: platform.FORMAT_RAW, want: content},
+		{name: "compressed raw", format: platform.FORMAT_RAW, compression: "xz", want: content / 3},
+		{name: "qcow2", format: platform.FORMAT_QCOW2, want: content / 2},
+		{name: "metadata preallocated qcow2", format: platform.FORMAT_QCOW2, options: distro.ImageOptions{QCOW2Preallocation: osbuild.QCOW2PreallocationMetadata}, want: content},
+		{name: "fully preallocated qcow2", format: platform.FORMAT_QCOW2, options: distro.ImageOptions{QCOW2Preallocation: osbuild.QCOW2PreallocationFull}, want: virtual},
+		{name: "raw output", format: platform.FORMAT_QCOW2, options: distro.ImageOptions{RawOutput: true}, want: content / 2},
+		{name: "stream optimized vmdk", format: platform.FORMAT_VMDK, want: content / 2},
+		{name: "sparse vmdk", format: platform.FORMAT_VMDK, options: distro.ImageOptions{VMDKSubformat: osbuild.VMDKSubformatMonolithicSparse}, want: content},
+		{name: "fixed vhd", format: platform.FORMAT_VHD, want: virtual},
+		{name: "dynamic vhd", format: platform.FORMAT_VHD, options: distro.ImageOptions{VHDSubformat: osbuild.VPCSubformatDynamic}, want: content},
+		{name: "compressed fixed vhd", format: platform.FORMAT_VHD, compression: "xz", want: content / 3},
+		{name: "gce", format: platform.FORMAT_GCE, want: content / 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, distro.EstimateActualSize(tc.format, tc.compression, virtual, content, tc.options))
+		})
+	}
+
+	// the content is at most the virtual size
+	assert.Equal(t, virtual, distro.EstimateActualSize(platform.FORMAT_RAW, "", virtual, 2*virtual, distro.ImageOptions{}))
+}
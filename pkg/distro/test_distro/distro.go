@@ -219,6 +219,22 @@ func (t *TestImageType) Exports() []string {
 	return distro.ExportsFallback()
 }
 
+func (t *TestImageType) Outputs(options distro.ImageOptions) []distro.Output {
+	return []distro.Output{{Pipeline: t.Exports()[0], Filename: t.Filename(), MIMEType: t.MIMEType()}}
+}
+
+func (t *TestImageType) Validate(b *blueprint.Blueprint, options distro.ImageOptions) []error {
+	return nil
+}
+
+func (t *TestImageType) MinimumSize(b *blueprint.Blueprint) (uint64, error) {
+	return 0, nil
+}
+
+func (t *TestImageType) SizeEstimate(b *blueprint.Blueprint, options distro.ImageOptions) (uint64, uint64, error) {
+	return 0, 0, nil
+}
+
 func (t *TestImageType) Manifest(b *blueprint.Blueprint, options distro.ImageOptions, repos []rpmmd.RepoConfig, seed int64) (*manifest.Manifest, []string, error) {
 	var bpPkgs []string
 	if b != nil {
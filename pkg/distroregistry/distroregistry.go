@@ -101,6 +101,12 @@ func (r *Registry) GetDistro(name string) distro.Distro {
 	return d
 }
 
+// List returns the names of all supported distributions, sorted
+// alphabetically. It is the List of the default Registry.
+func List() []string {
+	return NewDefault().List()
+}
+
 // List returns the names of all distros in a Registry, sorted alphabetically.
 func (r *Registry) List() []string {
 	list := []string{}
@@ -24,6 +24,15 @@ func TestRegistry_List(t *testing.T) {
 	require.ElementsMatch(t, expected, distros.List(), "unexpected list of distros")
 }
 
+func TestList(t *testing.T) {
+	list := List()
+
+	require.IsIncreasing(t, list)
+	for _, name := range []string{"fedora-37", "fedora-38", "fedora-39", "fedora-40", "rhel-7", "rhel-8", "rhel-810", "centos-8", "rhel-9", "rhel-94", "centos-9"} {
+		require.Contains(t, list, name)
+	}
+}
+
 func TestRegistry_GetDistro(t *testing.T) {
 	distros := NewDefault()
 
@@ -33,6 +33,9 @@ type AnacondaOSTreeInstaller struct {
 
 	Commit ostree.SourceSpec
 
+	// Installer settings and user content for the kickstart file
+	Kickstart *manifest.Kickstart
+
 	Filename string
 
 	AdditionalDracutModules   []string
@@ -115,6 +118,7 @@ func (img *AnacondaOSTreeInstaller) InstantiateManifest(m *manifest.Manifest,
 
 	// For ostree installers, always put the kickstart file in the root of the ISO
 	isoTreePipeline.KSPath = kspath
+	isoTreePipeline.Kickstart = img.Kickstart
 	isoTreePipeline.PayloadPath = "/ostree/repo"
 
 	isoTreePipeline.OSTreeCommitSource = &img.Commit
@@ -35,6 +35,10 @@ type AnacondaTarInstaller struct {
 	// default /usr/share/anaconda/interactive-defaults.ks in the rootfs.
 	ISORootKickstart bool
 
+	// Installer settings and user content for the kickstart file. Requires
+	// ISORootKickstart.
+	Kickstart *manifest.Kickstart
+
 	SquashfsCompression string
 
 	ISOLabelTempl string
@@ -144,6 +148,7 @@ func (img *AnacondaTarInstaller) InstantiateManifest(m *manifest.Manifest,
 	isoTreePipeline.PayloadPath = tarPath
 	if img.ISORootKickstart {
 		isoTreePipeline.KSPath = kspath
+		isoTreePipeline.Kickstart = img.Kickstart
 	}
 
 	isoTreePipeline.SquashfsCompression = img.SquashfsCompression
@@ -27,6 +27,25 @@ type DiskImage struct {
 	Compression      string
 	ForceSize        *bool
 	PartTool         osbuild.PartTool
+	VMDKSubformat    osbuild.VMDKSubformat
+	VPCSubformat     osbuild.VPCSubformat
+	QCOW2Compression osbuild.QCOW2CompressionType
+	// QCOW2ClusterSize and QCOW2Preallocation of qcow2 images, the qemu-img
+	// defaults if unset
+	QCOW2ClusterSize   uint64
+	QCOW2Preallocation osbuild.QCOW2Preallocation
+
+	// OVFVirtualMachine configures the virtual machine described by the OVF
+	// descriptor of ova images
+	OVFVirtualMachine *osbuild.OVFVirtualMachineOptions
+
+	// Proxmox, if set, exports the configuration of a Proxmox VE virtual
+	// machine with these settings for the qcow2 or raw disk image too.
+	Proxmox *manifest.ProxmoxVMOptions
+
+	// RawFilename, if set, is the filename of the raw disk image, which is
+	// then exported too, e.g. next to the qcow2 image converted from it.
+	RawFilename string
 
 	NoBLS     bool
 	OSProduct string
@@ -67,6 +86,9 @@ func (img *DiskImage) InstantiateManifest(m *manifest.Manifest,
 
 	rawImagePipeline := manifest.NewRawImage(buildPipeline, osPipeline)
 	rawImagePipeline.PartTool = img.PartTool
+	if img.RawFilename != "" {
+		rawImagePipeline.SetFilename(img.RawFilename)
+	}
 
 	var imagePipeline manifest.FilePipeline
 	switch img.Platform.GetImageFormat() {
@@ -75,21 +97,46 @@ func (img *DiskImage) InstantiateManifest(m *manifest.Manifest,
 	case platform.FORMAT_QCOW2:
 		qcow2Pipeline := manifest.NewQCOW2(buildPipeline, rawImagePipeline)
 		qcow2Pipeline.Compat = img.Platform.GetQCOW2Compat()
+		qcow2Pipeline.CompressionType = img.QCOW2Compression
+		qcow2Pipeline.ClusterSize = img.QCOW2ClusterSize
+		qcow2Pipeline.Preallocation = img.QCOW2Preallocation
 		imagePipeline = qcow2Pipeline
 	case platform.FORMAT_VHD:
 		vpcPipeline := manifest.NewVPC(buildPipeline, rawImagePipeline)
 		vpcPipeline.ForceSize = img.ForceSize
+		vpcPipeline.Subformat = img.VPCSubformat
 		imagePipeline = vpcPipeline
 	case platform.FORMAT_VMDK:
-		imagePipeline = manifest.NewVMDK(buildPipeline, rawImagePipeline)
+		vmdkPipeline := manifest.NewVMDK(buildPipeline, rawImagePipeline)
+		vmdkPipeline.Subformat = img.VMDKSubformat
+		imagePipeline = vmdkPipeline
 	case platform.FORMAT_OVA:
 		vmdkPipeline := manifest.NewVMDK(buildPipeline, rawImagePipeline)
 		ovfPipeline := manifest.NewOVF(buildPipeline, vmdkPipeline)
+		ovfPipeline.VirtualMachine = img.OVFVirtualMachine
 		tarPipeline := manifest.NewTar(buildPipeline, ovfPipeline, "archive")
 		tarPipeline.Format = osbuild.TarArchiveFormatUstar
 		tarPipeline.RootNode = osbuild.TarRootNodeOmit
 		tarPipeline.SetFilename(img.Filename)
 		imagePipeline = tarPipeline
+	case platform.FORMAT_VAGRANT_LIBVIRT:
+		qcow2Pipeline := manifest.NewQCOW2(buildPipeline, rawImagePipeline)
+		qcow2Pipeline.Compat = img.Platform.GetQCOW2Compat()
+		vagrantPipeline := manifest.NewVagrant(buildPipeline, qcow2Pipeline, manifest.VagrantProviderLibvirt)
+		vagrantPipeline.VirtualSize = img.PartitionTable.Size
+		tarPipeline := manifest.NewTar(buildPipeline, vagrantPipeline, "archive")
+		tarPipeline.RootNode = osbuild.TarRootNodeOmit
+		tarPipeline.SetFilename(img.Filename)
+		imagePipeline = tarPipeline
+	case platform.FORMAT_VAGRANT_VIRTUALBOX:
+		vmdkPipeline := manifest.NewVMDK(buildPipeline, rawImagePipeline)
+		vmdkPipeline.Subformat = img.VMDKSubformat
+		vagrantPipeline := manifest.NewVagrant(buildPipeline, vmdkPipeline, manifest.VagrantProviderVirtualBox)
+		vagrantPipeline.VirtualSize = img.PartitionTable.Size
+		tarPipeline := manifest.NewTar(buildPipeline, vagrantPipeline, "archive")
+		tarPipeline.RootNode = osbuild.TarRootNodeOmit
+		tarPipeline.SetFilename(img.Filename)
+		imagePipeline = tarPipeline
 	case platform.FORMAT_GCE:
 		// NOTE(akoutsou): temporary workaround; filename required for GCP
 		// TODO: define internal raw filename on image type
@@ -107,6 +154,17 @@ func (img *DiskImage) InstantiateManifest(m *manifest.Manifest,
 		panic("invalid image format for image kind")
 	}
 
+	if img.RawFilename != "" && imagePipeline != manifest.FilePipeline(rawImagePipeline) {
+		rawImagePipeline.Export()
+	}
+
+	if img.Proxmox != nil {
+		proxmoxPipeline := manifest.NewProxmox(buildPipeline, imagePipeline)
+		proxmoxPipeline.VM = *img.Proxmox
+		proxmoxPipeline.UEFI = img.Platform.GetBIOSPlatform() == ""
+		proxmoxPipeline.Export()
+	}
+
 	switch img.Compression {
 	case "xz":
 		xzPipeline := manifest.NewXZ(buildPipeline, imagePipeline)
@@ -31,6 +31,10 @@ type OSTreeArchive struct {
 	Filename  string
 
 	InstallWeakDeps bool
+
+	// BootContainer makes the image an ostree native container that can be
+	// installed with bootc, instead of a tar archive of the commit repository.
+	BootContainer bool
 }
 
 func NewOSTreeArchive(ref string) *OSTreeArchive {
@@ -59,6 +63,17 @@ func (img *OSTreeArchive) InstantiateManifest(m *manifest.Manifest,
 	ostreeCommitPipeline := manifest.NewOSTreeCommit(buildPipeline, osPipeline, img.OSTreeRef)
 	ostreeCommitPipeline.OSVersion = img.OSVersion
 
+	if img.BootContainer {
+		encapsulatePipeline := manifest.NewOSTreeEncapsulate(buildPipeline, ostreeCommitPipeline, "ostree-encapsulate")
+		encapsulatePipeline.SetFilename(img.Filename)
+		encapsulatePipeline.Cmd = []string{"/sbin/init"}
+		encapsulatePipeline.Labels = []string{
+			"containers.bootc=1",
+			"ostree.bootable=true",
+		}
+		return encapsulatePipeline.Export(), nil
+	}
+
 	tarPipeline := manifest.NewTar(buildPipeline, ostreeCommitPipeline, "commit-archive")
 	tarPipeline.SetFilename(img.Filename)
 	artifact := tarPipeline.Export()
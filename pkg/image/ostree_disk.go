@@ -70,6 +70,7 @@ func baseRawOstreeImage(img *OSTreeDiskImage, m *manifest.Manifest, buildPipelin
 	// other image types (e.g. live) pass the workload to the pipeline.
 	osPipeline.EnabledServices = img.Workload.GetServices()
 	osPipeline.DisabledServices = img.Workload.GetDisabledServices()
+	osPipeline.MaskedServices = img.Workload.GetMaskedServices()
 
 	return manifest.NewRawOStreeImage(buildPipeline, osPipeline, img.Platform)
 }
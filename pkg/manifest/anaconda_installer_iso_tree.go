@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path"
 
+	"github.com/osbuild/images/internal/fsnode"
 	"github.com/osbuild/images/internal/users"
 	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/disk"
@@ -53,8 +54,41 @@ type AnacondaInstallerISOTree struct {
 
 	// Enable ISOLinux stage
 	ISOLinux bool
+
+	// Installer settings and user content merged into the kickstart file.
+	// Only used when KSPath is set.
+	Kickstart *Kickstart
+
+	files []*fsnode.File
 }
 
+// Kickstart holds the installer settings of the kickstart file.
+type Kickstart struct {
+	// Disk to wipe and install the system to
+	TargetDisk string
+
+	// Reboot and eject the installation media when the installation finishes
+	Reboot bool
+
+	// Language, keyboard layout and timezone of the installation
+	Language string
+	Keyboard string
+	Timezone string
+
+	// Install without prompting, failing on missing settings instead. The
+	// language, keyboard layout and timezone default to en_US.UTF-8, us and
+	// UTC.
+	Unattended bool
+
+	// Content appended to the generated kickstart file. The generated
+	// kickstart is then written next to it and included at the top.
+	UserFile string
+}
+
+// kickstartBasePath is the location of the generated kickstart file in the
+// ISO when a user kickstart file includes it.
+const kickstartBasePath = "/osbuild-base.ks"
+
 func NewAnacondaInstallerISOTree(buildPipeline *Build, anacondaPipeline *AnacondaInstaller, rootfsPipeline *ISORootfsImg, bootTreePipeline *EFIBootTree) *AnacondaInstallerISOTree {
 
 	// the three pipelines should all belong to the same manifest
@@ -122,6 +156,86 @@ func (p *AnacondaInstallerISOTree) serializeStart(_ []rpmmd.PackageSpec, _ []con
 
 func (p *AnacondaInstallerISOTree) serializeEnd() {
 	p.ostreeCommitSpec = nil
+	p.files = nil
+}
+
+func (p *AnacondaInstallerISOTree) getInline() []string {
+	inlineData := []string{}
+
+	// inline data for the user kickstart file
+	for _, file := range p.files {
+		inlineData = append(inlineData, string(file.Data()))
+	}
+
+	return inlineData
+}
+
+// kickstartStages returns the stages that write the kickstart file with the
+// given options, merged with the Kickstart settings.
+func (p *AnacondaInstallerISOTree) kickstartStages(options *osbuild.KickstartStageOptions) []*osbuild.Stage {
+	if p.Kickstart == nil {
+		return []*osbuild.Stage{osbuild.NewKickstartStage(options)}
+	}
+
+	if disk := p.Kickstart.TargetDisk; disk != "" {
+		options.ZeroMBR = true
+		options.ClearPart = &osbuild.ClearPartOptions{
+			Drives:    []string{disk},
+			Initlabel: true,
+		}
+		options.IgnoreDisk = &osbuild.IgnoreDiskOptions{
+			OnlyUse: []string{disk},
+		}
+		options.AutoPart = &osbuild.AutoPartOptions{
+			Type:   "plain",
+			FSType: "xfs",
+			NoHome: true,
+		}
+	}
+	if p.Kickstart.Reboot {
+		options.Reboot = &osbuild.RebootOptions{Eject: true}
+	}
+	options.Lang = p.Kickstart.Language
+	options.Keyboard = p.Kickstart.Keyboard
+	options.Timezone = p.Kickstart.Timezone
+	if p.Kickstart.Unattended {
+		options.DisplayMode = "cmdline"
+		if options.Lang == "" {
+			options.Lang = "en_US.UTF-8"
+		}
+		if options.Keyboard == "" {
+			options.Keyboard = "us"
+		}
+		if options.Timezone == "" {
+			options.Timezone = "UTC"
+		}
+	}
+
+	if p.Kickstart.UserFile == "" {
+		return []*osbuild.Stage{osbuild.NewKickstartStage(options)}
+	}
+
+	// The kickstart stage can't write arbitrary content, so the generated
+	// kickstart is included by the user file from the root of the ISO
+	userPath := options.Path
+	options.Path = kickstartBasePath
+	stages := []*osbuild.Stage{osbuild.NewKickstartStage(options)}
+
+	data := fmt.Sprintf("%%include %s\n%s", path.Join("/run/install/repo", kickstartBasePath), p.Kickstart.UserFile)
+	file, err := fsnode.NewFile(userPath, nil, nil, nil, []byte(data))
+	if err != nil {
+		panic(fmt.Sprintf("failed to create the user kickstart file: %v", err))
+	}
+	p.files = []*fsnode.File{file}
+	return append(stages, osbuild.GenFileNodesStages(p.files)...)
+}
+
+func (p *AnacondaInstallerISOTree) getInputs() []string {
+	inputs := []string{p.anacondaPipeline.Name(), p.rootfsPipeline.Name(), p.bootTreePipeline.Name()}
+	if p.OSPipeline != nil {
+		inputs = append(inputs, p.OSPipeline.Name())
+	}
+	return inputs
 }
 
 func (p *AnacondaInstallerISOTree) serialize() osbuild.Pipeline {
@@ -278,7 +392,7 @@ func (p *AnacondaInstallerISOTree) serialize() osbuild.Pipeline {
 			panic("failed to create kickstartstage options")
 		}
 
-		pipeline.AddStage(osbuild.NewKickstartStage(kickstartOptions))
+		pipeline.AddStages(p.kickstartStages(kickstartOptions)...)
 	}
 
 	if p.OSPipeline != nil {
@@ -293,7 +407,7 @@ func (p *AnacondaInstallerISOTree) serialize() osbuild.Pipeline {
 				panic("failed to create kickstartstage options")
 			}
 
-			pipeline.AddStage(osbuild.NewKickstartStage(kickstartOptions))
+			pipeline.AddStages(p.kickstartStages(kickstartOptions)...)
 		}
 	}
 
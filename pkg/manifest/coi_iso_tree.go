@@ -59,6 +59,10 @@ func NewCoreOSISOTree(
 	return p
 }
 
+func (p *CoreOSISOTree) getInputs() []string {
+	return []string{p.payloadPipeline.Name(), p.coiPipeline.Name(), p.bootTreePipeline.Name()}
+}
+
 func (p *CoreOSISOTree) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
@@ -34,6 +34,10 @@ func (p *OSTreeCommit) getBuildPackages(Distro) []string {
 	return packages
 }
 
+func (p *OSTreeCommit) getInputs() []string {
+	return []string{p.treePipeline.Name()}
+}
+
 func (p *OSTreeCommit) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
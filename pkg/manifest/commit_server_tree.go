@@ -99,6 +99,10 @@ func (p *OSTreeCommitServer) serializeEnd() {
 	p.packageSpecs = nil
 }
 
+func (p *OSTreeCommitServer) getInputs() []string {
+	return []string{p.commitPipeline.Name()}
+}
+
 func (p *OSTreeCommitServer) serialize() osbuild.Pipeline {
 	if len(p.packageSpecs) == 0 {
 		panic("serialization not started")
@@ -0,0 +1,32 @@
+package manifest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// ExportDOT writes the pipeline graph of the manifest (see
+// Manifest.PipelineGraph) to w in the graphviz DOT language. Pipelines are
+// nodes, in the order they are built, and their dependencies are edges from
+// the pipeline that is used to the one that uses it. Build roots are dashed.
+// The output only depends on the structure of the manifest.
+func ExportDOT(w io.Writer, m Manifest) error {
+	pipelines, edges := m.PipelineGraph()
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "digraph manifest {")
+	fmt.Fprintln(b, "\trankdir=LR;")
+	for _, pipeline := range pipelines {
+		fmt.Fprintf(b, "\t%q;\n", pipeline.Name())
+	}
+	for _, edge := range edges {
+		if edge.Build {
+			fmt.Fprintf(b, "\t%q -> %q [style=dashed];\n", edge.From, edge.To)
+		} else {
+			fmt.Fprintf(b, "\t%q -> %q;\n", edge.From, edge.To)
+		}
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
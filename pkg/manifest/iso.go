@@ -43,6 +43,10 @@ func (p *ISO) getBuildPackages(Distro) []string {
 	}
 }
 
+func (p *ISO) getInputs() []string {
+	return []string{p.treePipeline.Name()}
+}
+
 func (p *ISO) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
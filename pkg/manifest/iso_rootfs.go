@@ -24,6 +24,10 @@ func NewISORootfsImg(buildPipeline *Build, installerPipeline Pipeline) *ISORootf
 	return p
 }
 
+func (p *ISORootfsImg) getInputs() []string {
+	return []string{p.installerPipeline.Name()}
+}
+
 func (p *ISORootfsImg) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
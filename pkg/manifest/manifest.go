@@ -13,8 +13,12 @@
 package manifest
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"strings"
 
+	"github.com/osbuild/images/internal/fsnode"
 	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/ostree"
@@ -57,6 +61,24 @@ func (m *OSBuildManifest) UnmarshalJSON(payload []byte) error {
 	return nil
 }
 
+// ContentHash returns the hex encoded SHA-256 digest of the manifest. Equal
+// manifests have the same content hash.
+func (m OSBuildManifest) ContentHash() string {
+	digest := sha256.Sum256(m)
+	return hex.EncodeToString(digest[:])
+}
+
+// ContentAddressedFilename returns the filename with the content hash
+// appended to its name, before the extension, e.g. disk-<hash>.qcow2 for
+// disk.qcow2.
+func ContentAddressedFilename(filename, hash string) string {
+	name, ext, hasExt := strings.Cut(filename, ".")
+	if !hasExt {
+		return filename + "-" + hash
+	}
+	return name + "-" + hash + "." + ext
+}
+
 // Manifest represents a manifest initialised with all the information required
 // to generate the pipelines but no content. The content type sources
 // (PackageSetChains, ContainerSourceSpecs, OSTreeSourceSpecs) must be
@@ -71,6 +93,46 @@ type Manifest struct {
 	// generate. It is used for determining package names that differ between
 	// different distributions and version.
 	Distro Distro
+
+	// Progress is called when serializing the manifest starts. It is optional.
+	Progress ProgressFunc
+
+	// ContentAddressedFilenames appends the content hash of the manifest to
+	// the filenames of the exported files (see ContentAddressedFilename). The
+	// hash is the one of the manifest serialized with the static filenames,
+	// so it only depends on the content of the images.
+	ContentAddressedFilenames bool
+
+	// SourceDateEpoch is the Unix timestamp of all the pipelines, which fixes
+	// the timestamps of their trees for reproducible builds (see
+	// osbuild.Pipeline.SourceEpoch). It is optional.
+	SourceDateEpoch *int64
+
+	// NoWeakDeps disables the installation of weak dependencies in all the
+	// package sets of the image, overriding the ones of the pipelines. The
+	// build root keeps them, since it is not part of the image.
+	NoWeakDeps bool
+}
+
+// A Phase is a step of the generation of a manifest.
+type Phase string
+
+const (
+	PhaseResolvingPackageSets Phase = "resolving package sets"
+	PhaseBuildingPipelines    Phase = "building pipelines"
+	PhaseSerializing          Phase = "serializing"
+)
+
+// A ProgressFunc is called with each phase of the generation of a manifest
+// when it starts, e.g. to show a progress indicator. It does not affect the
+// generated manifest.
+type ProgressFunc func(phase Phase)
+
+// Report calls the function with the given phase, unless it is nil.
+func (f ProgressFunc) Report(phase Phase) {
+	if f != nil {
+		f(phase)
+	}
 }
 
 func New() Manifest {
@@ -89,6 +151,34 @@ func (m *Manifest) addPipeline(p Pipeline) {
 	m.pipelines = append(m.pipelines, p)
 }
 
+// An Edge is a dependency between two pipelines of a manifest. The To pipeline
+// uses the tree of the From pipeline as its build root, if Build is set, or as
+// an input of its stages.
+type Edge struct {
+	From  string
+	To    string
+	Build bool
+}
+
+// PipelineGraph returns the pipelines of the manifest, in the order they are
+// built, and the dependencies between them, e.g. to render a diagram of the
+// structure of the image. The pipelines of the edges are referred to by name.
+func (m Manifest) PipelineGraph() ([]Pipeline, []Edge) {
+	pipelines := make([]Pipeline, len(m.pipelines))
+	copy(pipelines, m.pipelines)
+
+	var edges []Edge
+	for _, pipeline := range m.pipelines {
+		if build := pipeline.BuildPipeline(); build != nil {
+			edges = append(edges, Edge{From: build.Name(), To: pipeline.Name(), Build: true})
+		}
+		for _, input := range pipeline.getInputs() {
+			edges = append(edges, Edge{From: input, To: pipeline.Name()})
+		}
+	}
+	return pipelines, edges
+}
+
 type PackageSelector func([]rpmmd.PackageSet) []rpmmd.PackageSet
 
 func (m Manifest) GetPackageSetChains() map[string][]rpmmd.PackageSet {
@@ -96,6 +186,11 @@ func (m Manifest) GetPackageSetChains() map[string][]rpmmd.PackageSet {
 
 	for _, pipeline := range m.pipelines {
 		if chain := pipeline.getPackageSetChain(m.Distro); chain != nil {
+			if _, isBuild := pipeline.(*Build); m.NoWeakDeps && !isBuild {
+				for idx := range chain {
+					chain[idx].InstallWeakDeps = false
+				}
+			}
 			chains[pipeline.Name()] = chain
 		}
 	}
@@ -103,6 +198,25 @@ func (m Manifest) GetPackageSetChains() map[string][]rpmmd.PackageSet {
 	return chains
 }
 
+// GetRepositories returns the repositories used to depsolve the package sets
+// of all the pipelines of the manifest, in the order they first appear.
+// Repositories with the same configuration are only returned once.
+func (m Manifest) GetRepositories() []rpmmd.RepoConfig {
+	repos := make([]rpmmd.RepoConfig, 0)
+	seen := make(map[string]bool)
+	for _, pipeline := range m.pipelines {
+		for _, ps := range pipeline.getPackageSetChain(m.Distro) {
+			for _, repo := range ps.Repositories {
+				if hash := repo.Hash(); !seen[hash] {
+					seen[hash] = true
+					repos = append(repos, repo)
+				}
+			}
+		}
+	}
+	return repos
+}
+
 func (m Manifest) GetContainerSourceSpecs() map[string][]container.SourceSpec {
 	// Containers should only appear in the payload pipeline.
 	// Let's iterate over all pipelines to avoid assuming pipeline names, but
@@ -130,26 +244,55 @@ func (m Manifest) GetOSTreeSourceSpecs() map[string][]ostree.SourceSpec {
 }
 
 func (m Manifest) Serialize(packageSets map[string][]rpmmd.PackageSpec, containerSpecs map[string][]container.Spec, ostreeCommits map[string][]ostree.CommitSpec) (OSBuildManifest, error) {
+	m.Progress.Report(PhaseSerializing)
+
+	if !m.ContentAddressedFilenames {
+		return m.serialize(packageSets, containerSpecs, ostreeCommits)
+	}
+
+	static, err := m.serialize(packageSets, containerSpecs, ostreeCommits)
+	if err != nil {
+		return nil, err
+	}
+	hash := static.ContentHash()
+
+	// rename the exported files for this serialization only, so that
+	// serializing again hashes the same static manifest
+	for _, pipeline := range m.pipelines {
+		if filePipeline, ok := pipeline.(FilePipeline); ok && pipeline.getExport() {
+			filename := filePipeline.Filename()
+			filePipeline.SetFilename(ContentAddressedFilename(filename, hash))
+			defer filePipeline.SetFilename(filename)
+		}
+	}
+	return m.serialize(packageSets, containerSpecs, ostreeCommits)
+}
+
+func (m Manifest) serialize(packageSets map[string][]rpmmd.PackageSpec, containerSpecs map[string][]container.Spec, ostreeCommits map[string][]ostree.CommitSpec) (OSBuildManifest, error) {
 	pipelines := make([]osbuild.Pipeline, 0)
 	packages := make([]rpmmd.PackageSpec, 0)
 	commits := make([]ostree.CommitSpec, 0)
 	inline := make([]string, 0)
+	remoteFiles := make([]*fsnode.File, 0)
 	containers := make([]container.Spec, 0)
 	for _, pipeline := range m.pipelines {
 		pipeline.serializeStart(packageSets[pipeline.Name()], containerSpecs[pipeline.Name()], ostreeCommits[pipeline.Name()])
 	}
 	for _, pipeline := range m.pipelines {
 		commits = append(commits, pipeline.getOSTreeCommits()...)
-		pipelines = append(pipelines, pipeline.serialize())
+		serialized := pipeline.serialize()
+		serialized.SourceEpoch = m.SourceDateEpoch
+		pipelines = append(pipelines, serialized)
 		packages = append(packages, packageSets[pipeline.Name()]...)
 		inline = append(inline, pipeline.getInline()...)
+		remoteFiles = append(remoteFiles, pipeline.getRemoteFiles()...)
 		containers = append(containers, pipeline.getContainerSpecs()...)
 	}
 	for _, pipeline := range m.pipelines {
 		pipeline.serializeEnd()
 	}
 
-	sources, err := osbuild.GenSources(packages, commits, inline, containers)
+	sources, err := osbuild.GenSources(packages, commits, inline, containers, remoteFiles)
 	if err != nil {
 		return nil, err
 	}
@@ -163,6 +306,30 @@ func (m Manifest) Serialize(packageSets map[string][]rpmmd.PackageSpec, containe
 	)
 }
 
+// GetDiskSize returns the size in bytes of the partition table of the image,
+// i.e. the virtual size of disk images, or 0 if the image has no partition
+// table, e.g. for tarballs and ostree commits.
+func (m Manifest) GetDiskSize() uint64 {
+	for _, pipeline := range m.pipelines {
+		if osPipeline, ok := pipeline.(*OS); ok && osPipeline.PartitionTable != nil {
+			return osPipeline.PartitionTable.Size
+		}
+	}
+	return 0
+}
+
+// GetKernelName returns the name of the kernel package of the image, e.g.
+// kernel or kernel-rt, or an empty string if the image has no kernel, e.g. for
+// container images.
+func (m Manifest) GetKernelName() string {
+	for _, pipeline := range m.pipelines {
+		if osPipeline, ok := pipeline.(*OS); ok && osPipeline.KernelName != "" {
+			return osPipeline.KernelName
+		}
+	}
+	return ""
+}
+
 func (m Manifest) GetCheckpoints() []string {
 	checkpoints := []string{}
 	for _, p := range m.pipelines {
@@ -183,6 +350,18 @@ func (m Manifest) GetExports() []string {
 	return exports
 }
 
+// GetBuildPipelines returns the names of the build pipelines of the manifest,
+// which are the build roots of the other pipelines.
+func (m Manifest) GetBuildPipelines() []string {
+	builds := []string{}
+	for _, p := range m.pipelines {
+		if _, isBuild := p.(*Build); isBuild {
+			builds = append(builds, p.Name())
+		}
+	}
+	return builds
+}
+
 // filterRepos returns a list of repositories that specify the given pipeline
 // name in their PackageSets list in addition to any global repositories
 // (global repositories are ones that do not specify any PackageSets).
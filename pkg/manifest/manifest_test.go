@@ -0,0 +1,180 @@
+package manifest
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/platform"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/runner"
+)
+
+func TestContentAddressedFilename(t *testing.T) {
+	assert.Equal(t, "disk-abc.qcow2", ContentAddressedFilename("disk.qcow2", "abc"))
+	assert.Equal(t, "disk-abc.raw.xz", ContentAddressedFilename("disk.raw.xz", "abc"))
+	assert.Equal(t, "commit-abc", ContentAddressedFilename("commit", "abc"))
+}
+
+func TestOSBuildManifestContentHash(t *testing.T) {
+	m := OSBuildManifest(`{"version":"2"}`)
+	assert.Equal(t, m.ContentHash(), OSBuildManifest(`{"version":"2"}`).ContentHash())
+	assert.NotEqual(t, m.ContentHash(), OSBuildManifest(`{"version":"3"}`).ContentHash())
+	assert.Len(t, m.ContentHash(), 64)
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := Load(strings.NewReader(`{"version": 2, "manifest": {}}`))
+	assert.EqualError(t, err, "unsupported saved manifest version 2 (supported: 1)")
+
+	_, err = Load(strings.NewReader(`{"version": 1}`))
+	assert.EqualError(t, err, "saved manifest has no osbuild manifest")
+
+	_, err = Load(strings.NewReader(`{"version": 1, "manifest": {}, "pipelines": []}`))
+	assert.EqualError(t, err, `failed to parse saved manifest: json: unknown field "pipelines"`)
+}
+
+func TestLoadReformatted(t *testing.T) {
+	saved, err := Load(strings.NewReader(`{
+  "version": 1,
+  "manifest": {
+    "version": "2",
+    "pipelines": []
+  },
+  "checkpoints": [],
+  "exports": ["qcow2"]
+}`))
+	require.NoError(t, err)
+	assert.Equal(t, OSBuildManifest(`{"version":"2","pipelines":[]}`), saved.Manifest)
+	assert.Equal(t, saved.Manifest.ContentHash(), saved.ContentHash())
+	assert.Equal(t, []string{"qcow2"}, saved.Exports)
+}
+
+func TestSavedManifestSBOM(t *testing.T) {
+	origNow, origUUID := sbomNow, sbomUUID
+	sbomNow = func() time.Time { return time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC) }
+	sbomUUID = func() string { return "0b5c2f4e-9b1a-4f3e-8d6a-2c7e1f0a9b3d" }
+	defer func() { sbomNow, sbomUUID = origNow, origUUID }()
+
+	bash := rpmmd.PackageSpec{
+		Name:           "bash",
+		Version:        "5.2.15",
+		Release:        "3.fc38",
+		Arch:           "x86_64",
+		RemoteLocation: "https://example.com/bash-5.2.15-3.fc38.x86_64.rpm",
+		Checksum:       "sha256:0123456789abcdef",
+	}
+	saved := SavedManifest{
+		Version:        SavedManifestVersion,
+		Manifest:       OSBuildManifest(`{"version":"2","pipelines":[]}`),
+		BuildPipelines: []string{"build", "target-build"},
+		PackageSets: map[string][]rpmmd.PackageSpec{
+			"build":        {{Name: "dnf", Version: "4.16.2", Release: "1.fc38", Arch: "noarch"}},
+			"target-build": {{Name: "ostree", Version: "2023.6", Release: "1.fc38", Arch: "x86_64"}},
+			"os": {
+				bash,
+				{Name: "shadow-utils", Epoch: 2, Version: "4.13", Release: "6.fc38", Arch: "x86_64"},
+			},
+			"ostree-deployment": {bash},
+		},
+	}
+
+	data, err := saved.SBOM(SBOMFormatSPDXJSON)
+	require.NoError(t, err)
+
+	var doc spdxDocument
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+	assert.Equal(t, "2023-10-01T12:00:00Z", doc.CreationInfo.Created)
+	assert.Equal(t, "https://osbuild.org/spdxdocs/image-"+saved.ContentHash()+"-0b5c2f4e-9b1a-4f3e-8d6a-2c7e1f0a9b3d", doc.DocumentNamespace)
+	assert.Equal(t, []spdxPackage{
+		{
+			Name:             "bash",
+			SPDXID:           "SPDXRef-Package-1",
+			VersionInfo:      "5.2.15-3.fc38",
+			DownloadLocation: "https://example.com/bash-5.2.15-3.fc38.x86_64.rpm",
+			Checksums:        []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: "0123456789abcdef"}},
+			ExternalRefs:     []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: "pkg:rpm/bash@5.2.15-3.fc38?arch=x86_64"}},
+		},
+		{
+			Name:             "shadow-utils",
+			SPDXID:           "SPDXRef-Package-2",
+			VersionInfo:      "2:4.13-6.fc38",
+			DownloadLocation: "NOASSERTION",
+			ExternalRefs:     []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: "pkg:rpm/shadow-utils@4.13-6.fc38?arch=x86_64&epoch=2"}},
+		},
+	}, doc.Packages)
+	assert.Equal(t, []spdxRelationship{
+		{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: "SPDXRef-Package-1"},
+		{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: "SPDXRef-Package-2"},
+	}, doc.Relationships)
+
+	// the documents of the same manifest have different namespaces
+	sbomUUID = origUUID
+	var first, second spdxDocument
+	data, err = saved.SBOM(SBOMFormatSPDXJSON)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &first))
+	data, err = saved.SBOM(SBOMFormatSPDXJSON)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &second))
+	assert.NotEqual(t, first.DocumentNamespace, second.DocumentNamespace)
+
+	_, err = saved.SBOM("cyclonedx-json")
+	assert.EqualError(t, err, `unsupported SBOM format "cyclonedx-json" (supported: spdx-json)`)
+}
+
+// newTestQCOW2Manifest returns the manifest of a qcow2 image without content
+func newTestQCOW2Manifest() Manifest {
+	m := New()
+	build := NewBuild(&m, &runner.Fedora{Version: 38}, nil)
+	os := NewOS(&m, build, &platform.X86{BIOS: true}, nil)
+	image := NewRawImage(build, os)
+	NewQCOW2(build, image)
+	return m
+}
+
+func TestManifestPipelineGraph(t *testing.T) {
+	m := newTestQCOW2Manifest()
+	pipelines, edges := m.PipelineGraph()
+	var names []string
+	for _, pipeline := range pipelines {
+		names = append(names, pipeline.Name())
+	}
+	assert.Equal(t, []string{"build", "os", "image", "qcow2"}, names)
+	assert.Equal(t, []string{"build"}, m.GetBuildPipelines())
+	assert.Equal(t, []Edge{
+		{From: "build", To: "os", Build: true},
+		{From: "build", To: "image", Build: true},
+		{From: "os", To: "image"},
+		{From: "build", To: "qcow2", Build: true},
+		{From: "image", To: "qcow2"},
+	}, edges)
+}
+
+func TestExportDOT(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, ExportDOT(&b, newTestQCOW2Manifest()))
+	expected := `digraph manifest {
+	rankdir=LR;
+	"build";
+	"os";
+	"image";
+	"qcow2";
+	"build" -> "os" [style=dashed];
+	"build" -> "image" [style=dashed];
+	"os" -> "image";
+	"build" -> "qcow2" [style=dashed];
+	"image" -> "qcow2";
+}
+`
+	assert.Equal(t, expected, b.String())
+
+	var again strings.Builder
+	require.NoError(t, ExportDOT(&again, newTestQCOW2Manifest()))
+	assert.Equal(t, b.String(), again.String())
+}
@@ -35,6 +35,10 @@ func NewOCIContainer(buildPipeline *Build, treePipeline TreePipeline) *OCIContai
 	return p
 }
 
+func (p *OCIContainer) getInputs() []string {
+	return []string{p.treePipeline.Name()}
+}
+
 func (p *OCIContainer) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
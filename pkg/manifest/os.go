@@ -2,12 +2,15 @@ package manifest
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/internal/environment"
 	"github.com/osbuild/images/internal/fsnode"
+	"github.com/osbuild/images/internal/ignition"
 	"github.com/osbuild/images/internal/shell"
 	"github.com/osbuild/images/internal/users"
 	"github.com/osbuild/images/internal/workload"
@@ -84,9 +87,51 @@ type OSCustomizations struct {
 
 	SELinuxForceRelabel *bool
 
+	// SELinux booleans to set persistently and paths of policy modules in
+	// the tree to install
+	SELinuxBooleans map[string]bool
+	SELinuxModules  []string
+
+	// Size of the swap file to create at /swapfile on first boot, no swap
+	// file is created if zero
+	SwapFileSize uint64
+
+	// DNF module streams to enable, as name:stream or name:stream/profile
+	EnabledModules []string
+
+	// Enable FIPS mode: set the FIPS crypto policy, add the fips module to
+	// the initramfs, and boot the kernel with fips=1
+	FIPS bool
+
+	// Install systemd-boot instead of grub2 as the UEFI boot loader. Only
+	// supported on UEFI-only platforms. systemd-boot only reads the EFI
+	// system partition, which must therefore be mounted at /boot, where the
+	// kernels and their boot loader entries are installed.
+	SystemdBoot bool
+
+	// Mount the root filesystem read-only, with /etc, /home and /root as
+	// overlays whose changes are stored in /var, which must be a separate
+	// filesystem, and /tmp as a tmpfs
+	ReadOnlyRoot bool
+
+	// Entries of /etc/fstab that are added after the mounts of the partition
+	// table, e.g. for network shares
+	ExtraMounts []*osbuild.FSTabEntry
+
+	// Run ignition on the first boot with the given platform ID, e.g. qemu
+	// or metal. Requires a kernel and grub2 as the boot loader.
+	IgnitionPlatform string
+
+	// Ignition config that is embedded in the initramfs and used on the
+	// first boot if the platform provides none
+	IgnitionEmbedded *ignition.EmbeddedOptions
+
 	// Do not install documentation
 	ExcludeDocs bool
 
+	// Only install the translations of these languages, e.g. en_US or de
+	InstallLangs []string
+
 	Groups []users.Group
 	Users  []users.User
 
@@ -131,6 +176,14 @@ type OSCustomizations struct {
 	// Custom directories and files to create in the image
 	Directories []*fsnode.Directory
 	Files       []*fsnode.File
+
+	// UpdateCATrust regenerates the system-wide trust store after the custom
+	// files are created, e.g. for CA certificates added as anchors
+	UpdateCATrust bool
+
+	// State of /etc/machine-id, one of the osbuild.MachineIdFirstBoot values.
+	// The machine ID of the tree is kept as is if empty.
+	MachineIDFirstBoot string
 }
 
 // OS represents the filesystem tree of the target image. This roughly
@@ -192,8 +245,11 @@ func NewOS(m *Manifest,
 	return p
 }
 
-func (p *OS) getPackageSetChain(Distro) []rpmmd.PackageSet {
+func (p *OS) getPackageSetChain(distro Distro) []rpmmd.PackageSet {
 	packages := p.platform.GetPackages()
+	if p.SystemdBoot {
+		packages = systemdBootPackages(packages)
+	}
 
 	if p.KernelName != "" {
 		packages = append(packages, p.KernelName)
@@ -218,10 +274,25 @@ func (p *OS) getPackageSetChain(Distro) []rpmmd.PackageSet {
 		packages = append(packages, fmt.Sprintf("selinux-policy-%s", p.SElinux))
 	}
 
+	if len(p.SELinuxBooleans) > 0 || len(p.SELinuxModules) > 0 {
+		packages = append(packages, "policycoreutils")
+	}
+
 	if p.OpenSCAPConfig != nil {
 		packages = append(packages, "openscap-scanner", "scap-security-guide")
 	}
 
+	if p.FIPS {
+		packages = append(packages, "crypto-policies-scripts")
+		if distro == DISTRO_EL8 {
+			packages = append(packages, "dracut-fips")
+		}
+	}
+
+	if p.IgnitionPlatform != "" {
+		packages = append(packages, "ignition")
+	}
+
 	// Make sure the right packages are included for subscriptions
 	// rhc always uses insights, and depends on subscription-manager
 	// non-rhc uses subscription-manager and optionally includes Insights
@@ -236,12 +307,29 @@ func (p *OS) getPackageSetChain(Distro) []rpmmd.PackageSet {
 
 	osRepos := append(p.repos, p.ExtraBaseRepos...)
 
+	// the exclusions and the weak dependencies of the workload apply to the
+	// whole OS, so that a package of the image type doesn't pull in an
+	// excluded package either
+	exclude := p.ExcludeBasePackages
+	installWeakDeps := p.InstallWeakDeps
+	workloadWeakDeps := false
+	if p.Workload != nil {
+		if workloadExclude := p.Workload.GetExcludePackages(); len(workloadExclude) > 0 {
+			exclude = append(append([]string{}, exclude...), workloadExclude...)
+		}
+		if weakDeps := p.Workload.GetInstallWeakDeps(); weakDeps != nil {
+			installWeakDeps = *weakDeps
+			workloadWeakDeps = *weakDeps
+		}
+	}
+
 	chain := []rpmmd.PackageSet{
 		{
 			Include:         append(packages, p.ExtraBasePackages...),
-			Exclude:         p.ExcludeBasePackages,
+			Exclude:         exclude,
 			Repositories:    osRepos,
-			InstallWeakDeps: p.InstallWeakDeps,
+			InstallWeakDeps: installWeakDeps,
+			EnabledModules:  p.EnabledModules,
 		},
 	}
 
@@ -249,8 +337,11 @@ func (p *OS) getPackageSetChain(Distro) []rpmmd.PackageSet {
 		workloadPackages := p.Workload.GetPackages()
 		if len(workloadPackages) > 0 {
 			chain = append(chain, rpmmd.PackageSet{
-				Include:      workloadPackages,
-				Repositories: append(osRepos, p.Workload.GetRepos()...),
+				Include:         workloadPackages,
+				Exclude:         p.Workload.GetExcludePackages(),
+				Repositories:    append(osRepos, p.Workload.GetRepos()...),
+				InstallWeakDeps: workloadWeakDeps,
+				EnabledModules:  p.EnabledModules,
 			})
 		}
 	}
@@ -382,11 +473,19 @@ func (p *OS) serialize() osbuild.Pipeline {
 		}
 		rpmOptions.Exclude.Docs = true
 	}
+	rpmOptions.InstallLangs = p.InstallLangs
 	rpmOptions.GPGKeysFromTree = p.GPGKeyFiles
 	if p.OSTreeRef != "" {
 		rpmOptions.OSTreeBooted = common.ToPtr(true)
 		rpmOptions.DBPath = "/usr/share/rpm"
 	}
+	if configFile := p.kernelInstallConfigFile(); configFile != nil {
+		// kernel-install reads its layout when the kernel is installed
+		pipeline.AddStage(osbuild.NewMkdirStage(&osbuild.MkdirStageOptions{
+			Paths: []osbuild.MkdirStagePath{{Path: filepath.Dir(configFile.Path()), Parents: true, ExistOk: true}},
+		}))
+		pipeline.AddStages(osbuild.GenFileNodesStages([]*fsnode.File{configFile})...)
+	}
 	pipeline.AddStage(osbuild.NewRPMStage(rpmOptions, osbuild.NewRpmStageSourceFilesInputs(p.packageSpecs)))
 
 	if !p.NoBLS {
@@ -493,6 +592,71 @@ func (p *OS) serialize() osbuild.Pipeline {
 		pipeline.AddStage(osbuild.NewDracutConfStage(dracutConfConfig))
 	}
 
+	if p.FIPS {
+		// same changes as fips-mode-setup --enable, except for the
+		// bootloader configuration, which is generated below
+		pipeline.AddStage(osbuild.NewDracutConfStage(&osbuild.DracutConfStageOptions{
+			Filename: "40-fips.conf",
+			Config: osbuild.DracutConfigFile{
+				AddModules: []string{"fips"},
+			},
+		}))
+		pipeline.AddStage(osbuild.NewUpdateCryptoPoliciesStage(&osbuild.UpdateCryptoPoliciesStageOptions{
+			Policy: "FIPS",
+		}))
+		if p.KernelName != "" {
+			pipeline.AddStage(osbuild.NewDracutStage(&osbuild.DracutStageOptions{
+				Kernel:     []string{p.kernelVer},
+				AddModules: []string{"fips"},
+			}))
+		}
+	}
+
+	if p.ReadOnlyRoot {
+		// the initramfs mounts the /etc overlay
+		pipeline.AddStage(osbuild.NewDracutConfStage(&osbuild.DracutConfStageOptions{
+			Filename: "40-readonly-root.conf",
+			Config: osbuild.DracutConfigFile{
+				AddDrivers: []string{"overlay"},
+			},
+		}))
+		if p.KernelName != "" {
+			pipeline.AddStage(osbuild.NewDracutStage(&osbuild.DracutStageOptions{
+				Kernel:     []string{p.kernelVer},
+				AddDrivers: []string{"overlay"},
+			}))
+		}
+	}
+
+	if p.IgnitionPlatform != "" {
+		pipeline.AddStage(osbuild.NewDracutConfStage(&osbuild.DracutConfStageOptions{
+			Filename: "40-ignition.conf",
+			Config: osbuild.DracutConfigFile{
+				AddModules: []string{"ignition"},
+			},
+		}))
+		dracutOptions := &osbuild.DracutStageOptions{
+			Kernel:     []string{p.kernelVer},
+			AddModules: []string{"ignition"},
+		}
+		// the embedded config is only part of the initramfs of the image,
+		// the ones of kernel updates do not need it
+		if configFile := p.ignitionConfigFile(); configFile != nil {
+			pipeline.AddStages(osbuild.GenFileNodesStages([]*fsnode.File{configFile})...)
+			dracutOptions.Include = map[string]string{configFile.Path(): configFile.Path()}
+		}
+		pipeline.AddStage(osbuild.NewDracutStage(dracutOptions))
+		// /boot/ignition.firstboot makes grub2 set $ignition_firstboot, which
+		// is removed after the first boot. The systemd options are the same
+		// first boot workaround as the one of the ostree deployments.
+		pipeline.AddStage(osbuild.NewIgnitionStage(&osbuild.IgnitionStageOptions{
+			Network: []string{
+				"systemd.firstboot=off",
+				"systemd.condition-first-boot=true",
+			},
+		}))
+	}
+
 	for _, systemdUnitConfig := range p.SystemdUnit {
 		pipeline.AddStage(osbuild.NewSystemdUnitStage(systemdUnitConfig))
 	}
@@ -525,6 +689,10 @@ func (p *OS) serialize() osbuild.Pipeline {
 		pipeline.AddStage(osbuild.NewDNFConfigStage(dnfConfig))
 	}
 
+	for _, stage := range osbuild.NewDNFModuleEnableStages(p.EnabledModules) {
+		pipeline.AddStage(stage)
+	}
+
 	if p.DNFAutomaticConfig != nil {
 		pipeline.AddStage(osbuild.NewDNFAutomaticConfigStage(p.DNFAutomaticConfig))
 	}
@@ -602,18 +770,40 @@ func (p *OS) serialize() osbuild.Pipeline {
 	if pt := p.PartitionTable; pt != nil {
 		kernelOptions := osbuild.GenImageKernelOptions(p.PartitionTable)
 		kernelOptions = append(kernelOptions, p.KernelOptionsAppend...)
+		if p.FIPS {
+			kernelOptions = append(kernelOptions, osbuild.GenFIPSKernelOptions(pt)...)
+		}
+		if p.IgnitionPlatform != "" {
+			kernelOptions = append(kernelOptions, "ignition.platform.id="+p.IgnitionPlatform, "$ignition_firstboot")
+		}
 		if !p.KernelOptionsBootloader {
 			pipeline = prependKernelCmdlineStage(pipeline, strings.Join(kernelOptions, " "), pt)
 		}
 
-		pipeline.AddStage(osbuild.NewFSTabStage(osbuild.NewFSTabStageOptions(pt)))
+		fstabOptions := osbuild.NewFSTabStageOptions(pt)
+		if p.ReadOnlyRoot {
+			var paths []osbuild.MkdirStagePath
+			for _, dir := range fstabOptions.SetReadOnlyRoot(readOnlyRootOverlayDir) {
+				paths = append(paths, osbuild.MkdirStagePath{Path: dir, Parents: true, ExistOk: true})
+			}
+			pipeline.AddStage(osbuild.NewMkdirStage(&osbuild.MkdirStageOptions{Paths: paths}))
+		}
+		fstabOptions.FileSystems = append(fstabOptions.FileSystems, p.ExtraMounts...)
+		pipeline.AddStage(osbuild.NewFSTabStage(fstabOptions))
 
 		var bootloader *osbuild.Stage
 		switch p.platform.GetArch() {
 		case platform.ARCH_S390X:
 			bootloader = osbuild.NewZiplStage(new(osbuild.ZiplStageOptions))
 		default:
-			if p.NoBLS {
+			if p.SystemdBoot {
+				// the image pipeline copies systemd-boot to the EFI system
+				// partition, as the default boot loader of the disk
+				_, loader := systemdBootEFIFiles(p.platform.GetArch())
+				bootloader = osbuild.NewMkdirStage(&osbuild.MkdirStageOptions{
+					Paths: []osbuild.MkdirStagePath{{Path: filepath.Join("/boot", filepath.Dir(loader)), Parents: true, ExistOk: true}},
+				})
+			} else if p.NoBLS {
 				// BLS entries not supported: use grub2.legacy
 				id := "76a22bf4-f153-4541-b6c7-0332c0dfaeac"
 				product := osbuild.GRUB2Product{
@@ -651,6 +841,7 @@ func (p *OS) serialize() osbuild.Pipeline {
 
 					options.Config = cfg
 				}
+				options.Ignition = p.IgnitionPlatform != ""
 				if p.KernelOptionsBootloader {
 					options.WriteCmdLine = nil
 					if options.UEFI != nil {
@@ -693,8 +884,28 @@ func (p *OS) serialize() osbuild.Pipeline {
 		pipeline.AddStages(osbuild.GenFileNodesStages(p.Files)...)
 	}
 
+	if p.UpdateCATrust {
+		pipeline.AddStage(osbuild.NewUpdateCATrustStage())
+	}
+
+	if p.MachineIDFirstBoot != "" {
+		pipeline.AddStage(osbuild.NewMachineIdStage(&osbuild.MachineIdStageOptions{FirstBoot: p.MachineIDFirstBoot}))
+	}
+
+	if len(p.SELinuxBooleans) > 0 || len(p.SELinuxModules) > 0 {
+		// The policy can't be modified in the tree, so the modules and
+		// booleans are applied on first boot
+		addFirstBootCommands(&pipeline, selinuxFirstBootCommands(p.SELinuxModules, p.SELinuxBooleans))
+	}
+
+	if p.SwapFileSize > 0 {
+		// The swap file is allocated on first boot to not grow the image
+		addFirstBootCommands(&pipeline, swapFileFirstBootCommands(p.SwapFileSize))
+	}
+
 	enabledServices := []string{}
 	disabledServices := []string{}
+	maskedServices := []string{}
 	enabledServices = append(enabledServices, p.EnabledServices...)
 	disabledServices = append(disabledServices, p.DisabledServices...)
 	if p.Environment != nil {
@@ -703,12 +914,14 @@ func (p *OS) serialize() osbuild.Pipeline {
 	if p.Workload != nil {
 		enabledServices = append(enabledServices, p.Workload.GetServices()...)
 		disabledServices = append(disabledServices, p.Workload.GetDisabledServices()...)
+		maskedServices = append(maskedServices, p.Workload.GetMaskedServices()...)
 	}
 	if len(enabledServices) != 0 ||
-		len(disabledServices) != 0 || p.DefaultTarget != "" {
+		len(disabledServices) != 0 || len(maskedServices) != 0 || p.DefaultTarget != "" {
 		pipeline.AddStage(osbuild.NewSystemdStage(&osbuild.SystemdStageOptions{
 			EnabledServices:  enabledServices,
 			DisabledServices: disabledServices,
+			MaskedServices:   maskedServices,
 			DefaultTarget:    p.DefaultTarget,
 		}))
 	}
@@ -772,6 +985,51 @@ func prependKernelCmdlineStage(pipeline osbuild.Pipeline, kernelOptions string,
 	return pipeline
 }
 
+// systemdBootPackages replaces the grub2 and shim packages that the platform
+// requires for UEFI boot with systemd-boot.
+func systemdBootPackages(platformPackages []string) []string {
+	packages := make([]string, 0, len(platformPackages)+1)
+	for _, pkg := range platformPackages {
+		if strings.HasPrefix(pkg, "grub2-efi-") || strings.HasPrefix(pkg, "shim-") {
+			continue
+		}
+		packages = append(packages, pkg)
+	}
+	return append(packages, "systemd-boot-unsigned")
+}
+
+// kernelInstallConfigPath is the configuration of kernel-install(8).
+const kernelInstallConfigPath = "/etc/kernel/install.conf"
+
+// kernelInstallConfigFile returns the configuration of kernel-install for
+// systemd-boot, if any. The bls layout installs the kernels, their initramfs
+// and the boot loader entries to /boot, the EFI system partition, both when
+// the image is built and when the kernel is updated.
+func (p *OS) kernelInstallConfigFile() *fsnode.File {
+	if !p.SystemdBoot {
+		return nil
+	}
+	file, err := fsnode.NewFile(kernelInstallConfigPath, nil, nil, nil, []byte("layout=bls\nBOOT_ROOT=/boot\n"))
+	if err != nil {
+		panic(fmt.Sprintf("failed to create the kernel-install config file: %v", err))
+	}
+	return file
+}
+
+// systemdBootEFIFiles returns the path of the systemd-boot EFI binary in the
+// tree and the one of the default boot loader on the EFI system partition, to
+// which it is copied, for the given architecture.
+func systemdBootEFIFiles(arch platform.Arch) (string, string) {
+	switch arch {
+	case platform.ARCH_X86_64:
+		return "/usr/lib/systemd/boot/efi/systemd-bootx64.efi", "/EFI/BOOT/BOOTX64.EFI"
+	case platform.ARCH_AARCH64:
+		return "/usr/lib/systemd/boot/efi/systemd-bootaa64.efi", "/EFI/BOOT/BOOTAA64.EFI"
+	default:
+		panic(fmt.Sprintf("systemd-boot is not supported on %s", arch))
+	}
+}
+
 func usersFirstBootOptions(users []users.User) *osbuild.FirstBootStageOptions {
 	cmds := make([]string, 0, 3*len(users)+2)
 	// workaround for creating authorized_keys file for user
@@ -807,6 +1065,70 @@ func usersFirstBootOptions(users []users.User) *osbuild.FirstBootStageOptions {
 	return options
 }
 
+// selinuxFirstBootCommands returns the commands that install the given
+// policy modules and set the given booleans persistently.
+func selinuxFirstBootCommands(modules []string, booleans map[string]bool) []string {
+	cmds := make([]string, 0, len(modules)+1)
+	for _, module := range modules {
+		cmds = append(cmds, fmt.Sprintf("/usr/sbin/semodule -i %s", module))
+	}
+
+	if len(booleans) > 0 {
+		names := make([]string, 0, len(booleans))
+		for name := range booleans {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		values := make([]string, 0, len(names))
+		for _, name := range names {
+			value := "off"
+			if booleans[name] {
+				value = "on"
+			}
+			values = append(values, fmt.Sprintf("%s=%s", name, value))
+		}
+		cmds = append(cmds, fmt.Sprintf("/usr/sbin/setsebool -P %s", strings.Join(values, " ")))
+	}
+
+	return cmds
+}
+
+// Path of the swap file created on first boot
+const swapFilePath = "/swapfile"
+
+// Directory of the upper and work directories of the overlays of images with
+// a read-only root, e.g. /var/lib/readonly-root/etc/upper for /etc
+const readOnlyRootOverlayDir = "/var/lib/readonly-root"
+
+// swapFileFirstBootCommands returns the commands that create a swap file of
+// the given size, add it to fstab(5) and enable it.
+func swapFileFirstBootCommands(size uint64) []string {
+	return []string{
+		fmt.Sprintf("/usr/bin/fallocate -l %d %s", size, swapFilePath),
+		fmt.Sprintf("/usr/bin/chmod 0600 %s", swapFilePath),
+		fmt.Sprintf("/usr/sbin/mkswap %s", swapFilePath),
+		fmt.Sprintf("/usr/bin/sh -c 'echo \"%s none swap defaults 0 0\" >> /etc/fstab'", swapFilePath),
+		fmt.Sprintf("/usr/sbin/swapon %s", swapFilePath),
+	}
+}
+
+// addFirstBootCommands adds the commands to the first-boot stage of the
+// pipeline. Every first-boot stage replaces the service created by the
+// previous ones, so the commands are appended to the last existing stage
+// instead of adding a new one.
+func addFirstBootCommands(pipeline *osbuild.Pipeline, cmds []string) {
+	for idx := len(pipeline.Stages) - 1; idx >= 0; idx-- {
+		if options, ok := pipeline.Stages[idx].Options.(*osbuild.FirstBootStageOptions); ok {
+			options.Commands = append(options.Commands, cmds...)
+			return
+		}
+	}
+	pipeline.AddStage(osbuild.NewFirstBootStage(&osbuild.FirstBootStageOptions{
+		Commands: cmds,
+	}))
+}
+
 func (p *OS) Platform() platform.Platform {
 	return p.platform
 }
@@ -816,8 +1138,50 @@ func (p *OS) getInline() []string {
 
 	// inline data for custom files
 	for _, file := range p.Files {
+		if file.URL() != "" {
+			continue
+		}
 		inlineData = append(inlineData, string(file.Data()))
 	}
 
+	if configFile := p.ignitionConfigFile(); configFile != nil {
+		inlineData = append(inlineData, string(configFile.Data()))
+	}
+
+	if configFile := p.kernelInstallConfigFile(); configFile != nil {
+		inlineData = append(inlineData, string(configFile.Data()))
+	}
+
 	return inlineData
 }
+
+// ignitionConfigPath is the user config of ignition in the initramfs, which
+// it uses if the platform provides no config.
+const ignitionConfigPath = "/usr/lib/ignition/user.ign"
+
+// ignitionConfigFile returns the file of the embedded ignition config, if
+// any.
+func (p *OS) ignitionConfigFile() *fsnode.File {
+	if p.IgnitionPlatform == "" || p.IgnitionEmbedded == nil {
+		return nil
+	}
+	// the config may contain secrets
+	file, err := fsnode.NewFile(ignitionConfigPath, common.ToPtr(os.FileMode(0600)), "root", "root", []byte(p.IgnitionEmbedded.Config))
+	if err != nil {
+		panic(fmt.Sprintf("failed to create the ignition config file: %v", err))
+	}
+	return file
+}
+
+func (p *OS) getRemoteFiles() []*fsnode.File {
+	var remoteFiles []*fsnode.File
+
+	// custom files fetched at build time
+	for _, file := range p.Files {
+		if file.URL() != "" {
+			remoteFiles = append(remoteFiles, file)
+		}
+	}
+
+	return remoteFiles
+}
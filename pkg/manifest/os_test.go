@@ -116,6 +116,56 @@ func TestRhcInsightsCommands(t *testing.T) {
 	})
 }
 
+func TestSELinuxFirstBootCommands(t *testing.T) {
+	os := NewTestOS()
+	os.SELinuxModules = []string{"/etc/selinux/modules/myapp.pp"}
+	os.SELinuxBooleans = map[string]bool{"virt_use_nfs": false, "httpd_can_network_connect": true}
+	pipeline := os.serialize()
+	CheckFirstBootStageOptions(t, pipeline.Stages, []string{
+		"/usr/sbin/semodule -i /etc/selinux/modules/myapp.pp",
+		"/usr/sbin/setsebool -P httpd_can_network_connect=on virt_use_nfs=off",
+	})
+	CheckPkgSetInclude(t, os.getPackageSetChain(DISTRO_NULL), []string{"policycoreutils"})
+}
+
+func TestSELinuxFirstBootCommandsWithSubscription(t *testing.T) {
+	os := NewTestOS()
+	os.Subscription = &subscription.ImageOptions{
+		Organization:  "2040324",
+		ActivationKey: "my-secret-key",
+		ServerUrl:     "subscription.rhsm.redhat.com",
+		BaseUrl:       "http://cdn.redhat.com/",
+	}
+	os.SELinuxBooleans = map[string]bool{"httpd_can_network_connect": true}
+	pipeline := os.serialize()
+
+	// the commands must be merged into the single first-boot stage
+	firstBootStages := 0
+	for _, s := range pipeline.Stages {
+		if s.Type == "org.osbuild.first-boot" {
+			firstBootStages++
+		}
+	}
+	assert.Equal(t, 1, firstBootStages)
+	CheckFirstBootStageOptions(t, pipeline.Stages, []string{
+		"/usr/sbin/subscription-manager register --org=2040324 --activationkey=my-secret-key --serverurl subscription.rhsm.redhat.com --baseurl http://cdn.redhat.com/",
+		"/usr/sbin/setsebool -P httpd_can_network_connect=on",
+	})
+}
+
+func TestSwapFileFirstBootCommands(t *testing.T) {
+	os := NewTestOS()
+	os.SwapFileSize = 2147483648
+	pipeline := os.serialize()
+	CheckFirstBootStageOptions(t, pipeline.Stages, []string{
+		"/usr/bin/fallocate -l 2147483648 /swapfile",
+		"/usr/bin/chmod 0600 /swapfile",
+		"/usr/sbin/mkswap /swapfile",
+		`/usr/bin/sh -c 'echo "/swapfile none swap defaults 0 0" >> /etc/fstab'`,
+		"/usr/sbin/swapon /swapfile",
+	})
+}
+
 func TestSubscriptionManagerPackages(t *testing.T) {
 	os := NewTestOS()
 	os.Subscription = &subscription.ImageOptions{
@@ -152,3 +202,48 @@ func TestRhcInsightsPackages(t *testing.T) {
 	}
 	CheckPkgSetInclude(t, os.getPackageSetChain(DISTRO_NULL), []string{"rhc", "subscription-manager", "insights-client"})
 }
+
+func TestFIPSPackages(t *testing.T) {
+	os := NewTestOS()
+	os.FIPS = true
+	CheckPkgSetInclude(t, os.getPackageSetChain(DISTRO_FEDORA), []string{"crypto-policies-scripts"})
+	CheckPkgSetInclude(t, os.getPackageSetChain(DISTRO_EL8), []string{"crypto-policies-scripts", "dracut-fips"})
+}
+
+func TestFIPSStages(t *testing.T) {
+	os := NewTestOS()
+	os.FIPS = true
+	pipeline := os.serialize()
+
+	var stageTypes []string
+	for _, stage := range pipeline.Stages {
+		stageTypes = append(stageTypes, stage.Type)
+		if stage.Type == "org.osbuild.update-crypto-policies" {
+			assert.Equal(t, &osbuild.UpdateCryptoPoliciesStageOptions{Policy: "FIPS"}, stage.Options)
+		}
+	}
+	assert.Contains(t, stageTypes, "org.osbuild.dracut.conf")
+	assert.Contains(t, stageTypes, "org.osbuild.update-crypto-policies")
+}
+
+func TestSystemdBootPackages(t *testing.T) {
+	os := NewTestOS()
+	os.platform = &platform.Aarch64{UEFIVendor: "fedora"}
+	os.SystemdBoot = true
+
+	chain := os.getPackageSetChain(DISTRO_FEDORA)
+	CheckPkgSetInclude(t, chain, []string{"systemd-boot-unsigned", "efibootmgr", "grub2-tools"})
+	for _, pkg := range []string{"grub2-efi-aa64", "shim-aa64"} {
+		assert.NotContains(t, chain[0].Include, pkg)
+	}
+
+	// kernel-install puts the kernels and their entries on /boot
+	configFile := os.kernelInstallConfigFile()
+	require.NotNil(t, configFile)
+	assert.Equal(t, "/etc/kernel/install.conf", configFile.Path())
+	assert.Equal(t, "layout=bls\nBOOT_ROOT=/boot\n", string(configFile.Data()))
+	assert.Contains(t, os.getInline(), string(configFile.Data()))
+
+	os.SystemdBoot = false
+	assert.Nil(t, os.kernelInstallConfigFile())
+}
@@ -53,6 +53,7 @@ type OSTreeDeployment struct {
 
 	EnabledServices  []string
 	DisabledServices []string
+	MaskedServices   []string
 }
 
 // NewOSTreeDeployment creates a pipeline for an ostree deployment from a
@@ -335,10 +336,11 @@ func (p *OSTreeDeployment) serialize() osbuild.Pipeline {
 		pipeline.AddStages(fileStages...)
 	}
 
-	if len(p.EnabledServices) != 0 || len(p.DisabledServices) != 0 {
+	if len(p.EnabledServices) != 0 || len(p.DisabledServices) != 0 || len(p.MaskedServices) != 0 {
 		systemdStage := osbuild.NewSystemdStage(&osbuild.SystemdStageOptions{
 			EnabledServices:  p.EnabledServices,
 			DisabledServices: p.DisabledServices,
+			MaskedServices:   p.MaskedServices,
 		})
 		systemdStage.MountOSTree(p.osName, commit.Ref, 0)
 		pipeline.AddStage(systemdStage)
@@ -361,8 +363,24 @@ func (p *OSTreeDeployment) getInline() []string {
 
 	// inline data for custom files
 	for _, file := range p.Files {
+		if file.URL() != "" {
+			continue
+		}
 		inlineData = append(inlineData, string(file.Data()))
 	}
 
 	return inlineData
 }
+
+func (p *OSTreeDeployment) getRemoteFiles() []*fsnode.File {
+	var remoteFiles []*fsnode.File
+
+	// custom files fetched at build time
+	for _, file := range p.Files {
+		if file.URL() != "" {
+			remoteFiles = append(remoteFiles, file)
+		}
+	}
+
+	return remoteFiles
+}
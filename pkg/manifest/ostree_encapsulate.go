@@ -0,0 +1,71 @@
+package manifest
+
+import (
+	"github.com/osbuild/images/pkg/artifact"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// An OSTreeEncapsulate represents an ostree native container: the commit of
+// another pipeline wrapped in an OCI archive that can be pulled and deployed
+// by ostree based tools, like bootc.
+type OSTreeEncapsulate struct {
+	Base
+	filename string
+
+	// Default command of the container
+	Cmd []string
+
+	// Labels of the container, in KEY=VALUE form
+	Labels []string
+
+	commitPipeline *OSTreeCommit
+}
+
+func (p OSTreeEncapsulate) Filename() string {
+	return p.filename
+}
+
+func (p *OSTreeEncapsulate) SetFilename(filename string) {
+	p.filename = filename
+}
+
+// NewOSTreeEncapsulate creates a new OSTreeEncapsulate pipeline. The
+// commitPipeline is the pipeline producing the commit to encapsulate.
+func NewOSTreeEncapsulate(buildPipeline *Build, commitPipeline *OSTreeCommit, pipelinename string) *OSTreeEncapsulate {
+	p := &OSTreeEncapsulate{
+		Base:           NewBase(commitPipeline.Manifest(), pipelinename, buildPipeline),
+		commitPipeline: commitPipeline,
+		filename:       "container.tar",
+	}
+	buildPipeline.addDependent(p)
+	commitPipeline.Manifest().addPipeline(p)
+	return p
+}
+
+func (p *OSTreeEncapsulate) getInputs() []string {
+	return []string{p.commitPipeline.Name()}
+}
+
+func (p *OSTreeEncapsulate) serialize() osbuild.Pipeline {
+	pipeline := p.Base.serialize()
+
+	options := &osbuild.OSTreeEncapsulateStageOptions{
+		Filename: p.Filename(),
+		Cmd:      p.Cmd,
+		Labels:   p.Labels,
+	}
+	pipeline.AddStage(osbuild.NewOSTreeEncapsulateStage(options, p.commitPipeline.Name(), p.commitPipeline.ref))
+
+	return pipeline
+}
+
+func (p *OSTreeEncapsulate) getBuildPackages(Distro) []string {
+	// the encapsulation is done by rpm-ostree
+	return []string{"rpm-ostree"}
+}
+
+func (p *OSTreeEncapsulate) Export() *artifact.Artifact {
+	p.Base.export = true
+	mimeType := "application/x-tar"
+	return artifact.New(p.Name(), p.Filename(), &mimeType)
+}
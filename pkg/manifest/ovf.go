@@ -10,6 +10,9 @@ import (
 type OVF struct {
 	Base
 
+	// Settings of the virtual machine in the descriptor (optional)
+	VirtualMachine *osbuild.OVFVirtualMachineOptions
+
 	imgPipeline *VMDK
 }
 
@@ -24,6 +27,10 @@ func NewOVF(buidPipeline *Build, imgPipeline *VMDK) *OVF {
 	return p
 }
 
+func (p *OVF) getInputs() []string {
+	return []string{p.imgPipeline.Name()}
+}
+
 func (p *OVF) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
@@ -41,7 +48,8 @@ func (p *OVF) serialize() osbuild.Pipeline {
 	))
 
 	pipeline.AddStage(osbuild.NewOVFStage(&osbuild.OVFStageOptions{
-		Vmdk: p.imgPipeline.Filename(),
+		Vmdk:           p.imgPipeline.Filename(),
+		VirtualMachine: p.VirtualMachine,
 	}))
 
 	return pipeline
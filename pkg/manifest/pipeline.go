@@ -1,6 +1,7 @@
 package manifest
 
 import (
+	"github.com/osbuild/images/internal/fsnode"
 	"github.com/osbuild/images/pkg/artifact"
 	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/osbuild"
@@ -67,6 +68,12 @@ type Pipeline interface {
 	// getInline returns the list of inlined data content that will be used to
 	// embed files in the pipeline tree.
 	getInline() []string
+	// getRemoteFiles returns the list of files whose content will be fetched
+	// at build time to embed them in the pipeline tree.
+	getRemoteFiles() []*fsnode.File
+	// getInputs returns the names of the pipelines whose trees are inputs of
+	// the pipeline, not including the build pipeline.
+	getInputs() []string
 }
 
 // A Base represents the core functionality shared between each of the pipeline
@@ -142,6 +149,14 @@ func (p Base) getInline() []string {
 	return []string{}
 }
 
+func (p Base) getRemoteFiles() []*fsnode.File {
+	return nil
+}
+
+func (p Base) getInputs() []string {
+	return nil
+}
+
 // NewBase returns a generic Pipeline object. The name is mandatory, immutable and must
 // be unique among all the pipelines used in a manifest, which is currently not enforced.
 // The build argument is a pipeline representing a build root in which the rest of the
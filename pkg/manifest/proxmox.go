@@ -0,0 +1,158 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+	"github.com/osbuild/images/pkg/artifact"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// Defaults of the virtual machine settings of the Proxmox VE configuration
+const (
+	ProxmoxDefaultCores     = 2
+	ProxmoxDefaultMemoryMiB = 2048
+	ProxmoxDefaultBridge    = "vmbr0"
+)
+
+// The smallest amount of memory that Proxmox VE accepts for a virtual machine
+const proxmoxMinMemoryMiB = 16
+
+var proxmoxNameRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.-]*[a-zA-Z0-9])?$`)
+
+var proxmoxBridgeRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]*$`)
+
+// ProxmoxVMOptions are the settings of the virtual machine in the Proxmox VE
+// configuration that is exported next to a disk image. Zero values are
+// replaced by the defaults.
+type ProxmoxVMOptions struct {
+	// Name of the virtual machine
+	Name string `json:"name,omitempty"`
+	// Number of CPU cores
+	Cores uint `json:"cores,omitempty"`
+	// Memory in MiB
+	MemoryMiB uint64 `json:"memory_mib,omitempty"`
+	// Bridge of the network device
+	Bridge string `json:"bridge,omitempty"`
+}
+
+// Validate returns an error if the name or the bridge are not valid in a
+// Proxmox VE configuration or the memory is too small for a virtual machine.
+func (o ProxmoxVMOptions) Validate() error {
+	if o.Name != "" && !proxmoxNameRegex.MatchString(o.Name) {
+		return fmt.Errorf("proxmox virtual machine name %q must be a valid DNS name", o.Name)
+	}
+	if o.MemoryMiB != 0 && o.MemoryMiB < proxmoxMinMemoryMiB {
+		return fmt.Errorf("proxmox virtual machine memory %d MiB is less than the minimum of %d MiB", o.MemoryMiB, proxmoxMinMemoryMiB)
+	}
+	if o.Bridge != "" && !proxmoxBridgeRegex.MatchString(o.Bridge) {
+		return fmt.Errorf("proxmox network bridge %q is not a valid interface name", o.Bridge)
+	}
+	return nil
+}
+
+// A Proxmox generates the configuration of a Proxmox VE virtual machine
+// with the recommended settings for the disk image of another pipeline,
+// which can be imported with `qm importdisk`.
+type Proxmox struct {
+	Base
+	filename string
+
+	VM ProxmoxVMOptions
+	// Boot the virtual machine with UEFI (OVMF) instead of BIOS (SeaBIOS)
+	UEFI bool
+
+	imgPipeline FilePipeline
+}
+
+func (p Proxmox) Filename() string {
+	return p.filename
+}
+
+func (p *Proxmox) SetFilename(filename string) {
+	p.filename = filename
+}
+
+// NewProxmox creates a new Proxmox pipeline. imgPipeline is the pipeline
+// producing the qcow2 or raw disk image that the configuration is for.
+func NewProxmox(buildPipeline *Build, imgPipeline FilePipeline) *Proxmox {
+	p := &Proxmox{
+		Base:        NewBase(imgPipeline.Manifest(), "proxmox", buildPipeline),
+		imgPipeline: imgPipeline,
+		filename:    "proxmox.conf",
+	}
+	buildPipeline.addDependent(p)
+	imgPipeline.Manifest().addPipeline(p)
+	return p
+}
+
+// config returns the content of the configuration. Proxmox VE keeps the
+// comments at the start as the description of the virtual machine.
+func (p *Proxmox) config() string {
+	cores := p.VM.Cores
+	if cores == 0 {
+		cores = ProxmoxDefaultCores
+	}
+	memory := p.VM.MemoryMiB
+	if memory == 0 {
+		memory = ProxmoxDefaultMemoryMiB
+	}
+	bridge := p.VM.Bridge
+	if bridge == "" {
+		bridge = ProxmoxDefaultBridge
+	}
+	bios := "seabios"
+	if p.UEFI {
+		bios = "ovmf"
+	}
+
+	var b strings.Builder
+	disk := p.imgPipeline.Filename()
+	fmt.Fprintf(&b, "# Copy this file to /etc/pve/qemu-server/<vmid>.conf and import %s with:\n", disk)
+	fmt.Fprintf(&b, "#   qm importdisk <vmid> %s <storage>\n", disk)
+	b.WriteString("#   qm set <vmid> --scsi0 <storage>:vm-<vmid>-disk-0 --boot order=scsi0\n")
+	fmt.Fprintf(&b, "bios: %s\n", bios)
+	fmt.Fprintf(&b, "cores: %d\n", cores)
+	b.WriteString("machine: q35\n")
+	fmt.Fprintf(&b, "memory: %d\n", memory)
+	if p.VM.Name != "" {
+		fmt.Fprintf(&b, "name: %s\n", p.VM.Name)
+	}
+	fmt.Fprintf(&b, "net0: virtio,bridge=%s\n", bridge)
+	b.WriteString("ostype: l26\n")
+	b.WriteString("scsihw: virtio-scsi-single\n")
+	b.WriteString("serial0: socket\n")
+	return b.String()
+}
+
+func (p *Proxmox) file() *fsnode.File {
+	file, err := fsnode.NewFile("/"+p.Filename(), common.ToPtr(os.FileMode(0644)), nil, nil, []byte(p.config()))
+	if err != nil {
+		panic(err)
+	}
+	return file
+}
+
+func (p *Proxmox) serialize() osbuild.Pipeline {
+	if err := p.VM.Validate(); err != nil {
+		panic(err)
+	}
+
+	pipeline := p.Base.serialize()
+	pipeline.AddStages(osbuild.GenFileNodesStages([]*fsnode.File{p.file()})...)
+	return pipeline
+}
+
+func (p *Proxmox) getInline() []string {
+	return []string{string(p.file().Data())}
+}
+
+func (p *Proxmox) Export() *artifact.Artifact {
+	p.Base.export = true
+	mimeType := "text/plain"
+	return artifact.New(p.Name(), p.Filename(), &mimeType)
+}
@@ -11,6 +11,13 @@ type QCOW2 struct {
 	filename string
 	Compat   string
 
+	// Compression of the clusters, zlib if unset
+	CompressionType osbuild.QCOW2CompressionType
+	// Size of the clusters in bytes, 64 KiB if unset
+	ClusterSize uint64
+	// Preallocation of the image, none if unset
+	Preallocation osbuild.QCOW2Preallocation
+
 	imgPipeline FilePipeline
 }
 
@@ -36,6 +43,10 @@ func NewQCOW2(buildPipeline *Build, imgPipeline FilePipeline) *QCOW2 {
 	return p
 }
 
+func (p *QCOW2) getInputs() []string {
+	return []string{p.imgPipeline.Name()}
+}
+
 func (p *QCOW2) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
@@ -43,7 +54,10 @@ func (p *QCOW2) serialize() osbuild.Pipeline {
 		osbuild.NewQEMUStageOptions(p.Filename(),
 			osbuild.QEMUFormatQCOW2,
 			osbuild.QCOW2Options{
-				Compat: p.Compat,
+				Compat:          p.Compat,
+				CompressionType: p.CompressionType,
+				ClusterSize:     p.ClusterSize,
+				Preallocation:   p.Preallocation,
 			}),
 		osbuild.NewQemuStagePipelineFilesInputs(p.imgPipeline.Name(), p.imgPipeline.Filename()),
 	))
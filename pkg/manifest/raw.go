@@ -1,6 +1,8 @@
 package manifest
 
 import (
+	"fmt"
+
 	"github.com/osbuild/images/pkg/artifact"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/platform"
@@ -43,6 +45,10 @@ func (p *RawImage) getBuildPackages(d Distro) []string {
 	return pkgs
 }
 
+func (p *RawImage) getInputs() []string {
+	return []string{p.treePipeline.Name()}
+}
+
 func (p *RawImage) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
@@ -58,6 +64,24 @@ func (p *RawImage) serialize() osbuild.Pipeline {
 	inputName := "root-tree"
 	copyOptions, copyDevices, copyMounts := osbuild.GenCopyFSTreeOptions(inputName, p.treePipeline.Name(), p.Filename(), pt)
 	copyInputs := osbuild.NewPipelineTreeInputs(inputName, p.treePipeline.Name())
+	if p.treePipeline.SystemdBoot {
+		// the EFI system partition is /boot, the directory of the boot
+		// loader was created in the tree and is copied with it
+		source, loader := systemdBootEFIFiles(p.treePipeline.platform.GetArch())
+		espMount := ""
+		for _, mount := range *copyMounts {
+			if mount.Target == "/boot" && mount.Type == "org.osbuild.fat" {
+				espMount = mount.Name
+			}
+		}
+		if espMount == "" {
+			panic("systemd-boot requires the EFI system partition at /boot; this is a programming error")
+		}
+		copyOptions.Paths = append(copyOptions.Paths, osbuild.CopyStagePath{
+			From: fmt.Sprintf("input://%s%s", inputName, source),
+			To:   fmt.Sprintf("mount://%s%s", espMount, loader),
+		})
+	}
 	pipeline.AddStage(osbuild.NewCopyStage(copyOptions, copyInputs, copyDevices, copyMounts))
 
 	for _, stage := range osbuild.GenImageFinishStages(pt, p.Filename()) {
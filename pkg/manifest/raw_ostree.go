@@ -51,6 +51,10 @@ func (p *RawOSTreeImage) getBuildPackages(Distro) []string {
 	return packages
 }
 
+func (p *RawOSTreeImage) getInputs() []string {
+	return []string{p.treePipeline.Name()}
+}
+
 func (p *RawOSTreeImage) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
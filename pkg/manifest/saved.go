@@ -0,0 +1,91 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// SavedManifestVersion is the version of the format of the saved manifests.
+const SavedManifestVersion = 1
+
+// A SavedManifest is a manifest that was serialized with its resolved content
+// and saved, e.g. to build it later on another host without generating it
+// again. The osbuild manifest is all osbuild needs for the build, the
+// resolved content is kept for reference.
+type SavedManifest struct {
+	Version int `json:"version"`
+
+	// Manifest is the serialized manifest, as returned by Serialize
+	Manifest OSBuildManifest `json:"manifest"`
+
+	// Checkpoints and Exports are the pipelines to checkpoint and export
+	// when building the manifest
+	Checkpoints []string `json:"checkpoints"`
+	Exports     []string `json:"exports"`
+
+	// BuildPipelines are the pipelines of the build roots, whose packages
+	// are not installed in the image
+	BuildPipelines []string `json:"build_pipelines,omitempty"`
+
+	// The resolved content the manifest was serialized with, by pipeline
+	PackageSets   map[string][]rpmmd.PackageSpec `json:"package_sets,omitempty"`
+	Containers    map[string][]container.Spec    `json:"containers,omitempty"`
+	OSTreeCommits map[string][]ostree.CommitSpec `json:"ostree_commits,omitempty"`
+}
+
+// ContentHash returns the content hash of the saved osbuild manifest, which
+// is the one of the manifest that was saved.
+func (s SavedManifest) ContentHash() string {
+	return s.Manifest.ContentHash()
+}
+
+// Save serializes the manifest with the resolved content and writes it to w
+// together with everything needed to build it.
+func (m Manifest) Save(w io.Writer, packageSets map[string][]rpmmd.PackageSpec, containerSpecs map[string][]container.Spec, ostreeCommits map[string][]ostree.CommitSpec) error {
+	mf, err := m.Serialize(packageSets, containerSpecs, ostreeCommits)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(SavedManifest{
+		Version:        SavedManifestVersion,
+		Manifest:       mf,
+		Checkpoints:    m.GetCheckpoints(),
+		Exports:        m.GetExports(),
+		BuildPipelines: m.GetBuildPipelines(),
+		PackageSets:    packageSets,
+		Containers:     containerSpecs,
+		OSTreeCommits:  ostreeCommits,
+	})
+}
+
+// Load reads a manifest saved with Save.
+func Load(r io.Reader) (*SavedManifest, error) {
+	var saved SavedManifest
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&saved); err != nil {
+		return nil, fmt.Errorf("failed to parse saved manifest: %w", err)
+	}
+	if saved.Version != SavedManifestVersion {
+		return nil, fmt.Errorf("unsupported saved manifest version %d (supported: %d)", saved.Version, SavedManifestVersion)
+	}
+	if len(saved.Manifest) == 0 || bytes.Equal(saved.Manifest, []byte("null")) {
+		return nil, fmt.Errorf("saved manifest has no osbuild manifest")
+	}
+
+	// the osbuild manifest is kept as it was serialized, in case the saved
+	// manifest was reformatted, so that it has the same content hash
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, saved.Manifest); err != nil {
+		return nil, err
+	}
+	saved.Manifest = compacted.Bytes()
+	return &saved, nil
+}
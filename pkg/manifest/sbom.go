@@ -0,0 +1,201 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// SBOMFormatSPDXJSON is the SPDX 2.3 JSON format of software bills of
+// materials.
+const SBOMFormatSPDXJSON = "spdx-json"
+
+// sbomNow returns the creation time of the SBOMs
+var sbomNow = time.Now
+
+// sbomUUID returns the unique part of the namespace of the SBOMs
+var sbomUUID = uuid.NewString
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// The SPDX names of the rpm checksum types
+var spdxChecksumAlgorithms = map[string]string{
+	"md5":    "MD5",
+	"sha1":   "SHA1",
+	"sha224": "SHA224",
+	"sha256": "SHA256",
+	"sha384": "SHA384",
+	"sha512": "SHA512",
+}
+
+// evr returns the [epoch:]version-release of the package.
+func evr(pkg rpmmd.PackageSpec) string {
+	version := pkg.Version
+	if pkg.Release != "" {
+		version += "-" + pkg.Release
+	}
+	if pkg.Epoch != 0 {
+		version = fmt.Sprintf("%d:%s", pkg.Epoch, version)
+	}
+	return version
+}
+
+// rpmPURL returns the package URL of the package.
+func rpmPURL(pkg rpmmd.PackageSpec) string {
+	purl := "pkg:rpm/" + url.PathEscape(pkg.Name)
+	if pkg.Version != "" {
+		purl += "@" + url.PathEscape(pkg.Version)
+		if pkg.Release != "" {
+			purl += "-" + url.PathEscape(pkg.Release)
+		}
+	}
+	var qualifiers []string
+	if pkg.Arch != "" {
+		qualifiers = append(qualifiers, "arch="+url.QueryEscape(pkg.Arch))
+	}
+	if pkg.Epoch != 0 {
+		qualifiers = append(qualifiers, fmt.Sprintf("epoch=%d", pkg.Epoch))
+	}
+	if len(qualifiers) > 0 {
+		purl += "?" + strings.Join(qualifiers, "&")
+	}
+	return purl
+}
+
+func spdxPackageFromSpec(idx int, pkg rpmmd.PackageSpec) spdxPackage {
+	p := spdxPackage{
+		Name:             pkg.Name,
+		SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", idx),
+		VersionInfo:      evr(pkg),
+		DownloadLocation: "NOASSERTION",
+		ExternalRefs: []spdxExternalRef{
+			{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  rpmPURL(pkg),
+			},
+		},
+	}
+	if pkg.RemoteLocation != "" {
+		p.DownloadLocation = pkg.RemoteLocation
+	}
+	if checksumType, value, found := strings.Cut(pkg.Checksum, ":"); found {
+		if algorithm, ok := spdxChecksumAlgorithms[checksumType]; ok {
+			p.Checksums = []spdxChecksum{{Algorithm: algorithm, ChecksumValue: value}}
+		}
+	}
+	return p
+}
+
+// SBOM returns a software bill of materials of the image in the given format,
+// SBOMFormatSPDXJSON, with the resolved packages of the manifest. The
+// packages of the build pipelines are not part of the image and not
+// included. Versions, download locations and checksums are included when they
+// are known. Each document has a namespace of its own, as SPDX requires, even
+// if it is of the same manifest.
+func (s SavedManifest) SBOM(format string) ([]byte, error) {
+	if format != SBOMFormatSPDXJSON {
+		return nil, fmt.Errorf("unsupported SBOM format %q (supported: %s)", format, SBOMFormatSPDXJSON)
+	}
+
+	// the same package can be installed by several pipelines, e.g. the os
+	// and an ostree deployment
+	seen := make(map[string]bool)
+	var pkgs []rpmmd.PackageSpec
+	for pipeline, specs := range s.PackageSets {
+		if slices.Contains(s.BuildPipelines, pipeline) {
+			continue
+		}
+		for _, pkg := range specs {
+			nevra := fmt.Sprintf("%s-%s.%s", pkg.Name, evr(pkg), pkg.Arch)
+			if !seen[nevra] {
+				seen[nevra] = true
+				pkgs = append(pkgs, pkg)
+			}
+		}
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		if pkgs[i].Name != pkgs[j].Name {
+			return pkgs[i].Name < pkgs[j].Name
+		}
+		if evr(pkgs[i]) != evr(pkgs[j]) {
+			return evr(pkgs[i]) < evr(pkgs[j])
+		}
+		return pkgs[i].Arch < pkgs[j].Arch
+	})
+
+	hash := s.ContentHash()
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              "image-" + hash,
+		DocumentNamespace: fmt.Sprintf("https://osbuild.org/spdxdocs/image-%s-%s", hash, sbomUUID()),
+		CreationInfo: spdxCreationInfo{
+			Created:  sbomNow().UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: osbuild-images"},
+		},
+		Packages:      make([]spdxPackage, 0, len(pkgs)),
+		Relationships: make([]spdxRelationship, 0, len(pkgs)),
+	}
+	for idx, pkg := range pkgs {
+		p := spdxPackageFromSpec(idx+1, pkg)
+		doc.Packages = append(doc.Packages, p)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      doc.SPDXID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: p.SPDXID,
+		})
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
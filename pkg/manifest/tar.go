@@ -41,6 +41,10 @@ func NewTar(buildPipeline *Build, inputPipeline Pipeline, pipelinename string) *
 	return p
 }
 
+func (p *Tar) getInputs() []string {
+	return []string{p.inputPipeline.Name()}
+}
+
 func (p *Tar) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
@@ -0,0 +1,171 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/fsnode"
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+// VagrantProvider is the Vagrant provider that a box is built for.
+type VagrantProvider string
+
+const (
+	VagrantProviderLibvirt    VagrantProvider = "libvirt"
+	VagrantProviderVirtualBox VagrantProvider = "virtualbox"
+)
+
+// Names of the disk images in the tree of the Vagrant pipeline. The OVF
+// descriptor of the vmdk image of virtualbox boxes is named after it, i.e.
+// box.ovf.
+const (
+	vagrantLibvirtDiskFilename    = "box.img"
+	vagrantVirtualBoxDiskFilename = "box.vmdk"
+)
+
+const vagrantLibvirtVagrantfile = `Vagrant.configure("2") do |config|
+  config.vm.provider :libvirt do |libvirt|
+    libvirt.driver = "kvm"
+  end
+end
+`
+
+const vagrantVirtualBoxVagrantfile = `Vagrant.configure("2") do |config|
+  config.vm.provider :virtualbox do |vb|
+    vb.gui = false
+  end
+end
+`
+
+// The metadata.json of a box
+type vagrantMetadata struct {
+	Provider VagrantProvider `json:"provider"`
+	Format   string          `json:"format,omitempty"`
+	// Virtual size of the disk in GiB
+	VirtualSize uint64 `json:"virtual_size,omitempty"`
+}
+
+// A Vagrant copies a disk image to its own tree and adds the metadata.json
+// and the Vagrantfile of a Vagrant box for the provider. The tree is the
+// content of the box, which is packaged by a Tar pipeline.
+//
+// Boxes of the libvirt provider contain the qcow2 image as box.img, boxes of
+// the virtualbox provider contain the vmdk image and its OVF descriptor.
+type Vagrant struct {
+	Base
+
+	Provider VagrantProvider
+
+	// Virtual size of the disk in bytes, rounded up to GiB in the metadata
+	// of libvirt boxes
+	VirtualSize uint64
+
+	imgPipeline FilePipeline
+}
+
+// NewVagrant creates a new Vagrant pipeline. imgPipeline is the pipeline
+// producing the qcow2 image for the libvirt provider or the vmdk image for
+// the virtualbox provider.
+func NewVagrant(buildPipeline *Build, imgPipeline FilePipeline, provider VagrantProvider) *Vagrant {
+	switch provider {
+	case VagrantProviderLibvirt, VagrantProviderVirtualBox:
+	default:
+		panic(fmt.Sprintf("unsupported vagrant provider %q", provider))
+	}
+
+	p := &Vagrant{
+		Base:        NewBase(imgPipeline.Manifest(), "vagrant", buildPipeline),
+		Provider:    provider,
+		imgPipeline: imgPipeline,
+	}
+	buildPipeline.addDependent(p)
+	imgPipeline.Manifest().addPipeline(p)
+	return p
+}
+
+func (p *Vagrant) getInputs() []string {
+	return []string{p.imgPipeline.Name()}
+}
+
+func (p *Vagrant) diskFilename() string {
+	if p.Provider == VagrantProviderVirtualBox {
+		return vagrantVirtualBoxDiskFilename
+	}
+	return vagrantLibvirtDiskFilename
+}
+
+// files returns the metadata.json and the Vagrantfile of the box.
+func (p *Vagrant) files() []*fsnode.File {
+	metadata := vagrantMetadata{Provider: p.Provider}
+	vagrantfile := vagrantVirtualBoxVagrantfile
+	if p.Provider == VagrantProviderLibvirt {
+		metadata.Format = "qcow2"
+		metadata.VirtualSize = (p.VirtualSize + common.GibiByte - 1) / common.GibiByte
+		vagrantfile = vagrantLibvirtVagrantfile
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		panic(err)
+	}
+
+	var files []*fsnode.File
+	for _, f := range []struct {
+		path string
+		data []byte
+	}{
+		{"/metadata.json", append(metadataJSON, '\n')},
+		{"/Vagrantfile", []byte(vagrantfile)},
+	} {
+		file, err := fsnode.NewFile(f.path, common.ToPtr(os.FileMode(0644)), nil, nil, f.data)
+		if err != nil {
+			panic(err)
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+func (p *Vagrant) serialize() osbuild.Pipeline {
+	pipeline := p.Base.serialize()
+
+	inputName := "image-tree"
+	pipeline.AddStage(osbuild.NewCopyStageSimple(
+		&osbuild.CopyStageOptions{
+			Paths: []osbuild.CopyStagePath{
+				{
+					From: fmt.Sprintf("input://%s/%s", inputName, p.imgPipeline.Export().Filename()),
+					To:   "tree:///" + p.diskFilename(),
+				},
+			},
+		},
+		osbuild.NewPipelineTreeInputs(inputName, p.imgPipeline.Name()),
+	))
+
+	if p.Provider == VagrantProviderVirtualBox {
+		pipeline.AddStage(osbuild.NewOVFStage(&osbuild.OVFStageOptions{
+			Vmdk: p.diskFilename(),
+		}))
+	}
+
+	pipeline.AddStages(osbuild.GenFileNodesStages(p.files())...)
+
+	return pipeline
+}
+
+func (p *Vagrant) getInline() []string {
+	inlineData := []string{}
+	for _, file := range p.files() {
+		inlineData = append(inlineData, string(file.Data()))
+	}
+	return inlineData
+}
+
+func (p *Vagrant) getBuildPackages(Distro) []string {
+	if p.Provider == VagrantProviderVirtualBox {
+		return []string{"qemu-img"}
+	}
+	return []string{}
+}
@@ -10,6 +10,9 @@ type VMDK struct {
 	Base
 	filename string
 
+	// Subformat of the image, defaults to streamOptimized
+	Subformat osbuild.VMDKSubformat
+
 	imgPipeline Pipeline
 }
 
@@ -36,12 +39,20 @@ func NewVMDK(buildPipeline *Build, imgPipeline FilePipeline) *VMDK {
 	return p
 }
 
+func (p *VMDK) getInputs() []string {
+	return []string{p.imgPipeline.Name()}
+}
+
 func (p *VMDK) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
+	subformat := p.Subformat
+	if subformat == "" {
+		subformat = osbuild.VMDKSubformatStreamOptimized
+	}
 	pipeline.AddStage(osbuild.NewQEMUStage(
 		osbuild.NewQEMUStageOptions(p.Filename(), osbuild.QEMUFormatVMDK, osbuild.VMDKOptions{
-			Subformat: osbuild.VMDKSubformatStreamOptimized,
+			Subformat: subformat,
 		}),
 		osbuild.NewQemuStagePipelineFilesInputs(p.imgPipeline.Name(), p.imgPipeline.Export().Filename()),
 	))
@@ -12,6 +12,9 @@ type VPC struct {
 
 	ForceSize *bool
 
+	// Subformat of the image, fixed if unset
+	Subformat osbuild.VPCSubformat
+
 	imgPipeline *RawImage
 }
 
@@ -37,10 +40,14 @@ func NewVPC(buildPipeline *Build, imgPipeline *RawImage) *VPC {
 	return p
 }
 
+func (p *VPC) getInputs() []string {
+	return []string{p.imgPipeline.Name()}
+}
+
 func (p *VPC) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
-	formatOptions := osbuild.VPCOptions{ForceSize: p.ForceSize}
+	formatOptions := osbuild.VPCOptions{ForceSize: p.ForceSize, Subformat: p.Subformat}
 
 	pipeline.AddStage(osbuild.NewQEMUStage(
 		osbuild.NewQEMUStageOptions(p.Filename(), osbuild.QEMUFormatVPC, formatOptions),
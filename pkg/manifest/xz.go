@@ -34,6 +34,10 @@ func NewXZ(buildPipeline *Build, imgPipeline FilePipeline) *XZ {
 	return p
 }
 
+func (p *XZ) getInputs() []string {
+	return []string{p.imgPipeline.Name()}
+}
+
 func (p *XZ) serialize() osbuild.Pipeline {
 	pipeline := p.Base.serialize()
 
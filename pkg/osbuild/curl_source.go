@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"regexp"
 
+	"github.com/osbuild/images/internal/fsnode"
 	"github.com/osbuild/images/pkg/rpmmd"
 )
 
@@ -55,6 +56,16 @@ func (source *CurlSource) AddPackage(pkg rpmmd.PackageSpec) error {
 	return nil
 }
 
+// AddFile adds a remote file to the curl source to download. Will return an
+// error if the checksum of the file is invalid.
+func (source *CurlSource) AddFile(file *fsnode.File) error {
+	if !curlDigestPattern.MatchString(file.Checksum()) {
+		return fmt.Errorf("curl file source item with path %q has invalid digest %q", file.Path(), file.Checksum())
+	}
+	source.Items[file.Checksum()] = URL(file.URL())
+	return nil
+}
+
 type URL string
 
 func (URL) isCurlSourceItem() {}
@@ -3,8 +3,10 @@ package osbuild
 import (
 	"testing"
 
+	"github.com/osbuild/images/internal/fsnode"
 	"github.com/osbuild/images/pkg/rpmmd"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPackageSourceValidation(t *testing.T) {
@@ -117,3 +119,16 @@ func TestPackageSourceValidation(t *testing.T) {
 		}
 	}
 }
+
+func TestFileSourceValidation(t *testing.T) {
+	file, err := fsnode.NewRemoteFile("/opt/asset", nil, nil, nil, "https://example.com/asset", "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9")
+	require.NoError(t, err)
+
+	curl := NewCurlSource()
+	require.NoError(t, curl.AddFile(file))
+	assert.Equal(t, URL("https://example.com/asset"), curl.Items["sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"])
+
+	file, err = fsnode.NewRemoteFile("/opt/asset", nil, nil, nil, "https://example.com/asset", "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9")
+	require.NoError(t, err)
+	assert.EqualError(t, curl.AddFile(file), `curl file source item with path "/opt/asset" has invalid digest "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"`)
+}
@@ -109,6 +109,17 @@ func GenImageFinishStages(pt *disk.PartitionTable, filename string) []*Stage {
 	return GenDeviceFinishStages(pt, filename)
 }
 
+// GenFIPSKernelOptions returns the kernel command line options that boot the
+// system in FIPS mode. The integrity of the kernel is checked on the /boot
+// file system, so it must be specified when it is on a separate partition.
+func GenFIPSKernelOptions(pt *disk.PartitionTable) []string {
+	cmdline := []string{"fips=1"}
+	if bootMnt := pt.FindMountable("/boot"); bootMnt != nil {
+		cmdline = append(cmdline, "boot=UUID="+bootMnt.GetFSSpec().UUID)
+	}
+	return cmdline
+}
+
 func GenImageKernelOptions(pt *disk.PartitionTable) []string {
 	cmdline := make([]string, 0)
 
@@ -38,3 +38,30 @@ func TestGenImageKernelOptions(t *testing.T) {
 
 	assert.Subset(cmdline, []string{"luks.uuid=" + uuid})
 }
+
+func TestGenFIPSKernelOptions(t *testing.T) {
+	// math/rand is good enough in this case
+	/* #nosec G404 */
+	rng := rand.New(rand.NewSource(13))
+
+	plain := testPartitionTables["plain"]
+	pt, err := disk.NewPartitionTable(&plain, []blueprint.FilesystemCustomization{}, 0, disk.RawPartitioningMode, make(map[string]uint64), rng)
+	assert.NoError(t, err)
+
+	bootUUID := pt.FindMountable("/boot").GetFSSpec().UUID
+	assert.NotEmpty(t, bootUUID)
+	assert.Equal(t, []string{"fips=1", "boot=UUID=" + bootUUID}, GenFIPSKernelOptions(pt))
+
+	rootOnly := &disk.PartitionTable{
+		Type: "gpt",
+		Partitions: []disk.Partition{
+			{
+				Payload: &disk.Filesystem{
+					Type:       "xfs",
+					Mountpoint: "/",
+				},
+			},
+		},
+	}
+	assert.Equal(t, []string{"fips=1"}, GenFIPSKernelOptions(rootOnly))
+}
@@ -34,6 +34,11 @@ func (o DNFConfigStageOptions) validate() error {
 		if !valid {
 			return fmt.Errorf("DNF config parameter ip_resolve does not allow '%s' as a value", o.Config.Main.IPResolve)
 		}
+		for _, flag := range o.Config.Main.TSFlags {
+			if !dnfTSFlags[flag] {
+				return fmt.Errorf("DNF config parameter tsflags does not allow '%s' as a value", flag)
+			}
+		}
 	}
 
 	return nil
@@ -65,4 +70,18 @@ type DNFConfig struct {
 
 type DNFConfigMain struct {
 	IPResolve string `json:"ip_resolve,omitempty"`
+	// Flags of the rpm transactions of dnf, e.g. nodocs
+	TSFlags []string `json:"tsflags,omitempty"`
+}
+
+// The transaction flags of dnf.conf(5)
+var dnfTSFlags = map[string]bool{
+	"noscripts":  true,
+	"test":       true,
+	"notriggers": true,
+	"nodocs":     true,
+	"justdb":     true,
+	"nocontexts": true,
+	"nocaps":     true,
+	"nocrypto":   true,
 }
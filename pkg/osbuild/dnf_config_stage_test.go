@@ -122,6 +122,26 @@ func TestDNFConfigValidate(t *testing.T) {
 			},
 			false,
 		},
+		{
+			DNFConfigStageOptions{
+				Config: &DNFConfig{
+					Main: &DNFConfigMain{
+						TSFlags: []string{"nodocs", "nocontexts"},
+					},
+				},
+			},
+			true,
+		},
+		{
+			DNFConfigStageOptions{
+				Config: &DNFConfig{
+					Main: &DNFConfigMain{
+						TSFlags: []string{"nodocs", "nolocales"},
+					},
+				},
+			},
+			false,
+		},
 	}
 	for idx := range tests {
 		test := tests[idx]
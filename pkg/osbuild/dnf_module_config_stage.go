@@ -0,0 +1,63 @@
+package osbuild
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DNFModuleConfigStageOptions represents the state of a DNF module stream,
+// written to /etc/dnf/modules.d/<name>.module.
+type DNFModuleConfigStageOptions struct {
+	Conf *DNFModuleConfig `json:"conf"`
+}
+
+func (DNFModuleConfigStageOptions) isStageOptions() {}
+
+type DNFModuleConfig struct {
+	Name     string   `json:"name"`
+	Stream   string   `json:"stream"`
+	State    string   `json:"state"`
+	Profiles []string `json:"profiles"`
+}
+
+func (o DNFModuleConfigStageOptions) validate() error {
+	if o.Conf == nil || o.Conf.Name == "" || o.Conf.Stream == "" {
+		return fmt.Errorf("org.osbuild.dnf.module-config: module name and stream are required")
+	}
+	return nil
+}
+
+// NewDNFModuleConfigStage creates a new DNF module config Stage object.
+func NewDNFModuleConfigStage(options *DNFModuleConfigStageOptions) *Stage {
+	if err := options.validate(); err != nil {
+		panic(err)
+	}
+
+	return &Stage{
+		Type:    "org.osbuild.dnf.module-config",
+		Options: options,
+	}
+}
+
+// NewDNFModuleEnableStages creates a stage that enables the module stream for
+// each of the given name:stream or name:stream/profile specifications.
+func NewDNFModuleEnableStages(modules []string) []*Stage {
+	stages := make([]*Stage, 0, len(modules))
+	for _, module := range modules {
+		spec, profile, _ := strings.Cut(module, "/")
+		name, stream, _ := strings.Cut(spec, ":")
+		profiles := []string{}
+		if profile != "" {
+			profiles = append(profiles, profile)
+		}
+		stages = append(stages, NewDNFModuleConfigStage(&DNFModuleConfigStageOptions{
+			Conf: &DNFModuleConfig{
+				Name:     name,
+				Stream:   stream,
+				State:    "enabled",
+				Profiles: profiles,
+			},
+		}))
+	}
+	return stages
+}
@@ -0,0 +1,43 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDNFModuleEnableStages(t *testing.T) {
+	stages := NewDNFModuleEnableStages([]string{"nodejs:18", "postgresql:15/server"})
+	expected := []*Stage{
+		{
+			Type: "org.osbuild.dnf.module-config",
+			Options: &DNFModuleConfigStageOptions{
+				Conf: &DNFModuleConfig{
+					Name:     "nodejs",
+					Stream:   "18",
+					State:    "enabled",
+					Profiles: []string{},
+				},
+			},
+		},
+		{
+			Type: "org.osbuild.dnf.module-config",
+			Options: &DNFModuleConfigStageOptions{
+				Conf: &DNFModuleConfig{
+					Name:     "postgresql",
+					Stream:   "15",
+					State:    "enabled",
+					Profiles: []string{"server"},
+				},
+			},
+		},
+	}
+	assert.Equal(t, expected, stages)
+}
+
+func TestNewDNFModuleConfigStageInvalid(t *testing.T) {
+	assert.Panics(t, func() { NewDNFModuleConfigStage(&DNFModuleConfigStageOptions{}) })
+	assert.Panics(t, func() {
+		NewDNFModuleConfigStage(&DNFModuleConfigStageOptions{Conf: &DNFModuleConfig{Name: "nodejs"}})
+	})
+}
@@ -3,6 +3,7 @@ package osbuild
 import (
 	"crypto/sha256"
 	"fmt"
+	"strings"
 
 	"github.com/osbuild/images/internal/fsnode"
 )
@@ -11,7 +12,8 @@ import (
 // It generates the following stages:
 //   - copy stage with all the files that need to be created by copying their
 //     content from the list of inline sources. The SHA256 sum of the file is
-//     used as the name of the stage input.
+//     used as the name of the stage input. The content of remote files is
+//     taken from the curl source instead, using their checksum.
 //   - chmod stage with all the files that need to have their permissions set.
 //   - chown stage with all the files that need to have their ownership set.
 func GenFileNodesStages(files []*fsnode.File) []*Stage {
@@ -22,16 +24,22 @@ func GenFileNodesStages(files []*fsnode.File) []*Stage {
 	chownPaths := make(map[string]ChownStagePathOptions)
 
 	for _, file := range files {
-		fileDataChecksum := fmt.Sprintf("%x", sha256.Sum256(file.Data()))
-		copyStageInputKey := fmt.Sprintf("file-%s", fileDataChecksum)
+		// remote files are fetched by the curl source, inline ones are
+		// addressed by the checksum of their data
+		fileChecksum := file.Checksum()
+		if file.URL() == "" {
+			fileChecksum = fmt.Sprintf("sha256:%x", sha256.Sum256(file.Data()))
+		}
+		_, fileDigest, _ := strings.Cut(fileChecksum, ":")
+		copyStageInputKey := fmt.Sprintf("file-%s", fileDigest)
 		copyStagePaths = append(copyStagePaths, CopyStagePath{
-			From: fmt.Sprintf("input://%s/sha256:%s", copyStageInputKey, fileDataChecksum),
+			From: fmt.Sprintf("input://%s/%s", copyStageInputKey, fileChecksum),
 			To:   fmt.Sprintf("tree://%s", file.Path()),
 			// Default to removing the destination if it exists to ensure that symlinks are not followed.
 			RemoveDestination: true,
 		})
 		copyStageInputs[copyStageInputKey] = NewFilesInput(NewFilesInputSourceArrayRef([]FilesInputSourceArrayRefEntry{
-			NewFilesInputSourceArrayRefEntry(fmt.Sprintf("sha256:%s", fileDataChecksum), nil),
+			NewFilesInputSourceArrayRefEntry(fileChecksum, nil),
 		}))
 
 		if file.Mode() != nil {
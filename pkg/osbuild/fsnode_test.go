@@ -58,6 +58,27 @@ func TestGenFileNodesStages(t *testing.T) {
 				}),
 			},
 		},
+		{
+			name: "single-remote-file",
+			files: []*fsnode.File{
+				ensureFileCreation(fsnode.NewRemoteFile("/opt/asset", nil, nil, nil, "https://example.com/asset", fmt.Sprintf("sha256:%x", sha256.Sum256(fileData1)))),
+			},
+			expected: []*Stage{
+				NewCopyStageSimple(&CopyStageOptions{
+					Paths: []CopyStagePath{
+						{
+							From:              fmt.Sprintf("input://file-%[1]x/sha256:%[1]x", sha256.Sum256(fileData1)),
+							To:                "tree:///opt/asset",
+							RemoveDestination: true,
+						},
+					},
+				}, &CopyStageFilesInputs{
+					fmt.Sprintf("file-%x", sha256.Sum256(fileData1)): NewFilesInput(NewFilesInputSourceArrayRef([]FilesInputSourceArrayRefEntry{
+						NewFilesInputSourceArrayRefEntry(fmt.Sprintf("sha256:%x", sha256.Sum256(fileData1)), nil),
+					})),
+				}),
+			},
+		},
 		{
 			name: "multiple-files-simple",
 			files: []*fsnode.File{
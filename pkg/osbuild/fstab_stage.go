@@ -2,7 +2,9 @@ package osbuild
 
 import (
 	"fmt"
+	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/osbuild/images/pkg/disk"
 )
@@ -33,10 +35,12 @@ func NewFSTabStage(options *FSTabStageOptions) *Stage {
 }
 
 // An FSTabEntry represents one line in /etc/fstab. With the one exception
-// that the the spec field must be represented as an UUID.
+// that the the spec field must be represented as an UUID, a label or, for
+// filesystems without a block device like overlays, as a device name.
 type FSTabEntry struct {
 	UUID    string `json:"uuid,omitempty"`
 	Label   string `json:"label,omitempty"`
+	Device  string `json:"device,omitempty"`
 	VFSType string `json:"vfs_type"`
 	Path    string `json:"path,omitempty"`
 	Options string `json:"options,omitempty"`
@@ -76,3 +80,72 @@ func NewFSTabStageOptions(pt *disk.PartitionTable) *FSTabStageOptions {
 	})
 	return &options
 }
+
+// The directories of the root filesystem that are overlays on images with a
+// read-only root, since the system or the users write to them
+var readOnlyRootOverlays = []string{"/etc", "/home", "/root"}
+
+// SetReadOnlyRoot mounts the root filesystem read-only. /etc, /home and /root
+// become overlays on top of it, unless they are filesystems of their own, with
+// their upper and work directories below the given directory, which must be on
+// the /var filesystem. /tmp is a tmpfs, unless it is a filesystem.
+//
+// /etc is mounted by the initramfs, like /var, so that the writes of early
+// boot, e.g. of machine-id(5), already go to the overlay. The initramfs mounts
+// them below /sysroot and does not prefix the options, so the directories of
+// the overlay are the ones of /sysroot.
+//
+// It returns the upper and work directories of the overlays, which must be
+// created in the tree.
+func (options *FSTabStageOptions) SetReadOnlyRoot(overlayDir string) []string {
+	mounted := make(map[string]bool)
+	for _, fs := range options.FileSystems {
+		mounted[fs.Path] = true
+		switch fs.Path {
+		case "/":
+			fs.Options = appendFSTabOption(fs.Options, "ro")
+		case "/var":
+			fs.Options = appendFSTabOption(fs.Options, "x-initrd.mount")
+		}
+	}
+
+	var dirs []string
+	for _, path := range readOnlyRootOverlays {
+		if mounted[path] {
+			continue
+		}
+		dir := filepath.Join(overlayDir, strings.TrimPrefix(path, "/"))
+		dirs = append(dirs, dir+"/upper", dir+"/work")
+
+		prefix := ""
+		initrd := ""
+		if path == "/etc" {
+			prefix = "/sysroot"
+			initrd = "x-initrd.mount,"
+		}
+		options.FileSystems = append(options.FileSystems, &FSTabEntry{
+			Device:  "overlay",
+			VFSType: "overlay",
+			Path:    path,
+			Options: fmt.Sprintf("lowerdir=%[1]s%[2]s,upperdir=%[1]s%[3]s/upper,workdir=%[1]s%[3]s/work,%[4]sx-systemd.requires-mounts-for=%[1]s%[3]s", prefix, path, dir, initrd),
+		})
+	}
+	if !mounted["/tmp"] {
+		options.FileSystems = append(options.FileSystems, &FSTabEntry{
+			Device:  "tmpfs",
+			VFSType: "tmpfs",
+			Path:    "/tmp",
+			Options: "mode=1777,strictatime,nosuid,nodev",
+		})
+	}
+	return dirs
+}
+
+// appendFSTabOption adds the option to the mount options of an fstab entry,
+// replacing the defaults.
+func appendFSTabOption(options, option string) string {
+	if options == "" || options == "defaults" {
+		return option
+	}
+	return options + "," + option
+}
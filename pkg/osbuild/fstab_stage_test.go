@@ -50,3 +50,38 @@ func TestAddFilesystem(t *testing.T) {
 	}
 	assert.Equal(t, len(filesystems), len(options.FileSystems))
 }
+
+func TestSetReadOnlyRoot(t *testing.T) {
+	options := &FSTabStageOptions{}
+	options.AddFilesystem("76a22bf4-f153-4541-b6c7-0332c0dfaeac", "xfs", "/", "defaults", 0, 0)
+	options.AddFilesystem("bba22bf4-f153-4541-b6c7-0332c0dfaeac", "xfs", "/home", "defaults", 0, 0)
+	options.AddFilesystem("cca22bf4-f153-4541-b6c7-0332c0dfaeac", "xfs", "/var", "nodev", 0, 0)
+
+	dirs := options.SetReadOnlyRoot("/var/lib/readonly-root")
+	assert.Equal(t, []string{
+		"/var/lib/readonly-root/etc/upper",
+		"/var/lib/readonly-root/etc/work",
+		"/var/lib/readonly-root/root/upper",
+		"/var/lib/readonly-root/root/work",
+	}, dirs)
+
+	// /home is a filesystem of its own and stays as it is
+	assert.Equal(t, []*FSTabEntry{
+		{UUID: "76a22bf4-f153-4541-b6c7-0332c0dfaeac", VFSType: "xfs", Path: "/", Options: "ro"},
+		{UUID: "bba22bf4-f153-4541-b6c7-0332c0dfaeac", VFSType: "xfs", Path: "/home", Options: "defaults"},
+		{UUID: "cca22bf4-f153-4541-b6c7-0332c0dfaeac", VFSType: "xfs", Path: "/var", Options: "nodev,x-initrd.mount"},
+		{
+			Device:  "overlay",
+			VFSType: "overlay",
+			Path:    "/etc",
+			Options: "lowerdir=/sysroot/etc,upperdir=/sysroot/var/lib/readonly-root/etc/upper,workdir=/sysroot/var/lib/readonly-root/etc/work,x-initrd.mount,x-systemd.requires-mounts-for=/sysroot/var/lib/readonly-root/etc",
+		},
+		{
+			Device:  "overlay",
+			VFSType: "overlay",
+			Path:    "/root",
+			Options: "lowerdir=/root,upperdir=/var/lib/readonly-root/root/upper,workdir=/var/lib/readonly-root/root/work,x-systemd.requires-mounts-for=/var/lib/readonly-root/root",
+		},
+		{Device: "tmpfs", VFSType: "tmpfs", Path: "/tmp", Options: "mode=1777,strictatime,nosuid,nodev"},
+	}, options.FileSystems)
+}
@@ -13,12 +13,52 @@ type KickstartStageOptions struct {
 	Users map[string]UsersStageOptionsUser `json:"users,omitempty"`
 
 	Groups map[string]GroupsStageOptionsGroup `json:"groups,omitempty"`
+
+	ZeroMBR bool `json:"zerombr,omitempty"`
+
+	ClearPart *ClearPartOptions `json:"clearpart,omitempty"`
+
+	IgnoreDisk *IgnoreDiskOptions `json:"ignoredisk,omitempty"`
+
+	AutoPart *AutoPartOptions `json:"autopart,omitempty"`
+
+	Reboot *RebootOptions `json:"reboot,omitempty"`
+
+	Lang string `json:"lang,omitempty"`
+
+	Keyboard string `json:"keyboard,omitempty"`
+
+	Timezone string `json:"timezone,omitempty"`
+
+	// User interface of the installer: graphical, text or cmdline
+	DisplayMode string `json:"display_mode,omitempty"`
 }
 
 type LiveIMG struct {
 	URL string `json:"url"`
 }
 
+type ClearPartOptions struct {
+	All       bool     `json:"all,omitempty"`
+	Drives    []string `json:"drives,omitempty"`
+	Initlabel bool     `json:"initlabel,omitempty"`
+}
+
+type IgnoreDiskOptions struct {
+	OnlyUse []string `json:"only-use,omitempty"`
+}
+
+type AutoPartOptions struct {
+	// Partitioning scheme: plain, lvm, thinp or btrfs
+	Type   string `json:"type,omitempty"`
+	FSType string `json:"fstype,omitempty"`
+	NoHome bool   `json:"nohome,omitempty"`
+}
+
+type RebootOptions struct {
+	Eject bool `json:"eject,omitempty"`
+}
+
 type OSTreeOptions struct {
 	OSName string `json:"osname"`
 	URL    string `json:"url"`
@@ -0,0 +1,27 @@
+package osbuild
+
+const (
+	// The machine ID is unset, so systemd generates it and treats the boot
+	// as the first boot of the system, see machine-id(5)
+	MachineIdFirstBootYes = "yes"
+	// /etc/machine-id is empty, so systemd generates the machine ID on boot,
+	// but not as the first boot
+	MachineIdFirstBootNo = "no"
+	// The machine ID of the tree is kept
+	MachineIdFirstBootPreserve = "preserve"
+)
+
+type MachineIdStageOptions struct {
+	// Determines the state of /etc/machine-id, one of the MachineIdFirstBoot
+	// values
+	FirstBoot string `json:"first-boot"`
+}
+
+func (MachineIdStageOptions) isStageOptions() {}
+
+func NewMachineIdStage(options *MachineIdStageOptions) *Stage {
+	return &Stage{
+		Type:    "org.osbuild.machine-id",
+		Options: options,
+	}
+}
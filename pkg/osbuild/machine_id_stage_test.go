@@ -0,0 +1,16 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMachineIdStage(t *testing.T) {
+	expectedStage := &Stage{
+		Type:    "org.osbuild.machine-id",
+		Options: &MachineIdStageOptions{FirstBoot: MachineIdFirstBootNo},
+	}
+	actualStage := NewMachineIdStage(&MachineIdStageOptions{FirstBoot: MachineIdFirstBootNo})
+	assert.Equal(t, expectedStage, actualStage)
+}
@@ -40,6 +40,7 @@ func GenMkfsStages(pt *disk.PartitionTable, device *Device) []*Stage {
 		case "vfat":
 			options := &MkfsFATStageOptions{
 				VolID: strings.Replace(fsSpec.UUID, "-", "", -1),
+				Label: fsSpec.Label,
 			}
 			stage = NewMkfsFATStage(options, stageDevices)
 		case "btrfs":
@@ -54,6 +55,12 @@ func GenMkfsStages(pt *disk.PartitionTable, device *Device) []*Stage {
 				Label: fsSpec.Label,
 			}
 			stage = NewMkfsExt4Stage(options, stageDevices)
+		case "swap":
+			options := &MkswapStageOptions{
+				UUID:  fsSpec.UUID,
+				Label: fsSpec.Label,
+			}
+			stage = NewMkswapStage(options, stageDevices)
 		default:
 			panic("unknown fs type " + t)
 		}
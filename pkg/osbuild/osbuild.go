@@ -17,6 +17,11 @@ type Pipeline struct {
 
 	Runner string `json:"runner,omitempty"`
 
+	// Unix timestamp that the stages use as SOURCE_DATE_EPOCH and that the
+	// modification times of the files in the tree are clamped to, for
+	// reproducible builds
+	SourceEpoch *int64 `json:"source-epoch,omitempty"`
+
 	// Sequence of stages that produce the filesystem tree, which is the
 	// payload of the produced image.
 	Stages []*Stage `json:"stages,omitempty"`
@@ -0,0 +1,39 @@
+package osbuild
+
+// Options for the org.osbuild.ostree.encapsulate stage.
+type OSTreeEncapsulateStageOptions struct {
+	// Name of the OCI archive to create
+	Filename string `json:"filename"`
+
+	// Default command of the container
+	Cmd []string `json:"cmd,omitempty"`
+
+	// Maximum number of layers to split the commit into
+	MaxLayers *int `json:"max_layers,omitempty"`
+
+	// Labels of the container, in KEY=VALUE form
+	Labels []string `json:"labels,omitempty"`
+
+	// Version of the encapsulation format
+	FormatVersion *int `json:"format_version,omitempty"`
+}
+
+func (OSTreeEncapsulateStageOptions) isStageOptions() {}
+
+type OSTreeEncapsulateStageInputs struct {
+	Commit *OSTreePullStageInput `json:"commit"`
+}
+
+func (OSTreeEncapsulateStageInputs) isStageInputs() {}
+
+// NewOSTreeEncapsulateStage creates a new org.osbuild.ostree.encapsulate
+// stage that wraps the commit with the given ref, produced by inputPipeline,
+// in an ostree native container archive.
+func NewOSTreeEncapsulateStage(options *OSTreeEncapsulateStageOptions, inputPipeline, ref string) *Stage {
+	inputs := NewOstreePullStageInputs("org.osbuild.pipeline", "name:"+inputPipeline, ref)
+	return &Stage{
+		Type:    "org.osbuild.ostree.encapsulate",
+		Options: options,
+		Inputs:  &OSTreeEncapsulateStageInputs{Commit: inputs.Commits},
+	}
+}
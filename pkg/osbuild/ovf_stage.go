@@ -3,16 +3,58 @@ package osbuild
 import (
 	"fmt"
 	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slices"
 )
 
 const vmdkRegex = "^[a-zA-Z0-9+_.-]*$"
 
+// The range of the VMware virtual hardware versions (vmx-N) supported in the
+// OVF descriptor
+const (
+	OVFMinHardwareVersion = 4
+	OVFMaxHardwareVersion = 21
+)
+
+// Network adapter types supported in the OVF descriptor
+var ovfNetworkAdapters = []string{"vmxnet3", "e1000", "e1000e"}
+
 type OVFStageOptions struct {
 	Vmdk string `json:"vmdk"`
+
+	// Settings of the described virtual machine, the defaults of the stage
+	// are used if unset
+	VirtualMachine *OVFVirtualMachineOptions `json:"virtual_machine,omitempty"`
+}
+
+// OVFVirtualMachineOptions configure the virtual machine described by an OVF
+// descriptor. Zero values keep the defaults of the stage.
+type OVFVirtualMachineOptions struct {
+	// Virtual hardware version, e.g. 19 for vmx-19
+	HardwareVersion int `json:"hardware_version,omitempty"`
+	// Network adapter type: vmxnet3, e1000 or e1000e
+	NetworkAdapter string `json:"network_adapter,omitempty"`
+	// Memory in MiB
+	MemoryMiB uint64 `json:"memory_mib,omitempty"`
+	// Number of virtual CPUs
+	CPUs uint `json:"cpus,omitempty"`
 }
 
 func (OVFStageOptions) isStageOptions() {}
 
+// Validate returns an error if the hardware version is outside of the
+// supported range or the network adapter type is unknown.
+func (o OVFVirtualMachineOptions) Validate() error {
+	if o.HardwareVersion != 0 && (o.HardwareVersion < OVFMinHardwareVersion || o.HardwareVersion > OVFMaxHardwareVersion) {
+		return fmt.Errorf("virtual hardware version %d is outside of the supported range %d-%d", o.HardwareVersion, OVFMinHardwareVersion, OVFMaxHardwareVersion)
+	}
+	if o.NetworkAdapter != "" && !slices.Contains(ovfNetworkAdapters, o.NetworkAdapter) {
+		return fmt.Errorf("unsupported network adapter type %q (supported: %s)", o.NetworkAdapter, strings.Join(ovfNetworkAdapters, ", "))
+	}
+	return nil
+}
+
 func (o OVFStageOptions) validate() error {
 	if o.Vmdk == "" {
 		return fmt.Errorf("'vmdk' option is empty")
@@ -23,6 +65,10 @@ func (o OVFStageOptions) validate() error {
 		return fmt.Errorf("'vmdk' name %q doesn't conform to schema (%s)", o.Vmdk, exp.String())
 	}
 
+	if o.VirtualMachine != nil {
+		return o.VirtualMachine.Validate()
+	}
+
 	return nil
 }
 
@@ -0,0 +1,68 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOVFStage(t *testing.T) {
+	expectedStage := &Stage{
+		Type: "org.osbuild.ovf",
+		Options: &OVFStageOptions{
+			Vmdk:           "image.vmdk",
+			VirtualMachine: &OVFVirtualMachineOptions{HardwareVersion: 19, NetworkAdapter: "vmxnet3", MemoryMiB: 4096, CPUs: 2},
+		},
+	}
+	actualStage := NewOVFStage(&OVFStageOptions{
+		Vmdk:           "image.vmdk",
+		VirtualMachine: &OVFVirtualMachineOptions{HardwareVersion: 19, NetworkAdapter: "vmxnet3", MemoryMiB: 4096, CPUs: 2},
+	})
+	assert.Equal(t, expectedStage, actualStage)
+
+	assert.Panics(t, func() {
+		NewOVFStage(&OVFStageOptions{Vmdk: "image.vmdk", VirtualMachine: &OVFVirtualMachineOptions{HardwareVersion: 3}})
+	})
+}
+
+func TestOVFVirtualMachineOptionsValidate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options OVFVirtualMachineOptions
+		wantErr string
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:    "valid",
+			options: OVFVirtualMachineOptions{HardwareVersion: 21, NetworkAdapter: "e1000", MemoryMiB: 2048, CPUs: 4},
+		},
+		{
+			name:    "hardware version too old",
+			options: OVFVirtualMachineOptions{HardwareVersion: 3},
+			wantErr: "virtual hardware version 3 is outside of the supported range 4-21",
+		},
+		{
+			name:    "hardware version too new",
+			options: OVFVirtualMachineOptions{HardwareVersion: 22},
+			wantErr: "virtual hardware version 22 is outside of the supported range 4-21",
+		},
+		{
+			name:    "unknown network adapter",
+			options: OVFVirtualMachineOptions{NetworkAdapter: "virtio"},
+			wantErr: `unsupported network adapter type "virtio" (supported: vmxnet3, e1000, e1000e)`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
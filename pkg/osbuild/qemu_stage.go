@@ -8,7 +8,10 @@ import (
 // Convert a disk image to a different format.
 //
 // Some formats support format-specific options:
-//   qcow2: The compatibility version can be specified via 'compat'
+//   qcow2: The compatibility version can be specified via 'compat', the
+//          compression of the clusters via 'compression_type', the size of
+//          the clusters via 'cluster_size' and the preallocation via
+//          'preallocation'
 
 type QEMUStageOptions struct {
 	// Filename for resulting image
@@ -22,6 +25,9 @@ func (QEMUStageOptions) isStageOptions() {}
 
 type QEMUFormat string
 type VMDKSubformat string
+type VPCSubformat string
+type QCOW2CompressionType string
+type QCOW2Preallocation string
 
 const (
 	QEMUFormatQCOW2 QEMUFormat = "qcow2"
@@ -35,12 +41,32 @@ const (
 	VMDKSubformatTwoGbMaxExtentSparse VMDKSubformat = "twoGbMaxExtentSparse"
 	VMDKSubformatTwoGbMaxExtentFlat   VMDKSubformat = "twoGbMaxExtentFlat"
 	VMDKSubformatStreamOptimized      VMDKSubformat = "streamOptimized"
+
+	VPCSubformatFixed   VPCSubformat = "fixed"
+	VPCSubformatDynamic VPCSubformat = "dynamic"
+
+	QCOW2CompressionZlib QCOW2CompressionType = "zlib"
+	QCOW2CompressionZstd QCOW2CompressionType = "zstd"
+
+	QCOW2PreallocationOff      QCOW2Preallocation = "off"
+	QCOW2PreallocationMetadata QCOW2Preallocation = "metadata"
+	QCOW2PreallocationFalloc   QCOW2Preallocation = "falloc"
+	QCOW2PreallocationFull     QCOW2Preallocation = "full"
+)
+
+// The range of the qcow2 cluster sizes that qemu-img accepts
+const (
+	QCOW2MinClusterSize = 512
+	QCOW2MaxClusterSize = 2 * 1024 * 1024
 )
 
 type QEMUFormatOptions interface {
 	isQEMUFormatOptions()
 	validate() error
 	formatType() QEMUFormat
+	// qemuImgArgs returns the format specific options of the qemu-img
+	// conversion of the stage
+	qemuImgArgs() []string
 }
 
 type QCOW2Options struct {
@@ -49,6 +75,17 @@ type QCOW2Options struct {
 
 	// The qcow2-compatibility-version to use
 	Compat string `json:"compat"`
+
+	// The compression of the clusters, zlib if unset. zstd requires
+	// qemu-img 5.1 and compat 1.1 or newer.
+	CompressionType QCOW2CompressionType `json:"compression_type,omitempty"`
+
+	// The size of the clusters in bytes, a power of two, 65536 if unset
+	ClusterSize uint64 `json:"cluster_size,omitempty"`
+
+	// The preallocation of the image, off if unset. Preallocated images are
+	// not compressed.
+	Preallocation QCOW2Preallocation `json:"preallocation,omitempty"`
 }
 
 func (QCOW2Options) isQEMUFormatOptions() {}
@@ -57,13 +94,70 @@ func (o QCOW2Options) validate() error {
 	if o.Type != QEMUFormatQCOW2 {
 		return fmt.Errorf("invalid format type %q for %q options", o.Type, QEMUFormatQCOW2)
 	}
+
+	switch o.CompressionType {
+	case "", QCOW2CompressionZlib:
+	case QCOW2CompressionZstd:
+		if o.Compat == "0.10" {
+			return fmt.Errorf("'compression_type' %q requires 'compat' 1.1 or newer", o.CompressionType)
+		}
+	default:
+		return fmt.Errorf("'compression_type' option does not allow %q as a value", o.CompressionType)
+	}
+
+	if o.ClusterSize != 0 && (o.ClusterSize < QCOW2MinClusterSize || o.ClusterSize > QCOW2MaxClusterSize || o.ClusterSize&(o.ClusterSize-1) != 0) {
+		return fmt.Errorf("'cluster_size' option must be a power of two between %d and %d, not %d", QCOW2MinClusterSize, QCOW2MaxClusterSize, o.ClusterSize)
+	}
+
+	switch o.Preallocation {
+	case "", QCOW2PreallocationOff:
+	case QCOW2PreallocationMetadata, QCOW2PreallocationFalloc, QCOW2PreallocationFull:
+		if o.CompressionType != "" {
+			return fmt.Errorf("'preallocation' %q can't be combined with 'compression_type', preallocated images are not compressed", o.Preallocation)
+		}
+	default:
+		return fmt.Errorf("'preallocation' option does not allow %q as a value", o.Preallocation)
+	}
 	return nil
 }
 
+// Validate returns an error if qemu-img doesn't accept the options, e.g. to
+// check the options of users before creating the stage.
+func (o QCOW2Options) Validate() error {
+	o.Type = QEMUFormatQCOW2
+	return o.validate()
+}
+
+// compressed returns true if the clusters of the image are compressed, which
+// qemu-img doesn't support together with preallocation.
+func (o QCOW2Options) compressed() bool {
+	return o.Preallocation == "" || o.Preallocation == QCOW2PreallocationOff
+}
+
 func (o QCOW2Options) formatType() QEMUFormat {
 	return o.Type
 }
 
+func (o QCOW2Options) qemuImgArgs() []string {
+	var argv []string
+	if o.compressed() {
+		argv = append(argv, "-c")
+	}
+	if o.Compat != "" {
+		argv = append(argv, "-o", "compat="+o.Compat)
+	}
+	if o.CompressionType != "" {
+		argv = append(argv, "-o", "compression_type="+string(o.CompressionType))
+	}
+	if o.ClusterSize != 0 {
+		argv = append(argv, "-o", fmt.Sprintf("cluster_size=%d", o.ClusterSize))
+	}
+	if o.Preallocation != "" {
+		argv = append(argv, "-o", "preallocation="+string(o.Preallocation))
+	}
+	return argv
+}
+
 type VDIOptions struct {
 	// The type of the format must be 'vdi'
 	Type QEMUFormat `json:"type"`
@@ -82,12 +176,19 @@ func (o VDIOptions) formatType() QEMUFormat {
 	return o.Type
 }
 
+func (VDIOptions) qemuImgArgs() []string {
+	return nil
+}
+
 type VPCOptions struct {
 	// The type of the format must be 'vpc'
 	Type QEMUFormat `json:"type"`
 
 	// VPC related options
 	ForceSize *bool `json:"force_size,omitempty"`
+
+	// The stage creates fixed images if no subformat is set
+	Subformat VPCSubformat `json:"subformat,omitempty"`
 }
 
 func (VPCOptions) isQEMUFormatOptions() {}
@@ -96,6 +197,10 @@ func (o VPCOptions) validate() error {
 	if o.Type != QEMUFormatVPC {
 		return fmt.Errorf("invalid format type %q for %q options", o.Type, QEMUFormatVPC)
 	}
+
+	if o.Subformat != "" && o.Subformat != VPCSubformatFixed && o.Subformat != VPCSubformatDynamic {
+		return fmt.Errorf("'subformat' option does not allow %q as a value", o.Subformat)
+	}
 	return nil
 }
 
@@ -103,6 +208,19 @@ func (o VPCOptions) formatType() QEMUFormat {
 	return o.Type
 }
 
+func (o VPCOptions) qemuImgArgs() []string {
+	subformat := o.Subformat
+	if subformat == "" {
+		subformat = VPCSubformatFixed
+	}
+	argv := []string{"-o", "subformat=" + string(subformat)}
+	// the stage forces the size unless disabled
+	if o.ForceSize == nil || *o.ForceSize {
+		argv = append(argv, "-o", "force_size")
+	}
+	return argv
+}
+
 type VMDKOptions struct {
 	// The type of the format must be 'vmdk'
 	Type QEMUFormat `json:"type"`
@@ -144,6 +262,14 @@ func (o VMDKOptions) formatType() QEMUFormat {
 	return o.Type
 }
 
+func (o VMDKOptions) qemuImgArgs() []string {
+	argv := []string{"-c"}
+	if o.Subformat != "" {
+		argv = append(argv, "-o", "subformat="+string(o.Subformat))
+	}
+	return argv
+}
+
 type VHDXOptions struct {
 	// The type of the format must be 'vhdx'
 	Type QEMUFormat `json:"type"`
@@ -162,6 +288,10 @@ func (o VHDXOptions) formatType() QEMUFormat {
 	return o.Type
 }
 
+func (VHDXOptions) qemuImgArgs() []string {
+	return nil
+}
+
 type QEMUStageInputs struct {
 	Image *FilesInput `json:"image"`
 }
@@ -241,6 +371,18 @@ func NewQEMUStageOptions(filename string, format QEMUFormat, formatOptions QEMUF
 	}
 }
 
+// QEMUImgConvertArgs returns the qemu-img command that the stage runs to
+// convert the source image to the file and format of the options, e.g. to
+// convert images outside of osbuild in the same way.
+func QEMUImgConvertArgs(options *QEMUStageOptions, source string) ([]string, error) {
+	if err := options.Format.validate(); err != nil {
+		return nil, err
+	}
+	argv := []string{"qemu-img", "convert", "-O", string(options.Format.formatType())}
+	argv = append(argv, options.Format.qemuImgArgs()...)
+	return append(argv, source, options.Filename), nil
+}
+
 // alias for custom marshaller
 type qemuStageOptions QEMUStageOptions
 
@@ -142,6 +142,29 @@ func TestNewQEMUStageOptions(t *testing.T) {
 				},
 			},
 		},
+		{
+			Filename: "image.vpc",
+			Format:   QEMUFormatVPC,
+			FormatOptions: VPCOptions{
+				Subformat: VPCSubformatDynamic,
+			},
+			ExpectedOptions: &QEMUStageOptions{
+				Filename: "image.vpc",
+				Format: VPCOptions{
+					Type:      QEMUFormatVPC,
+					Subformat: VPCSubformatDynamic,
+				},
+			},
+		},
+		// unknown vpc subformat
+		{
+			Filename: "image.vpc",
+			Format:   QEMUFormatVPC,
+			FormatOptions: VPCOptions{
+				Subformat: "sparse",
+			},
+			Error: true,
+		},
 		{
 			Filename:      "image.vmdk",
 			Format:        QEMUFormatVMDK,
@@ -222,3 +245,75 @@ func TestNewQEMUStageOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestQEMUImgConvertArgs(t *testing.T) {
+	argv, err := QEMUImgConvertArgs(NewQEMUStageOptions("image.vpc", QEMUFormatVPC, VPCOptions{ForceSize: common.ToPtr(false)}), "disk.raw")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"qemu-img", "convert", "-O", "vpc", "-o", "subformat=fixed", "disk.raw", "image.vpc"}, argv)
+
+	argv, err = QEMUImgConvertArgs(NewQEMUStageOptions("image.qcow2", QEMUFormatQCOW2, QCOW2Options{Compat: "1.1"}), "disk.raw")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"qemu-img", "convert", "-O", "qcow2", "-c", "-o", "compat=1.1", "disk.raw", "image.qcow2"}, argv)
+
+	argv, err = QEMUImgConvertArgs(NewQEMUStageOptions("image.qcow2", QEMUFormatQCOW2, QCOW2Options{Compat: "1.1", CompressionType: QCOW2CompressionZstd}), "disk.raw")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"qemu-img", "convert", "-O", "qcow2", "-c", "-o", "compat=1.1", "-o", "compression_type=zstd", "disk.raw", "image.qcow2"}, argv)
+
+	argv, err = QEMUImgConvertArgs(NewQEMUStageOptions("image.qcow2", QEMUFormatQCOW2, QCOW2Options{ClusterSize: 2 * 1024 * 1024, Preallocation: QCOW2PreallocationMetadata}), "disk.raw")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"qemu-img", "convert", "-O", "qcow2", "-o", "cluster_size=2097152", "-o", "preallocation=metadata", "disk.raw", "image.qcow2"}, argv)
+
+	argv, err = QEMUImgConvertArgs(NewQEMUStageOptions("image.qcow2", QEMUFormatQCOW2, QCOW2Options{Preallocation: QCOW2PreallocationOff}), "disk.raw")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"qemu-img", "convert", "-O", "qcow2", "-c", "-o", "preallocation=off", "disk.raw", "image.qcow2"}, argv)
+
+	_, err = QEMUImgConvertArgs(&QEMUStageOptions{Filename: "image.vmdk", Format: VMDKOptions{Type: QEMUFormatVMDK, Subformat: "sparse"}}, "disk.raw")
+	assert.EqualError(t, err, `'subformat' option does not allow "sparse" as a value`)
+
+	_, err = QEMUImgConvertArgs(&QEMUStageOptions{Filename: "image.qcow2", Format: QCOW2Options{Type: QEMUFormatQCOW2, CompressionType: "lz4"}}, "disk.raw")
+	assert.EqualError(t, err, `'compression_type' option does not allow "lz4" as a value`)
+
+	_, err = QEMUImgConvertArgs(&QEMUStageOptions{Filename: "image.qcow2", Format: QCOW2Options{Type: QEMUFormatQCOW2, Compat: "0.10", CompressionType: QCOW2CompressionZstd}}, "disk.raw")
+	assert.EqualError(t, err, `'compression_type' "zstd" requires 'compat' 1.1 or newer`)
+}
+
+func TestQCOW2OptionsValidate(t *testing.T) {
+	testCases := []struct {
+		options QCOW2Options
+		wantErr string
+	}{
+		{options: QCOW2Options{}},
+		{options: QCOW2Options{ClusterSize: 512, Preallocation: QCOW2PreallocationFull}},
+		{options: QCOW2Options{ClusterSize: 65536, Preallocation: QCOW2PreallocationFalloc}},
+		{options: QCOW2Options{Preallocation: QCOW2PreallocationOff, CompressionType: QCOW2CompressionZstd}},
+		{
+			options: QCOW2Options{ClusterSize: 256},
+			wantErr: "'cluster_size' option must be a power of two between 512 and 2097152, not 256",
+		},
+		{
+			options: QCOW2Options{ClusterSize: 4 * 1024 * 1024},
+			wantErr: "'cluster_size' option must be a power of two between 512 and 2097152, not 4194304",
+		},
+		{
+			options: QCOW2Options{ClusterSize: 65535},
+			wantErr: "'cluster_size' option must be a power of two between 512 and 2097152, not 65535",
+		},
+		{
+			options: QCOW2Options{Preallocation: "sparse"},
+			wantErr: `'preallocation' option does not allow "sparse" as a value`,
+		},
+		{
+			options: QCOW2Options{Preallocation: QCOW2PreallocationMetadata, CompressionType: QCOW2CompressionZlib},
+			wantErr: `'preallocation' "metadata" can't be combined with 'compression_type', preallocated images are not compressed`,
+		},
+	}
+
+	for _, tc := range testCases {
+		err := tc.options.Validate()
+		if tc.wantErr == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, tc.wantErr)
+		}
+	}
+}
@@ -19,6 +19,9 @@ type RPMStageOptions struct {
 
 	Exclude *Exclude `json:"exclude,omitempty"`
 
+	// Only install the translations of these languages, e.g. en_US or de
+	InstallLangs []string `json:"install_langs,omitempty"`
+
 	// Create the '/run/ostree-booted' marker
 	OSTreeBooted *bool `json:"ostree_booted,omitempty"`
 }
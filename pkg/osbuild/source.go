@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 
+	"github.com/osbuild/images/internal/fsnode"
 	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/ostree"
 	"github.com/osbuild/images/pkg/rpmmd"
@@ -54,11 +55,11 @@ func (sources *Sources) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func GenSources(packages []rpmmd.PackageSpec, ostreeCommits []ostree.CommitSpec, inlineData []string, containers []container.Spec) (Sources, error) {
+func GenSources(packages []rpmmd.PackageSpec, ostreeCommits []ostree.CommitSpec, inlineData []string, containers []container.Spec, remoteFiles []*fsnode.File) (Sources, error) {
 	sources := Sources{}
 
-	// collect rpm package sources
-	if len(packages) > 0 {
+	// collect rpm package and remote file sources
+	if len(packages) > 0 || len(remoteFiles) > 0 {
 		curl := NewCurlSource()
 		for _, pkg := range packages {
 			err := curl.AddPackage(pkg)
@@ -66,6 +67,12 @@ func GenSources(packages []rpmmd.PackageSpec, ostreeCommits []ostree.CommitSpec,
 				return nil, err
 			}
 		}
+		for _, file := range remoteFiles {
+			err := curl.AddFile(file)
+			if err != nil {
+				return nil, err
+			}
+		}
 		sources["org.osbuild.curl"] = curl
 	}
 
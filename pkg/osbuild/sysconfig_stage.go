@@ -1,5 +1,7 @@
 package osbuild
 
+import "strings"
+
 type SysconfigStageOptions struct {
 	Kernel         *SysconfigKernelOptions  `json:"kernel,omitempty"`
 	Network        *SysconfigNetworkOptions `json:"network,omitempty"`
@@ -27,6 +29,19 @@ type SysconfigKernelOptions struct {
 	DefaultKernel string `json:"default_kernel,omitempty"`
 }
 
+// WithDefaultKernel returns a copy of the sysconfig options whose default
+// kernel, the kernel package that kernel updates make the default boot entry,
+// is the given kernel package instead of the default one, e.g. kernel-rt-core
+// instead of kernel-core.
+func (o SysconfigStageOptions) WithDefaultKernel(kernelName string) *SysconfigStageOptions {
+	if o.Kernel != nil && o.Kernel.DefaultKernel != "" {
+		kernel := *o.Kernel
+		kernel.DefaultKernel = kernelName + strings.TrimPrefix(kernel.DefaultKernel, "kernel")
+		o.Kernel = &kernel
+	}
+	return &o
+}
+
 type SysconfigDesktopOptions struct {
 	Preferred      string `json:"preferred,omitempty"`
 	DisplayManager string `json:"displaymanager,omitempty"`
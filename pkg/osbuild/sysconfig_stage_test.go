@@ -14,3 +14,25 @@ func TestNewSysconfigStage(t *testing.T) {
 	actualStage := NewSysconfigStage(&SysconfigStageOptions{})
 	assert.Equal(t, expectedStage, actualStage)
 }
+
+func TestSysconfigWithDefaultKernel(t *testing.T) {
+	options := &SysconfigStageOptions{
+		Kernel: &SysconfigKernelOptions{
+			UpdateDefault: true,
+			DefaultKernel: "kernel-core",
+		},
+		Network: &SysconfigNetworkOptions{Networking: true},
+	}
+	rt := options.WithDefaultKernel("kernel-rt")
+	assert.Equal(t, &SysconfigStageOptions{
+		Kernel: &SysconfigKernelOptions{
+			UpdateDefault: true,
+			DefaultKernel: "kernel-rt-core",
+		},
+		Network: &SysconfigNetworkOptions{Networking: true},
+	}, rt)
+	assert.Equal(t, "kernel-core", options.Kernel.DefaultKernel)
+
+	assert.Equal(t, "kernel-debug", (&SysconfigStageOptions{Kernel: &SysconfigKernelOptions{DefaultKernel: "kernel"}}).WithDefaultKernel("kernel-debug").Kernel.DefaultKernel)
+	assert.Nil(t, SysconfigStageOptions{}.WithDefaultKernel("kernel-rt").Kernel)
+}
@@ -0,0 +1,10 @@
+package osbuild
+
+// NewUpdateCATrustStage creates a new org.osbuild.pki.update-ca-trust stage,
+// which regenerates the system-wide trust store of the tree from its anchors,
+// see update-ca-trust(8).
+func NewUpdateCATrustStage() *Stage {
+	return &Stage{
+		Type: "org.osbuild.pki.update-ca-trust",
+	}
+}
@@ -0,0 +1,15 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUpdateCATrustStage(t *testing.T) {
+	expectedStage := &Stage{
+		Type: "org.osbuild.pki.update-ca-trust",
+	}
+	actualStage := NewUpdateCATrustStage()
+	assert.Equal(t, expectedStage, actualStage)
+}
@@ -0,0 +1,17 @@
+package osbuild
+
+type UpdateCryptoPoliciesStageOptions struct {
+	// The system-wide crypto policy to set, e.g. FIPS
+	Policy string `json:"policy"`
+}
+
+func (UpdateCryptoPoliciesStageOptions) isStageOptions() {}
+
+// NewUpdateCryptoPoliciesStage creates a stage that sets the system-wide
+// crypto policy of the tree.
+func NewUpdateCryptoPoliciesStage(options *UpdateCryptoPoliciesStageOptions) *Stage {
+	return &Stage{
+		Type:    "org.osbuild.update-crypto-policies",
+		Options: options,
+	}
+}
@@ -2,7 +2,6 @@ package osbuild
 
 import (
 	"github.com/osbuild/images/internal/users"
-	"github.com/osbuild/images/pkg/crypt"
 )
 
 type UsersStageOptions struct {
@@ -34,7 +33,7 @@ func NewUsersStageOptions(userCustomizations []users.User, omitKey bool) (*Users
 		return nil, nil
 	}
 
-	users := make(map[string]UsersStageOptionsUser, len(userCustomizations))
+	stageUsers := make(map[string]UsersStageOptionsUser, len(userCustomizations))
 	for _, uc := range userCustomizations {
 		// Don't hash empty passwords, set to nil to lock account
 		if uc.Password != nil && len(*uc.Password) == 0 {
@@ -42,8 +41,8 @@ func NewUsersStageOptions(userCustomizations []users.User, omitKey bool) (*Users
 		}
 
 		// Hash non-empty un-hashed passwords
-		if uc.Password != nil && !crypt.PasswordIsCrypted(*uc.Password) {
-			cryptedPassword, err := crypt.CryptSHA512(*uc.Password)
+		if uc.Password != nil {
+			cryptedPassword, err := users.HashPassword(*uc.Password)
 			if err != nil {
 				return nil, err
 			}
@@ -64,26 +63,26 @@ func NewUsersStageOptions(userCustomizations []users.User, omitKey bool) (*Users
 		if !omitKey {
 			user.Key = uc.Key
 		}
-		users[uc.Name] = user
+		stageUsers[uc.Name] = user
 	}
 
-	return &UsersStageOptions{Users: users}, nil
+	return &UsersStageOptions{Users: stageUsers}, nil
 }
 
-func GenUsersStage(users []users.User, omitKey bool) (*Stage, error) {
+func GenUsersStage(userCustomizations []users.User, omitKey bool) (*Stage, error) {
 	options := &UsersStageOptions{
-		Users: make(map[string]UsersStageOptionsUser, len(users)),
+		Users: make(map[string]UsersStageOptionsUser, len(userCustomizations)),
 	}
 
-	for _, user := range users {
+	for _, user := range userCustomizations {
 		// Don't hash empty passwords, set to nil to lock account
 		if user.Password != nil && len(*user.Password) == 0 {
 			user.Password = nil
 		}
 
 		// Hash non-empty un-hashed passwords
-		if user.Password != nil && !crypt.PasswordIsCrypted(*user.Password) {
-			cryptedPassword, err := crypt.CryptSHA512(*user.Password)
+		if user.Password != nil {
+			cryptedPassword, err := users.HashPassword(*user.Password)
 			if err != nil {
 				return nil, err
 			}
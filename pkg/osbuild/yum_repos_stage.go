@@ -98,6 +98,12 @@ func repoConfigToYumRepository(repo rpmmd.RepoConfig) YumRepository {
 		sslVerify = common.ToPtr(!ignoreSSL)
 	}
 
+	// make the default verification of the packages explicit
+	gpgCheck := repo.CheckGPG
+	if gpgCheck == nil && len(keys) > 0 {
+		gpgCheck = common.ToPtr(repo.GPGCheckEnabled())
+	}
+
 	yumRepo := YumRepository{
 		Id:           repo.Id,
 		Name:         repo.Name,
@@ -105,7 +111,7 @@ func repoConfigToYumRepository(repo rpmmd.RepoConfig) YumRepository {
 		Metalink:     repo.Metalink,
 		BaseURLs:     urls,
 		GPGKey:       keys,
-		GPGCheck:     repo.CheckGPG,
+		GPGCheck:     gpgCheck,
 		RepoGPGCheck: repo.CheckRepoGPG,
 		Enabled:      repo.Enabled,
 		Priority:     repo.Priority,
@@ -187,3 +187,36 @@ func TestYumReposStageOptionsValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestNewYumReposStageOptionsGPGCheck(t *testing.T) {
+	key := "-----BEGIN PGP PUBLIC KEY BLOCK-----\n\nkey\n-----END PGP PUBLIC KEY BLOCK-----\n"
+	options := NewYumReposStageOptions("testing.repo", []rpmmd.RepoConfig{
+		{
+			Id:       "default",
+			BaseURLs: []string{"http://example.org/default"},
+			GPGKeys:  []string{key},
+		},
+		{
+			Id:           "disabled",
+			BaseURLs:     []string{"http://example.org/disabled"},
+			GPGKeys:      []string{key},
+			CheckGPG:     common.ToPtr(false),
+			CheckRepoGPG: common.ToPtr(true),
+		},
+		{
+			Id:       "no-keys",
+			BaseURLs: []string{"http://example.org/no-keys"},
+		},
+	})
+
+	assert.Len(t, options.Repos, 3)
+	assert.Equal(t, common.ToPtr(true), options.Repos[0].GPGCheck)
+	assert.Nil(t, options.Repos[0].RepoGPGCheck)
+	assert.Equal(t, []string{key}, options.Repos[0].GPGKey)
+
+	assert.Equal(t, common.ToPtr(false), options.Repos[1].GPGCheck)
+	assert.Equal(t, common.ToPtr(true), options.Repos[1].RepoGPGCheck)
+
+	assert.Nil(t, options.Repos[2].GPGCheck)
+	assert.Empty(t, options.Repos[2].GPGKey)
+}
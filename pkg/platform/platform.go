@@ -19,6 +19,8 @@ const ( // image format enum
 	FORMAT_VHD
 	FORMAT_GCE
 	FORMAT_OVA
+	FORMAT_VAGRANT_LIBVIRT
+	FORMAT_VAGRANT_VIRTUALBOX
 )
 
 func (a Arch) String() string {
@@ -52,6 +54,10 @@ func (f ImageFormat) String() string {
 		return "gce"
 	case FORMAT_OVA:
 		return "ova"
+	case FORMAT_VAGRANT_LIBVIRT:
+		return "vagrant_libvirt"
+	case FORMAT_VAGRANT_VIRTUALBOX:
+		return "vagrant_virtualbox"
 	default:
 		panic("invalid image format")
 	}
@@ -31,12 +31,18 @@ type RepoConfig struct {
 	// the repo id is not always required and is ignored in some cases.
 	// For example, it is not required in dnf-json, but it is a required
 	// field for creating a repo file in `/etc/yum.repos.d/`
-	Id             string   `json:"id,omitempty"`
-	Name           string   `json:"name,omitempty"`
-	BaseURLs       []string `json:"baseurls,omitempty"`
-	Metalink       string   `json:"metalink,omitempty"`
-	MirrorList     string   `json:"mirrorlist,omitempty"`
-	GPGKeys        []string `json:"gpgkeys,omitempty"`
+	Id         string   `json:"id,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	BaseURLs   []string `json:"baseurls,omitempty"`
+	Metalink   string   `json:"metalink,omitempty"`
+	MirrorList string   `json:"mirrorlist,omitempty"`
+	// GPG keys of the repository, either as URLs or as inline ASCII armored
+	// key content. Only inline keys can be used to verify the packages
+	// when they are installed.
+	GPGKeys []string `json:"gpgkeys,omitempty"`
+	// Verify the signatures of the packages and of the repository metadata.
+	// When unset, package signatures are verified if the repository has
+	// GPG keys and metadata signatures are not verified.
 	CheckGPG       *bool    `json:"check_gpg,omitempty"`
 	CheckRepoGPG   *bool    `json:"check_repo_gpg,omitempty"`
 	Priority       *int     `json:"priority,omitempty"`
@@ -46,6 +52,10 @@ type RepoConfig struct {
 	Enabled        *bool    `json:"enabled,omitempty"`
 	ImageTypeTags  []string `json:"image_type_tags,omitempty"`
 	PackageSets    []string `json:"package_sets,omitempty"`
+	// Proxy that dnf downloads the repository metadata through, e.g.
+	// http://proxy.example.com:3128. It is not part of the hash, the
+	// repository content is the same with or without it.
+	Proxy string `json:"proxy,omitempty"`
 }
 
 // Hash calculates an ID string that uniquely represents a repository
@@ -56,7 +66,12 @@ func (r *RepoConfig) Hash() string {
 		return fmt.Sprintf("%T", b)
 	}
 	bpts := func(b *bool) string {
-		return fmt.Sprintf("%T", b)
+		// unset values keep their original representation so that the
+		// hashes of existing configurations do not change
+		if b == nil {
+			return fmt.Sprintf("%T", b)
+		}
+		return fmt.Sprintf("%t", *b)
 	}
 	ats := func(s []string) string {
 		return strings.Join(s, "")
@@ -72,6 +87,22 @@ func (r *RepoConfig) Hash() string {
 		bts(r.RHSM))))
 }
 
+// GPGCheckEnabled returns true if the signatures of the packages from the
+// repository must be verified. Verification is enabled by default for
+// repositories with GPG keys.
+func (r *RepoConfig) GPGCheckEnabled() bool {
+	if r.CheckGPG != nil {
+		return *r.CheckGPG
+	}
+	return len(r.GPGKeys) > 0
+}
+
+// RepoGPGCheckEnabled returns true if the signature of the repository
+// metadata must be verified.
+func (r *RepoConfig) RepoGPGCheckEnabled() bool {
+	return r.CheckRepoGPG != nil && *r.CheckRepoGPG
+}
+
 type DistrosRepoConfigs map[string]map[string][]RepoConfig
 
 type PackageList []Package
@@ -127,6 +158,9 @@ type PackageSet struct {
 	Exclude         []string
 	Repositories    []RepoConfig
 	InstallWeakDeps bool
+	// Module streams to enable before depsolving, as name:stream or
+	// name:stream/profile. The packages of the profiles are installed.
+	EnabledModules []string
 }
 
 // Append the Include and Exclude package list from another PackageSet and
@@ -134,6 +168,7 @@ type PackageSet struct {
 func (ps PackageSet) Append(other PackageSet) PackageSet {
 	ps.Include = append(ps.Include, other.Include...)
 	ps.Exclude = append(ps.Exclude, other.Exclude...)
+	ps.EnabledModules = append(ps.EnabledModules, other.EnabledModules...)
 	return ps
 }
 
@@ -51,3 +51,37 @@ func TestPackageSpecGetNEVRA(t *testing.T) {
 	assert.Equal(t, "grub2-1:2.06-94.fc38.noarch", specs[1].GetNEVRA())
 
 }
+
+func TestRepoConfigGPGCheckEnabled(t *testing.T) {
+	yes := true
+	no := false
+	tests := []struct {
+		name string
+		repo RepoConfig
+		want bool
+	}{
+		{"no-keys", RepoConfig{}, false},
+		{"keys", RepoConfig{GPGKeys: []string{"https://example.org/key"}}, true},
+		{"keys-disabled", RepoConfig{GPGKeys: []string{"https://example.org/key"}, CheckGPG: &no}, false},
+		{"no-keys-enabled", RepoConfig{CheckGPG: &yes}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.repo.GPGCheckEnabled())
+		})
+	}
+}
+
+func TestRepoConfigHashGPGCheck(t *testing.T) {
+	yes := true
+	no := false
+	repo := RepoConfig{BaseURLs: []string{"https://example.org/repo"}}
+	enabled := repo
+	enabled.CheckGPG = &yes
+	disabled := repo
+	disabled.CheckGPG = &no
+
+	assert.NotEqual(t, repo.Hash(), enabled.Hash())
+	assert.NotEqual(t, repo.Hash(), disabled.Hash())
+	assert.NotEqual(t, enabled.Hash(), disabled.Hash())
+}